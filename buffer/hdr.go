@@ -0,0 +1,226 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package buffer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	stdmath "math"
+	"os"
+	"strings"
+
+	"poly.red/math"
+)
+
+// HDR is a high dynamic range image of linear RGB radiance values.
+type HDR struct {
+	Width, Height int
+	// Pix holds the RGB values of the pixels, from top to bottom and left
+	// to right.
+	Pix []float32
+}
+
+// NewHDR returns a black HDR image of the given size.
+func NewHDR(width, height int) *HDR {
+	return &HDR{Width: width, Height: height, Pix: make([]float32, width*height*3)}
+}
+
+// At returns the RGB value of the pixel (x, y).
+func (h *HDR) At(x, y int) math.Vec4[float32] {
+	i := (y*h.Width + x) * 3
+	return math.NewVec4(h.Pix[i], h.Pix[i+1], h.Pix[i+2], 0)
+}
+
+// Set sets the RGB value of the pixel (x, y).
+func (h *HDR) Set(x, y int, c math.Vec4[float32]) {
+	i := (y*h.Width + x) * 3
+	h.Pix[i], h.Pix[i+1], h.Pix[i+2] = c.X, c.Y, c.Z
+}
+
+// Sample bilinearly samples the image at the coordinates u, v in [0, 1],
+// where v = 0 is the top row. The image wraps around horizontally and is
+// clamped vertically, as an equirectangular map of the sphere is.
+func (h *HDR) Sample(u, v float32) math.Vec4[float32] {
+	fx := u*float32(h.Width) - 0.5
+	fy := math.Clamp(v*float32(h.Height)-0.5, 0, float32(h.Height-1))
+	x0f, y0f := math.Floor(fx), math.Floor(fy)
+	tx, ty := fx-x0f, fy-y0f
+	x0, y0 := int(x0f)%h.Width, int(y0f)
+	if x0 < 0 {
+		x0 += h.Width
+	}
+	x1, y1 := x0+1, y0+1
+	if x1 >= h.Width {
+		x1 -= h.Width
+	}
+	if y1 >= h.Height {
+		y1 = h.Height - 1
+	}
+	w00, w10 := (1-tx)*(1-ty), tx*(1-ty)
+	w01, w11 := (1-tx)*ty, tx*ty
+	i00, i10 := (y0*h.Width+x0)*3, (y0*h.Width+x1)*3
+	i01, i11 := (y1*h.Width+x0)*3, (y1*h.Width+x1)*3
+	var c [3]float32
+	for k := range c {
+		c[k] = h.Pix[i00+k]*w00 + h.Pix[i10+k]*w10 + h.Pix[i01+k]*w01 + h.Pix[i11+k]*w11
+	}
+	return math.NewVec4(c[0], c[1], c[2], 0)
+}
+
+// LoadHDR loads a Radiance RGBE (.hdr) image from the given file.
+func LoadHDR(path string) (*HDR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("loader: cannot open file %s, err: %w", path, err)
+	}
+	defer f.Close()
+
+	img, err := DecodeHDR(f)
+	if err != nil {
+		return nil, fmt.Errorf("loader: cannot load hdr image, path: %s, err: %w", path, err)
+	}
+	return img, nil
+}
+
+var errHDRFormat = errors.New("hdr: invalid format")
+
+// DecodeHDR decodes a Radiance RGBE image in the 32-bit_rle_rgbe format,
+// with flat or run-length encoded scanlines, and the standard -Y H +X W
+// orientation.
+func DecodeHDR(r io.Reader) (*HDR, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(magic, "#?") {
+		return nil, fmt.Errorf("%w: missing signature", errHDRFormat)
+	}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if f, ok := strings.CutPrefix(line, "FORMAT="); ok && f != "32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("%w: unsupported format %s", errHDRFormat, f)
+		}
+	}
+	res, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	var w, h int
+	if _, err := fmt.Sscanf(res, "-Y %d +X %d", &h, &w); err != nil {
+		return nil, fmt.Errorf("%w: unsupported resolution %q", errHDRFormat, strings.TrimSpace(res))
+	}
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("%w: invalid size %dx%d", errHDRFormat, w, h)
+	}
+
+	img := NewHDR(w, h)
+	scan := make([]byte, w*4)
+	for y := 0; y < h; y++ {
+		if err := readHDRScanline(br, scan); err != nil {
+			return nil, err
+		}
+		for x := 0; x < w; x++ {
+			img.Set(x, y, rgbe(scan[x*4], scan[x*4+1], scan[x*4+2], scan[x*4+3]))
+		}
+	}
+	return img, nil
+}
+
+// readHDRScanline reads a scanline of RGBE pixels into scan.
+func readHDRScanline(br *bufio.Reader, scan []byte) error {
+	w := len(scan) / 4
+	if _, err := io.ReadFull(br, scan[:4]); err != nil {
+		return err
+	}
+
+	// Scanlines of 8 to 0x7fff pixels may be run-length encoded per
+	// component, which is signaled by the pixel [2, 2, hi, lo].
+	if w < 8 || w > 0x7fff || scan[0] != 2 || scan[1] != 2 || scan[2]&0x80 != 0 {
+		_, err := io.ReadFull(br, scan[4:])
+		return err
+	}
+	if int(scan[2])<<8|int(scan[3]) != w {
+		return fmt.Errorf("%w: scanline length mismatch", errHDRFormat)
+	}
+	for c := 0; c < 4; c++ {
+		for x := 0; x < w; {
+			n, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if n > 128 {
+				// A run of the same value.
+				n -= 128
+				v, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				if n == 0 || x+int(n) > w {
+					return fmt.Errorf("%w: bad run length", errHDRFormat)
+				}
+				for i := 0; i < int(n); i++ {
+					scan[(x+i)*4+c] = v
+				}
+			} else {
+				// A dump of different values.
+				if n == 0 || x+int(n) > w {
+					return fmt.Errorf("%w: bad dump length", errHDRFormat)
+				}
+				for i := 0; i < int(n); i++ {
+					v, err := br.ReadByte()
+					if err != nil {
+						return err
+					}
+					scan[(x+i)*4+c] = v
+				}
+			}
+			x += int(n)
+		}
+	}
+	return nil
+}
+
+// rgbe converts a shared exponent RGBE pixel to RGB.
+func rgbe(r, g, b, e uint8) math.Vec4[float32] {
+	if e == 0 {
+		return math.Vec4[float32]{}
+	}
+	f := float32(stdmath.Ldexp(1, int(e)-(128+8)))
+	return math.NewVec4(
+		(float32(r)+0.5)*f,
+		(float32(g)+0.5)*f,
+		(float32(b)+0.5)*f,
+		0,
+	)
+}
+
+// EncodeHDR encodes the given image as a Radiance RGBE image with flat
+// scanlines.
+func EncodeHDR(w io.Writer, img *HDR) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", img.Height, img.Width)
+	for i := 0; i < len(img.Pix); i += 3 {
+		r, g, b := img.Pix[i], img.Pix[i+1], img.Pix[i+2]
+		m := math.Max(r, g, b)
+		if m < 1e-32 {
+			bw.Write([]byte{0, 0, 0, 0})
+			continue
+		}
+		frac, exp := stdmath.Frexp(float64(m))
+		s := float32(frac) * 256 / m
+		bw.Write([]byte{uint8(r * s), uint8(g * s), uint8(b * s), uint8(exp + 128)})
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package buffer_test
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"poly.red/buffer"
+	pmath "poly.red/math"
+)
+
+func TestHDR(t *testing.T) {
+	img := buffer.NewHDR(16, 4)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			img.Set(x, y, pmath.NewVec4(float32(x)/4, float32(y)*10, 0.001, 0))
+		}
+	}
+
+	var b bytes.Buffer
+	if err := buffer.EncodeHDR(&b, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := buffer.DecodeHDR(&b)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Width != img.Width || got.Height != img.Height {
+		t.Fatalf("size: got %dx%d, want %dx%d", got.Width, got.Height, img.Width, img.Height)
+	}
+	for i, want := range img.Pix {
+		// The shared exponent keeps 8 bits of the largest component.
+		max := float32(math.Max(float64(img.Pix[i-i%3]), math.Max(float64(img.Pix[i-i%3+1]), float64(img.Pix[i-i%3+2]))))
+		if d := got.Pix[i] - want; d > max/128 || d < -max/128 {
+			t.Fatalf("pixel %d: got %v, want %v", i/3, got.Pix[i], want)
+		}
+	}
+
+	// Bilinear samples wrap around horizontally.
+	if c := got.Sample(0, 0); math.Abs(float64(c.X-(got.At(0, 0).X+got.At(15, 0).X)/2)) > 1e-5 {
+		t.Fatalf("wrap: got %v, want the average of the first and the last column", c.X)
+	}
+}
+
+func TestDecodeHDRRLE(t *testing.T) {
+	// A scanline of 8 pixels, run-length encoded per component: a run of
+	// 8 red values, a dump of 8 green values, and runs of blue and exponent.
+	var b bytes.Buffer
+	b.WriteString("#?RADIANCE\n# comment\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 8\n")
+	b.Write([]byte{2, 2, 0, 8})
+	b.Write([]byte{128 + 8, 128})
+	b.Write([]byte{8, 0, 16, 32, 48, 64, 80, 96, 112})
+	b.Write([]byte{128 + 8, 0})
+	b.Write([]byte{128 + 8, 129})
+
+	img, err := buffer.DecodeHDR(&b)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for x := 0; x < 8; x++ {
+		c := img.At(x, 0)
+		// The exponent 129 scales the mantissas by 2/256.
+		wantG := (float32(x*16) + 0.5) / 128
+		if c.X != 128.5/128 || c.Y != wantG || c.Z != 0.5/128 {
+			t.Fatalf("pixel %d: got %v, want (%v, %v, %v)", x, c, 128.5/128, wantG, 0.5/128)
+		}
+	}
+
+	for _, bad := range []string{
+		"P6\n",
+		"#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n\x00\x00\x00\x00",
+		"#?RADIANCE\n\n+Y 1 +X 1\n\x00\x00\x00\x00",
+		"#?RADIANCE\n\n-Y 1 +X 8\n\x02\x02\x00\x08\x00",
+	} {
+		if _, err := buffer.DecodeHDR(strings.NewReader(bad)); err == nil {
+			t.Errorf("decode %q: want an error", bad)
+		}
+	}
+}
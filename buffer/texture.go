@@ -30,6 +30,8 @@ type Texture struct {
 	mipmap    []*image.RGBA
 	image     *image.RGBA
 	debug     bool
+	source    string
+	srgb      bool
 }
 
 func NewTexture(opts ...TextureOpt) *Texture {
@@ -78,6 +80,20 @@ func (t *Texture) UseMipmap() bool {
 	return t.useMipmap
 }
 
+// Source returns the path of the image file that the texture was loaded
+// from, or "" if it was not, and whether the sRGB colors of the file were
+// converted to linear.
+func (t *Texture) Source() (path string, srgb bool) {
+	return t.source, t.srgb
+}
+
+// Mipmap returns the levels of the texture's mipmap, level 0 first: the
+// texture image followed by its successive halvings. The levels are shared
+// with the texture and must not be modified.
+func (t *Texture) Mipmap() []*image.RGBA {
+	return t.mipmap
+}
+
 // Query fetches the color of at pixel (u, v). This function is a naive
 // mipmap implementation that does magnification and minification.
 func (t *Texture) Query(lod, u, v float32) color.RGBA {
@@ -224,3 +240,17 @@ func TextureIsoMipmap(enable bool) TextureOpt {
 		}
 	}
 }
+
+// TextureSource records the image file that the texture image was loaded
+// from, and whether its sRGB colors were converted to linear. Scene files
+// can reference such a texture by its path instead of embedding it.
+func TextureSource(path string, srgb bool) TextureOpt {
+	return func(t any) {
+		switch o := t.(type) {
+		case *Texture:
+			o.source, o.srgb = path, srgb
+		default:
+			panic("buffer: misuse of Source option")
+		}
+	}
+}
@@ -72,6 +72,12 @@ func (c *Orthographic) SetAspect(width, height float32) {
 	c.left = -width / 2
 }
 
+// ViewFrustum returns the parameters of the ViewFrustum option of the
+// camera: left, right, bottom, top, near, far.
+func (c *Orthographic) ViewFrustum() []float32 {
+	return []float32{c.left, c.right, c.bottom, c.top, c.near, c.far}
+}
+
 // Position returns the position of the given camera.
 func (c *Orthographic) Position() math.Vec3[float32] {
 	return c.position
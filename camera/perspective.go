@@ -64,6 +64,12 @@ func (c *Perspective) SetAspect(width, height float32) {
 	c.aspect = width / height
 }
 
+// ViewFrustum returns the parameters of the ViewFrustum option of the
+// camera: fov, aspect, near, far.
+func (c *Perspective) ViewFrustum() []float32 {
+	return []float32{c.fov, c.aspect, c.near, c.far}
+}
+
 // Position returns the position of the given camera.
 func (c *Perspective) Position() math.Vec3[float32] {
 	return c.position
@@ -18,6 +18,7 @@ func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage:
 polyred show /path/to/mesh.obj
+polyred show /path/to/scene.json
 `)
 	}
 
@@ -7,6 +7,7 @@ package main
 import (
 	"image"
 	"log"
+	"path/filepath"
 	"runtime"
 
 	"poly.red/app"
@@ -29,7 +30,7 @@ type App struct {
 	cache *image.RGBA
 }
 
-func newApp(objPath string) *App {
+func newApp(path string) *App {
 	w, h := 800, 600
 
 	// camera and renderer
@@ -38,8 +39,23 @@ func newApp(objPath string) *App {
 		camera.ViewFrustum(45, float32(w)/float32(h), 0.1, 10),
 	)
 
-	s := scene.NewScene(model.MustLoad(objPath))
-	s.Normalize()
+	// A scene file is shown as it is, through its first camera if it has
+	// one, whereas a model is normalized.
+	var s *scene.Scene
+	if filepath.Ext(path) == ".json" {
+		var err error
+		if s, err = scene.Load(path); err != nil {
+			log.Fatal(err)
+		}
+		scene.IterObjects(s, func(sc camera.Interface, _ math.Mat4[float32]) bool {
+			c = sc
+			c.SetAspect(float32(w), float32(h))
+			return false
+		})
+	} else {
+		s = scene.NewScene(model.MustLoad(path))
+		s.Normalize()
+	}
 
 	r := render.NewRenderer(
 		render.Size(w, h),
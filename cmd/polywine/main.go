@@ -53,6 +53,9 @@ func newApp() *App {
 		render.Scene(s),
 		render.Workers(2),
 		render.PixelFormat(buffer.PixelFormatBGRA),
+		// Frames are only rendered when the view changes, hence FXAA
+		// rather than TAA, which converges over successive frames.
+		render.Antialiasing(render.AntialiasingFXAA),
 	)
 	if runtime.GOOS != "darwin" {
 		r.Options(render.PixelFormat(buffer.PixelFormatRGBA))
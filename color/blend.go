@@ -0,0 +1,125 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package color
+
+import "poly.red/math"
+
+// BlendMode is a mode that composites a source color over a destination
+// color. Both colors are alpha-premultiplied.
+type BlendMode int
+
+// The Porter-Duff operators. The zero value is the "source over" operator,
+// which is the conventional alpha blending.
+const (
+	BlendSrcOver BlendMode = iota
+	BlendClear
+	BlendSrc
+	BlendDst
+	BlendDstOver
+	BlendSrcIn
+	BlendDstIn
+	BlendSrcOut
+	BlendDstOut
+	BlendSrcAtop
+	BlendDstAtop
+	BlendXor
+
+	// The separable blend modes of the W3C compositing specification.
+	// They blend the colors where both the source and the destination
+	// are covered, and composite with "source over" elsewhere.
+	BlendMultiply
+	BlendScreen
+	BlendMin
+	BlendMax
+
+	// BlendAdd sums the source and destination, which is the "plus"
+	// operator of Porter-Duff.
+	BlendAdd
+)
+
+var blendModeNames = [...]string{
+	BlendSrcOver:  "src-over",
+	BlendClear:    "clear",
+	BlendSrc:      "src",
+	BlendDst:      "dst",
+	BlendDstOver:  "dst-over",
+	BlendSrcIn:    "src-in",
+	BlendDstIn:    "dst-in",
+	BlendSrcOut:   "src-out",
+	BlendDstOut:   "dst-out",
+	BlendSrcAtop:  "src-atop",
+	BlendDstAtop:  "dst-atop",
+	BlendXor:      "xor",
+	BlendMultiply: "multiply",
+	BlendScreen:   "screen",
+	BlendMin:      "min",
+	BlendMax:      "max",
+	BlendAdd:      "add",
+}
+
+func (m BlendMode) String() string {
+	if m < 0 || int(m) >= len(blendModeNames) {
+		return "unknown"
+	}
+	return blendModeNames[m]
+}
+
+// Blend composites the alpha-premultiplied color src over dst with the
+// given blend mode.
+func Blend(mode BlendMode, dst, src RGBA) RGBA {
+	sa, da := float32(src.A)/0xff, float32(dst.A)/0xff
+	c := func(s, d uint8) uint8 {
+		v := BlendChannel(mode, float32(s)/0xff, float32(d)/0xff, sa, da)
+		return uint8(math.Clamp(math.Round(v*0xff), 0, 0xff))
+	}
+	return RGBA{
+		R: c(src.R, dst.R),
+		G: c(src.G, dst.G),
+		B: c(src.B, dst.B),
+		A: c(src.A, dst.A),
+	}
+}
+
+// BlendChannel blends a premultiplied source channel s of alpha sa with a
+// premultiplied destination channel d of alpha da, all in [0, 1]. The alpha
+// channel itself is blended by passing it as both s and sa, and d and da.
+func BlendChannel(mode BlendMode, s, d, sa, da float32) float32 {
+	switch mode {
+	case BlendClear:
+		return 0
+	case BlendSrc:
+		return s
+	case BlendDst:
+		return d
+	case BlendDstOver:
+		return s*(1-da) + d
+	case BlendSrcIn:
+		return s * da
+	case BlendDstIn:
+		return d * sa
+	case BlendSrcOut:
+		return s * (1 - da)
+	case BlendDstOut:
+		return d * (1 - sa)
+	case BlendSrcAtop:
+		return s*da + d*(1-sa)
+	case BlendDstAtop:
+		return s*(1-da) + d*sa
+	case BlendXor:
+		return s*(1-da) + d*(1-sa)
+	case BlendMultiply:
+		return s*d + s*(1-da) + d*(1-sa)
+	case BlendScreen:
+		return s + d - s*d
+	case BlendMin:
+		return math.Min(s*da, d*sa) + s*(1-da) + d*(1-sa)
+	case BlendMax:
+		return math.Max(s*da, d*sa) + s*(1-da) + d*(1-sa)
+	case BlendAdd:
+		return math.Min(s+d, 1)
+	default: // BlendSrcOver
+		return s + d*(1-sa)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package color_test
+
+import (
+	"testing"
+
+	"poly.red/color"
+)
+
+func TestBlend(t *testing.T) {
+	// A half transparent red over an opaque grey.
+	dst := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	src := color.RGBA{R: 128, A: 128}
+
+	tests := []struct {
+		mode color.BlendMode
+		want color.RGBA
+	}{
+		{color.BlendSrcOver, color.RGBA{R: 178, G: 50, B: 50, A: 255}},
+		{color.BlendClear, color.RGBA{}},
+		{color.BlendSrc, src},
+		{color.BlendDst, dst},
+		{color.BlendDstOver, dst},
+		{color.BlendSrcIn, src},
+		{color.BlendDstIn, color.RGBA{R: 50, G: 50, B: 50, A: 128}},
+		{color.BlendSrcOut, color.RGBA{}},
+		{color.BlendDstOut, color.RGBA{R: 50, G: 50, B: 50, A: 127}},
+		{color.BlendSrcAtop, color.RGBA{R: 178, G: 50, B: 50, A: 255}},
+		{color.BlendDstAtop, color.RGBA{R: 50, G: 50, B: 50, A: 128}},
+		{color.BlendXor, color.RGBA{R: 50, G: 50, B: 50, A: 127}},
+		{color.BlendMultiply, color.RGBA{R: 100, G: 50, B: 50, A: 255}},
+		{color.BlendScreen, color.RGBA{R: 178, G: 100, B: 100, A: 255}},
+		{color.BlendMin, color.RGBA{R: 100, G: 50, B: 50, A: 255}},
+		{color.BlendMax, color.RGBA{R: 178, G: 100, B: 100, A: 255}},
+		{color.BlendAdd, color.RGBA{R: 228, G: 100, B: 100, A: 255}},
+	}
+	for _, tt := range tests {
+		if got := color.Blend(tt.mode, dst, src); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
@@ -32,11 +32,21 @@ type Geometry struct {
 
 // New builds a geometry from a mesh and the materials it owns. The mesh's
 // primitives carry geometry-local indices (0..len(mats)-1) into mats.
-func New(mesh mesh.Mesh, mats ...material.Material) *Geometry {
+// Tangents are generated for the mesh if one of the materials has a normal
+// map and the mesh does not carry tangents yet.
+func New(m mesh.Mesh, mats ...material.Material) *Geometry {
 	g := &Geometry{
-		mesh: mesh,
+		mesh: m,
 		mats: mats,
 	}
+	for _, mat := range mats {
+		if std := material.StandardOf(mat); std != nil && std.NormalMap != nil {
+			if !mesh.HasTangents(m) {
+				mesh.GenerateTangents(m)
+			}
+			break
+		}
+	}
 	g.ResetContext()
 	return g
 }
@@ -22,6 +22,7 @@ const (
 	AttribNormal
 	AttriTexcoord
 	AttribColor
+	AttribTangent
 )
 
 var attribNames = map[AttribType]string{
@@ -30,6 +31,7 @@ var attribNames = map[AttribType]string{
 	AttribNormal:    "normal",
 	AttriTexcoord:   "texcoord",
 	AttribColor:     "color",
+	AttribTangent:   "tangent",
 }
 
 func (a AttribType) String() string {
@@ -63,6 +65,7 @@ func NewBufferedMesh() *BufferedMesh {
 			AttribNormal:   nil,
 			AttriTexcoord:  nil,
 			AttribColor:    nil,
+			AttribTangent:  nil,
 		},
 	}
 	return bm
@@ -104,10 +107,11 @@ func (bm *BufferedMesh) Triangles() []*primitive.Triangle {
 	attrNor := bm.GetAttribute(AttribNormal)
 	attrColor := bm.GetAttribute(AttribColor)
 	attrUV := bm.GetAttribute(AttriTexcoord)
+	attrTan := bm.GetAttribute(AttribTangent)
 	tris := []*primitive.Triangle{}
 
 	for i := 0; i < len(bm.ibo); i += 3 {
-		var px, py, pz, nx, ny, nz, u, v float32
+		var px, py, pz, nx, ny, nz, u, v, tx, ty, tz, tw float32
 		var cr, cb, cg, ca uint8
 		px = attrPos.Values[attrPos.Stride*bm.ibo[i]+0]
 		py = attrPos.Values[attrPos.Stride*bm.ibo[i]+1]
@@ -118,20 +122,27 @@ func (bm *BufferedMesh) Triangles() []*primitive.Triangle {
 			nz = attrNor.Values[attrNor.Stride*bm.ibo[i]+2]
 		}
 		if attrColor != nil {
-			cr = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+0] * 0xff)
-			cb = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+1] * 0xff)
-			cg = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+2] * 0xff)
-			ca = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+3] * 0xff)
+			cr = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+0]*0xff + 0.5)
+			cb = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+1]*0xff + 0.5)
+			cg = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+2]*0xff + 0.5)
+			ca = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+3]*0xff + 0.5)
 		}
 		if attrUV != nil {
 			u = attrUV.Values[attrUV.Stride*bm.ibo[i]+0]
 			v = attrUV.Values[attrUV.Stride*bm.ibo[i]+1]
 		}
+		if attrTan != nil {
+			tx = attrTan.Values[attrTan.Stride*bm.ibo[i]+0]
+			ty = attrTan.Values[attrTan.Stride*bm.ibo[i]+1]
+			tz = attrTan.Values[attrTan.Stride*bm.ibo[i]+2]
+			tw = attrTan.Values[attrTan.Stride*bm.ibo[i]+3]
+		}
 		v1 := primitive.NewVertex(
 			primitive.Pos(math.NewVec4(px, py, pz, 1)),
 			primitive.Nor(math.NewVec4(nx, ny, nz, 0)),
 			primitive.Col(color.RGBA{cr, cb, cg, ca}),
 			primitive.UV(math.NewVec2(u, v)),
+			primitive.Tan(math.NewVec4(tx, ty, tz, tw)),
 		)
 
 		px = attrPos.Values[attrPos.Stride*bm.ibo[i+1]+0]
@@ -143,20 +154,27 @@ func (bm *BufferedMesh) Triangles() []*primitive.Triangle {
 			nz = attrNor.Values[attrNor.Stride*bm.ibo[i+1]+2]
 		}
 		if attrColor != nil {
-			cr = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+1]+0] * 0xff)
-			cb = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+1]+1] * 0xff)
-			cg = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+1]+2] * 0xff)
-			ca = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+1]+3] * 0xff)
+			cr = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+1]+0]*0xff + 0.5)
+			cb = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+1]+1]*0xff + 0.5)
+			cg = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+1]+2]*0xff + 0.5)
+			ca = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+1]+3]*0xff + 0.5)
 		}
 		if attrUV != nil {
 			u = attrUV.Values[attrUV.Stride*bm.ibo[i+1]+0]
 			v = attrUV.Values[attrUV.Stride*bm.ibo[i+1]+1]
 		}
+		if attrTan != nil {
+			tx = attrTan.Values[attrTan.Stride*bm.ibo[i+1]+0]
+			ty = attrTan.Values[attrTan.Stride*bm.ibo[i+1]+1]
+			tz = attrTan.Values[attrTan.Stride*bm.ibo[i+1]+2]
+			tw = attrTan.Values[attrTan.Stride*bm.ibo[i+1]+3]
+		}
 		v2 := primitive.NewVertex(
 			primitive.Pos(math.NewVec4(px, py, pz, 1)),
 			primitive.Nor(math.NewVec4(nx, ny, nz, 0)),
 			primitive.Col(color.RGBA{cr, cb, cg, ca}),
 			primitive.UV(math.NewVec2(u, v)),
+			primitive.Tan(math.NewVec4(tx, ty, tz, tw)),
 		)
 
 		px = attrPos.Values[attrPos.Stride*bm.ibo[i+2]+0]
@@ -168,20 +186,27 @@ func (bm *BufferedMesh) Triangles() []*primitive.Triangle {
 			nz = attrNor.Values[attrNor.Stride*bm.ibo[i+2]+2]
 		}
 		if attrColor != nil {
-			cr = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+2]+0] * 0xff)
-			cb = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+2]+1] * 0xff)
-			cg = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+2]+2] * 0xff)
-			ca = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+2]+3] * 0xff)
+			cr = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+2]+0]*0xff + 0.5)
+			cb = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+2]+1]*0xff + 0.5)
+			cg = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+2]+2]*0xff + 0.5)
+			ca = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i+2]+3]*0xff + 0.5)
 		}
 		if attrUV != nil {
 			u = attrUV.Values[attrUV.Stride*bm.ibo[i+2]+0]
 			v = attrUV.Values[attrUV.Stride*bm.ibo[i+2]+1]
 		}
+		if attrTan != nil {
+			tx = attrTan.Values[attrTan.Stride*bm.ibo[i+2]+0]
+			ty = attrTan.Values[attrTan.Stride*bm.ibo[i+2]+1]
+			tz = attrTan.Values[attrTan.Stride*bm.ibo[i+2]+2]
+			tw = attrTan.Values[attrTan.Stride*bm.ibo[i+2]+3]
+		}
 		v3 := primitive.NewVertex(
 			primitive.Pos(math.NewVec4(px, py, pz, 1)),
 			primitive.Nor(math.NewVec4(nx, ny, nz, 0)),
 			primitive.Col(color.RGBA{cr, cb, cg, ca}),
 			primitive.UV(math.NewVec2(u, v)),
+			primitive.Tan(math.NewVec4(tx, ty, tz, tw)),
 		)
 
 		tris = append(tris, &primitive.Triangle{V1: v1, V2: v2, V3: v3})
@@ -200,8 +225,9 @@ func (bm *BufferedMesh) VertexBuffer() buffer.VertexBuffer {
 	attrNor := bm.GetAttribute(AttribNormal)
 	attrColor := bm.GetAttribute(AttribColor)
 	attrUV := bm.GetAttribute(AttriTexcoord)
+	attrTan := bm.GetAttribute(AttribTangent)
 
-	var px, py, pz, nx, ny, nz, u, v float32
+	var px, py, pz, nx, ny, nz, u, v, tx, ty, tz, tw float32
 	var cr, cb, cg, ca uint8
 
 	bm.vbo = make([]*primitive.Vertex, len(bm.ibo))
@@ -215,20 +241,27 @@ func (bm *BufferedMesh) VertexBuffer() buffer.VertexBuffer {
 			nz = attrNor.Values[attrNor.Stride*bm.ibo[i]+2]
 		}
 		if attrColor != nil {
-			cr = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+0] * 0xff)
-			cb = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+1] * 0xff)
-			cg = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+2] * 0xff)
-			ca = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+3] * 0xff)
+			cr = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+0]*0xff + 0.5)
+			cb = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+1]*0xff + 0.5)
+			cg = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+2]*0xff + 0.5)
+			ca = uint8(attrColor.Values[attrColor.Stride*bm.ibo[i]+3]*0xff + 0.5)
 		}
 		if attrUV != nil {
 			u = attrUV.Values[attrUV.Stride*bm.ibo[i]+0]
 			v = attrUV.Values[attrUV.Stride*bm.ibo[i]+1]
 		}
+		if attrTan != nil {
+			tx = attrTan.Values[attrTan.Stride*bm.ibo[i]+0]
+			ty = attrTan.Values[attrTan.Stride*bm.ibo[i]+1]
+			tz = attrTan.Values[attrTan.Stride*bm.ibo[i]+2]
+			tw = attrTan.Values[attrTan.Stride*bm.ibo[i]+3]
+		}
 		bm.vbo[i] = primitive.NewVertex(
 			primitive.Pos(math.NewVec4(px, py, pz, 1)),
 			primitive.Nor(math.NewVec4(nx, ny, nz, 0)),
 			primitive.Col(color.RGBA{cr, cb, cg, ca}),
 			primitive.UV(math.NewVec2(u, v)),
+			primitive.Tan(math.NewVec4(tx, ty, tz, tw)),
 		)
 	}
 	return bm.vbo
@@ -5,9 +5,11 @@
 package mesh_test
 
 import (
+	"image/color"
 	"testing"
 
 	"poly.red/geometry/mesh"
+	"poly.red/geometry/primitive"
 )
 
 func TestBufferedMesh(t *testing.T) {
@@ -33,3 +35,25 @@ func TestBufferedMesh(t *testing.T) {
 		t.Fatalf("expect 4 faces, but only got %v", len(bm.Triangles()))
 	}
 }
+
+// TestBufferedMeshColor checks that the float color attribute is rounded to
+// the nearest 8-bit color, such that 8-bit colors survive the round trip.
+func TestBufferedMeshColor(t *testing.T) {
+	cols := []float32{
+		200.0 / 0xff, 3.0 / 0xff, 129.0 / 0xff, 1,
+		0, 0.3, 1, 0.5,
+		0.999, 0.001, 0.25, 0.75,
+	}
+	want := []color.RGBA{{200, 3, 129, 255}, {0, 77, 255, 128}, {255, 0, 64, 191}}
+	bm := mesh.NewBufferedMesh()
+	bm.SetAttribute(mesh.AttribPosition, mesh.NewBufferAttrib(3, []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}))
+	bm.SetAttribute(mesh.AttribColor, mesh.NewBufferAttrib(4, cols))
+	bm.SetIndexBuffer([]int{0, 1, 2})
+
+	tri := bm.Triangles()[0]
+	for i, v := range []*primitive.Vertex{tri.V1, tri.V2, tri.V3} {
+		if v.Col != want[i] || bm.VertexBuffer()[i].Col != want[i] {
+			t.Fatalf("vertex %d: color %v, want %v", i, v.Col, want[i])
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package mesh
+
+import (
+	"poly.red/geometry/primitive"
+	"poly.red/math"
+)
+
+// GenerateTangents computes the tangent of every vertex of the given mesh
+// from its positions, normals and UV coordinates, and stores it in the
+// Tan field of the vertex.
+//
+// The tangents follow the MikkTSpace conventions, so that normal maps baked
+// by common tools are reproduced: the tangent of a face points towards
+// increasing u and its bitangent towards increasing v. Each face contributes
+// its tangent, projected onto the tangent plane of the vertex normal and
+// weighted by the angle of the face at the corner, to all corners sharing the
+// same position, normal, UV coordinates and handedness. The W component of a
+// tangent is the handedness of the bitangent relative to cross(Nor, Tan).
+//
+// Vertices whose faces have degenerate UV coordinates receive an arbitrary
+// tangent perpendicular to their normal.
+func GenerateTangents(m Mesh) {
+	type key struct {
+		pos, nor [3]float32
+		uv       [2]float32
+		flip     bool
+	}
+	type corner struct {
+		v *primitive.Vertex
+		k key
+	}
+
+	tris := m.Triangles()
+	sums := map[key]math.Vec4[float32]{}
+	corners := make([]corner, 0, len(tris)*3)
+	for _, t := range tris {
+		vs := [3]*primitive.Vertex{t.V1, t.V2, t.V3}
+		e1 := vs[1].Pos.Sub(vs[0].Pos).Vec()
+		e2 := vs[2].Pos.Sub(vs[0].Pos).Vec()
+		du1, dv1 := vs[1].UV.X-vs[0].UV.X, vs[1].UV.Y-vs[0].UV.Y
+		du2, dv2 := vs[2].UV.X-vs[0].UV.X, vs[2].UV.Y-vs[0].UV.Y
+
+		// Solve e1 = du1*T + dv1*B and e2 = du2*T + dv2*B. Only the
+		// directions matter, hence the determinant is used for its sign.
+		det := du1*dv2 - du2*dv1
+		var tf, bf math.Vec4[float32]
+		if math.Abs(det) > 1e-12 {
+			s := float32(1)
+			if det < 0 {
+				s = -1
+			}
+			tf = e1.Scale(dv2, dv2, dv2, 0).Sub(e2.Scale(dv1, dv1, dv1, 0)).Scale(s, s, s, 0)
+			bf = e2.Scale(du1, du1, du1, 0).Sub(e1.Scale(du2, du2, du2, 0)).Scale(s, s, s, 0)
+		}
+
+		for i, v := range vs {
+			n := tangentNormal(v, e1, e2)
+			k := key{
+				pos: [3]float32{v.Pos.X, v.Pos.Y, v.Pos.Z},
+				nor: [3]float32{n.X, n.Y, n.Z},
+				uv:  [2]float32{v.UV.X, v.UV.Y},
+			}
+
+			tc := tf.Sub(n.Scale(n.Dot(tf), n.Dot(tf), n.Dot(tf), 0))
+			bc := bf.Sub(n.Scale(n.Dot(bf), n.Dot(bf), n.Dot(bf), 0))
+			if tc.Len() > 1e-12 && bc.Len() > 1e-12 {
+				tc, bc = tc.Unit(), bc.Unit()
+				k.flip = n.Cross(tc).Dot(bc) < 0
+
+				// Weight by the angle of the face at this corner.
+				a := vs[(i+1)%3].Pos.Sub(v.Pos).Vec()
+				b := vs[(i+2)%3].Pos.Sub(v.Pos).Vec()
+				w := float32(0)
+				if a.Len() > 0 && b.Len() > 0 {
+					w = math.Acos(math.Clamp(a.Unit().Dot(b.Unit()), -1, 1))
+				}
+				s := sums[k]
+				sums[k] = s.Add(tc.Scale(w, w, w, 0))
+			}
+			corners = append(corners, corner{v, k})
+		}
+	}
+
+	for _, c := range corners {
+		n := math.NewVec4(c.k.nor[0], c.k.nor[1], c.k.nor[2], 0)
+		t := sums[c.k]
+		t = t.Sub(n.Scale(n.Dot(t), n.Dot(t), n.Dot(t), 0))
+		if t.Len() < 1e-12 {
+			c.v.Tan = perpendicular(n)
+			continue
+		}
+		t = t.Unit()
+		t.W = 1
+		if c.k.flip {
+			t.W = -1
+		}
+		c.v.Tan = t
+	}
+}
+
+// tangentNormal returns the unit normal of the given vertex, or the normal
+// of the face spanned by the edges e1 and e2 if the vertex has no normal.
+func tangentNormal(v *primitive.Vertex, e1, e2 math.Vec4[float32]) math.Vec4[float32] {
+	n := v.Nor.Vec()
+	if n.Len() > 0 {
+		return n.Unit()
+	}
+	n = e1.Cross(e2)
+	if n.Len() > 0 {
+		return n.Unit()
+	}
+	return math.NewVec4[float32](0, 0, 1, 0)
+}
+
+// perpendicular returns an arbitrary right-handed unit tangent that is
+// perpendicular to the unit normal n.
+func perpendicular(n math.Vec4[float32]) math.Vec4[float32] {
+	a := math.NewVec4[float32](1, 0, 0, 0)
+	if math.Abs(n.X) > 0.9 {
+		a = math.NewVec4[float32](0, 1, 0, 0)
+	}
+	t := a.Sub(n.Scale(n.Dot(a), n.Dot(a), n.Dot(a), 0)).Unit()
+	t.W = 1
+	return t
+}
+
+// HasTangents reports whether every vertex of the given mesh carries a
+// tangent.
+func HasTangents(m Mesh) bool {
+	for _, t := range m.Triangles() {
+		if t.V1.Tan.IsZero() || t.V2.Tan.IsZero() || t.V3.Tan.IsZero() {
+			return false
+		}
+	}
+	return true
+}
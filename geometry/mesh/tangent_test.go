@@ -0,0 +1,79 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package mesh_test
+
+import (
+	"testing"
+
+	"poly.red/geometry/mesh"
+	"poly.red/geometry/primitive"
+	"poly.red/math"
+)
+
+func TestGenerateTangents(t *testing.T) {
+	// A quad on the XZ plane facing +Y whose v grows towards -Z, and whose
+	// u grows towards +X, or towards -X if mirrored.
+	plane := func(mirror bool) *mesh.TriangleMesh {
+		v := func(x, z float32) *primitive.Vertex {
+			u := x + 0.5
+			if mirror {
+				u = 1 - u
+			}
+			return primitive.NewVertex(
+				primitive.Pos(math.NewVec4(x, 0, z, 1)),
+				primitive.UV(math.NewVec2(u, 0.5-z)),
+				primitive.Nor(math.NewVec4[float32](0, 1, 0, 0)),
+			)
+		}
+		v1, v2, v3, v4 := v(-0.5, 0.5), v(0.5, 0.5), v(0.5, -0.5), v(-0.5, -0.5)
+		return mesh.NewTriangleMesh([]*primitive.Triangle{
+			{V1: v1, V2: v2, V3: v3},
+			{V1: v1, V2: v3, V3: v4},
+		})
+	}
+
+	tests := []struct {
+		name   string
+		mirror bool
+		want   math.Vec4[float32]
+	}{
+		{"standard", false, math.NewVec4[float32](1, 0, 0, 1)},
+		{"mirrored", true, math.NewVec4[float32](-1, 0, 0, -1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := plane(tt.mirror)
+			if mesh.HasTangents(m) {
+				t.Fatalf("mesh has tangents before generation")
+			}
+			mesh.GenerateTangents(m)
+			if !mesh.HasTangents(m) {
+				t.Fatalf("mesh has no tangents after generation")
+			}
+			for _, tri := range m.Triangles() {
+				for _, v := range []*primitive.Vertex{tri.V1, tri.V2, tri.V3} {
+					if !v.Tan.Eq(tt.want) {
+						t.Fatalf("vertex %v: want tangent %v, got %v", v.Pos, tt.want, v.Tan)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateTangentsDegenerate(t *testing.T) {
+	// Without UV coordinates, tangents are perpendicular to the normals.
+	n := math.NewVec4[float32](0, 0, 1, 0)
+	v1 := primitive.NewVertex(primitive.Pos(math.NewVec4[float32](0, 0, 0, 1)), primitive.Nor(n))
+	v2 := primitive.NewVertex(primitive.Pos(math.NewVec4[float32](1, 0, 0, 1)), primitive.Nor(n))
+	v3 := primitive.NewVertex(primitive.Pos(math.NewVec4[float32](0, 1, 0, 1)), primitive.Nor(n))
+	m := mesh.NewTriangleMesh([]*primitive.Triangle{{V1: v1, V2: v2, V3: v3}})
+	mesh.GenerateTangents(m)
+	for _, v := range []*primitive.Vertex{v1, v2, v3} {
+		if !math.ApproxEq(v.Tan.ToVec3().Len(), 1, 1e-5) || !math.ApproxEq(v.Tan.Dot(n), 0, 1e-5) || v.Tan.W != 1 {
+			t.Fatalf("want a unit tangent perpendicular to the normal, got %v", v.Tan)
+		}
+	}
+}
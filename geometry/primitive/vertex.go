@@ -18,6 +18,10 @@ type Vertex struct {
 	Nor math.Vec4[float32] // Nor is the vertex normal
 	Col color.RGBA         // Col is the vertex color
 	UV  math.Vec2[float32] // UV is the vertex UV coordinates
+	// Tan is the vertex tangent. Its W component is the handedness (±1) of
+	// the bitangent, that is cross(Nor, Tan) scaled by W. A zero tangent
+	// means that the vertex has no tangent.
+	Tan math.Vec4[float32]
 }
 
 // NewVertex creates a new Vertex and have an unset index.
@@ -56,6 +60,11 @@ func Col(col color.RGBA) VertOpt {
 		v.Col = col
 	}
 }
+func Tan(tan math.Vec4[float32]) VertOpt {
+	return func(v *Vertex) {
+		v.Tan = tan
+	}
+}
 
 // NewRandomVertex returns a vertex that its position, normal, color and
 // UV coordinates are randomly generated.
@@ -76,6 +85,7 @@ func (v *Vertex) Copy() *Vertex {
 		Nor(v.Nor),
 		Col(v.Col),
 		UV(v.UV),
+		Tan(v.Tan),
 	)
 }
 
@@ -11,12 +11,13 @@ type backend interface {
 	newBuffer(size int, usage BufferUsage, data []byte) (backendBuffer, error)
 	newShaderModule(src ShaderSource) (backendShaderModule, error)
 	newComputePipeline(mod backendShaderModule, entry string) (backendComputePipeline, error)
-	newTexture(format TextureFormat, w, h int, renderTarget bool) (backendTexture, error)
+	newTexture(format TextureFormat, w, h int, renderTarget bool, samples int) (backendTexture, error)
 	newSampler(desc SamplerDescriptor) backendSampler
-	newRenderPipeline(vmod backendShaderModule, ventry string, fmod backendShaderModule, fentry string, color TextureFormat, extraColor []TextureFormat, depth TextureFormat) (backendRenderPipeline, error)
+	newRenderPipeline(vmod backendShaderModule, ventry string, fmod backendShaderModule, fentry string, color TextureFormat, extraColor []TextureFormat, depth TextureFormat, samples int) (backendRenderPipeline, error)
 	newCommandBuffer() backendCommandBuffer
 	newWindowSurface(display, window uintptr, w, h int) (backendWindowSurface, error)
 	windowVisualID() uint32 // native visual an on-screen window must use (0 if N/A)
+	storageTextures() bool  // compute shaders can load RGBA32Float textures by texel
 	waitIdle()
 	close() error
 }
@@ -41,8 +42,9 @@ type backendRenderPipeline interface{ isRenderPipeline() }
 
 // renderColorTarget is one extra color attachment (1..N) of a render pass.
 type renderColorTarget struct {
-	tex   backendTexture
-	clear [4]float64
+	tex     backendTexture
+	clear   [4]float64
+	resolve backendTexture // optional resolve target of a multisampled tex
 }
 
 // renderPassInfo is the backend-facing description of a render pass.
@@ -50,6 +52,7 @@ type renderPassInfo struct {
 	color      backendTexture
 	load       LoadOp
 	clearColor [4]float64
+	resolve    backendTexture      // optional resolve target of a multisampled color
 	extraColor []renderColorTarget // color attachments 1..N
 	depth      backendTexture      // optional depth attachment
 	clearDepth float64
@@ -83,6 +83,10 @@ func (m *metalBackend) newWindowSurface(display, window uintptr, w, h int) (back
 
 func (m *metalBackend) windowVisualID() uint32 { return 0 }
 
+// storageTextures reports true: every texture is created with ShaderRead
+// usage, and a compute kernel reads it through texture2d<float, access::read>.
+func (m *metalBackend) storageTextures() bool { return true }
+
 func (m *metalBackend) waitIdle() {
 	if m.hasLast {
 		m.last.WaitUntilCompleted()
@@ -192,15 +196,16 @@ func mtlPrim(p Primitive) mtl.PrimitiveType {
 	}
 }
 
-func (m *metalBackend) newTexture(format TextureFormat, w, h int, renderTarget bool) (backendTexture, error) {
+func (m *metalBackend) newTexture(format TextureFormat, w, h int, renderTarget bool, samples int) (backendTexture, error) {
 	usage := mtl.TextureUsageShaderRead
 	if renderTarget {
 		usage |= mtl.TextureUsageRenderTarget
 	}
-	// A depth texture cannot use Shared storage on macOS; it is a private
-	// render-target attachment that is never read back to the CPU.
+	// A depth or multisampled texture cannot use Shared storage on macOS; it
+	// is a private render-target attachment that is never read back to the
+	// CPU (a multisampled one is resolved into a single-sampled texture).
 	storage := mtl.StorageModeShared
-	if format == Depth32Float {
+	if format == Depth32Float || samples > 1 {
 		storage = mtl.StorageModePrivate
 		usage = mtl.TextureUsageRenderTarget
 	}
@@ -210,6 +215,7 @@ func (m *metalBackend) newTexture(format TextureFormat, w, h int, renderTarget b
 		Height:      h,
 		StorageMode: storage,
 		Usage:       usage,
+		SampleCount: samples,
 	})
 	// Bytes per pixel for CPU readback: RGBA32Float is 16, RGBA8Unorm is 4.
 	bpp := 4
@@ -219,7 +225,7 @@ func (m *metalBackend) newTexture(format TextureFormat, w, h int, renderTarget b
 	return &metalTexture{tex: tex, w: w, h: h, bpp: bpp}, nil
 }
 
-func (m *metalBackend) newRenderPipeline(vmod backendShaderModule, ventry string, fmod backendShaderModule, fentry string, color TextureFormat, extraColor []TextureFormat, depth TextureFormat) (backendRenderPipeline, error) {
+func (m *metalBackend) newRenderPipeline(vmod backendShaderModule, ventry string, fmod backendShaderModule, fentry string, color TextureFormat, extraColor []TextureFormat, depth TextureFormat, samples int) (backendRenderPipeline, error) {
 	vfn, err := vmod.(*metalModule).lib.MakeFunction(ventry)
 	if err != nil {
 		return nil, err
@@ -232,6 +238,7 @@ func (m *metalBackend) newRenderPipeline(vmod backendShaderModule, ventry string
 		VertexFunction:   vfn,
 		FragmentFunction: ffn,
 		ColorPixelFormat: mtlFormat(color),
+		SampleCount:      samples,
 	}
 	for _, f := range extraColor {
 		pdesc.ExtraColorPixelFormats = append(pdesc.ExtraColorPixelFormats, mtlFormat(f))
@@ -323,20 +330,10 @@ func (c *metalCmd) beginRender(info renderPassInfo) {
 		load = mtl.LoadActionClear
 	}
 	desc := mtl.RenderPassDescriptor{
-		ColorAttachment0: mtl.ColorAttachment{
-			Texture:     info.color.(*metalTexture).tex,
-			LoadAction:  load,
-			StoreAction: mtl.StoreActionStore,
-			ClearColor:  mtl.ClearColor{Red: info.clearColor[0], Green: info.clearColor[1], Blue: info.clearColor[2], Alpha: info.clearColor[3]},
-		},
+		ColorAttachment0: mtlColorAttachment(info.color, info.resolve, load, info.clearColor),
 	}
 	for _, t := range info.extraColor {
-		desc.ExtraColorAttachments = append(desc.ExtraColorAttachments, mtl.ColorAttachment{
-			Texture:     t.tex.(*metalTexture).tex,
-			LoadAction:  load,
-			StoreAction: mtl.StoreActionStore,
-			ClearColor:  mtl.ClearColor{Red: t.clear[0], Green: t.clear[1], Blue: t.clear[2], Alpha: t.clear[3]},
-		})
+		desc.ExtraColorAttachments = append(desc.ExtraColorAttachments, mtlColorAttachment(t.tex, t.resolve, load, t.clear))
 	}
 	if info.depth != nil {
 		desc.Depth = mtl.DepthAttachment{
@@ -349,6 +346,22 @@ func (c *metalCmd) beginRender(info renderPassInfo) {
 	c.renc = c.cb.MakeRenderCommandEncoder(desc)
 }
 
+// mtlColorAttachment returns a color attachment that stores the texture, or
+// resolves its samples into the resolve texture if there is one.
+func mtlColorAttachment(tex, resolve backendTexture, load mtl.LoadAction, clear [4]float64) mtl.ColorAttachment {
+	att := mtl.ColorAttachment{
+		Texture:     tex.(*metalTexture).tex,
+		LoadAction:  load,
+		StoreAction: mtl.StoreActionStore,
+		ClearColor:  mtl.ClearColor{Red: clear[0], Green: clear[1], Blue: clear[2], Alpha: clear[3]},
+	}
+	if resolve != nil {
+		att.StoreAction = mtl.StoreActionMultisampleResolve
+		att.ResolveTexture = resolve.(*metalTexture).tex
+	}
+	return att
+}
+
 func (c *metalCmd) setRenderPipeline(p backendRenderPipeline) {
 	mp := p.(*metalRenderPipeline)
 	c.renc.SetRenderPipelineState(mp.rps)
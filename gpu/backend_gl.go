@@ -54,6 +54,7 @@ const (
 	glDepthComponent    = 0x1902
 	glDepthComponent32F = 0x8CAC
 	glRGBA32F           = 0x8814
+	glReadOnly          = 0x88B8
 	glFloat             = 0x1406
 	glTexture2D         = 0x0DE1
 	glRGBA              = 0x1908
@@ -72,11 +73,16 @@ const (
 	glLess              = 0x0201
 	glTrue              = 1
 
+	glTexture2DMultisample = 0x9100
+
 	glReadFramebuffer = 0x8CA8
 	glDrawFramebuffer = 0x8CA9
 	glColorBufferBit  = 0x00004000
 
 	eglNativeVisualID = 0x302E
+
+	eglPlatformSurfacelessMESA = 0x31DD
+	eglDefaultDisplay          = 0
 )
 
 // glFns holds the resolved EGL/GLES entry points (purego function pointers).
@@ -97,14 +103,16 @@ type glFns struct {
 	genFramebuffers, bindFramebuffer, framebufferTexture2D, checkFramebuffer uintptr
 	genVertexArrays, bindVertexArray                                         uintptr
 	viewport, clearBufferfv, drawArrays, readPixels                          uintptr
-	blitFramebuffer, getError                                                uintptr
+	blitFramebuffer, getError, readBuffer, texStorage2DMultisample           uintptr
 	enable, disable, depthFunc, depthMask, drawBuffers                       uintptr
+	texStorage2D, bindImageTexture, texSubImage2D                            uintptr
 }
 
 type glBackend struct {
 	reqs       chan func()
 	fns        glFns
 	nativeDisp uintptr // X11 Display* for the EGL X11 platform (0 = default display)
+	headless   bool    // open the surfaceless platform rather than the default display
 	dpy        uintptr
 	ctx        uintptr
 	cfg        uintptr
@@ -113,6 +121,10 @@ type glBackend struct {
 
 func (b *glBackend) windowVisualID() uint32 { return b.visualID }
 
+// storageTextures reports true: ES 3.1 compute shaders load RGBA32Float
+// textures as images.
+func (b *glBackend) storageTextures() bool { return true }
+
 func openBackend(c config) (backend, Driver, error) {
 	if c.driver == DriverVulkan {
 		return openVKBackend(c)
@@ -120,7 +132,7 @@ func openBackend(c config) (backend, Driver, error) {
 	if c.driver != DriverAuto && c.driver != DriverGL {
 		return nil, DriverAuto, ErrUnsupported
 	}
-	b := &glBackend{reqs: make(chan func()), nativeDisp: c.nativeDisplay}
+	b := &glBackend{reqs: make(chan func()), nativeDisp: c.nativeDisplay, headless: c.headless}
 	ready := make(chan error, 1)
 	go b.loop(ready)
 	if err := <-ready; err != nil {
@@ -208,6 +220,7 @@ func (b *glBackend) init() error {
 	f.genTextures = sym(gles, "glGenTextures")
 	f.bindTexture = sym(gles, "glBindTexture")
 	f.texImage2D = sym(gles, "glTexImage2D")
+	f.texSubImage2D = sym(gles, "glTexSubImage2D")
 	f.texParameteri = sym(gles, "glTexParameteri")
 	f.genFramebuffers = sym(gles, "glGenFramebuffers")
 	f.bindFramebuffer = sym(gles, "glBindFramebuffer")
@@ -221,11 +234,15 @@ func (b *glBackend) init() error {
 	f.readPixels = sym(gles, "glReadPixels")
 	f.blitFramebuffer = sym(gles, "glBlitFramebuffer")
 	f.getError = sym(gles, "glGetError")
+	f.readBuffer = sym(gles, "glReadBuffer")
+	f.texStorage2DMultisample = sym(gles, "glTexStorage2DMultisample")
 	f.enable = sym(gles, "glEnable")
 	f.disable = sym(gles, "glDisable")
 	f.depthFunc = sym(gles, "glDepthFunc")
 	f.depthMask = sym(gles, "glDepthMask")
 	f.drawBuffers = sym(gles, "glDrawBuffers")
+	f.texStorage2D = sym(gles, "glTexStorage2D")
+	f.bindImageTexture = sym(gles, "glBindImageTexture")
 	if loadErr != nil {
 		return loadErr
 	}
@@ -233,7 +250,17 @@ func (b *glBackend) init() error {
 	// With a native X11 Display* this binds EGL to the X11 platform so an X11
 	// window is a valid native window; with 0 it is EGL_DEFAULT_DISPLAY (the
 	// surfaceless/headless compute path).
-	dpy, _, _ := purego.SyscallN(f.eglGetDisplay, b.nativeDisp)
+	var dpy uintptr
+	if b.headless && b.nativeDisp == 0 {
+		// EGL 1.5 or EGL_EXT_platform_base; without it, the default display
+		// below is all there is.
+		if getPlatformDisplay, err := glDlsym(egl, "eglGetPlatformDisplay"); err == nil && getPlatformDisplay != 0 {
+			dpy, _, _ = purego.SyscallN(getPlatformDisplay, uintptr(eglPlatformSurfacelessMESA), uintptr(eglDefaultDisplay), 0)
+		}
+	}
+	if dpy == 0 {
+		dpy, _, _ = purego.SyscallN(f.eglGetDisplay, b.nativeDisp)
+	}
 	if dpy == 0 {
 		return fmt.Errorf("gpu/gl: eglGetDisplay returned EGL_NO_DISPLAY (need EGL_PLATFORM=surfaceless or a display)")
 	}
@@ -435,6 +462,18 @@ type glCmd struct {
 	ops  []func()
 	prog uint32 // current compute pipeline program
 	gx   int    // current dispatch x
+
+	// The multisampled attachments of the current render pass and their
+	// resolve targets, blitted by endRender.
+	fbo      uint32
+	resolves []glResolve
+}
+
+// glResolve resolves the color attachment att of a render pass into a
+// single-sampled texture.
+type glResolve struct {
+	att uint32
+	dst *glTexture
 }
 
 func (b *glBackend) newCommandBuffer() backendCommandBuffer { return &glCmd{b: b} }
@@ -494,33 +533,52 @@ type glTexture struct {
 	id      uint32
 	fbo     uint32
 	w, h    int
-	depth   bool // a Depth32Float texture (attached as a depth attachment, not color)
-	floatTx bool // an RGBA32Float color texture (readback is 16 bytes/pixel, GL_FLOAT)
+	depth   bool    // a Depth32Float texture (attached as a depth attachment, not color)
+	floatTx bool    // an RGBA32Float color texture (readback is 16 bytes/pixel, GL_FLOAT)
+	target  uintptr // GL_TEXTURE_2D, or GL_TEXTURE_2D_MULTISAMPLE for a multisampled texture
 }
 
-func (b *glBackend) newTexture(format TextureFormat, w, h int, renderTarget bool) (backendTexture, error) {
-	t := &glTexture{b: b, w: w, h: h, depth: format == Depth32Float, floatTx: format == RGBA32Float}
+func (b *glBackend) newTexture(format TextureFormat, w, h int, renderTarget bool, samples int) (backendTexture, error) {
+	t := &glTexture{b: b, w: w, h: h, depth: format == Depth32Float, floatTx: format == RGBA32Float, target: glTexture2D}
+	if samples > 1 {
+		t.target = glTexture2DMultisample
+	}
 	b.do(func() {
 		f := &b.fns
 		purego.SyscallN(f.genTextures, 1, uintptr(unsafe.Pointer(&t.id)))
-		purego.SyscallN(f.bindTexture, uintptr(glTexture2D), uintptr(t.id))
+		purego.SyscallN(f.bindTexture, t.target, uintptr(t.id))
 		switch {
+		case samples > 1:
+			// A multisampled texture has immutable storage and no sampler
+			// state; fixed sample locations keep the resolve deterministic.
+			internal := uintptr(glRGBA8)
+			switch {
+			case t.depth:
+				internal = glDepthComponent32F
+			case t.floatTx:
+				internal = glRGBA32F
+			}
+			purego.SyscallN(f.texStorage2DMultisample, t.target, uintptr(samples), internal, uintptr(w), uintptr(h), uintptr(glTrue))
 		case t.depth:
 			purego.SyscallN(f.texImage2D, uintptr(glTexture2D), 0, uintptr(glDepthComponent32F), uintptr(w), uintptr(h), 0, uintptr(glDepthComponent), uintptr(glFloat), 0)
 		case t.floatTx:
-			purego.SyscallN(f.texImage2D, uintptr(glTexture2D), 0, uintptr(glRGBA32F), uintptr(w), uintptr(h), 0, uintptr(glRGBA), uintptr(glFloat), 0)
+			// Immutable storage, so that a compute shader can bind the
+			// texture as an image (glBindImageTexture requires it on ES).
+			purego.SyscallN(f.texStorage2D, uintptr(glTexture2D), 1, uintptr(glRGBA32F), uintptr(w), uintptr(h))
 		default:
 			purego.SyscallN(f.texImage2D, uintptr(glTexture2D), 0, uintptr(glRGBA8), uintptr(w), uintptr(h), 0, uintptr(glRGBA), uintptr(glUnsignedByte), 0)
 		}
-		purego.SyscallN(f.texParameteri, uintptr(glTexture2D), uintptr(glTexMinFilter), uintptr(glNearest))
-		purego.SyscallN(f.texParameteri, uintptr(glTexture2D), uintptr(glTexMagFilter), uintptr(glNearest))
+		if samples <= 1 {
+			purego.SyscallN(f.texParameteri, uintptr(glTexture2D), uintptr(glTexMinFilter), uintptr(glNearest))
+			purego.SyscallN(f.texParameteri, uintptr(glTexture2D), uintptr(glTexMagFilter), uintptr(glNearest))
+		}
 		// A color render target gets its own framebuffer (color attachment 0). A
 		// depth texture carries no framebuffer of its own: beginRender attaches it
 		// to a color pass's framebuffer as the depth attachment.
 		if renderTarget && !t.depth {
 			purego.SyscallN(f.genFramebuffers, 1, uintptr(unsafe.Pointer(&t.fbo)))
 			purego.SyscallN(f.bindFramebuffer, uintptr(glFramebuffer), uintptr(t.fbo))
-			purego.SyscallN(f.framebufferTexture2D, uintptr(glFramebuffer), uintptr(glColorAttachment0), uintptr(glTexture2D), uintptr(t.id), 0)
+			purego.SyscallN(f.framebufferTexture2D, uintptr(glFramebuffer), uintptr(glColorAttachment0), t.target, uintptr(t.id), 0)
 		}
 	})
 	return t, nil
@@ -552,6 +610,12 @@ func (t *glTexture) write(pixels []byte, bytesPerRow int) {
 	t.b.do(func() {
 		f := &t.b.fns
 		purego.SyscallN(f.bindTexture, uintptr(glTexture2D), uintptr(t.id))
+		if t.floatTx {
+			// The storage of a float texture is immutable, see newTexture.
+			purego.SyscallN(f.texSubImage2D, uintptr(glTexture2D), 0, 0, 0, uintptr(t.w), uintptr(t.h), uintptr(glRGBA), uintptr(glFloat), uintptr(unsafe.Pointer(&pixels[0])))
+			runtime.KeepAlive(pixels)
+			return
+		}
 		purego.SyscallN(f.texImage2D, uintptr(glTexture2D), 0, uintptr(glRGBA8), uintptr(t.w), uintptr(t.h), 0, uintptr(glRGBA), uintptr(glUnsignedByte), uintptr(unsafe.Pointer(&pixels[0])))
 		runtime.KeepAlive(pixels)
 	})
@@ -576,7 +640,7 @@ func (b *glBackend) newWindowSurface(display, window uintptr, w, h int) (backend
 	_ = display
 	// newTexture marshals onto the context thread itself, so it is called
 	// outside the do() below to avoid a nested (deadlocking) do.
-	bt, err := b.newTexture(RGBA8Unorm, w, h, true)
+	bt, err := b.newTexture(RGBA8Unorm, w, h, true, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -628,7 +692,7 @@ func (s *glWindowSurface) resize(w, h int) error {
 	// The EGL window surface auto-tracks the window size on most drivers; only the
 	// upload/blit texture needs reallocating. newTexture self-marshals onto the
 	// context thread, so it is not wrapped in do() here.
-	bt, err := s.b.newTexture(RGBA8Unorm, w, h, true)
+	bt, err := s.b.newTexture(RGBA8Unorm, w, h, true, 1)
 	if err != nil {
 		return err
 	}
@@ -675,7 +739,7 @@ type glRenderPipeline struct{ program uint32 }
 
 func (glRenderPipeline) isRenderPipeline() {}
 
-func (b *glBackend) newRenderPipeline(vmod backendShaderModule, ventry string, fmod backendShaderModule, fentry string, color TextureFormat, extraColor []TextureFormat, depth TextureFormat) (backendRenderPipeline, error) {
+func (b *glBackend) newRenderPipeline(vmod backendShaderModule, ventry string, fmod backendShaderModule, fentry string, color TextureFormat, extraColor []TextureFormat, depth TextureFormat, samples int) (backendRenderPipeline, error) {
 	vs, ok1 := vmod.(glShaderModule)
 	fs, ok2 := fmod.(glShaderModule)
 	if !ok1 || !ok2 {
@@ -741,6 +805,15 @@ func (c *glCmd) beginRender(info renderPassInfo) {
 	extra := info.extraColor
 	depth, _ := info.depth.(*glTexture)
 	clearDepth := float32(info.clearDepth)
+	c.fbo, c.resolves = t.fbo, nil
+	if info.resolve != nil {
+		c.resolves = append(c.resolves, glResolve{att: glColorAttachment0, dst: info.resolve.(*glTexture)})
+	}
+	for i, ec := range extra {
+		if ec.resolve != nil {
+			c.resolves = append(c.resolves, glResolve{att: uint32(glColorAttachment1 + i), dst: ec.resolve.(*glTexture)})
+		}
+	}
 	c.record(func() {
 		f := &c.b.fns
 		purego.SyscallN(f.bindFramebuffer, uintptr(glFramebuffer), uintptr(t.fbo))
@@ -752,7 +825,7 @@ func (c *glCmd) beginRender(info renderPassInfo) {
 		for i, ec := range extra {
 			et := ec.tex.(*glTexture)
 			att := uint32(glColorAttachment1 + i)
-			purego.SyscallN(f.framebufferTexture2D, uintptr(glFramebuffer), uintptr(att), uintptr(glTexture2D), uintptr(et.id), 0)
+			purego.SyscallN(f.framebufferTexture2D, uintptr(glFramebuffer), uintptr(att), et.target, uintptr(et.id), 0)
 			bufs = append(bufs, att)
 		}
 		if len(bufs) > 1 {
@@ -762,7 +835,7 @@ func (c *glCmd) beginRender(info renderPassInfo) {
 		// Depth: attach + enable the standard 3D test (less, write, fresh clear), or
 		// disable depth testing for a color-only pass.
 		if depth != nil {
-			purego.SyscallN(f.framebufferTexture2D, uintptr(glFramebuffer), uintptr(glDepthAttachment), uintptr(glTexture2D), uintptr(depth.id), 0)
+			purego.SyscallN(f.framebufferTexture2D, uintptr(glFramebuffer), uintptr(glDepthAttachment), depth.target, uintptr(depth.id), 0)
 			purego.SyscallN(f.enable, uintptr(glDepthTest))
 			purego.SyscallN(f.depthFunc, uintptr(glLess))
 			purego.SyscallN(f.depthMask, uintptr(glTrue))
@@ -807,9 +880,37 @@ func (c *glCmd) draw(prim Primitive, start, count int) {
 	c.record(func() { purego.SyscallN(c.b.fns.drawArrays, mode, uintptr(start), uintptr(count)) })
 }
 
-func (c *glCmd) endRender() {}
+// endRender resolves the multisampled color attachments of the render pass
+// by blitting each into the framebuffer of its resolve texture.
+func (c *glCmd) endRender() {
+	if len(c.resolves) == 0 {
+		return
+	}
+	fbo, resolves := c.fbo, c.resolves
+	c.resolves = nil
+	c.record(func() {
+		f := &c.b.fns
+		for _, r := range resolves {
+			w, h := uintptr(r.dst.w), uintptr(r.dst.h)
+			purego.SyscallN(f.bindFramebuffer, uintptr(glReadFramebuffer), uintptr(fbo))
+			purego.SyscallN(f.readBuffer, uintptr(r.att))
+			purego.SyscallN(f.bindFramebuffer, uintptr(glDrawFramebuffer), uintptr(r.dst.fbo))
+			purego.SyscallN(f.blitFramebuffer, 0, 0, w, h, 0, 0, w, h, uintptr(glColorBufferBit), uintptr(glNearest))
+		}
+		purego.SyscallN(f.readBuffer, uintptr(glColorAttachment0))
+		purego.SyscallN(f.bindFramebuffer, uintptr(glFramebuffer), 0)
+	})
+}
+
+// setComputeTexture binds an RGBA32Float texture to the image unit index,
+// read-only, where the compute shader loads it with imageLoad.
+func (c *glCmd) setComputeTexture(index int, t backendTexture) {
+	gt := t.(*glTexture)
+	c.record(func() {
+		purego.SyscallN(c.b.fns.bindImageTexture, uintptr(index), uintptr(gt.id), 0, 0, 0, uintptr(glReadOnly), uintptr(glRGBA32F))
+	})
+}
 
-func (c *glCmd) setComputeTexture(index int, t backendTexture) {}
 func (c *glCmd) setComputeSampler(index int, s backendSampler) {}
 
 // cStr converts a NUL-terminated C string at p to a Go string.
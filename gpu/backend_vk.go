@@ -8,13 +8,16 @@
 // gpu/vk*_linux_test.go probes) behind the private backend interface, so
 // gpu.Open(WithDriver(DriverVulkan)) is a first-class driver alongside Metal and
 // GL. Vulkan is reached through purego (no cgo). Shader modules consume SPIR-V
-// (ShaderSource.SPIRV); a Go->SPIR-V emitter is a follow-up, so callers compile
-// their GLSL to SPIR-V (e.g. with glslang) today. Compute only; render is a
-// follow-up. Verified in CI on Mesa lavapipe.
+// (ShaderSource.SPIRV): the Go kernels compile to it with shader.CompileSPIRV,
+// and other shaders with glslang. Compute, with storage buffers and
+// RGBA32Float storage textures, and render to texture (depth, MRT, MSAA
+// resolve, readback) are implemented; a window surface is a follow-up.
+// Verified in CI on Mesa lavapipe.
 package gpu
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"unsafe"
 
@@ -128,6 +131,10 @@ type (
 		buffer      uintptr
 		offset, rng uint64
 	}
+	vkDescriptorImageInfoB struct {
+		sampler, imageView uintptr
+		imageLayout        uint32
+	}
 	vkWriteDescriptorSetB struct {
 		sType                                                  uint32
 		pNext                                                  uintptr
@@ -162,6 +169,171 @@ type (
 		signalCount       uint32
 		pSignal           uintptr
 	}
+	vkImageCreateInfoB struct {
+		sType                    uint32
+		pNext                    uintptr
+		flags, imageType, format uint32
+		width, height, depth     uint32
+		mipLevels, arrayLayers   uint32
+		samples, tiling, usage   uint32
+		mode, qfiCount           uint32
+		pQFI                     uintptr
+		initialLayout            uint32
+	}
+	vkSubresourceRangeB struct {
+		aspectMask, baseMip, levelCount, baseLayer, layerCount uint32
+	}
+	vkImageViewCreateInfoB struct {
+		sType            uint32
+		pNext            uintptr
+		flags            uint32
+		image            uintptr
+		viewType, format uint32
+		components       [4]uint32
+		rng              vkSubresourceRangeB
+	}
+	vkMemoryBarrierB struct {
+		sType          uint32
+		pNext          uintptr
+		srcAccess, dst uint32
+	}
+	vkImageMemoryBarrierB struct {
+		sType                uint32
+		pNext                uintptr
+		srcAccess, dstAccess uint32
+		oldLayout, newLayout uint32
+		srcQueue, dstQueue   uint32
+		image                uintptr
+		rng                  vkSubresourceRangeB
+	}
+	vkBufferImageCopyB struct {
+		bufferOffset                              uint64
+		bufferRowLength, bufferImageHeight        uint32
+		aspectMask, mipLevel, baseLayer, layerCnt uint32
+		x, y, z                                   int32
+		width, height, depth                      uint32
+	}
+	vkAttachmentDescriptionB struct {
+		flags, format, samples                     uint32
+		loadOp, storeOp, stencilLoad, stencilStore uint32
+		initialLayout, finalLayout                 uint32
+	}
+	vkAttachmentReferenceB struct{ attachment, layout uint32 }
+	vkSubpassDescriptionB  struct {
+		flags, bindPoint, inputCount uint32
+		pInput                       uintptr
+		colorCount                   uint32
+		pColor, pResolve, pDepth     uintptr
+		preserveCount                uint32
+		pPreserve                    uintptr
+	}
+	vkRenderPassCreateInfoB struct {
+		sType                  uint32
+		pNext                  uintptr
+		flags, attachmentCount uint32
+		pAttachments           uintptr
+		subpassCount           uint32
+		pSubpasses             uintptr
+		dependencyCount        uint32
+		pDependencies          uintptr
+	}
+	vkFramebufferCreateInfoB struct {
+		sType                 uint32
+		pNext                 uintptr
+		flags                 uint32
+		renderPass            uintptr
+		attachmentCount       uint32
+		pAttachments          uintptr
+		width, height, layers uint32
+	}
+	vkRenderPassBeginInfoB struct {
+		sType                   uint32
+		pNext                   uintptr
+		renderPass, framebuffer uintptr
+		x, y                    int32
+		width, height           uint32
+		clearValueCount         uint32
+		pClearValues            uintptr
+	}
+	vkVertexInputStateB struct {
+		sType               uint32
+		pNext               uintptr
+		flags, bindingCount uint32
+		pBindings           uintptr
+		attributeCount      uint32
+		pAttributes         uintptr
+	}
+	vkInputAssemblyStateB struct {
+		sType                    uint32
+		pNext                    uintptr
+		flags, topology, restart uint32
+	}
+	vkViewportStateB struct {
+		sType                uint32
+		pNext                uintptr
+		flags, viewportCount uint32
+		pViewports           uintptr
+		scissorCount         uint32
+		pScissors            uintptr
+	}
+	vkRasterizationStateB struct {
+		sType                                         uint32
+		pNext                                         uintptr
+		flags, depthClamp, discard, polygonMode       uint32
+		cullMode, frontFace, depthBias                uint32
+		biasConstant, biasClamp, biasSlope, lineWidth float32
+	}
+	vkMultisampleStateB struct {
+		sType                         uint32
+		pNext                         uintptr
+		flags, samples, sampleShading uint32
+		minSampleShading              float32
+		pSampleMask                   uintptr
+		alphaToCoverage, alphaToOne   uint32
+	}
+	vkDepthStencilStateB struct {
+		sType                                      uint32
+		pNext                                      uintptr
+		flags, depthTest, depthWrite, depthCompare uint32
+		depthBoundsTest, stencilTest               uint32
+		front, back                                [7]uint32
+		minDepthBounds, maxDepthBounds             float32
+	}
+	vkColorBlendAttachmentB struct {
+		blendEnable, srcColor, dstColor, colorOp uint32
+		srcAlpha, dstAlpha, alphaOp, writeMask   uint32
+	}
+	vkColorBlendStateB struct {
+		sType                                      uint32
+		pNext                                      uintptr
+		flags, logicOpEnable, logicOp, attachCount uint32
+		pAttachments                               uintptr
+		blendConstants                             [4]float32
+	}
+	vkDynamicStateB struct {
+		sType             uint32
+		pNext             uintptr
+		flags, stateCount uint32
+		pStates           uintptr
+	}
+	vkGraphicsPipelineCreateInfoB struct {
+		sType                                    uint32
+		pNext                                    uintptr
+		flags, stageCount                        uint32
+		pStages, pVertexInput, pInputAssembly    uintptr
+		pTessellation, pViewport, pRasterization uintptr
+		pMultisample, pDepthStencil, pColorBlend uintptr
+		pDynamic                                 uintptr
+		layout, renderPass                       uintptr
+		subpass                                  uint32
+		basePipeline                             uintptr
+		baseIndex                                int32
+	}
+	vkViewportB struct{ x, y, width, height, minDepth, maxDepth float32 }
+	vkRect2DB   struct {
+		x, y          int32
+		width, height uint32
+	}
 )
 
 const (
@@ -183,13 +355,66 @@ const (
 	vksCmdBufAlloc = 40
 	vksCmdBegin    = 42
 
+	vksImage         = 14
+	vksImageView     = 15
+	vksVertexInput   = 19
+	vksInputAssembly = 20
+	vksViewport      = 22
+	vksRasterization = 23
+	vksMultisample   = 24
+	vksDepthStencil  = 25
+	vksColorBlend    = 26
+	vksDynamicState  = 27
+	vksGraphicsPipe  = 28
+	vksFramebuffer   = 37
+	vksRenderPass    = 38
+	vksRenderBegin   = 43
+	vksImageBarrier  = 45
+	vksMemBarrier    = 46
+
 	vkUsageStorage      = 0x20
 	vkMemHostVisibleB   = 0x2
 	vkMemHostCoherentB  = 0x4
+	vkDescStorageImage  = 3
 	vkDescStorageBuffer = 7
 	vkStageComputeB     = 0x20
 	vkBindCompute       = 1
 	vkQueueComputeBitB  = 0x2
+
+	vkUsageTransferSrc  = 0x1
+	vkUsageTransferDst  = 0x2
+	vkImageSampled      = 0x4
+	vkImageStorage      = 0x8
+	vkImageColorAttach  = 0x10
+	vkImageDepthAttach  = 0x20
+	vkMemDeviceLocalB   = 0x1
+	vkFormatRGBA8       = 37
+	vkFormatRGBA32F     = 109
+	vkFormatD32         = 126
+	vkLayoutUndefined   = 0
+	vkLayoutGeneral     = 1
+	vkAspectColor       = 0x1
+	vkAspectDepth       = 0x2
+	vkStageVertexB      = 0x1
+	vkStageFragmentB    = 0x10
+	vkBindGraphics      = 0
+	vkPipeTopOfPipe     = 0x1
+	vkPipeHost          = 0x4000
+	vkPipeAllCommands   = 0x10000
+	vkAccessHostRead    = 0x2000
+	vkAccessMemoryRead  = 0x8000
+	vkAccessMemoryWrite = 0x10000
+	vkLoadOpLoad        = 0
+	vkLoadOpClear       = 1
+	vkLoadOpDontCare    = 2
+	vkStoreOpStore      = 0
+	vkStoreOpDontCare   = 1
+	vkCompareLess       = 1
+	vkFrontClockwise    = 1
+	vkDynamicViewport   = 0
+	vkDynamicScissor    = 1
+	vkQueueIgnored      = ^uint32(0)
+	vkColorWriteAll     = 0xF
 )
 
 type vkBackend struct {
@@ -203,6 +428,9 @@ type vkBackend struct {
 	memProp []byte
 	memN    uint32
 	mu      sync.Mutex
+
+	// passes caches the render passes by their attachments, see renderPass.
+	passes map[vkPassKey]uintptr
 }
 
 func (b *vkBackend) c(name string, args ...uintptr) {
@@ -233,7 +461,7 @@ func newVKBackend() (b *vkBackend, err error) {
 	if e != nil {
 		return nil, fmt.Errorf("gpu/vk: %w", e)
 	}
-	b = &vkBackend{lib: lib, fn: map[string]uintptr{}}
+	b = &vkBackend{lib: lib, fn: map[string]uintptr{}, passes: map[vkPassKey]uintptr{}}
 	for _, name := range []string{
 		"vkCreateInstance", "vkEnumeratePhysicalDevices", "vkGetPhysicalDeviceQueueFamilyProperties",
 		"vkCreateDevice", "vkGetDeviceQueue", "vkGetPhysicalDeviceMemoryProperties",
@@ -243,7 +471,11 @@ func newVKBackend() (b *vkBackend, err error) {
 		"vkCreateDescriptorPool", "vkResetDescriptorPool", "vkAllocateDescriptorSets", "vkUpdateDescriptorSets",
 		"vkCreateCommandPool", "vkResetCommandPool", "vkAllocateCommandBuffers", "vkBeginCommandBuffer",
 		"vkCmdBindPipeline", "vkCmdBindDescriptorSets", "vkCmdDispatch", "vkEndCommandBuffer",
-		"vkQueueSubmit", "vkDeviceWaitIdle", "vkDestroyDevice",
+		"vkQueueSubmit", "vkDeviceWaitIdle", "vkDestroyDevice", "vkDestroyDescriptorPool",
+		"vkCreateImage", "vkGetImageMemoryRequirements", "vkBindImageMemory", "vkCreateImageView",
+		"vkCreateRenderPass", "vkCreateFramebuffer", "vkDestroyFramebuffer", "vkCreateGraphicsPipelines",
+		"vkCmdBeginRenderPass", "vkCmdEndRenderPass", "vkCmdSetViewport", "vkCmdSetScissor", "vkCmdDraw",
+		"vkCmdPipelineBarrier", "vkCmdCopyImageToBuffer", "vkCmdCopyBufferToImage",
 	} {
 		p, e := purego.Dlsym(lib, name)
 		if e != nil {
@@ -309,6 +541,20 @@ func (b *vkBackend) hostMemType(bits uint32) uint32 {
 	panic("gpu/vk: no host-visible coherent memory type")
 }
 
+// deviceMemType returns a memory type of bits for an image, device-local if
+// the device has one (lavapipe's only memory is both).
+func (b *vkBackend) deviceMemType(bits uint32) uint32 {
+	for _, want := range []uint32{vkMemDeviceLocalB, 0} {
+		for i := 0; i < int(b.memN); i++ {
+			f := *(*uint32)(unsafe.Pointer(&b.memProp[4+i*8]))
+			if bits&(1<<uint(i)) != 0 && f&want == want {
+				return uint32(i)
+			}
+		}
+	}
+	panic("gpu/vk: no memory type for an image")
+}
+
 type vkBuffer struct {
 	b              *vkBackend
 	buffer, memory uintptr
@@ -324,8 +570,18 @@ func (b *vkBackend) newBuffer(size int, usage BufferUsage, data []byte) (bb back
 	}()
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	buf := b.hostBuffer(size, vkUsageStorage)
+	if len(data) > 0 {
+		copy(unsafe.Slice((*byte)(buf.ptr), size), data)
+	}
+	return buf, nil
+}
+
+// hostBuffer creates a buffer in host-visible coherent memory, mapped for
+// the lifetime of the buffer. The caller holds b.mu.
+func (b *vkBackend) hostBuffer(size int, usage uint32) *vkBuffer {
 	buf := &vkBuffer{b: b, size: size}
-	bci := vkBufferCreateInfoB{sType: vksBuffer, size: uint64(size), usage: vkUsageStorage}
+	bci := vkBufferCreateInfoB{sType: vksBuffer, size: uint64(size), usage: usage}
 	b.c("vkCreateBuffer", b.device, uintptr(unsafe.Pointer(&bci)), 0, uintptr(unsafe.Pointer(&buf.buffer)))
 	var req vkMemoryRequirementsB
 	purego.SyscallN(b.fn["vkGetBufferMemoryRequirements"], b.device, buf.buffer, uintptr(unsafe.Pointer(&req)))
@@ -335,10 +591,7 @@ func (b *vkBackend) newBuffer(size int, usage BufferUsage, data []byte) (bb back
 	var p uintptr
 	b.c("vkMapMemory", b.device, buf.memory, 0, uintptr(size), 0, uintptr(unsafe.Pointer(&p)))
 	buf.ptr = unsafe.Pointer(p)
-	if len(data) > 0 {
-		copy(unsafe.Slice((*byte)(buf.ptr), size), data)
-	}
-	return buf, nil
+	return buf
 }
 
 func (b *vkBuffer) bytes() []byte {
@@ -350,6 +603,11 @@ func (b *vkBuffer) bytes() []byte {
 func (b *vkBuffer) release() {
 	b.b.mu.Lock()
 	defer b.b.mu.Unlock()
+	b.free()
+}
+
+// free destroys the buffer and its memory. The caller holds b.b.mu.
+func (b *vkBuffer) free() {
 	purego.SyscallN(b.b.fn["vkDestroyBuffer"], b.b.device, b.buffer, 0)
 	purego.SyscallN(b.b.fn["vkFreeMemory"], b.b.device, b.memory, 0)
 }
@@ -362,7 +620,7 @@ func (vkModule) isShaderModule() {}
 
 func (b *vkBackend) newShaderModule(src ShaderSource) (m backendShaderModule, err error) {
 	if len(src.SPIRV) == 0 {
-		return nil, fmt.Errorf("gpu/vk: ShaderSource.SPIRV is empty (the Vulkan backend needs SPIR-V; compile GLSL with glslang)")
+		return nil, fmt.Errorf("gpu/vk: ShaderSource.SPIRV is empty (the Vulkan backend needs SPIR-V; compile Go kernels with shader.CompileSPIRV)")
 	}
 	if len(src.SPIRV)%4 != 0 {
 		return nil, fmt.Errorf("gpu/vk: SPIR-V length %d is not a multiple of 4", len(src.SPIRV))
@@ -389,34 +647,37 @@ type vkPipeline struct {
 	module uintptr
 	entry  []byte
 
-	built    bool
-	nbind    int
-	dsl      uintptr
-	layout   uintptr
-	pipeline uintptr
+	built     bool
+	nbind     int
+	nimg      int
+	dsl, idsl uintptr // the buffers (set 0) and the images (set 1)
+	layout    uintptr
+	pipeline  uintptr
 }
 
 func (p *vkPipeline) maxThreads() int { return 1024 }
 
 func (b *vkBackend) newComputePipeline(mod backendShaderModule, entry string) (backendComputePipeline, error) {
-	// SPIR-V from our GLSL pipeline (glslang) always names the compute entry
-	// point "main" (from GLSL's void main()), regardless of the Device-API entry
+	// SPIR-V from shader.CompileSPIRV, as from glslang (GLSL's void main()),
+	// names the compute entry point "main", regardless of the Device-API entry
 	// name (which is the Go kernel name, used by the Metal/MSL backend). Use the
 	// SPIR-V convention here.
 	return &vkPipeline{b: b, module: mod.(vkModule).module, entry: append([]byte("main"), 0)}, nil
 }
 
-// build lazily creates the descriptor-set layout, pipeline layout and compute
-// pipeline once the binding count is known (at first dispatch).
-func (p *vkPipeline) build(nbind int) {
+// build lazily creates the descriptor-set layouts, pipeline layout and compute
+// pipeline once the binding counts are known (at first dispatch): nbind
+// storage buffers in set 0 and, if the kernel loads textures, nimg storage
+// images in set 1, their own binding space as on GL.
+func (p *vkPipeline) build(nbind, nimg int) {
 	b := p.b
-	binds := make([]vkDSLBindingB, nbind)
-	for i := range binds {
-		binds[i] = vkDSLBindingB{binding: uint32(i), descriptorType: vkDescStorageBuffer, descriptorCount: 1, stageFlags: vkStageComputeB}
+	p.dsl = b.setLayout(vkDescStorageBuffer, nbind)
+	sets := []uintptr{p.dsl}
+	if nimg > 0 {
+		p.idsl = b.setLayout(vkDescStorageImage, nimg)
+		sets = append(sets, p.idsl)
 	}
-	dslci := vkDSLCreateInfoB{sType: vksDSL, bindingCount: uint32(nbind), pBindings: uintptr(unsafe.Pointer(&binds[0]))}
-	b.c("vkCreateDescriptorSetLayout", b.device, uintptr(unsafe.Pointer(&dslci)), 0, uintptr(unsafe.Pointer(&p.dsl)))
-	plci := vkPipelineLayoutCreateInfoB{sType: vksPipeLayout, setLayoutCount: 1, pSetLayouts: uintptr(unsafe.Pointer(&p.dsl))}
+	plci := vkPipelineLayoutCreateInfoB{sType: vksPipeLayout, setLayoutCount: uint32(len(sets)), pSetLayouts: uintptr(unsafe.Pointer(&sets[0]))}
 	b.c("vkCreatePipelineLayout", b.device, uintptr(unsafe.Pointer(&plci)), 0, uintptr(unsafe.Pointer(&p.layout)))
 	cpci := vkComputePipelineCreateInfoB{
 		sType:  vksComputePipe,
@@ -424,20 +685,92 @@ func (p *vkPipeline) build(nbind int) {
 		layout: p.layout,
 	}
 	b.c("vkCreateComputePipelines", b.device, 0, 1, uintptr(unsafe.Pointer(&cpci)), 0, uintptr(unsafe.Pointer(&p.pipeline)))
-	p.nbind = nbind
+	p.nbind, p.nimg = nbind, nimg
 	p.built = true
 }
 
+// setLayout creates a descriptor-set layout of n compute descriptors of type
+// typ at the bindings 0 to n-1.
+func (b *vkBackend) setLayout(typ uint32, n int) uintptr {
+	binds := make([]vkDSLBindingB, n)
+	for i := range binds {
+		binds[i] = vkDSLBindingB{binding: uint32(i), descriptorType: typ, descriptorCount: 1, stageFlags: vkStageComputeB}
+	}
+	dslci := vkDSLCreateInfoB{sType: vksDSL, bindingCount: uint32(n)}
+	if n > 0 {
+		dslci.pBindings = uintptr(unsafe.Pointer(&binds[0]))
+	}
+	var dsl uintptr
+	b.c("vkCreateDescriptorSetLayout", b.device, uintptr(unsafe.Pointer(&dslci)), 0, uintptr(unsafe.Pointer(&dsl)))
+	return dsl
+}
+
 type vkBufBind struct {
 	buf   *vkBuffer
 	index int
 }
 
+// rebind binds buf at index in binds, replacing an earlier binding there.
+func rebind(binds []vkBufBind, buf backendBuffer, index int) []vkBufBind {
+	for i := range binds {
+		if binds[i].index == index {
+			binds[i].buf = buf.(*vkBuffer)
+			return binds
+		}
+	}
+	return append(binds, vkBufBind{buf: buf.(*vkBuffer), index: index})
+}
+
+// bindCount is the binding count of a descriptor set that holds binds.
+func bindCount(binds []vkBufBind) int {
+	n := 0
+	for _, bd := range binds {
+		if bd.index+1 > n {
+			n = bd.index + 1
+		}
+	}
+	return n
+}
+
+// vkImgBind is a storage texture bound at index.
+type vkImgBind struct {
+	tex   *vkTexture
+	index int
+}
+
+// vkDispatch is a recorded compute dispatch.
+type vkDispatch struct {
+	pipe   *vkPipeline
+	binds  []vkBufBind
+	images []vkImgBind
+	x      int
+}
+
+// vkPass is a recorded render pass and its draws.
+type vkPass struct {
+	info  renderPassInfo
+	draws []vkDraw
+}
+
+type vkDraw struct {
+	pipe         *vkRenderPipeline
+	binds        []vkBufBind
+	topology     uint32
+	start, count int
+}
+
+// vkCmd records the dispatches and render passes of a command buffer. The
+// pipelines and descriptor sets they need are only known once recorded, so
+// commit creates them and replays the passes into one Vulkan command buffer,
+// with a barrier between consecutive passes.
 type vkCmd struct {
-	b     *vkBackend
-	pipe  *vkPipeline
-	binds []vkBufBind
-	gx    int
+	b      *vkBackend
+	ops    []any // *vkDispatch or *vkPass
+	pipe   *vkPipeline
+	rpipe  *vkRenderPipeline
+	pass   *vkPass
+	binds  []vkBufBind
+	images []vkImgBind
 }
 
 func (b *vkBackend) newCommandBuffer() backendCommandBuffer { return &vkCmd{b: b} }
@@ -449,44 +782,141 @@ func (b *vkBackend) newWindowSurface(display, window uintptr, w, h int) (backend
 }
 
 func (b *vkBackend) windowVisualID() uint32 { return 0 }
+func (b *vkBackend) storageTextures() bool  { return true }
 
-func (c *vkCmd) beginCompute() {}
+func (c *vkCmd) beginCompute() { c.binds, c.images = nil, nil }
 func (c *vkCmd) setComputePipeline(p backendComputePipeline) {
 	c.pipe = p.(*vkPipeline)
 }
 func (c *vkCmd) setBuffer(buf backendBuffer, offset, index int) {
-	c.binds = append(c.binds, vkBufBind{buf: buf.(*vkBuffer), index: index})
+	c.binds = rebind(c.binds, buf, index)
+}
+func (c *vkCmd) dispatch(x, y, z int) {
+	binds := append([]vkBufBind(nil), c.binds...)
+	images := append([]vkImgBind(nil), c.images...)
+	c.ops = append(c.ops, &vkDispatch{pipe: c.pipe, binds: binds, images: images, x: x})
+}
+func (c *vkCmd) endCompute() {}
+
+func (c *vkCmd) beginRender(info renderPassInfo) {
+	c.pass = &vkPass{info: info}
+	c.binds = nil
+	c.ops = append(c.ops, c.pass)
 }
-func (c *vkCmd) dispatch(x, y, z int) { c.gx = x }
-func (c *vkCmd) endCompute()          {}
+
+func (c *vkCmd) setRenderPipeline(p backendRenderPipeline) {
+	c.rpipe = p.(*vkRenderPipeline)
+}
+
+func (c *vkCmd) setRenderBuffer(buf backendBuffer, offset, index int) {
+	c.binds = rebind(c.binds, buf, index)
+}
+
+// setVertexBuffer binds a vertex buffer as the storage buffer at index,
+// which the vertex shader indexes by gl_VertexIndex, as on GL.
+func (c *vkCmd) setVertexBuffer(buf backendBuffer, index int) {
+	c.binds = rebind(c.binds, buf, index)
+}
+
+func (c *vkCmd) draw(prim Primitive, start, count int) {
+	binds := append([]vkBufBind(nil), c.binds...)
+	c.pass.draws = append(c.pass.draws, vkDraw{pipe: c.rpipe, binds: binds, topology: vkTopology(prim), start: start, count: count})
+}
+
+// endRender has nothing to do: the render pass resolves its multisampled
+// attachments itself, see passLayout.
+func (c *vkCmd) endRender() { c.pass = nil }
+
+// setComputeTexture binds an RGBA32Float texture as the storage image at
+// index of set 1, replacing an earlier binding there.
+func (c *vkCmd) setComputeTexture(index int, t backendTexture) {
+	for i := range c.images {
+		if c.images[i].index == index {
+			c.images[i].tex = t.(*vkTexture)
+			return
+		}
+	}
+	c.images = append(c.images, vkImgBind{tex: t.(*vkTexture), index: index})
+}
+func (c *vkCmd) setComputeSampler(index int, s backendSampler) {}
 
 func (c *vkCmd) commit() {
 	b := c.b
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	if len(c.ops) == 0 {
+		return
+	}
 
-	nbind := 0
-	for _, bd := range c.binds {
-		if bd.index+1 > nbind {
-			nbind = bd.index + 1
+	sets, descs, imgs := 0, 0, 0
+	for _, op := range c.ops {
+		switch op := op.(type) {
+		case *vkDispatch:
+			if !op.pipe.built {
+				nimg := 0
+				for _, im := range op.images {
+					nimg = max(nimg, im.index+1)
+				}
+				op.pipe.build(bindCount(op.binds), nimg)
+			}
+			sets, descs = sets+1, descs+op.pipe.nbind
+			if op.pipe.nimg > 0 {
+				sets, imgs = sets+1, imgs+op.pipe.nimg
+			}
+		case *vkPass:
+			for _, d := range op.draws {
+				sets, descs = sets+1, descs+bindCount(d.binds)
+			}
 		}
 	}
-	if !c.pipe.built {
-		c.pipe.build(nbind)
-	}
 
 	// Fresh descriptor pool per submit (simplest correct lifetime).
-	poolSize := vkDescriptorPoolSizeB{typ: vkDescStorageBuffer, descriptorCount: uint32(nbind)}
-	dpci := vkDescriptorPoolCreateInfoB{sType: vksDescPool, maxSets: 1, poolSizeCount: 1, pPoolSizes: uintptr(unsafe.Pointer(&poolSize))}
+	poolSizes := []vkDescriptorPoolSizeB{
+		{typ: vkDescStorageBuffer, descriptorCount: uint32(max(descs, 1))},
+		{typ: vkDescStorageImage, descriptorCount: uint32(max(imgs, 1))},
+	}
+	dpci := vkDescriptorPoolCreateInfoB{sType: vksDescPool, maxSets: uint32(max(sets, 1)), poolSizeCount: 2, pPoolSizes: uintptr(unsafe.Pointer(&poolSizes[0]))}
 	var pool uintptr
 	b.c("vkCreateDescriptorPool", b.device, uintptr(unsafe.Pointer(&dpci)), 0, uintptr(unsafe.Pointer(&pool)))
-	dsai := vkDSAllocateInfoB{sType: vksDSAlloc, descriptorPool: pool, count: 1, pSetLayouts: uintptr(unsafe.Pointer(&c.pipe.dsl))}
+	defer purego.SyscallN(b.fn["vkDestroyDescriptorPool"], b.device, pool, 0)
+
+	var framebuffers []uintptr
+	cmd := b.beginCommands()
+	for i, op := range c.ops {
+		if i > 0 {
+			b.barrier(cmd)
+		}
+		switch op := op.(type) {
+		case *vkDispatch:
+			sets := []uintptr{b.descriptorSet(pool, op.pipe.dsl, op.binds)}
+			if op.pipe.nimg > 0 {
+				sets = append(sets, b.imageSet(pool, op.pipe.idsl, op.images))
+			}
+			purego.SyscallN(b.fn["vkCmdBindPipeline"], cmd, vkBindCompute, op.pipe.pipeline)
+			purego.SyscallN(b.fn["vkCmdBindDescriptorSets"], cmd, vkBindCompute, op.pipe.layout, 0, uintptr(len(sets)), uintptr(unsafe.Pointer(&sets[0])), 0, 0)
+			purego.SyscallN(b.fn["vkCmdDispatch"], cmd, uintptr(op.x), 1, 1)
+		case *vkPass:
+			framebuffers = append(framebuffers, b.recordPass(cmd, pool, op))
+		}
+	}
+	b.submit(cmd)
+	for _, fb := range framebuffers {
+		purego.SyscallN(b.fn["vkDestroyFramebuffer"], b.device, fb, 0)
+	}
+}
+
+// descriptorSet allocates a descriptor set of layout dsl from pool and
+// points its bindings at binds.
+func (b *vkBackend) descriptorSet(pool, dsl uintptr, binds []vkBufBind) uintptr {
+	dsai := vkDSAllocateInfoB{sType: vksDSAlloc, descriptorPool: pool, count: 1, pSetLayouts: uintptr(unsafe.Pointer(&dsl))}
 	var set uintptr
 	b.c("vkAllocateDescriptorSets", b.device, uintptr(unsafe.Pointer(&dsai)), uintptr(unsafe.Pointer(&set)))
-
-	infos := make([]vkDescriptorBufferInfoB, len(c.binds))
-	writes := make([]vkWriteDescriptorSetB, len(c.binds))
-	for i, bd := range c.binds {
+	if len(binds) == 0 {
+		return set
+	}
+	infos := make([]vkDescriptorBufferInfoB, len(binds))
+	writes := make([]vkWriteDescriptorSetB, len(binds))
+	for i, bd := range binds {
 		infos[i] = vkDescriptorBufferInfoB{buffer: bd.buf.buffer, rng: uint64(bd.buf.size)}
 		writes[i] = vkWriteDescriptorSetB{
 			sType: vksWriteDS, dstSet: set, dstBinding: uint32(bd.index), descriptorCount: 1,
@@ -494,22 +924,66 @@ func (c *vkCmd) commit() {
 		}
 	}
 	purego.SyscallN(b.fn["vkUpdateDescriptorSets"], b.device, uintptr(len(writes)), uintptr(unsafe.Pointer(&writes[0])), 0, 0)
+	return set
+}
+
+// imageSet allocates a descriptor set of layout dsl from pool and points its
+// bindings at the storage images of images, which stay in the GENERAL layout
+// of every texture.
+func (b *vkBackend) imageSet(pool, dsl uintptr, images []vkImgBind) uintptr {
+	dsai := vkDSAllocateInfoB{sType: vksDSAlloc, descriptorPool: pool, count: 1, pSetLayouts: uintptr(unsafe.Pointer(&dsl))}
+	var set uintptr
+	b.c("vkAllocateDescriptorSets", b.device, uintptr(unsafe.Pointer(&dsai)), uintptr(unsafe.Pointer(&set)))
+	if len(images) == 0 {
+		return set
+	}
+	infos := make([]vkDescriptorImageInfoB, len(images))
+	writes := make([]vkWriteDescriptorSetB, len(images))
+	for i, im := range images {
+		infos[i] = vkDescriptorImageInfoB{imageView: im.tex.view, imageLayout: vkLayoutGeneral}
+		writes[i] = vkWriteDescriptorSetB{
+			sType: vksWriteDS, dstSet: set, dstBinding: uint32(im.index), descriptorCount: 1,
+			descType: vkDescStorageImage, pImageInfo: uintptr(unsafe.Pointer(&infos[i])),
+		}
+	}
+	purego.SyscallN(b.fn["vkUpdateDescriptorSets"], b.device, uintptr(len(writes)), uintptr(unsafe.Pointer(&writes[0])), 0, 0)
+	return set
+}
 
+// beginCommands starts recording a fresh command buffer. Every submit waits
+// for the device, so the pool is reset and reused each time. The caller
+// holds b.mu.
+func (b *vkBackend) beginCommands() uintptr {
 	purego.SyscallN(b.fn["vkResetCommandPool"], b.device, b.cmdPool, 0)
 	cbai := vkCommandBufferAllocateInfoB{sType: vksCmdBufAlloc, commandPool: b.cmdPool, level: 0, cnt: 1}
 	var cmd uintptr
 	b.c("vkAllocateCommandBuffers", b.device, uintptr(unsafe.Pointer(&cbai)), uintptr(unsafe.Pointer(&cmd)))
 	begin := vkCommandBufferBeginInfoB{sType: vksCmdBegin}
 	b.c("vkBeginCommandBuffer", cmd, uintptr(unsafe.Pointer(&begin)))
-	purego.SyscallN(b.fn["vkCmdBindPipeline"], cmd, vkBindCompute, c.pipe.pipeline)
-	purego.SyscallN(b.fn["vkCmdBindDescriptorSets"], cmd, vkBindCompute, c.pipe.layout, 0, 1, uintptr(unsafe.Pointer(&set)), 0, 0)
-	purego.SyscallN(b.fn["vkCmdDispatch"], cmd, uintptr(c.gx), 1, 1)
-	b.c("vkEndCommandBuffer", cmd)
+	return cmd
+}
 
+// submit ends cmd, submits it and waits for the device.
+func (b *vkBackend) submit(cmd uintptr) {
+	b.c("vkEndCommandBuffer", cmd)
 	si := vkSubmitInfoB{sType: vksSubmit, cmdCount: 1, pCmd: uintptr(unsafe.Pointer(&cmd))}
 	b.c("vkQueueSubmit", b.queue, 1, uintptr(unsafe.Pointer(&si)), 0)
 	purego.SyscallN(b.fn["vkDeviceWaitIdle"], b.device)
-	purego.SyscallN(b.fn["vkResetDescriptorPool"], b.device, pool, 0)
+}
+
+// oneShot records commands with record and runs them to completion.
+func (b *vkBackend) oneShot(record func(cmd uintptr)) {
+	cmd := b.beginCommands()
+	record(cmd)
+	b.submit(cmd)
+}
+
+// barrier makes every write of the commands before it visible to the
+// commands after it. The passes of a command buffer are few, so a global
+// barrier costs nothing next to tracking each resource.
+func (b *vkBackend) barrier(cmd uintptr) {
+	mb := vkMemoryBarrierB{sType: vksMemBarrier, srcAccess: vkAccessMemoryWrite, dst: vkAccessMemoryRead | vkAccessMemoryWrite}
+	purego.SyscallN(b.fn["vkCmdPipelineBarrier"], cmd, vkPipeAllCommands, vkPipeAllCommands, 0, 1, uintptr(unsafe.Pointer(&mb)), 0, 0, 0, 0)
 }
 
 func (b *vkBackend) waitIdle() {
@@ -526,20 +1000,385 @@ func (b *vkBackend) close() error {
 	return nil
 }
 
-// Render / texture / sampler are not implemented on the Vulkan backend yet.
-func (b *vkBackend) newTexture(format TextureFormat, w, h int, renderTarget bool) (backendTexture, error) {
-	return nil, fmt.Errorf("gpu/vk: textures not yet implemented")
+// --- textures ---
+
+// vkTexture is an image in the GENERAL layout, which every use of it
+// (attachment, copy) accepts, so that no pass has to track its layout.
+//
+// The rows of the image are in GL's order: the viewport maps clip y = -1 to
+// row 0, as glViewport does, so that gl_FragCoord, the winding of the
+// triangles and the rows that write uploads agree with the GL backend, and
+// readPixels flips the rows to return them top-down as GL does.
+type vkTexture struct {
+	b                   *vkBackend
+	image, memory, view uintptr
+	format, aspect      uint32
+	w, h, samples, bpp  int
 }
+
+// vkFormat is the Vulkan format of f.
+func vkFormat(f TextureFormat) (uint32, bool) {
+	switch f {
+	case RGBA8Unorm:
+		return vkFormatRGBA8, true
+	case RGBA32Float:
+		return vkFormatRGBA32F, true
+	case Depth32Float:
+		return vkFormatD32, true
+	}
+	return 0, false
+}
+
+func (b *vkBackend) newTexture(format TextureFormat, w, h int, renderTarget bool, samples int) (bt backendTexture, err error) {
+	vf, ok := vkFormat(format)
+	if !ok {
+		return nil, fmt.Errorf("gpu/vk: unsupported texture format %d", format)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := &vkTexture{b: b, format: vf, aspect: vkAspectColor, w: w, h: h, samples: samples, bpp: 4}
+	attach := uint32(vkImageColorAttach)
+	switch format {
+	case Depth32Float:
+		t.aspect, attach = vkAspectDepth, vkImageDepthAttach
+	case RGBA32Float:
+		t.bpp = 16
+	}
+	// A multisampled image is only ever an attachment: it is read back
+	// through its resolve target. A float one is also a storage image, which
+	// a compute kernel loads by texel (gpumath.Image).
+	usage := uint32(vkUsageTransferSrc | vkUsageTransferDst | vkImageSampled)
+	switch {
+	case samples > 1:
+		usage = 0
+	case format == RGBA32Float:
+		usage |= vkImageStorage
+	}
+	if renderTarget {
+		usage |= attach
+	}
+	ici := vkImageCreateInfoB{
+		sType: vksImage, imageType: 1, format: vf, width: uint32(w), height: uint32(h), depth: 1,
+		mipLevels: 1, arrayLayers: 1, samples: uint32(samples), usage: usage, initialLayout: vkLayoutUndefined,
+	}
+	b.c("vkCreateImage", b.device, uintptr(unsafe.Pointer(&ici)), 0, uintptr(unsafe.Pointer(&t.image)))
+	var req vkMemoryRequirementsB
+	purego.SyscallN(b.fn["vkGetImageMemoryRequirements"], b.device, t.image, uintptr(unsafe.Pointer(&req)))
+	mai := vkMemoryAllocateInfoB{sType: vksMemAlloc, allocationSize: req.size, memoryTypeIdx: b.deviceMemType(req.memoryTypeBits)}
+	b.c("vkAllocateMemory", b.device, uintptr(unsafe.Pointer(&mai)), 0, uintptr(unsafe.Pointer(&t.memory)))
+	b.c("vkBindImageMemory", b.device, t.image, t.memory, 0)
+
+	rng := vkSubresourceRangeB{aspectMask: t.aspect, levelCount: 1, layerCount: 1}
+	ivci := vkImageViewCreateInfoB{sType: vksImageView, image: t.image, viewType: 1, format: vf, rng: rng}
+	b.c("vkCreateImageView", b.device, uintptr(unsafe.Pointer(&ivci)), 0, uintptr(unsafe.Pointer(&t.view)))
+
+	b.oneShot(func(cmd uintptr) {
+		ib := vkImageMemoryBarrierB{
+			sType: vksImageBarrier, dstAccess: vkAccessMemoryRead | vkAccessMemoryWrite,
+			oldLayout: vkLayoutUndefined, newLayout: vkLayoutGeneral,
+			srcQueue: vkQueueIgnored, dstQueue: vkQueueIgnored, image: t.image, rng: rng,
+		}
+		purego.SyscallN(b.fn["vkCmdPipelineBarrier"], cmd, vkPipeTopOfPipe, vkPipeAllCommands, 0, 0, 0, 0, 0, 1, uintptr(unsafe.Pointer(&ib)))
+	})
+	return t, nil
+}
+
+// readPixels returns the rows of the texture top-down, 4 bytes per pixel
+// (a float for a depth texture) or 16 for RGBA32Float. A multisampled
+// texture cannot be copied and reads back as nil.
+func (t *vkTexture) readPixels() []byte {
+	if t.samples > 1 {
+		return nil
+	}
+	b := t.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	row := t.w * t.bpp
+	buf := b.hostBuffer(row*t.h, vkUsageTransferDst)
+	defer buf.free()
+	b.oneShot(func(cmd uintptr) {
+		b.barrier(cmd)
+		region := vkBufferImageCopyB{aspectMask: t.aspect, layerCnt: 1, width: uint32(t.w), height: uint32(t.h), depth: 1}
+		purego.SyscallN(b.fn["vkCmdCopyImageToBuffer"], cmd, t.image, vkLayoutGeneral, buf.buffer, 1, uintptr(unsafe.Pointer(&region)))
+		mb := vkMemoryBarrierB{sType: vksMemBarrier, srcAccess: vkAccessMemoryWrite, dst: vkAccessHostRead}
+		purego.SyscallN(b.fn["vkCmdPipelineBarrier"], cmd, vkPipeAllCommands, vkPipeHost, 0, 1, uintptr(unsafe.Pointer(&mb)), 0, 0, 0, 0)
+	})
+	src := unsafe.Slice((*byte)(buf.ptr), row*t.h)
+	flipped := make([]byte, len(src))
+	for y := 0; y < t.h; y++ {
+		copy(flipped[y*row:(y+1)*row], src[(t.h-1-y)*row:(t.h-y)*row])
+	}
+	return flipped
+}
+
+// write uploads the rows of pixels through a staging buffer, in their
+// order, as glTexImage2D does.
+func (t *vkTexture) write(pixels []byte, bytesPerRow int) {
+	b := t.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	buf := b.hostBuffer(bytesPerRow*t.h, vkUsageTransferSrc)
+	defer buf.free()
+	copy(unsafe.Slice((*byte)(buf.ptr), buf.size), pixels)
+	b.oneShot(func(cmd uintptr) {
+		b.barrier(cmd)
+		region := vkBufferImageCopyB{
+			bufferRowLength: uint32(bytesPerRow / t.bpp), aspectMask: t.aspect, layerCnt: 1,
+			width: uint32(t.w), height: uint32(t.h), depth: 1,
+		}
+		purego.SyscallN(b.fn["vkCmdCopyBufferToImage"], cmd, buf.buffer, t.image, vkLayoutGeneral, 1, uintptr(unsafe.Pointer(&region)))
+	})
+}
+
 func (b *vkBackend) newSampler(desc SamplerDescriptor) backendSampler { return nil }
-func (b *vkBackend) newRenderPipeline(vmod backendShaderModule, ventry string, fmod backendShaderModule, fentry string, color TextureFormat, extraColor []TextureFormat, depth TextureFormat) (backendRenderPipeline, error) {
-	return nil, fmt.Errorf("gpu/vk: render pipelines not yet implemented")
+
+// --- render pipelines ---
+
+// vkRenderPipeline holds the shaders of a render pipeline. A Vulkan
+// pipeline also fixes the primitive topology, the render pass and the
+// descriptor-set layout, which are only known at draw time, so its
+// variants are built lazily at commit, like the compute pipeline, and
+// cached.
+type vkRenderPipeline struct {
+	b          *vkBackend
+	vmod, fmod uintptr
+	entry      []byte
+
+	layouts  map[int]vkLayout // by binding count
+	variants map[vkVariantKey]uintptr
 }
 
-func (c *vkCmd) beginRender(info renderPassInfo)               {}
-func (c *vkCmd) setRenderPipeline(backendRenderPipeline)       {}
-func (c *vkCmd) setRenderBuffer(backendBuffer, int, int)       {}
-func (c *vkCmd) setVertexBuffer(backendBuffer, int)            {}
-func (c *vkCmd) draw(prim Primitive, start, count int)         {}
-func (c *vkCmd) endRender()                                    {}
-func (c *vkCmd) setComputeTexture(index int, t backendTexture) {}
-func (c *vkCmd) setComputeSampler(index int, s backendSampler) {}
+type vkLayout struct{ dsl, layout uintptr }
+
+type vkVariantKey struct {
+	pass     uintptr
+	topology uint32
+	nbind    int
+}
+
+func (*vkRenderPipeline) isRenderPipeline() {}
+
+func (b *vkBackend) newRenderPipeline(vmod backendShaderModule, ventry string, fmod backendShaderModule, fentry string, color TextureFormat, extraColor []TextureFormat, depth TextureFormat, samples int) (backendRenderPipeline, error) {
+	vs, ok1 := vmod.(vkModule)
+	fs, ok2 := fmod.(vkModule)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("gpu/vk: render pipeline needs Vulkan shader modules")
+	}
+	for _, f := range append([]TextureFormat{color}, extraColor...) {
+		if _, ok := vkFormat(f); !ok || f == Depth32Float {
+			return nil, fmt.Errorf("gpu/vk: unsupported color format %d", f)
+		}
+	}
+	// As for compute, glslang names both entry points "main".
+	return &vkRenderPipeline{
+		b: b, vmod: vs.module, fmod: fs.module, entry: append([]byte("main"), 0),
+		layouts: map[int]vkLayout{}, variants: map[vkVariantKey]uintptr{},
+	}, nil
+}
+
+// layout returns the pipeline layout of a descriptor set of nbind storage
+// buffers, visible to both stages. The caller holds b.mu.
+func (p *vkRenderPipeline) layout(nbind int) vkLayout {
+	if l, ok := p.layouts[nbind]; ok {
+		return l
+	}
+	b := p.b
+	binds := make([]vkDSLBindingB, nbind+1) // +1 keeps &binds[0] valid
+	for i := 0; i < nbind; i++ {
+		binds[i] = vkDSLBindingB{binding: uint32(i), descriptorType: vkDescStorageBuffer, descriptorCount: 1, stageFlags: vkStageVertexB | vkStageFragmentB}
+	}
+	var l vkLayout
+	dslci := vkDSLCreateInfoB{sType: vksDSL, bindingCount: uint32(nbind), pBindings: uintptr(unsafe.Pointer(&binds[0]))}
+	b.c("vkCreateDescriptorSetLayout", b.device, uintptr(unsafe.Pointer(&dslci)), 0, uintptr(unsafe.Pointer(&l.dsl)))
+	plci := vkPipelineLayoutCreateInfoB{sType: vksPipeLayout, setLayoutCount: 1, pSetLayouts: uintptr(unsafe.Pointer(&l.dsl))}
+	b.c("vkCreatePipelineLayout", b.device, uintptr(unsafe.Pointer(&plci)), 0, uintptr(unsafe.Pointer(&l.layout)))
+	p.layouts[nbind] = l
+	return l
+}
+
+// variant returns the pipeline for a draw of topology with nbind bindings
+// in the render pass rp of attachments l. The caller holds b.mu.
+func (p *vkRenderPipeline) variant(rp uintptr, l *vkPassLayout, topology uint32, nbind int) uintptr {
+	key := vkVariantKey{pass: rp, topology: topology, nbind: nbind}
+	if pipe, ok := p.variants[key]; ok {
+		return pipe
+	}
+	b := p.b
+	stages := [2]vkShaderStageCreateInfoB{
+		{sType: vksShaderStage, stage: vkStageVertexB, module: p.vmod, pName: uintptr(unsafe.Pointer(&p.entry[0]))},
+		{sType: vksShaderStage, stage: vkStageFragmentB, module: p.fmod, pName: uintptr(unsafe.Pointer(&p.entry[0]))},
+	}
+	// Vertices come from storage buffers, so the vertex input is empty.
+	vi := vkVertexInputStateB{sType: vksVertexInput}
+	ia := vkInputAssemblyStateB{sType: vksInputAssembly, topology: topology}
+	vp := vkViewportStateB{sType: vksViewport, viewportCount: 1, scissorCount: 1}
+	// Vulkan measures the winding in a y-down framebuffer, so the clockwise
+	// front face is GL's counterclockwise one.
+	rs := vkRasterizationStateB{sType: vksRasterization, frontFace: vkFrontClockwise, lineWidth: 1}
+	ms := vkMultisampleStateB{sType: vksMultisample, samples: l.atts[0].samples}
+	// The standard 3D depth test of the GL backend: less, with writes.
+	ds := vkDepthStencilStateB{sType: vksDepthStencil, depthTest: 1, depthWrite: 1, depthCompare: vkCompareLess, maxDepthBounds: 1}
+	atts := make([]vkColorBlendAttachmentB, len(l.color))
+	for i := range atts {
+		atts[i].writeMask = vkColorWriteAll
+	}
+	cb := vkColorBlendStateB{sType: vksColorBlend, attachCount: uint32(len(atts)), pAttachments: uintptr(unsafe.Pointer(&atts[0]))}
+	dyn := [2]uint32{vkDynamicViewport, vkDynamicScissor}
+	dy := vkDynamicStateB{sType: vksDynamicState, stateCount: 2, pStates: uintptr(unsafe.Pointer(&dyn[0]))}
+	gpci := vkGraphicsPipelineCreateInfoB{
+		sType: vksGraphicsPipe, stageCount: 2, pStages: uintptr(unsafe.Pointer(&stages[0])),
+		pVertexInput: uintptr(unsafe.Pointer(&vi)), pInputAssembly: uintptr(unsafe.Pointer(&ia)),
+		pViewport: uintptr(unsafe.Pointer(&vp)), pRasterization: uintptr(unsafe.Pointer(&rs)),
+		pMultisample: uintptr(unsafe.Pointer(&ms)), pColorBlend: uintptr(unsafe.Pointer(&cb)),
+		pDynamic: uintptr(unsafe.Pointer(&dy)), layout: p.layout(nbind).layout, renderPass: rp, baseIndex: -1,
+	}
+	if l.depth != nil {
+		gpci.pDepthStencil = uintptr(unsafe.Pointer(&ds))
+	}
+	var pipe uintptr
+	b.c("vkCreateGraphicsPipelines", b.device, 0, 1, uintptr(unsafe.Pointer(&gpci)), 0, uintptr(unsafe.Pointer(&pipe)))
+	p.variants[key] = pipe
+	return pipe
+}
+
+// vkTopology is the Vulkan primitive topology of p.
+func vkTopology(p Primitive) uint32 {
+	switch p {
+	case TriangleStrip:
+		return 4
+	case LineList:
+		return 1
+	case PointList:
+		return 0
+	default:
+		return 3
+	}
+}
+
+// --- render passes ---
+
+// vkPassLayout is the attachments of a render pass in framebuffer order:
+// the colors, then their resolve targets, then the depth.
+type vkPassLayout struct {
+	atts    []vkAttachmentDescriptionB
+	views   []uintptr
+	clears  [][4]uint32 // VkClearValue
+	color   []vkAttachmentReferenceB
+	resolve []vkAttachmentReferenceB // nil without a resolve target
+	depth   *vkAttachmentReferenceB
+}
+
+type vkPassKey string
+
+// passLayout lays out the attachments of info. As on GL, the depth is
+// cleared at the start of every pass, and the colors only if info.load is
+// LoadClear. A multisampled color is resolved by the subpass into its
+// resolve target.
+func passLayout(info renderPassInfo) *vkPassLayout {
+	l := &vkPassLayout{}
+	add := func(t *vkTexture, load uint32, clear [4]uint32) vkAttachmentReferenceB {
+		l.atts = append(l.atts, vkAttachmentDescriptionB{
+			format: t.format, samples: uint32(t.samples), loadOp: load, storeOp: vkStoreOpStore,
+			stencilLoad: vkLoadOpDontCare, stencilStore: vkStoreOpDontCare,
+			initialLayout: vkLayoutGeneral, finalLayout: vkLayoutGeneral,
+		})
+		l.views = append(l.views, t.view)
+		l.clears = append(l.clears, clear)
+		return vkAttachmentReferenceB{attachment: uint32(len(l.atts) - 1), layout: vkLayoutGeneral}
+	}
+	load := uint32(vkLoadOpLoad)
+	if info.load == LoadClear {
+		load = vkLoadOpClear
+	}
+	colors := append([]renderColorTarget{{tex: info.color, clear: info.clearColor, resolve: info.resolve}}, info.extraColor...)
+	for _, ct := range colors {
+		var cv [4]uint32
+		for i, v := range ct.clear {
+			cv[i] = math.Float32bits(float32(v))
+		}
+		l.color = append(l.color, add(ct.tex.(*vkTexture), load, cv))
+	}
+	for i, ct := range colors {
+		if ct.resolve == nil {
+			continue
+		}
+		if l.resolve == nil {
+			l.resolve = make([]vkAttachmentReferenceB, len(colors))
+			for j := range l.resolve {
+				l.resolve[j].attachment = vkQueueIgnored // VK_ATTACHMENT_UNUSED
+			}
+		}
+		l.resolve[i] = add(ct.resolve.(*vkTexture), vkLoadOpDontCare, [4]uint32{})
+	}
+	if info.depth != nil {
+		d := add(info.depth.(*vkTexture), vkLoadOpClear, [4]uint32{math.Float32bits(float32(info.clearDepth))})
+		l.depth = &d
+	}
+	return l
+}
+
+// renderPass returns the render pass of the attachments l, created once
+// per layout. The caller holds b.mu.
+func (b *vkBackend) renderPass(l *vkPassLayout) uintptr {
+	key := vkPassKey(fmt.Sprint(l.atts, l.color, l.resolve, l.depth != nil))
+	if rp, ok := b.passes[key]; ok {
+		return rp
+	}
+	sub := vkSubpassDescriptionB{bindPoint: vkBindGraphics, colorCount: uint32(len(l.color)), pColor: uintptr(unsafe.Pointer(&l.color[0]))}
+	if l.resolve != nil {
+		sub.pResolve = uintptr(unsafe.Pointer(&l.resolve[0]))
+	}
+	if l.depth != nil {
+		sub.pDepth = uintptr(unsafe.Pointer(l.depth))
+	}
+	rpci := vkRenderPassCreateInfoB{
+		sType: vksRenderPass, attachmentCount: uint32(len(l.atts)), pAttachments: uintptr(unsafe.Pointer(&l.atts[0])),
+		subpassCount: 1, pSubpasses: uintptr(unsafe.Pointer(&sub)),
+	}
+	var rp uintptr
+	b.c("vkCreateRenderPass", b.device, uintptr(unsafe.Pointer(&rpci)), 0, uintptr(unsafe.Pointer(&rp)))
+	b.passes[key] = rp
+	return rp
+}
+
+// recordPass records the render pass p into cmd and returns its
+// framebuffer, which the caller destroys once cmd completed.
+func (b *vkBackend) recordPass(cmd, pool uintptr, p *vkPass) uintptr {
+	l := passLayout(p.info)
+	rp := b.renderPass(l)
+	color := p.info.color.(*vkTexture)
+	w, h := uint32(color.w), uint32(color.h)
+
+	fbci := vkFramebufferCreateInfoB{
+		sType: vksFramebuffer, renderPass: rp, attachmentCount: uint32(len(l.views)),
+		pAttachments: uintptr(unsafe.Pointer(&l.views[0])), width: w, height: h, layers: 1,
+	}
+	var fb uintptr
+	b.c("vkCreateFramebuffer", b.device, uintptr(unsafe.Pointer(&fbci)), 0, uintptr(unsafe.Pointer(&fb)))
+
+	rbi := vkRenderPassBeginInfoB{
+		sType: vksRenderBegin, renderPass: rp, framebuffer: fb, width: w, height: h,
+		clearValueCount: uint32(len(l.clears)), pClearValues: uintptr(unsafe.Pointer(&l.clears[0])),
+	}
+	purego.SyscallN(b.fn["vkCmdBeginRenderPass"], cmd, uintptr(unsafe.Pointer(&rbi)), 0)
+	viewport := vkViewportB{width: float32(w), height: float32(h), maxDepth: 1}
+	scissor := vkRect2DB{width: w, height: h}
+	for _, d := range p.draws {
+		nbind := bindCount(d.binds)
+		purego.SyscallN(b.fn["vkCmdBindPipeline"], cmd, vkBindGraphics, d.pipe.variant(rp, l, d.topology, nbind))
+		purego.SyscallN(b.fn["vkCmdSetViewport"], cmd, 0, 1, uintptr(unsafe.Pointer(&viewport)))
+		purego.SyscallN(b.fn["vkCmdSetScissor"], cmd, 0, 1, uintptr(unsafe.Pointer(&scissor)))
+		layout := d.pipe.layout(nbind)
+		set := b.descriptorSet(pool, layout.dsl, d.binds)
+		purego.SyscallN(b.fn["vkCmdBindDescriptorSets"], cmd, vkBindGraphics, layout.layout, 0, 1, uintptr(unsafe.Pointer(&set)), 0, 0)
+		purego.SyscallN(b.fn["vkCmdDraw"], cmd, uintptr(d.count), 1, uintptr(d.start), 0)
+	}
+	purego.SyscallN(b.fn["vkCmdEndRenderPass"], cmd)
+	return fb
+}
@@ -21,13 +21,21 @@ import (
 )
 
 func glslToSPIRV(t *testing.T, src string) []byte {
+	t.Helper()
+	return glslStageToSPIRV(t, "comp", src)
+}
+
+// glslStageToSPIRV compiles the GLSL of a shader stage to SPIR-V, where
+// stage is the file extension by which glslang tells the stage: "comp",
+// "vert" or "frag".
+func glslStageToSPIRV(t *testing.T, stage, src string) []byte {
 	t.Helper()
 	glslang, err := exec.LookPath("glslangValidator")
 	if err != nil {
 		t.Skipf("glslangValidator not found: %v", err)
 	}
 	dir := t.TempDir()
-	comp := filepath.Join(dir, "k.comp")
+	comp := filepath.Join(dir, "k."+stage)
 	spv := filepath.Join(dir, "k.spv")
 	if err := os.WriteFile(comp, []byte(src), 0o644); err != nil {
 		t.Fatal(err)
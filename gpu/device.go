@@ -49,6 +49,7 @@ type Option func(*config)
 type config struct {
 	driver        Driver
 	nativeDisplay uintptr
+	headless      bool
 }
 
 // WithDriver forces a specific driver instead of auto-selection.
@@ -65,6 +66,15 @@ func WithNativeDisplay(d uintptr) Option {
 	return func(c *config) { c.nativeDisplay = d }
 }
 
+// WithHeadless asks for a device without any display, for compute and
+// offscreen rendering only. The GL backend then opens the surfaceless EGL
+// platform (EGL_MESA_platform_surfaceless) itself, as EGL_PLATFORM=surfaceless
+// does, so a headless machine with Mesa needs no environment setup. It has no
+// effect with WithNativeDisplay or on the other backends.
+func WithHeadless() Option {
+	return func(c *config) { c.headless = true }
+}
+
 // Device is the root object: the factory for GPU resources and the owner of the
 // command queue. Obtain one with Open.
 type Device struct {
@@ -0,0 +1,91 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin
+
+// Multisampling conformance for the Metal render pipeline: a white triangle
+// whose hypotenuse crosses the target diagonally is drawn into a 4x
+// multisampled color attachment and resolved into a single-sampled texture.
+// The pixels on the edge must resolve to the fraction of their covered
+// samples, strictly between black and white.
+package gpu_test
+
+import (
+	"testing"
+
+	"poly.red/gpu"
+)
+
+func TestRenderMultisampleResolve(t *testing.T) {
+	dev, err := gpu.Open()
+	if err != nil {
+		t.Skipf("no GPU device: %v", err)
+	}
+	defer dev.Close()
+
+	if _, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: 4, Height: 4, RenderTarget: true, SampleCount: 3}); err == nil {
+		t.Fatal("a sample count of 3 was accepted")
+	}
+
+	mod, err := dev.NewShaderModule(gpu.ShaderSource{MSL: depthMSL})
+	if err != nil {
+		t.Fatalf("compile shader: %v", err)
+	}
+	pipe, err := dev.NewRenderPipeline(gpu.RenderPipelineDescriptor{
+		VertexModule: mod, VertexEntry: "vmain",
+		FragmentModule: mod, FragmentEntry: "fmain",
+		ColorFormat: gpu.RGBA8Unorm,
+		SampleCount: 4,
+	})
+	if err != nil {
+		t.Fatalf("render pipeline: %v", err)
+	}
+
+	const W, H = 16, 16
+	ms, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: W, Height: H, RenderTarget: true, SampleCount: 4})
+	if err != nil {
+		t.Fatalf("multisampled texture: %v", err)
+	}
+	resolved, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: W, Height: H, RenderTarget: true})
+	if err != nil {
+		t.Fatalf("resolve texture: %v", err)
+	}
+	buf := func(d []float32) *gpu.Buffer {
+		b, err := dev.NewBuffer(gpu.BufferDescriptor{Size: len(d) * 4, Usage: gpu.BufferStorage, Data: bytesFromFloats(d)})
+		if err != nil {
+			t.Fatalf("buffer: %v", err)
+		}
+		return b
+	}
+	pos := buf([]float32{-1, -1, 0.5, 1, -1, 0.5, -1, 1, 0.5})
+	col := buf([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+	enc := dev.NewCommandEncoder()
+	rp := enc.BeginRenderPass(gpu.RenderPassDescriptor{
+		ColorTexture: ms, ResolveTexture: resolved,
+		Load: gpu.LoadClear, ClearColor: [4]float64{0, 0, 0, 1},
+	})
+	rp.SetPipeline(pipe)
+	rp.SetVertexBuffer(0, pos)
+	rp.SetVertexBuffer(1, col)
+	rp.Draw(gpu.TriangleList, 0, 3)
+	rp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	pix := resolved.ReadPixels()
+	at := func(x, y int) byte { return pix[(y*W+x)*4] }
+	if in, out := at(1, H-2), at(W-2, 1); in != 255 || out != 0 {
+		t.Fatalf("interior %d, exterior %d: want 255 and 0", in, out)
+	}
+	partial := 0
+	for x := 0; x < W; x++ {
+		if v := at(x, x); v > 0 && v < 255 {
+			partial++
+		}
+	}
+	if partial == 0 {
+		t.Fatal("no pixel on the edge resolved to a partial coverage")
+	}
+}
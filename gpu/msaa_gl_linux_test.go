@@ -0,0 +1,99 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// Multisampling conformance for the GL backend: a white triangle whose
+// hypotenuse crosses the target diagonally is drawn into a 4x multisampled
+// color attachment and resolved into a single-sampled texture. The pixels on
+// the edge must resolve to the fraction of their covered samples, strictly
+// between black and white, while the interior and the exterior stay solid.
+package gpu_test
+
+import (
+	"os"
+	"testing"
+
+	"poly.red/gpu"
+)
+
+func TestGLRenderMultisampleResolve(t *testing.T) {
+	if os.Getenv("EGL_PLATFORM") != "surfaceless" {
+		t.Skip("set EGL_PLATFORM=surfaceless to run the GL multisample test")
+	}
+	dev, err := gpu.Open(gpu.WithDriver(gpu.DriverGL))
+	if err != nil {
+		t.Skipf("no GL device: %v", err)
+	}
+	defer dev.Close()
+
+	if _, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: 4, Height: 4, RenderTarget: true, SampleCount: 3}); err == nil {
+		t.Fatal("a sample count of 3 was accepted")
+	}
+
+	vmod, err := dev.NewShaderModule(gpu.ShaderSource{GLSL: depthGLVert})
+	if err != nil {
+		t.Fatalf("vertex module: %v", err)
+	}
+	fmod, err := dev.NewShaderModule(gpu.ShaderSource{GLSL: depthGLFrag})
+	if err != nil {
+		t.Fatalf("fragment module: %v", err)
+	}
+	pipe, err := dev.NewRenderPipeline(gpu.RenderPipelineDescriptor{
+		VertexModule: vmod, VertexEntry: "main",
+		FragmentModule: fmod, FragmentEntry: "main",
+		ColorFormat: gpu.RGBA8Unorm,
+		SampleCount: 4,
+	})
+	if err != nil {
+		t.Fatalf("render pipeline: %v", err)
+	}
+
+	const W, H = 16, 16
+	ms, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: W, Height: H, RenderTarget: true, SampleCount: 4})
+	if err != nil {
+		t.Fatalf("multisampled texture: %v", err)
+	}
+	resolved, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: W, Height: H, RenderTarget: true})
+	if err != nil {
+		t.Fatalf("resolve texture: %v", err)
+	}
+	pos, err := dev.NewBuffer(gpu.BufferDescriptor{Data: glBytesOf([]float32{-1, -1, 0, 1, -1, 0, -1, 1, 0}), Usage: gpu.BufferStorage})
+	if err != nil {
+		t.Fatalf("buffer: %v", err)
+	}
+	col, err := dev.NewBuffer(gpu.BufferDescriptor{Data: glBytesOf([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1}), Usage: gpu.BufferStorage})
+	if err != nil {
+		t.Fatalf("buffer: %v", err)
+	}
+
+	enc := dev.NewCommandEncoder()
+	rp := enc.BeginRenderPass(gpu.RenderPassDescriptor{
+		ColorTexture: ms, ResolveTexture: resolved,
+		Load: gpu.LoadClear, ClearColor: [4]float64{0, 0, 0, 1},
+	})
+	rp.SetPipeline(pipe)
+	rp.SetVertexBuffer(0, pos)
+	rp.SetVertexBuffer(1, col)
+	rp.Draw(gpu.TriangleList, 0, 3)
+	rp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	// The readback is top-down: the triangle covers the lower left.
+	pix := resolved.ReadPixels()
+	at := func(x, y int) byte { return pix[(y*W+x)*4] }
+	if in, out := at(1, H-2), at(W-2, 1); in != 255 || out != 0 {
+		t.Fatalf("interior %d, exterior %d: want 255 and 0", in, out)
+	}
+	partial := 0
+	for x := 0; x < W; x++ {
+		if v := at(x, x); v > 0 && v < 255 {
+			partial++
+		}
+	}
+	if partial == 0 {
+		t.Fatal("no pixel on the edge resolved to a partial coverage")
+	}
+}
@@ -85,6 +85,8 @@ var (
 	selSetWidth              = objc.RegisterName("setWidth:")
 	selSetHeight             = objc.RegisterName("setHeight:")
 	selSetStorageMode        = objc.RegisterName("setStorageMode:")
+	selSetTextureType        = objc.RegisterName("setTextureType:")
+	selSetSampleCount        = objc.RegisterName("setSampleCount:")
 	selTexWidth              = objc.RegisterName("width")
 	selTexHeight             = objc.RegisterName("height")
 	selReplaceRegion         = objc.RegisterName("replaceRegion:mipmapLevel:withBytes:bytesPerRow:")
@@ -215,6 +217,10 @@ func (d Device) MakeTexture(td TextureDescriptor) Texture {
 	if td.Usage != 0 {
 		desc.Send(selSetUsage, uint64(td.Usage))
 	}
+	if td.SampleCount > 1 {
+		desc.Send(selSetTextureType, uint64(TextureType2DMultisample))
+		desc.Send(selSetSampleCount, uint64(td.SampleCount))
+	}
 	texture := d.device.Send(selNewTextureWithDesc, desc)
 	desc.Send(selRelease)
 	return Texture{
@@ -266,6 +272,15 @@ const (
 	PixelFormatDepth32Float   PixelFormat = 252 // A pixel format with one 32-bit floating-point component, used for a depth render target.
 )
 
+// TextureType defines the dimension of a texture.
+// https://developer.apple.com/documentation/metal/mtltexturetype.
+type TextureType uint8
+
+const (
+	TextureType2D            TextureType = 2
+	TextureType2DMultisample TextureType = 4
+)
+
 // TextureDescriptor configures new Texture objects.
 // https://developer.apple.com/documentation/metal/mtltexturedescriptor.
 type TextureDescriptor struct {
@@ -274,6 +289,9 @@ type TextureDescriptor struct {
 	Height      int
 	StorageMode StorageMode
 	Usage       TextureUsage
+	// SampleCount is the number of samples per pixel. A count greater than
+	// 1 creates a TextureType2DMultisample texture.
+	SampleCount int
 }
 
 // Texture is a memory allocation for storing formatted
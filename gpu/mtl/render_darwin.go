@@ -26,6 +26,8 @@ var (
 	selSetTexture          = objc.RegisterName("setTexture:")
 	selSetLoadAction       = objc.RegisterName("setLoadAction:")
 	selSetStoreAction      = objc.RegisterName("setStoreAction:")
+	selSetResolveTexture   = objc.RegisterName("setResolveTexture:")
+	selSetRasterSamples    = objc.RegisterName("setRasterSampleCount:")
 	selSetClearColor       = objc.RegisterName("setClearColor:")
 	selRenderEncoder       = objc.RegisterName("renderCommandEncoderWithDescriptor:")
 	selSetRenderPipeline   = objc.RegisterName("setRenderPipelineState:")
@@ -133,8 +135,9 @@ const (
 type StoreAction uint8
 
 const (
-	StoreActionDontCare StoreAction = 0
-	StoreActionStore    StoreAction = 1
+	StoreActionDontCare           StoreAction = 0
+	StoreActionStore              StoreAction = 1
+	StoreActionMultisampleResolve StoreAction = 2
 )
 
 // PrimitiveType is the geometry primitive a draw call assembles.
@@ -205,6 +208,9 @@ type RenderPipelineDescriptor struct {
 	ExtraColorPixelFormats []PixelFormat
 	// DepthPixelFormat is the depth attachment format, or 0 (Invalid) for none.
 	DepthPixelFormat PixelFormat
+	// SampleCount is the number of samples per pixel of the attachments, 0
+	// or 1 for single-sampled attachments.
+	SampleCount int
 }
 
 // MakeRenderPipelineState creates a render pipeline state object.
@@ -221,6 +227,9 @@ func (d Device) MakeRenderPipelineState(desc RenderPipelineDescriptor) (RenderPi
 		a.Send(selSetPixelFormat, uint64(f))
 	}
 	rpd.Send(selSetDepthAttachPixFmt, uint64(desc.DepthPixelFormat))
+	if desc.SampleCount > 1 {
+		rpd.Send(selSetRasterSamples, uint64(desc.SampleCount))
+	}
 
 	var err objc.ID
 	pso := d.device.Send(selNewRenderPipeline, rpd, unsafe.Pointer(&err))
@@ -236,6 +245,9 @@ type ColorAttachment struct {
 	LoadAction  LoadAction
 	StoreAction StoreAction
 	ClearColor  ClearColor
+	// ResolveTexture receives the resolved samples of a multisampled
+	// Texture with the StoreActionMultisampleResolve store action.
+	ResolveTexture Texture
 }
 
 // DepthAttachment configures a render-pass depth attachment.
@@ -266,6 +278,9 @@ func (rp RenderPassDescriptor) objc() objc.ID {
 		att.Send(selSetLoadAction, uint64(c.LoadAction))
 		att.Send(selSetStoreAction, uint64(c.StoreAction))
 		att.Send(selSetClearColor, mtlClearColor{c.ClearColor.Red, c.ClearColor.Green, c.ClearColor.Blue, c.ClearColor.Alpha})
+		if c.ResolveTexture.texture != 0 {
+			att.Send(selSetResolveTexture, c.ResolveTexture.texture)
+		}
 	}
 	setColor(0, rp.ColorAttachment0)
 	for i, c := range rp.ExtraColorAttachments {
@@ -8,7 +8,6 @@ package gpu_test
 
 import (
 	"os"
-	"strings"
 	"testing"
 
 	"poly.red/gpu"
@@ -40,8 +39,7 @@ func TestShadingParityGL(t *testing.T) {
 }
 
 // TestShadingParityVulkan runs the shared cross-backend shading parity on the
-// Vulkan backend (Go kernel -> GLSL -> SPIR-V via glslang). Runs in the vk-probe
-// CI job.
+// Vulkan backend (Go kernel -> SPIR-V). Runs in the vk-probe CI job.
 func TestShadingParityVulkan(t *testing.T) {
 	if os.Getenv("POLYRED_VK_PROBE") != "1" {
 		t.Skip("set POLYRED_VK_PROBE=1 to run the Vulkan shading parity")
@@ -52,16 +50,11 @@ func TestShadingParityVulkan(t *testing.T) {
 	}
 	defer dev.Close()
 	runParity(t, dev, func(goSrc, entry string) (*gpu.ShaderModule, []shader.Binding, error) {
-		ks, err := shader.CompileGLSL(goSrc)
+		ks, err := shader.CompileSPIRV(goSrc)
 		if err != nil {
 			return nil, nil, err
 		}
-		// CompileGLSL targets GLES (#version 310 es) for the GL backend; glslang
-		// compiles desktop/Vulkan GLSL (#version 450) for SPIR-V. The body is
-		// identical, so retarget the version header for the Vulkan path.
-		glsl := strings.Replace(ks[entry].GLSL, "#version 310 es", "#version 450", 1)
-		spv := glslToSPIRV(t, glsl)
-		mod, err := dev.NewShaderModule(gpu.ShaderSource{SPIRV: spv})
+		mod, err := dev.NewShaderModule(gpu.ShaderSource{SPIRV: ks[entry].SPIRV})
 		if err != nil {
 			return nil, nil, err
 		}
@@ -332,16 +332,24 @@ func runAuthorOnceParity(t *testing.T, dev *gpu.Device, mk mkFunc) {
 	sc := makeParityScene(n)
 	ref := cpuShade(sc)
 
+	// The author-once kernel takes 16-float lights and 10-float materials (the
+	// trailing type 0 is Blinn-Phong), and a per-fragment surface that only
+	// metallic-roughness materials read.
+	lights := widen(sc.lights, 10, 16)
+	materials := widen(sc.materials, 9, 10)
+	surface := make([]float32, n*8)
+	env := []float32{0, 0, 0, 0}
+
 	// Run the author-once kernel as ordinary Go on the CPU.
 	cpuGo := make([]float32, n*4)
 	for i := 0; i < n; i++ {
-		kernels.Shade(uint(i), sc.normals, sc.worldpos, sc.basecol, sc.lights, sc.matidx, sc.materials, sc.scene, cpuGo)
+		kernels.Shade(uint(i), sc.normals, sc.worldpos, sc.basecol, lights, sc.matidx, materials, surface, sc.scene, env, cpuGo)
 	}
 	// Compile the *same source* and run it on the GPU.
 	inputs := map[string][]float32{
 		"normals": sc.normals, "worldpos": sc.worldpos, "basecol": sc.basecol,
-		"lights": sc.lights, "matidx": sc.matidx, "materials": sc.materials,
-		"scene": sc.scene, "out": make([]float32, n*4),
+		"lights": lights, "matidx": sc.matidx, "materials": materials,
+		"surface": surface, "scene": sc.scene, "env": env, "out": make([]float32, n*4),
 	}
 	gpuOut := runCompute(t, dev, mk, kernels.ShadeSrc, "Shade", inputs, "out", n)
 
@@ -350,6 +358,16 @@ func runAuthorOnceParity(t *testing.T, dev *gpu.Device, mk mkFunc) {
 	compareParity(t, dev, "author-once GPU vs CPU-as-Go", gpuOut, cpuGo, 0.05)
 }
 
+// widen pads each record of from floats in b with zeros to to floats.
+func widen(b []float32, from, to int) []float32 {
+	var w []float32
+	for i := 0; i+from <= len(b); i += from {
+		w = append(w, b[i:i+from]...)
+		w = append(w, make([]float32, to-from)...)
+	}
+	return w
+}
+
 // runShadingParity: the Blinn-Phong deferred shading scene.
 func runShadingParity(t *testing.T, dev *gpu.Device, mk mkFunc) {
 	t.Helper()
@@ -29,13 +29,21 @@ type TextureDescriptor struct {
 	Width        int
 	Height       int
 	RenderTarget bool // usable as a render-pass color attachment
+	// SampleCount is the number of samples per pixel of a multisampled render
+	// target, 1 (or 0) for a single-sampled texture. A multisampled texture
+	// is an attachment only: a render pass resolves it into a single-sampled
+	// texture (see RenderPassDescriptor.ResolveTexture), which is the one to
+	// read back or sample.
+	SampleCount int
 }
 
 // Texture is a GPU image, usable as a render target and/or sampled resource.
 type Texture struct {
-	b backendTexture
-	w int
-	h int
+	b       backendTexture
+	format  TextureFormat
+	w       int
+	h       int
+	samples int
 }
 
 // Width returns the texture width in pixels.
@@ -44,13 +52,22 @@ func (t *Texture) Width() int { return t.w }
 // Height returns the texture height in pixels.
 func (t *Texture) Height() int { return t.h }
 
+// SampleCount returns the number of samples per pixel of the texture.
+func (t *Texture) SampleCount() int { return t.samples }
+
 // ReadPixels copies the texture's pixels back to CPU memory (tightly packed,
 // 4 bytes/pixel for RGBA8Unorm). Used for headless render-to-image.
 func (t *Texture) ReadPixels() []byte { return t.b.readPixels() }
 
-// Write uploads tightly-packed pixel data (4 bytes/pixel for RGBA8Unorm) into
-// the texture.
-func (t *Texture) Write(pixels []byte) { t.b.write(pixels, t.w*4) }
+// Write uploads tightly-packed pixel data (4 bytes/pixel for RGBA8Unorm, 16
+// for RGBA32Float) into the texture.
+func (t *Texture) Write(pixels []byte) {
+	bpp := 4
+	if t.format == RGBA32Float {
+		bpp = 16
+	}
+	t.b.write(pixels, t.w*bpp)
+}
 
 // FilterMode selects texture filtering.
 type FilterMode int
@@ -86,11 +103,18 @@ func (d *Device) NewSampler(desc SamplerDescriptor) *Sampler {
 	return &Sampler{b: d.b.newSampler(desc)}
 }
 
-// SetTexture binds a texture for sampling at the given texture index.
+// SetTexture binds a texture for sampling at the given texture index. On
+// the GL backend the index is the image unit, and the texture must be an
+// RGBA32Float texture, which the shader loads read-only by imageLoad.
 func (p *ComputePass) SetTexture(index int, t *Texture) {
 	p.e.cmd.setComputeTexture(index, t.b)
 }
 
+// StorageTextures reports whether compute shaders can load RGBA32Float
+// textures by texel, by ComputePass.SetTexture, so that a compute pass may
+// consume the targets of a render pass without reading them back to the CPU.
+func (d *Device) StorageTextures() bool { return d.b.storageTextures() }
+
 // SetSampler binds a sampler at the given sampler index.
 func (p *ComputePass) SetSampler(index int, s *Sampler) {
 	p.e.cmd.setComputeSampler(index, s.b)
@@ -101,11 +125,30 @@ func (d *Device) NewTexture(desc TextureDescriptor) (*Texture, error) {
 	if desc.Width <= 0 || desc.Height <= 0 {
 		return nil, errors.New("gpu: texture dimensions must be > 0")
 	}
-	bt, err := d.b.newTexture(desc.Format, desc.Width, desc.Height, desc.RenderTarget)
+	samples, err := sampleCount(desc.SampleCount)
+	if err != nil {
+		return nil, err
+	}
+	if samples > 1 && !desc.RenderTarget {
+		return nil, errors.New("gpu: a multisampled texture must be a render target")
+	}
+	bt, err := d.b.newTexture(desc.Format, desc.Width, desc.Height, desc.RenderTarget, samples)
 	if err != nil {
 		return nil, err
 	}
-	return &Texture{b: bt, w: desc.Width, h: desc.Height}, nil
+	return &Texture{b: bt, format: desc.Format, w: desc.Width, h: desc.Height, samples: samples}, nil
+}
+
+// sampleCount validates the sample count of a texture or a render pipeline,
+// where zero means single-sampled.
+func sampleCount(n int) (int, error) {
+	switch n {
+	case 0, 1:
+		return 1, nil
+	case 2, 4, 8:
+		return n, nil
+	}
+	return 0, errors.New("gpu: sample count must be 1, 2, 4 or 8")
 }
 
 // RenderPipelineDescriptor describes a render pipeline. The vertex and fragment
@@ -124,6 +167,11 @@ type RenderPipelineDescriptor struct {
 	// DepthFormat is the depth attachment format (FormatNone for no depth test).
 	// When set, the pipeline depth-tests with "less" and writes depth.
 	DepthFormat TextureFormat
+	// SampleCount is the number of samples per pixel of the attachments the
+	// pipeline renders to, 1 (or 0) for single-sampled attachments. The
+	// fragment stage runs once per pixel and its outputs are stored to the
+	// samples the primitive covers.
+	SampleCount int
 }
 
 // RenderPipeline is a compiled render pipeline.
@@ -136,7 +184,11 @@ func (d *Device) NewRenderPipeline(desc RenderPipelineDescriptor) (*RenderPipeli
 	if desc.VertexModule == nil || desc.FragmentModule == nil {
 		return nil, errors.New("gpu: render pipeline requires vertex and fragment modules")
 	}
-	bp, err := d.b.newRenderPipeline(desc.VertexModule.b, desc.VertexEntry, desc.FragmentModule.b, desc.FragmentEntry, desc.ColorFormat, desc.ExtraColorFormats, desc.DepthFormat)
+	samples, err := sampleCount(desc.SampleCount)
+	if err != nil {
+		return nil, err
+	}
+	bp, err := d.b.newRenderPipeline(desc.VertexModule.b, desc.VertexEntry, desc.FragmentModule.b, desc.FragmentEntry, desc.ColorFormat, desc.ExtraColorFormats, desc.DepthFormat, samples)
 	if err != nil {
 		return nil, err
 	}
@@ -169,6 +221,10 @@ type RenderPassDescriptor struct {
 	ColorTexture *Texture
 	Load         LoadOp
 	ClearColor   [4]float64 // RGBA, used when Load == LoadClear
+	// ResolveTexture, if set, receives the average of the samples of the
+	// multisampled ColorTexture at the end of the pass. It is a
+	// single-sampled texture of the same size and format.
+	ResolveTexture *Texture
 	// ExtraColorTargets are color attachments 1..N (attachment 0 is ColorTexture).
 	// Each is cleared to its ClearColor when Load == LoadClear. Used for a G-buffer.
 	ExtraColorTargets []ColorTarget
@@ -182,6 +238,9 @@ type RenderPassDescriptor struct {
 type ColorTarget struct {
 	Texture    *Texture
 	ClearColor [4]float64
+	// Resolve, if set, receives the resolved samples of a multisampled
+	// Texture at the end of the pass, see RenderPassDescriptor.ResolveTexture.
+	Resolve *Texture
 }
 
 // RenderPass encodes draw commands.
@@ -196,8 +255,15 @@ func (e *CommandEncoder) BeginRenderPass(desc RenderPassDescriptor) *RenderPass
 		load:       desc.Load,
 		clearColor: desc.ClearColor,
 	}
+	if desc.ResolveTexture != nil {
+		info.resolve = desc.ResolveTexture.b
+	}
 	for _, t := range desc.ExtraColorTargets {
-		info.extraColor = append(info.extraColor, renderColorTarget{tex: t.Texture.b, clear: t.ClearColor})
+		ct := renderColorTarget{tex: t.Texture.b, clear: t.ClearColor}
+		if t.Resolve != nil {
+			ct.resolve = t.Resolve.b
+		}
+		info.extraColor = append(info.extraColor, ct)
 	}
 	if desc.DepthTexture != nil {
 		info.depth = desc.DepthTexture.b
@@ -41,6 +41,7 @@ const (
 	UniformBuffer
 	SampledTexture
 	SamplerBinding
+	StorageTexture // a read-only gpumath.Image, loaded by texel
 )
 
 // Binding describes one kernel parameter's GPU binding.
@@ -51,15 +52,17 @@ type Binding struct {
 }
 
 // Kernel is a compiled kernel (compute, vertex, or fragment). MSL is set by
-// Compile; GLSL is set by CompileGLSL. Bindings are per-target: the GLSL compute
-// emitter numbers storage buffers (SSBO) and uniform blocks (UBO) in separate
-// binding spaces, matching how a GL backend binds them.
+// Compile; GLSL is set by CompileGLSL; SPIRV is set by CompileSPIRV. Bindings
+// are per-target: the GLSL compute emitter numbers storage buffers (SSBO) and
+// uniform blocks (UBO) in separate binding spaces, matching how a GL backend
+// binds them.
 type Kernel struct {
 	Name     string
 	Stage    Stage
 	Bindings []Binding
 	MSL      string
 	GLSL     string
+	SPIRV    []byte
 }
 
 // builtins maps allowed Go call targets to their MSL spelling.
@@ -82,6 +85,52 @@ var builtins = map[string]string{
 	"Floor": "floor", "Ceil": "ceil", "Round": "round", "Fract": "fract",
 	"Trunc": "trunc", "Log2": "log2",
 	"Clampf": "clamp", "Minf": "min", "Maxf": "max", "Absf": "abs",
+	// fragment stage derivatives
+	"Dfdx": "dfdx", "Dfdy": "dfdy",
+}
+
+// glslBuiltins are the builtins GLSL spells differently from MSL.
+var glslBuiltins = map[string]string{"dfdx": "dFdx", "dfdy": "dFdy"}
+
+// fragmentOnly are the gpumath functions that read the rasterizer, so only a
+// fragment kernel may call them.
+var fragmentOnly = map[string]bool{"FrontFacing": true, "Discard": true, "Dfdx": true, "Dfdy": true}
+
+// checkStageCalls rejects calls to the fragment-only functions outside of a
+// fragment kernel.
+func checkStageCalls(fn *ast.FuncDecl, stage Stage) error {
+	if stage == StageFragment {
+		return nil
+	}
+	var err error
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && fragmentOnly[id.Name] && err == nil {
+				err = fmt.Errorf("%s is only available in a fragment kernel", id.Name)
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// frontFacingParam is the MSL parameter of a fragment kernel that calls
+// FrontFacing.
+const frontFacingParam = "gpu_front"
+
+// usesFrontFacing reports whether fn calls FrontFacing, so that its MSL takes
+// the front facing flag of the fragment.
+func usesFrontFacing(fn *ast.FuncDecl) bool {
+	used := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "FrontFacing" {
+				used = true
+			}
+		}
+		return !used
+	})
+	return used
 }
 
 // gpumath constructors map to a canonical (MSL-spelled) vector/matrix type; the
@@ -214,43 +263,59 @@ func Compile(src string) (map[string]*Kernel, error) {
 	return compileAll(src, false)
 }
 
-// CompileGLSL is like Compile but emits GLSL ES 3.10 compute source (Kernel.GLSL)
-// for the OpenGL ES backend. It supports compute kernels with []float32 storage
-// buffers and struct-by-value uniforms; vertex/fragment and texture/sampler
-// kernels are not yet supported and return an error.
+// CompileGLSL is like Compile but emits GLSL ES 3.10 source (Kernel.GLSL) for
+// the OpenGL ES backend. It supports compute, vertex and fragment kernels with
+// []float32 storage buffers, struct-by-value uniforms and images; texture and
+// sampler parameters are not yet supported and return an error.
 func CompileGLSL(src string) (map[string]*Kernel, error) {
 	return compileAll(src, true)
 }
 
-func compileAll(src string, glsl bool) (map[string]*Kernel, error) {
+// source is a parsed kernel source: its struct types, and its entry points
+// and helpers in source order.
+type source struct {
+	structs        map[string]*ast.StructType
+	funcs, helpers []*ast.FuncDecl
+}
+
+// parseSource parses src and sorts its declarations for the emitters.
+func parseSource(src string) (*source, error) {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "kernel.go", src, parser.ParseComments)
 	if err != nil {
 		return nil, fmt.Errorf("shader: parse: %w", err)
 	}
 
-	structs := map[string]*ast.StructType{}
-	var funcs, helpers []*ast.FuncDecl
+	ps := &source{structs: map[string]*ast.StructType{}}
 	for _, d := range file.Decls {
 		switch decl := d.(type) {
 		case *ast.GenDecl:
 			for _, s := range decl.Specs {
 				if ts, ok := s.(*ast.TypeSpec); ok {
 					if st, ok := ts.Type.(*ast.StructType); ok {
-						structs[ts.Name.Name] = st
+						ps.structs[ts.Name.Name] = st
 					}
 				}
 			}
 		case *ast.FuncDecl:
 			if decl.Recv == nil && decl.Body != nil {
 				if isHelperFn(decl.Doc) {
-					helpers = append(helpers, decl)
+					ps.helpers = append(ps.helpers, decl)
 				} else {
-					funcs = append(funcs, decl)
+					ps.funcs = append(ps.funcs, decl)
 				}
 			}
 		}
 	}
+	return ps, nil
+}
+
+func compileAll(src string, glsl bool) (map[string]*Kernel, error) {
+	ps, err := parseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	structs, funcs, helpers := ps.structs, ps.funcs, ps.helpers
 
 	// Helpers are emitted into every kernel from this source, in source order so
 	// a helper can call one declared above it. With no helpers the prelude is
@@ -303,6 +368,10 @@ type compiler struct {
 	glsl    bool              // emit GLSL type spellings instead of MSL
 	helpers map[string]string // //gpu:helper func name -> canonical result type
 	buf     strings.Builder
+
+	// ret, if set, emits `return v` of a vertex or fragment kernel, whose
+	// result goes to the stage outputs rather than back to a caller.
+	ret func(v string, depth int)
 }
 
 // glslReserved are GLSL keywords that may collide with Go kernel identifiers
@@ -315,6 +384,13 @@ var glslReserved = map[string]bool{
 	"bool": true, "vec2": true, "vec3": true, "vec4": true, "mat2": true,
 	"mat3": true, "mat4": true, "sampler2D": true, "highp": true, "lowp": true,
 	"mediump": true, "precision": true, "discard": true, "struct": true,
+	// Reserved for future use by GLSL ES 3.10; a local such as the shadow
+	// kernel's `filter` does not compile unmangled.
+	"filter": true, "input": true, "output": true, "sample": true, "shared": true,
+	"flat": true, "smooth": true, "common": true, "partition": true, "active": true,
+	"half": true, "fixed": true, "long": true, "short": true, "double": true,
+	"class": true, "union": true, "enum": true, "static": true, "inline": true,
+	"public": true, "extern": true, "external": true, "this": true, "cast": true,
 }
 
 // name returns an identifier's spelling in the target language: identity for MSL
@@ -392,25 +468,14 @@ func isSwizzle(s string) bool {
 func compileKernel(fn *ast.FuncDecl, structs map[string]*ast.StructType, helpers string, helperTypes map[string]string) (*Kernel, error) {
 	stage := stageOf(fn.Doc)
 	params := flattenParams(fn.Type.Params)
+	if err := checkStageCalls(fn, stage); err != nil {
+		return nil, err
+	}
 
 	c := &compiler{structs: structs, env: map[string]string{}, written: map[string]bool{}, helpers: helperTypes}
 
-	// First pass: detect which buffer params are written (appear on the LHS of
-	// an index assignment), so reads stay const.
-	ast.Inspect(fn.Body, func(n ast.Node) bool {
-		as, ok := n.(*ast.AssignStmt)
-		if !ok {
-			return true
-		}
-		for _, lhs := range as.Lhs {
-			if ix, ok := lhs.(*ast.IndexExpr); ok {
-				if id, ok := ix.X.(*ast.Ident); ok {
-					c.written[id.Name] = true
-				}
-			}
-		}
-		return true
-	})
+	// First pass: detect which buffer params are written, so reads stay const.
+	c.written = writtenBuffers(fn.Body)
 
 	// compute and vertex kernels take a leading id parameter
 	// (thread_position_in_grid / vertex_id); fragment kernels do not.
@@ -469,6 +534,12 @@ func compileKernel(fn *ast.FuncDecl, structs map[string]*ast.StructType, helpers
 				c.env[p.name] = "texture2d"
 				texIndex++
 				continue
+			case "Image":
+				sig = append(sig, fmt.Sprintf("texture2d<float, access::read> %s [[texture(%d)]]", p.name, texIndex))
+				bindings = append(bindings, Binding{Index: texIndex, Name: p.name, Kind: StorageTexture})
+				c.env[p.name] = "image"
+				texIndex++
+				continue
 			case "Sampler":
 				sig = append(sig, fmt.Sprintf("sampler %s [[sampler(%d)]]", p.name, samplerIndex))
 				bindings = append(bindings, Binding{Index: samplerIndex, Name: p.name, Kind: SamplerBinding})
@@ -505,15 +576,14 @@ func compileKernel(fn *ast.FuncDecl, structs map[string]*ast.StructType, helpers
 		}
 		sig = append(sig, fmt.Sprintf("uint %s %s", idName, attr))
 	}
-
-	var body strings.Builder
-	bc := &compiler{structs: structs, env: c.env, written: c.written, buf: body, helpers: helperTypes}
-	if err := bc.stmts(fn.Body.List, 1); err != nil {
-		return nil, err
+	if stage == StageFragment && usesFrontFacing(fn) {
+		sig = append(sig, fmt.Sprintf("bool %s [[front_facing]]", frontFacingParam))
 	}
 
 	// Function keyword and return type per stage.
 	kw, ret := "kernel", "void"
+	targets := "" // the struct of the color targets a fragment returns
+	pos := ""     // the clip-space position a vertex returns, in _ret
 	switch stage {
 	case StageVertex, StageFragment:
 		if stage == StageVertex {
@@ -528,19 +598,50 @@ func compileKernel(fn *ast.FuncDecl, structs map[string]*ast.StructType, helpers
 		if mt, ok := goToMSLType(rt); ok {
 			// built-in vector return (e.g. fragment float4)
 			ret = mt
-		} else if _, isStruct := structs[rt]; isStruct {
-			// vertex output struct (varyings + [[position]])
+			pos = "_ret"
+		} else if st, isStruct := structs[rt]; isStruct {
+			// vertex output struct (varyings + [[position]]) or the
+			// color targets of a fragment
 			ret = rt
 			usedStructs = append(usedStructs, rt)
+			if stage == StageFragment {
+				targets = rt
+			} else if f := positionField(st); f != "" {
+				pos = "_ret." + f
+			} else {
+				return nil, fmt.Errorf("vertex output %s has no gpu:\"position\" field", rt)
+			}
 		} else {
 			return nil, fmt.Errorf("unsupported return type %q", rt)
 		}
 	}
 
+	var body strings.Builder
+	bc := &compiler{structs: structs, env: c.env, written: c.written, buf: body, helpers: helperTypes}
+	if stage == StageVertex {
+		// Kernels output GL's clip space, whose z runs over [-w, w]; Metal
+		// clips z to [0, w]. Remap z so that depth means the same on both.
+		bc.ret = func(v string, depth int) {
+			bc.indent(depth)
+			bc.buf.WriteString("{\n")
+			bc.indent(depth + 1)
+			fmt.Fprintf(&bc.buf, "%s _ret = %s;\n", ret, v)
+			bc.indent(depth + 1)
+			fmt.Fprintf(&bc.buf, "%s.z = (%s.z + %s.w) * 0.5;\n", pos, pos, pos)
+			bc.indent(depth + 1)
+			bc.buf.WriteString("return _ret;\n")
+			bc.indent(depth)
+			bc.buf.WriteString("}\n")
+		}
+	}
+	if err := bc.stmts(fn.Body.List, 1); err != nil {
+		return nil, err
+	}
+
 	var msl strings.Builder
 	msl.WriteString("#include <metal_stdlib>\nusing namespace metal;\n\n")
 	for _, name := range usedStructs {
-		emitStruct(&msl, name, structs[name])
+		emitStruct(&msl, name, structs[name], name == targets)
 	}
 	msl.WriteString(helpers)
 	fmt.Fprintf(&msl, "%s %s %s(%s) {\n%s}\n", kw, ret, fn.Name.Name, strings.Join(sig, ",\n    "), bc.buf.String())
@@ -556,26 +657,16 @@ func compileKernel(fn *ast.FuncDecl, structs map[string]*ast.StructType, helpers
 // pervasively as in gid*4); explicit uint() conversions in the source still work.
 func compileKernelGLSL(fn *ast.FuncDecl, structs map[string]*ast.StructType, helpers string, helperTypes map[string]string) (*Kernel, error) {
 	if stage := stageOf(fn.Doc); stage != StageCompute {
-		return nil, fmt.Errorf("GLSL backend supports compute kernels only (no vertex/fragment yet)")
+		return compileStageGLSL(fn, stage, structs, helpers, helperTypes)
+	}
+	if err := checkStageCalls(fn, StageCompute); err != nil {
+		return nil, err
 	}
 	params := flattenParams(fn.Type.Params)
 	c := &compiler{structs: structs, env: map[string]string{}, written: map[string]bool{}, glsl: true, helpers: helperTypes}
 
 	// First pass: which buffer params are written (so reads stay readonly).
-	ast.Inspect(fn.Body, func(n ast.Node) bool {
-		as, ok := n.(*ast.AssignStmt)
-		if !ok {
-			return true
-		}
-		for _, lhs := range as.Lhs {
-			if ix, ok := lhs.(*ast.IndexExpr); ok {
-				if id, ok := ix.X.(*ast.Ident); ok {
-					c.written[id.Name] = true
-				}
-			}
-		}
-		return true
-	})
+	c.written = writtenBuffers(fn.Body)
 
 	if len(params) == 0 {
 		return nil, fmt.Errorf("kernel needs a leading id parameter")
@@ -588,22 +679,54 @@ func compileKernelGLSL(fn *ast.FuncDecl, structs map[string]*ast.StructType, hel
 	idName := gid.name
 	c.env[idName] = "int"
 
+	decls, bindings, err := c.glslResources(params[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var body strings.Builder
+	bc := &compiler{structs: structs, env: c.env, written: c.written, glsl: true, buf: body, helpers: helperTypes}
+	if err := bc.stmts(fn.Body.List, 1); err != nil {
+		return nil, err
+	}
+
+	var src strings.Builder
+	src.WriteString("#version 310 es\nprecision highp float;\nlayout(local_size_x = 1) in;\n\n")
+	for _, d := range decls {
+		src.WriteString(d + "\n")
+	}
+	if helpers != "" {
+		src.WriteString("\n" + helpers)
+		src.WriteString("void main() {\n")
+	} else {
+		src.WriteString("\nvoid main() {\n")
+	}
+	fmt.Fprintf(&src, "    int %s = int(gl_GlobalInvocationID.x);\n", c.name(idName))
+	src.WriteString(bc.buf.String())
+	src.WriteString("}\n")
+
+	return &Kernel{Name: fn.Name.Name, Stage: StageCompute, Bindings: bindings, GLSL: src.String()}, nil
+}
+
+// glslResources declares the buffer, uniform and image parameters of a GLSL
+// shader and returns the declarations and their bindings.
+func (c *compiler) glslResources(params []param) ([]string, []Binding, error) {
 	var bindings []Binding
 	var decls []string
-	ssboIndex, uboIndex := 0, 0
-	for _, p := range params[1:] {
+	ssboIndex, uboIndex, imageIndex := 0, 0, 0
+	for _, p := range params {
 		switch t := p.typ.(type) {
 		case *ast.ArrayType: // []float32 -> std430 SSBO
 			if t.Len != nil {
-				return nil, fmt.Errorf("parameter %q: only slices ([]float32) are supported as buffers", p.name)
+				return nil, nil, fmt.Errorf("parameter %q: only slices ([]float32) are supported as buffers", p.name)
 			}
 			elt, ok := identType(t.Elt)
 			if !ok {
-				return nil, fmt.Errorf("parameter %q: unsupported slice element", p.name)
+				return nil, nil, fmt.Errorf("parameter %q: unsupported slice element", p.name)
 			}
 			mt, ok := goToMSLType(elt)
 			if !ok {
-				return nil, fmt.Errorf("parameter %q: unsupported slice element %q", p.name, elt)
+				return nil, nil, fmt.Errorf("parameter %q: unsupported slice element %q", p.name, elt)
 			}
 			qual := ""
 			if !c.written[p.name] {
@@ -616,11 +739,18 @@ func compileKernelGLSL(fn *ast.FuncDecl, structs map[string]*ast.StructType, hel
 		case *ast.Ident:
 			switch t.Name {
 			case "Texture2D", "Sampler":
-				return nil, fmt.Errorf("parameter %q: GLSL backend does not support textures/samplers yet", p.name)
+				return nil, nil, fmt.Errorf("parameter %q: GLSL backend does not support textures/samplers yet", p.name)
+			case "Image":
+				// Images have their own binding space, the image units.
+				decls = append(decls, fmt.Sprintf("layout(rgba32f, binding = %d) readonly uniform highp image2D %s;", imageIndex, c.name(p.name)))
+				bindings = append(bindings, Binding{Index: imageIndex, Name: p.name, Kind: StorageTexture})
+				c.env[p.name] = "image"
+				imageIndex++
+				continue
 			}
-			st, ok := structs[t.Name]
+			st, ok := c.structs[t.Name]
 			if !ok {
-				return nil, fmt.Errorf("parameter %q: unsupported type %q", p.name, t.Name)
+				return nil, nil, fmt.Errorf("parameter %q: unsupported type %q", p.name, t.Name)
 			}
 			var fields []string
 			for _, f := range st.Fields.List {
@@ -638,58 +768,256 @@ func compileKernelGLSL(fn *ast.FuncDecl, structs map[string]*ast.StructType, hel
 			c.env[p.name] = t.Name
 			uboIndex++
 		default:
-			return nil, fmt.Errorf("parameter %q: unsupported parameter type", p.name)
+			return nil, nil, fmt.Errorf("parameter %q: unsupported parameter type", p.name)
+		}
+	}
+	return decls, bindings, nil
+}
+
+// compileStageGLSL emits a GLSL ES 3.10 vertex or fragment shader for fn,
+// with the resources laid out as for compute. A vertex kernel reads its id
+// from gl_VertexID and returns its clip-space position, or a struct of the
+// position (its gpu:"position" field) and the varyings. The first struct
+// parameter of a fragment kernel is that struct, interpolated, whose position
+// reads gl_FragCoord; the fragment returns the color of its target, or a
+// struct of the colors of its targets in order. The varyings and targets are
+// globals in GLSL, so a return stores the fields of the result in them.
+func compileStageGLSL(fn *ast.FuncDecl, stage Stage, structs map[string]*ast.StructType, helpers string, helperTypes map[string]string) (*Kernel, error) {
+	if err := checkStageCalls(fn, stage); err != nil {
+		return nil, err
+	}
+	params := flattenParams(fn.Type.Params)
+	c := &compiler{structs: structs, env: map[string]string{}, written: writtenBuffers(fn.Body), glsl: true, helpers: helperTypes}
+	if fn.Type.Results == nil || len(fn.Type.Results.List) != 1 {
+		kw := "vertex"
+		if stage == StageFragment {
+			kw = "fragment"
+		}
+		return nil, fmt.Errorf("%s kernel must return exactly one value", kw)
+	}
+
+	var decls, prologue []string
+	var used []string // the structs the shader declares
+	if stage == StageVertex {
+		if len(params) == 0 {
+			return nil, fmt.Errorf("kernel needs a leading id parameter")
+		}
+		vid := params[0]
+		if vt, ok := identType(vid.typ); !ok || !isIntType(vt) {
+			return nil, fmt.Errorf("first parameter %q must be the int/uint id", vid.name)
+		}
+		c.env[vid.name] = "int"
+		prologue = append(prologue, fmt.Sprintf("int %s = gl_VertexID;", c.name(vid.name)))
+		params = params[1:]
+	} else if len(params) > 0 {
+		if t, ok := params[0].typ.(*ast.Ident); ok && structs[t.Name] != nil {
+			in := params[0]
+			st := structs[t.Name]
+			var args []string
+			for _, f := range st.Fields.List {
+				for _, n := range f.Names {
+					if fieldTag(f) == "position" {
+						args = append(args, "gl_FragCoord")
+						continue
+					}
+					decls = append(decls, c.varying("in", f, n.Name))
+					args = append(args, "v_"+n.Name)
+				}
+			}
+			c.env[in.name] = t.Name
+			used = append(used, t.Name)
+			prologue = append(prologue, fmt.Sprintf("%s %s = %s(%s);", t.Name, c.name(in.name), t.Name, strings.Join(args, ", ")))
+			params = params[1:]
+		}
+	}
+
+	res, bindings, err := c.glslResources(params)
+	if err != nil {
+		return nil, err
+	}
+	decls = append(res, decls...)
+
+	// outs pairs each output variable with the part of the result it holds.
+	var outs [][2]string
+	rt, _ := identType(fn.Type.Results.List[0].Type)
+	ret, ok := goToMSLType(rt)
+	switch st := structs[rt]; {
+	case ok && stage == StageVertex:
+		outs = append(outs, [2]string{"gl_Position", "_ret"})
+	case ok:
+		decls = append(decls, fmt.Sprintf("layout(location = 0) out %s _out0;", c.typ(ret)))
+		outs = append(outs, [2]string{"_out0", "_ret"})
+	case st != nil && stage == StageVertex:
+		if positionField(st) == "" {
+			return nil, fmt.Errorf("vertex output %s has no gpu:\"position\" field", rt)
+		}
+		for _, f := range st.Fields.List {
+			for _, n := range f.Names {
+				if fieldTag(f) == "position" {
+					outs = append(outs, [2]string{"gl_Position", "_ret." + n.Name})
+					continue
+				}
+				decls = append(decls, c.varying("out", f, n.Name))
+				outs = append(outs, [2]string{"v_" + n.Name, "_ret." + n.Name})
+			}
+		}
+	case st != nil:
+		for _, f := range st.Fields.List {
+			ft, _ := identType(f.Type)
+			mt, _ := goToMSLType(ft)
+			for _, n := range f.Names {
+				decls = append(decls, fmt.Sprintf("layout(location = %d) out %s _out_%s;", len(outs), c.typ(mt), n.Name))
+				outs = append(outs, [2]string{"_out_" + n.Name, "_ret." + n.Name})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported return type %q", rt)
+	}
+	if !ok {
+		ret = rt
+		if len(used) == 0 || used[0] != rt {
+			used = append(used, rt)
 		}
 	}
 
 	var body strings.Builder
 	bc := &compiler{structs: structs, env: c.env, written: c.written, glsl: true, buf: body, helpers: helperTypes}
+	bc.ret = func(v string, depth int) {
+		bc.indent(depth)
+		bc.buf.WriteString("{\n")
+		bc.indent(depth + 1)
+		fmt.Fprintf(&bc.buf, "%s _ret = %s;\n", bc.typ(ret), v)
+		for _, o := range outs {
+			bc.indent(depth + 1)
+			fmt.Fprintf(&bc.buf, "%s = %s;\n", o[0], o[1])
+		}
+		bc.indent(depth + 1)
+		bc.buf.WriteString("return;\n")
+		bc.indent(depth)
+		bc.buf.WriteString("}\n")
+	}
 	if err := bc.stmts(fn.Body.List, 1); err != nil {
 		return nil, err
 	}
 
 	var src strings.Builder
-	src.WriteString("#version 310 es\nprecision highp float;\nlayout(local_size_x = 1) in;\n\n")
+	src.WriteString("#version 310 es\nprecision highp float;\n\n")
+	for _, name := range used {
+		src.WriteString(c.glslStruct(name, structs[name]))
+	}
 	for _, d := range decls {
 		src.WriteString(d + "\n")
 	}
-	if helpers != "" {
-		src.WriteString("\n" + helpers)
-		src.WriteString("void main() {\n")
-	} else {
-		src.WriteString("\nvoid main() {\n")
+	src.WriteString("\n" + helpers)
+	src.WriteString("void main() {\n")
+	for _, p := range prologue {
+		src.WriteString("    " + p + "\n")
 	}
-	fmt.Fprintf(&src, "    int %s = int(gl_GlobalInvocationID.x);\n", c.name(idName))
 	src.WriteString(bc.buf.String())
 	src.WriteString("}\n")
 
-	return &Kernel{Name: fn.Name.Name, Stage: StageCompute, Bindings: bindings, GLSL: src.String()}, nil
+	return &Kernel{Name: fn.Name.Name, Stage: stage, Bindings: bindings, GLSL: src.String()}, nil
+}
+
+// varying declares the field name of f as a vertex output or fragment input
+// (dir "out" or "in"), flat if f is tagged gpu:"flat".
+func (c *compiler) varying(dir string, f *ast.Field, name string) string {
+	ft, _ := identType(f.Type)
+	mt, _ := goToMSLType(ft)
+	qual := ""
+	if fieldTag(f) == "flat" {
+		qual = "flat "
+	}
+	return fmt.Sprintf("%s%s %s v_%s;", qual, dir, c.typ(mt), name)
 }
 
-func emitStruct(w *strings.Builder, name string, st *ast.StructType) {
+// glslStruct declares the struct type name in GLSL.
+func (c *compiler) glslStruct(name string, st *ast.StructType) string {
+	var w strings.Builder
+	fmt.Fprintf(&w, "struct %s {\n", name)
+	for _, f := range st.Fields.List {
+		ft, _ := identType(f.Type)
+		mt, ok := goToMSLType(ft)
+		if !ok {
+			mt = ft
+		}
+		for _, n := range f.Names {
+			fmt.Fprintf(&w, "    %s %s;\n", c.typ(mt), n.Name)
+		}
+	}
+	w.WriteString("};\n\n")
+	return w.String()
+}
+
+// writtenBuffers returns the buffer parameters that body writes to, those
+// that appear on the left of an index assignment.
+func writtenBuffers(body *ast.BlockStmt) map[string]bool {
+	written := map[string]bool{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok {
+			return true
+		}
+		for _, lhs := range as.Lhs {
+			if ix, ok := lhs.(*ast.IndexExpr); ok {
+				if id, ok := ix.X.(*ast.Ident); ok {
+					written[id.Name] = true
+				}
+			}
+		}
+		return true
+	})
+	return written
+}
+
+func emitStruct(w *strings.Builder, name string, st *ast.StructType, targets bool) {
 	fmt.Fprintf(w, "struct %s {\n", name)
+	target := 0
 	for _, f := range st.Fields.List {
 		ft, _ := identType(f.Type)
 		mt, ok := goToMSLType(ft)
 		if !ok {
 			mt = ft
 		}
-		// A `gpu:"position"` tag marks the clip-space position output.
+		// A `gpu:"position"` tag marks the clip-space position output, and a
+		// `gpu:"flat"` tag a varying that is not interpolated.
 		attr := ""
-		if f.Tag != nil {
-			tag := reflect.StructTag(strings.Trim(f.Tag.Value, "`"))
-			switch tag.Get("gpu") {
-			case "position":
-				attr = " [[position]]"
-			}
+		switch fieldTag(f) {
+		case "position":
+			attr = " [[position]]"
+		case "flat":
+			attr = " [[flat]]"
 		}
 		for _, n := range f.Names {
+			// The fields of a fragment's result are its color targets, in order.
+			if targets {
+				attr = fmt.Sprintf(" [[color(%d)]]", target)
+				target++
+			}
 			fmt.Fprintf(w, "    %s %s%s;\n", mt, n.Name, attr)
 		}
 	}
 	w.WriteString("};\n\n")
 }
 
+// fieldTag returns the gpu tag of a struct field.
+func fieldTag(f *ast.Field) string {
+	if f.Tag == nil {
+		return ""
+	}
+	return reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("gpu")
+}
+
+// positionField returns the name of the gpu:"position" field of st, or "".
+func positionField(st *ast.StructType) string {
+	for _, f := range st.Fields.List {
+		if fieldTag(f) == "position" && len(f.Names) == 1 {
+			return f.Names[0].Name
+		}
+	}
+	return ""
+}
+
 type param struct {
 	name string
 	typ  ast.Expr
@@ -749,9 +1077,25 @@ func (c *compiler) stmt(s ast.Stmt, depth int) error {
 		return nil
 	case *ast.BlockStmt:
 		return c.stmts(st.List, depth)
-	case *ast.ReturnStmt:
+	case *ast.ExprStmt:
+		// Discard() is the only call made for its effect.
+		call, ok := st.X.(*ast.CallExpr)
+		if !ok {
+			return fmt.Errorf("unsupported expression statement")
+		}
+		if id, ok := call.Fun.(*ast.Ident); !ok || id.Name != "Discard" {
+			return fmt.Errorf("unsupported expression statement")
+		}
 		c.indent(depth)
+		if c.glsl {
+			c.buf.WriteString("discard;\n")
+		} else {
+			c.buf.WriteString("discard_fragment();\n")
+		}
+		return nil
+	case *ast.ReturnStmt:
 		if len(st.Results) == 0 {
+			c.indent(depth)
 			c.buf.WriteString("return;\n")
 			return nil
 		}
@@ -759,6 +1103,11 @@ func (c *compiler) stmt(s ast.Stmt, depth int) error {
 		if err != nil {
 			return err
 		}
+		if c.ret != nil {
+			c.ret(v, depth)
+			return nil
+		}
+		c.indent(depth)
 		fmt.Fprintf(&c.buf, "return %s;\n", v)
 		return nil
 	default:
@@ -1081,6 +1430,16 @@ func (c *compiler) call(ex *ast.CallExpr) (string, error) {
 		case "Sample":
 			// Texture2D.Sample(samp, uv) -> tex.sample(...)
 			return fmt.Sprintf("%s.sample(%s)", base, strings.Join(args, ", ")), nil
+		case "Load":
+			// Image.Load(x, y) -> a texel read. Image rows run bottom-up,
+			// as GL stores a render target; Metal stores them top-down.
+			if len(args) != 2 {
+				return "", fmt.Errorf("method %q takes two arguments", name)
+			}
+			if c.glsl {
+				return fmt.Sprintf("imageLoad(%s, ivec2(%s, %s))", base, args[0], args[1]), nil
+			}
+			return fmt.Sprintf("%s.read(uint2(uint(%s), %s.get_height() - 1u - uint(%s)))", base, args[0], base, args[1]), nil
 		}
 		return "", fmt.Errorf("unsupported method %q", name)
 	}
@@ -1089,6 +1448,14 @@ func (c *compiler) call(ex *ast.CallExpr) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("unsupported call target")
 	}
+	if id.Name == "FrontFacing" {
+		if c.glsl {
+			return "gl_FrontFacing", nil
+		}
+		// Metal's front faces wind clockwise, GL's counter-clockwise, so a
+		// front face on GL is a back face on Metal (see usesFrontFacing).
+		return "(!" + frontFacingParam + ")", nil
+	}
 	// gpumath vector/matrix constructors: emit the target type's constructor
 	// (V4 -> float4 on MSL, vec4 on GLSL) via c.typ.
 	if mt, ok := vecCtor[id.Name]; ok {
@@ -1119,6 +1486,9 @@ func (c *compiler) call(ex *ast.CallExpr) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("call to %q is not in the builtin/conversion whitelist", id.Name)
 	}
+	if g, ok := glslBuiltins[msl]; ok && c.glsl {
+		msl = g
+	}
 	var args []string
 	for _, a := range ex.Args {
 		v, err := c.expr(a)
@@ -1145,8 +1515,8 @@ func (c *compiler) inferType(e ast.Expr) string {
 	case *ast.CallExpr:
 		if sel, ok := ex.Fun.(*ast.SelectorExpr); ok {
 			switch sel.Sel.Name {
-			case "Sample":
-				return "float4" // Texture2D.Sample returns a float4
+			case "Sample", "Load":
+				return "float4" // Texture2D.Sample and Image.Load return a float4
 			case "Add", "Sub", "Mul", "Scale", "Div", "Normalize":
 				return c.inferType(sel.X) // vector-preserving: receiver's type
 			case "MulV":
@@ -1165,6 +1535,8 @@ func (c *compiler) inferType(e ast.Expr) string {
 			}
 			// gpumath constructors return their vector/matrix type.
 			switch id.Name {
+			case "FrontFacing":
+				return "bool"
 			case "V2":
 				return "float2"
 			case "V3":
@@ -1177,7 +1549,7 @@ func (c *compiler) inferType(e ast.Expr) string {
 			// vector-preserving builtins return their argument's type
 			switch id.Name {
 			case "normalize", "cross", "reflect", "min", "max", "clamp", "abs",
-				"Normalize", "Cross", "Reflect", "Mix":
+				"Normalize", "Cross", "Reflect", "Mix", "Dfdx", "Dfdy":
 				if len(ex.Args) > 0 {
 					return c.inferType(ex.Args[0])
 				}
@@ -1210,6 +1582,8 @@ func (c *compiler) inferType(e ast.Expr) string {
 		return lt
 	case *ast.ParenExpr:
 		return c.inferType(ex.X)
+	case *ast.UnaryExpr:
+		return c.inferType(ex.X) // -k has the type of k
 	case *ast.CompositeLit:
 		if tn, ok := identType(ex.Type); ok {
 			if mt, ok := goToMSLType(tn); ok {
@@ -7,6 +7,8 @@ package shader
 import (
 	"strings"
 	"testing"
+
+	kernelpkg "poly.red/gpu/shader/gpumath/kernels"
 )
 
 // kernels is the Go source for the matrix compute kernels. The compiler turns
@@ -86,6 +88,47 @@ func TestCompile(t *testing.T) {
 	}
 }
 
+// TestCompileStages checks the MSL of the vertex and fragment stages of the
+// forward pass: flat varyings, the color targets of a struct result, the front
+// facing flag, the derivatives and the remap of the clip-space z of GL to
+// Metal's.
+func TestCompileStages(t *testing.T) {
+	ks, err := Compile(kernelpkg.ForwardSrc)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	for name, wants := range map[string][]string{
+		"Forward": {
+			"float Mat [[flat]];",
+			"float4 Pos [[position]];",
+			"vertex ForwardVaryings Forward(",
+			"uint vid [[vertex_id]]",
+			"_ret.Pos.z = (_ret.Pos.z + _ret.Pos.w) * 0.5;",
+			"return _ret;",
+		},
+		"ForwardGBuffer": {
+			"float4 WP [[color(0)]];",
+			"float4 T [[color(3)]];",
+			"ForwardVaryings in [[stage_in]]",
+			"bool gpu_front [[front_facing]]",
+			"if (!(!gpu_front)) {",
+			"discard_fragment();",
+			"float2 dx = dfdx(in.UV);",
+			"(1.0 - (in.Pos.z * 2.0))",
+		},
+		"ForwardPeel": {
+			"device const float* peel [[buffer(7)]]",
+			"int(in.Pos.y)",
+		},
+	} {
+		for _, want := range wants {
+			if !strings.Contains(ks[name].MSL, want) {
+				t.Errorf("%s MSL missing %q\n---\n%s", name, want, ks[name].MSL)
+			}
+		}
+	}
+}
+
 func TestCompileRejectsUnsupported(t *testing.T) {
 	bad := `package k
 func K(gid int, a []float32) {
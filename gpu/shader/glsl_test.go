@@ -130,14 +130,100 @@ func TestCompileGLSLCompute(t *testing.T) {
 	if !strings.Contains(sk["Shadow"].GLSL, "mat4") {
 		t.Errorf("shadow GLSL missing mat4:\n%s", sk["Shadow"].GLSL)
 	}
+	// The filter loops count from -K: a negated int stays an int.
+	if !strings.Contains(sk["Shadow"].GLSL, "for (int i = -K;") {
+		t.Errorf("shadow GLSL declares the negated int loop counter as another type:\n%s", sk["Shadow"].GLSL)
+	}
+	// `filter` is reserved by GLSL ES 3.10 and must be mangled too.
+	if !strings.Contains(sk["Shadow"].GLSL, "filter_") || strings.Contains(sk["Shadow"].GLSL, " filter ") {
+		t.Errorf("shadow GLSL did not mangle reserved word 'filter':\n%s", sk["Shadow"].GLSL)
+	}
 
-	// AO kernel uses trig/atan and nested loops; just require it compiles.
+	// The AO kernels use trig, matrix multiply and nested loops; just
+	// require they compile.
 	if _, err := CompileGLSL(kernelpkg.AOSrc); err != nil {
 		t.Fatalf("compile AO: %v", err)
 	}
+	if _, err := CompileGLSL(kernelpkg.AOBlurSrc); err != nil {
+		t.Fatalf("compile AOBlur: %v", err)
+	}
+
+	// The antialiasing kernels call int helpers from nested loops.
+	if _, err := CompileGLSL(kernelpkg.FXAASrc); err != nil {
+		t.Fatalf("compile FXAA: %v", err)
+	}
+	if _, err := CompileGLSL(kernelpkg.TAASrc); err != nil {
+		t.Fatalf("compile TAA: %v", err)
+	}
+
+	// The G-buffer kernel loads the forward pass's targets as images, which
+	// are numbered in their own binding space.
+	gk, err := CompileGLSL(kernelpkg.GBufferSrc)
+	if err != nil {
+		t.Fatalf("compile GBuffer: %v", err)
+	}
+	for _, want := range []string{
+		"layout(rgba32f, binding = 1) readonly uniform highp image2D nr;",
+		"imageLoad(wp, ivec2(x, y))",
+		"layout(std430, binding = 0) readonly buffer _ssbo0 { float mats[]; };",
+	} {
+		if !strings.Contains(gk["GBuffer"].GLSL, want) {
+			t.Errorf("GBuffer GLSL missing %q\n---\n%s", want, gk["GBuffer"].GLSL)
+		}
+	}
+}
+
+// TestCompileGLSLStages checks the GLSL of the vertex and fragment stages of
+// the forward pass: the varyings and targets are globals that a return
+// stores the fields of its result in, the varyings struct of a fragment is
+// rebuilt from them and gl_FragCoord, and flat, the derivatives and the front
+// facing flag get their GLSL spellings.
+func TestCompileGLSLStages(t *testing.T) {
+	ks, err := CompileGLSL(kernelpkg.ForwardSrc)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	for name, wants := range map[string][]string{
+		"Forward": {
+			"layout(std430, binding = 6) readonly buffer _ssbo6 { float wtan[]; };",
+			"flat out float v_Mat;",
+			"out vec2 v_UV;",
+			"int vid = gl_VertexID;",
+			"gl_Position = _ret.Pos;",
+			"v_Mat = _ret.Mat;",
+		},
+		"ForwardGBuffer": {
+			"flat in float v_Mat;",
+			"layout(location = 0) out vec4 _out_WP;",
+			"layout(location = 3) out vec4 _out_T;",
+			"ForwardVaryings in_ = ForwardVaryings(gl_FragCoord, v_World, v_Normal, v_Tangent, v_UV, v_Mat);",
+			"if (!gl_FrontFacing) {",
+			"discard;",
+			"vec2 dx = dFdx(in_.UV);",
+			"_out_T = _ret.T;",
+		},
+		"ForwardPeel": {
+			"layout(std430, binding = 7) readonly buffer _ssbo7 { float peel[]; };",
+			"int(in_.Pos.y)",
+		},
+		"ForwardCoverage": {
+			"layout(location = 0) out vec4 _out0;",
+			"_out0 = _ret;",
+		},
+	} {
+		k := ks[name]
+		if k.Stage == StageCompute {
+			t.Errorf("%s: compiled as a compute kernel", name)
+		}
+		for _, want := range wants {
+			if !strings.Contains(k.GLSL, want) {
+				t.Errorf("%s GLSL missing %q\n---\n%s", name, want, k.GLSL)
+			}
+		}
+	}
 }
 
-// TestCompileGLSLRejectsUnsupported verifies the GLSL compute emitter rejects
+// TestCompileGLSLRejectsUnsupported verifies the GLSL emitter rejects
 // what it does not yet support, with a clear error, rather than emitting bad
 // shader source.
 func TestCompileGLSLRejectsUnsupported(t *testing.T) {
@@ -146,11 +232,21 @@ func TestCompileGLSLRejectsUnsupported(t *testing.T) {
 		src  string
 	}{
 		{
-			name: "vertex stage",
+			name: "vertex output without a position",
 			src: `package k
 type Vec4 struct{ X, Y, Z, W float32 }
+type VOut struct{ Color Vec4 }
 //gpu:vertex
-func V(vid uint, pos []float32) Vec4 { return Vec4{pos[vid], 0, 0, 1} }`,
+func V(vid uint, pos []float32) VOut { return VOut{Vec4{pos[vid], 0, 0, 1}} }`,
+		},
+		{
+			name: "derivative outside a fragment",
+			src: `package k
+type Vec2 struct{ X, Y float32 }
+func K(gid uint, a []float32, out []float32) {
+	d := Dfdx(Vec2{a[gid], 0})
+	out[gid] = d.X
+}`,
 		},
 		{
 			name: "texture param",
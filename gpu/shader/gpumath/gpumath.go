@@ -51,6 +51,20 @@ func (a Vec4) Normalize() Vec4 {
 	return a.Scale(1 / l)
 }
 
+// --- Vec2, Vec3 methods ---
+
+func (a Vec2) Dot(b Vec2) float32 { return a.X*b.X + a.Y*b.Y }
+func (a Vec3) Dot(b Vec3) float32 { return a.X*b.X + a.Y*b.Y + a.Z*b.Z }
+func (a Vec3) Length() float32    { return float32(math.Sqrt(float64(a.Dot(a)))) }
+func (a Vec3) Normalize() Vec3 {
+	l := a.Length()
+	if l == 0 {
+		return a
+	}
+	s := 1 / l
+	return Vec3{a.X * s, a.Y * s, a.Z * s}
+}
+
 // --- free functions (the compiler maps these to shader builtins) ---
 
 func Add(a, b Vec4) Vec4       { return a.Add(b) }
@@ -109,3 +123,41 @@ func Maxf(a, b float32) float32 {
 func (m Mat4) MulV(v Vec4) Vec4 {
 	return m.C0.Scale(v.X).Add(m.C1.Scale(v.Y)).Add(m.C2.Scale(v.Z)).Add(m.C3.Scale(v.W))
 }
+
+// --- Image ---
+
+// Image is a read-only RGBA32Float texture that a kernel loads by texel,
+// such as a render target of an earlier pass that stays on the GPU. Its rows
+// run bottom-up, in the order of the render's fragment buffer: Pix holds the
+// texel (x, y) at (y*Width+x)*4. The compiler lowers it to a read-access
+// texture2d (MSL) or a readonly image2D (GLSL).
+type Image struct {
+	Width, Height int
+	Pix           []float32
+}
+
+// Load returns the texel (x, y) of the image.
+func (m Image) Load(x, y int) Vec4 {
+	i := (y*m.Width + x) * 4
+	return Vec4{m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3]}
+}
+
+// --- fragment stage ---
+
+// A fragment kernel (//gpu:fragment) runs on the GPU only, where these read
+// the state of the rasterizer. Their Go bodies, those of a lone front facing
+// fragment, let the kernel build as Go.
+
+// FrontFacing reports whether the fragment belongs to a front face, a
+// counter-clockwise triangle in normalized device coordinates as on GL, on
+// every backend.
+func FrontFacing() bool { return true }
+
+// Discard drops the fragment: the rest of the kernel does not run and no
+// render target or depth is written.
+func Discard() {}
+
+// Dfdx and Dfdy return the screen space derivatives of v in x and y, the
+// difference to the neighboring fragment.
+func Dfdx(v Vec2) Vec2 { return Vec2{} }
+func Dfdy(v Vec2) Vec2 { return Vec2{} }
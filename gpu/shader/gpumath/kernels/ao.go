@@ -6,51 +6,72 @@ package kernels
 
 import . "poly.red/gpu/shader/gpumath"
 
-// AO is the screen-space ambient occlusion pass (material/ao.go), authored once:
-// it runs as Go on the CPU and its source (AOSrc) compiles to the GPU. For 8
-// directions it marches the depth buffer, accumulates the max elevation angle,
-// and darkens by pow(total, 10000). It runs in place after Shade/Shadow over the
-// shaded float buffer. au = [width, height, _, _].
-func AO(gid uint, fragxyz []float32, aoflag []float32, depthbuf []float32, color []float32, au []float32) {
+// AO is the screen-space ambient occlusion pass (render/ssao.go), authored
+// once: it runs as Go on the CPU and its source (AOSrc) compiles to the GPU.
+// It writes the unblurred ambient light factor of every fragment to ao, 1 for
+// a fragment without ambient occlusion.
+//
+// viewpos and viewnor hold the view-space position and normal of each pixel,
+// the position with w = 1 for a covered pixel and w = 0 otherwise. The
+// samples are spread over the hemisphere around the normal, closer to the
+// fragment for the first ones, and rotated per pixel by a 4x4 pattern. A
+// sample is occluded if the depth buffer in its direction is in front of
+// it, within the radius of the fragment. au = [width, height, radius,
+// samples, strength, _, _, _, viewport*proj (column-major)].
+func AO(gid uint, viewpos []float32, viewnor []float32, aoflag []float32, ao []float32, au []float32) {
+	ao[gid] = 1.0
 	if aoflag[gid] < 0.5 {
 		return
 	}
-	px := fragxyz[gid*4]
-	py := fragxyz[gid*4+1]
-	traceDepth := fragxyz[gid*4+2]
 	width := int(au[0])
 	height := int(au[1])
-	total := float32(0)
-	for d := 0; d < 8; d++ {
-		ang := float32(d) * 0.78539816339744830961
-		dirX := Cos(ang)
-		dirY := Sin(ang)
-		maxangle := float32(0)
-		for t := 0; t < 100; t++ {
-			ft := float32(t)
-			dx := dirX * ft
-			dy := dirY * ft
-			distance := Sqrt(dx*dx + dy*dy)
-			if distance >= 1.0 {
-				ix := int(px + dx)
-				iy := int(py + dy)
-				if ix >= 0 {
-					if ix < width {
-						if iy >= 0 {
-							if iy < height {
-								elevation := depthbuf[iy*width+ix] - traceDepth
-								maxangle = Maxf(maxangle, Atan(elevation/distance))
-							}
-						}
-					}
+	radius := au[2]
+	samples := int(au[3])
+	strength := au[4]
+	M := M4(
+		V4(au[8], au[9], au[10], au[11]),
+		V4(au[12], au[13], au[14], au[15]),
+		V4(au[16], au[17], au[18], au[19]),
+		V4(au[20], au[21], au[22], au[23]),
+	)
+	P := V4(viewpos[gid*4], viewpos[gid*4+1], viewpos[gid*4+2], 1.0)
+	N := Normalize(V4(viewnor[gid*4], viewnor[gid*4+1], viewnor[gid*4+2], 0.0))
+
+	// A tangent frame around the normal.
+	a := V4(1.0, 0.0, 0.0, 0.0)
+	if Absf(N.X) > 0.9 {
+		a = V4(0.0, 1.0, 0.0, 0.0)
+	}
+	T := Normalize(a.Sub(N.Scale(Dot(a, N))))
+	B := V4(N.Y*T.Z-N.Z*T.Y, N.Z*T.X-N.X*T.Z, N.X*T.Y-N.Y*T.X, 0.0)
+
+	y := int(gid) / width
+	x := int(gid) - y*width
+	rot := float32((x-x/4*4)*4+(y-y/4*4)) * 0.39269908
+	bias := 0.05 * radius
+	occ := float32(0)
+	for i := 0; i < samples; i++ {
+		t := (float32(i) + 0.5) / float32(samples)
+		phi := float32(i)*2.39996323 + rot
+		st := Sqrt(t)
+		ct := Sqrt(1.0 - t)
+		scale := radius * (0.1 + 0.9*t*t)
+		d := T.Scale(Cos(phi) * st).Add(B.Scale(Sin(phi) * st)).Add(N.Scale(ct))
+		S := P.Add(d.Scale(scale))
+		clip := M.MulV(S)
+		sx := int(Floor(clip.X/clip.W + 0.5))
+		sy := int(Floor(clip.Y/clip.W + 0.5))
+		if sx >= 0 && sx < width && sy >= 0 && sy < height {
+			k := sy*width + sx
+			if viewpos[k*4+3] > 0.5 {
+				sz := viewpos[k*4+2]
+				if sz >= S.Z+bias {
+					// Occluders beyond the radius fade out.
+					r := Clampf(radius/Maxf(Absf(P.Z-sz), 0.000001), 0.0, 1.0)
+					occ = occ + r*r*(3.0-2.0*r)
 				}
 			}
 		}
-		total = total + (1.57079632679489661923 - maxangle)
 	}
-	total = total / (1.57079632679489661923 * 8.0)
-	total = Pow(total, 10000.0)
-	color[gid*4] = Floor(Clampf(Round(color[gid*4]), 0.0, 255.0) * total)
-	color[gid*4+1] = Floor(Clampf(Round(color[gid*4+1]), 0.0, 255.0) * total)
-	color[gid*4+2] = Floor(Clampf(Round(color[gid*4+2]), 0.0, 255.0) * total)
+	ao[gid] = Clampf(1.0-strength*occ/float32(samples), 0.0, 1.0)
 }
@@ -6,28 +6,58 @@ package kernels
 
 import "testing"
 
-// TestAO checks the author-once AO kernel run as Go: a non-AO fragment is
-// untouched, and an AO fragment is only ever darkened (the pow(.,10000) factor is
-// in [0,1]). The factor is too float-sensitive for an exact assertion (the
-// engine's GPU/CPU AO parity uses a tolerance for the same reason).
+// TestAO checks the author-once AO kernels run as Go on a 4x4 view-space
+// G-buffer of a floor in front of a wall: a fragment without ambient
+// occlusion is untouched, a fragment of the open floor is not occluded, a
+// fragment in the corner is, and the blur only ever darkens.
 func TestAO(t *testing.T) {
-	orig := []float32{200, 150, 100}
+	const w, h = 4, 4
+	// An orthographic view of one unit per pixel, looking down -Z: the
+	// bottom row is the wall at z = -1, the other rows are the floor at
+	// z = -3 facing the camera.
+	viewpos := make([]float32, w*h*4)
+	viewnor := make([]float32, w*h*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			k := (y*w + x) * 4
+			z := float32(-3)
+			if y == 0 {
+				z = -1
+			}
+			viewpos[k], viewpos[k+1], viewpos[k+2], viewpos[k+3] = float32(x), float32(y), z, 1
+			viewnor[k+2] = 1
+		}
+	}
+	au := []float32{w, h, 3, 16, 1, 0, 0, 0,
+		1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
 
-	// aoflag = 0: fragment skips AO, color unchanged.
-	color := []float32{200, 150, 100, 255}
-	AO(0, []float32{4, 4, 0, 0}, []float32{0}, make([]float32, 64), color, []float32{8, 8, 0, 0})
-	for i := 0; i < 3; i++ {
-		if color[i] != orig[i] {
-			t.Errorf("aoflag=0 chan %d changed: got %v want %v", i, color[i], orig[i])
+	aoflag := make([]float32, w*h)
+	ao := make([]float32, w*h)
+	AO(5, viewpos, viewnor, aoflag, ao, au)
+	if ao[5] != 1 {
+		t.Errorf("aoflag=0: got %v, want 1", ao[5])
+	}
+
+	for i := range aoflag {
+		aoflag[i] = 1
+	}
+	for i := 0; i < w*h; i++ {
+		AO(uint(i), viewpos, viewnor, aoflag, ao, au)
+		if ao[i] < 0 || ao[i] > 1 {
+			t.Fatalf("pixel %d: got %v, want in [0, 1]", i, ao[i])
 		}
 	}
+	if open, corner := ao[3*w+1], ao[1*w+1]; corner >= open || open < 0.99 {
+		t.Errorf("corner %v, open floor %v: want the corner occluded", corner, open)
+	}
 
-	// aoflag = 1: AO only darkens, so each channel ends up in [0, orig].
-	color2 := []float32{200, 150, 100, 255}
-	AO(0, []float32{4, 4, 0, 0}, []float32{1}, make([]float32, 64), color2, []float32{8, 8, 0, 0})
+	color := []float32{200, 150, 100, 255}
+	color = append(color, make([]float32, (w*h-1)*4)...)
+	AOBlur(0, viewpos, aoflag, ao, color, au)
+	orig := []float32{200, 150, 100}
 	for i := 0; i < 3; i++ {
-		if color2[i] < 0 || color2[i] > orig[i] {
-			t.Errorf("AO chan %d = %v, want in [0, %v]", i, color2[i], orig[i])
+		if color[i] < 0 || color[i] > orig[i] {
+			t.Errorf("AOBlur chan %d = %v, want in [0, %v]", i, color[i], orig[i])
 		}
 	}
 }
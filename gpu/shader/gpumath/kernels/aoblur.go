@@ -0,0 +1,45 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import . "poly.red/gpu/shader/gpumath"
+
+// AOBlur is the bilateral blur of the ambient occlusion pass (render/ssao.go),
+// authored once: it runs as Go on the CPU and its source (AOBlurSrc) compiles
+// to the GPU. It averages the factors of AO over the 4x4 pixels of its
+// rotation pattern, weighted by how close their view-space depth is to the
+// one of the fragment, which keeps the edges sharp, and darkens the shaded
+// color in place. au is the same as for AO.
+func AOBlur(gid uint, viewpos []float32, aoflag []float32, ao []float32, color []float32, au []float32) {
+	if aoflag[gid] < 0.5 {
+		return
+	}
+	width := int(au[0])
+	height := int(au[1])
+	radius := au[2]
+	y := int(gid) / width
+	x := int(gid) - y*width
+	zc := viewpos[gid*4+2]
+	sum := float32(0)
+	wsum := float32(0)
+	for j := -2; j < 2; j++ {
+		for i := -2; i < 2; i++ {
+			sx := x + i
+			sy := y + j
+			if sx >= 0 && sx < width && sy >= 0 && sy < height {
+				k := sy*width + sx
+				if aoflag[k] > 0.5 {
+					w := Clampf(1.0-Absf(viewpos[k*4+2]-zc)/radius, 0.0, 1.0)
+					sum = sum + ao[k]*w
+					wsum = wsum + w
+				}
+			}
+		}
+	}
+	f := sum / wsum
+	color[gid*4] = Floor(Clampf(Round(color[gid*4]), 0.0, 255.0) * f)
+	color[gid*4+1] = Floor(Clampf(Round(color[gid*4+1]), 0.0, 255.0) * f)
+	color[gid*4+2] = Floor(Clampf(Round(color[gid*4+2]), 0.0, 255.0) * f)
+}
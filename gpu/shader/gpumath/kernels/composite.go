@@ -0,0 +1,83 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import . "poly.red/gpu/shader/gpumath"
+
+// Composite is the blend-mode stage of the transparent pass
+// (render/transparent.go), authored once: it runs as Go on the CPU and its
+// source (CompositeSrc) compiles to the GPU. Unlike OIT, the blend modes do
+// not commute in general, so it composites the fragments of a pixel one by one
+// in the order given, back to front.
+//
+// ranges holds [offset, count] of the fragments of each pixel in frags, whose
+// stride is 5: [r, g, b, alpha, mode] with the color premultiplied in [0, 1]
+// and mode a color.BlendMode. dst holds the premultiplied color of each pixel
+// that the fragments are composited over, and out receives the result, both
+// with stride 4 in [0, 1].
+func Composite(gid uint, ranges []float32, frags []float32, dst []float32, out []float32) {
+	offset := int(ranges[gid*2])
+	count := int(ranges[gid*2+1])
+	r := dst[gid*4]
+	g := dst[gid*4+1]
+	b := dst[gid*4+2]
+	a := dst[gid*4+3]
+	for i := 0; i < count; i++ {
+		k := (offset + i) * 5
+		sa := frags[k+3]
+		mode := int(frags[k+4] + 0.5)
+		r = BlendChannel(mode, frags[k], r, sa, a)
+		g = BlendChannel(mode, frags[k+1], g, sa, a)
+		b = BlendChannel(mode, frags[k+2], b, sa, a)
+		a = BlendChannel(mode, sa, a, sa, a)
+	}
+	out[gid*4] = r
+	out[gid*4+1] = g
+	out[gid*4+2] = b
+	out[gid*4+3] = a
+}
+
+// BlendChannel blends a premultiplied source channel s of alpha sa with a
+// premultiplied destination channel d of alpha da. It mirrors
+// color.BlendChannel, with the modes numbered as color.BlendMode.
+//
+//gpu:helper
+func BlendChannel(mode int, s, d, sa, da float32) float32 {
+	v := s + d*(1.0-sa)
+	if mode == 1 {
+		v = 0.0
+	} else if mode == 2 {
+		v = s
+	} else if mode == 3 {
+		v = d
+	} else if mode == 4 {
+		v = s*(1.0-da) + d
+	} else if mode == 5 {
+		v = s * da
+	} else if mode == 6 {
+		v = d * sa
+	} else if mode == 7 {
+		v = s * (1.0 - da)
+	} else if mode == 8 {
+		v = d * (1.0 - sa)
+	} else if mode == 9 {
+		v = s*da + d*(1.0-sa)
+	} else if mode == 10 {
+		v = s*(1.0-da) + d*sa
+	} else if mode == 11 {
+		v = s*(1.0-da) + d*(1.0-sa)
+	} else if mode == 12 {
+		v = s*d + s*(1.0-da) + d*(1.0-sa)
+	} else if mode == 13 {
+		v = s + d - s*d
+	} else if mode == 14 {
+		v = Minf(s*da, d*sa) + s*(1.0-da) + d*(1.0-sa)
+	} else if mode == 15 {
+		v = Maxf(s*da, d*sa) + s*(1.0-da) + d*(1.0-sa)
+	} else if mode == 16 {
+		v = Minf(s+d, 1.0)
+	}
+	return v
+}
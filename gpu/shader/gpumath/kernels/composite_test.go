@@ -0,0 +1,69 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import (
+	"math"
+	"testing"
+
+	"poly.red/color"
+)
+
+// TestComposite checks the author-once Composite kernel run as Go: its
+// blend modes match color.BlendChannel, and the fragments of a pixel are
+// composited in the given order.
+func TestComposite(t *testing.T) {
+	vs := []float32{0, 0.25, 0.5, 1}
+	for mode := color.BlendSrcOver; mode <= color.BlendAdd; mode++ {
+		for _, sa := range vs {
+			for _, da := range vs {
+				for _, s := range vs {
+					for _, d := range vs {
+						// Premultiplied channels do not exceed their alpha.
+						s, d := s*sa, d*da
+						got := BlendChannel(int(mode), s, d, sa, da)
+						want := color.BlendChannel(mode, s, d, sa, da)
+						if math.Abs(float64(got-want)) > 1e-6 {
+							t.Fatalf("%v(%v, %v, %v, %v): got %v, want %v", mode, s, d, sa, da, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// A half transparent red multiplied over green, then white added.
+	frags := []float32{
+		0.5, 0, 0, 0.5, float32(color.BlendMultiply),
+		1, 1, 1, 1, float32(color.BlendAdd),
+	}
+	ranges := []float32{
+		0, 0, // no fragment
+		0, 1, // multiply
+		0, 2, // multiply, add
+	}
+	dst := []float32{0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1}
+	out := make([]float32, 3*4)
+	for i := 0; i < 3; i++ {
+		Composite(uint(i), ranges, frags, dst, out)
+	}
+	eq := func(a, b []float32) bool {
+		for i := range a {
+			if math.Abs(float64(a[i]-b[i])) > 1e-6 {
+				return false
+			}
+		}
+		return true
+	}
+	if got := out[0:4]; !eq(got, dst[0:4]) {
+		t.Errorf("empty pixel: got %v, want %v", got, dst[0:4])
+	}
+	if got := out[4:8]; !eq(got, []float32{0, 0.5, 0, 1}) {
+		t.Errorf("multiply: got %v, want [0 0.5 0 1]", got)
+	}
+	if got := out[8:12]; !eq(got, []float32{1, 1, 1, 1}) {
+		t.Errorf("multiply then add: got %v, want [1 1 1 1]", got)
+	}
+}
@@ -27,3 +27,49 @@ var ShadowSrc string
 //
 //go:embed ao.go
 var AOSrc string
+
+// AOBlurSrc is the source of aoblur.go (the bilateral blur of the ambient
+// occlusion pass).
+//
+//go:embed aoblur.go
+var AOBlurSrc string
+
+// OITSrc is the source of oit.go (the transparent pass resolve).
+//
+//go:embed oit.go
+var OITSrc string
+
+// CompositeSrc is the source of composite.go (the blend modes of the
+// transparent pass).
+//
+//go:embed composite.go
+var CompositeSrc string
+
+// FXAASrc is the source of fxaa.go (the fast approximate antialiasing
+// pass).
+//
+//go:embed fxaa.go
+var FXAASrc string
+
+// TAASrc is the source of taa.go (the temporal antialiasing pass).
+//
+//go:embed taa.go
+var TAASrc string
+
+// GBufferSrc is the source of gbuffer.go (the unpacking of the GPU forward
+// pass's render targets for the deferred passes).
+//
+//go:embed gbuffer.go
+var GBufferSrc string
+
+// SampleSrc is the source of sample.go (the sampling of the material
+// textures).
+//
+//go:embed sample.go
+var SampleSrc string
+
+// ForwardSrc is the source of forward.go (the vertex and fragment stages of
+// the GPU forward pass).
+//
+//go:embed forward.go
+var ForwardSrc string
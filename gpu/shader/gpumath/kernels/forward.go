@@ -0,0 +1,123 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import . "poly.red/gpu/shader/gpumath"
+
+// ForwardVaryings is the output of the vertex stage of the GPU forward pass,
+// interpolated for its fragment stages. Pos is in clip space; World, Normal
+// and Tangent are the world position, normal and tangent (xyz, handedness w)
+// of the vertex, UV its texture coordinates and Mat the material index of its
+// triangle.
+type ForwardVaryings struct {
+	Pos     Vec4 `gpu:"position"`
+	World   Vec3
+	Normal  Vec3
+	Tangent Vec4
+	UV      Vec2
+	Mat     float32 `gpu:"flat"`
+}
+
+// ForwardTargets are the four RGBA32Float render targets of the G-buffer of
+// the GPU forward pass: the world position and depth, the unit world normal
+// and material index, the texture coordinates and their squared screen space
+// derivatives (u, v, du, dv), and the world tangent and handedness.
+type ForwardTargets struct {
+	WP Vec4
+	N  Vec4
+	UV Vec4
+	T  Vec4
+}
+
+// Forward is the vertex stage of the GPU forward pass, authored once: its
+// source (ForwardSrc) compiles to the GPU with ForwardGBuffer,
+// ForwardCoverage and ForwardPeel. It transforms the model position of vertex vid by the
+// column-major matrix m to clip space, with x, y and w negated as the w of
+// the renderer's projection is. The depth of the renderer grows towards the
+// camera, whereas the depth test keeps the least depth: z is left as is, so
+// that the negated w flips the depth and the nearest fragment has the least.
+// The world position, normal and tangent and the uv are
+// computed on the CPU, as the forward pass of the CPU does, and pass
+// through; pos, wpos, wnor and wtan hold 4 floats per vertex, uv 2 and mid 1.
+//
+//gpu:vertex
+func Forward(vid uint, pos []float32, wpos []float32, wnor []float32, mid []float32, uv []float32, m []float32, wtan []float32) ForwardVaryings {
+	i := vid * 4
+	p := V4(pos[i], pos[i+1], pos[i+2], pos[i+3])
+	t := M4(V4(m[0], m[1], m[2], m[3]), V4(m[4], m[5], m[6], m[7]), V4(m[8], m[9], m[10], m[11]), V4(m[12], m[13], m[14], m[15]))
+	return ForwardVaryings{
+		Pos:     t.MulV(p).Mul(V4(-1.0, -1.0, 1.0, -1.0)),
+		World:   V3(wpos[i], wpos[i+1], wpos[i+2]),
+		Normal:  V3(wnor[i], wnor[i+1], wnor[i+2]),
+		Tangent: V4(wtan[i], wtan[i+1], wtan[i+2], wtan[i+3]),
+		UV:      V2(uv[vid*2], uv[vid*2+1]),
+		Mat:     mid[vid],
+	}
+}
+
+// ForwardGBuffer is the fragment stage of the GPU forward pass that writes
+// the G-buffer (ForwardTargets) of the front faces. The depth is remapped
+// from the [0, 1] of the depth buffer to the [-1, 1] of the CPU and flipped
+// back, and the uv derivatives give the level of detail the CPU derives for
+// mipmapping.
+//
+//gpu:fragment
+func ForwardGBuffer(in ForwardVaryings) ForwardTargets {
+	if !FrontFacing() {
+		Discard()
+	}
+	n := in.Normal.Normalize()
+	dx := Dfdx(in.UV)
+	dy := Dfdy(in.UV)
+	return ForwardTargets{
+		WP: V4(in.World.X, in.World.Y, in.World.Z, 1.0-in.Pos.Z*2.0),
+		N:  V4(n.X, n.Y, n.Z, in.Mat),
+		UV: V4(in.UV.X, in.UV.Y, dx.Dot(dx), dy.Dot(dy)),
+		T:  in.Tangent,
+	}
+}
+
+// ForwardCoverage is the fragment stage of the multisampled coverage pass
+// of the GPU forward pass: every covered sample of a front face writes
+// white.
+//
+//gpu:fragment
+func ForwardCoverage(in ForwardVaryings) Vec4 {
+	if !FrontFacing() {
+		Discard()
+	}
+	return V4(1.0, 1.0, 1.0, 1.0)
+}
+
+// ForwardPeel is the fragment stage of the GPU forward pass that peels a
+// layer of the transparent primitives: it writes the G-buffer of the front
+// faces as ForwardGBuffer does, but discards the fragments at or in front of
+// the layer peeled before and those behind the opaque scene, so that the
+// depth test keeps the nearest of the others. peel holds the width of the
+// targets, then for every pixel, in the row order of its position, the depth
+// of the layer peeled before and that of the opaque scene, both in the depth
+// of the CPU. The buffers of Forward precede peel so that its binding
+// follows theirs.
+//
+//gpu:fragment
+func ForwardPeel(in ForwardVaryings, pos []float32, wpos []float32, wnor []float32, mid []float32, uv []float32, m []float32, wtan []float32, peel []float32) ForwardTargets {
+	if !FrontFacing() {
+		Discard()
+	}
+	d := 1.0 - in.Pos.Z*2.0
+	i := (int(in.Pos.Y)*int(peel[0]) + int(in.Pos.X)) * 2
+	if d >= peel[i+1] || d <= peel[i+2] {
+		Discard()
+	}
+	n := in.Normal.Normalize()
+	dx := Dfdx(in.UV)
+	dy := Dfdy(in.UV)
+	return ForwardTargets{
+		WP: V4(in.World.X, in.World.Y, in.World.Z, d),
+		N:  V4(n.X, n.Y, n.Z, in.Mat),
+		UV: V4(in.UV.X, in.UV.Y, dx.Dot(dx), dy.Dot(dy)),
+		T:  in.Tangent,
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import (
+	"testing"
+
+	. "poly.red/gpu/shader/gpumath"
+)
+
+// TestForward checks the author-once forward stages run as Go: the vertex
+// stage negates the transformed position but its depth and passes the CPU's
+// attributes through, and the G-buffer fragment remaps the depth and
+// normalizes the normal.
+func TestForward(t *testing.T) {
+	pos := []float32{0, 0, 0, 1, 1, 2, 3, 1}
+	wpos := []float32{0, 0, 0, 1, 4, 5, 6, 1}
+	wnor := []float32{0, 0, 1, 0, 0, 3, 4, 0}
+	wtan := []float32{1, 0, 0, 1, 0, 1, 0, -1}
+	uv := []float32{0, 0, 0.25, 0.75}
+	mid := []float32{2, 2}
+	// Scales by 2 and translates by (1, 1, 1), column-major.
+	m := []float32{2, 0, 0, 0, 0, 2, 0, 0, 0, 0, 2, 0, 1, 1, 1, 1}
+
+	v := Forward(1, pos, wpos, wnor, mid, uv, m, wtan)
+	if want := V4(-3, -5, 7, -1); v.Pos != want {
+		t.Errorf("Pos = %v, want %v", v.Pos, want)
+	}
+	if v.World != V3(4, 5, 6) || v.Normal != V3(0, 3, 4) || v.Tangent != V4(0, 1, 0, -1) {
+		t.Errorf("attributes = %v %v %v", v.World, v.Normal, v.Tangent)
+	}
+	if v.UV != V2(0.25, 0.75) || v.Mat != 2 {
+		t.Errorf("UV = %v, Mat = %v", v.UV, v.Mat)
+	}
+
+	v.Pos.Z = 0.25 // the window depth of the fragment
+	g := ForwardGBuffer(v)
+	if want := V4(4, 5, 6, 0.5); g.WP != want {
+		t.Errorf("WP = %v, want %v", g.WP, want)
+	}
+	if want := V4(0, 0.6, 0.8, 2); g.N != want {
+		t.Errorf("N = %v, want %v", g.N, want)
+	}
+	if want := V4(0.25, 0.75, 0, 0); g.UV != want {
+		t.Errorf("UV = %v, want %v", g.UV, want)
+	}
+	if g.T != v.Tangent {
+		t.Errorf("T = %v, want %v", g.T, v.Tangent)
+	}
+
+	// The fragment of pixel (1, 0) of a 2 pixels wide target lies between
+	// the layer peeled before and the opaque scene, so it is peeled.
+	v.Pos.X, v.Pos.Y = 1.5, 0.5
+	peel := []float32{2, 1, -1, 1, 0}
+	if p := ForwardPeel(v, pos, wpos, wnor, mid, uv, m, wtan, peel); p != g {
+		t.Errorf("ForwardPeel = %v, want %v", p, g)
+	}
+}
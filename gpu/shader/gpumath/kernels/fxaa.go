@@ -0,0 +1,196 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import . "poly.red/gpu/shader/gpumath"
+
+// FXAA is the fast approximate antialiasing pass (render/antialias.go),
+// authored once: it runs as Go on the CPU and its source (FXAASrc) compiles
+// to the GPU. It follows the quality variant of Lottes' FXAA 3.11 on the
+// final image: a pixel whose luma contrast with its neighbors is below the
+// threshold is kept, otherwise the direction of the edge through it is found
+// from the 3x3 luma, the edge is followed on both sides until its end, and
+// the pixel is blended with its neighbor across the edge by how close it is
+// to the end, or by the subpixel contrast if that is larger.
+//
+// in and out hold the RGBA color of each pixel in [0, 1], in gamma space.
+// fu = [width, height, contrast threshold, relative threshold, subpixel
+// blending].
+func FXAA(gid uint, in []float32, out []float32, fu []float32) {
+	width := int(fu[0])
+	height := int(fu[1])
+	y := int(gid) / width
+	x := int(gid) - y*width
+	k := int(gid) * 4
+	out[k] = in[k]
+	out[k+1] = in[k+1]
+	out[k+2] = in[k+2]
+	out[k+3] = in[k+3]
+
+	lc := FXAALuma(in[k], in[k+1], in[k+2])
+	i := FXAAIndex(x, y-1, width, height) * 4
+	ld := FXAALuma(in[i], in[i+1], in[i+2])
+	i = FXAAIndex(x, y+1, width, height) * 4
+	lu := FXAALuma(in[i], in[i+1], in[i+2])
+	i = FXAAIndex(x-1, y, width, height) * 4
+	ll := FXAALuma(in[i], in[i+1], in[i+2])
+	i = FXAAIndex(x+1, y, width, height) * 4
+	lr := FXAALuma(in[i], in[i+1], in[i+2])
+	lmin := Minf(lc, Minf(Minf(ld, lu), Minf(ll, lr)))
+	lmax := Maxf(lc, Maxf(Maxf(ld, lu), Maxf(ll, lr)))
+	contrast := lmax - lmin
+	if contrast < Maxf(fu[2], lmax*fu[3]) {
+		return
+	}
+
+	i = FXAAIndex(x-1, y-1, width, height) * 4
+	ldl := FXAALuma(in[i], in[i+1], in[i+2])
+	i = FXAAIndex(x+1, y+1, width, height) * 4
+	lur := FXAALuma(in[i], in[i+1], in[i+2])
+	i = FXAAIndex(x+1, y-1, width, height) * 4
+	ldr := FXAALuma(in[i], in[i+1], in[i+2])
+	i = FXAAIndex(x-1, y+1, width, height) * 4
+	lul := FXAALuma(in[i], in[i+1], in[i+2])
+
+	// The edge is horizontal if the luma changes more across the rows
+	// than across the columns.
+	edgeH := Absf(ldl+lul-2.0*ll) + 2.0*Absf(ld+lu-2.0*lc) + Absf(ldr+lur-2.0*lr)
+	edgeV := Absf(lul+lur-2.0*lu) + 2.0*Absf(ll+lr-2.0*lc) + Absf(ldl+ldr-2.0*ld)
+	horizontal := 0
+	luma1 := ll
+	luma2 := lr
+	if edgeH >= edgeV {
+		horizontal = 1
+		luma1 = ld
+		luma2 = lu
+	}
+
+	// The side of the edge with the steepest gradient is across it.
+	grad1 := luma1 - lc
+	grad2 := luma2 - lc
+	dir := 1
+	average := 0.5 * (luma2 + lc)
+	if Absf(grad1) >= Absf(grad2) {
+		dir = -1
+		average = 0.5 * (luma1 + lc)
+	}
+	threshold := 0.25 * Maxf(Absf(grad1), Absf(grad2))
+
+	// Follow the edge on both sides, between the pixel rows (or columns)
+	// on both sides of it, until the luma leaves its average.
+	ax := 0
+	ay := 1
+	sx := dir
+	sy := 0
+	if horizontal == 1 {
+		ax = 1
+		ay = 0
+		sx = 0
+		sy = dir
+	}
+	dist1 := float32(0)
+	dist2 := float32(0)
+	end1 := float32(0)
+	end2 := float32(0)
+	done1 := 0
+	done2 := 0
+	for s := 0; s < 12; s++ {
+		d := FXAAStep(s)
+		if done1 == 0 {
+			dist1 = dist1 + d
+			px := x - ax*int(dist1)
+			py := y - ay*int(dist1)
+			i = FXAAIndex(px, py, width, height) * 4
+			j := FXAAIndex(px+sx, py+sy, width, height) * 4
+			end1 = 0.5*(FXAALuma(in[i], in[i+1], in[i+2])+FXAALuma(in[j], in[j+1], in[j+2])) - average
+			if Absf(end1) >= threshold {
+				done1 = 1
+			}
+		}
+		if done2 == 0 {
+			dist2 = dist2 + d
+			px := x + ax*int(dist2)
+			py := y + ay*int(dist2)
+			i = FXAAIndex(px, py, width, height) * 4
+			j := FXAAIndex(px+sx, py+sy, width, height) * 4
+			end2 = 0.5*(FXAALuma(in[i], in[i+1], in[i+2])+FXAALuma(in[j], in[j+1], in[j+2])) - average
+			if Absf(end2) >= threshold {
+				done2 = 1
+			}
+		}
+	}
+
+	// Blend towards the neighbor across the edge by how close the pixel
+	// is to the nearer end, if the luma there varies in the direction that
+	// makes the pixel part of the other side.
+	end := end2
+	dist := dist2
+	if dist1 < dist2 {
+		end = end1
+		dist = dist1
+	}
+	blend := float32(0)
+	if (end < 0.0 && lc >= average) || (end >= 0.0 && lc < average) {
+		blend = 0.5 - dist/(dist1+dist2)
+	}
+
+	// Subpixel aliasing: blend by the contrast of the pixel with the
+	// average of its 3x3 neighborhood.
+	lavg := (2.0*(ld+lu+ll+lr) + ldl + ldr + lul + lur) / 12.0
+	sub := Clampf(Absf(lavg-lc)/contrast, 0.0, 1.0)
+	sub = (-2.0*sub + 3.0) * sub * sub
+	blend = Maxf(blend, sub*sub*fu[4])
+
+	j := FXAAIndex(x+sx, y+sy, width, height) * 4
+	out[k] = in[k] + (in[j]-in[k])*blend
+	out[k+1] = in[k+1] + (in[j+1]-in[k+1])*blend
+	out[k+2] = in[k+2] + (in[j+2]-in[k+2])*blend
+}
+
+// FXAALuma is the luma of an RGB color, the weights of Rec. 601.
+//
+//gpu:helper
+func FXAALuma(r, g, b float32) float32 {
+	return 0.299*r + 0.587*g + 0.114*b
+}
+
+// FXAAIndex is the index of the pixel (x, y) clamped to the image.
+//
+//gpu:helper
+func FXAAIndex(x, y, width, height int) int {
+	cx := x
+	if cx < 0 {
+		cx = 0
+	}
+	if cx > width-1 {
+		cx = width - 1
+	}
+	cy := y
+	if cy < 0 {
+		cy = 0
+	}
+	if cy > height-1 {
+		cy = height - 1
+	}
+	return cy*width + cx
+}
+
+// FXAAStep is the length of the s-th step of the edge search, in pixels:
+// the search strides further once it is away from the pixel.
+//
+//gpu:helper
+func FXAAStep(s int) float32 {
+	d := float32(1)
+	if s >= 5 {
+		d = 2.0
+	}
+	if s >= 10 {
+		d = 4.0
+	}
+	if s >= 11 {
+		d = 8.0
+	}
+	return d
+}
@@ -0,0 +1,61 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import "testing"
+
+// TestFXAA checks the author-once FXAA kernel run as Go on a white
+// triangle over black whose hypotenuse is a staircase: the pixels away from
+// the edge are kept, the pixels of the staircase are blended with their
+// neighbors across it, and the alpha is kept.
+func TestFXAA(t *testing.T) {
+	const w, h = 16, 16
+	in := make([]float32, w*h*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			k := (y*w + x) * 4
+			in[k+3] = 1
+			// A slope of 1/3, which leaves long steps.
+			if 3*y < x {
+				in[k], in[k+1], in[k+2] = 1, 1, 1
+			}
+		}
+	}
+	fu := []float32{w, h, 0.0312, 0.125, 0.75}
+	out := make([]float32, w*h*4)
+	for i := 0; i < w*h; i++ {
+		FXAA(uint(i), in, out, fu)
+	}
+
+	blended := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			k := (y*w + x) * 4
+			if out[k+3] != 1 {
+				t.Fatalf("pixel (%d, %d): alpha %v, want 1", x, y, out[k+3])
+			}
+			if out[k] < 0 || out[k] > 1 {
+				t.Fatalf("pixel (%d, %d): %v is out of [0, 1]", x, y, out[k])
+			}
+			near := false
+			for j := y - 1; j <= y+1; j++ {
+				for i := x - 1; i <= x+1; i++ {
+					if i >= 0 && i < w && j >= 0 && j < h && (3*j < i) != (3*y < x) {
+						near = true
+					}
+				}
+			}
+			if !near && out[k] != in[k] {
+				t.Fatalf("pixel (%d, %d) away from the edge: got %v, want %v", x, y, out[k], in[k])
+			}
+			if out[k] > 0 && out[k] < 1 {
+				blended++
+			}
+		}
+	}
+	if blended == 0 {
+		t.Fatal("no pixel of the edge is blended")
+	}
+}
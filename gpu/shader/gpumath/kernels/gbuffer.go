@@ -0,0 +1,115 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import . "poly.red/gpu/shader/gpumath"
+
+// GBuffer unpacks the render targets of the GPU forward pass into the
+// inputs of the deferred (Shade), shadow (Shadow) and ambient occlusion (AO,
+// AOBlur) kernels, authored once: it runs as Go on the CPU and its source
+// (GBufferSrc) compiles to the GPU, where it reads the targets in place so
+// that the G-buffer never leaves the device between the passes.
+//
+// wp holds the world position and depth of each pixel, nr its normal and
+// material index, or a negative index if no fragment covers the pixel, and
+// uv its texture coordinates and their screen space derivatives. Each
+// material is 16 floats: [receive shadow, ambient occlusion, texture, size,
+// basecol.rgba, surface (8 floats)], where basecol is in [0, 255], surface
+// is the per-fragment surface of Shade, texture is the index of the texture
+// of the material in the atlas of Sample or -1 if it has none, and size is
+// the width of the texture, or 0 if it does not use mipmap. gu = [width,
+// height, _, _, view matrix, screen to view matrix] with column-major
+// matrices.
+//
+// The outputs are in the layouts of Shade (normals, worldpos, basecol,
+// matidx, surface), Shadow (fragxyz, recv) and AO (viewpos, viewnor,
+// aoflag) and Sample (uvl), where matidx indexes the same material table.
+// A textured material leaves basecol to Sample, which samples its texture
+// at the level of detail of the CPU's deferred pass. A pixel without a
+// fragment gets a basecol alpha of -1, which Shade passes through to the
+// alpha of its output, and no texture, shadow or ambient occlusion.
+func GBuffer(gid uint, wp Image, nr Image, uv Image, mats []float32, gu []float32, normals []float32, worldpos []float32, basecol []float32, matidx []float32, surface []float32, fragxyz []float32, recv []float32, viewpos []float32, viewnor []float32, aoflag []float32, uvl []float32) {
+	width := int(gu[0])
+	y := int(gid) / width
+	x := int(gid) - y*width
+	k := int(gid) * 4
+	s := int(gid) * 8
+	p := wp.Load(x, y)
+	n := nr.Load(x, y)
+
+	for i := 0; i < 4; i++ {
+		normals[k+i] = 0.0
+		worldpos[k+i] = 0.0
+		basecol[k+i] = 0.0
+		fragxyz[k+i] = 0.0
+		viewpos[k+i] = 0.0
+		viewnor[k+i] = 0.0
+		uvl[k+i] = 0.0
+		surface[s+i] = 0.0
+		surface[s+4+i] = 0.0
+	}
+	matidx[gid] = 0.0
+	recv[gid] = 0.0
+	aoflag[gid] = 0.0
+	basecol[k+3] = -1.0
+	uvl[k+3] = -1.0
+	if n.W < -0.5 {
+		return
+	}
+
+	mi := int(Floor(n.W + 0.5))
+	m := mi * 16
+	matidx[gid] = float32(mi)
+	normals[k] = n.X
+	normals[k+1] = n.Y
+	normals[k+2] = n.Z
+	worldpos[k] = p.X
+	worldpos[k+1] = p.Y
+	worldpos[k+2] = p.Z
+	worldpos[k+3] = 1.0
+	for i := 0; i < 4; i++ {
+		basecol[k+i] = mats[m+4+i]
+		surface[s+i] = mats[m+8+i]
+		surface[s+4+i] = mats[m+12+i]
+	}
+	fragxyz[k] = float32(x)
+	fragxyz[k+1] = float32(y)
+	fragxyz[k+2] = p.W
+	recv[gid] = mats[m]
+	aoflag[gid] = mats[m+1]
+	if mats[m+2] > -0.5 {
+		t := uv.Load(x, y)
+		lod := float32(0)
+		if mats[m+3] > 0.0 {
+			lod = Log2(Maxf(mats[m+3]*Sqrt(Maxf(t.Z, t.W)), 1.0))
+		}
+		uvl[k] = t.X
+		uvl[k+1] = 1.0 - t.Y
+		uvl[k+2] = lod
+		uvl[k+3] = mats[m+2]
+	}
+
+	view := M4(
+		V4(gu[4], gu[5], gu[6], gu[7]),
+		V4(gu[8], gu[9], gu[10], gu[11]),
+		V4(gu[12], gu[13], gu[14], gu[15]),
+		V4(gu[16], gu[17], gu[18], gu[19]),
+	)
+	screenToView := M4(
+		V4(gu[20], gu[21], gu[22], gu[23]),
+		V4(gu[24], gu[25], gu[26], gu[27]),
+		V4(gu[28], gu[29], gu[30], gu[31]),
+		V4(gu[32], gu[33], gu[34], gu[35]),
+	)
+	vp := screenToView.MulV(V4(float32(x), float32(y), p.W, 1.0))
+	viewpos[k] = vp.X / vp.W
+	viewpos[k+1] = vp.Y / vp.W
+	viewpos[k+2] = vp.Z / vp.W
+	viewpos[k+3] = 1.0
+	vn := view.MulV(V4(n.X, n.Y, n.Z, 0.0)).Normalize()
+	viewnor[k] = vn.X
+	viewnor[k+1] = vn.Y
+	viewnor[k+2] = vn.Z
+}
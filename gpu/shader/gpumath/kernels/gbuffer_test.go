@@ -0,0 +1,71 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import (
+	"testing"
+
+	. "poly.red/gpu/shader/gpumath"
+)
+
+// TestGBuffer checks the author-once GBuffer kernel run as Go on a 2x1
+// G-buffer: the covered pixel takes the attributes of its material and the
+// view-space position by the screen to view matrix, its texture is sampled
+// at the level of detail of its derivatives, and the uncovered one is marked
+// by a negative basecol alpha without texture, shadow or ambient occlusion.
+func TestGBuffer(t *testing.T) {
+	wp := Image{Width: 2, Height: 1, Pix: []float32{
+		1, 2, 3, 0.5, // world position, depth
+		0, 0, 0, 0,
+	}}
+	nr := Image{Width: 2, Height: 1, Pix: []float32{
+		0, 0, 2, 1, // normal, material 1
+		0, 0, 0, -2, // no fragment
+	}}
+	uv := Image{Width: 2, Height: 1, Pix: []float32{
+		0.25, 0.75, 1, 4, // uv, du, dv
+		0.5, 0.5, 0, 0,
+	}}
+	mats := make([]float32, 32)
+	copy(mats[16:], []float32{1, 1, 3, 4, 10, 20, 30, 255, 0.5, 0.25, 1, 0, 7, 8, 9, 0})
+	identity := []float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+	gu := append([]float32{2, 1, 0, 0}, identity...)
+	gu = append(gu, 2, 0, 0, 0, 0, 2, 0, 0, 0, 0, 2, 0, 0, 0, 0, 2) // w = 2
+
+	n := 2
+	normals, worldpos, basecol := make([]float32, n*4), make([]float32, n*4), make([]float32, n*4)
+	matidx, surface, fragxyz := make([]float32, n), make([]float32, n*8), make([]float32, n*4)
+	recv, viewpos, viewnor, aoflag := make([]float32, n), make([]float32, n*4), make([]float32, n*4), make([]float32, n)
+	uvl := make([]float32, n*4)
+	for gid := uint(0); gid < uint(n); gid++ {
+		GBuffer(gid, wp, nr, uv, mats, gu, normals, worldpos, basecol, matidx, surface, fragxyz, recv, viewpos, viewnor, aoflag, uvl)
+	}
+
+	eq := func(name string, got, want []float32) {
+		t.Helper()
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s = %v, want %v", name, got, want)
+				return
+			}
+		}
+	}
+	eq("normals", normals[:4], []float32{0, 0, 2, 0})
+	eq("worldpos", worldpos[:4], []float32{1, 2, 3, 1})
+	eq("basecol", basecol[:4], []float32{10, 20, 30, 255})
+	eq("matidx", matidx[:1], []float32{1})
+	eq("surface", surface[:8], []float32{0.5, 0.25, 1, 0, 7, 8, 9, 0})
+	eq("fragxyz", fragxyz[:3], []float32{0, 0, 0.5})
+	eq("recv", recv[:1], []float32{1})
+	eq("aoflag", aoflag[:1], []float32{1})
+	eq("viewpos", viewpos[:4], []float32{0, 0, 0.5, 1})
+	eq("viewnor", viewnor[:4], []float32{0, 0, 1, 0})
+	eq("uvl", uvl[:4], []float32{0.25, 0.25, 3, 3}) // lod = log2(4*sqrt(4))
+
+	if basecol[7] != -1 || uvl[7] != -1 || recv[1] != 0 || aoflag[1] != 0 || viewpos[7] != 0 {
+		t.Errorf("uncovered pixel: basecol alpha %v, texture %v, recv %v, aoflag %v, viewpos w %v, want -1, -1, 0, 0, 0",
+			basecol[7], uvl[7], recv[1], aoflag[1], viewpos[7])
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import . "poly.red/gpu/shader/gpumath"
+
+// OIT is the resolve of the transparent pass (render/transparent.go), authored
+// once: it runs as Go on the CPU and its source (OITSrc) compiles to the GPU. It
+// blends the transparent fragments of a pixel with weighted blended
+// order-independent transparency (McGuire and Bavoil 2013), which needs no
+// sorting: the colors are averaged with weights that decrease with the distance
+// to the camera, and the coverage is one minus the product of the
+// transmittances.
+//
+// ranges holds [offset, count] of the fragments of each pixel in frags, whose
+// stride is 5: [r, g, b, alpha, distance] with the straight color in [0, 1].
+// out receives the transparent layer of the pixel as a premultiplied color and
+// its coverage, in [0, 1].
+func OIT(gid uint, ranges []float32, frags []float32, out []float32) {
+	offset := int(ranges[gid*2])
+	count := int(ranges[gid*2+1])
+	r := float32(0)
+	g := float32(0)
+	b := float32(0)
+	wsum := float32(0)
+	reveal := float32(1)
+	for i := 0; i < count; i++ {
+		k := (offset + i) * 5
+		a := frags[k+3]
+		w := OITWeight(frags[k+4], a)
+		r = r + frags[k]*w
+		g = g + frags[k+1]*w
+		b = b + frags[k+2]*w
+		wsum = wsum + w
+		reveal = reveal * (1.0 - a)
+	}
+	coverage := 1.0 - reveal
+	if wsum > 0.00001 {
+		out[gid*4] = r / wsum * coverage
+		out[gid*4+1] = g / wsum * coverage
+		out[gid*4+2] = b / wsum * coverage
+	} else {
+		out[gid*4] = 0.0
+		out[gid*4+1] = 0.0
+		out[gid*4+2] = 0.0
+	}
+	out[gid*4+3] = coverage
+}
+
+// OITWeight is the weight of a fragment with alpha a at distance z from the
+// camera, equation (7) of McGuire and Bavoil: near fragments dominate the
+// average, and the clamp keeps the sums in the float range.
+//
+//gpu:helper
+func OITWeight(z, a float32) float32 {
+	return a * Clampf(10.0/(0.00001+Pow(z/5.0, 2.0)+Pow(z/200.0, 6.0)), 0.01, 3000.0)
+}
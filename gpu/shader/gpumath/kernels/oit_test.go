@@ -0,0 +1,50 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import (
+	"math"
+	"testing"
+)
+
+// TestOIT checks the author-once OIT kernel run as Go: a pixel without
+// fragments is empty, a single fragment is its premultiplied color, and the
+// resolve of several fragments does not depend on their order.
+func TestOIT(t *testing.T) {
+	near := []float32{1, 0, 0, 0.5, 1}
+	far := []float32{0, 0, 1, 0.5, 3}
+	frags := append(append(append([]float32{}, near...), far...), near...)
+	ranges := []float32{
+		0, 0, // no fragment
+		0, 1, // near
+		0, 2, // near, far
+		1, 2, // far, near
+	}
+	out := make([]float32, 4*4)
+	for i := 0; i < 4; i++ {
+		OIT(uint(i), ranges, frags, out)
+	}
+
+	eq := func(a, b []float32) bool {
+		for i := range a {
+			if math.Abs(float64(a[i]-b[i])) > 1e-6 {
+				return false
+			}
+		}
+		return true
+	}
+	if got := out[0:4]; !eq(got, []float32{0, 0, 0, 0}) {
+		t.Errorf("empty pixel: got %v", got)
+	}
+	if got := out[4:8]; !eq(got, []float32{0.5, 0, 0, 0.5}) {
+		t.Errorf("single fragment: got %v, want [0.5 0 0 0.5]", got)
+	}
+	if !eq(out[8:12], out[12:16]) {
+		t.Errorf("order dependent resolve: %v != %v", out[8:12], out[12:16])
+	}
+	if got := out[8:12]; got[3] != 0.75 || got[0] <= got[2] {
+		t.Errorf("two fragments: got %v, want coverage 0.75 dominated by the near fragment", got)
+	}
+}
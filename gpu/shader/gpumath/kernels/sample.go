@@ -0,0 +1,153 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import . "poly.red/gpu/shader/gpumath"
+
+// Sample samples the material textures of the fragments, authored once: it
+// runs as Go on the CPU and its source (SampleSrc) compiles to the GPU. It
+// transliterates buffer.Texture.Query, including its wrap, its level
+// selection and its truncation to 8 bits at every blend, so that it returns
+// the very colors of Query.
+//
+// atlas holds the mipmap levels of all textures, 4 floats in [0, 255] per
+// texel, row by row. texs describes the textures: 4 floats per texture,
+// [level count, mipmap, first level, _], where mipmap is 0 if the texture
+// samples level 0 by nearest texel, then 4 floats per level, [offset of the
+// level in atlas, width, height, _]. uvl = [u, v, lod, texture] per
+// fragment, with v already flipped to the row order of the texture (1-V),
+// and a negative texture for a fragment without texture. out receives the
+// RGBA of each textured fragment and keeps its value for the others.
+func Sample(gid uint, atlas []float32, texs []float32, uvl []float32, out []float32) {
+	k := int(gid) * 4
+	if uvl[k+3] < -0.5 {
+		return
+	}
+	t := int(Floor(uvl[k+3]+0.5)) * 4
+	u := SampleWrap(uvl[k])
+	v := SampleWrap(uvl[k+1])
+	lod := uvl[k+2]
+	levels := int(texs[t])
+	first := int(texs[t+2])
+
+	// The levels to blend, h and h+1 if nl is 2, by the weight p.
+	h := 0
+	nl := 1
+	p := float32(0)
+	nearest := 0
+	if texs[t+1] < 0.5 {
+		nearest = 1
+	} else {
+		if lod < 0.0 {
+			lod = 0.0
+		} else if lod >= float32(levels) {
+			lod = float32(levels - 1)
+		}
+		if lod > 1.0 {
+			lod = lod - 1.0
+			h = int(Floor(lod))
+			if h+1 < levels {
+				p = lod - float32(h)
+				if Absf(p) > 1e-7 {
+					nl = 2
+				}
+			}
+		}
+	}
+
+	for li := 0; li < nl; li++ {
+		e := (first + h + li) * 4
+		o := int(texs[e])
+		dx := int(texs[e+1])
+		dy := int(texs[e+2])
+		x := u * (float32(dx) - 1.0)
+		y := v * (float32(dy) - 1.0)
+		x0 := Floor(x)
+		y0 := Floor(y)
+		i := int(x0)
+		j := int(y0)
+		fx := x - x0
+		fy := y - y0
+		if nearest == 1 {
+			fx = 0.0
+			fy = 0.0
+		}
+
+		// The taps of the bilinear blend, falling back to (i, j) at the
+		// last row and column as Query does.
+		t1 := SampleTexel(i, j, dx, dy)
+		t2 := t1
+		t3 := t1
+		t4 := t1
+		if i < dx-1 {
+			t2 = SampleTexel(i+1, j, dx, dy)
+		}
+		if j < dy-1 {
+			t3 = SampleTexel(i, j+1, dx, dy)
+		}
+		if i < dx-1 && j < dy-1 {
+			t4 = SampleTexel(i+1, j+1, dx, dy)
+		}
+		for c := 0; c < 4; c++ {
+			p1 := float32(0)
+			p2 := float32(0)
+			p3 := float32(0)
+			p4 := float32(0)
+			if t1 >= 0 {
+				p1 = atlas[o+t1+c]
+			}
+			if t2 >= 0 {
+				p2 = atlas[o+t2+c]
+			}
+			if t3 >= 0 {
+				p3 = atlas[o+t3+c]
+			}
+			if t4 >= 0 {
+				p4 = atlas[o+t4+c]
+			}
+			col := SampleLerp(SampleLerp(p1, p2, fx), SampleLerp(p3, p4, fx), fy)
+			if li == 0 {
+				out[k+c] = col
+			} else {
+				out[k+c] = SampleLerp(out[k+c], col, p)
+			}
+		}
+	}
+}
+
+// SampleWrap wraps a texture coordinate as Query does: to its fractional
+// part, 1 for a nonzero integer, and 1-u for a negative fraction.
+//
+//gpu:helper
+func SampleWrap(u float32) float32 {
+	f := u - Trunc(u)
+	if Trunc(u) != 0.0 && f == 0.0 {
+		f = 1.0
+	}
+	if f < 0.0 {
+		f = 1.0 - f
+	}
+	return f
+}
+
+// SampleTexel is the offset of the texel (i, j) in a level of dx by dy
+// texels, or -1 outside the level, where Query reads a zero color.
+//
+//gpu:helper
+func SampleTexel(i, j, dx, dy int) int {
+	o := -1
+	if i >= 0 && i < dx && j >= 0 && j < dy {
+		o = (j*dx + i) * 4
+	}
+	return o
+}
+
+// SampleLerp blends two 8-bit channels as math.LerpC does, truncating the
+// result to an integer.
+//
+//gpu:helper
+func SampleLerp(from, to, t float32) float32 {
+	return Trunc(from + t*(to-from))
+}
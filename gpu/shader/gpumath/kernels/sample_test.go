@@ -0,0 +1,40 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import "testing"
+
+// TestSample checks the author-once Sample kernel run as Go on a 2x2
+// texture with a 1x1 second level: a bilinear blend truncating at every
+// step, a blend of the two levels, the wrap of a negative coordinate onto
+// the last column, and a fragment without texture that keeps its color.
+// The bit-exact parity with buffer.Texture.Query is tested in render.
+func TestSample(t *testing.T) {
+	var atlas []float32
+	for _, c := range []float32{0, 100, 200, 255, 50} {
+		atlas = append(atlas, c, c, c, c)
+	}
+	texs := []float32{
+		2, 1, 1, 0, // two levels, mipmap, first level
+		0, 2, 2, 0, // level 0
+		16, 1, 1, 0, // level 1
+	}
+	uvl := []float32{
+		0.5, 0.5, 0, 0,
+		0.5, 0.5, 1.5, 0,
+		-0.5, 0, 0, 0,
+		0.5, 0.5, 0, -1,
+	}
+	out := make([]float32, len(uvl))
+	out[12] = 7
+	for gid := uint(0); gid < 4; gid++ {
+		Sample(gid, atlas, texs, uvl, out)
+	}
+	for i, want := range []float32{138, 94, 100, 7} {
+		if out[i*4] != want {
+			t.Errorf("sample %d = %v, want %v", i, out[i*4], want)
+		}
+	}
+}
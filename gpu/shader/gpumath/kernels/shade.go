@@ -9,44 +9,264 @@ package kernels
 
 import . "poly.red/gpu/shader/gpumath"
 
-// Shade is the deferred Blinn-Phong shading kernel, authored once. It runs as Go
-// on the CPU (call it per element) and its source (ShadeSrc) compiles to the GPU.
-// Inputs are storage buffers; scene = [CamPos.xyz, _, AmbientI, NumLights].
-func Shade(gid uint, normals []float32, worldpos []float32, basecol []float32, lights []float32, matidx []float32, materials []float32, scene []float32, out []float32) {
+// Shade is the deferred shading kernel, authored once. It runs as Go on the CPU
+// (call it per element) and its source (ShadeSrc) compiles to the GPU. Inputs are
+// storage buffers; scene = [CamPos.xyz, _, AmbientI, NumLights]. Each light is 16
+// floats: [type, pos-or-dir.xyzw, color.rgba, intensity, spotdir.xyz, cosInner,
+// cosOuter, range] where type is 0 point, 1 directional and 2 spot, or [3,
+// center.xyzw, color.rgba, intensity, u.xyz, v.xyz] for an area light that spans
+// center +- u +- v and emits towards v x u.
+//
+// Each material is 10 floats: [diffuse.rgba, specular.rgba, shininess, type]
+// where type is 0 for Blinn-Phong and 1 for a metallic-roughness material shaded
+// by CookTorrance. The latter reads the per-fragment surface = [metallic,
+// roughness, occlusion, _, emissive.rgb, _], i.e. its factors multiplied by its
+// texture maps, and uses basecol as its base color.
+//
+// env holds the prefiltered maps of an environment map (light.EnvMap) as
+// [levels, intensity, _, _], levels+1 entries [offset, width, height, _] of the
+// irradiance map followed by the specular levels, and their RGB pixels at the
+// offsets into env. The environment map lights the scene if levels > 0.
+func Shade(gid uint, normals []float32, worldpos []float32, basecol []float32, lights []float32, matidx []float32, materials []float32, surface []float32, scene []float32, env []float32, out []float32) {
 	N := V4(normals[gid*4], normals[gid*4+1], normals[gid*4+2], normals[gid*4+3])
 	wpos := V4(worldpos[gid*4], worldpos[gid*4+1], worldpos[gid*4+2], worldpos[gid*4+3])
 	col := V4(basecol[gid*4], basecol[gid*4+1], basecol[gid*4+2], basecol[gid*4+3])
 	mi := int(matidx[gid])
-	diffuse := V4(materials[mi*9], materials[mi*9+1], materials[mi*9+2], materials[mi*9+3])
-	specular := V4(materials[mi*9+4], materials[mi*9+5], materials[mi*9+6], materials[mi*9+7])
-	shininess := materials[mi*9+8]
+	diffuse := V4(materials[mi*10], materials[mi*10+1], materials[mi*10+2], materials[mi*10+3])
+	specular := V4(materials[mi*10+4], materials[mi*10+5], materials[mi*10+6], materials[mi*10+7])
+	shininess := materials[mi*10+8]
+	pbr := materials[mi*10+9]
+	metallic := surface[gid*8]
+	roughness := surface[gid*8+1]
+	base := col.Div(255.0)
 	camPos := V4(scene[0], scene[1], scene[2], scene[3])
 	ambientI := scene[4]
 	count := int(scene[5])
 	acc := col.Scale(ambientI)
+	if pbr > 0.5 {
+		acc = col.Scale(ambientI * surface[gid*8+2]).Add(V4(surface[gid*8+4], surface[gid*8+5], surface[gid*8+6], 0))
+	}
+	levels := int(env[0])
+	if levels > 0 {
+		// Image based light: bilinear lookups of the irradiance around N
+		// and of the two specular levels of the nearest roughnesses around
+		// the reflected view direction, as light.EnvMap does.
+		V := Normalize(camPos.Sub(wpos))
+		R := N.Scale(2.0 * Dot(N, V)).Sub(V)
+		rough := Pow(2.0/(Maxf(shininess, 0.0)+2.0), 0.25)
+		if pbr > 0.5 {
+			rough = roughness
+		}
+		lf := Clampf(rough, 0.0, 1.0) * float32(levels-1)
+		l0 := int(Floor(lf))
+		l1 := l0 + 1
+		if l1 > levels-1 {
+			l1 = levels - 1
+		}
+		lw := lf - float32(l0)
+		irr := V4(0.0, 0.0, 0.0, 0.0)
+		rad := V4(0.0, 0.0, 0.0, 0.0)
+		for j := 0; j < 3; j++ {
+			m := 0
+			d := N
+			wj := 1.0 - lw
+			if j == 1 {
+				m = 1 + l0
+				d = R
+			} else if j == 2 {
+				m = 1 + l1
+				d = R
+				wj = lw
+			}
+			off := int(env[4+m*4])
+			ew := int(env[5+m*4])
+			eh := int(env[6+m*4])
+			uv := EquirectUV(d)
+			fx := uv.X*float32(ew) - 0.5
+			fy := Clampf(uv.Y*float32(eh)-0.5, 0.0, float32(eh-1))
+			x0f := Floor(fx)
+			y0f := Floor(fy)
+			tx := fx - x0f
+			ty := fy - y0f
+			x0 := int(x0f)
+			y0 := int(y0f)
+			if x0 < 0 {
+				x0 = x0 + ew
+			}
+			if x0 >= ew {
+				x0 = x0 - ew
+			}
+			x1 := x0 + 1
+			if x1 >= ew {
+				x1 = x1 - ew
+			}
+			y1 := y0 + 1
+			if y1 >= eh {
+				y1 = eh - 1
+			}
+			k00 := off + (y0*ew+x0)*3
+			k10 := off + (y0*ew+x1)*3
+			k01 := off + (y1*ew+x0)*3
+			k11 := off + (y1*ew+x1)*3
+			c := V4(env[k00], env[k00+1], env[k00+2], 0.0).Scale((1.0 - tx) * (1.0 - ty))
+			c = c.Add(V4(env[k10], env[k10+1], env[k10+2], 0.0).Scale(tx * (1.0 - ty)))
+			c = c.Add(V4(env[k01], env[k01+1], env[k01+2], 0.0).Scale((1.0 - tx) * ty))
+			c = c.Add(V4(env[k11], env[k11+1], env[k11+2], 0.0).Scale(tx * ty))
+			if j == 0 {
+				irr = c.Scale(env[1])
+			} else {
+				rad = rad.Add(c.Scale(wj * env[1]))
+			}
+		}
+		if pbr > 0.5 {
+			occlusion := surface[gid*8+2]
+			ab := EnvBRDF(Clampf(Dot(N, V), 0.0, 1.0), roughness)
+			F0 := V4(0.04, 0.04, 0.04, 0.0).Scale(1.0 - metallic).Add(base.Scale(metallic))
+			spec := F0.Scale(ab.X).Add(V4(ab.Y, ab.Y, ab.Y, 0.0))
+			acc = acc.Add(col.Mul(irr).Scale((1.0 - metallic) * occlusion)).Add(rad.Mul(spec).Scale(255.0 * occlusion))
+		} else {
+			acc = acc.Add(col.Mul(irr)).Add(specular.Mul(rad))
+		}
+	}
 	for i := 0; i < count; i++ {
-		lt := lights[i*10]
-		lp := V4(lights[i*10+1], lights[i*10+2], lights[i*10+3], lights[i*10+4])
-		lc := V4(lights[i*10+5], lights[i*10+6], lights[i*10+7], lights[i*10+8])
-		li := lights[i*10+9]
-		var L Vec4
-		var I float32
-		if lt < 0.5 {
-			Ldir := lp.Sub(wpos)
-			L = Normalize(Ldir)
-			I = li / Length(Ldir)
+		lt := lights[i*16]
+		lp := V4(lights[i*16+1], lights[i*16+2], lights[i*16+3], lights[i*16+4])
+		lc := V4(lights[i*16+5], lights[i*16+6], lights[i*16+7], lights[i*16+8])
+		li := lights[i*16+9]
+		if lt > 2.5 {
+			// Area light: the same grid of light.AreaSamples^2 point samples
+			// as light.Area.Samples, each emitting from the front side.
+			ue := V4(lights[i*16+10], lights[i*16+11], lights[i*16+12], 0)
+			ve := V4(lights[i*16+13], lights[i*16+14], lights[i*16+15], 0)
+			ad := Normalize(V4(ve.Y*ue.Z-ve.Z*ue.Y, ve.Z*ue.X-ve.X*ue.Z, ve.X*ue.Y-ve.Y*ue.X, 0))
+			V := Normalize(camPos.Sub(wpos))
+			for sj := 0; sj < 4; sj++ {
+				for si := 0; si < 4; si++ {
+					su := (2.0*float32(si)+1.0)/4.0 - 1.0
+					sv := (2.0*float32(sj)+1.0)/4.0 - 1.0
+					Ldir := lp.Add(ue.Scale(su)).Add(ve.Scale(sv)).Sub(wpos)
+					dist := Length(Ldir)
+					L := Normalize(Ldir)
+					I := li / dist * Clampf(-Dot(L, ad), 0.0, 1.0) / 16.0
+					if pbr > 0.5 {
+						acc = acc.Add(CookTorrance(N, L, V, base, metallic, roughness).Mul(lc).Scale(I))
+					} else {
+						H := Normalize(L.Add(V))
+						Ld := Clampf(Dot(N, L), 0.0, 1.0)
+						Ls := Pow(Clampf(Dot(N, H), 0.0, 1.0), shininess)
+						acc = acc.Add(diffuse.Mul(col.Scale(Ld * I)).Div(255.0)).Add(specular.Mul(lc.Scale(Ls * I)).Div(255.0))
+					}
+				}
+			}
 		} else {
-			L = V4(-lp.X, -lp.Y, -lp.Z, 0)
-			I = li
+			var L Vec4
+			var I float32
+			if lt < 0.5 {
+				Ldir := lp.Sub(wpos)
+				L = Normalize(Ldir)
+				I = li / Length(Ldir)
+			} else if lt < 1.5 {
+				L = V4(-lp.X, -lp.Y, -lp.Z, 0)
+				I = li
+			} else {
+				Ldir := lp.Sub(wpos)
+				dist := Length(Ldir)
+				L = Normalize(Ldir)
+				sd := V4(lights[i*16+10], lights[i*16+11], lights[i*16+12], 0)
+				I = li / dist * SpotFalloff(-Dot(L, sd), lights[i*16+13], lights[i*16+14], dist, lights[i*16+15])
+			}
+			V := Normalize(camPos.Sub(wpos))
+			if pbr > 0.5 {
+				acc = acc.Add(CookTorrance(N, L, V, base, metallic, roughness).Mul(lc).Scale(I))
+			} else {
+				H := Normalize(L.Add(V))
+				Ld := Clampf(Dot(N, L), 0.0, 1.0)
+				Ls := Pow(Clampf(Dot(N, H), 0.0, 1.0), shininess)
+				acc = acc.Add(diffuse.Mul(col.Scale(Ld * I)).Div(255.0)).Add(specular.Mul(lc.Scale(Ls * I)).Div(255.0))
+			}
 		}
-		V := Normalize(camPos.Sub(wpos))
-		H := Normalize(L.Add(V))
-		Ld := Clampf(Dot(N, L), 0.0, 1.0)
-		Ls := Pow(Clampf(Dot(N, H), 0.0, 1.0), shininess)
-		acc = acc.Add(diffuse.Mul(col.Scale(Ld * I)).Div(255.0)).Add(specular.Mul(lc.Scale(Ls * I)).Div(255.0))
 	}
 	out[gid*4] = acc.X
 	out[gid*4+1] = acc.Y
 	out[gid*4+2] = acc.Z
 	out[gid*4+3] = col.W
 }
+
+// SpotFalloff is the cone and range attenuation of a spot light, the same
+// formula as light.Spot.Attenuation: a smooth step from the outer to the inner
+// cone, windowed to reach zero at rng (rng = 0 means unlimited).
+//
+//gpu:helper
+func SpotFalloff(cosTheta, cosInner, cosOuter, dist, rng float32) float32 {
+	t := Clampf((cosTheta-cosOuter)/Maxf(cosInner-cosOuter, 0.0001), 0.0, 1.0)
+	a := t * t * (3.0 - 2.0*t)
+	if rng > 0.0 {
+		r := dist / rng
+		w := Clampf(1.0-r*r*r*r, 0.0, 1.0)
+		a = a * w * w
+	}
+	return a
+}
+
+// CookTorrance is the reflectance of a metallic-roughness material with base
+// color base in [0, 1] towards V for light incident from L, the same formula as
+// shader.PBRFragmentShader: a Lambertian diffuse term plus a GGX distribution,
+// a Smith-Schlick geometry term and a Schlick Fresnel term, multiplied by the
+// cosine of the incident angle. The light intensity is scaled by pi, such that a
+// rough dielectric reflects as much as the Blinn-Phong diffuse term.
+//
+//gpu:helper
+func CookTorrance(N, L, V, base Vec4, metallic, roughness float32) Vec4 {
+	H := Normalize(L.Add(V))
+	NdotL := Clampf(Dot(N, L), 0.0, 1.0)
+	NdotV := Clampf(Dot(N, V), 0.0001, 1.0)
+	NdotH := Clampf(Dot(N, H), 0.0, 1.0)
+	VdotH := Clampf(Dot(V, H), 0.0, 1.0)
+	r := Clampf(roughness, 0.045, 1.0)
+	a2 := r * r * r * r
+	d := NdotH*NdotH*(a2-1.0) + 1.0
+	D := a2 / (3.14159265 * d * d)
+	k := (r + 1.0) * (r + 1.0) / 8.0
+	G := NdotV / (NdotV*(1.0-k) + k) * NdotL / (NdotL*(1.0-k) + k)
+	fw := Pow(1.0-VdotH, 5.0)
+	F0 := V4(0.04, 0.04, 0.04, 0.0).Scale(1.0 - metallic).Add(base.Scale(metallic))
+	F := F0.Scale(1.0 - fw).Add(V4(fw, fw, fw, 0.0))
+	spec := F.Scale(3.14159265 * D * G / (4.0*NdotV*NdotL + 0.0001))
+	kd := V4(1.0-F.X, 1.0-F.Y, 1.0-F.Z, 0.0).Scale(1.0 - metallic)
+	return kd.Mul(base).Add(spec).Scale(NdotL)
+}
+
+// EquirectUV returns the coordinates of the unit direction d in an
+// equirectangular image as (u, v, 0, 0), the same mapping as light.EquirectUV.
+//
+//gpu:helper
+func EquirectUV(d Vec4) Vec4 {
+	a := float32(0)
+	if d.Z < 0.0 {
+		a = Atan(d.X / -d.Z)
+	} else if d.Z > 0.0 {
+		if d.X >= 0.0 {
+			a = Atan(d.X/-d.Z) + 3.14159265
+		} else {
+			a = Atan(d.X/-d.Z) - 3.14159265
+		}
+	} else if d.X > 0.0 {
+		a = 1.57079633
+	} else if d.X < 0.0 {
+		a = -1.57079633
+	}
+	return V4(0.5+a/(2.0*3.14159265), Acos(Clampf(d.Y, -1.0, 1.0))/3.14159265, 0.0, 0.0)
+}
+
+// EnvBRDF returns (A, B, 0, 0) of the split-sum approximation of the specular
+// reflectance F0*A + B, the same fit as shader.EnvBRDF.
+//
+//gpu:helper
+func EnvBRDF(NdotV, roughness float32) Vec4 {
+	r0 := -1.0*roughness + 1.0
+	r1 := -0.0275*roughness + 0.0425
+	r2 := -0.572*roughness + 1.04
+	r3 := 0.022*roughness - 0.04
+	a004 := Minf(r0*r0, Pow(2.0, -9.28*NdotV))*r0 + r1
+	return V4(-1.04*a004+r2, 1.04*a004+r3, 0.0, 0.0)
+}
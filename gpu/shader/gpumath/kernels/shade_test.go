@@ -0,0 +1,31 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import "testing"
+
+// TestSpotFalloff checks the spot light cone and range attenuation run as Go:
+// full inside the inner cone, zero outside the outer cone and beyond the range,
+// and a monotonic smooth step in between.
+func TestSpotFalloff(t *testing.T) {
+	const cosInner, cosOuter = 0.9, 0.7
+	if got := SpotFalloff(0.95, cosInner, cosOuter, 1, 0); got != 1 {
+		t.Errorf("inside inner cone = %v, want 1", got)
+	}
+	if got := SpotFalloff(0.5, cosInner, cosOuter, 1, 0); got != 0 {
+		t.Errorf("outside outer cone = %v, want 0", got)
+	}
+	if got := SpotFalloff(0.95, cosInner, cosOuter, 5, 4); got != 0 {
+		t.Errorf("beyond range = %v, want 0", got)
+	}
+	prev := float32(0)
+	for c := float32(0.7); c <= 0.9; c += 0.02 {
+		got := SpotFalloff(c, cosInner, cosOuter, 1, 0)
+		if got < prev || got < 0 || got > 1 {
+			t.Fatalf("falloff at cos %v = %v, want monotonic in [0, 1] (prev %v)", c, got, prev)
+		}
+		prev = got
+	}
+}
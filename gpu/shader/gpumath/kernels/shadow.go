@@ -9,8 +9,14 @@ import . "poly.red/gpu/shader/gpumath"
 // Shadow multiplies each shaded color by a shadow factor computed from N shadow
 // maps, authored once: it runs as Go on the CPU and its source (ShadowSrc)
 // compiles to the GPU. It runs in place after Shade over the shaded float buffer.
-// su = [width, depthLen, n, _]; mats holds N column-major light view-projection
-// matrices; depths holds N shadow maps of depthLen each.
+// su = [width, depthLen, n, filter, kernel, _, _, _] where filter is 0 (hard),
+// 1 (PCF) or 2 (PCSS) and kernel is the filter radius in texels; mats holds N
+// column-major light view-projection matrices, each followed by the index of
+// the light owning the map, the weight of the map and two padding floats (20
+// floats per map); depths holds N shadow maps of depthLen each. Maps of the same
+// light (the six faces of a point light cube map) are consecutive and darken a
+// fragment at most once. Weighted maps (the points of an area light) sum their
+// weighted occlusion instead, which averages the visibility over the light.
 func Shadow(gid uint, fragxyz []float32, recv []float32, depths []float32, mats []float32, color []float32, su []float32) {
 	if recv[gid] < 0.5 {
 		return
@@ -19,32 +25,103 @@ func Shadow(gid uint, fragxyz []float32, recv []float32, depths []float32, mats
 	fy := fragxyz[gid*4+1]
 	fz := fragxyz[gid*4+2]
 	occ := float32(0)
+	hit := float32(0)
+	owner := float32(-1)
 	width := int(su[0])
 	dl := int(su[1])
 	n := int(su[2])
+	filter := int(su[3])
+	K := int(su[4])
+	height := dl / width
 	for k := 0; k < n; k++ {
+		if mats[k*20+16] != owner {
+			occ = occ + hit
+			hit = 0.0
+			owner = mats[k*20+16]
+		}
 		M := M4(
-			V4(mats[k*16], mats[k*16+1], mats[k*16+2], mats[k*16+3]),
-			V4(mats[k*16+4], mats[k*16+5], mats[k*16+6], mats[k*16+7]),
-			V4(mats[k*16+8], mats[k*16+9], mats[k*16+10], mats[k*16+11]),
-			V4(mats[k*16+12], mats[k*16+13], mats[k*16+14], mats[k*16+15]),
+			V4(mats[k*20], mats[k*20+1], mats[k*20+2], mats[k*20+3]),
+			V4(mats[k*20+4], mats[k*20+5], mats[k*20+6], mats[k*20+7]),
+			V4(mats[k*20+8], mats[k*20+9], mats[k*20+10], mats[k*20+11]),
+			V4(mats[k*20+12], mats[k*20+13], mats[k*20+14], mats[k*20+15]),
 		)
 		clip := M.MulV(V4(fx, fy, fz, 1))
 		sx := clip.X / clip.W
 		sy := clip.Y / clip.W
 		sz := clip.Z / clip.W
-		idx := int(sx) + int(sy)*width
-		if idx > 0 {
-			if idx < dl {
-				if sz < depths[k*dl+idx]-0.03 {
-					occ = occ + 1
+		// Outside of the light frustum is not covered by the map: lit.
+		if sx >= 0.0 && sx < float32(width) && sy >= 0.0 && sy < float32(height) && sz >= -1.0 && sz <= 1.0 {
+			cx := int(sx)
+			cy := int(sy)
+
+			// The filter radius in texels: none for hard shadows, the kernel
+			// for PCF, and the penumbra of the average blocker for PCSS.
+			radius := float32(0)
+			if filter == 1 {
+				radius = float32(K)
+			}
+			if filter == 2 {
+				blockers := float32(0)
+				bsum := float32(0)
+				for i := -K; i <= K; i++ {
+					for j := -K; j <= K; j++ {
+						x := cx + i
+						y := cy + j
+						if x >= 0 && x < width && y >= 0 && y < height {
+							d := depths[k*dl+x+y*width]
+							if sz < d-0.03 {
+								blockers = blockers + 1.0
+								bsum = bsum + d
+							}
+						}
+					}
+				}
+				if blockers > 0.0 {
+					radius = PenumbraRadius(sz, bsum/blockers, float32(K))
+				}
+			}
+
+			R := int(radius + 0.5)
+			taken := float32(0)
+			occluded := float32(0)
+			for i := -K; i <= K; i++ {
+				for j := -K; j <= K; j++ {
+					x := cx + i
+					y := cy + j
+					if i >= -R && i <= R && j >= -R && j <= R && x >= 0 && x < width && y >= 0 && y < height {
+						taken = taken + 1.0
+						if sz < depths[k*dl+x+y*width]-0.03 {
+							occluded = occluded + 1.0
+						}
+					}
+				}
+			}
+			if taken > 0.0 {
+				weight := mats[k*20+17]
+				if weight > 0.0 {
+					hit = hit + weight*occluded/taken
+				} else {
+					hit = Maxf(hit, occluded/taken)
 				}
 			}
 		}
 	}
+	occ = occ + hit
 	wf := Pow(0.5, occ)
 	// Match the engine: uint8(clamp(round(blinn),0,255) * w), truncated.
 	color[gid*4] = Floor(Clampf(Round(color[gid*4]), 0.0, 255.0) * wf)
 	color[gid*4+1] = Floor(Clampf(Round(color[gid*4+1]), 0.0, 255.0) * wf)
 	color[gid*4+2] = Floor(Clampf(Round(color[gid*4+2]), 0.0, 255.0) * wf)
 }
+
+// PenumbraRadius is the PCSS filter radius in shadow map texels for a receiver
+// at depth zr behind a blocker at depth zb, both in the [-1, 1] shadow map depth
+// range where 1 is the near plane. The penumbra grows with the distance between
+// them relative to the blocker distance, and is limited to kernel.
+//
+//gpu:helper
+func PenumbraRadius(zr, zb, kernel float32) float32 {
+	dr := (1.0 - zr) * 0.5
+	db := (1.0 - zb) * 0.5
+	return Clampf(kernel*(dr-db)/Maxf(db, 0.001), 0.0, kernel)
+}
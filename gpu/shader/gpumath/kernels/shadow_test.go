@@ -12,7 +12,7 @@ import "testing"
 func TestShadow(t *testing.T) {
 	// recv = 0: fragment does not receive shadow, color unchanged.
 	color := []float32{200, 150, 100, 255}
-	Shadow(0, []float32{0, 0, 0, 0}, []float32{0}, []float32{0}, []float32{0}, color, []float32{4, 0, 0, 0})
+	Shadow(0, []float32{0, 0, 0, 0}, []float32{0}, []float32{0}, []float32{0}, color, []float32{4, 0, 0, 0, 0, 0, 0, 0})
 	if color[0] != 200 || color[1] != 150 || color[2] != 100 {
 		t.Errorf("recv=0 should leave color unchanged, got %v", color[:3])
 	}
@@ -20,7 +20,7 @@ func TestShadow(t *testing.T) {
 	// recv = 1, n = 0 maps: occ = 0, factor = pow(0.5,0) = 1; color is quantized
 	// via floor(clamp(round(c),0,255)).
 	color2 := []float32{200.4, 149.6, 100, 255}
-	su := []float32{4, 0, 0, 0} // width=4, depthLen=0, n=0
+	su := []float32{4, 0, 0, 0, 0, 0, 0, 0} // width=4, depthLen=0, n=0
 	Shadow(0, []float32{0, 0, 0, 0}, []float32{1}, []float32{0}, []float32{0}, color2, su)
 	want := []float32{200, 150, 100}
 	for i := 0; i < 3; i++ {
@@ -29,3 +29,87 @@ func TestShadow(t *testing.T) {
 		}
 	}
 }
+
+// TestShadowMapOwner checks that shadow maps of the same light (the faces of a
+// point light cube map) darken a fragment once, while maps of different lights
+// darken it once each, and weighted maps of the same light (the points of an
+// area light) average their occlusion.
+func TestShadowMapOwner(t *testing.T) {
+	// Identity matrices followed by the owning light index, the weight and
+	// padding.
+	mat := func(owner, weight float32) []float32 {
+		return []float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, owner, weight, 0, 0}
+	}
+	// 4x1 maps that record an occluder at depth 1 in front of the fragment
+	// at pixel (1, 0) with depth 0.5.
+	occluded := []float32{-1, 1, -1, -1}
+	empty := []float32{-1, -1, -1, -1}
+	frag := []float32{1, 0, 0.5, 0}
+	su := []float32{4, 4, 2, 0, 0, 0, 0, 0} // width=4, depthLen=4, n=2, hard
+
+	for _, tt := range []struct {
+		name   string
+		mats   []float32
+		depths []float32
+		want   float32
+	}{
+		{"same light", append(mat(0, 0), mat(0, 0)...), append(occluded, occluded...), 100},
+		{"two lights", append(mat(0, 0), mat(1, 0)...), append(occluded, occluded...), 50},
+		{"weighted", append(mat(0, 0.5), mat(0, 0.5)...), append(occluded, empty...), 141},
+	} {
+		color := []float32{200, 200, 200, 255}
+		Shadow(0, frag, []float32{1}, tt.depths, tt.mats, color, su)
+		if color[0] != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, color[0], tt.want)
+		}
+	}
+}
+
+// TestShadowFilter checks the PCF and PCSS filters against a 5x5 map that has
+// an occluder in its left two columns: a fragment next to the occluder edge is
+// fully shadowed by the hard filter and partially shadowed by the filters.
+func TestShadowFilter(t *testing.T) {
+	mats := []float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	depths := make([]float32, 25)
+	for i := range depths {
+		depths[i] = -1
+		if i%5 < 2 {
+			depths[i] = 0.8
+		}
+	}
+	frag := []float32{1.5, 2.5, 0, 0} // texel (1, 2), depth 0
+
+	for _, tt := range []struct {
+		name   string
+		filter float32
+		kernel float32
+		want   float32
+	}{
+		{"hard", 0, 1, 100},
+		// 3x3 kernel: 6 of 9 samples are occluded, 200 * 0.5^(2/3).
+		{"pcf", 1, 1, 125},
+		// The penumbra is 2*(0.5-0.1)/0.1 = 8 texels, limited by the kernel of
+		// 2 texels: 10 of the 20 samples of the 5x5 kernel inside of the map
+		// are occluded, 200 * 0.5^(1/2).
+		{"pcss", 2, 2, 141},
+	} {
+		color := []float32{200, 200, 200, 255}
+		su := []float32{5, 25, 1, tt.filter, tt.kernel, 0, 0, 0}
+		Shadow(0, frag, []float32{1}, depths, mats, color, su)
+		if color[0] != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, color[0], tt.want)
+		}
+	}
+}
+
+func TestPenumbraRadius(t *testing.T) {
+	if r := PenumbraRadius(0.5, 0.5, 4); r != 0 {
+		t.Errorf("contact: got %v, want 0", r)
+	}
+	if r := PenumbraRadius(0.2, 0.6, 8); r != 8 {
+		t.Errorf("got %v, want 8 (limited by kernel)", r)
+	}
+	if r := PenumbraRadius(0.6, 0.8, 8); r != 8*(0.2-0.1)/0.1 {
+		t.Errorf("got %v, want %v", r, 8*(0.2-0.1)/0.1)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import . "poly.red/gpu/shader/gpumath"
+
+// TAA is the temporal antialiasing pass (render/antialias.go), authored
+// once: it runs as Go on the CPU and its source (TAASrc) compiles to the GPU.
+// The frames are rendered with a subpixel camera jitter, and TAA blends the
+// color of the current frame into the history of the previous ones: a pixel
+// is reprojected to its position in the previous frame by its depth, the
+// history is sampled there bilinearly and clamped to the color range of the
+// 3x3 neighborhood of the pixel in the current frame, which rejects history
+// that the pixel no longer shows, and the current color is blended in by a
+// constant factor.
+//
+// cur, history and out hold the RGBA color of each pixel in [0, 1], depth
+// the depth of each pixel in [-1, 1]. tu = [width, height, blend factor,
+// whether the history is valid, _, _, _, _, reprojection (column-major)],
+// where the reprojection transforms the screen position and depth of a pixel
+// in the current frame to the screen position in the previous frame.
+func TAA(gid uint, cur []float32, depth []float32, history []float32, out []float32, tu []float32) {
+	width := int(tu[0])
+	height := int(tu[1])
+	y := int(gid) / width
+	x := int(gid) - y*width
+	k := int(gid) * 4
+	c := V4(cur[k], cur[k+1], cur[k+2], cur[k+3])
+	out[k] = c.X
+	out[k+1] = c.Y
+	out[k+2] = c.Z
+	out[k+3] = c.W
+	if tu[3] < 0.5 {
+		return
+	}
+
+	lo := c
+	hi := c
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 3; i++ {
+			n := TAAIndex(x+i-1, y+j-1, width, height) * 4
+			lo = V4(Minf(lo.X, cur[n]), Minf(lo.Y, cur[n+1]), Minf(lo.Z, cur[n+2]), Minf(lo.W, cur[n+3]))
+			hi = V4(Maxf(hi.X, cur[n]), Maxf(hi.Y, cur[n+1]), Maxf(hi.Z, cur[n+2]), Maxf(hi.W, cur[n+3]))
+		}
+	}
+
+	M := M4(
+		V4(tu[8], tu[9], tu[10], tu[11]),
+		V4(tu[12], tu[13], tu[14], tu[15]),
+		V4(tu[16], tu[17], tu[18], tu[19]),
+		V4(tu[20], tu[21], tu[22], tu[23]),
+	)
+	p := M.MulV(V4(float32(x), float32(y), depth[gid], 1.0))
+	px := p.X / p.W
+	py := p.Y / p.W
+	if px < 0.0 || py < 0.0 || px > float32(width-1) || py > float32(height-1) {
+		return
+	}
+	x0 := int(Floor(px))
+	y0 := int(Floor(py))
+	fx := px - float32(x0)
+	fy := py - float32(y0)
+	i00 := TAAIndex(x0, y0, width, height) * 4
+	i10 := TAAIndex(x0+1, y0, width, height) * 4
+	i01 := TAAIndex(x0, y0+1, width, height) * 4
+	i11 := TAAIndex(x0+1, y0+1, width, height) * 4
+	h := V4(history[i00], history[i00+1], history[i00+2], history[i00+3]).Scale((1.0 - fx) * (1.0 - fy))
+	h = h.Add(V4(history[i10], history[i10+1], history[i10+2], history[i10+3]).Scale(fx * (1.0 - fy)))
+	h = h.Add(V4(history[i01], history[i01+1], history[i01+2], history[i01+3]).Scale((1.0 - fx) * fy))
+	h = h.Add(V4(history[i11], history[i11+1], history[i11+2], history[i11+3]).Scale(fx * fy))
+	h = V4(Clampf(h.X, lo.X, hi.X), Clampf(h.Y, lo.Y, hi.Y), Clampf(h.Z, lo.Z, hi.Z), Clampf(h.W, lo.W, hi.W))
+
+	o := h.Add(c.Sub(h).Scale(tu[2]))
+	out[k] = o.X
+	out[k+1] = o.Y
+	out[k+2] = o.Z
+	out[k+3] = o.W
+}
+
+// TAAIndex is the index of the pixel (x, y) clamped to the image.
+//
+//gpu:helper
+func TAAIndex(x, y, width, height int) int {
+	cx := x
+	if cx < 0 {
+		cx = 0
+	}
+	if cx > width-1 {
+		cx = width - 1
+	}
+	cy := y
+	if cy < 0 {
+		cy = 0
+	}
+	if cy > height-1 {
+		cy = height - 1
+	}
+	return cy*width + cx
+}
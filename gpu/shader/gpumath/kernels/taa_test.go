@@ -0,0 +1,60 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kernels
+
+import (
+	"math"
+	"testing"
+)
+
+// TestTAA checks the author-once TAA kernel run as Go on a 3x3 gray image:
+// without history the current frame is kept, a history within the colors
+// of the neighborhood is blended in, a history outside of them is clamped
+// first, and a pixel reprojected off the screen keeps the current frame.
+func TestTAA(t *testing.T) {
+	const w, h = 3, 3
+	cur := make([]float32, w*h*4)
+	for i := 0; i < w*h; i++ {
+		cur[i*4], cur[i*4+1], cur[i*4+2], cur[i*4+3] = 0.5, 0.5, 0.5, 1
+	}
+	cur[0] = 0.3 // the red range of the neighborhood is [0.3, 0.5]
+	depth := make([]float32, w*h)
+	tu := func(valid float32, dx float32) []float32 {
+		// Translates the screen by dx pixels in x.
+		return []float32{w, h, 0.5, valid, 0, 0, 0, 0,
+			1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, dx, 0, 0, 1}
+	}
+	run := func(history, tu []float32) []float32 {
+		out := make([]float32, w*h*4)
+		for i := 0; i < w*h; i++ {
+			TAA(uint(i), cur, depth, history, out, tu)
+		}
+		return out
+	}
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-6 }
+
+	if out := run(make([]float32, w*h*4), tu(0, 0)); !near(out[4*4], 0.5) {
+		t.Errorf("no history: got %v, want 0.5", out[4*4])
+	}
+
+	history := make([]float32, w*h*4)
+	for i := range history {
+		history[i] = 0.4
+	}
+	if out := run(history, tu(1, 0)); !near(out[4*4], 0.45) || !near(out[4*4+1], 0.5) {
+		t.Errorf("history in range: got %v, want red 0.45 and green 0.5", out[4*4:4*4+4])
+	}
+
+	for i := range history {
+		history[i] = 0
+	}
+	if out := run(history, tu(1, 0)); !near(out[4*4], 0.4) || !near(out[4*4+1], 0.5) {
+		t.Errorf("history out of range: got %v, want red 0.4 and green 0.5", out[4*4:4*4+4])
+	}
+
+	if out := run(history, tu(1, 5)); !near(out[4*4], 0.5) {
+		t.Errorf("off the screen: got %v, want 0.5", out[4*4])
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// The author-once kernels on Vulkan: every kernel of the package compiles to
+// SPIR-V with shader.CompileSPIRV, runs through the Vulkan compute path and
+// must compute what it computes as Go on the same inputs. The arithmetic is
+// exact on both sides; the builtins that the driver implements (pow, sin,
+// normalize, ...) may differ in the last bits, hence the tolerance of the
+// kernels that call them. Verified in CI on Mesa lavapipe; gated on
+// POLYRED_VK_PROBE=1.
+package kernels
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"os"
+	"testing"
+
+	"poly.red/gpu"
+	"poly.red/gpu/shader"
+	. "poly.red/gpu/shader/gpumath"
+)
+
+// vkKernel is a kernel and its inputs: n invocations over the images and
+// buffers, in the order of its parameters. run calls the kernel as Go.
+type vkKernel struct {
+	name, src string
+	n         int
+	images    []Image
+	bufs      [][]float32
+	tol       float32 // the largest difference, relative to max(1, |want|)
+	run       func(gid uint, im []Image, b [][]float32)
+}
+
+// vkKernels returns the kernels of the package with inputs that take their
+// branches: covered and uncovered pixels, every light and material type,
+// edges for the antialiasing passes, and so on.
+func vkKernels() []vkKernel {
+	const W, H = 8, 6
+	const n = W * H
+	r := rand.New(rand.NewPCG(1, 19))
+	uniform := func(k int, lo, hi float32) []float32 {
+		v := make([]float32, k)
+		for i := range v {
+			v[i] = lo + (hi-lo)*r.Float32()
+		}
+		return v
+	}
+	integers := func(k, lo, hi int) []float32 {
+		v := make([]float32, k)
+		for i := range v {
+			v[i] = float32(lo + r.IntN(hi-lo+1))
+		}
+		return v
+	}
+	zeros := func(k int) []float32 { return make([]float32, k) }
+	mat := func(c0, c1, c2, c3 [4]float32) []float32 {
+		return append(append(append(c0[:], c1[:]...), c2[:]...), c3[:]...)
+	}
+	identity := mat([4]float32{1, 0, 0, 0}, [4]float32{0, 1, 0, 0}, [4]float32{0, 0, 1, 0}, [4]float32{0, 0, 0, 1})
+
+	// [offset, count] of up to 3 fragments per pixel.
+	ranges := func() ([]float32, int) {
+		rg, total := make([]float32, n*2), 0
+		for i := 0; i < n; i++ {
+			c := r.IntN(4)
+			rg[i*2], rg[i*2+1] = float32(total), float32(c)
+			total += c
+		}
+		return rg, total
+	}
+
+	// The view space of the ambient occlusion: a pixel (px, py) at depth z
+	// is at ((px-W/2)*-z/f, (py-H/2)*-z/f, z), which viewport*proj maps back.
+	const f = 4
+	viewpos, viewnor, aoflag := zeros(n*4), zeros(n*4), zeros(n)
+	for i := 0; i < n; i++ {
+		px, py := float32(i%W), float32(i/W)
+		z := -2 - 2*r.Float32()
+		viewpos[i*4], viewpos[i*4+1], viewpos[i*4+2], viewpos[i*4+3] = (px-W/2)*-z/f, (py-H/2)*-z/f, z, 1
+		nx, ny := r.Float32()-0.5, r.Float32()-0.5
+		viewnor[i*4], viewnor[i*4+1], viewnor[i*4+2] = nx, ny, 1
+		if r.IntN(5) > 0 {
+			aoflag[i] = 1
+		} else {
+			viewpos[i*4+3] = 0
+		}
+	}
+	au := append([]float32{W, H, 0.5, 8, 1, 0, 0, 0},
+		mat([4]float32{f, 0, 0, 0}, [4]float32{0, f, 0, 0}, [4]float32{-W / 2, -H / 2, 0, -1}, [4]float32{0, 0, 0, 0})...)
+
+	oitRanges, oitCount := ranges()
+	oitFrags := uniform(oitCount*5, 0, 1)
+	for i := 0; i < oitCount; i++ {
+		oitFrags[i*5+4] = 1 + 300*r.Float32()
+	}
+	compRanges, compCount := ranges()
+	compFrags := uniform(compCount*5, 0, 1)
+	for i := 0; i < compCount; i++ {
+		compFrags[i*5+4] = float32(r.IntN(17))
+	}
+
+	// The shadow map is the depth of every pixel, seen through the identity.
+	fragxyz := zeros(n * 4)
+	for i := 0; i < n; i++ {
+		fragxyz[i*4], fragxyz[i*4+1], fragxyz[i*4+2] = float32(i%W)+0.5, float32(i/W)+0.5, 2*r.Float32()-1
+	}
+	shadowMats := append(append([]float32(nil), identity...), 0, 0, 0, 0)
+
+	// A point, a directional, a spot and an area light, a Blinn-Phong and
+	// a metallic-roughness material, and an environment map of two
+	// specular levels of 4x2 texels.
+	lights := []float32{
+		0, 2, 3, 4, 1, 255, 200, 100, 255, 5, 0, 0, 0, 0, 0, 0,
+		1, -0.3, -1, -0.2, 0, 100, 255, 100, 255, 0.8, 0, 0, 0, 0, 0, 0,
+		2, 0, 4, 1, 1, 255, 255, 255, 255, 6, 0, -1, 0, 0.9, 0.7, 20,
+		3, 0, 3, -1, 1, 200, 200, 255, 255, 4, 1, 0, 0, 0, 0, 1,
+	}
+	materials := []float32{
+		200, 180, 160, 255, 120, 120, 120, 255, 32, 0,
+		255, 255, 255, 255, 0, 0, 0, 0, 0, 1,
+	}
+	env := []float32{2, 0.7, 0, 0, 16, 4, 2, 0, 40, 4, 2, 0, 64, 4, 2, 0}
+	env = append(env, uniform(3*4*2*3, 0, 1)...)
+	normals := zeros(n * 4)
+	for i := 0; i < n; i++ {
+		normals[i*4], normals[i*4+1], normals[i*4+2] = r.Float32()-0.5, 1, r.Float32()-0.5
+		l := float32(math.Sqrt(float64(normals[i*4]*normals[i*4] + 1 + normals[i*4+2]*normals[i*4+2])))
+		normals[i*4], normals[i*4+1], normals[i*4+2] = normals[i*4]/l, 1/l, normals[i*4+2]/l
+	}
+	basecol := uniform(n*4, 0, 255)
+	surface := uniform(n*8, 0, 1)
+
+	// A texture of three levels, 4x4, 2x2 and 1x1.
+	atlas := integers(84, 0, 255)
+	texs := []float32{3, 1, 1, 0, 0, 4, 4, 0, 64, 2, 2, 0, 80, 1, 1, 0}
+	uvl := zeros(n * 4)
+	for i := 0; i < n; i++ {
+		uvl[i*4], uvl[i*4+1], uvl[i*4+2] = 3*r.Float32()-1.5, 3*r.Float32()-1.5, 4*r.Float32()-1
+		if r.IntN(4) == 0 {
+			uvl[i*4+3] = -1
+		}
+	}
+
+	// The render targets of the forward pass: a covered pixel has the
+	// material 0 or 1, an uncovered one -2.
+	wp := Image{Width: W, Height: H, Pix: uniform(n*4, -1, 1)}
+	nr := Image{Width: W, Height: H, Pix: zeros(n * 4)}
+	uv := Image{Width: W, Height: H, Pix: uniform(n*4, 0, 2)}
+	for i := 0; i < n; i++ {
+		nr.Pix[i*4], nr.Pix[i*4+1], nr.Pix[i*4+2] = normals[i*4], normals[i*4+1], normals[i*4+2]
+		nr.Pix[i*4+3] = float32(r.IntN(3))
+		if nr.Pix[i*4+3] == 2 {
+			nr.Pix[i*4+3] = -2
+		}
+	}
+	gmats := append([]float32{1, 1, 0, 4}, uniform(12, 0, 255)...)
+	gmats = append(gmats, 0, 0, -1, 0)
+	gmats = append(gmats, uniform(12, 0, 1)...)
+	gu := append([]float32{W, H, 0, 0}, mat([4]float32{0.8, 0, 0.6, 0}, [4]float32{0, 1, 0, 0}, [4]float32{-0.6, 0, 0.8, 0}, [4]float32{1, 2, 3, 1})...)
+	gu = append(gu, mat([4]float32{0.25, 0, 0, 0}, [4]float32{0, 0.25, 0, 0}, [4]float32{0, 0, 1, 0.5}, [4]float32{-1, -1, 0, 1})...)
+
+	return []vkKernel{
+		{name: "SRGB", src: SRGBSrc, n: n, tol: 1e-5,
+			bufs: [][]float32{append(uniform(n-4, 0, 1), 0, 0.001, 0.0031308, 1), zeros(n)},
+			run:  func(gid uint, _ []Image, b [][]float32) { SRGB(gid, b[0], b[1]) }},
+		{name: "FXAA", src: FXAASrc, n: n, tol: 1e-5,
+			bufs: [][]float32{uniform(n*4, 0, 1), zeros(n * 4), {W, H, 0.0312, 0.125, 0.75}},
+			run:  func(gid uint, _ []Image, b [][]float32) { FXAA(gid, b[0], b[1], b[2]) }},
+		{name: "TAA", src: TAASrc, n: n, tol: 1e-5,
+			bufs: [][]float32{uniform(n*4, 0, 1), uniform(n, -1, 1), uniform(n*4, 0, 1), zeros(n * 4),
+				append([]float32{W, H, 0.1, 1, 0, 0, 0, 0}, mat([4]float32{1, 0, 0, 0}, [4]float32{0, 1, 0, 0}, [4]float32{0.1, 0.05, 1, 0}, [4]float32{0.3, -0.2, 0, 1})...)},
+			run: func(gid uint, _ []Image, b [][]float32) { TAA(gid, b[0], b[1], b[2], b[3], b[4]) }},
+		{name: "OIT", src: OITSrc, n: n, tol: 1e-4,
+			bufs: [][]float32{oitRanges, oitFrags, zeros(n * 4)},
+			run:  func(gid uint, _ []Image, b [][]float32) { OIT(gid, b[0], b[1], b[2]) }},
+		{name: "Composite", src: CompositeSrc, n: n, tol: 1e-5,
+			bufs: [][]float32{compRanges, compFrags, uniform(n*4, 0, 1), zeros(n * 4)},
+			run:  func(gid uint, _ []Image, b [][]float32) { Composite(gid, b[0], b[1], b[2], b[3]) }},
+		{name: "AO", src: AOSrc, n: n, tol: 1e-4,
+			bufs: [][]float32{viewpos, viewnor, aoflag, zeros(n), au},
+			run:  func(gid uint, _ []Image, b [][]float32) { AO(gid, b[0], b[1], b[2], b[3], b[4]) }},
+		{name: "AOBlur", src: AOBlurSrc, n: n, tol: 1e-4,
+			bufs: [][]float32{viewpos, aoflag, uniform(n, 0, 1), uniform(n*4, 0, 255), au},
+			run:  func(gid uint, _ []Image, b [][]float32) { AOBlur(gid, b[0], b[1], b[2], b[3], b[4]) }},
+		{name: "Shadow", src: ShadowSrc, n: n, tol: 1e-4,
+			bufs: [][]float32{fragxyz, integers(n, 0, 1), uniform(n, -1, 1), shadowMats, uniform(n*4, 0, 255), {W, n, 1, 1, 1, 0, 0, 0}},
+			run:  func(gid uint, _ []Image, b [][]float32) { Shadow(gid, b[0], b[1], b[2], b[3], b[4], b[5]) }},
+		{name: "Shade", src: ShadeSrc, n: n, tol: 1e-3,
+			bufs: [][]float32{normals, uniform(n*4, -1, 1), basecol, lights, integers(n, 0, 1), materials, surface, {0, 1, 5, 1, 0.2, 4}, env, zeros(n * 4)},
+			run: func(gid uint, _ []Image, b [][]float32) {
+				Shade(gid, b[0], b[1], b[2], b[3], b[4], b[5], b[6], b[7], b[8], b[9])
+			}},
+		{name: "Sample", src: SampleSrc, n: n,
+			bufs: [][]float32{atlas, texs, uvl, uniform(n*4, 0, 255)},
+			run:  func(gid uint, _ []Image, b [][]float32) { Sample(gid, b[0], b[1], b[2], b[3]) }},
+		{name: "GBuffer", src: GBufferSrc, n: n, tol: 1e-5,
+			images: []Image{wp, nr, uv},
+			bufs: [][]float32{gmats, gu, zeros(n * 4), zeros(n * 4), zeros(n * 4), zeros(n), zeros(n * 8),
+				zeros(n * 4), zeros(n), zeros(n * 4), zeros(n * 4), zeros(n), zeros(n * 4)},
+			run: func(gid uint, im []Image, b [][]float32) {
+				GBuffer(gid, im[0], im[1], im[2], b[0], b[1], b[2], b[3], b[4], b[5], b[6], b[7], b[8], b[9], b[10], b[11], b[12])
+			}},
+	}
+}
+
+// runGo runs k as Go on copies of its buffers and returns them.
+func (k vkKernel) runGo() [][]float32 {
+	bufs := make([][]float32, len(k.bufs))
+	for i, b := range k.bufs {
+		bufs[i] = append([]float32(nil), b...)
+	}
+	for gid := 0; gid < k.n; gid++ {
+		k.run(uint(gid), k.images, bufs)
+	}
+	return bufs
+}
+
+// compare reports the elements of got that differ from want by more than
+// the tolerance of k.
+func (k vkKernel) compare(t *testing.T, got, want [][]float32) {
+	t.Helper()
+	for i := range want {
+		bad := 0
+		for j := range want[i] {
+			g, w := got[i][j], want[i][j]
+			d := float32(math.Abs(float64(g - w)))
+			if !(d <= k.tol*max(1, float32(math.Abs(float64(w))))) && !(g != g && w != w) {
+				if bad++; bad <= 3 {
+					t.Errorf("%s: buffer %d [%d] = %v, want %v", k.name, i, j, g, w)
+				}
+			}
+		}
+		if bad > 3 {
+			t.Errorf("%s: buffer %d: %d elements differ", k.name, i, bad)
+		}
+	}
+}
+
+func vkBytes(v []float32) []byte {
+	b := make([]byte, len(v)*4)
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(x))
+	}
+	return b
+}
+
+func vkFloats(b []byte, n int) []float32 {
+	v := make([]float32, n)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return v
+}
+
+func TestVulkanKernels(t *testing.T) {
+	if os.Getenv("POLYRED_VK_PROBE") != "1" {
+		t.Skip("set POLYRED_VK_PROBE=1 to run the kernels on Vulkan")
+	}
+	dev, err := gpu.Open(gpu.WithDriver(gpu.DriverVulkan))
+	if err != nil {
+		t.Skipf("no Vulkan device: %v", err)
+	}
+	defer dev.Close()
+
+	for _, k := range vkKernels() {
+		t.Run(k.name, func(t *testing.T) {
+			ks, err := shader.CompileSPIRV(k.src)
+			if err != nil {
+				t.Fatalf("CompileSPIRV: %v", err)
+			}
+			mod, err := dev.NewShaderModule(gpu.ShaderSource{SPIRV: ks[k.name].SPIRV})
+			if err != nil {
+				t.Fatalf("shader module: %v", err)
+			}
+			entries := make([]gpu.BindGroupLayoutEntry, len(k.bufs))
+			group := make([]gpu.BindGroupEntry, len(k.bufs))
+			bufs := make([]*gpu.Buffer, len(k.bufs))
+			for i, b := range k.bufs {
+				if bufs[i], err = dev.NewBuffer(gpu.BufferDescriptor{Data: vkBytes(b), Usage: gpu.BufferStorage | gpu.BufferMapRead}); err != nil {
+					t.Fatalf("buffer %d: %v", i, err)
+				}
+				entries[i] = gpu.BindGroupLayoutEntry{Binding: i, Visibility: gpu.StageCompute, Kind: gpu.StorageBuffer}
+				group[i] = gpu.BindGroupEntry{Binding: i, Buffer: bufs[i]}
+			}
+			layout := dev.NewBindGroupLayout(entries...)
+			pipe, err := dev.NewComputePipeline(gpu.ComputePipelineDescriptor{Layout: dev.NewPipelineLayout(layout), Module: mod, Entry: k.name})
+			if err != nil {
+				t.Fatalf("compute pipeline: %v", err)
+			}
+			enc := dev.NewCommandEncoder()
+			cp := enc.BeginComputePass()
+			cp.SetPipeline(pipe)
+			cp.SetBindGroup(0, dev.NewBindGroup(layout, group...))
+			for i, im := range k.images {
+				tex, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA32Float, Width: im.Width, Height: im.Height})
+				if err != nil {
+					t.Fatalf("texture %d: %v", i, err)
+				}
+				tex.Write(vkBytes(im.Pix))
+				cp.SetTexture(i, tex)
+			}
+			cp.Dispatch(k.n, 1, 1)
+			cp.End()
+			dev.Queue().Submit(enc.Finish())
+			dev.Queue().WaitIdle()
+
+			got := make([][]float32, len(bufs))
+			for i, b := range bufs {
+				got[i] = vkFloats(b.Bytes(), len(k.bufs[i]))
+			}
+			k.compare(t, got, k.runGo())
+		})
+	}
+}
@@ -117,12 +117,19 @@ func TestHelperEmittedBeforeCaller(t *testing.T) {
 // changed entry-point selection for every kernel in the tree, so the kernels that
 // declare no helper must still compile to exactly one entry point with no
 // prelude. Byte-identity of their generated source was verified against a
-// pre-change dump when this landed; this keeps the shape locked.
+// pre-change dump when this landed; this keeps the shape locked. Shade has since
+// gained the SpotFalloff helper, so it must carry exactly that prelude and still
+// a single entry point.
 func TestExistingKernelsUnaffectedByHelpers(t *testing.T) {
-	for name, src := range map[string]string{
-		"Shade": kernels.ShadeSrc, "SRGB": kernels.SRGBSrc,
-		"Shadow": kernels.ShadowSrc, "AO": kernels.AOSrc,
+	for name, tc := range map[string]struct {
+		src     string
+		helpers bool
+	}{
+		"Shade": {kernels.ShadeSrc, true}, "SRGB": {kernels.SRGBSrc, false},
+		"Shadow": {kernels.ShadowSrc, true}, "AO": {kernels.AOSrc, false},
+		"AOBlur": {kernels.AOBlurSrc, false},
 	} {
+		src := tc.src
 		t.Run(name, func(t *testing.T) {
 			msl, err := shader.Compile(src)
 			if err != nil {
@@ -139,8 +146,8 @@ func TestExistingKernelsUnaffectedByHelpers(t *testing.T) {
 				t.Fatalf("GLSL: got %d kernels, want 1", len(glsl))
 			}
 			for _, k := range msl {
-				if strings.Contains(k.MSL, "static ") {
-					t.Errorf("MSL gained a helper prelude it never declared:\n%s", k.MSL)
+				if got := strings.Contains(k.MSL, "static "); got != tc.helpers {
+					t.Errorf("MSL helper prelude = %v, want %v:\n%s", got, tc.helpers, k.MSL)
 				}
 			}
 		})
@@ -0,0 +1,2200 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"math"
+	"slices"
+	"strings"
+)
+
+// The SPIR-V emitter translates the kernel AST to a binary module directly,
+// without a shading language in between, so that the Vulkan backend needs no
+// external compiler. Unlike the MSL and GLSL emitters, which print the Go
+// expressions and let the shader compiler type them, SPIR-V is typed
+// instruction by instruction, so the emitter types the expressions itself,
+// with Go's rules: an untyped constant takes the type of the other operand
+// and is folded exactly, as the Go compiler folds it. Locals live in
+// function variables, which the driver promotes to registers, and if and for
+// become structured selections and loops.
+
+// SPIR-V module constants, see the SPIR-V specification §2.3 and §3.
+const (
+	spvMagic   = 0x07230203
+	spvVersion = 0x00010000 // 1.0, which every Vulkan driver accepts
+
+	spvCapShader          = 1
+	spvAddrLogical        = 0
+	spvMemGLSL450         = 1
+	spvModelVertex        = 0
+	spvModelFragment      = 4
+	spvModelGLCompute     = 5
+	spvModeOriginUpper    = 7
+	spvModeLocalSize      = 17
+	spvDim2D              = 1
+	spvFormatRgba32f      = 1
+	spvBuiltInPosition    = 0
+	spvBuiltInFragCoord   = 15
+	spvBuiltInFrontFacing = 17
+	spvBuiltInGlobalID    = 28
+	spvBuiltInVertexIndex = 42
+	spvFunctionControl0   = 0
+
+	spvClassUniformConstant = 0
+	spvClassInput           = 1
+	spvClassUniform         = 2
+	spvClassOutput          = 3
+	spvClassFunction        = 7
+
+	spvDecBufferBlock    = 3
+	spvDecColMajor       = 5
+	spvDecArrayStride    = 6
+	spvDecMatrixStride   = 7
+	spvDecBuiltIn        = 11
+	spvDecFlat           = 14
+	spvDecNonWritable    = 24
+	spvDecLocation       = 30
+	spvDecBinding        = 33
+	spvDecDescriptorSet  = 34
+	spvDecOffset         = 35
+	spvDecNoContraction  = 42
+	spvSelectionControl0 = 0
+	spvLoopControl0      = 0
+)
+
+// SPIR-V opcodes.
+const (
+	opName               = 5
+	opExtInstImport      = 11
+	opExtInst            = 12
+	opMemoryModel        = 14
+	opEntryPoint         = 15
+	opExecutionMode      = 16
+	opCapability         = 17
+	opTypeVoid           = 19
+	opTypeBool           = 20
+	opTypeInt            = 21
+	opTypeFloat          = 22
+	opTypeVector         = 23
+	opTypeMatrix         = 24
+	opTypeImage          = 25
+	opTypeRuntimeArray   = 29
+	opTypeStruct         = 30
+	opTypePointer        = 32
+	opTypeFunction       = 33
+	opConstantTrue       = 41
+	opConstantFalse      = 42
+	opConstant           = 43
+	opConstantNull       = 46
+	opFunction           = 54
+	opFunctionParameter  = 55
+	opFunctionEnd        = 56
+	opFunctionCall       = 57
+	opVariable           = 59
+	opLoad               = 61
+	opStore              = 62
+	opAccessChain        = 65
+	opDecorate           = 71
+	opMemberDecorate     = 72
+	opVectorShuffle      = 79
+	opCompositeConstruct = 80
+	opCompositeExtract   = 81
+	opCompositeInsert    = 82
+	opImageRead          = 98
+	opConvertFToU        = 109
+	opConvertFToS        = 110
+	opConvertSToF        = 111
+	opConvertUToF        = 112
+	opBitcast            = 124
+	opSNegate            = 126
+	opFNegate            = 127
+	opIAdd               = 128
+	opFAdd               = 129
+	opISub               = 130
+	opFSub               = 131
+	opIMul               = 132
+	opFMul               = 133
+	opUDiv               = 134
+	opSDiv               = 135
+	opFDiv               = 136
+	opUMod               = 137
+	opSRem               = 138
+	opMatrixTimesScalar  = 143
+	opMatrixTimesVector  = 145
+	opMatrixTimesMatrix  = 146
+	opDot                = 148
+	opLogicalEqual       = 164
+	opLogicalNotEqual    = 165
+	opLogicalNot         = 168
+	opSelect             = 169
+	opIEqual             = 170
+	opINotEqual          = 171
+	opUGreaterThan       = 172
+	opSGreaterThan       = 173
+	opUGreaterThanEqual  = 174
+	opSGreaterThanEqual  = 175
+	opULessThan          = 176
+	opSLessThan          = 177
+	opULessThanEqual     = 178
+	opSLessThanEqual     = 179
+	opFOrdEqual          = 180
+	opFUnordNotEqual     = 183
+	opFOrdLessThan       = 184
+	opFOrdGreaterThan    = 186
+	opFOrdLessThanEqual  = 188
+	opFOrdGreaterThanEq  = 190
+	opDPdx               = 207
+	opDPdy               = 208
+	opPhi                = 245
+	opLoopMerge          = 246
+	opSelectionMerge     = 247
+	opLabel              = 248
+	opBranch             = 249
+	opBranchConditional  = 250
+	opKill               = 252
+	opReturn             = 253
+	opReturnValue        = 254
+	opUnreachable        = 255
+)
+
+// GLSL.std.450 extended instructions of the builtins that take float
+// operands, by their canonical (MSL) spelling.
+var spvFloatExt = map[string]uint32{
+	"trunc": 3, "floor": 8, "ceil": 9, "fract": 10,
+	"sin": 13, "cos": 14, "tan": 15, "asin": 16, "acos": 17,
+	"pow": 26, "exp": 27, "log": 28, "log2": 30, "sqrt": 31,
+	"length": 66, "cross": 68, "normalize": 69, "reflect": 71,
+}
+
+// GLSL.std.450 instructions whose variant depends on the operand type:
+// float, signed and unsigned.
+var spvTypedExt = map[string][3]uint32{
+	"abs":   {4, 5, 0},
+	"min":   {37, 39, 38},
+	"max":   {40, 42, 41},
+	"clamp": {43, 45, 44},
+}
+
+const (
+	spvExtFSign = 6
+	spvExtAtan  = 18
+	spvExtAtan2 = 25
+	spvExtFMix  = 46
+)
+
+// CompileSPIRV is like CompileGLSL but emits a binary SPIR-V 1.0 module
+// (Kernel.SPIRV) for the Vulkan backend, whose entry point is "main". It
+// supports compute kernels and vertex and fragment stages with storage
+// buffers of 32-bit scalars, struct-by-value uniforms and images. All
+// buffers, uniforms included, are storage buffers in descriptor set 0,
+// numbered in parameter order as on Metal; images are numbered in descriptor
+// set 1, their own binding space as on GL.
+//
+// The stages take their varyings and return their targets as in GLSL (see
+// compileStageGLSL): the varyings are Input and Output variables at
+// consecutive Locations in the order of the fields of their struct, less
+// the position, which is the Position builtin of the vertex and FragCoord
+// of the fragment; the targets of a fragment are Output variables at the
+// Locations of their order.
+func CompileSPIRV(src string) (map[string]*Kernel, error) {
+	ps, err := parseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range ps.helpers {
+		if fn.Type.Results == nil || len(fn.Type.Results.List) != 1 {
+			return nil, fmt.Errorf("shader: helper %s must return exactly one value", fn.Name.Name)
+		}
+	}
+	out := map[string]*Kernel{}
+	for _, fn := range ps.funcs {
+		k, err := compileKernelSPIRV(fn, ps)
+		if err != nil {
+			return nil, fmt.Errorf("shader: kernel %s: %w", fn.Name.Name, err)
+		}
+		out[k.Name] = k
+	}
+	return out, nil
+}
+
+// spvModule accumulates the sections of a module. Types, constants and
+// global variables share one section, in which each is created after what it
+// refers to.
+type spvModule struct {
+	bound   uint32 // the last id allocated
+	ext     uint32 // the GLSL.std.450 import
+	names   []uint32
+	decos   []uint32
+	globals []uint32
+	funcs   []uint32
+	ids     map[string]uint32 // types and constants by key
+	structs map[string]*ast.StructType
+	helpers map[string]*spvHelper
+}
+
+// spvHelper is the function of a //gpu:helper.
+type spvHelper struct {
+	fn     *ast.FuncDecl
+	id     uint32
+	ret    string
+	params []param
+	types  []string
+}
+
+// spvField is a field of a struct value: of the varyings or targets of a
+// stage, or a local. tag is its gpu tag.
+type spvField struct {
+	name, typ, tag string
+}
+
+// spvValue is the result of an expression: its id and its canonical type.
+type spvValue struct {
+	id  uint32
+	typ string
+}
+
+// spvLocal is a variable of the function storage class.
+type spvLocal struct {
+	ptr uint32
+	typ string
+}
+
+// spvBuffer is a storage buffer parameter; spvUniform a struct-by-value
+// uniform, with its field names and types; spvImage an image parameter.
+type (
+	spvBuffer struct {
+		v    uint32
+		elem string
+	}
+	spvUniform struct {
+		v      uint32
+		fields []string
+		types  []string
+	}
+	spvImage struct {
+		v, typ uint32
+	}
+)
+
+func (m *spvModule) id() uint32 {
+	m.bound++
+	return m.bound
+}
+
+// inst appends an instruction of opcode op to dst.
+func inst(dst *[]uint32, op uint32, operands ...uint32) {
+	*dst = append(*dst, uint32(len(operands)+1)<<16|op)
+	*dst = append(*dst, operands...)
+}
+
+// spvString is the literal string s: nul terminated, packed in little-endian
+// words.
+func spvString(s string) []uint32 {
+	b := append([]byte(s), 0)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	w := make([]uint32, len(b)/4)
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return w
+}
+
+func (m *spvModule) name(id uint32, s string) {
+	inst(&m.names, opName, append([]uint32{id}, spvString(s)...)...)
+}
+
+func (m *spvModule) decorate(id uint32, deco ...uint32) {
+	inst(&m.decos, opDecorate, append([]uint32{id}, deco...)...)
+}
+
+// spvVecLen is the component count of a vector type, or 0 for a scalar.
+func spvVecLen(t string) int {
+	switch t {
+	case "float2", "int2", "uint2":
+		return 2
+	case "float3", "int3", "uint3":
+		return 3
+	case "float4", "int4", "uint4":
+		return 4
+	}
+	return 0
+}
+
+// spvScalar is the component type of a vector (or matrix) type, or t itself.
+func spvScalar(t string) string {
+	switch {
+	case t == "float4x4":
+		return "float"
+	case spvVecLen(t) > 0:
+		return t[:len(t)-1]
+	}
+	return t
+}
+
+func spvIsInt(t string) bool { return t == "int" || t == "uint" }
+
+func spvIsFloat(t string) bool { return spvScalar(t) == "float" }
+
+// typ returns the id of the canonical type t, declaring it on first use.
+func (m *spvModule) typ(t string) uint32 {
+	if id, ok := m.ids[t]; ok {
+		return id
+	}
+	var id uint32
+	switch t {
+	case "void":
+		id = m.id()
+		inst(&m.globals, opTypeVoid, id)
+	case "bool":
+		id = m.id()
+		inst(&m.globals, opTypeBool, id)
+	case "float":
+		id = m.id()
+		inst(&m.globals, opTypeFloat, id, 32)
+	case "int":
+		id = m.id()
+		inst(&m.globals, opTypeInt, id, 32, 1)
+	case "uint":
+		id = m.id()
+		inst(&m.globals, opTypeInt, id, 32, 0)
+	case "float4x4":
+		col := m.typ("float4")
+		id = m.id()
+		inst(&m.globals, opTypeMatrix, id, col, 4)
+	default:
+		if _, ok := m.structs[t]; ok {
+			fs, err := m.fields(t)
+			if err != nil {
+				panic("shader: no SPIR-V type for " + t)
+			}
+			members := make([]uint32, len(fs))
+			for i, fd := range fs {
+				members[i] = m.typ(fd.typ)
+			}
+			id = m.id()
+			inst(&m.globals, opTypeStruct, append([]uint32{id}, members...)...)
+			break
+		}
+		n := spvVecLen(t)
+		if n == 0 {
+			panic("shader: no SPIR-V type for " + t)
+		}
+		comp := m.typ(spvScalar(t))
+		id = m.id()
+		inst(&m.globals, opTypeVector, id, comp, uint32(n))
+	}
+	m.ids[t] = id
+	return id
+}
+
+// ptr returns the id of the pointer type to the type id t in class.
+func (m *spvModule) ptr(class, t uint32) uint32 {
+	key := fmt.Sprintf("*%d:%d", class, t)
+	if id, ok := m.ids[key]; ok {
+		return id
+	}
+	id := m.id()
+	inst(&m.globals, opTypePointer, id, class, t)
+	m.ids[key] = id
+	return id
+}
+
+// fnType returns the id of the function type of the result and parameter
+// type ids.
+func (m *spvModule) fnType(ret uint32, params ...uint32) uint32 {
+	key := fmt.Sprint("fn", ret, params)
+	if id, ok := m.ids[key]; ok {
+		return id
+	}
+	id := m.id()
+	inst(&m.globals, opTypeFunction, append([]uint32{id, ret}, params...)...)
+	m.ids[key] = id
+	return id
+}
+
+// constant returns the id of the scalar constant of type t whose bits are w.
+func (m *spvModule) constant(t string, w uint32) uint32 {
+	key := fmt.Sprintf("%s=%d", t, w)
+	if id, ok := m.ids[key]; ok {
+		return id
+	}
+	tid := m.typ(t)
+	id := m.id()
+	switch {
+	case t == "bool" && w != 0:
+		inst(&m.globals, opConstantTrue, tid, id)
+	case t == "bool":
+		inst(&m.globals, opConstantFalse, tid, id)
+	default:
+		inst(&m.globals, opConstant, tid, id, w)
+	}
+	m.ids[key] = id
+	return id
+}
+
+func (m *spvModule) constInt(v int32) uint32 { return m.constant("int", uint32(v)) }
+
+// zero returns the id of the zero value of type t.
+func (m *spvModule) zero(t string) uint32 {
+	key := "0:" + t
+	if id, ok := m.ids[key]; ok {
+		return id
+	}
+	tid := m.typ(t)
+	id := m.id()
+	inst(&m.globals, opConstantNull, tid, id)
+	m.ids[key] = id
+	return id
+}
+
+// imageType returns the id of the type of an Image parameter: a 2D storage
+// image of RGBA32F texels.
+func (m *spvModule) imageType() uint32 {
+	if id, ok := m.ids["image"]; ok {
+		return id
+	}
+	ft := m.typ("float")
+	id := m.id()
+	inst(&m.globals, opTypeImage, id, ft, spvDim2D, 0, 0, 0, 2, spvFormatRgba32f)
+	m.ids["image"] = id
+	return id
+}
+
+// variable declares a global variable of the type id t in class.
+func (m *spvModule) variable(class, t uint32) uint32 {
+	id := m.id()
+	inst(&m.globals, opVariable, m.ptr(class, t), id, class)
+	return id
+}
+
+// builtin declares the variable of class of the builtin b, of the canonical
+// type t.
+func (m *spvModule) builtin(class uint32, t string, b uint32) uint32 {
+	v := m.variable(class, m.typ(t))
+	m.decorate(v, spvDecBuiltIn, b)
+	return v
+}
+
+// fields returns the fields of the struct name, whose types must be scalars,
+// vectors or matrices.
+func (m *spvModule) fields(name string) ([]spvField, error) {
+	st, ok := m.structs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown struct %q", name)
+	}
+	var fs []spvField
+	for _, fd := range st.Fields.List {
+		ft, _ := identType(fd.Type)
+		mt, ok := goToMSLType(ft)
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported field type %q", name, ft)
+		}
+		for _, n := range fd.Names {
+			fs = append(fs, spvField{name: n.Name, typ: mt, tag: fieldTag(fd)})
+		}
+	}
+	return fs, nil
+}
+
+// varyings declares the variables of class of the varyings fs: the position
+// is the builtin FragCoord of a fragment's input and Position of a vertex's
+// output, the others take consecutive Locations, four for a matrix, and are
+// flat if tagged gpu:"flat".
+func (m *spvModule) varyings(class uint32, fs []spvField) []uint32 {
+	vars := make([]uint32, len(fs))
+	loc := uint32(0)
+	for i, fd := range fs {
+		if fd.tag == "position" {
+			b := uint32(spvBuiltInPosition)
+			if class == spvClassInput {
+				b = spvBuiltInFragCoord
+			}
+			vars[i] = m.builtin(class, fd.typ, b)
+			continue
+		}
+		v := m.variable(class, m.typ(fd.typ))
+		m.decorate(v, spvDecLocation, loc)
+		if fd.tag == "flat" {
+			m.decorate(v, spvDecFlat)
+		}
+		m.name(v, "v_"+fd.name)
+		vars[i] = v
+		loc++
+		if fd.typ == "float4x4" {
+			loc += 3
+		}
+	}
+	return vars
+}
+
+// storageBlock declares a buffer block of members of the canonical types at
+// the offsets, a "[]" prefix marking a runtime array, as a variable in
+// descriptor set 0 at binding. Buffers are BufferBlock structs of the Uniform
+// class, the SPIR-V 1.0 spelling of a storage buffer; each gets a struct of
+// its own, as its members carry the NonWritable of the buffer.
+func (m *spvModule) storageBlock(types []string, offsets []uint32, readonly bool, binding int) uint32 {
+	members := make([]uint32, len(types))
+	for i, t := range types {
+		if elem, ok := strings.CutPrefix(t, "[]"); ok {
+			members[i] = m.runtimeArray(elem)
+		} else {
+			members[i] = m.typ(t)
+		}
+	}
+	st := m.id()
+	inst(&m.globals, opTypeStruct, append([]uint32{st}, members...)...)
+	for i, off := range offsets {
+		inst(&m.decos, opMemberDecorate, st, uint32(i), spvDecOffset, off)
+		if types[i] == "float4x4" {
+			inst(&m.decos, opMemberDecorate, st, uint32(i), spvDecColMajor)
+			inst(&m.decos, opMemberDecorate, st, uint32(i), spvDecMatrixStride, 16)
+		}
+		if readonly {
+			inst(&m.decos, opMemberDecorate, st, uint32(i), spvDecNonWritable)
+		}
+	}
+	m.decorate(st, spvDecBufferBlock)
+	v := m.variable(spvClassUniform, st)
+	m.decorate(v, spvDecDescriptorSet, 0)
+	m.decorate(v, spvDecBinding, uint32(binding))
+	return v
+}
+
+// runtimeArray returns the id of the runtime array of t with a stride of 4.
+func (m *spvModule) runtimeArray(t string) uint32 {
+	key := "[]" + t
+	if id, ok := m.ids[key]; ok {
+		return id
+	}
+	elem := m.typ(t)
+	id := m.id()
+	inst(&m.globals, opTypeRuntimeArray, id, elem)
+	m.decorate(id, spvDecArrayStride, 4)
+	m.ids[key] = id
+	return id
+}
+
+// std140 returns the alignment and size of a uniform field of type t.
+func std140(t string) (align, size uint32) {
+	switch t {
+	case "float2":
+		return 8, 8
+	case "float3":
+		return 16, 12
+	case "float4":
+		return 16, 16
+	case "float4x4":
+		return 16, 64
+	}
+	return 4, 4
+}
+
+func compileKernelSPIRV(fn *ast.FuncDecl, ps *source) (*Kernel, error) {
+	stage := stageOf(fn.Doc)
+	if err := checkStageCalls(fn, stage); err != nil {
+		return nil, err
+	}
+	params := flattenParams(fn.Type.Params)
+
+	m := &spvModule{ids: map[string]uint32{}, structs: ps.structs, helpers: map[string]*spvHelper{}}
+	m.ext = m.id()
+	main := m.id()
+	m.name(main, fn.Name.Name)
+	for _, h := range ps.helpers {
+		sh := &spvHelper{fn: h, id: m.id(), params: flattenParams(h.Type.Params)}
+		rt, _ := identType(h.Type.Results.List[0].Type)
+		var ok bool
+		if sh.ret, ok = goToMSLType(rt); !ok {
+			return nil, fmt.Errorf("helper %s: unsupported result type %q", h.Name.Name, rt)
+		}
+		for _, p := range sh.params {
+			tn, _ := identType(p.typ)
+			mt, ok := goToMSLType(tn)
+			if !ok {
+				return nil, fmt.Errorf("helper %s: parameter %q must be a scalar or vector", h.Name.Name, p.name)
+			}
+			sh.types = append(sh.types, mt)
+		}
+		m.helpers[h.Name.Name] = sh
+		m.name(sh.id, h.Name.Name)
+	}
+
+	// Compute and vertex kernels take a leading id parameter; the first
+	// struct parameter of a fragment kernel is its varyings.
+	var id, in *param
+	if stage != StageFragment {
+		if len(params) == 0 {
+			return nil, fmt.Errorf("kernel needs a leading id parameter")
+		}
+		id, params = &params[0], params[1:]
+		if t, ok := identType(id.typ); !ok || !isIntType(t) {
+			return nil, fmt.Errorf("first parameter %q must be the int/uint id", id.name)
+		}
+	} else if len(params) > 0 {
+		if t, ok := params[0].typ.(*ast.Ident); ok && ps.structs[t.Name] != nil {
+			in, params = &params[0], params[1:]
+		}
+	}
+
+	f := m.newFunc("void")
+	bindings, err := f.resources(params, writtenBuffers(fn.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	// iface are the Input and Output variables of the entry point. The id
+	// is the x of gl_GlobalInvocationID or gl_VertexIndex, held in a local
+	// as any other variable.
+	var iface []uint32
+	var idVar uint32
+	switch stage {
+	case StageCompute:
+		idVar = m.builtin(spvClassInput, "uint3", spvBuiltInGlobalID)
+	case StageVertex:
+		idVar = m.builtin(spvClassInput, "int", spvBuiltInVertexIndex)
+	}
+	if idVar != 0 {
+		iface = append(iface, idVar)
+	}
+	var inFields []spvField
+	var inVars []uint32
+	if in != nil {
+		st := in.typ.(*ast.Ident).Name
+		if inFields, err = m.fields(st); err != nil {
+			return nil, err
+		}
+		inVars = m.varyings(spvClassInput, inFields)
+		iface = append(iface, inVars...)
+	}
+	if stage != StageCompute {
+		if err := f.outputs(fn, stage); err != nil {
+			return nil, err
+		}
+		for _, o := range f.outs {
+			iface = append(iface, o.v)
+		}
+	}
+	if stage == StageFragment && usesFrontFacing(fn) {
+		f.front = m.builtin(spvClassInput, "bool", spvBuiltInFrontFacing)
+		iface = append(iface, f.front)
+	}
+
+	f.begin(main, m.fnType(m.typ("void")))
+	if id != nil {
+		var x spvValue
+		if stage == StageCompute {
+			g := f.op(opLoad, "uint3", idVar)
+			x = f.op(opCompositeExtract, "uint", g.id, 0)
+		} else {
+			x = f.op(opLoad, "int", idVar)
+		}
+		t, _ := identType(id.typ)
+		t, _ = goToMSLType(t)
+		x, err := f.coerce(x, t)
+		if err != nil {
+			return nil, err
+		}
+		inst(&f.body, opStore, f.declare(id.name, t), x.id)
+	}
+	if in != nil {
+		ids := make([]uint32, len(inFields))
+		for i, fd := range inFields {
+			ids[i] = f.op(opLoad, fd.typ, inVars[i]).id
+		}
+		st := in.typ.(*ast.Ident).Name
+		v := f.op(opCompositeConstruct, st, ids...)
+		inst(&f.body, opStore, f.declare(in.name, st), v.id)
+	}
+	if err := f.stmts(fn.Body.List); err != nil {
+		return nil, err
+	}
+	f.end()
+
+	for _, h := range ps.helpers {
+		if err := m.helper(m.helpers[h.Name.Name]); err != nil {
+			return nil, fmt.Errorf("helper %s: %w", h.Name.Name, err)
+		}
+	}
+
+	model := map[Stage]uint32{StageCompute: spvModelGLCompute, StageVertex: spvModelVertex, StageFragment: spvModelFragment}[stage]
+	var w []uint32
+	w = append(w, spvMagic, spvVersion, 0, m.bound+1, 0)
+	inst(&w, opCapability, spvCapShader)
+	inst(&w, opExtInstImport, append([]uint32{m.ext}, spvString("GLSL.std.450")...)...)
+	inst(&w, opMemoryModel, spvAddrLogical, spvMemGLSL450)
+	inst(&w, opEntryPoint, append(append([]uint32{model, main}, spvString("main")...), iface...)...)
+	switch stage {
+	case StageCompute:
+		inst(&w, opExecutionMode, main, spvModeLocalSize, 1, 1, 1)
+	case StageFragment:
+		// Vulkan's only origin. With the viewport of the backend, which
+		// keeps GL's row order, FragCoord is GL's gl_FragCoord.
+		inst(&w, opExecutionMode, main, spvModeOriginUpper)
+	}
+	w = append(w, m.names...)
+	w = append(w, m.decos...)
+	w = append(w, m.globals...)
+	w = append(w, m.funcs...)
+
+	b := make([]byte, len(w)*4)
+	for i, v := range w {
+		binary.LittleEndian.PutUint32(b[i*4:], v)
+	}
+	return &Kernel{Name: fn.Name.Name, Stage: stage, Bindings: bindings, SPIRV: b}, nil
+}
+
+// resources declares the buffer, uniform and image parameters of a kernel,
+// written the names of the buffers it writes, and returns their bindings.
+func (f *spvFunc) resources(params []param, written map[string]bool) ([]Binding, error) {
+	m := f.m
+	var bindings []Binding
+	bufIndex, imageIndex := 0, 0
+	for _, p := range params {
+		switch t := p.typ.(type) {
+		case *ast.ArrayType:
+			if t.Len != nil {
+				return nil, fmt.Errorf("parameter %q: only slices ([]float32) are supported as buffers", p.name)
+			}
+			elt, _ := identType(t.Elt)
+			mt, ok := goToMSLType(elt)
+			if !ok || spvVecLen(mt) > 0 || mt == "float4x4" {
+				return nil, fmt.Errorf("parameter %q: unsupported slice element %q", p.name, elt)
+			}
+			v := m.storageBlock([]string{"[]" + mt}, []uint32{0}, !written[p.name], bufIndex)
+			m.name(v, p.name)
+			f.bufs[p.name] = spvBuffer{v: v, elem: mt}
+			bindings = append(bindings, Binding{Index: bufIndex, Name: p.name, Kind: StorageBuffer})
+			bufIndex++
+		case *ast.Ident:
+			switch t.Name {
+			case "Texture2D", "Sampler":
+				return nil, fmt.Errorf("parameter %q: SPIR-V backend does not support textures/samplers yet", p.name)
+			case "Image":
+				it := m.imageType()
+				v := m.variable(spvClassUniformConstant, it)
+				m.decorate(v, spvDecDescriptorSet, 1)
+				m.decorate(v, spvDecBinding, uint32(imageIndex))
+				m.decorate(v, spvDecNonWritable)
+				m.name(v, p.name)
+				f.images[p.name] = spvImage{v: v, typ: it}
+				bindings = append(bindings, Binding{Index: imageIndex, Name: p.name, Kind: StorageTexture})
+				imageIndex++
+				continue
+			}
+			st, ok := m.structs[t.Name]
+			if !ok {
+				return nil, fmt.Errorf("parameter %q: unsupported type %q", p.name, t.Name)
+			}
+			var u spvUniform
+			var offsets []uint32
+			off := uint32(0)
+			for _, fd := range st.Fields.List {
+				ft, _ := identType(fd.Type)
+				mt, ok := goToMSLType(ft)
+				if !ok {
+					return nil, fmt.Errorf("parameter %q: unsupported field type %q", p.name, ft)
+				}
+				align, size := std140(mt)
+				for _, n := range fd.Names {
+					off = (off + align - 1) / align * align
+					u.fields = append(u.fields, n.Name)
+					u.types = append(u.types, mt)
+					offsets = append(offsets, off)
+					off += size
+				}
+			}
+			u.v = m.storageBlock(u.types, offsets, true, bufIndex)
+			m.name(u.v, p.name)
+			f.unis[p.name] = u
+			bindings = append(bindings, Binding{Index: bufIndex, Name: p.name, Kind: UniformBuffer})
+			bufIndex++
+		default:
+			return nil, fmt.Errorf("parameter %q: unsupported parameter type", p.name)
+		}
+	}
+	return bindings, nil
+}
+
+// outputs declares the Output variables of the result of the vertex or
+// fragment kernel fn: the position and the varyings of a vertex, the
+// targets of a fragment.
+func (f *spvFunc) outputs(fn *ast.FuncDecl, stage Stage) error {
+	m := f.m
+	kw := "vertex"
+	if stage == StageFragment {
+		kw = "fragment"
+	}
+	if fn.Type.Results == nil || len(fn.Type.Results.List) != 1 {
+		return fmt.Errorf("%s kernel must return exactly one value", kw)
+	}
+	rt, _ := identType(fn.Type.Results.List[0].Type)
+	var fs []spvField
+	t, whole := goToMSLType(rt)
+	if whole {
+		tag := ""
+		if stage == StageVertex {
+			tag = "position"
+		}
+		fs = []spvField{{typ: t, tag: tag}}
+		f.result = t
+	} else if st := m.structs[rt]; st != nil {
+		var err error
+		if fs, err = m.fields(rt); err != nil {
+			return err
+		}
+		if stage == StageVertex && positionField(st) == "" {
+			return fmt.Errorf("vertex output %s has no gpu:\"position\" field", rt)
+		}
+		f.result = rt
+	} else {
+		return fmt.Errorf("unsupported return type %q", rt)
+	}
+
+	var vars []uint32
+	if stage == StageVertex {
+		vars = m.varyings(spvClassOutput, fs)
+	} else {
+		for i, fd := range fs {
+			v := m.variable(spvClassOutput, m.typ(fd.typ))
+			m.decorate(v, spvDecLocation, uint32(i))
+			m.name(v, "_out_"+fd.name)
+			vars = append(vars, v)
+		}
+	}
+	for i, fd := range fs {
+		pos := stage == StageVertex && fd.tag == "position"
+		if pos && fd.typ != "float4" {
+			return fmt.Errorf("vertex position is a %s, not a Vec4", fd.typ)
+		}
+		field := i
+		if whole {
+			field = -1
+		}
+		f.outs = append(f.outs, spvOutput{v: vars[i], field: field, typ: fd.typ, pos: pos})
+	}
+	return nil
+}
+
+// output stores the result e of a vertex or fragment kernel in its outputs
+// and returns.
+func (f *spvFunc) output(e ast.Expr) error {
+	v, err := f.typed(e, f.result)
+	if err != nil {
+		return err
+	}
+	for _, o := range f.outs {
+		x := v
+		if o.field >= 0 {
+			x = f.op(opCompositeExtract, o.typ, v.id, uint32(o.field))
+		}
+		if o.pos {
+			// Kernels output GL's clip space, whose z runs over [-w, w];
+			// Vulkan clips z to [0, w]. Remap z so that depth means the
+			// same on both, as the MSL emitter does for Metal.
+			z := f.op(opCompositeExtract, "float", x.id, 2)
+			w := f.op(opCompositeExtract, "float", x.id, 3)
+			s := f.exact(opFAdd, "float", z.id, w.id)
+			h := f.exact(opFMul, "float", s.id, f.m.constant("float", math.Float32bits(0.5)))
+			x = f.op(opCompositeInsert, o.typ, h.id, x.id, 2)
+		}
+		inst(&f.body, opStore, o.v, x.id)
+	}
+	inst(&f.body, opReturn)
+	f.closed = true
+	return nil
+}
+
+// spvFunc emits the body of a function.
+type spvFunc struct {
+	m      *spvModule
+	ret    string   // the result type, "void" for a kernel
+	head   []uint32 // OpFunction and its parameters
+	vars   []uint32 // the variables, which must open the first block
+	body   []uint32
+	entry  uint32 // the first block
+	label  uint32 // the current block
+	closed bool   // the current block has its terminator
+	scopes []map[string]spvLocal
+	bufs   map[string]spvBuffer
+	unis   map[string]spvUniform
+	images map[string]spvImage
+
+	// result is the result type of a vertex or fragment kernel, whose
+	// return stores it in outs; front is the FrontFacing input of a
+	// fragment kernel that calls FrontFacing.
+	result string
+	outs   []spvOutput
+	front  uint32
+}
+
+// spvOutput is an Output variable of a vertex or fragment kernel and the
+// field of the result it holds, -1 for the whole result. The position of a
+// vertex is pos.
+type spvOutput struct {
+	v     uint32
+	field int
+	typ   string
+	pos   bool
+}
+
+func (m *spvModule) newFunc(ret string) *spvFunc {
+	return &spvFunc{
+		m: m, ret: ret, scopes: []map[string]spvLocal{{}},
+		bufs: map[string]spvBuffer{}, unis: map[string]spvUniform{}, images: map[string]spvImage{},
+	}
+}
+
+// begin opens the function id of the function type fnType and its first
+// block. Parameters go to f.head in between.
+func (f *spvFunc) begin(id, fnType uint32) {
+	inst(&f.head, opFunction, f.m.typ(f.ret), id, spvFunctionControl0, fnType)
+	f.entry = f.m.id()
+	f.label = f.entry
+}
+
+// end closes the function and appends it to the module. A function whose
+// last block falls through returns if void; otherwise the block follows
+// branches that all returned, and is unreachable.
+func (f *spvFunc) end() {
+	if !f.closed {
+		if f.ret == "void" {
+			inst(&f.body, opReturn)
+		} else {
+			inst(&f.body, opUnreachable)
+		}
+	}
+	m := f.m
+	m.funcs = append(m.funcs, f.head...)
+	inst(&m.funcs, opLabel, f.entry)
+	m.funcs = append(m.funcs, f.vars...)
+	m.funcs = append(m.funcs, f.body...)
+	inst(&m.funcs, opFunctionEnd)
+}
+
+// helper emits the function of a //gpu:helper. Its parameters are copied to
+// variables, which the body may assign as Go allows.
+func (m *spvModule) helper(h *spvHelper) error {
+	f := m.newFunc(h.ret)
+	ptypes := make([]uint32, len(h.types))
+	for i, t := range h.types {
+		ptypes[i] = m.typ(t)
+	}
+	f.begin(h.id, m.fnType(m.typ(h.ret), ptypes...))
+	for i, p := range h.params {
+		id := m.id()
+		inst(&f.head, opFunctionParameter, ptypes[i], id)
+		inst(&f.body, opStore, f.declare(p.name, h.types[i]), id)
+	}
+	if err := f.stmts(h.fn.Body.List); err != nil {
+		return err
+	}
+	f.end()
+	return nil
+}
+
+// op emits the instruction op with a result of type t and returns it.
+func (f *spvFunc) op(op uint32, t string, operands ...uint32) spvValue {
+	id := f.m.id()
+	inst(&f.body, op, append([]uint32{f.m.typ(t), id}, operands...)...)
+	return spvValue{id: id, typ: t}
+}
+
+// exact emits op as op does and forbids the driver to contract it with
+// another operation into a fused multiply-add, so that the GPU rounds every
+// product and sum as the kernel does as Go on the CPU.
+func (f *spvFunc) exact(op uint32, t string, operands ...uint32) spvValue {
+	v := f.op(op, t, operands...)
+	f.m.decorate(v.id, spvDecNoContraction)
+	return v
+}
+
+func (f *spvFunc) ext(t string, instr uint32, args ...spvValue) spvValue {
+	operands := []uint32{f.m.ext, instr}
+	for _, a := range args {
+		operands = append(operands, a.id)
+	}
+	return f.op(opExtInst, t, operands...)
+}
+
+// block opens the block label.
+func (f *spvFunc) block(label uint32) {
+	inst(&f.body, opLabel, label)
+	f.label, f.closed = label, false
+}
+
+// branch closes the current block by a branch to label, unless it is closed
+// already.
+func (f *spvFunc) branch(label uint32) {
+	if !f.closed {
+		inst(&f.body, opBranch, label)
+		f.closed = true
+	}
+}
+
+// declare declares the local name of type t in the innermost scope and
+// returns its pointer.
+func (f *spvFunc) declare(name, t string) uint32 {
+	m := f.m
+	id := m.id()
+	inst(&f.vars, opVariable, m.ptr(spvClassFunction, m.typ(t)), id, spvClassFunction)
+	f.scopes[len(f.scopes)-1][name] = spvLocal{ptr: id, typ: t}
+	return id
+}
+
+func (f *spvFunc) lookup(name string) (spvLocal, bool) {
+	for i := len(f.scopes) - 1; i >= 0; i-- {
+		if l, ok := f.scopes[i][name]; ok {
+			return l, true
+		}
+	}
+	return spvLocal{}, false
+}
+
+func (f *spvFunc) push() { f.scopes = append(f.scopes, map[string]spvLocal{}) }
+func (f *spvFunc) pop()  { f.scopes = f.scopes[:len(f.scopes)-1] }
+
+func (f *spvFunc) scoped(list []ast.Stmt) error {
+	f.push()
+	defer f.pop()
+	return f.stmts(list)
+}
+
+// stmts emits list. The statements after a return are unreachable and
+// dropped.
+func (f *spvFunc) stmts(list []ast.Stmt) error {
+	for _, s := range list {
+		if f.closed {
+			return nil
+		}
+		if err := f.stmt(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *spvFunc) stmt(s ast.Stmt) error {
+	switch st := s.(type) {
+	case *ast.AssignStmt:
+		return f.assign(st)
+	case *ast.DeclStmt:
+		return f.declStmt(st)
+	case *ast.ForStmt:
+		return f.forStmt(st)
+	case *ast.IfStmt:
+		return f.ifStmt(st)
+	case *ast.IncDecStmt:
+		ptr, t, err := f.lvalue(st.X)
+		if err != nil {
+			return err
+		}
+		if !spvIsInt(t) && t != "float" {
+			return fmt.Errorf("%s of a %s", st.Tok, t)
+		}
+		op := token.ADD
+		if st.Tok == token.DEC {
+			op = token.SUB
+		}
+		one, err := f.constant(constant.MakeInt64(1), t)
+		if err != nil {
+			return err
+		}
+		v, err := f.arith(op, f.op(opLoad, t, ptr), one)
+		if err != nil {
+			return err
+		}
+		inst(&f.body, opStore, ptr, v.id)
+		return nil
+	case *ast.BlockStmt:
+		return f.scoped(st.List)
+	case *ast.ExprStmt:
+		// Discard ends the invocation of a fragment, as a return does.
+		if call, ok := st.X.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "Discard" && len(call.Args) == 0 {
+				inst(&f.body, opKill)
+				f.closed = true
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported statement %T", s)
+	case *ast.ReturnStmt:
+		if f.outs != nil {
+			if len(st.Results) != 1 {
+				return fmt.Errorf("a vertex or fragment kernel returns exactly one value")
+			}
+			return f.output(st.Results[0])
+		}
+		if len(st.Results) == 0 {
+			inst(&f.body, opReturn)
+			f.closed = true
+			return nil
+		}
+		v, err := f.typed(st.Results[0], f.ret)
+		if err != nil {
+			return err
+		}
+		inst(&f.body, opReturnValue, v.id)
+		f.closed = true
+		return nil
+	default:
+		return fmt.Errorf("unsupported statement %T", s)
+	}
+}
+
+func (f *spvFunc) assign(st *ast.AssignStmt) error {
+	if len(st.Lhs) != 1 || len(st.Rhs) != 1 {
+		return fmt.Errorf("only single assignments are supported")
+	}
+	if st.Tok == token.DEFINE {
+		id, ok := st.Lhs[0].(*ast.Ident)
+		if !ok {
+			return fmt.Errorf("only identifiers may be declared with :=")
+		}
+		v, err := f.expr(st.Rhs[0], "")
+		if err != nil {
+			return err
+		}
+		inst(&f.body, opStore, f.declare(id.Name, v.typ), v.id)
+		return nil
+	}
+	ptr, t, err := f.lvalue(st.Lhs[0])
+	if err != nil {
+		return err
+	}
+	var v spvValue
+	if st.Tok == token.ASSIGN {
+		v, err = f.typed(st.Rhs[0], t)
+	} else {
+		op, ok := assignOp[st.Tok]
+		if !ok {
+			return fmt.Errorf("unsupported assignment %s", st.Tok)
+		}
+		var r spvValue
+		if r, err = f.expr(st.Rhs[0], spvScalar(t)); err == nil {
+			v, err = f.arith(op, f.op(opLoad, t, ptr), r)
+		}
+		if err == nil {
+			v, err = f.coerce(v, t)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	inst(&f.body, opStore, ptr, v.id)
+	return nil
+}
+
+// assignOp maps an assignment operator to its binary operator.
+var assignOp = map[token.Token]token.Token{
+	token.ADD_ASSIGN: token.ADD, token.SUB_ASSIGN: token.SUB,
+	token.MUL_ASSIGN: token.MUL, token.QUO_ASSIGN: token.QUO,
+	token.REM_ASSIGN: token.REM,
+}
+
+func (f *spvFunc) declStmt(st *ast.DeclStmt) error {
+	gd, ok := st.Decl.(*ast.GenDecl)
+	if !ok || gd.Tok != token.VAR {
+		return fmt.Errorf("unsupported declaration")
+	}
+	for _, spec := range gd.Specs {
+		vs := spec.(*ast.ValueSpec)
+		t := ""
+		if vs.Type != nil {
+			gt, _ := identType(vs.Type)
+			if t, ok = goToMSLType(gt); !ok && f.m.structs[gt] != nil {
+				if _, err := f.m.fields(gt); err != nil {
+					return err
+				}
+				t, ok = gt, true
+			}
+			if !ok {
+				return fmt.Errorf("unsupported variable type %q", gt)
+			}
+		}
+		for i, name := range vs.Names {
+			var v spvValue
+			switch {
+			case i < len(vs.Values):
+				var err error
+				if v, err = f.typed(vs.Values[i], t); err != nil {
+					return err
+				}
+			case t == "":
+				return fmt.Errorf("variable %q has neither a type nor a value", name.Name)
+			default:
+				v = spvValue{id: f.m.zero(t), typ: t}
+			}
+			inst(&f.body, opStore, f.declare(name.Name, v.typ), v.id)
+		}
+	}
+	return nil
+}
+
+// ifStmt emits a selection. Its merge block is unreachable if both branches
+// return.
+func (f *spvFunc) ifStmt(st *ast.IfStmt) error {
+	if st.Init != nil {
+		return fmt.Errorf("if statements with an init statement are not supported")
+	}
+	cond, err := f.cond(st.Cond)
+	if err != nil {
+		return err
+	}
+	m := f.m
+	then, merge := m.id(), m.id()
+	els := merge
+	if st.Else != nil {
+		els = m.id()
+	}
+	inst(&f.body, opSelectionMerge, merge, spvSelectionControl0)
+	inst(&f.body, opBranchConditional, cond.id, then, els)
+	f.block(then)
+	if err := f.scoped(st.Body.List); err != nil {
+		return err
+	}
+	open := !f.closed
+	f.branch(merge)
+	if st.Else == nil {
+		open = true
+	} else {
+		f.block(els)
+		switch e := st.Else.(type) {
+		case *ast.BlockStmt:
+			err = f.scoped(e.List)
+		case *ast.IfStmt:
+			err = f.ifStmt(e)
+		default:
+			err = fmt.Errorf("unsupported else clause")
+		}
+		if err != nil {
+			return err
+		}
+		open = open || !f.closed
+		f.branch(merge)
+	}
+	f.block(merge)
+	if !open {
+		inst(&f.body, opUnreachable)
+		f.closed = true
+	}
+	return nil
+}
+
+// forStmt emits a loop: a header that declares it, a block that tests the
+// condition and leaves to the merge block, the body, and the continue block
+// of the post statement. Without a condition there is no way out of the
+// loop, as the subset has no break, and its merge block is unreachable.
+func (f *spvFunc) forStmt(st *ast.ForStmt) error {
+	f.push()
+	defer f.pop()
+	if st.Init != nil {
+		if err := f.stmt(st.Init); err != nil {
+			return err
+		}
+	}
+	m := f.m
+	header, test, body, cont, merge := m.id(), m.id(), m.id(), m.id(), m.id()
+	f.branch(header)
+	f.block(header)
+	inst(&f.body, opLoopMerge, merge, cont, spvLoopControl0)
+	f.closed = false
+	f.branch(test)
+	f.block(test)
+	if st.Cond != nil {
+		cond, err := f.cond(st.Cond)
+		if err != nil {
+			return err
+		}
+		inst(&f.body, opBranchConditional, cond.id, body, merge)
+		f.closed = true
+	} else {
+		f.branch(body)
+	}
+	f.block(body)
+	if err := f.scoped(st.Body.List); err != nil {
+		return err
+	}
+	f.branch(cont)
+	f.block(cont)
+	if st.Post != nil {
+		if err := f.stmt(st.Post); err != nil {
+			return err
+		}
+	}
+	f.branch(header)
+	f.block(merge)
+	if st.Cond == nil {
+		inst(&f.body, opUnreachable)
+		f.closed = true
+	}
+	return nil
+}
+
+func (f *spvFunc) cond(e ast.Expr) (spvValue, error) {
+	v, err := f.expr(e, "bool")
+	if err == nil && v.typ != "bool" {
+		err = fmt.Errorf("condition is a %s, not a bool", v.typ)
+	}
+	return v, err
+}
+
+// lvalue returns the pointer to an assignable expression and its type: a
+// local, an element of a storage buffer or a component of a local vector.
+func (f *spvFunc) lvalue(e ast.Expr) (uint32, string, error) {
+	m := f.m
+	switch ex := e.(type) {
+	case *ast.Ident:
+		if l, ok := f.lookup(ex.Name); ok {
+			return l.ptr, l.typ, nil
+		}
+		if _, ok := f.bufs[ex.Name]; ok {
+			return 0, "", fmt.Errorf("cannot assign to buffer %q", ex.Name)
+		}
+		return 0, "", fmt.Errorf("undefined identifier %q", ex.Name)
+	case *ast.IndexExpr:
+		return f.element(ex)
+	case *ast.SelectorExpr:
+		id, ok := ex.X.(*ast.Ident)
+		if !ok {
+			break
+		}
+		l, ok := f.lookup(id.Name)
+		if ok && m.structs[l.typ] != nil {
+			fs, _ := m.fields(l.typ)
+			for i, fd := range fs {
+				if fd.name == ex.Sel.Name {
+					ptr := m.id()
+					inst(&f.body, opAccessChain, m.ptr(spvClassFunction, m.typ(fd.typ)), ptr, l.ptr, m.constInt(int32(i)))
+					return ptr, fd.typ, nil
+				}
+			}
+			return 0, "", fmt.Errorf("%s has no field %s", id.Name, ex.Sel.Name)
+		}
+		if !ok || spvVecLen(l.typ) == 0 {
+			break
+		}
+		k := strings.IndexByte("xyzw", strings.ToLower(ex.Sel.Name)[0])
+		if len(ex.Sel.Name) != 1 || k < 0 || k >= spvVecLen(l.typ) {
+			return 0, "", fmt.Errorf("cannot assign to %s.%s", id.Name, ex.Sel.Name)
+		}
+		t := spvScalar(l.typ)
+		ptr := m.id()
+		inst(&f.body, opAccessChain, m.ptr(spvClassFunction, m.typ(t)), ptr, l.ptr, m.constInt(int32(k)))
+		return ptr, t, nil
+	case *ast.ParenExpr:
+		return f.lvalue(ex.X)
+	}
+	return 0, "", fmt.Errorf("unsupported assignment target %T", e)
+}
+
+// element returns the pointer to the element of a storage buffer indexed by
+// ex.
+func (f *spvFunc) element(ex *ast.IndexExpr) (uint32, string, error) {
+	id, ok := ex.X.(*ast.Ident)
+	if !ok {
+		return 0, "", fmt.Errorf("only buffer parameters can be indexed")
+	}
+	b, ok := f.bufs[id.Name]
+	if !ok {
+		return 0, "", fmt.Errorf("only buffer parameters can be indexed, not %q", id.Name)
+	}
+	i, err := f.expr(ex.Index, "int")
+	if err != nil {
+		return 0, "", err
+	}
+	if !spvIsInt(i.typ) {
+		return 0, "", fmt.Errorf("index of %q is a %s", id.Name, i.typ)
+	}
+	m := f.m
+	ptr := m.id()
+	inst(&f.body, opAccessChain, m.ptr(spvClassUniform, m.typ(b.elem)), ptr, b.v, m.constInt(0), i.id)
+	return ptr, b.elem, nil
+}
+
+// typed evaluates e as a value of type t: an untyped constant takes the
+// type, and an integer the signedness of t.
+func (f *spvFunc) typed(e ast.Expr, t string) (spvValue, error) {
+	v, err := f.expr(e, spvScalar(t))
+	if err != nil || t == "" {
+		return v, err
+	}
+	return f.coerce(v, t)
+}
+
+// coerce converts v to the type t where the subset allows it implicitly:
+// between int and uint, which the kernels mix freely as GLSL's int id
+// allows. Everything else must match.
+func (f *spvFunc) coerce(v spvValue, t string) (spvValue, error) {
+	switch {
+	case v.typ == t:
+		return v, nil
+	case spvIsInt(v.typ) && spvIsInt(t):
+		return f.op(opBitcast, t, v.id), nil
+	}
+	return v, fmt.Errorf("cannot use a %s as a %s", v.typ, t)
+}
+
+// constExpr folds e if it is an untyped constant expression: literals and
+// the operators on them, as Go folds them, exactly.
+func constExpr(e ast.Expr) (constant.Value, bool) {
+	switch ex := e.(type) {
+	case *ast.BasicLit:
+		v := constant.MakeFromLiteral(ex.Value, ex.Kind, 0)
+		return v, v.Kind() == constant.Int || v.Kind() == constant.Float
+	case *ast.Ident:
+		switch ex.Name {
+		case "true":
+			return constant.MakeBool(true), true
+		case "false":
+			return constant.MakeBool(false), true
+		}
+	case *ast.ParenExpr:
+		return constExpr(ex.X)
+	case *ast.UnaryExpr:
+		x, ok := constExpr(ex.X)
+		if !ok || (ex.Op != token.SUB && ex.Op != token.ADD && ex.Op != token.NOT) {
+			return nil, false
+		}
+		return constant.UnaryOp(ex.Op, x, 0), true
+	case *ast.BinaryExpr:
+		x, ok := constExpr(ex.X)
+		if !ok {
+			return nil, false
+		}
+		y, ok := constExpr(ex.Y)
+		if !ok {
+			return nil, false
+		}
+		switch ex.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return constant.MakeBool(constant.Compare(x, ex.Op, y)), true
+		case token.QUO:
+			if constant.Sign(y) == 0 {
+				return nil, false
+			}
+			if x.Kind() == constant.Int && y.Kind() == constant.Int {
+				return constant.BinaryOp(x, token.QUO_ASSIGN, y), true // integer division
+			}
+		case token.REM:
+			if constant.Sign(y) == 0 || x.Kind() != constant.Int || y.Kind() != constant.Int {
+				return nil, false
+			}
+		case token.ADD, token.SUB, token.MUL, token.LAND, token.LOR:
+		default:
+			return nil, false
+		}
+		return constant.BinaryOp(x, ex.Op, y), true
+	}
+	return nil, false
+}
+
+// constant emits the constant v as a value of type t, or of its default
+// type if t is empty.
+func (f *spvFunc) constant(v constant.Value, t string) (spvValue, error) {
+	if t == "" || (t == "bool") != (v.Kind() == constant.Bool) {
+		switch v.Kind() {
+		case constant.Bool:
+			t = "bool"
+		case constant.Int:
+			t = "int"
+		default:
+			t = "float"
+		}
+	}
+	m := f.m
+	switch t {
+	case "bool":
+		w := uint32(0)
+		if constant.BoolVal(v) {
+			w = 1
+		}
+		return spvValue{id: m.constant(t, w), typ: t}, nil
+	case "float":
+		x, _ := constant.Float32Val(constant.ToFloat(v))
+		return spvValue{id: m.constant(t, math.Float32bits(x)), typ: t}, nil
+	case "int", "uint":
+		i := constant.ToInt(v)
+		if i.Kind() != constant.Int {
+			return spvValue{}, fmt.Errorf("constant %s truncated to %s", v, t)
+		}
+		x, ok := constant.Int64Val(i)
+		if !ok || (t == "int" && (x < math.MinInt32 || x > math.MaxInt32)) || (t == "uint" && (x < 0 || x > math.MaxUint32)) {
+			return spvValue{}, fmt.Errorf("constant %s overflows %s", v, t)
+		}
+		return spvValue{id: m.constant(t, uint32(x)), typ: t}, nil
+	}
+	return spvValue{}, fmt.Errorf("constant %s cannot be a %s", v, t)
+}
+
+// expr evaluates e. want is the type an untyped constant in e takes, as an
+// operand of a typed expression; "" leaves it its default type.
+func (f *spvFunc) expr(e ast.Expr, want string) (spvValue, error) {
+	if v, ok := constExpr(e); ok {
+		return f.constant(v, want)
+	}
+	switch ex := e.(type) {
+	case *ast.Ident:
+		if l, ok := f.lookup(ex.Name); ok {
+			return f.op(opLoad, l.typ, l.ptr), nil
+		}
+		_, b := f.bufs[ex.Name]
+		_, u := f.unis[ex.Name]
+		_, i := f.images[ex.Name]
+		if b || u || i {
+			return spvValue{}, fmt.Errorf("parameter %q cannot be used as a value", ex.Name)
+		}
+		return spvValue{}, fmt.Errorf("undefined identifier %q", ex.Name)
+	case *ast.BasicLit:
+		return spvValue{}, fmt.Errorf("unsupported literal %s", ex.Value)
+	case *ast.ParenExpr:
+		return f.expr(ex.X, want)
+	case *ast.UnaryExpr:
+		return f.unary(ex, want)
+	case *ast.BinaryExpr:
+		return f.binary(ex, want)
+	case *ast.IndexExpr:
+		ptr, t, err := f.element(ex)
+		if err != nil {
+			return spvValue{}, err
+		}
+		return f.op(opLoad, t, ptr), nil
+	case *ast.SelectorExpr:
+		return f.selector(ex)
+	case *ast.CallExpr:
+		return f.call(ex, want)
+	case *ast.CompositeLit:
+		return f.compositeLit(ex)
+	}
+	return spvValue{}, fmt.Errorf("unsupported expression %T", e)
+}
+
+func (f *spvFunc) unary(ex *ast.UnaryExpr, want string) (spvValue, error) {
+	x, err := f.expr(ex.X, want)
+	if err != nil {
+		return x, err
+	}
+	switch {
+	case ex.Op == token.ADD && x.typ != "bool":
+		return x, nil
+	case ex.Op == token.SUB && spvIsFloat(x.typ):
+		return f.op(opFNegate, x.typ, x.id), nil
+	case ex.Op == token.SUB && spvIsInt(x.typ):
+		return f.op(opSNegate, x.typ, x.id), nil
+	case ex.Op == token.NOT && x.typ == "bool":
+		return f.op(opLogicalNot, x.typ, x.id), nil
+	}
+	return x, fmt.Errorf("unsupported operator %s on a %s", ex.Op, x.typ)
+}
+
+// operands evaluates the operands x and y of a binary operation. The typed
+// one goes first, so that an untyped constant on the other side takes its
+// type.
+func (f *spvFunc) operands(x, y ast.Expr, want string) (l, r spvValue, err error) {
+	if _, ok := constExpr(x); ok {
+		if r, err = f.expr(y, want); err == nil {
+			l, err = f.expr(x, spvScalar(r.typ))
+		}
+		return l, r, err
+	}
+	if l, err = f.expr(x, want); err == nil {
+		r, err = f.expr(y, spvScalar(l.typ))
+	}
+	return l, r, err
+}
+
+func (f *spvFunc) binary(ex *ast.BinaryExpr, want string) (spvValue, error) {
+	switch ex.Op {
+	case token.LAND, token.LOR:
+		return f.logical(ex)
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		want = ""
+	}
+	l, r, err := f.operands(ex.X, ex.Y, want)
+	if err != nil {
+		return l, err
+	}
+	return f.arith(ex.Op, l, r)
+}
+
+// logical emits && and || with Go's short circuit: the right operand is
+// only evaluated, in a block of its own, if the left one does not decide.
+// The right operand of a bounds test may index out of the buffer otherwise.
+func (f *spvFunc) logical(ex *ast.BinaryExpr) (spvValue, error) {
+	l, err := f.cond(ex.X)
+	if err != nil {
+		return l, err
+	}
+	m := f.m
+	from, rhs, merge := f.label, m.id(), m.id()
+	inst(&f.body, opSelectionMerge, merge, spvSelectionControl0)
+	if ex.Op == token.LAND {
+		inst(&f.body, opBranchConditional, l.id, rhs, merge)
+	} else {
+		inst(&f.body, opBranchConditional, l.id, merge, rhs)
+	}
+	f.block(rhs)
+	r, err := f.cond(ex.Y)
+	if err != nil {
+		return r, err
+	}
+	end := f.label
+	f.branch(merge)
+	f.block(merge)
+	return f.op(opPhi, "bool", l.id, from, r.id, end), nil
+}
+
+// arith applies the binary operator op to l and r: componentwise on vectors
+// of the same type, with a scalar operand splatted to the vector of the
+// other, and as linear algebra on a matrix.
+func (f *spvFunc) arith(op token.Token, l, r spvValue) (spvValue, error) {
+	var err error
+	switch {
+	case spvIsInt(l.typ) && spvIsInt(r.typ) && l.typ != r.typ:
+		// int and uint mix as GLSL's int id does: the uint is converted.
+		if l.typ == "uint" {
+			l, err = f.coerce(l, "int")
+		} else {
+			r, err = f.coerce(r, "int")
+		}
+	case l.typ == "float4x4" && op == token.MUL:
+		switch r.typ {
+		case "float4":
+			return f.exact(opMatrixTimesVector, r.typ, l.id, r.id), nil
+		case "float4x4":
+			return f.exact(opMatrixTimesMatrix, r.typ, l.id, r.id), nil
+		case "float":
+			return f.exact(opMatrixTimesScalar, l.typ, l.id, r.id), nil
+		}
+	case spvVecLen(l.typ) > 0 && r.typ == spvScalar(l.typ):
+		r = f.splat(r, l.typ)
+	case spvVecLen(r.typ) > 0 && l.typ == spvScalar(r.typ):
+		l = f.splat(l, r.typ)
+	}
+	if err != nil {
+		return l, err
+	}
+	if l.typ != r.typ {
+		return l, fmt.Errorf("mismatched types %s and %s for %s", l.typ, r.typ, op)
+	}
+	t := l.typ
+	s := spvScalar(t)
+	if s != t && !(spvVecLen(t) > 0 && s == "float") {
+		return l, fmt.Errorf("unsupported operator %s on a %s", op, t)
+	}
+	if s != t {
+		switch op {
+		case token.ADD, token.SUB, token.MUL, token.QUO:
+		default:
+			return l, fmt.Errorf("unsupported operator %s on a %s", op, t)
+		}
+	}
+	var code uint32
+	switch s {
+	case "float":
+		code = map[token.Token]uint32{
+			token.ADD: opFAdd, token.SUB: opFSub, token.MUL: opFMul, token.QUO: opFDiv,
+			token.EQL: opFOrdEqual, token.NEQ: opFUnordNotEqual,
+			token.LSS: opFOrdLessThan, token.LEQ: opFOrdLessThanEqual,
+			token.GTR: opFOrdGreaterThan, token.GEQ: opFOrdGreaterThanEq,
+		}[op]
+	case "int":
+		code = map[token.Token]uint32{
+			token.ADD: opIAdd, token.SUB: opISub, token.MUL: opIMul, token.QUO: opSDiv, token.REM: opSRem,
+			token.EQL: opIEqual, token.NEQ: opINotEqual,
+			token.LSS: opSLessThan, token.LEQ: opSLessThanEqual,
+			token.GTR: opSGreaterThan, token.GEQ: opSGreaterThanEqual,
+		}[op]
+	case "uint":
+		code = map[token.Token]uint32{
+			token.ADD: opIAdd, token.SUB: opISub, token.MUL: opIMul, token.QUO: opUDiv, token.REM: opUMod,
+			token.EQL: opIEqual, token.NEQ: opINotEqual,
+			token.LSS: opULessThan, token.LEQ: opULessThanEqual,
+			token.GTR: opUGreaterThan, token.GEQ: opUGreaterThanEqual,
+		}[op]
+	case "bool":
+		code = map[token.Token]uint32{token.EQL: opLogicalEqual, token.NEQ: opLogicalNotEqual}[op]
+	}
+	if code == 0 {
+		return l, fmt.Errorf("unsupported operator %s on a %s", op, t)
+	}
+	switch op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return f.op(code, "bool", l.id, r.id), nil
+	}
+	if code == opFAdd || code == opFSub || code == opFMul {
+		return f.exact(code, t, l.id, r.id), nil
+	}
+	return f.op(code, t, l.id, r.id), nil
+}
+
+// splat returns the vector of type t whose components are all s.
+func (f *spvFunc) splat(s spvValue, t string) spvValue {
+	ids := make([]uint32, spvVecLen(t))
+	for i := range ids {
+		ids[i] = s.id
+	}
+	return f.op(opCompositeConstruct, t, ids...)
+}
+
+// convert converts v to the scalar type t, as a Go conversion does.
+func (f *spvFunc) convert(v spvValue, t string) (spvValue, error) {
+	switch {
+	case v.typ == t:
+		return v, nil
+	case v.typ == "float" && t == "int":
+		return f.op(opConvertFToS, t, v.id), nil
+	case v.typ == "float" && t == "uint":
+		return f.op(opConvertFToU, t, v.id), nil
+	case v.typ == "int" && t == "float":
+		return f.op(opConvertSToF, t, v.id), nil
+	case v.typ == "uint" && t == "float":
+		return f.op(opConvertUToF, t, v.id), nil
+	case spvIsInt(v.typ) && spvIsInt(t):
+		return f.op(opBitcast, t, v.id), nil
+	}
+	return v, fmt.Errorf("cannot convert a %s to %s", v.typ, t)
+}
+
+// selector evaluates a field of a uniform or a struct, a component or
+// swizzle of a vector, or a column of a matrix.
+func (f *spvFunc) selector(ex *ast.SelectorExpr) (spvValue, error) {
+	m := f.m
+	if id, ok := ex.X.(*ast.Ident); ok {
+		if u, ok := f.unis[id.Name]; ok {
+			for i, name := range u.fields {
+				if name == ex.Sel.Name {
+					t := u.types[i]
+					ptr := m.id()
+					inst(&f.body, opAccessChain, m.ptr(spvClassUniform, m.typ(t)), ptr, u.v, m.constInt(int32(i)))
+					return f.op(opLoad, t, ptr), nil
+				}
+			}
+			return spvValue{}, fmt.Errorf("%s has no field %s", id.Name, ex.Sel.Name)
+		}
+	}
+	x, err := f.expr(ex.X, "")
+	if err != nil {
+		return x, err
+	}
+	if m.structs[x.typ] != nil {
+		fs, _ := m.fields(x.typ)
+		for i, fd := range fs {
+			if fd.name == ex.Sel.Name {
+				return f.op(opCompositeExtract, fd.typ, x.id, uint32(i)), nil
+			}
+		}
+		return x, fmt.Errorf("%s has no field %s", x.typ, ex.Sel.Name)
+	}
+	if x.typ == "float4x4" {
+		if k := strings.Index("C0C1C2C3", ex.Sel.Name); len(ex.Sel.Name) == 2 && k >= 0 && k%2 == 0 {
+			return f.op(opCompositeExtract, "float4", x.id, uint32(k/2)), nil
+		}
+	}
+	if n := spvVecLen(x.typ); n > 0 {
+		if sw := strings.ToLower(ex.Sel.Name); isSwizzle(sw) {
+			idx := make([]uint32, len(sw))
+			for i := range sw {
+				idx[i] = uint32(strings.IndexByte("xyzw", sw[i]))
+				if int(idx[i]) >= n {
+					return x, fmt.Errorf("%s has no component %s", x.typ, ex.Sel.Name)
+				}
+			}
+			s := spvScalar(x.typ)
+			if len(idx) == 1 {
+				return f.op(opCompositeExtract, s, x.id, idx[0]), nil
+			}
+			t := fmt.Sprintf("%s%d", s, len(idx))
+			return f.op(opVectorShuffle, t, append([]uint32{x.id, x.id}, idx...)...), nil
+		}
+	}
+	return x, fmt.Errorf("unsupported selector .%s on a %s", ex.Sel.Name, x.typ)
+}
+
+// compositeLit builds a gpumath vector or matrix, or a struct, from its
+// positional or keyed elements, the missing ones zero.
+func (f *spvFunc) compositeLit(ex *ast.CompositeLit) (spvValue, error) {
+	tname, _ := identType(ex.Type)
+	t, ok := goToMSLType(tname)
+	if !ok && f.m.structs[tname] != nil {
+		return f.structLit(tname, ex)
+	}
+	if !ok || spvIsInt(t) || t == "float" {
+		return spvValue{}, fmt.Errorf("unsupported composite type %q", tname)
+	}
+	fields, elem := "XYZW", "float"
+	if t == "float4x4" {
+		fields, elem = "C0C1C2C3", "float4"
+	}
+	n := len(fields) / len(elem) // 4 fields, or the components of a vector
+	if t != "float4x4" {
+		n = spvVecLen(t)
+	}
+	width := len(fields) / 4
+	elems := make([]ast.Expr, n)
+	for i, e := range ex.Elts {
+		k := i
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			key, _ := kv.Key.(*ast.Ident)
+			if key == nil || strings.Index(fields, key.Name) < 0 || len(key.Name) != width {
+				return spvValue{}, fmt.Errorf("%s has no field %v", tname, kv.Key)
+			}
+			k, e = strings.Index(fields, key.Name)/width, kv.Value
+		}
+		if k >= n {
+			return spvValue{}, fmt.Errorf("too many elements in %s literal", tname)
+		}
+		elems[k] = e
+	}
+	ids := make([]uint32, n)
+	for i, e := range elems {
+		if e == nil {
+			ids[i] = f.m.zero(elem)
+			continue
+		}
+		v, err := f.typed(e, elem)
+		if err != nil {
+			return v, err
+		}
+		ids[i] = v.id
+	}
+	return f.op(opCompositeConstruct, t, ids...), nil
+}
+
+// structLit builds the struct t of the literal ex.
+func (f *spvFunc) structLit(t string, ex *ast.CompositeLit) (spvValue, error) {
+	fs, err := f.m.fields(t)
+	if err != nil {
+		return spvValue{}, err
+	}
+	elems := make([]ast.Expr, len(fs))
+	for i, e := range ex.Elts {
+		k := i
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			key, _ := kv.Key.(*ast.Ident)
+			k = slices.IndexFunc(fs, func(fd spvField) bool { return key != nil && fd.name == key.Name })
+			if k < 0 {
+				return spvValue{}, fmt.Errorf("%s has no field %v", t, kv.Key)
+			}
+			e = kv.Value
+		}
+		if k >= len(fs) {
+			return spvValue{}, fmt.Errorf("too many elements in %s literal", t)
+		}
+		elems[k] = e
+	}
+	ids := make([]uint32, len(fs))
+	for i, e := range elems {
+		if e == nil {
+			ids[i] = f.m.zero(fs[i].typ)
+			continue
+		}
+		v, err := f.typed(e, fs[i].typ)
+		if err != nil {
+			return v, err
+		}
+		ids[i] = v.id
+	}
+	return f.op(opCompositeConstruct, t, ids...), nil
+}
+
+// construct builds the vector or matrix t of a gpumath constructor: of its
+// components, of vectors and scalars that add up to them, or of one scalar.
+func (f *spvFunc) construct(t string, args []ast.Expr) (spvValue, error) {
+	var ids []uint32
+	n := 0
+	for _, a := range args {
+		v, err := f.expr(a, "float")
+		if err != nil {
+			return v, err
+		}
+		switch {
+		case t == "float4x4" && v.typ == "float4":
+			n++
+		case t != "float4x4" && v.typ == "float":
+			n++
+		case t != "float4x4" && spvScalar(v.typ) == "float":
+			n += spvVecLen(v.typ)
+		default:
+			return v, fmt.Errorf("cannot construct a %s of a %s", t, v.typ)
+		}
+		ids = append(ids, v.id)
+	}
+	want := 4
+	if t != "float4x4" {
+		want = spvVecLen(t)
+		if len(ids) == 1 && n == 1 {
+			return f.splat(spvValue{id: ids[0], typ: "float"}, t), nil
+		}
+	}
+	if n != want {
+		return spvValue{}, fmt.Errorf("%s takes %d components, got %d", t, want, n)
+	}
+	return f.op(opCompositeConstruct, t, ids...), nil
+}
+
+func (f *spvFunc) call(ex *ast.CallExpr, want string) (spvValue, error) {
+	if sel, ok := ex.Fun.(*ast.SelectorExpr); ok {
+		return f.method(sel, ex.Args)
+	}
+	id, ok := ex.Fun.(*ast.Ident)
+	if !ok {
+		return spvValue{}, fmt.Errorf("unsupported call target")
+	}
+	if t, ok := vecCtor[id.Name]; ok {
+		return f.construct(t, ex.Args)
+	}
+	if id.Name == "FrontFacing" {
+		if f.front == 0 {
+			return spvValue{}, fmt.Errorf("FrontFacing is only available in a fragment kernel")
+		}
+		return f.op(opLoad, "bool", f.front), nil
+	}
+	if h, ok := f.m.helpers[id.Name]; ok {
+		if len(ex.Args) != len(h.types) {
+			return spvValue{}, fmt.Errorf("%s takes %d arguments, got %d", id.Name, len(h.types), len(ex.Args))
+		}
+		ops := []uint32{h.id}
+		for i, a := range ex.Args {
+			v, err := f.typed(a, h.types[i])
+			if err != nil {
+				return v, err
+			}
+			ops = append(ops, v.id)
+		}
+		return f.op(opFunctionCall, h.ret, ops...), nil
+	}
+	name, ok := builtins[id.Name]
+	if !ok {
+		return spvValue{}, fmt.Errorf("call to %q is not in the builtin/conversion whitelist", id.Name)
+	}
+	switch name {
+	case "float", "int", "uint":
+		if len(ex.Args) != 1 {
+			return spvValue{}, fmt.Errorf("conversion to %s takes one argument", id.Name)
+		}
+		if c, ok := constExpr(ex.Args[0]); ok {
+			return f.constant(c, name)
+		}
+		v, err := f.expr(ex.Args[0], "")
+		if err != nil {
+			return v, err
+		}
+		return f.convert(v, name)
+	}
+	return f.builtin(name, ex.Args, want)
+}
+
+// method evaluates a gpumath method call or an image load.
+func (f *spvFunc) method(sel *ast.SelectorExpr, args []ast.Expr) (spvValue, error) {
+	name := sel.Sel.Name
+	if id, ok := sel.X.(*ast.Ident); ok {
+		if img, ok := f.images[id.Name]; ok && name == "Load" {
+			// Image.Load(x, y) reads a texel; the rows of a Vulkan texture
+			// run bottom-up as on GL, see gpu.Texture.
+			if len(args) != 2 {
+				return spvValue{}, fmt.Errorf("method %q takes two arguments", name)
+			}
+			var xy [2]uint32
+			for i, a := range args {
+				v, err := f.typed(a, "int")
+				if err != nil {
+					return v, err
+				}
+				xy[i] = v.id
+			}
+			coord := f.op(opCompositeConstruct, "int2", xy[0], xy[1])
+			image := f.m.id() // an image has no canonical type
+			inst(&f.body, opLoad, img.typ, image, img.v)
+			return f.op(opImageRead, "float4", image, coord.id), nil
+		}
+	}
+	x, err := f.expr(sel.X, "")
+	if err != nil {
+		return x, err
+	}
+	if op, ok := vecMethodOp[name]; ok {
+		if len(args) != 1 {
+			return spvValue{}, fmt.Errorf("method %q takes one argument", name)
+		}
+		y, err := f.expr(args[0], spvScalar(x.typ))
+		if err != nil {
+			return y, err
+		}
+		return f.arith(map[string]token.Token{"+": token.ADD, "-": token.SUB, "*": token.MUL, "/": token.QUO}[op], x, y)
+	}
+	switch name {
+	case "Dot":
+		if len(args) != 1 {
+			return spvValue{}, fmt.Errorf("method %q takes one argument", name)
+		}
+		y, err := f.expr(args[0], "")
+		if err != nil {
+			return y, err
+		}
+		return f.dot(x, y)
+	case "Length", "Normalize":
+		if len(args) != 0 || !spvIsFloat(x.typ) || x.typ == "float4x4" {
+			return spvValue{}, fmt.Errorf("unsupported method %q on a %s", name, x.typ)
+		}
+		if name == "Length" {
+			return f.ext("float", spvFloatExt["length"], x), nil
+		}
+		return f.ext(x.typ, spvFloatExt["normalize"], x), nil
+	}
+	return spvValue{}, fmt.Errorf("unsupported method %q", name)
+}
+
+func (f *spvFunc) dot(x, y spvValue) (spvValue, error) {
+	switch {
+	case x.typ != y.typ || !spvIsFloat(x.typ) || x.typ == "float4x4":
+		return x, fmt.Errorf("dot of a %s and a %s", x.typ, y.typ)
+	case x.typ == "float":
+		return f.exact(opFMul, "float", x.id, y.id), nil
+	}
+	return f.exact(opDot, "float", x.id, y.id), nil
+}
+
+// builtin evaluates a call of the builtin of canonical name.
+func (f *spvFunc) builtin(name string, args []ast.Expr, want string) (spvValue, error) {
+	// The typed arguments go first, so that the untyped constants take
+	// their type; float builtins take floats anyway.
+	vals := make([]spvValue, len(args))
+	anchor := ""
+	if _, ok := spvTypedExt[name]; !ok {
+		anchor = "float"
+	} else if spvIsInt(want) || want == "float" {
+		anchor = want
+	}
+	for i, a := range args {
+		if _, ok := constExpr(a); ok {
+			continue
+		}
+		v, err := f.expr(a, anchor)
+		if err != nil {
+			return v, err
+		}
+		vals[i] = v
+		if anchor == "" {
+			anchor = spvScalar(v.typ)
+		}
+	}
+	for i, a := range args {
+		if vals[i].id != 0 {
+			continue
+		}
+		v, err := f.expr(a, anchor)
+		if err != nil {
+			return v, err
+		}
+		vals[i] = v
+	}
+	if len(vals) == 0 {
+		return spvValue{}, fmt.Errorf("%s takes arguments", name)
+	}
+
+	// Scalars splat to the vector of a vector argument, as GLSL's
+	// clamp(v, 0.0, 1.0) and mix(a, b, t).
+	t := vals[0].typ
+	for _, v := range vals {
+		if spvVecLen(v.typ) > 0 {
+			t = v.typ
+		}
+	}
+	for i, v := range vals {
+		if v.typ != t && spvVecLen(t) > 0 && v.typ == spvScalar(t) && name != "dot" {
+			vals[i] = f.splat(v, t)
+		} else if v.typ != t {
+			c, err := f.coerce(v, t)
+			if err != nil {
+				return v, fmt.Errorf("%s: %w", name, err)
+			}
+			vals[i] = c
+		}
+	}
+
+	arity := map[string]int{"pow": 2, "cross": 2, "reflect": 2, "dot": 2, "min": 2, "max": 2, "clamp": 3, "mix": 3}
+	n, ok := arity[name]
+	if !ok {
+		n = 1
+		if name == "atan" && len(vals) == 2 {
+			n = 2
+		}
+	}
+	if len(vals) != n {
+		return spvValue{}, fmt.Errorf("%s takes %d arguments, got %d", name, n, len(vals))
+	}
+
+	if variants, ok := spvTypedExt[name]; ok {
+		switch spvScalar(t) {
+		case "float":
+			return f.ext(t, variants[0], vals...), nil
+		case "int":
+			return f.ext(t, variants[1], vals...), nil
+		case "uint":
+			if name == "abs" {
+				return vals[0], nil
+			}
+			return f.ext(t, variants[2], vals...), nil
+		}
+		return spvValue{}, fmt.Errorf("%s of a %s", name, t)
+	}
+	if !spvIsFloat(t) || t == "float4x4" {
+		return spvValue{}, fmt.Errorf("%s of a %s", name, t)
+	}
+	switch name {
+	case "dot":
+		return f.dot(vals[0], vals[1])
+	case "round":
+		return f.round(vals[0]), nil
+	case "mix":
+		return f.ext(t, spvExtFMix, vals...), nil
+	case "atan":
+		if n == 2 {
+			return f.ext(t, spvExtAtan2, vals...), nil
+		}
+		return f.ext(t, spvExtAtan, vals...), nil
+	case "length":
+		return f.ext("float", spvFloatExt[name], vals...), nil
+	case "dfdx":
+		return f.op(opDPdx, t, vals[0].id), nil
+	case "dfdy":
+		return f.op(opDPdy, t, vals[0].id), nil
+	case "cross":
+		if t != "float3" {
+			return spvValue{}, fmt.Errorf("cross of a %s", t)
+		}
+	}
+	instr, ok := spvFloatExt[name]
+	if !ok {
+		return spvValue{}, fmt.Errorf("builtin %q is not supported by the SPIR-V backend", name)
+	}
+	return f.ext(t, instr, vals...), nil
+}
+
+// round rounds x half away from zero as Go's math.Round does. GLSL.std.450
+// Round leaves the halfway cases to the driver, and Mesa rounds them to even.
+// The fraction x-trunc(x) is exact, so the test of the half is.
+func (f *spvFunc) round(x spvValue) spvValue {
+	if n := spvVecLen(x.typ); n > 0 {
+		ids := make([]uint32, n)
+		for i := range ids {
+			c := f.op(opCompositeExtract, "float", x.id, uint32(i))
+			ids[i] = f.round(c).id
+		}
+		return f.op(opCompositeConstruct, x.typ, ids...)
+	}
+	t := f.ext("float", spvFloatExt["trunc"], x)
+	frac := f.ext("float", spvTypedExt["abs"][0], f.exact(opFSub, "float", x.id, t.id))
+	half := f.op(opFOrdGreaterThanEq, "bool", frac.id, f.m.constant("float", math.Float32bits(0.5)))
+	away := f.exact(opFAdd, "float", t.id, f.ext("float", spvExtFSign, x).id)
+	return f.op(opSelect, "float", half.id, away.id, t.id)
+}
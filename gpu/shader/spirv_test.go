@@ -0,0 +1,218 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shader
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	kernelpkg "poly.red/gpu/shader/gpumath/kernels"
+)
+
+// spirvKernels are the engine's author-once kernels by entry point.
+var spirvKernels = map[string]string{
+	"Shade":     kernelpkg.ShadeSrc,
+	"SRGB":      kernelpkg.SRGBSrc,
+	"Shadow":    kernelpkg.ShadowSrc,
+	"AO":        kernelpkg.AOSrc,
+	"AOBlur":    kernelpkg.AOBlurSrc,
+	"OIT":       kernelpkg.OITSrc,
+	"Composite": kernelpkg.CompositeSrc,
+	"FXAA":      kernelpkg.FXAASrc,
+	"TAA":       kernelpkg.TAASrc,
+	"GBuffer":   kernelpkg.GBufferSrc,
+	"Sample":    kernelpkg.SampleSrc,
+
+	"Forward":         kernelpkg.ForwardSrc,
+	"ForwardGBuffer":  kernelpkg.ForwardSrc,
+	"ForwardCoverage": kernelpkg.ForwardSrc,
+	"ForwardPeel":     kernelpkg.ForwardSrc,
+}
+
+// TestCompileSPIRV checks that every engine kernel and the compiler fixtures
+// compile to a well-formed SPIR-V module: the header, the word count and the
+// entry point "main" of the execution model of its stage. When spirv-val is on PATH (the vk-probe CI job
+// installs it) the modules are also validated for Vulkan 1.0. Running them is
+// TestVulkanKernels in gpumath/kernels, gated on a Vulkan device.
+func TestCompileSPIRV(t *testing.T) {
+	srcs := map[string]string{
+		"Mul":   kernels,
+		"Shade": uniformSceneKernelSrc,
+		"VMain": vertFragKernelSrc,
+		"FMain": vertFragKernelSrc,
+	}
+	for entry, src := range spirvKernels {
+		srcs["kernels."+entry] = src
+	}
+	val, _ := exec.LookPath("spirv-val")
+	for name, src := range srcs {
+		entry := name[strings.LastIndex(name, ".")+1:]
+		ks, err := CompileSPIRV(src)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		k, ok := ks[entry]
+		if !ok {
+			t.Fatalf("%s: kernel %q not compiled", name, entry)
+		}
+		checkSPIRV(t, name, k.SPIRV, spvModels[k.Stage])
+		if val == "" {
+			continue
+		}
+		f := filepath.Join(t.TempDir(), entry+".spv")
+		if err := os.WriteFile(f, k.SPIRV, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command(val, "--target-env", "vulkan1.0", f).CombinedOutput(); err != nil {
+			t.Errorf("%s: spirv-val: %v\n%s", name, err, out)
+		}
+	}
+}
+
+// spvModels are the execution models of the stages.
+var spvModels = map[Stage]uint32{StageCompute: 5, StageVertex: 0, StageFragment: 4}
+
+// checkSPIRV checks the module header and that the instruction stream ends
+// exactly at the end of the module and declares a "main" of the execution
+// model.
+func checkSPIRV(t *testing.T, name string, b []byte, model uint32) {
+	t.Helper()
+	w := spvWords(t, name, b)
+	if w[0] != 0x07230203 || w[1] != 0x00010000 || w[3] == 0 || w[4] != 0 {
+		t.Fatalf("%s: bad header %#x", name, w[:5])
+	}
+	entry := false
+	for i := 5; i < len(w); {
+		op, n := w[i]&0xffff, int(w[i]>>16)
+		if n == 0 || i+n > len(w) {
+			t.Fatalf("%s: bad instruction at word %d", name, i)
+		}
+		if op == opEntryPoint && w[i+1] == model {
+			entry = decodeString(w[i+3:i+n]) == "main"
+		}
+		i += n
+	}
+	if !entry {
+		t.Fatalf("%s: no entry point main of execution model %d", name, model)
+	}
+}
+
+// spvWords returns the words of the module b.
+func spvWords(t *testing.T, name string, b []byte) []uint32 {
+	t.Helper()
+	if len(b) < 20 || len(b)%4 != 0 {
+		t.Fatalf("%s: SPIR-V of %d bytes", name, len(b))
+	}
+	w := make([]uint32, len(b)/4)
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return w
+}
+
+// decodeString decodes a nul-terminated SPIR-V literal string.
+func decodeString(w []uint32) string {
+	var sb strings.Builder
+	for _, x := range w {
+		for j := 0; j < 4; j++ {
+			c := byte(x >> (8 * j))
+			if c == 0 {
+				return sb.String()
+			}
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// TestCompileSPIRVBindings checks the binding metadata of a kernel mixing
+// images and storage buffers: each has its own index space, numbered in
+// parameter order.
+func TestCompileSPIRVBindings(t *testing.T) {
+	ks, err := CompileSPIRV(kernelpkg.GBufferSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imgs, bufs int
+	for _, b := range ks["GBuffer"].Bindings {
+		switch b.Kind {
+		case StorageTexture:
+			if b.Index != imgs {
+				t.Fatalf("image %s: index %d, want %d", b.Name, b.Index, imgs)
+			}
+			imgs++
+		case StorageBuffer:
+			if b.Index != bufs {
+				t.Fatalf("buffer %s: index %d, want %d", b.Name, b.Index, bufs)
+			}
+			bufs++
+		default:
+			t.Fatalf("%s: unexpected binding kind %v", b.Name, b.Kind)
+		}
+	}
+	if imgs == 0 || bufs == 0 {
+		t.Fatalf("GBuffer: %d images, %d buffers", imgs, bufs)
+	}
+}
+
+// TestCompileSPIRVStages checks the interface of the stages of the GPU
+// forward pass: the builtins, the Locations of the varyings and targets,
+// the flat material index, and the instructions of Discard and the
+// derivatives.
+func TestCompileSPIRVStages(t *testing.T) {
+	ks, err := CompileSPIRV(kernelpkg.ForwardSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		entry     string
+		builtins  []uint32 // in the order of declaration
+		locations int
+		ops       []uint32
+	}{
+		// VertexIndex, Position; World, Normal, Tangent, UV, Mat.
+		{"Forward", []uint32{42, 0}, 5, nil},
+		// FragCoord, FrontFacing; the five varyings in, four targets out.
+		{"ForwardGBuffer", []uint32{15, 17}, 9, []uint32{opKill, opDPdx, opDPdy}},
+		{"ForwardCoverage", []uint32{15, 17}, 6, []uint32{opKill}},
+		{"ForwardPeel", []uint32{15, 17}, 9, []uint32{opKill, opDPdx, opDPdy}},
+	} {
+		w := spvWords(t, tt.entry, ks[tt.entry].SPIRV)
+		var builtins []uint32
+		locations, flat := 0, 0
+		ops := map[uint32]bool{}
+		for i := 5; i < len(w); i += int(w[i] >> 16) {
+			op := w[i] & 0xffff
+			ops[op] = true
+			if op != opDecorate {
+				continue
+			}
+			switch w[i+2] {
+			case spvDecBuiltIn:
+				builtins = append(builtins, w[i+3])
+			case spvDecLocation:
+				locations++
+			case spvDecFlat:
+				flat++
+			}
+		}
+		if !slices.Equal(builtins, tt.builtins) {
+			t.Errorf("%s: builtins %v, want %v", tt.entry, builtins, tt.builtins)
+		}
+		if locations != tt.locations || flat != 1 {
+			t.Errorf("%s: %d locations and %d flat, want %d and 1", tt.entry, locations, flat, tt.locations)
+		}
+		for _, op := range tt.ops {
+			if !ops[op] {
+				t.Errorf("%s: no instruction %d", tt.entry, op)
+			}
+		}
+	}
+}
@@ -71,10 +71,17 @@ func K(gid uint, out []float32) {
 // pure-Go test is the offline regression guard for the corpus.
 func TestCompileAcceptsRealKernels(t *testing.T) {
 	corpus := map[string]string{
-		"deferred": kernelpkg.ShadeSrc,
-		"shadow":   kernelpkg.ShadowSrc,
-		"ao":       kernelpkg.AOSrc,
-		"vertfrag": vertFragKernelSrc,
+		"deferred":  kernelpkg.ShadeSrc,
+		"shadow":    kernelpkg.ShadowSrc,
+		"ao":        kernelpkg.AOSrc,
+		"aoblur":    kernelpkg.AOBlurSrc,
+		"oit":       kernelpkg.OITSrc,
+		"composite": kernelpkg.CompositeSrc,
+		"fxaa":      kernelpkg.FXAASrc,
+		"taa":       kernelpkg.TAASrc,
+		"gbuffer":   kernelpkg.GBufferSrc,
+		"sample":    kernelpkg.SampleSrc,
+		"vertfrag":  vertFragKernelSrc,
 	}
 	for name, src := range corpus {
 		t.Run(name, func(t *testing.T) {
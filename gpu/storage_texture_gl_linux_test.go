@@ -0,0 +1,129 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// Storage texture conformance for the GL backend: a compute kernel loads the
+// RGBA32F target of a render pass in place (gpumath.Image), which lets the
+// deferred passes consume the G-buffer without a readback. The rows of an
+// Image run bottom-up, so Load(x, y) must return row h-1-y of the top-down
+// ReadPixels. Runs on Mesa llvmpipe (surfaceless).
+package gpu_test
+
+import (
+	"os"
+	"testing"
+
+	"poly.red/gpu"
+	"poly.red/gpu/shader"
+)
+
+const storageTexFrag = `#version 310 es
+precision highp float;
+out vec4 fragColor;
+void main() { fragColor = vec4(gl_FragCoord.x, gl_FragCoord.y, 7.0, -1.0); }`
+
+const storageTexKernel = `package kernels
+func Copy(gid uint, img Image, out []float32) {
+	width := int(out[0])
+	y := int(gid) / width
+	x := int(gid) - y*width
+	c := img.Load(x, y)
+	out[gid*4+4] = c.X
+	out[gid*4+5] = c.Y
+	out[gid*4+6] = c.Z
+	out[gid*4+7] = c.W
+}`
+
+func TestGLStorageTexture(t *testing.T) {
+	if os.Getenv("EGL_PLATFORM") != "surfaceless" {
+		t.Skip("set EGL_PLATFORM=surfaceless to run the GL storage texture test")
+	}
+	dev, err := gpu.Open(gpu.WithDriver(gpu.DriverGL))
+	if err != nil {
+		t.Skipf("no GL device: %v", err)
+	}
+	defer dev.Close()
+	if !dev.StorageTextures() {
+		t.Fatal("the GL backend must load storage textures")
+	}
+
+	vmod, err := dev.NewShaderModule(gpu.ShaderSource{GLSL: floatTgtVert})
+	if err != nil {
+		t.Fatalf("vertex module: %v", err)
+	}
+	fmod, err := dev.NewShaderModule(gpu.ShaderSource{GLSL: storageTexFrag})
+	if err != nil {
+		t.Fatalf("fragment module: %v", err)
+	}
+	pipe, err := dev.NewRenderPipeline(gpu.RenderPipelineDescriptor{
+		VertexModule: vmod, VertexEntry: "main",
+		FragmentModule: fmod, FragmentEntry: "main",
+		ColorFormat: gpu.RGBA32Float,
+	})
+	if err != nil {
+		t.Fatalf("render pipeline: %v", err)
+	}
+
+	const W, H = 5, 3
+	tex, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA32Float, Width: W, Height: H, RenderTarget: true})
+	if err != nil {
+		t.Fatalf("texture: %v", err)
+	}
+	verts := []float32{-1, -1, 3, -1, -1, 3}
+	vbuf, err := dev.NewBuffer(gpu.BufferDescriptor{Data: glBytesOf(verts), Usage: gpu.BufferStorage})
+	if err != nil {
+		t.Fatalf("vertex buffer: %v", err)
+	}
+	enc := dev.NewCommandEncoder()
+	rp := enc.BeginRenderPass(gpu.RenderPassDescriptor{ColorTexture: tex, Load: gpu.LoadClear})
+	rp.SetPipeline(pipe)
+	rp.SetVertexBuffer(0, vbuf)
+	rp.Draw(gpu.TriangleList, 0, 3)
+	rp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	ks, err := shader.CompileGLSL(storageTexKernel)
+	if err != nil {
+		t.Fatalf("CompileGLSL: %v", err)
+	}
+	kmod, err := dev.NewShaderModule(gpu.ShaderSource{GLSL: ks["Copy"].GLSL})
+	if err != nil {
+		t.Fatalf("kernel module: %v", err)
+	}
+	layout := dev.NewBindGroupLayout(gpu.BindGroupLayoutEntry{Binding: 0, Visibility: gpu.StageCompute, Kind: gpu.StorageBuffer})
+	kpipe, err := dev.NewComputePipeline(gpu.ComputePipelineDescriptor{Layout: dev.NewPipelineLayout(layout), Module: kmod, Entry: "Copy"})
+	if err != nil {
+		t.Fatalf("compute pipeline: %v", err)
+	}
+	out := make([]float32, 4+W*H*4)
+	out[0] = W
+	obuf, err := dev.NewBuffer(gpu.BufferDescriptor{Data: glBytesOf(out), Usage: gpu.BufferStorage | gpu.BufferMapRead})
+	if err != nil {
+		t.Fatalf("output buffer: %v", err)
+	}
+	enc = dev.NewCommandEncoder()
+	cp := enc.BeginComputePass()
+	cp.SetPipeline(kpipe)
+	cp.SetBindGroup(0, dev.NewBindGroup(layout, gpu.BindGroupEntry{Binding: 0, Buffer: obuf}))
+	cp.SetTexture(0, tex)
+	cp.Dispatch(W*H, 1, 1)
+	cp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	got := glFloatsOf(obuf.Bytes(), len(out))[4:]
+	raw := tex.ReadPixels()
+	for y := 0; y < H; y++ {
+		for x := 0; x < W; x++ {
+			off := ((H-1-y)*W + x) * 16
+			want := [4]float32{f32(raw[off:]), f32(raw[off+4:]), f32(raw[off+8:]), f32(raw[off+12:])}
+			i := (y*W + x) * 4
+			if [4]float32(got[i:i+4]) != want || want[1] != float32(y)+0.5 {
+				t.Fatalf("Load(%d, %d) = %v, want %v (the y of the pixel center is %v)", x, y, got[i:i+4], want, float32(y)+0.5)
+			}
+		}
+	}
+}
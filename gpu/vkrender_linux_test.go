@@ -0,0 +1,273 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// Render conformance for the Vulkan backend, the counterparts of the GL depth,
+// MRT and multisample tests: the same draws through the public Device API,
+// with the stages authored in Go and compiled to SPIR-V by
+// shader.CompileSPIRV. The stages output GL's clip space, which the emitter
+// remaps to Vulkan's, so everything, the depths of the triangles and the row
+// order of the readback included, must match GL. Gated on POLYRED_VK_PROBE=1.
+package gpu_test
+
+import (
+	"os"
+	"testing"
+
+	"poly.red/gpu"
+	"poly.red/gpu/shader"
+)
+
+// vkRenderSrc are the stages of the render tests: a triangle of per-vertex
+// colors, and a full-screen triangle that writes red and green to two
+// targets.
+const vkRenderSrc = `package kernels
+
+type Vec3 struct{ X, Y, Z float32 }
+type Vec4 struct{ X, Y, Z, W float32 }
+
+type ColorVaryings struct {
+	Pos   Vec4 ` + "`gpu:\"position\"`" + `
+	Color Vec3
+}
+
+type MRTTargets struct {
+	Out0 Vec4
+	Out1 Vec4
+}
+
+//gpu:vertex
+func DepthVert(vid int, pos []float32, col []float32) ColorVaryings {
+	i := vid * 3
+	return ColorVaryings{Vec4{pos[i], pos[i+1], pos[i+2], 1.0}, Vec3{col[i], col[i+1], col[i+2]}}
+}
+
+//gpu:fragment
+func DepthFrag(in ColorVaryings) Vec4 {
+	return Vec4{in.Color.X, in.Color.Y, in.Color.Z, 1.0}
+}
+
+//gpu:vertex
+func MRTVert(vid int, verts []float32) Vec4 {
+	return Vec4{verts[vid*2], verts[vid*2+1], 0.0, 1.0}
+}
+
+//gpu:fragment
+func MRTFrag() MRTTargets {
+	return MRTTargets{Vec4{1.0, 0.0, 0.0, 1.0}, Vec4{0.0, 1.0, 0.0, 1.0}}
+}
+`
+
+// vkRenderDevice opens the Vulkan device of a render test, or skips it.
+func vkRenderDevice(t *testing.T) *gpu.Device {
+	t.Helper()
+	if os.Getenv("POLYRED_VK_PROBE") != "1" {
+		t.Skip("set POLYRED_VK_PROBE=1 to run the Vulkan render tests")
+	}
+	dev, err := gpu.Open(gpu.WithDriver(gpu.DriverVulkan))
+	if err != nil {
+		t.Skipf("no Vulkan device: %v", err)
+	}
+	return dev
+}
+
+// vkRenderPipeline builds a render pipeline of the vertex and fragment
+// stages vert and frag of vkRenderSrc.
+func vkRenderPipeline(t *testing.T, dev *gpu.Device, vert, frag string, desc gpu.RenderPipelineDescriptor) *gpu.RenderPipeline {
+	t.Helper()
+	ks, err := shader.CompileSPIRV(vkRenderSrc)
+	if err != nil {
+		t.Fatalf("CompileSPIRV: %v", err)
+	}
+	vmod, err := dev.NewShaderModule(gpu.ShaderSource{SPIRV: ks[vert].SPIRV})
+	if err != nil {
+		t.Fatalf("vertex module: %v", err)
+	}
+	fmod, err := dev.NewShaderModule(gpu.ShaderSource{SPIRV: ks[frag].SPIRV})
+	if err != nil {
+		t.Fatalf("fragment module: %v", err)
+	}
+	desc.VertexModule, desc.VertexEntry = vmod, "main"
+	desc.FragmentModule, desc.FragmentEntry = fmod, "main"
+	pipe, err := dev.NewRenderPipeline(desc)
+	if err != nil {
+		t.Fatalf("render pipeline: %v", err)
+	}
+	return pipe
+}
+
+func TestVulkanRenderDepthOcclusion(t *testing.T) {
+	dev := vkRenderDevice(t)
+	defer dev.Close()
+	pipe := vkRenderPipeline(t, dev, "DepthVert", "DepthFrag", gpu.RenderPipelineDescriptor{
+		ColorFormat: gpu.RGBA8Unorm,
+		DepthFormat: gpu.Depth32Float,
+	})
+
+	const W, H = 16, 16
+	// Full-screen triangles at z=-0.5 (near, red) and z=+0.5 (far, green),
+	// as in the GL test.
+	near := []float32{-1, -1, -0.5, 3, -1, -0.5, -1, 3, -0.5}
+	far := []float32{-1, -1, 0.5, 3, -1, 0.5, -1, 3, 0.5}
+	red := []float32{1, 0, 0, 1, 0, 0, 1, 0, 0}
+	green := []float32{0, 1, 0, 0, 1, 0, 0, 1, 0}
+
+	buf := func(d []float32) *gpu.Buffer {
+		b, err := dev.NewBuffer(gpu.BufferDescriptor{Data: glBytesOf(d), Usage: gpu.BufferStorage})
+		if err != nil {
+			t.Fatalf("buffer: %v", err)
+		}
+		return b
+	}
+	nearPos, farPos, redCol, greenCol := buf(near), buf(far), buf(red), buf(green)
+
+	render := func(firstFar bool) (r, g, b uint8) {
+		color, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: W, Height: H, RenderTarget: true})
+		if err != nil {
+			t.Fatalf("color texture: %v", err)
+		}
+		depth, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.Depth32Float, Width: W, Height: H, RenderTarget: true})
+		if err != nil {
+			t.Fatalf("depth texture: %v", err)
+		}
+		enc := dev.NewCommandEncoder()
+		rp := enc.BeginRenderPass(gpu.RenderPassDescriptor{
+			ColorTexture: color, Load: gpu.LoadClear, ClearColor: [4]float64{0, 0, 0, 1},
+			DepthTexture: depth, ClearDepth: 1,
+		})
+		rp.SetPipeline(pipe)
+		draw := func(pos, col *gpu.Buffer) {
+			rp.SetVertexBuffer(0, pos)
+			rp.SetVertexBuffer(1, col)
+			rp.Draw(gpu.TriangleList, 0, 3)
+		}
+		if firstFar {
+			draw(farPos, greenCol)
+			draw(nearPos, redCol)
+		} else {
+			draw(nearPos, redCol)
+			draw(farPos, greenCol)
+		}
+		rp.End()
+		dev.Queue().Submit(enc.Finish())
+		dev.Queue().WaitIdle()
+
+		pix := color.ReadPixels()
+		c := ((H/2)*W + W/2) * 4
+		return pix[c], pix[c+1], pix[c+2]
+	}
+
+	for _, firstFar := range []bool{false, true} {
+		r, g, b := render(firstFar)
+		if r < 200 || g > 60 {
+			t.Fatalf("firstFar=%v: center=(%d,%d,%d), want near (red) to win via depth test", firstFar, r, g, b)
+		}
+	}
+}
+
+func TestVulkanRenderMRT(t *testing.T) {
+	dev := vkRenderDevice(t)
+	defer dev.Close()
+	pipe := vkRenderPipeline(t, dev, "MRTVert", "MRTFrag", gpu.RenderPipelineDescriptor{
+		ColorFormat:       gpu.RGBA8Unorm,
+		ExtraColorFormats: []gpu.TextureFormat{gpu.RGBA8Unorm},
+	})
+
+	const W, H = 16, 16
+	mkTex := func() *gpu.Texture {
+		tex, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: W, Height: H, RenderTarget: true})
+		if err != nil {
+			t.Fatalf("texture: %v", err)
+		}
+		return tex
+	}
+	tex0, tex1 := mkTex(), mkTex()
+
+	verts := []float32{-1, -1, 3, -1, -1, 3} // full-screen triangle
+	vbuf, err := dev.NewBuffer(gpu.BufferDescriptor{Data: glBytesOf(verts), Usage: gpu.BufferStorage})
+	if err != nil {
+		t.Fatalf("vertex buffer: %v", err)
+	}
+
+	enc := dev.NewCommandEncoder()
+	rp := enc.BeginRenderPass(gpu.RenderPassDescriptor{
+		ColorTexture: tex0, Load: gpu.LoadClear, ClearColor: [4]float64{0, 0, 0, 1},
+		ExtraColorTargets: []gpu.ColorTarget{{Texture: tex1, ClearColor: [4]float64{0, 0, 0, 1}}},
+	})
+	rp.SetPipeline(pipe)
+	rp.SetVertexBuffer(0, vbuf)
+	rp.Draw(gpu.TriangleList, 0, 3)
+	rp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	c := ((H/2)*W + W/2) * 4
+	p0 := tex0.ReadPixels()
+	p1 := tex1.ReadPixels()
+	if p0[c] < 200 || p0[c+1] > 60 {
+		t.Fatalf("attachment 0 center = (%d,%d,%d), want red", p0[c], p0[c+1], p0[c+2])
+	}
+	if p1[c+1] < 200 || p1[c] > 60 {
+		t.Fatalf("attachment 1 center = (%d,%d,%d), want green", p1[c], p1[c+1], p1[c+2])
+	}
+}
+
+// TestVulkanRenderMultisampleResolve is the GL multisample test on Vulkan.
+// Its triangle covers the lower left of the top-down readback, as on GL, so
+// it also checks the row order of the readback.
+func TestVulkanRenderMultisampleResolve(t *testing.T) {
+	dev := vkRenderDevice(t)
+	defer dev.Close()
+	pipe := vkRenderPipeline(t, dev, "DepthVert", "DepthFrag", gpu.RenderPipelineDescriptor{
+		ColorFormat: gpu.RGBA8Unorm,
+		SampleCount: 4,
+	})
+
+	const W, H = 16, 16
+	ms, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: W, Height: H, RenderTarget: true, SampleCount: 4})
+	if err != nil {
+		t.Fatalf("multisampled texture: %v", err)
+	}
+	resolved, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA8Unorm, Width: W, Height: H, RenderTarget: true})
+	if err != nil {
+		t.Fatalf("resolve texture: %v", err)
+	}
+	pos, err := dev.NewBuffer(gpu.BufferDescriptor{Data: glBytesOf([]float32{-1, -1, 0, 1, -1, 0, -1, 1, 0}), Usage: gpu.BufferStorage})
+	if err != nil {
+		t.Fatalf("buffer: %v", err)
+	}
+	col, err := dev.NewBuffer(gpu.BufferDescriptor{Data: glBytesOf([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1}), Usage: gpu.BufferStorage})
+	if err != nil {
+		t.Fatalf("buffer: %v", err)
+	}
+
+	enc := dev.NewCommandEncoder()
+	rp := enc.BeginRenderPass(gpu.RenderPassDescriptor{
+		ColorTexture: ms, ResolveTexture: resolved,
+		Load: gpu.LoadClear, ClearColor: [4]float64{0, 0, 0, 1},
+	})
+	rp.SetPipeline(pipe)
+	rp.SetVertexBuffer(0, pos)
+	rp.SetVertexBuffer(1, col)
+	rp.Draw(gpu.TriangleList, 0, 3)
+	rp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	pix := resolved.ReadPixels()
+	at := func(x, y int) byte { return pix[(y*W+x)*4] }
+	if in, out := at(1, H-2), at(W-2, 1); in != 255 || out != 0 {
+		t.Fatalf("interior %d, exterior %d: want 255 and 0", in, out)
+	}
+	partial := 0
+	for x := 0; x < W; x++ {
+		if v := at(x, x); v > 0 && v < 255 {
+			partial++
+		}
+	}
+	if partial == 0 {
+		t.Fatal("no pixel on the edge resolved to a partial coverage")
+	}
+}
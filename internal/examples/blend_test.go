@@ -6,19 +6,21 @@ package example_test
 
 import (
 	"fmt"
-	"image/color"
+	"image"
+	stdmath "math"
 	"testing"
 
 	"poly.red/buffer"
+	"poly.red/color"
 	"poly.red/geometry/primitive"
 	"poly.red/internal/imageutil"
 	"poly.red/render"
 )
 
-func TestBlending(t *testing.T) {
+// blendImages draws src2.png over src1.png with the given blend function.
+func blendImages(f render.BlendFunc) *image.RGBA {
 	img1 := imageutil.MustLoadImage("../testdata/src1.png")
 	img2 := imageutil.MustLoadImage("../testdata/src2.png")
-	want := imageutil.MustLoadImage("../testdata/blend.png")
 
 	buf1 := buffer.NewBuffer(img1.Rect)
 	for i := 0; i < buf1.Bounds().Dx(); i++ {
@@ -40,15 +42,165 @@ func TestBlending(t *testing.T) {
 
 	r := render.NewRenderer(
 		render.Size(img1.Bounds().Dx(), img1.Bounds().Dy()),
-		render.Blending(render.AlphaBlend),
+		render.Blending(f),
 	)
 
 	r.DrawFragments(buf1, func(f *primitive.Fragment) color.RGBA {
 		return buf2.Get(f.X, f.Y).Col
 	})
+	return buf1.Image()
+}
+
+// checkBlend compares the given image with the reference image. The image
+// is saved to the output directory if it differs.
+//
+// The reference is a PNG image, which stores straight alpha colors, whereas
+// the renderer blends premultiplied ones: converting a premultiplied color
+// of the reference to straight alpha and back rounds twice, by up to one
+// level in each channel. The comparison allows that, and TestBlendModes
+// checks the blending itself exactly.
+func checkBlend(t *testing.T, name string, got, want *image.RGBA) {
+	t.Helper()
+
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			g, w := got.RGBAAt(x, y), want.RGBAAt(x, y)
+			if diff(g.R, w.R) > 1 || diff(g.G, w.G) > 1 || diff(g.B, w.B) > 1 || diff(g.A, w.A) > 1 {
+				imageutil.Save(got, fmt.Sprintf("./out/%s.png", name))
+				t.Fatalf("%s: pixel (%d, %d): got %v, want %v", name, x, y, g, w)
+			}
+		}
+	}
+}
 
-	imageutil.Save(buf1.Image(), "./out/dst.png")
-	diff, num := imageutil.Diff(want, buf1.Image(), imageutil.MseKernel)
-	imageutil.Save(diff, "./out/diff.png")
-	fmt.Println("total diff: ", num, img1.Bounds().Dx()*img1.Bounds().Dy())
+func diff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestBlending(t *testing.T) {
+	want := imageutil.MustLoadImage("../testdata/blend.png")
+	checkBlend(t, "dst", blendImages(render.AlphaBlend), want)
+}
+
+// porterDuff returns the Porter-Duff factors of the source and of the
+// destination of a blend mode, for a source of alpha sa over a destination
+// of alpha da. The separable modes have no such factors and return false.
+func porterDuff(mode color.BlendMode, sa, da float64) (fa, fb float64, ok bool) {
+	switch mode {
+	case color.BlendSrcOver:
+		return 1, 1 - sa, true
+	case color.BlendClear:
+		return 0, 0, true
+	case color.BlendSrc:
+		return 1, 0, true
+	case color.BlendDst:
+		return 0, 1, true
+	case color.BlendDstOver:
+		return 1 - da, 1, true
+	case color.BlendSrcIn:
+		return da, 0, true
+	case color.BlendDstIn:
+		return 0, sa, true
+	case color.BlendSrcOut:
+		return 1 - da, 0, true
+	case color.BlendDstOut:
+		return 0, 1 - sa, true
+	case color.BlendSrcAtop:
+		return da, 1 - sa, true
+	case color.BlendDstAtop:
+		return 1 - da, sa, true
+	case color.BlendXor:
+		return 1 - da, 1 - sa, true
+	case color.BlendAdd:
+		return 1, 1, true
+	}
+	return 0, 0, false
+}
+
+// separable is the blend function B(cs, cd) of the straight colors of a
+// separable blend mode of the W3C compositing specification.
+func separable(mode color.BlendMode, cs, cd float64) float64 {
+	switch mode {
+	case color.BlendMultiply:
+		return cs * cd
+	case color.BlendScreen:
+		return cs + cd - cs*cd
+	case color.BlendMin:
+		return stdmath.Min(cs, cd)
+	default: // color.BlendMax
+		return stdmath.Max(cs, cd)
+	}
+}
+
+// blendWant composites the premultiplied src over dst with the formulas of
+// the blend mode: co = cs*Fa + cd*Fb for the Porter-Duff operators, and
+// co = cs*(1-da) + cd*(1-sa) + sa*da*B(cs/sa, cd/da) for the separable
+// modes, rounded to 8 bits.
+func blendWant(mode color.BlendMode, dst, src color.RGBA) color.RGBA {
+	sa, da := float64(src.A)/0xff, float64(dst.A)/0xff
+	channel := func(s, d uint8, alpha bool) uint8 {
+		cs, cd := float64(s)/0xff, float64(d)/0xff
+		var co float64
+		if fa, fb, ok := porterDuff(mode, sa, da); ok {
+			co = cs*fa + cd*fb
+		} else {
+			co = cs*(1-da) + cd*(1-sa)
+			switch {
+			case alpha:
+				co += sa * da
+			case sa > 0 && da > 0:
+				co += sa * da * separable(mode, cs/sa, cd/da)
+			}
+		}
+		return uint8(stdmath.Round(stdmath.Min(co, 1) * 0xff))
+	}
+	return color.RGBA{
+		R: channel(src.R, dst.R, false),
+		G: channel(src.G, dst.G, false),
+		B: channel(src.B, dst.B, false),
+		A: channel(src.A, dst.A, true),
+	}
+}
+
+// TestBlendModes blends known premultiplied source colors over known
+// destination colors with every blend mode, and requires the colors of
+// the blend formulas exactly.
+func TestBlendModes(t *testing.T) {
+	dsts := []color.RGBA{
+		{R: 100, G: 100, B: 100, A: 255}, // opaque grey
+		{R: 0, G: 60, B: 120, A: 180},    // translucent blue
+		{},                               // transparent
+	}
+	srcs := []color.RGBA{
+		{R: 128, A: 128},               // half transparent red
+		{R: 20, G: 51, B: 26, A: 102},  // translucent green
+		{R: 10, G: 200, B: 90, A: 255}, // opaque
+		{},                             // transparent
+	}
+
+	for mode := color.BlendSrcOver; mode <= color.BlendAdd; mode++ {
+		t.Run(mode.String(), func(t *testing.T) {
+			buf := buffer.NewBuffer(image.Rect(0, 0, len(srcs), len(dsts)))
+			for y, dst := range dsts {
+				for x := range srcs {
+					buf.Set(x, y, buffer.Fragment{Ok: true, Fragment: primitive.Fragment{X: x, Y: y, Col: dst}})
+				}
+			}
+			r := render.NewRenderer(render.Size(len(srcs), len(dsts)), render.Blending(render.Blend(mode)))
+			r.DrawFragments(buf, func(f *primitive.Fragment) color.RGBA {
+				return srcs[f.X]
+			})
+
+			for y, dst := range dsts {
+				for x, src := range srcs {
+					if got, want := buf.Get(x, y).Col, blendWant(mode, dst, src); got != want {
+						t.Errorf("%v over %v: got %v, want %v", src, dst, got, want)
+					}
+				}
+			}
+		})
+	}
 }
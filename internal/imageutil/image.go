@@ -10,6 +10,7 @@ import (
 	"image/draw"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
 	"os"
 	"runtime"
 
@@ -46,6 +47,21 @@ type imageOption struct {
 }
 
 func LoadImage(path string, opts ...Option) (*image.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("loader: cannot open file %s, err: %w", path, err)
+	}
+	data, err := DecodeImage(f, opts...)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("loader: cannot load texture, path: %s, err: %w", path, err)
+	}
+	return data, nil
+}
+
+// DecodeImage decodes a PNG or JPEG image from the given reader, for
+// images embedded in a model file rather than stored next to it.
+func DecodeImage(r io.Reader, opts ...Option) (*image.RGBA, error) {
 	option := &imageOption{
 		gammaCorrection: false,
 	}
@@ -54,14 +70,9 @@ func LoadImage(path string, opts ...Option) (*image.RGBA, error) {
 		opt(option)
 	}
 
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("loader: cannot open file %s, err: %w", path, err)
-	}
-	img, _, err := image.Decode(f)
-	f.Close()
+	img, _, err := image.Decode(r)
 	if err != nil {
-		return nil, fmt.Errorf("loader: cannot load texture, path: %s, err: %w", path, err)
+		return nil, err
 	}
 
 	var data *image.RGBA
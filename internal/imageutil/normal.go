@@ -0,0 +1,52 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package imageutil
+
+import (
+	"image"
+	"math"
+)
+
+// IsGray reports whether the red, green and blue channels of every pixel
+// of the image are equal, as in height maps but not in normal maps.
+func IsGray(img *image.RGBA) bool {
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):img.PixOffset(img.Rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			if row[i] != row[i+1] || row[i] != row[i+2] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// HeightToNormal returns the tangent-space normal map of a height map,
+// whose red channel is the height. The slope of the heights, in full
+// intensities per texel, is multiplied by scale. The first row of the
+// image is the top of the texture, where v is the largest, and the map
+// wraps around at its borders.
+func HeightToNormal(img *image.RGBA, scale float32) *image.RGBA {
+	r := img.Rect
+	w, h := r.Dx(), r.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	height := func(x, y int) float64 {
+		x, y = (x+w)%w, (y+h)%h
+		return float64(img.Pix[img.PixOffset(r.Min.X+x, r.Min.Y+y)]) / 0xff
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			du := (height(x+1, y) - height(x-1, y)) / 2 * float64(scale)
+			dv := (height(x, y-1) - height(x, y+1)) / 2 * float64(scale)
+			l := math.Sqrt(du*du + dv*dv + 1)
+			i := out.PixOffset(x, y)
+			out.Pix[i+0] = uint8(math.Round((-du/l + 1) / 2 * 0xff))
+			out.Pix[i+1] = uint8(math.Round((-dv/l + 1) / 2 * 0xff))
+			out.Pix[i+2] = uint8(math.Round((1/l + 1) / 2 * 0xff))
+			out.Pix[i+3] = 0xff
+		}
+	}
+	return out
+}
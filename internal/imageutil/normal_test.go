@@ -0,0 +1,38 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package imageutil_test
+
+import (
+	"image"
+	"testing"
+
+	"poly.red/internal/imageutil"
+)
+
+func TestHeightToNormal(t *testing.T) {
+	// A ramp that rises to the right, and is flat vertically.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			i := img.PixOffset(x, y)
+			v := uint8(x * 0x40)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 0xff
+		}
+	}
+	if !imageutil.IsGray(img) {
+		t.Fatal("the height map is not gray")
+	}
+
+	n := imageutil.HeightToNormal(img, 4)
+	if imageutil.IsGray(n) {
+		t.Fatal("the normal map is gray")
+	}
+	// At x = 1 the slope is 1/4 per texel, scaled to 1: the normal tilts
+	// by 45 degrees against the u direction.
+	c := n.RGBAAt(1, 2)
+	if c.R != 37 || c.G != 128 || c.B != 217 || c.A != 0xff {
+		t.Fatalf("normal %v, want {37 128 217 255}", c)
+	}
+}
@@ -19,68 +19,133 @@ var (
 	_ object.Object[float32] = &Area{}
 )
 
+// AreaSamples is the number of samples per side of the regular grid
+// that an area light is evaluated with, i.e. a shaded point receives
+// light from AreaSamples*AreaSamples points on the emitter.
+const AreaSamples = 4
+
+// Area is a rectangular area light. It is located at its center and emits
+// from its front side, which faces the light direction. The intensity is
+// distributed over the rectangle, which results in soft lighting and,
+// when casting shadows, soft shadows.
 type Area struct {
 	math.TransformContext[float32]
 
-	position   math.Vec3[float32]
-	intensity  float32
-	color      color.RGBA
-	shape      *geometry.Geometry
-	maxBounces int
-	castShadow bool
+	position      math.Vec3[float32]
+	direction     math.Vec3[float32]
+	width, height float32
+	u, v          math.Vec3[float32] // half extents of the rectangle
+	intensity     float32
+	color         color.RGBA
+	shape         *geometry.Geometry
+	maxBounces    int
+	castShadow    bool
 }
 
+// NewArea returns a new area light. By default it is a 1x1 rectangle
+// located at the origin that faces down the -Y axis.
 func NewArea(opts ...Option) Source {
-	// FIXME: construct a geometry manually here to avoid circular import.
-	// Find a better way to do this.
-	width, height := float32(1.0), float32(1.0)
-	v1 := primitive.NewVertex(
-		primitive.Pos(math.NewVec4(-0.5*width, 0, -0.5*height, 1)),
-		primitive.UV(math.NewVec2[float32](0, 1)),
-		primitive.Nor(math.NewVec4[float32](0, 1, 0, 0)),
-		primitive.Col(color.RGBA{R: 255, G: 0, B: 0, A: 255}),
-	)
-	v2 := primitive.NewVertex(
-		primitive.Pos(math.NewVec4(-0.5*width, 0, 0.5*height, 1)),
-		primitive.UV(math.NewVec2[float32](0, 0)),
-		primitive.Nor(math.NewVec4[float32](0, 1, 0, 0)),
-		primitive.Col(color.RGBA{R: 0, G: 255, B: 0, A: 255}),
-	)
-	v3 := primitive.NewVertex(
-		primitive.Pos(math.NewVec4(0.5*width, 0, 0.5*height, 1)),
-		primitive.UV(math.NewVec2[float32](1, 0)),
-		primitive.Nor(math.NewVec4[float32](0, 1, 0, 0)),
-		primitive.Col(color.RGBA{R: 0, G: 0, B: 255, A: 255}),
-	)
-	v4 := primitive.NewVertex(
-		primitive.Pos(math.NewVec4(0.5*width, 0, -0.5*height, 1)),
-		primitive.UV(math.NewVec2[float32](1, 1)),
-		primitive.Nor(math.NewVec4[float32](0, 1, 0, 0)),
-		primitive.Col(color.RGBA{R: 0, G: 0, B: 0, A: 255}),
-	)
-	plane := mesh.NewTriangleMesh([]*primitive.Triangle{
-		{V1: v1, V2: v2, V3: v3},
-		{V1: v1, V2: v3, V3: v4},
-	})
-
 	a := &Area{
 		intensity:  0.1,
 		color:      color.White,
-		shape:      geometry.New(plane),
+		direction:  math.NewVec3[float32](0, -1, 0),
+		width:      1,
+		height:     1,
 		maxBounces: 1024,
 		castShadow: false,
 	}
 	for _, opt := range opts {
 		opt(a)
 	}
+
+	// Span the rectangle with two axes perpendicular to its direction,
+	// such that the direction equals v x u.
+	a.direction = a.direction.Unit()
+	ref := math.NewVec3[float32](0, 1, 0)
+	if math.Abs(a.direction.Y) > 0.99 {
+		ref = math.NewVec3[float32](0, 0, 1)
+	}
+	u := ref.Cross(a.direction).Unit()
+	v := u.Cross(a.direction)
+	a.u = u.Scale(0.5*a.width, 0.5*a.width, 0.5*a.width)
+	a.v = v.Scale(0.5*a.height, 0.5*a.height, 0.5*a.height)
+	a.shape = a.newShape()
 	a.ResetContext()
 	return a
 }
 
+// newShape constructs the emitter geometry manually here to avoid circular
+// import. It is colored by the light, and has no material such that the
+// renderer draws it without shading.
+func (a *Area) newShape() *geometry.Geometry {
+	corner := func(s, t float32, uv math.Vec2[float32]) *primitive.Vertex {
+		return primitive.NewVertex(
+			primitive.Pos(a.position.Add(a.u.Scale(s, s, s)).Add(a.v.Scale(t, t, t)).ToVec4(1)),
+			primitive.UV(uv),
+			primitive.Nor(a.direction.ToVec4(0)),
+			primitive.Col(a.color),
+		)
+	}
+	v1 := corner(-1, -1, math.NewVec2[float32](0, 1))
+	v2 := corner(-1, 1, math.NewVec2[float32](0, 0))
+	v3 := corner(1, 1, math.NewVec2[float32](1, 0))
+	v4 := corner(1, -1, math.NewVec2[float32](1, 1))
+	plane := mesh.NewTriangleMesh([]*primitive.Triangle{
+		{V1: v1, V2: v2, V3: v3, MaterialID: -1},
+		{V1: v1, V2: v3, V3: v4, MaterialID: -1},
+	})
+	return geometry.New(plane)
+}
+
 func (a *Area) Name() string                 { return "area_light" }
 func (a *Area) Type() object.Type            { return object.TypeLight }
 func (a *Area) Color() color.RGBA            { return a.color }
 func (a *Area) Intensity() float32           { return a.intensity }
-func (a *Area) AABB() primitive.AABB         { return primitive.NewAABB(math.NewVec3[float32](0, 0, 0)) }
 func (a *Area) CastShadow() bool             { return a.castShadow }
 func (a *Area) Position() math.Vec3[float32] { return a.position }
+func (a *Area) Dir() math.Vec3[float32]      { return a.direction }
+
+// AABB returns the bounding box of the rectangle.
+func (a *Area) AABB() primitive.AABB {
+	return primitive.NewAABB(
+		a.position.Sub(a.u).Sub(a.v),
+		a.position.Sub(a.u).Add(a.v),
+		a.position.Add(a.u).Add(a.v),
+		a.position.Add(a.u).Sub(a.v),
+	)
+}
+
+// Size returns the width and the height of the rectangle.
+func (a *Area) Size() (width, height float32) { return a.width, a.height }
+
+// Extents returns the half extents of the rectangle along its width and
+// its height, the rectangle is spanned by Position() +- u +- v.
+func (a *Area) Extents() (u, v math.Vec3[float32]) { return a.u, a.v }
+
+// Shape returns the emitter geometry of the light in world space.
+func (a *Area) Shape() *geometry.Geometry { return a.shape }
+
+// Samples returns the points of the AreaSamples x AreaSamples grid that
+// evaluates the light, each sample is at the center of its grid cell.
+//
+// The GPU deferred shading kernel (kernels.Shade) uses the same samples,
+// keep them in sync.
+func (a *Area) Samples() []math.Vec3[float32] {
+	ps := make([]math.Vec3[float32], 0, AreaSamples*AreaSamples)
+	for j := 0; j < AreaSamples; j++ {
+		t := (2*float32(j)+1)/AreaSamples - 1
+		for i := 0; i < AreaSamples; i++ {
+			s := (2*float32(i)+1)/AreaSamples - 1
+			ps = append(ps, a.position.Add(a.u.Scale(s, s, s)).Add(a.v.Scale(t, t, t)))
+		}
+	}
+	return ps
+}
+
+// Emission returns the fraction of the light that a sample p of the
+// light emits towards the world space position x: the cosine of the
+// emitting angle on the front side of the rectangle, zero on the back.
+func (a *Area) Emission(p math.Vec3[float32], x math.Vec4[float32]) float32 {
+	d := x.Sub(p.ToVec4(1)).Unit()
+	return math.Clamp(d.Dot(a.direction.ToVec4(0)), 0, 1)
+}
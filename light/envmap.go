@@ -0,0 +1,269 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package light
+
+import (
+	"runtime"
+	"sync"
+
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/geometry/primitive"
+	"poly.red/math"
+	"poly.red/scene/object"
+)
+
+var (
+	_ Light                  = &EnvMap{}
+	_ Environment            = &EnvMap{}
+	_ object.Object[float32] = &EnvMap{}
+)
+
+// The resolutions of the prefiltered maps of an environment map. The
+// prefiltered maps are at most as large as the environment image.
+const (
+	// EnvIrradianceWidth is the width of the irradiance map.
+	EnvIrradianceWidth = 32
+	// EnvSpecularWidth is the width of the first specular level, which
+	// is the environment image itself for a mirror-like surface.
+	EnvSpecularWidth = 128
+	// EnvSpecularLevels is the number of specular levels. The level i
+	// is prefiltered for the roughness i/(EnvSpecularLevels-1) and is
+	// half as large as the level i-1.
+	EnvSpecularLevels = 5
+)
+
+// EnvMap is an environment light of an equirectangular HDR image, which
+// lights the scene from all directions of the sphere around it.
+//
+// The image is prefiltered into an irradiance map, the cosine weighted
+// average of the radiance around a normal, for the diffuse light, and into
+// the levels of a specular map, the GGX weighted average of the radiance
+// around a reflected view direction for increasing roughness, for the
+// specular light.
+//
+// The top row of the image is the +Y direction, and its center column is
+// the -Z direction.
+type EnvMap struct {
+	math.TransformContext[float32] // not used
+
+	color     color.RGBA
+	intensity float32
+
+	image      *buffer.HDR
+	irradiance *buffer.HDR
+	specular   []*buffer.HDR
+}
+
+// NewEnvMap returns an environment light of the given equirectangular
+// image. The image is prefiltered once.
+func NewEnvMap(img *buffer.HDR, opts ...Option) Environment {
+	e := &EnvMap{
+		intensity: 1,
+		color:     color.White,
+		image:     img,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.ResetContext()
+	e.prefilter()
+	return e
+}
+
+// LoadEnvMap returns an environment light of the equirectangular Radiance
+// RGBE (.hdr) image at the given path.
+func LoadEnvMap(path string, opts ...Option) (Environment, error) {
+	img, err := buffer.LoadHDR(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnvMap(img, opts...), nil
+}
+
+func (e *EnvMap) Name() string         { return "envmap_light" }
+func (e *EnvMap) Type() object.Type    { return object.TypeLight }
+func (e *EnvMap) Color() color.RGBA    { return e.color }
+func (e *EnvMap) Intensity() float32   { return e.intensity }
+func (e *EnvMap) AABB() primitive.AABB { return primitive.NewAABB(math.NewVec3[float32](0, 0, 0)) }
+
+// Image returns the equirectangular image of the environment.
+func (e *EnvMap) Image() *buffer.HDR { return e.image }
+
+// Maps returns the prefiltered irradiance map and specular levels, without
+// the intensity of the light.
+func (e *EnvMap) Maps() (irradiance *buffer.HDR, specular []*buffer.HDR) {
+	return e.irradiance, e.specular
+}
+
+// Irradiance returns the diffuse light for a surface of the given unit
+// normal, which is the radiance of a uniform environment.
+func (e *EnvMap) Irradiance(n math.Vec4[float32]) math.Vec4[float32] {
+	u, v := EquirectUV(n)
+	return e.scale(e.irradiance.Sample(u, v))
+}
+
+// Radiance returns the specular light that a surface of the given
+// roughness reflects in the given unit direction. It interpolates the two
+// specular levels of the nearest roughnesses.
+func (e *EnvMap) Radiance(r math.Vec4[float32], roughness float32) math.Vec4[float32] {
+	u, v := EquirectUV(r)
+	lf := math.Clamp(roughness, 0, 1) * float32(len(e.specular)-1)
+	l0 := int(math.Floor(lf))
+	l1 := min(l0+1, len(e.specular)-1)
+	t := lf - float32(l0)
+	c0, c1 := e.specular[l0].Sample(u, v), e.specular[l1].Sample(u, v)
+	return e.scale(math.NewVec4(
+		c0.X*(1-t)+c1.X*t,
+		c0.Y*(1-t)+c1.Y*t,
+		c0.Z*(1-t)+c1.Z*t,
+		0,
+	))
+}
+
+// Background returns the radiance of the environment image in the given
+// unit direction.
+func (e *EnvMap) Background(d math.Vec4[float32]) math.Vec4[float32] {
+	u, v := EquirectUV(d)
+	return e.scale(e.image.Sample(u, v))
+}
+
+func (e *EnvMap) scale(c math.Vec4[float32]) math.Vec4[float32] {
+	return math.NewVec4(c.X*e.intensity, c.Y*e.intensity, c.Z*e.intensity, 0)
+}
+
+// EquirectUV returns the coordinates of the given unit direction in an
+// equirectangular image, where u in [0, 1] goes around the Y axis and
+// starts at +Z, and v in [0, 1] goes from +Y to -Y.
+func EquirectUV(d math.Vec4[float32]) (u, v float32) {
+	// Adding 0 turns -0 into 0, such that the poles map to the center
+	// column, as they do in the GPU kernel.
+	u = 0.5 + math.Atan2(d.X, -d.Z+0)/(2*math.Pi)
+	v = math.Acos(math.Clamp(d.Y, -1, 1)) / math.Pi
+	return u, v
+}
+
+// equirectDir returns the unit direction of the center of the pixel (x, y)
+// of an equirectangular image of the given size, and the solid angle that
+// the pixel covers.
+func equirectDir(x, y, w, h int) (math.Vec4[float32], float32) {
+	theta := (float32(y) + 0.5) / float32(h) * math.Pi
+	phi := ((float32(x)+0.5)/float32(w) - 0.5) * 2 * math.Pi
+	st := math.Sin(theta)
+	d := math.NewVec4(st*math.Sin(phi), math.Cos(theta), -st*math.Cos(phi), 0)
+	return d, 2 * math.Pi / float32(w) * math.Pi / float32(h) * st
+}
+
+// prefilter computes the irradiance map and the specular levels.
+func (e *EnvMap) prefilter() {
+	e.irradiance = convolve(downsample(e.image, 2*EnvIrradianceWidth), EnvIrradianceWidth,
+		func(n, l math.Vec4[float32]) float32 {
+			return math.Max(n.Dot(l), 0)
+		})
+
+	e.specular = make([]*buffer.HDR, EnvSpecularLevels)
+	e.specular[0] = downsample(e.image, EnvSpecularWidth)
+	w := e.specular[0].Width
+	for i := 1; i < EnvSpecularLevels; i++ {
+		r := float32(i) / float32(EnvSpecularLevels-1)
+		a2 := r * r * r * r
+
+		// Every level is filtered from the image at twice its resolution,
+		// which resolves the lobe of its roughness.
+		src := downsample(e.image, max(w>>(i-1), 2))
+		e.specular[i] = convolve(src, max(w>>i, 2),
+			func(n, l math.Vec4[float32]) float32 {
+				// The GGX distribution of the half vector, with the
+				// normal and the view direction along the reflected
+				// direction n, weighted by the incident cosine.
+				nl := n.Dot(l)
+				if nl <= 0 {
+					return 0
+				}
+				h := n.Add(l).Unit()
+				nh := n.Dot(h)
+				d := nh*nh*(a2-1) + 1
+				return a2 / (math.Pi * d * d) * nl
+			})
+	}
+}
+
+// downsample box filters the given image to the given width, keeping its
+// aspect ratio. It returns the image if it is not wider.
+func downsample(img *buffer.HDR, width int) *buffer.HDR {
+	if img.Width <= width {
+		return img
+	}
+	height := max(img.Height*width/img.Width, 1)
+	out := buffer.NewHDR(width, height)
+	for y := 0; y < height; y++ {
+		y0, y1 := y*img.Height/height, (y+1)*img.Height/height
+		for x := 0; x < width; x++ {
+			x0, x1 := x*img.Width/width, (x+1)*img.Width/width
+			var sum math.Vec4[float32]
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := img.At(sx, sy)
+					sum = math.NewVec4(sum.X+c.X, sum.Y+c.Y, sum.Z+c.Z, 0)
+				}
+			}
+			k := 1 / float32((x1-x0)*(y1-y0))
+			out.Set(x, y, sum.Scale(k, k, k, 0))
+		}
+	}
+	return out
+}
+
+// convolve returns the image of the given width, whose pixel of direction
+// n is the average of the pixels of direction l of the given image,
+// weighted by weight(n, l) and their solid angles.
+func convolve(img *buffer.HDR, width int, weight func(n, l math.Vec4[float32]) float32) *buffer.HDR {
+	width = max(width, 2)
+	height := max(width/2, 1)
+	out := buffer.NewHDR(width, height)
+
+	// The directions and solid angles of the source pixels.
+	dirs := make([]math.Vec4[float32], img.Width*img.Height)
+	areas := make([]float32, img.Width*img.Height)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			dirs[y*img.Width+x], areas[y*img.Width+x] = equirectDir(x, y, img.Width, img.Height)
+		}
+	}
+
+	var wg sync.WaitGroup
+	rows := make(chan int, height)
+	for y := 0; y < height; y++ {
+		rows <- y
+	}
+	close(rows)
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := range rows {
+				for x := 0; x < width; x++ {
+					n, _ := equirectDir(x, y, width, height)
+					var r, g, b, wsum float32
+					for i, l := range dirs {
+						w := weight(n, l) * areas[i]
+						if w <= 0 {
+							continue
+						}
+						r += img.Pix[i*3] * w
+						g += img.Pix[i*3+1] * w
+						b += img.Pix[i*3+2] * w
+						wsum += w
+					}
+					if wsum > 0 {
+						out.Set(x, y, math.NewVec4(r/wsum, g/wsum, b/wsum, 0))
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return out
+}
@@ -17,10 +17,16 @@ func Intensity(I float32) Option {
 		switch a := l.(type) {
 		case *Ambient:
 			a.intensity = I
+		case *EnvMap:
+			a.intensity = I
 		case *Directional:
 			a.intensity = I
 		case *Point:
 			a.intensity = I
+		case *Spot:
+			a.intensity = I
+		case *Area:
+			a.intensity = I
 		default:
 			panic("light: invalid usage of Intensity option")
 		}
@@ -32,10 +38,16 @@ func Color(c color.RGBA) Option {
 		switch a := l.(type) {
 		case *Ambient:
 			a.color = c
+		case *EnvMap:
+			a.color = c
 		case *Directional:
 			a.color = c
 		case *Point:
 			a.color = c
+		case *Spot:
+			a.color = c
+		case *Area:
+			a.color = c
 		default:
 			panic("light: invalid usage of Color option")
 		}
@@ -47,6 +59,10 @@ func Direction(dir math.Vec3[float32]) Option {
 		switch a := any(l).(type) {
 		case *Directional:
 			a.direction = dir
+		case *Spot:
+			a.direction = dir
+		case *Area:
+			a.direction = dir
 		default:
 			panic("light: invalid usage of Direction option")
 		}
@@ -60,6 +76,10 @@ func Position(pos math.Vec3[float32]) Option {
 			a.position = pos
 		case *Point:
 			a.position = pos
+		case *Spot:
+			a.position = pos
+		case *Area:
+			a.position = pos
 		default:
 			panic("light: invalid usage of Position option")
 		}
@@ -73,8 +93,53 @@ func CastShadow(enable bool) Option {
 			a.useShadowMap = enable
 		case *Point:
 			a.useShadowMap = enable
+		case *Spot:
+			a.useShadowMap = enable
+		case *Area:
+			a.castShadow = enable
 		default:
 			panic("light: invalid usage of CastShadow option")
 		}
 	}
 }
+
+// Cone sets the inner and outer half angles, in degrees, of a spot light
+// cone. The light has full intensity inside the inner cone and smoothly
+// falls off to zero at the outer cone.
+func Cone(inner, outer float32) Option {
+	return func(l Light) {
+		switch a := l.(type) {
+		case *Spot:
+			a.inner = inner
+			a.outer = outer
+		default:
+			panic("light: invalid usage of Cone option")
+		}
+	}
+}
+
+// Range sets the distance after which a spot light has no contribution.
+// A zero range means the light has an unlimited range.
+func Range(r float32) Option {
+	return func(l Light) {
+		switch a := l.(type) {
+		case *Spot:
+			a.distance = r
+		default:
+			panic("light: invalid usage of Range option")
+		}
+	}
+}
+
+// Size sets the width and the height of the rectangle of an area light.
+func Size(width, height float32) Option {
+	return func(l Light) {
+		switch a := l.(type) {
+		case *Area:
+			a.width = width
+			a.height = height
+		default:
+			panic("light: invalid usage of Size option")
+		}
+	}
+}
@@ -3,3 +3,107 @@
 // can be found in the LICENSE file.
 
 package light
+
+import (
+	"image/color"
+
+	"poly.red/geometry/primitive"
+	"poly.red/math"
+	"poly.red/scene/object"
+)
+
+var (
+	_ Light                  = &Spot{}
+	_ Source                 = &Spot{}
+	_ object.Object[float32] = &Spot{}
+)
+
+// Spot is a spot light. It emits from a position towards a direction
+// inside a cone: full intensity within the inner cone angle, a smooth
+// falloff to zero at the outer cone angle, and an optional range beyond
+// which it contributes nothing.
+type Spot struct {
+	math.TransformContext[float32]
+
+	position     math.Vec3[float32]
+	direction    math.Vec3[float32]
+	intensity    float32
+	color        color.RGBA
+	inner, outer float32 // half angles, in degrees
+	cosInner     float32
+	cosOuter     float32
+	distance     float32 // range, 0 means unlimited
+	useShadowMap bool
+}
+
+// NewSpot returns a new spot light. By default it is located at (1, 1, 1),
+// points down the -Y axis with a 30 degree inner and 45 degree outer cone
+// and has an unlimited range.
+func NewSpot(opts ...Option) Source {
+	l := &Spot{
+		intensity:    1,
+		color:        color.RGBA{255, 255, 255, 255},
+		position:     math.NewVec3[float32](1, 1, 1),
+		direction:    math.NewVec3[float32](0, -1, 0),
+		inner:        30,
+		outer:        45,
+		useShadowMap: false,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.direction = l.direction.Unit()
+	l.outer = math.Clamp(l.outer, 0, 89)
+	l.inner = math.Clamp(l.inner, 0, l.outer)
+	l.cosInner = math.Cos(math.DegToRad(l.inner))
+	l.cosOuter = math.Cos(math.DegToRad(l.outer))
+	l.ResetContext()
+	return l
+}
+
+func (l *Spot) Name() string                 { return "spot_light" }
+func (l *Spot) Type() object.Type            { return object.TypeLight }
+func (l *Spot) Intensity() float32           { return l.intensity }
+func (l *Spot) Position() math.Vec3[float32] { return l.position }
+func (l *Spot) Dir() math.Vec3[float32]      { return l.direction }
+func (l *Spot) Color() color.RGBA            { return l.color }
+func (l *Spot) CastShadow() bool             { return l.useShadowMap }
+func (l *Spot) AABB() primitive.AABB         { return primitive.NewAABB(l.position) }
+
+// Cone returns the inner and outer half angles of the cone in degrees.
+func (l *Spot) Cone() (inner, outer float32) { return l.inner, l.outer }
+
+// CosCone returns the cosines of the inner and outer half angles, which
+// is the form the shaders consume.
+func (l *Spot) CosCone() (inner, outer float32) { return l.cosInner, l.cosOuter }
+
+// Range returns the distance after which the light has no contribution.
+// Zero means the light has an unlimited range.
+func (l *Spot) Range() float32 { return l.distance }
+
+// Attenuation returns the cone and range falloff of the light at the
+// given world space position, in [0, 1]. It does not include the inverse
+// distance falloff that spot lights share with point lights.
+//
+// The GPU deferred shading kernel (kernels.Shade) evaluates the same
+// formula, keep them in sync.
+func (l *Spot) Attenuation(x math.Vec4[float32]) float32 {
+	Ldir := l.position.ToVec4(1).Sub(x)
+	dist := Ldir.Len()
+	if dist == 0 {
+		return 1
+	}
+
+	// Smooth step between the outer and the inner cone.
+	cosTheta := -Ldir.Unit().Dot(l.direction.ToVec4(0))
+	t := math.Clamp((cosTheta-l.cosOuter)/math.Max(l.cosInner-l.cosOuter, 1e-4), 0, 1)
+	a := t * t * (3 - 2*t)
+
+	// Windowed range falloff that reaches exactly zero at the range.
+	if l.distance > 0 {
+		r := dist / l.distance
+		w := math.Clamp(1-r*r*r*r, 0, 1)
+		a *= w * w
+	}
+	return a
+}
@@ -61,3 +61,25 @@ func BenchmarkBlinnPhongShader(b *testing.B) {
 	}
 	_ = cc
 }
+
+// TestMaps checks that the specular map scales the specular color and the
+// alpha map scales the opacity.
+func TestMaps(t *testing.T) {
+	m := material.NewBlinnPhong(
+		material.Specular(color.RGBA{R: 0xff, G: 0x80, B: 0, A: 0xff}),
+		material.SpecularMap(buffer.NewUniformTexture(color.RGBA{R: 0x80, G: 0xff, B: 0xff, A: 0xff})),
+		material.Opacity(0.5),
+		material.AlphaMap(buffer.NewUniformTexture(color.RGBA{R: 0x33, A: 0xff})),
+	)
+	if got, want := m.SpecularAt(0.5, 0.5, 0, 0), (color.RGBA{R: 0x80, G: 0x80, B: 0, A: 0xff}); got != want {
+		t.Fatalf("specular %v, want %v", got, want)
+	}
+	if got := m.Alpha(0.5, 0.5, 0, 0); math.Abs(got-0.1) > 1e-6 {
+		t.Fatalf("alpha %v, want 0.1", got)
+	}
+
+	m = material.NewBlinnPhong(material.Specular(color.White), material.Opacity(0.5))
+	if m.SpecularAt(0, 0, 0, 0) != color.White || m.Alpha(0, 0, 0, 0) != 0.5 {
+		t.Fatalf("specular %v, alpha %v without maps", m.SpecularAt(0, 0, 0, 0), m.Alpha(0, 0, 0, 0))
+	}
+}
@@ -24,6 +24,23 @@ type Standard struct {
 	AmbientOcclusion bool
 	ReceiveShadow    bool
 	Texture          *buffer.Texture
+	// NormalMap is a tangent-space normal map. It perturbs the shading
+	// normal of geometries that carry vertex tangents.
+	NormalMap *buffer.Texture
+	// AlphaMap is a texture whose red channel scales the opacity.
+	// Geometries of materials with an alpha map are blended by the
+	// transparent pass.
+	AlphaMap *buffer.Texture
+	// Opacity is the opacity of the material in [0, 1], which scales the
+	// alpha of its color. Geometries of materials with an opacity below 1
+	// are drawn into the transparent layers of the forward pass instead of
+	// its G-buffer, and blended by the transparent pass.
+	Opacity float32
+	// Blend is the mode that composites the material over the scene
+	// behind it. Geometries of materials with a mode other than the
+	// default color.BlendSrcOver are blended by the transparent pass, on
+	// the CPU and the GPU alike.
+	Blend color.BlendMode
 
 	name string
 }
@@ -41,6 +58,34 @@ func (m *Standard) Config(opts ...Option) {
 	}
 }
 
+// StandardOf returns the properties that the given material shares with
+// all materials, or nil if the material is nil or of an unknown type.
+func StandardOf(m Material) *Standard {
+	switch x := m.(type) {
+	case *Standard:
+		return x
+	case *BlinnPhong:
+		if x != nil {
+			return &x.Standard
+		}
+	case *PBR:
+		if x != nil {
+			return &x.Standard
+		}
+	}
+	return nil
+}
+
+// Alpha returns the opacity of the material at the texture coordinates
+// (u, v), whose screen space derivatives du and dv select the mipmap level
+// of the alpha map.
+func (m *Standard) Alpha(u, v, du, dv float32) float32 {
+	if m.AlphaMap == nil {
+		return m.Opacity
+	}
+	return m.Opacity * float32(query(m.AlphaMap, u, v, du, dv).R) / 0xff
+}
+
 type BlinnPhong struct {
 	Standard
 	Ambient   color.RGBA
@@ -48,7 +93,8 @@ type BlinnPhong struct {
 	Specular  color.RGBA
 	Emissive  color.RGBA
 	Shininess float32
-	Opacity   float32
+	// SpecularMap is a texture that scales the specular color.
+	SpecularMap *buffer.Texture
 }
 
 // NewBlinnPhong creates and returns a new Blinn-Phong material. Materials are
@@ -61,6 +107,7 @@ func NewBlinnPhong(opts ...Option) *BlinnPhong {
 			ReceiveShadow:    false,
 			AmbientOcclusion: false,
 			Texture:          nil,
+			Opacity:          1,
 		},
 		Diffuse:   color.FromValue(0.5, 0.5, 0.5, 1.0),
 		Specular:  color.FromValue(0.5, 0.5, 0.5, 1.0),
@@ -84,3 +131,19 @@ func (m *BlinnPhong) Config(opts ...Option) {
 		opt(m)
 	}
 }
+
+// SpecularAt returns the specular color of the material at the texture
+// coordinates (u, v), whose screen space derivatives du and dv select the
+// mipmap level of the specular map.
+func (m *BlinnPhong) SpecularAt(u, v, du, dv float32) color.RGBA {
+	if m.SpecularMap == nil {
+		return m.Specular
+	}
+	c := query(m.SpecularMap, u, v, du, dv)
+	return color.RGBA{
+		R: uint8((uint32(m.Specular.R)*uint32(c.R) + 0x7f) / 0xff),
+		G: uint8((uint32(m.Specular.G)*uint32(c.G) + 0x7f) / 0xff),
+		B: uint8((uint32(m.Specular.B)*uint32(c.B) + 0x7f) / 0xff),
+		A: m.Specular.A,
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package material
+
+import "poly.red/math"
+
+// PerturbNormal returns the shading normal of a surface with the unit normal
+// n and the tangent t, whose W component is the handedness of the bitangent,
+// perturbed by the normal map at the texture coordinates (u, v). The screen
+// space derivatives du and dv select the mipmap level of the normal map. It
+// returns n if the material has no normal map or t is zero.
+func (m *Standard) PerturbNormal(n, t math.Vec4[float32], u, v, du, dv float32) math.Vec4[float32] {
+	if m.NormalMap == nil || t.IsZero() {
+		return n
+	}
+
+	h := float32(1)
+	if t.W < 0 {
+		h = -1
+	}
+
+	// Orthogonalize the interpolated tangent against the normal.
+	t = math.NewVec4(t.X, t.Y, t.Z, 0)
+	t = t.Sub(n.Scale(n.Dot(t), n.Dot(t), n.Dot(t), 0))
+	if t.IsZero() {
+		return n
+	}
+	t = t.Unit()
+	b := n.Cross(t).Scale(h, h, h, 0)
+
+	c := toVec4(query(m.NormalMap, u, v, du, dv))
+	x, y, z := c.X*2-1, c.Y*2-1, c.Z*2-1
+	p := math.NewVec4(
+		t.X*x+b.X*y+n.X*z,
+		t.Y*x+b.Y*y+n.Y*z,
+		t.Z*x+b.Z*y+n.Z*z,
+		0,
+	)
+	if p.IsZero() {
+		return n
+	}
+	return p.Unit()
+}
@@ -5,9 +5,9 @@
 package material
 
 import (
-	"image/color"
-
 	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/math"
 )
 
 type Option func(m Material)
@@ -19,6 +19,8 @@ func Name(name string) Option {
 			x.name = name
 		case *BlinnPhong:
 			x.Standard.name = name
+		case *PBR:
+			x.Standard.name = name
 		default:
 			panic("unsupported type")
 		}
@@ -32,6 +34,8 @@ func Texture(tex *buffer.Texture) Option {
 			x.Texture = tex
 		case *BlinnPhong:
 			x.Standard.Texture = tex
+		case *PBR:
+			x.Standard.Texture = tex
 		default:
 			panic("unsupported type")
 		}
@@ -78,6 +82,8 @@ func FlatShading(enable bool) Option {
 			x.FlatShading = enable
 		case *BlinnPhong:
 			x.Standard.FlatShading = enable
+		case *PBR:
+			x.Standard.FlatShading = enable
 		default:
 			panic("unsupported type")
 		}
@@ -91,6 +97,8 @@ func AmbientOcclusion(enable bool) Option {
 			x.AmbientOcclusion = enable
 		case *BlinnPhong:
 			x.Standard.AmbientOcclusion = enable
+		case *PBR:
+			x.Standard.AmbientOcclusion = enable
 		default:
 			panic("unsupported type")
 		}
@@ -104,6 +112,180 @@ func ReceiveShadow(enable bool) Option {
 			x.ReceiveShadow = enable
 		case *BlinnPhong:
 			x.Standard.ReceiveShadow = enable
+		case *PBR:
+			x.Standard.ReceiveShadow = enable
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+func Emissive(col color.RGBA) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *BlinnPhong:
+			x.Emissive = col
+		case *PBR:
+			x.Emissive = col
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// Opacity is an option that customizes the opacity of a material. The
+// opacity is clamped to [0, 1].
+func Opacity(opacity float32) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *Standard:
+			x.Opacity = math.Clamp(opacity, 0, 1)
+		case *BlinnPhong:
+			x.Standard.Opacity = math.Clamp(opacity, 0, 1)
+		case *PBR:
+			x.Standard.Opacity = math.Clamp(opacity, 0, 1)
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// Blend is an option that customizes the blend mode of a material.
+func Blend(mode color.BlendMode) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *Standard:
+			x.Blend = mode
+		case *BlinnPhong:
+			x.Standard.Blend = mode
+		case *PBR:
+			x.Standard.Blend = mode
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// BaseColor is an option that customizes the base color of a PBR material.
+func BaseColor(col color.RGBA) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *PBR:
+			x.BaseColor = col
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// Metallic is an option that customizes the metalness of a PBR material,
+// where 0 is a dielectric and 1 is a metal.
+func Metallic(metallic float32) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *PBR:
+			x.Metallic = math.Clamp(metallic, 0, 1)
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// Roughness is an option that customizes the perceptual roughness of a PBR
+// material, where 0 is a perfect mirror and 1 is completely rough.
+func Roughness(roughness float32) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *PBR:
+			x.Roughness = math.Clamp(roughness, 0, 1)
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// MetallicRoughnessMap is an option that customizes the texture whose blue
+// channel scales the metalness and whose green channel scales the
+// roughness of a PBR material.
+func MetallicRoughnessMap(tex *buffer.Texture) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *PBR:
+			x.MetallicRoughnessMap = tex
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// OcclusionMap is an option that customizes the texture whose red channel
+// is the ambient occlusion of a PBR material.
+func OcclusionMap(tex *buffer.Texture) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *PBR:
+			x.OcclusionMap = tex
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// EmissiveMap is an option that customizes the texture that scales the
+// emissive color of a PBR material.
+func EmissiveMap(tex *buffer.Texture) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *PBR:
+			x.EmissiveMap = tex
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// NormalMap is an option that customizes the tangent-space normal map of a
+// material. The red, green and blue channels hold the x, y and z components
+// of the normal relative to the tangent, the bitangent and the vertex normal.
+func NormalMap(tex *buffer.Texture) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *Standard:
+			x.NormalMap = tex
+		case *BlinnPhong:
+			x.Standard.NormalMap = tex
+		case *PBR:
+			x.Standard.NormalMap = tex
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// SpecularMap is an option that customizes the texture that scales the
+// specular color of a Blinn-Phong material.
+func SpecularMap(tex *buffer.Texture) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *BlinnPhong:
+			x.SpecularMap = tex
+		default:
+			panic("unsupported type")
+		}
+	}
+}
+
+// AlphaMap is an option that customizes the texture whose red channel
+// scales the opacity of a material.
+func AlphaMap(tex *buffer.Texture) Option {
+	return func(m Material) {
+		switch x := m.(type) {
+		case *Standard:
+			x.AlphaMap = tex
+		case *BlinnPhong:
+			x.Standard.AlphaMap = tex
+		case *PBR:
+			x.Standard.AlphaMap = tex
 		default:
 			panic("unsupported type")
 		}
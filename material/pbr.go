@@ -0,0 +1,119 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package material
+
+import (
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/math"
+)
+
+var _ Material = &PBR{}
+
+// PBR is a physically based material in the metallic-roughness workflow of
+// glTF, shaded with a Cook-Torrance BRDF. Every factor is multiplied by its
+// texture map when the map is set: the base color by Texture, the metallic
+// and the roughness by the blue and the green channel of
+// MetallicRoughnessMap, the occlusion by the red channel of OcclusionMap,
+// and the emissive color by EmissiveMap.
+type PBR struct {
+	Standard
+	BaseColor color.RGBA
+	Metallic  float32
+	Roughness float32
+	Emissive  color.RGBA
+
+	MetallicRoughnessMap *buffer.Texture
+	OcclusionMap         *buffer.Texture
+	EmissiveMap          *buffer.Texture
+}
+
+// NewPBR creates and returns a new metallic-roughness material. By default
+// it is a white, rough dielectric without any texture map.
+func NewPBR(opts ...Option) *PBR {
+	m := &PBR{
+		Standard:  Standard{Opacity: 1},
+		BaseColor: color.White,
+		Metallic:  0,
+		Roughness: 1,
+		Emissive:  color.Black,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *PBR) Name() string {
+	if m.name == "" {
+		return "pbr"
+	}
+	return m.name
+}
+
+func (m *PBR) Config(opts ...Option) {
+	for _, opt := range opts {
+		opt(m)
+	}
+}
+
+// Surface holds the parameters of a PBR material at a point of a surface,
+// that is the factors of the material multiplied by its texture maps. The
+// colors are in [0, 1].
+type Surface struct {
+	BaseColor math.Vec4[float32]
+	Metallic  float32
+	Roughness float32
+	Occlusion float32
+	Emissive  math.Vec3[float32]
+}
+
+// Surface samples the texture maps at the texture coordinates (u, v), whose
+// screen space derivatives du and dv select the mipmap level, and returns
+// the parameters of the material at that point.
+func (m *PBR) Surface(u, v, du, dv float32) Surface {
+	s := Surface{
+		BaseColor: toVec4(m.BaseColor),
+		Metallic:  m.Metallic,
+		Roughness: m.Roughness,
+		Occlusion: 1,
+		Emissive:  toVec4(m.Emissive).ToVec3(),
+	}
+	if m.Texture != nil {
+		c := toVec4(query(m.Texture, u, v, du, dv))
+		s.BaseColor = math.NewVec4(s.BaseColor.X*c.X, s.BaseColor.Y*c.Y, s.BaseColor.Z*c.Z, s.BaseColor.W*c.W)
+	}
+	if m.MetallicRoughnessMap != nil {
+		c := toVec4(query(m.MetallicRoughnessMap, u, v, du, dv))
+		s.Metallic *= c.Z
+		s.Roughness *= c.Y
+	}
+	if m.OcclusionMap != nil {
+		s.Occlusion = toVec4(query(m.OcclusionMap, u, v, du, dv)).X
+	}
+	if m.EmissiveMap != nil {
+		c := toVec4(query(m.EmissiveMap, u, v, du, dv))
+		s.Emissive = math.NewVec3(s.Emissive.X*c.X, s.Emissive.Y*c.Y, s.Emissive.Z*c.Z)
+	}
+	return s
+}
+
+// query samples the texture with the same level of detail selection as the
+// Blinn-Phong shader.
+func query(t *buffer.Texture, u, v, du, dv float32) color.RGBA {
+	lod := float32(0.0)
+	if t.UseMipmap() {
+		siz := float32(t.Size()) * math.Sqrt(math.Max(du, dv))
+		if siz < 1 {
+			siz = 1
+		}
+		lod = math.Log2(siz)
+	}
+	return t.Query(lod, u, v)
+}
+
+func toVec4(c color.RGBA) math.Vec4[float32] {
+	return math.NewVec4(float32(c.R)/0xff, float32(c.G)/0xff, float32(c.B)/0xff, float32(c.A)/0xff)
+}
@@ -20,6 +20,7 @@ var defaultMaterial = &BlinnPhong{
 		AmbientOcclusion: false,
 		ReceiveShadow:    false,
 		Texture:          buffer.NewUniformTexture(color.Blue),
+		Opacity:          1,
 		name:             "default",
 	},
 	Ambient:   color.FromValue(0.7, 0.7, 0.7, 1.0),
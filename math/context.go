@@ -180,3 +180,10 @@ func (ctx *TransformContext[T]) RotateZ(angle T) {
 	ctx.rotation = q.Mul(ctx.rotation)
 	ctx.needUpdate = true
 }
+
+// Transform applies an arbitrary transformation matrix. Like scaling and
+// translation, it is accumulated in the order of the calls.
+func (ctx *TransformContext[T]) Transform(m Mat4[T]) {
+	ctx.internal = m.MulM(ctx.internal)
+	ctx.needUpdate = true
+}
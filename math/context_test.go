@@ -181,3 +181,30 @@ func TestTransformationContextScale(t *testing.T) {
 		}
 	})
 }
+
+func TestTransformationContextTransform(t *testing.T) {
+	ctx := math.TransformContext[float32]{}
+	ctx.ResetContext()
+
+	m := math.NewMat4[float32](
+		0, -2, 0, 1,
+		1, 0, 0, 2,
+		0, 0, 3, 3,
+		0, 0, 0, 1,
+	)
+	ctx.Transform(m)
+	if got := ctx.ModelMatrix(); !got.Eq(m) {
+		t.Fatalf("unexpected model matrix, got %v, want %v", got, m)
+	}
+
+	ctx.Translate(1, 0, 0)
+	want := math.NewMat4[float32](
+		0, -2, 0, 2,
+		1, 0, 0, 2,
+		0, 0, 3, 3,
+		0, 0, 0, 1,
+	)
+	if got := ctx.ModelMatrix(); !got.Eq(want) {
+		t.Fatalf("unexpected model matrix, got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,766 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/geometry"
+	"poly.red/geometry/mesh"
+	"poly.red/internal/imageutil"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model/gltf"
+	"poly.red/scene"
+	"poly.red/scene/object"
+)
+
+// loadGLTF loads a .gltf or .glb file into a group whose children mirror
+// the root nodes of its default scene. Every node is a group carrying the
+// local transform of the node, and every triangle primitive of its mesh a
+// geometry with a buffered mesh and a PBR material.
+func loadGLTF(path string) (*scene.Group, error) {
+	f, err := gltf.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("model: cannot load the given file %v: %w", path, err)
+	}
+
+	l := &gltfLoader{
+		f:        f,
+		mats:     make([]material.Material, len(f.Materials)),
+		textures: map[gltfTexture]*buffer.Texture{},
+		visiting: map[int]bool{},
+	}
+	g := scene.NewGroup()
+	for _, n := range l.roots() {
+		c, err := l.node(n)
+		if err != nil {
+			return nil, fmt.Errorf("model: cannot load the given file %v: %w", path, err)
+		}
+		g.Add(c)
+	}
+	return g, nil
+}
+
+// gltfTexture identifies a decoded image: color textures are converted
+// from sRGB to linear, data textures are not.
+type gltfTexture struct {
+	image int
+	srgb  bool
+}
+
+type gltfLoader struct {
+	f        *gltf.File
+	mats     []material.Material
+	defMat   material.Material
+	textures map[gltfTexture]*buffer.Texture
+	visiting map[int]bool
+}
+
+// roots returns the root nodes of the default scene, or of the first
+// scene, or all nodes that are no child of another node if the file has no
+// scene.
+func (l *gltfLoader) roots() []int {
+	switch {
+	case l.f.Scene != nil && *l.f.Scene >= 0 && *l.f.Scene < len(l.f.Scenes):
+		return l.f.Scenes[*l.f.Scene].Nodes
+	case len(l.f.Scenes) > 0:
+		return l.f.Scenes[0].Nodes
+	}
+	child := make([]bool, len(l.f.Nodes))
+	for _, n := range l.f.Nodes {
+		for _, c := range n.Children {
+			if c >= 0 && c < len(child) {
+				child[c] = true
+			}
+		}
+	}
+	var roots []int
+	for i := range l.f.Nodes {
+		if !child[i] {
+			roots = append(roots, i)
+		}
+	}
+	return roots
+}
+
+func (l *gltfLoader) node(i int) (*scene.Group, error) {
+	if i < 0 || i >= len(l.f.Nodes) {
+		return nil, fmt.Errorf("node %d out of range", i)
+	}
+	if l.visiting[i] {
+		return nil, fmt.Errorf("node %d is its own ancestor", i)
+	}
+	l.visiting[i] = true
+	defer delete(l.visiting, i)
+
+	n := &l.f.Nodes[i]
+	g := scene.NewGroup()
+	if n.Name != "" {
+		g.SetName(n.Name)
+	}
+	if m, ok := nodeMatrix(n); ok {
+		g.Transform(m)
+	}
+
+	if n.Mesh != nil {
+		if *n.Mesh < 0 || *n.Mesh >= len(l.f.Meshes) {
+			return nil, fmt.Errorf("mesh %d out of range", *n.Mesh)
+		}
+		for j := range l.f.Meshes[*n.Mesh].Primitives {
+			geo, err := l.primitive(&l.f.Meshes[*n.Mesh].Primitives[j])
+			if err != nil {
+				return nil, fmt.Errorf("mesh %d, primitive %d: %w", *n.Mesh, j, err)
+			}
+			if geo != nil {
+				g.Add(geo)
+			}
+		}
+	}
+
+	for _, c := range n.Children {
+		cg, err := l.node(c)
+		if err != nil {
+			return nil, err
+		}
+		g.Add(cg)
+	}
+	return g, nil
+}
+
+// nodeMatrix returns the local transform of a node, and false if it has
+// none.
+func nodeMatrix(n *gltf.Node) (math.Mat4[float32], bool) {
+	if m := n.Matrix; m != nil {
+		// Column-major.
+		return math.NewMat4(
+			m[0], m[4], m[8], m[12],
+			m[1], m[5], m[9], m[13],
+			m[2], m[6], m[10], m[14],
+			m[3], m[7], m[11], m[15],
+		), true
+	}
+	if n.Translation == nil && n.Rotation == nil && n.Scale == nil {
+		return math.Mat4I[float32](), false
+	}
+
+	m := math.Mat4I[float32]()
+	if s := n.Scale; s != nil {
+		m = math.NewMat4(
+			s[0], 0, 0, 0,
+			0, s[1], 0, 0,
+			0, 0, s[2], 0,
+			0, 0, 0, 1,
+		)
+	}
+	if r := n.Rotation; r != nil {
+		q := math.NewQuaternion(r[3], r[0], r[1], r[2])
+		m = q.ToRoMat().MulM(m)
+	}
+	if t := n.Translation; t != nil {
+		m = math.NewMat4(
+			1, 0, 0, t[0],
+			0, 1, 0, t[1],
+			0, 0, 1, t[2],
+			0, 0, 0, 1,
+		).MulM(m)
+	}
+	return m, true
+}
+
+// primitive builds the geometry of a primitive, or returns nil for points
+// and lines, which are not rendered.
+func (l *gltfLoader) primitive(p *gltf.Primitive) (*geometry.Geometry, error) {
+	mode := gltf.Triangles
+	if p.Mode != nil {
+		mode = *p.Mode
+	}
+	if mode != gltf.Triangles && mode != gltf.TriangleStrip && mode != gltf.TriangleFan {
+		return nil, nil
+	}
+
+	pa, ok := p.Attributes["POSITION"]
+	if !ok {
+		return nil, errors.New("no POSITION attribute")
+	}
+	pos, n, err := l.f.Floats(pa)
+	if err != nil {
+		return nil, err
+	}
+	if n != 3 {
+		return nil, fmt.Errorf("POSITION has %d components", n)
+	}
+	count := len(pos) / 3
+
+	var idx []int
+	if p.Indices != nil {
+		if idx, err = l.f.Indices(*p.Indices); err != nil {
+			return nil, err
+		}
+		for _, v := range idx {
+			if v >= count {
+				return nil, fmt.Errorf("index %d exceeds %d vertices", v, count)
+			}
+		}
+	} else {
+		idx = make([]int, count)
+		for i := range idx {
+			idx[i] = i
+		}
+	}
+	idx = triangleList(idx, mode)
+	if len(idx) == 0 {
+		return nil, nil
+	}
+
+	bm := mesh.NewBufferedMesh()
+	bm.SetAttribute(mesh.AttribPosition, mesh.NewBufferAttrib(3, pos))
+	attrs := []struct {
+		name   string
+		typ    mesh.AttribType
+		stride int
+	}{
+		{"NORMAL", mesh.AttribNormal, 3},
+		{"TEXCOORD_0", mesh.AttriTexcoord, 2},
+		{"COLOR_0", mesh.AttribColor, 4},
+		{"TANGENT", mesh.AttribTangent, 4},
+	}
+	for _, a := range attrs {
+		acc, ok := p.Attributes[a.name]
+		if !ok {
+			continue
+		}
+		vs, n, err := l.f.Floats(acc)
+		if err != nil {
+			return nil, err
+		}
+		if a.typ == mesh.AttribColor && n == 3 {
+			vs, n = rgbToRGBA(vs), 4
+		}
+		if a.typ == mesh.AttriTexcoord {
+			flipV(vs)
+		}
+		if n != a.stride || len(vs) != count*n {
+			return nil, fmt.Errorf("%s has %d components of %d vertices", a.name, n, len(vs)/max(n, 1))
+		}
+		bm.SetAttribute(a.typ, mesh.NewBufferAttrib(n, vs))
+	}
+	if bm.GetAttribute(mesh.AttribNormal) == nil {
+		bm = flatMesh(bm, idx)
+	} else {
+		bm.SetIndexBuffer(buffer.IndexBuffer(idx))
+	}
+
+	// A primitive with vertex colors but without a material, as Save
+	// writes them, is shaded with its vertex colors.
+	if p.Material == nil && bm.GetAttribute(mesh.AttribColor) != nil {
+		useVertexColor(bm)
+		return geometry.New(bm), nil
+	}
+	mat, err := l.material(p.Material)
+	if err != nil {
+		return nil, err
+	}
+	return geometry.New(bm, mat), nil
+}
+
+// triangleList converts the indices of a triangle strip or fan to those of
+// a triangle list, and drops a trailing incomplete triangle.
+func triangleList(idx []int, mode int) []int {
+	switch mode {
+	case gltf.TriangleStrip:
+		var out []int
+		for i := 2; i < len(idx); i++ {
+			if i%2 == 0 {
+				out = append(out, idx[i-2], idx[i-1], idx[i])
+			} else {
+				out = append(out, idx[i-1], idx[i-2], idx[i])
+			}
+		}
+		return out
+	case gltf.TriangleFan:
+		var out []int
+		for i := 2; i < len(idx); i++ {
+			out = append(out, idx[0], idx[i-1], idx[i])
+		}
+		return out
+	}
+	return idx[:len(idx)/3*3]
+}
+
+func rgbToRGBA(vs []float32) []float32 {
+	out := make([]float32, len(vs)/3*4)
+	for i := 0; i < len(vs)/3; i++ {
+		out[4*i+0] = vs[3*i+0]
+		out[4*i+1] = vs[3*i+1]
+		out[4*i+2] = vs[3*i+2]
+		out[4*i+3] = 1
+	}
+	return out
+}
+
+// flipV converts the texture coordinates uv between glTF, whose origin is
+// the top left corner of an image, and the renderer, whose origin is the
+// bottom left one as in OBJ.
+func flipV(uv []float32) {
+	for i := 1; i < len(uv); i += 2 {
+		uv[i] = 1 - uv[i]
+	}
+}
+
+// flatMesh unwelds the triangles of a mesh without normals and gives each
+// the normal of its face, as glTF asks of such primitives.
+func flatMesh(bm *mesh.BufferedMesh, idx []int) *mesh.BufferedMesh {
+	out := mesh.NewBufferedMesh()
+	for _, typ := range []mesh.AttribType{mesh.AttribPosition, mesh.AttriTexcoord, mesh.AttribColor, mesh.AttribTangent} {
+		a := bm.GetAttribute(typ)
+		if a == nil {
+			continue
+		}
+		vs := make([]float32, 0, len(idx)*a.Stride)
+		for _, i := range idx {
+			vs = append(vs, a.Values[i*a.Stride:(i+1)*a.Stride]...)
+		}
+		out.SetAttribute(typ, mesh.NewBufferAttrib(a.Stride, vs))
+	}
+
+	pos := out.GetAttribute(mesh.AttribPosition).Values
+	nor := make([]float32, len(pos))
+	for i := 0; i < len(pos); i += 9 {
+		v1 := math.NewVec3(pos[i+0], pos[i+1], pos[i+2])
+		v2 := math.NewVec3(pos[i+3], pos[i+4], pos[i+5])
+		v3 := math.NewVec3(pos[i+6], pos[i+7], pos[i+8])
+		n := v2.Sub(v1).Cross(v3.Sub(v1))
+		if n.Len() > 0 {
+			n = n.Unit()
+		}
+		for j := 0; j < 3; j++ {
+			nor[i+3*j+0], nor[i+3*j+1], nor[i+3*j+2] = n.X, n.Y, n.Z
+		}
+	}
+	out.SetAttribute(mesh.AttribNormal, mesh.NewBufferAttrib(3, nor))
+
+	ibo := make(buffer.IndexBuffer, len(idx))
+	for i := range ibo {
+		ibo[i] = i
+	}
+	out.SetIndexBuffer(ibo)
+	return out
+}
+
+// material returns the PBR material of index i, or the default material of
+// glTF if i is nil. Materials are shared by the primitives that use them.
+func (l *gltfLoader) material(i *int) (material.Material, error) {
+	if i == nil {
+		if l.defMat == nil {
+			l.defMat = material.NewPBR(material.Metallic(1), material.Roughness(1))
+		}
+		return l.defMat, nil
+	}
+	if *i < 0 || *i >= len(l.f.Materials) {
+		return nil, fmt.Errorf("material %d out of range", *i)
+	}
+	if l.mats[*i] != nil {
+		return l.mats[*i], nil
+	}
+
+	m := &l.f.Materials[*i]
+	var (
+		base                = [4]float32{1, 1, 1, 1}
+		metallic, roughness = float32(1), float32(1)
+		emissive            [3]float32
+		opts                []material.Option
+	)
+	if m.Name != "" {
+		opts = append(opts, material.Name(m.Name))
+	}
+	if pmr := m.PBRMetallicRoughness; pmr != nil {
+		if pmr.BaseColorFactor != nil {
+			base = *pmr.BaseColorFactor
+		}
+		if pmr.MetallicFactor != nil {
+			metallic = *pmr.MetallicFactor
+		}
+		if pmr.RoughnessFactor != nil {
+			roughness = *pmr.RoughnessFactor
+		}
+		tex, err := l.texture(pmr.BaseColorTexture, true)
+		if err != nil {
+			return nil, err
+		}
+		if tex != nil {
+			opts = append(opts, material.Texture(tex))
+		}
+		if tex, err = l.texture(pmr.MetallicRoughnessTexture, false); err != nil {
+			return nil, err
+		}
+		if tex != nil {
+			opts = append(opts, material.MetallicRoughnessMap(tex))
+		}
+	}
+	if m.EmissiveFactor != nil {
+		emissive = *m.EmissiveFactor
+	}
+	for _, t := range []struct {
+		info *gltf.TextureInfo
+		srgb bool
+		opt  func(*buffer.Texture) material.Option
+	}{
+		{m.NormalTexture, false, material.NormalMap},
+		{m.OcclusionTexture, false, material.OcclusionMap},
+		{m.EmissiveTexture, true, material.EmissiveMap},
+	} {
+		tex, err := l.texture(t.info, t.srgb)
+		if err != nil {
+			return nil, err
+		}
+		if tex != nil {
+			opts = append(opts, t.opt(tex))
+		}
+	}
+
+	// The alpha of the base color is the opacity of blended materials and
+	// ignored otherwise; masking is not supported and renders opaque.
+	if m.AlphaMode == gltf.AlphaBlend {
+		opts = append(opts, material.Opacity(base[3]))
+	}
+	c := func(v float32) float32 { return math.Clamp(v, 0, 1) }
+	opts = append(opts,
+		material.BaseColor(color.FromValue(c(base[0]), c(base[1]), c(base[2]), 1)),
+		material.Metallic(metallic),
+		material.Roughness(roughness),
+		material.Emissive(color.FromValue(c(emissive[0]), c(emissive[1]), c(emissive[2]), 1)),
+	)
+	l.mats[*i] = material.NewPBR(opts...)
+	return l.mats[*i], nil
+}
+
+// texture returns the texture of the given texture info, or nil if there
+// is none or its image is provided only by an extension.
+func (l *gltfLoader) texture(info *gltf.TextureInfo, srgb bool) (*buffer.Texture, error) {
+	if info == nil {
+		return nil, nil
+	}
+	if info.Index < 0 || info.Index >= len(l.f.Textures) {
+		return nil, fmt.Errorf("texture %d out of range", info.Index)
+	}
+	src := l.f.Textures[info.Index].Source
+	if src == nil {
+		return nil, nil
+	}
+	key := gltfTexture{image: *src, srgb: srgb}
+	if t, ok := l.textures[key]; ok {
+		return t, nil
+	}
+
+	b, err := l.f.ImageData(*src)
+	if err != nil {
+		return nil, err
+	}
+	img, err := imageutil.DecodeImage(bytes.NewReader(b), imageutil.GammaCorrect(srgb))
+	if err != nil {
+		return nil, fmt.Errorf("image %d: %w", *src, err)
+	}
+	t := buffer.NewTexture(buffer.TextureImage(img), buffer.TextureIsoMipmap(true),
+		buffer.TextureSource(l.f.ImagePath(*src), srgb))
+	l.textures[key] = t
+	return t, nil
+}
+
+// saveGLTF writes the hierarchy of an object as glTF nodes. A group is a
+// node with its local transform, whose mesh has a primitive per material
+// of each geometry the group holds, and geometries with a transform of
+// their own are child nodes. Materials are written as PBR materials, and
+// their textures are embedded as PNG images.
+func saveGLTF(path string, o object.Object[float32], opt *saveOption) error {
+	w := &gltfWriter{
+		f: &gltf.File{Document: gltf.Document{
+			Asset: gltf.Asset{Version: "2.0", Generator: "polyred"},
+		}},
+		bake:     opt.bake,
+		mats:     map[material.Material]int{},
+		textures: map[gltfImage]int{},
+	}
+	root, err := w.node(o, math.Mat4I[float32]())
+	if err != nil {
+		return err
+	}
+	w.f.Scene = ref(0)
+	w.f.Scenes = []gltf.Scene{{Nodes: []int{root}}}
+	if len(w.bin) > 0 {
+		w.f.Data = [][]byte{w.bin}
+	}
+	glb := filepath.Ext(path) == ".glb"
+	return writeFile(path, func(out io.Writer) error { return w.f.Encode(out, glb) })
+}
+
+// gltfImage identifies a written texture: color textures are converted
+// back to sRGB, data textures are not.
+type gltfImage struct {
+	tex  *buffer.Texture
+	srgb bool
+}
+
+type gltfWriter struct {
+	f        *gltf.File
+	bin      []byte
+	bake     bool
+	mats     map[material.Material]int
+	textures map[gltfImage]int
+}
+
+// node appends the node of an object whose parent has the world transform
+// parent, and returns the index of the node.
+func (w *gltfWriter) node(o object.Object[float32], parent math.Mat4[float32]) (int, error) {
+	world := parent.MulM(o.ModelMatrix())
+	i := len(w.f.Nodes)
+	w.f.Nodes = append(w.f.Nodes, gltf.Node{})
+
+	n := gltf.Node{}
+	if !w.bake && o.ModelMatrix() != math.Mat4I[float32]() {
+		n.Matrix = columnMajor(o.ModelMatrix())
+	}
+	var (
+		geos     []placedGeometry
+		children []object.Object[float32]
+	)
+	switch x := o.(type) {
+	case *scene.Group:
+		if x.Name() != "group" {
+			n.Name = x.Name()
+		}
+		for _, c := range x.Objects() {
+			g, ok := c.(*geometry.Geometry)
+			switch {
+			case ok && w.bake:
+				geos = append(geos, placedGeometry{geo: g, model: world.MulM(g.ModelMatrix())})
+			case ok && g.ModelMatrix() == math.Mat4I[float32]():
+				geos = append(geos, placedGeometry{geo: g, model: math.Mat4I[float32]()})
+			default:
+				children = append(children, c)
+			}
+		}
+	case *geometry.Geometry:
+		m := math.Mat4I[float32]()
+		if w.bake {
+			m = world
+		}
+		geos = append(geos, placedGeometry{geo: x, model: m})
+	}
+
+	var ms gltf.Mesh
+	for _, pg := range geos {
+		ps, err := w.primitives(newMeshData(pg.geo, pg.model))
+		if err != nil {
+			return 0, err
+		}
+		ms.Primitives = append(ms.Primitives, ps...)
+	}
+	if len(ms.Primitives) > 0 {
+		n.Mesh = ref(len(w.f.Meshes))
+		w.f.Meshes = append(w.f.Meshes, ms)
+	}
+	for _, c := range children {
+		ci, err := w.node(c, world)
+		if err != nil {
+			return 0, err
+		}
+		n.Children = append(n.Children, ci)
+	}
+	w.f.Nodes[i] = n
+	return i, nil
+}
+
+func columnMajor(m math.Mat4[float32]) *[16]float32 {
+	return &[16]float32{
+		m.X00, m.X10, m.X20, m.X30,
+		m.X01, m.X11, m.X21, m.X31,
+		m.X02, m.X12, m.X22, m.X32,
+		m.X03, m.X13, m.X23, m.X33,
+	}
+}
+
+// primitives returns a primitive per material of a mesh, which share its
+// vertex attributes.
+func (w *gltfWriter) primitives(md *meshData) ([]gltf.Primitive, error) {
+	if len(md.pos) == 0 {
+		return nil, nil
+	}
+	uv := slices.Clone(md.uv)
+	flipV(uv)
+	attrs := map[string]int{
+		"POSITION":   w.floats(md.pos, "VEC3", true),
+		"NORMAL":     w.floats(md.nor, "VEC3", false),
+		"TEXCOORD_0": w.floats(uv, "VEC2", false),
+	}
+	if md.vertexColored() {
+		attrs["COLOR_0"] = w.floats(md.col, "VEC4", false)
+	}
+	if md.tangents {
+		attrs["TANGENT"] = w.floats(md.tan, "VEC4", false)
+	}
+
+	ps := make([]gltf.Primitive, 0, len(md.parts))
+	for _, part := range md.parts {
+		p := gltf.Primitive{Attributes: attrs, Indices: ref(w.indices(part.idx))}
+		if part.mat != nil {
+			m, err := w.material(part.mat)
+			if err != nil {
+				return nil, err
+			}
+			p.Material = ref(m)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// view appends b to the buffer, aligned to 4 bytes, and returns its
+// buffer view.
+func (w *gltfWriter) view(b []byte) int {
+	for len(w.bin)%4 != 0 {
+		w.bin = append(w.bin, 0)
+	}
+	w.f.BufferViews = append(w.f.BufferViews, gltf.BufferView{
+		ByteOffset: len(w.bin),
+		ByteLength: len(b),
+	})
+	w.bin = append(w.bin, b...)
+	return len(w.f.BufferViews) - 1
+}
+
+// floats appends a float accessor of the given type, and its bounds if
+// asked, as POSITION requires them.
+func (w *gltfWriter) floats(vs []float32, typ string, bounds bool) int {
+	b, _ := binary.Append(nil, binary.LittleEndian, vs)
+	n := map[string]int{"VEC2": 2, "VEC3": 3, "VEC4": 4}[typ]
+	a := gltf.Accessor{
+		BufferView:    ref(w.view(b)),
+		ComponentType: gltf.Float,
+		Count:         len(vs) / n,
+		Type:          typ,
+	}
+	if bounds {
+		a.Min = append([]float32(nil), vs[:n]...)
+		a.Max = append([]float32(nil), vs[:n]...)
+		for i := n; i < len(vs); i++ {
+			a.Min[i%n] = math.Min(a.Min[i%n], vs[i])
+			a.Max[i%n] = math.Max(a.Max[i%n], vs[i])
+		}
+	}
+	w.f.Accessors = append(w.f.Accessors, a)
+	return len(w.f.Accessors) - 1
+}
+
+func (w *gltfWriter) indices(idx []int) int {
+	b := make([]byte, 0, 4*len(idx))
+	for _, i := range idx {
+		b = binary.LittleEndian.AppendUint32(b, uint32(i))
+	}
+	w.f.Accessors = append(w.f.Accessors, gltf.Accessor{
+		BufferView:    ref(w.view(b)),
+		ComponentType: gltf.UnsignedInt,
+		Count:         len(idx),
+		Type:          "SCALAR",
+	})
+	return len(w.f.Accessors) - 1
+}
+
+// material appends a material once and returns its index. A Blinn-Phong
+// material becomes a dielectric of its diffuse color, whose roughness
+// matches its shininess.
+func (w *gltfWriter) material(m material.Material) (int, error) {
+	if i, ok := w.mats[m]; ok {
+		return i, nil
+	}
+
+	type textureMap struct {
+		tex  *buffer.Texture
+		srgb bool
+		set  func(*gltf.Material, *gltf.TextureInfo)
+	}
+	var (
+		base     = color.White
+		metallic = float32(0)
+		rough    = float32(1)
+		emissive = color.Black
+		maps     []textureMap
+	)
+	addMap := func(tex *buffer.Texture, srgb bool, set func(*gltf.Material, *gltf.TextureInfo)) {
+		maps = append(maps, textureMap{tex, srgb, set})
+	}
+	switch x := m.(type) {
+	case *material.PBR:
+		base, metallic, rough, emissive = x.BaseColor, x.Metallic, x.Roughness, x.Emissive
+		addMap(x.MetallicRoughnessMap, false, func(g *gltf.Material, t *gltf.TextureInfo) { g.PBRMetallicRoughness.MetallicRoughnessTexture = t })
+		addMap(x.OcclusionMap, false, func(g *gltf.Material, t *gltf.TextureInfo) { g.OcclusionTexture = t })
+		addMap(x.EmissiveMap, true, func(g *gltf.Material, t *gltf.TextureInfo) { g.EmissiveTexture = t })
+	case *material.BlinnPhong:
+		base, rough, emissive = x.Diffuse, roughness(x.Shininess), x.Emissive
+	}
+
+	f := func(v uint8) float32 { return float32(v) / 0xff }
+	gm := gltf.Material{
+		Name: m.Name(),
+		PBRMetallicRoughness: &gltf.PBRMetallicRoughness{
+			BaseColorFactor: &[4]float32{f(base.R), f(base.G), f(base.B), 1},
+			MetallicFactor:  ref(metallic),
+			RoughnessFactor: ref(rough),
+		},
+		EmissiveFactor: &[3]float32{f(emissive.R), f(emissive.G), f(emissive.B)},
+	}
+	if std := material.StandardOf(m); std != nil {
+		if std.Opacity < 1 {
+			gm.AlphaMode = gltf.AlphaBlend
+			gm.PBRMetallicRoughness.BaseColorFactor[3] = std.Opacity
+		}
+		addMap(std.Texture, true, func(g *gltf.Material, t *gltf.TextureInfo) { g.PBRMetallicRoughness.BaseColorTexture = t })
+		addMap(std.NormalMap, false, func(g *gltf.Material, t *gltf.TextureInfo) { g.NormalTexture = t })
+	}
+	for _, mp := range maps {
+		if mp.tex == nil {
+			continue
+		}
+		t, err := w.texture(mp.tex, mp.srgb)
+		if err != nil {
+			return 0, err
+		}
+		mp.set(&gm, &gltf.TextureInfo{Index: t})
+	}
+
+	w.f.Materials = append(w.f.Materials, gm)
+	w.mats[m] = len(w.f.Materials) - 1
+	return w.mats[m], nil
+}
+
+// texture embeds a texture as a PNG image once and returns its index.
+func (w *gltfWriter) texture(t *buffer.Texture, srgb bool) (int, error) {
+	key := gltfImage{t, srgb}
+	if i, ok := w.textures[key]; ok {
+		return i, nil
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, encodeTexture(t, srgb)); err != nil {
+		return 0, err
+	}
+	w.f.Images = append(w.f.Images, gltf.Image{
+		MimeType:   "image/png",
+		BufferView: ref(w.view(b.Bytes())),
+	})
+	w.f.Textures = append(w.f.Textures, gltf.Texture{Source: ref(len(w.f.Images) - 1)})
+	w.textures[key] = len(w.f.Textures) - 1
+	return w.textures[key], nil
+}
+
+func ref[T any](v T) *T { return &v }
@@ -0,0 +1,69 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package gltf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+)
+
+// Encode writes the file as a .gltf document, whose buffers are embedded
+// as base64 data URIs, or as a .glb container, whose first buffer is the
+// binary chunk.
+func (f *File) Encode(w io.Writer, glb bool) error {
+	doc := f.Document
+	doc.Buffers = make([]Buffer, len(f.Data))
+	for i, b := range f.Data {
+		doc.Buffers[i] = Buffer{ByteLength: len(b)}
+		if !glb || i > 0 {
+			doc.Buffers[i].URI = "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(b)
+		}
+	}
+
+	if !glb {
+		js, err := json.MarshalIndent(&doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(js, '\n'))
+		return err
+	}
+
+	js, err := json.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	js = pad(js, ' ')
+	var bin []byte
+	if len(f.Data) > 0 {
+		bin = pad(append([]byte(nil), f.Data[0]...), 0)
+	}
+
+	n := 12 + 8 + len(js)
+	if bin != nil {
+		n += 8 + len(bin)
+	}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, [3]uint32{glbMagic, 2, uint32(n)})
+	binary.Write(&b, binary.LittleEndian, [2]uint32{uint32(len(js)), glbChunkJSON})
+	b.Write(js)
+	if bin != nil {
+		binary.Write(&b, binary.LittleEndian, [2]uint32{uint32(len(bin)), glbChunkBIN})
+		b.Write(bin)
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// pad pads b to a multiple of 4 bytes, as chunks of a GLB are aligned.
+func pad(b []byte, c byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, c)
+	}
+	return b
+}
@@ -0,0 +1,605 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+// Package gltf is used to parse the glTF 2.0 file format, both the JSON
+// (*.gltf) and the binary (*.glb) variant. Buffers and images are resolved
+// from data URIs, from the binary chunk of a GLB, or from files relative to
+// the document; remote URIs are not fetched. Format spec:
+// https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html
+package gltf
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Document is the JSON part of a glTF asset. Only the properties used to
+// build a scene are decoded; extensions and extras are ignored.
+type Document struct {
+	Asset       Asset        `json:"asset"`
+	Scene       *int         `json:"scene,omitempty"`
+	Scenes      []Scene      `json:"scenes,omitempty"`
+	Nodes       []Node       `json:"nodes,omitempty"`
+	Meshes      []Mesh       `json:"meshes,omitempty"`
+	Materials   []Material   `json:"materials,omitempty"`
+	Textures    []Texture    `json:"textures,omitempty"`
+	Images      []Image      `json:"images,omitempty"`
+	Accessors   []Accessor   `json:"accessors,omitempty"`
+	BufferViews []BufferView `json:"bufferViews,omitempty"`
+	Buffers     []Buffer     `json:"buffers,omitempty"`
+}
+
+type Asset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type Scene struct {
+	Name  string `json:"name,omitempty"`
+	Nodes []int  `json:"nodes,omitempty"`
+}
+
+// Node is a node of the node hierarchy. Its local transform is either
+// Matrix, column-major, or the composition T * R * S of Translation,
+// Rotation (a unit quaternion x, y, z, w) and Scale.
+type Node struct {
+	Name        string       `json:"name,omitempty"`
+	Children    []int        `json:"children,omitempty"`
+	Mesh        *int         `json:"mesh,omitempty"`
+	Matrix      *[16]float32 `json:"matrix,omitempty"`
+	Translation *[3]float32  `json:"translation,omitempty"`
+	Rotation    *[4]float32  `json:"rotation,omitempty"`
+	Scale       *[3]float32  `json:"scale,omitempty"`
+}
+
+type Mesh struct {
+	Name       string      `json:"name,omitempty"`
+	Primitives []Primitive `json:"primitives"`
+}
+
+// Primitive modes.
+const (
+	Points        = 0
+	Lines         = 1
+	LineLoop      = 2
+	LineStrip     = 3
+	Triangles     = 4
+	TriangleStrip = 5
+	TriangleFan   = 6
+)
+
+// Primitive is a set of geometry drawn with a single material. Attributes
+// maps attribute semantics (POSITION, NORMAL, TEXCOORD_0, ...) to accessors.
+type Primitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices,omitempty"`
+	Material   *int           `json:"material,omitempty"`
+	Mode       *int           `json:"mode,omitempty"`
+}
+
+// Alpha modes of a material.
+const (
+	AlphaOpaque = "OPAQUE"
+	AlphaMask   = "MASK"
+	AlphaBlend  = "BLEND"
+)
+
+type Material struct {
+	Name                 string                `json:"name,omitempty"`
+	PBRMetallicRoughness *PBRMetallicRoughness `json:"pbrMetallicRoughness,omitempty"`
+	NormalTexture        *TextureInfo          `json:"normalTexture,omitempty"`
+	OcclusionTexture     *TextureInfo          `json:"occlusionTexture,omitempty"`
+	EmissiveTexture      *TextureInfo          `json:"emissiveTexture,omitempty"`
+	EmissiveFactor       *[3]float32           `json:"emissiveFactor,omitempty"`
+	AlphaMode            string                `json:"alphaMode,omitempty"`
+	AlphaCutoff          *float32              `json:"alphaCutoff,omitempty"`
+	DoubleSided          bool                  `json:"doubleSided,omitempty"`
+}
+
+// PBRMetallicRoughness holds the metallic-roughness parameters of a
+// material. Absent factors default to 1.
+type PBRMetallicRoughness struct {
+	BaseColorFactor          *[4]float32  `json:"baseColorFactor,omitempty"`
+	BaseColorTexture         *TextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor           *float32     `json:"metallicFactor,omitempty"`
+	RoughnessFactor          *float32     `json:"roughnessFactor,omitempty"`
+	MetallicRoughnessTexture *TextureInfo `json:"metallicRoughnessTexture,omitempty"`
+}
+
+type TextureInfo struct {
+	Index    int `json:"index"`
+	TexCoord int `json:"texCoord,omitempty"`
+}
+
+type Texture struct {
+	Source *int `json:"source,omitempty"`
+}
+
+// Image is an image referenced either by URI or by a buffer view.
+type Image struct {
+	Name       string `json:"name,omitempty"`
+	URI        string `json:"uri,omitempty"`
+	MimeType   string `json:"mimeType,omitempty"`
+	BufferView *int   `json:"bufferView,omitempty"`
+}
+
+// Component types of an accessor.
+const (
+	Byte          = 5120
+	UnsignedByte  = 5121
+	Short         = 5122
+	UnsignedShort = 5123
+	UnsignedInt   = 5125
+	Float         = 5126
+)
+
+// Accessor is a typed view into a buffer view. Type is one of SCALAR,
+// VEC2, VEC3, VEC4, MAT2, MAT3 and MAT4.
+type Accessor struct {
+	BufferView    *int      `json:"bufferView,omitempty"`
+	ByteOffset    int       `json:"byteOffset,omitempty"`
+	ComponentType int       `json:"componentType"`
+	Normalized    bool      `json:"normalized,omitempty"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+	Sparse        *Sparse   `json:"sparse,omitempty"`
+}
+
+// Sparse stores the elements of an accessor that deviate from its buffer
+// view, or from zero when it has none.
+type Sparse struct {
+	Count   int `json:"count"`
+	Indices struct {
+		BufferView    int `json:"bufferView"`
+		ByteOffset    int `json:"byteOffset,omitempty"`
+		ComponentType int `json:"componentType"`
+	} `json:"indices"`
+	Values struct {
+		BufferView int `json:"bufferView"`
+		ByteOffset int `json:"byteOffset,omitempty"`
+	} `json:"values"`
+}
+
+type BufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset,omitempty"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride,omitempty"`
+}
+
+type Buffer struct {
+	URI        string `json:"uri,omitempty"`
+	ByteLength int    `json:"byteLength"`
+}
+
+// File contains a glTF document and its resolved buffers.
+type File struct {
+	Document
+	Dir  string   // Directory that relative URIs are resolved against
+	Data [][]byte // Contents of the buffers
+}
+
+// GLB constants.
+const (
+	glbMagic     = 0x46546c67 // "glTF"
+	glbChunkJSON = 0x4e4f534a // "JSON"
+	glbChunkBIN  = 0x004e4942 // "BIN\x00"
+)
+
+// Load loads a .gltf or a .glb file.
+func Load(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("gltf: cannot read file %v: %w", path, err)
+	}
+	return Decode(b, filepath.Dir(path))
+}
+
+// Decode decodes a glTF asset, either JSON or GLB, and resolves its
+// buffers. Relative URIs are resolved against dir.
+func Decode(b []byte, dir string) (*File, error) {
+	f := &File{Dir: dir}
+
+	var bin []byte
+	js := b
+	if len(b) >= 12 && binary.LittleEndian.Uint32(b) == glbMagic {
+		var err error
+		js, bin, err = splitGLB(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(js, &f.Document); err != nil {
+		return nil, fmt.Errorf("gltf: invalid document: %w", err)
+	}
+	if !strings.HasPrefix(f.Asset.Version, "2.") {
+		return nil, fmt.Errorf("gltf: unsupported version %q", f.Asset.Version)
+	}
+
+	f.Data = make([][]byte, len(f.Buffers))
+	for i, buf := range f.Buffers {
+		var (
+			data []byte
+			err  error
+		)
+		if buf.URI == "" {
+			// Only the first buffer of a GLB may omit its URI, which
+			// refers to the binary chunk.
+			if i != 0 || bin == nil {
+				return nil, fmt.Errorf("gltf: buffer %d has no data", i)
+			}
+			data = bin
+		} else if data, err = f.readURI(buf.URI); err != nil {
+			return nil, fmt.Errorf("gltf: buffer %d: %w", i, err)
+		}
+		if len(data) < buf.ByteLength {
+			return nil, fmt.Errorf("gltf: buffer %d holds %d bytes, want %d", i, len(data), buf.ByteLength)
+		}
+		f.Data[i] = data[:buf.ByteLength]
+	}
+	return f, nil
+}
+
+// splitGLB returns the JSON and the binary chunk of a GLB container.
+func splitGLB(b []byte) (js, bin []byte, err error) {
+	if v := binary.LittleEndian.Uint32(b[4:]); v != 2 {
+		return nil, nil, fmt.Errorf("gltf: unsupported GLB version %d", v)
+	}
+	n := int(binary.LittleEndian.Uint32(b[8:]))
+	if n > len(b) {
+		return nil, nil, errors.New("gltf: truncated GLB")
+	}
+	for off := 12; off+8 <= n; {
+		size := int(binary.LittleEndian.Uint32(b[off:]))
+		typ := binary.LittleEndian.Uint32(b[off+4:])
+		off += 8
+		if size < 0 || off+size > n {
+			return nil, nil, errors.New("gltf: truncated GLB chunk")
+		}
+		switch typ {
+		case glbChunkJSON:
+			if js == nil {
+				js = b[off : off+size]
+			}
+		case glbChunkBIN:
+			if bin == nil {
+				bin = b[off : off+size]
+			}
+		}
+		off += size
+	}
+	if js == nil {
+		return nil, nil, errors.New("gltf: GLB without a JSON chunk")
+	}
+	return js, bin, nil
+}
+
+// readURI returns the contents of a data URI or of a file relative to the
+// document.
+func (f *File) readURI(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		i := strings.IndexByte(uri, ',')
+		if i < 0 || !strings.HasSuffix(uri[:i], ";base64") {
+			return nil, fmt.Errorf("unsupported data URI %.32q", uri)
+		}
+		return base64.StdEncoding.DecodeString(uri[i+1:])
+	}
+	if strings.Contains(uri, "://") {
+		return nil, fmt.Errorf("remote URI %q is not supported", uri)
+	}
+	p, err := url.PathUnescape(uri)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(f.Dir, filepath.FromSlash(p)))
+}
+
+// view returns the bytes of buffer view i.
+func (f *File) view(i int) ([]byte, BufferView, error) {
+	if i < 0 || i >= len(f.BufferViews) {
+		return nil, BufferView{}, fmt.Errorf("gltf: buffer view %d out of range", i)
+	}
+	v := f.BufferViews[i]
+	if v.Buffer < 0 || v.Buffer >= len(f.Data) {
+		return nil, v, fmt.Errorf("gltf: buffer %d out of range", v.Buffer)
+	}
+	b := f.Data[v.Buffer]
+	if v.ByteOffset < 0 || v.ByteLength < 0 || v.ByteOffset+v.ByteLength > len(b) {
+		return nil, v, fmt.Errorf("gltf: buffer view %d exceeds its buffer", i)
+	}
+	return b[v.ByteOffset : v.ByteOffset+v.ByteLength], v, nil
+}
+
+// ImageData returns the encoded bytes of image i.
+func (f *File) ImageData(i int) ([]byte, error) {
+	if i < 0 || i >= len(f.Images) {
+		return nil, fmt.Errorf("gltf: image %d out of range", i)
+	}
+	img := f.Images[i]
+	if img.BufferView != nil {
+		b, _, err := f.view(*img.BufferView)
+		return b, err
+	}
+	b, err := f.readURI(img.URI)
+	if err != nil {
+		return nil, fmt.Errorf("gltf: image %d: %w", i, err)
+	}
+	return b, nil
+}
+
+// ImagePath returns the path of the file that image i is read from, or ""
+// if the image is embedded in the document or out of range.
+func (f *File) ImagePath(i int) string {
+	if i < 0 || i >= len(f.Images) {
+		return ""
+	}
+	uri := f.Images[i].URI
+	if f.Images[i].BufferView != nil || strings.HasPrefix(uri, "data:") || strings.Contains(uri, "://") {
+		return ""
+	}
+	p, err := url.PathUnescape(uri)
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(f.Dir, filepath.FromSlash(p))
+}
+
+// components returns the number of components of an accessor type.
+func components(typ string) int {
+	switch typ {
+	case "SCALAR":
+		return 1
+	case "VEC2":
+		return 2
+	case "VEC3":
+		return 3
+	case "VEC4", "MAT2":
+		return 4
+	case "MAT3":
+		return 9
+	case "MAT4":
+		return 16
+	}
+	return 0
+}
+
+// componentSize returns the size in bytes of a component type.
+func componentSize(typ int) int {
+	switch typ {
+	case Byte, UnsignedByte:
+		return 1
+	case Short, UnsignedShort:
+		return 2
+	case UnsignedInt, Float:
+		return 4
+	}
+	return 0
+}
+
+// Floats decodes accessor i into a dense slice of floats, and returns the
+// number of components per element. Normalized integers are mapped to
+// [0, 1] or [-1, 1], other integers are converted as is.
+func (f *File) Floats(i int) ([]float32, int, error) {
+	var out []float32
+	n, err := f.decode(i, func(count, n int) { out = make([]float32, count*n) },
+		func(k int, b []byte, a *Accessor) { out[k] = component(b, a.ComponentType, a.Normalized) })
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, n, nil
+}
+
+// Indices decodes the scalar integer accessor i.
+func (f *File) Indices(i int) ([]int, error) {
+	if i < 0 || i >= len(f.Accessors) {
+		return nil, fmt.Errorf("gltf: accessor %d out of range", i)
+	}
+	switch a := f.Accessors[i]; {
+	case a.Type != "SCALAR":
+		return nil, fmt.Errorf("gltf: index accessor %d is of type %v", i, a.Type)
+	case a.ComponentType != UnsignedByte && a.ComponentType != UnsignedShort && a.ComponentType != UnsignedInt:
+		return nil, fmt.Errorf("gltf: index accessor %d has component type %v", i, a.ComponentType)
+	}
+	var out []int
+	_, err := f.decode(i, func(count, _ int) { out = make([]int, count) },
+		func(k int, b []byte, a *Accessor) { out[k] = index(b, a.ComponentType) })
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decode walks the components of accessor i, first those of its buffer
+// view and then its sparse substitutions. alloc receives the element count
+// and the number of components per element before put is called with the
+// flat index and the bytes of each component. The count is checked against
+// the buffer view and the sparse data before alloc, such that a malformed
+// count cannot allocate more than the file holds.
+func (f *File) decode(i int, alloc func(count, n int), put func(k int, b []byte, a *Accessor)) (int, error) {
+	if i < 0 || i >= len(f.Accessors) {
+		return 0, fmt.Errorf("gltf: accessor %d out of range", i)
+	}
+	a := &f.Accessors[i]
+	n, size := components(a.Type), componentSize(a.ComponentType)
+	if n == 0 || size == 0 {
+		return 0, fmt.Errorf("gltf: accessor %d has unsupported type %v of %v", i, a.Type, a.ComponentType)
+	}
+	if a.Count < 0 {
+		return 0, fmt.Errorf("gltf: accessor %d has a negative count", i)
+	}
+
+	var (
+		b      []byte
+		stride int
+	)
+	if a.BufferView != nil {
+		var (
+			v   BufferView
+			err error
+		)
+		b, v, err = f.view(*a.BufferView)
+		if err != nil {
+			return 0, err
+		}
+		stride = v.ByteStride
+		if stride == 0 {
+			stride = n * size
+		}
+		if stride < n*size {
+			return 0, fmt.Errorf("gltf: buffer view %d has a byte stride below the element size of accessor %d", *a.BufferView, i)
+		}
+		if !fits(a.ByteOffset, a.Count, stride, n*size, len(b)) {
+			return 0, fmt.Errorf("gltf: accessor %d exceeds its buffer view", i)
+		}
+	} else if a.Count > f.dataSize()/(n*size) {
+		// Without a buffer view the elements are zeros, which are no more
+		// than the binary data of the file holds for the attributes they
+		// go with.
+		return 0, fmt.Errorf("gltf: accessor %d without buffer view exceeds the size of the buffers", i)
+	}
+
+	var (
+		idx  []int
+		vals []byte
+	)
+	if s := a.Sparse; s != nil {
+		var err error
+		idx, err = f.sparseIndices(s)
+		if err != nil {
+			return 0, fmt.Errorf("gltf: accessor %d: %w", i, err)
+		}
+		for _, e := range idx {
+			if e < 0 || e >= a.Count {
+				return 0, fmt.Errorf("gltf: sparse index %d of accessor %d out of range", e, i)
+			}
+		}
+		vals, _, err = f.view(s.Values.BufferView)
+		if err != nil {
+			return 0, err
+		}
+		if !fits(s.Values.ByteOffset, s.Count, n*size, n*size, len(vals)) {
+			return 0, fmt.Errorf("gltf: sparse values of accessor %d exceed their buffer view", i)
+		}
+	}
+
+	alloc(a.Count, n)
+	if b != nil {
+		for e := 0; e < a.Count; e++ {
+			off := a.ByteOffset + e*stride
+			for c := 0; c < n; c++ {
+				put(e*n+c, b[off+c*size:], a)
+			}
+		}
+	}
+	for k, e := range idx {
+		off := a.Sparse.Values.ByteOffset + k*n*size
+		for c := 0; c < n; c++ {
+			put(e*n+c, vals[off+c*size:], a)
+		}
+	}
+	return n, nil
+}
+
+func (f *File) sparseIndices(s *Sparse) ([]int, error) {
+	b, _, err := f.view(s.Indices.BufferView)
+	if err != nil {
+		return nil, err
+	}
+	typ := s.Indices.ComponentType
+	if typ != UnsignedByte && typ != UnsignedShort && typ != UnsignedInt {
+		return nil, fmt.Errorf("unsupported sparse index type %v", typ)
+	}
+	size := componentSize(typ)
+	if !fits(s.Indices.ByteOffset, s.Count, size, size, len(b)) {
+		return nil, errors.New("sparse indices exceed their buffer view")
+	}
+	idx := make([]int, s.Count)
+	for k := range idx {
+		idx[k] = index(b[s.Indices.ByteOffset+k*size:], typ)
+	}
+	return idx, nil
+}
+
+// fits reports whether count elements of size bytes, the first at offset
+// off and each stride bytes after the previous, lie within length bytes.
+// It does not overflow for any count.
+func fits(off, count, stride, size, length int) bool {
+	if off < 0 || count < 0 || off > length {
+		return false
+	}
+	if count == 0 {
+		return true
+	}
+	if size > length-off {
+		return false
+	}
+	return count-1 <= (length-off-size)/stride
+}
+
+// dataSize returns the size in bytes of all buffers of the file.
+func (f *File) dataSize() int {
+	n := 0
+	for _, b := range f.Data {
+		n += len(b)
+	}
+	return n
+}
+
+// index decodes an unsigned integer component.
+func index(b []byte, typ int) int {
+	switch typ {
+	case UnsignedByte:
+		return int(b[0])
+	case UnsignedShort:
+		return int(binary.LittleEndian.Uint16(b))
+	case UnsignedInt:
+		return int(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+// component decodes a single component as a float.
+func component(b []byte, typ int, normalized bool) float32 {
+	switch typ {
+	case Byte:
+		v := float32(int8(b[0]))
+		if normalized {
+			return max(v/127, -1)
+		}
+		return v
+	case UnsignedByte:
+		v := float32(b[0])
+		if normalized {
+			return v / 255
+		}
+		return v
+	case Short:
+		v := float32(int16(binary.LittleEndian.Uint16(b)))
+		if normalized {
+			return max(v/32767, -1)
+		}
+		return v
+	case UnsignedShort:
+		v := float32(binary.LittleEndian.Uint16(b))
+		if normalized {
+			return v / 65535
+		}
+		return v
+	case UnsignedInt:
+		return float32(binary.LittleEndian.Uint32(b))
+	case Float:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
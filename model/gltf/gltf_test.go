@@ -0,0 +1,197 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package gltf_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"poly.red/model/gltf"
+)
+
+func TestParseGLTF(t *testing.T) {
+	for _, path := range []string{"../../internal/testdata/quad.gltf", "../../internal/testdata/quad.glb"} {
+		f, err := gltf.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Nodes) != 3 || len(f.Meshes[0].Primitives) != 2 {
+			t.Fatalf("%s: %d nodes, %d primitives", path, len(f.Nodes), len(f.Meshes[0].Primitives))
+		}
+		idx, err := f.Indices(*f.Meshes[0].Primitives[0].Indices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []int{0, 1, 2, 0, 2, 3}; !reflect.DeepEqual(idx, want) {
+			t.Fatalf("%s: indices %v, want %v", path, idx, want)
+		}
+		b, err := f.ImageData(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b[1:4]) != "PNG" {
+			t.Fatalf("%s: image is not a PNG", path)
+		}
+	}
+}
+
+// TestAccessor checks interleaved, normalized and sparse accessors.
+func TestAccessor(t *testing.T) {
+	// Two interleaved elements of a normalized ubyte VEC2 and a short
+	// SCALAR with a stride of 4, followed by a sparse substitution of the
+	// second element of the VEC2 accessor.
+	buf := []byte{
+		0, 255, 0x00, 0x80,
+		51, 102, 0xff, 0x7f,
+		1,        // sparse index
+		255, 255, // sparse value
+	}
+	src := fmt.Sprintf(`{
+	"asset": {"version": "2.0"},
+	"buffers": [{"byteLength": %d, "uri": "data:application/octet-stream;base64,%s"}],
+	"bufferViews": [
+		{"buffer": 0, "byteLength": 8, "byteStride": 4},
+		{"buffer": 0, "byteOffset": 8, "byteLength": 1},
+		{"buffer": 0, "byteOffset": 9, "byteLength": 2}
+	],
+	"accessors": [
+		{"bufferView": 0, "componentType": 5121, "normalized": true, "count": 2, "type": "VEC2"},
+		{"bufferView": 0, "byteOffset": 2, "componentType": 5122, "normalized": true, "count": 2, "type": "SCALAR"},
+		{"bufferView": 0, "componentType": 5121, "normalized": true, "count": 2, "type": "VEC2",
+		 "sparse": {"count": 1, "indices": {"bufferView": 1, "componentType": 5121}, "values": {"bufferView": 2}}},
+		{"componentType": 5126, "count": 2, "type": "SCALAR"}
+	]
+}`, len(buf), base64.StdEncoding.EncodeToString(buf))
+
+	f, err := gltf.Decode([]byte(src), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		acc  int
+		want []float32
+		n    int
+	}{
+		{0, []float32{0, 1, 0.2, 0.4}, 2},
+		{1, []float32{-1, 1}, 1},
+		{2, []float32{0, 1, 1, 1}, 2},
+		{3, []float32{0, 0}, 1},
+	}
+	for _, tt := range tests {
+		got, n, err := f.Floats(tt.acc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.n || !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("accessor %d: got %v (%d), want %v (%d)", tt.acc, got, n, tt.want, tt.n)
+		}
+	}
+	if _, err := f.Indices(1); err == nil {
+		t.Fatal("want an error for signed indices")
+	}
+}
+
+// TestAccessorErrors checks that malformed accessors fail before their
+// elements are allocated, so that a huge count is an error rather than an
+// allocation of the size it claims.
+func TestAccessorErrors(t *testing.T) {
+	buf := make([]byte, 16)
+	buf[8] = 5 // sparse index out of range
+	src := fmt.Sprintf(`{
+	"asset": {"version": "2.0"},
+	"buffers": [{"byteLength": %d, "uri": "data:application/octet-stream;base64,%s"}],
+	"bufferViews": [
+		{"buffer": 0, "byteLength": 8},
+		{"buffer": 0, "byteLength": 8, "byteStride": 2},
+		{"buffer": 0, "byteOffset": 8, "byteLength": 1},
+		{"buffer": 0, "byteOffset": 12, "byteLength": 4}
+	],
+	"accessors": [
+		{"bufferView": 0, "componentType": 5126, "count": 1099511627776, "type": "SCALAR"},
+		{"bufferView": 0, "componentType": 5126, "count": 2305843009213693952, "type": "SCALAR"},
+		{"bufferView": 0, "byteOffset": -4, "componentType": 5126, "count": 1, "type": "SCALAR"},
+		{"bufferView": 1, "componentType": 5126, "count": 2, "type": "SCALAR"},
+		{"componentType": 5126, "count": 1099511627776, "type": "VEC4"},
+		{"componentType": 5126, "count": 2, "type": "SCALAR",
+		 "sparse": {"count": 1, "indices": {"bufferView": 2, "componentType": 5121}, "values": {"bufferView": 3}}},
+		{"componentType": 5126, "count": 8, "type": "SCALAR",
+		 "sparse": {"count": 1099511627776, "indices": {"bufferView": 2, "componentType": 5121}, "values": {"bufferView": 3}}},
+		{"bufferView": 0, "componentType": 5126, "count": 1, "type": "SCALAR",
+		 "sparse": {"count": 1, "indices": {"bufferView": 2, "componentType": 5121}, "values": {"bufferView": 3, "byteOffset": 2}}}
+	]
+}`, len(buf), base64.StdEncoding.EncodeToString(buf))
+
+	f, err := gltf.Decode([]byte(src), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range f.Accessors {
+		if got, _, err := f.Floats(i); err == nil {
+			t.Errorf("accessor %d: want an error, got %d values", i, len(got))
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	glb := func(js string) []byte {
+		for len(js)%4 != 0 {
+			js += " "
+		}
+		b := binary.LittleEndian.AppendUint32(nil, 0x46546c67)
+		b = binary.LittleEndian.AppendUint32(b, 2)
+		b = binary.LittleEndian.AppendUint32(b, uint32(20+len(js)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(js)))
+		b = binary.LittleEndian.AppendUint32(b, 0x4e4f534a)
+		return append(b, js...)
+	}
+	tests := map[string][]byte{
+		"version":     []byte(`{"asset": {"version": "1.0"}}`),
+		"remote":      []byte(`{"asset": {"version": "2.0"}, "buffers": [{"byteLength": 4, "uri": "https://example.com/a.bin"}]}`),
+		"short":       []byte(`{"asset": {"version": "2.0"}, "buffers": [{"byteLength": 4, "uri": "data:application/octet-stream;base64,AAA="}]}`),
+		"no BIN":      glb(`{"asset": {"version": "2.0"}, "buffers": [{"byteLength": 4}]}`),
+		"truncated":   glb(`{"asset": {"version": "2.0"}}`)[:16],
+		"invalid doc": []byte(`{`),
+	}
+	for name, b := range tests {
+		if _, err := gltf.Decode(b, ""); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+	if _, err := gltf.Decode(glb(`{"asset": {"version": "2.0"}}`), ""); err != nil {
+		t.Fatalf("GLB without BIN chunk: %v", err)
+	}
+}
+
+// TestEncode encodes the quad asset as .gltf and as .glb, and decodes it.
+func TestEncode(t *testing.T) {
+	f, err := gltf.Load("../../internal/testdata/quad.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, glb := range []bool{false, true} {
+		var b bytes.Buffer
+		if err := f.Encode(&b, glb); err != nil {
+			t.Fatal(err)
+		}
+		if glb && (b.Len()%4 != 0 || string(b.Bytes()[:4]) != "glTF") {
+			t.Fatalf("invalid glb header, %d bytes", b.Len())
+		}
+		got, err := gltf.Decode(b.Bytes(), f.Dir)
+		if err != nil {
+			t.Fatalf("glb %v: %v", glb, err)
+		}
+		if !reflect.DeepEqual(got.Data, f.Data) {
+			t.Fatalf("glb %v: buffers differ", glb)
+		}
+		got.Buffers, f.Buffers = nil, nil
+		if !reflect.DeepEqual(got.Document, f.Document) {
+			t.Fatalf("glb %v: document %+v, want %+v", glb, got.Document, f.Document)
+		}
+	}
+}
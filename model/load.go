@@ -20,6 +20,13 @@ import (
 	"poly.red/scene"
 )
 
+// The formats of Load load the model files that scene files reference.
+func init() {
+	for _, ext := range []string{".obj", ".gltf", ".glb", ".ply", ".stl"} {
+		scene.RegisterFormat(ext, Load)
+	}
+}
+
 func MustLoad(path string) *scene.Group {
 	m, err := Load(path)
 	if err != nil {
@@ -28,13 +35,31 @@ func MustLoad(path string) *scene.Group {
 	return m
 }
 
+// Load loads a model file of the format of its extension. The source of
+// the returned group is the path, hence scene.Save references the file
+// instead of writing the group.
 func Load(path string) (*scene.Group, error) {
+	var (
+		g   *scene.Group
+		err error
+	)
 	switch v := filepath.Ext(path); v {
 	case ".obj":
-		return loadObj(path)
+		g, err = loadObj(path)
+	case ".gltf", ".glb":
+		g, err = loadGLTF(path)
+	case ".ply":
+		g, err = loadPly(path)
+	case ".stl":
+		g, err = loadStl(path)
 	default:
 		return nil, fmt.Errorf("model: unsupported format %v", v)
 	}
+	if err != nil {
+		return nil, err
+	}
+	g.SetSource(path)
+	return g, nil
 }
 
 func loadObj(path string) (*scene.Group, error) {
@@ -48,47 +73,29 @@ func loadObj(path string) (*scene.Group, error) {
 	// Create all named materials. A .mtl name with no material (nil) is omitted;
 	// faces referencing it fall back to material.Default() below.
 	allMats := map[string]*material.BlinnPhong{}
+	textures := map[objTexture]*buffer.Texture{}
 	for name, mat := range fi.Materials {
 		if mat == nil {
 			continue
 		}
-		switch mat.Illum {
-		case 0, 2:
-			opts := []material.Option{
-				material.Name(name),
-				material.Diffuse(mat.Diffuse),
-				material.Specular(mat.Specular),
-				material.Shininess(mat.Shininess),
-				material.FlatShading(false),
-				material.AmbientOcclusion(false),
-				material.ReceiveShadow(false),
-			}
-			if mat.MapKd == "" {
-				opts = append(opts, material.Texture(buffer.NewUniformTexture(color.Blue)))
-			} else {
-				p := filepath.Join(filepath.Clean(fi.MtlDir), filepath.Clean(mat.MapKd))
-				opts = append(opts, material.Texture(buffer.NewTexture(
-					buffer.TextureImage(
-						// FIXME: this might be problematic when the MapKd is a windows \ separated path.
-						imageutil.MustLoadImage(p,
-							// FIXME: make sure renderer know about this and do gamma correct if needed.
-							imageutil.GammaCorrect(true),
-						)),
-					buffer.TextureIsoMipmap(true),
-				)))
-			}
-			allMats[name] = material.NewBlinnPhong(opts...)
-		default:
-			panic("unsupported illumination model")
+		m, err := newObjMaterial(fi.MtlDir, name, mat, textures)
+		if err != nil {
+			return nil, fmt.Errorf("model: cannot load the given file %v: %w", path, err)
 		}
+		allMats[name] = m
 	}
 
-	// Create all mesh objects.
+	// Create all mesh objects. Objects without faces, such as those of
+	// only lines, are not rendered.
 	for i := range fi.Objs {
+		if len(fi.Objs[i].Faces) == 0 {
+			continue
+		}
+
 		var (
-			tris     []*primitive.Triangle
-			quads    []*primitive.Quad
-			polygons []*primitive.Polygon
+			faces []primitive.Face
+			tris  []*primitive.Triangle
+			quads []*primitive.Quad
 
 			// The unique materials this geometry owns, and a map from material to
 			// its geometry-local index. Faces carry the local index.
@@ -112,34 +119,34 @@ func loadObj(path string) (*scene.Group, error) {
 			}
 			materialID := localIndex(mat)
 
+			// The texture coordinates are transformed by the -o and -s
+			// options of the diffuse map.
+			var uv obj.TextureMap
+			if m := fi.Materials[face.Material]; m != nil {
+				uv = m.MapKd
+			}
+
 			switch len(face.Vertices) {
 			case 3:
-				tris = append(tris, newTrianglePrimitive(fi, &face, materialID))
+				t := newTrianglePrimitive(fi, &face, materialID, uv)
+				tris = append(tris, t)
+				faces = append(faces, t)
 			case 4:
-				quads = append(quads, newQuadPrimitive(fi, &face, materialID))
+				q := newQuadPrimitive(fi, &face, materialID, uv)
+				quads = append(quads, q)
+				faces = append(faces, q)
 			default:
-				polygons = append(polygons, newPolygonPrimitive(fi, &face, materialID))
+				faces = append(faces, newPolygonPrimitive(fi, &face, materialID, uv))
 			}
-
 		}
 
 		var m mesh.Mesh
-		switch {
-		case len(tris) != 0 && len(quads) == 0: // only with triangles
+		switch len(faces) {
+		case len(tris): // only with triangles
 			m = mesh.NewTriangleMesh(tris)
-		case len(tris) == 0 && len(quads) != 0: // only with quads
+		case len(quads): // only with quads
 			m = mesh.NewQuadMesh(quads)
-		case len(tris) != 0 && len(quads) != 0: // hybrid
-			faces := []primitive.Face{}
-			for i := range tris {
-				faces = append(faces, tris[i])
-			}
-			for i := range quads {
-				faces = append(faces, quads[i])
-			}
-			for i := range polygons {
-				faces = append(faces, polygons[i])
-			}
+		default: // hybrid, with polygons
 			m = mesh.NewPolygonMesh(faces)
 		}
 
@@ -151,107 +158,163 @@ func loadObj(path string) (*scene.Group, error) {
 	return g, nil
 }
 
-func newTrianglePrimitive(f *obj.File, face *obj.Face, materialID int64) *primitive.Triangle {
-	t := &primitive.Triangle{
-		V1:         primitive.NewVertex(),
-		V2:         primitive.NewVertex(),
-		V3:         primitive.NewVertex(),
-		MaterialID: materialID,
+// objTexture identifies a loaded texture map. Color maps are converted
+// from sRGB to linear, and height maps become normal maps of the given
+// bump multiplier.
+type objTexture struct {
+	path string
+	srgb bool
+	bump float32
+}
+
+// newObjMaterial creates the Blinn-Phong material of an obj material.
+// Illumination models 0 and 1 have no highlights. The others are shaded
+// with highlights, without their ray traced reflections and refractions.
+//
+// The diffuse and specular maps scale the diffuse and specular colors,
+// map_d scales the opacity, and norm, or otherwise map_Bump, perturbs
+// the normals. A grayscale bump map is a height map, which is converted
+// to a normal map.
+func newObjMaterial(dir, name string, mat *obj.Material, textures map[objTexture]*buffer.Texture) (*material.BlinnPhong, error) {
+	specular := mat.Specular
+	if mat.Illum == 0 || mat.Illum == 1 {
+		specular = color.Black
+	}
+	opts := []material.Option{
+		material.Name(name),
+		material.Diffuse(mat.Diffuse),
+		material.Specular(specular),
+		material.Shininess(mat.Shininess),
+		material.Opacity(mat.Opacity),
+		material.FlatShading(false),
+		material.AmbientOcclusion(false),
+		material.ReceiveShadow(false),
 	}
-	vs := face.Vertices
-	t.V1.Pos = math.NewVec4(f.Vertices[3*vs[0]+0], f.Vertices[3*vs[0]+1], f.Vertices[3*vs[0]+2], 1)
-	t.V2.Pos = math.NewVec4(f.Vertices[3*vs[1]+0], f.Vertices[3*vs[1]+1], f.Vertices[3*vs[1]+2], 1)
-	t.V3.Pos = math.NewVec4(f.Vertices[3*vs[2]+0], f.Vertices[3*vs[2]+1], f.Vertices[3*vs[2]+2], 1)
 
-	vs = face.Normals
-	if len(f.Normals) > 0 {
-		t.V1.Nor = math.NewVec4(f.Normals[3*vs[0]], f.Normals[3*vs[0]+1], f.Normals[3*vs[0]+2], 0)
-		t.V2.Nor = math.NewVec4(f.Normals[3*vs[1]], f.Normals[3*vs[1]+1], f.Normals[3*vs[1]+2], 0)
-		t.V3.Nor = math.NewVec4(f.Normals[3*vs[2]], f.Normals[3*vs[2]+1], f.Normals[3*vs[2]+2], 0)
-		if t.V1.Nor.IsZero() {
-			t.V1.Nor = t.Normal()
+	load := func(m obj.TextureMap, srgb, bump bool) (*buffer.Texture, error) {
+		key := objTexture{path: filepath.FromSlash(m.Path), srgb: srgb}
+		if !filepath.IsAbs(key.path) {
+			key.path = filepath.Join(dir, key.path)
+		}
+		if bump {
+			key.bump = m.Bump
 		}
-		if t.V2.Nor.IsZero() {
-			t.V1.Nor = t.Normal()
+		if tex, ok := textures[key]; ok {
+			return tex, nil
 		}
-		if t.V3.Nor.IsZero() {
-			t.V1.Nor = t.Normal()
+		img, err := imageutil.LoadImage(key.path, imageutil.GammaCorrect(srgb))
+		if err != nil {
+			return nil, err
 		}
+		src := buffer.TextureSource(key.path, srgb)
+		if bump && imageutil.IsGray(img) {
+			// The normal map converted from a height map is not the file.
+			img, src = imageutil.HeightToNormal(img, m.Bump), buffer.TextureSource("", false)
+		}
+		tex := buffer.NewTexture(buffer.TextureImage(img), buffer.TextureIsoMipmap(true), src)
+		textures[key] = tex
+		return tex, nil
 	}
 
-	vs = face.Uvs
-	if len(f.Uvs) > 0 {
-		t.V1.UV = math.NewVec2(f.Uvs[2*vs[0]], f.Uvs[2*vs[0]+1])
-		t.V2.UV = math.NewVec2(f.Uvs[2*vs[1]], f.Uvs[2*vs[1]+1])
-		t.V3.UV = math.NewVec2(f.Uvs[2*vs[2]], f.Uvs[2*vs[2]+1])
+	type textureMap struct {
+		m          obj.TextureMap
+		srgb, bump bool
+		opt        func(*buffer.Texture) material.Option
+	}
+	maps := []textureMap{
+		{mat.MapKd, true, false, material.Texture},
+		{mat.MapKs, true, false, material.SpecularMap},
+		{mat.MapD, false, false, material.AlphaMap},
+		{mat.MapNorm, false, false, material.NormalMap},
+	}
+	if mat.MapNorm.Path == "" {
+		maps = append(maps, textureMap{mat.MapBump, false, true, material.NormalMap})
+	}
+	for _, t := range maps {
+		if t.m.Path == "" {
+			continue
+		}
+		tex, err := load(t.m, t.srgb, t.bump)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, t.opt(tex))
 	}
+	if mat.MapKd.Path == "" {
+		opts = append(opts, material.Texture(buffer.NewUniformTexture(color.Blue)))
+	}
+	return material.NewBlinnPhong(opts...), nil
+}
 
-	t.V1.Col = color.FromValue[float32](1, 1, 1, 1)
-	t.V2.Col = color.FromValue[float32](1, 1, 1, 1)
-	t.V3.Col = color.FromValue[float32](1, 1, 1, 1)
-	return t
+// objVertex returns the i-th vertex of a face. Its texture coordinates
+// are transformed by the offset and scale of a texture map, and its
+// normal is zero if the face has none.
+func objVertex(f *obj.File, face *obj.Face, i int, uv obj.TextureMap) *primitive.Vertex {
+	v := primitive.NewVertex()
+	vi := face.Vertices[i]
+	v.Pos = math.NewVec4(f.Vertices[3*vi+0], f.Vertices[3*vi+1], f.Vertices[3*vi+2], 1)
+	v.Col = vertexColor(f, vi)
+	if i < len(face.Normals) {
+		if n := face.Normals[i]; n >= 0 && 3*n+2 < len(f.Normals) {
+			v.Nor = math.NewVec4(f.Normals[3*n], f.Normals[3*n+1], f.Normals[3*n+2], 0)
+		}
+	}
+	if i < len(face.Uvs) {
+		if t := face.Uvs[i]; t >= 0 && 2*t+1 < len(f.Uvs) {
+			v.UV = math.NewVec2(f.Uvs[2*t], f.Uvs[2*t+1])
+			if uv.Path != "" {
+				v.UV.X = v.UV.X*uv.Scale[0] + uv.Offset[0]
+				v.UV.Y = v.UV.Y*uv.Scale[1] + uv.Offset[1]
+			}
+		}
+	}
+	return v
 }
 
-func newQuadPrimitive(f *obj.File, face *obj.Face, materialID int64) *primitive.Quad {
-	t := &primitive.Quad{
-		V1:         primitive.NewVertex(),
-		V2:         primitive.NewVertex(),
-		V3:         primitive.NewVertex(),
-		V4:         primitive.NewVertex(),
+func newTrianglePrimitive(f *obj.File, face *obj.Face, materialID int64, uv obj.TextureMap) *primitive.Triangle {
+	t := &primitive.Triangle{
+		V1:         objVertex(f, face, 0, uv),
+		V2:         objVertex(f, face, 1, uv),
+		V3:         objVertex(f, face, 2, uv),
 		MaterialID: materialID,
 	}
-	vs := face.Vertices
-	t.V1.Pos = math.NewVec4(f.Vertices[3*vs[0]+0], f.Vertices[3*vs[0]+1], f.Vertices[3*vs[0]+2], 1)
-	t.V2.Pos = math.NewVec4(f.Vertices[3*vs[1]+0], f.Vertices[3*vs[1]+1], f.Vertices[3*vs[1]+2], 1)
-	t.V3.Pos = math.NewVec4(f.Vertices[3*vs[2]+0], f.Vertices[3*vs[2]+1], f.Vertices[3*vs[2]+2], 1)
-	t.V4.Pos = math.NewVec4(f.Vertices[3*vs[3]+0], f.Vertices[3*vs[3]+1], f.Vertices[3*vs[3]+2], 1)
-
-	vs = face.Normals
 	if len(f.Normals) > 0 {
-		t.V1.Nor = math.NewVec4(f.Normals[3*vs[0]], f.Normals[3*vs[0]+1], f.Normals[3*vs[0]+2], 0)
-		t.V2.Nor = math.NewVec4(f.Normals[3*vs[1]], f.Normals[3*vs[1]+1], f.Normals[3*vs[1]+2], 0)
-		t.V3.Nor = math.NewVec4(f.Normals[3*vs[2]], f.Normals[3*vs[2]+1], f.Normals[3*vs[2]+2], 0)
-		t.V4.Nor = math.NewVec4(f.Normals[3*vs[3]], f.Normals[3*vs[3]+1], f.Normals[3*vs[3]+2], 0)
+		for _, v := range []*primitive.Vertex{t.V1, t.V2, t.V3} {
+			if v.Nor.IsZero() {
+				v.Nor = t.Normal()
+			}
+		}
 	}
+	return t
+}
 
-	vs = face.Uvs
-	if len(f.Uvs) > 0 {
-		t.V1.UV = math.NewVec2(f.Uvs[2*vs[0]], f.Uvs[2*vs[0]+1])
-		t.V2.UV = math.NewVec2(f.Uvs[2*vs[1]], f.Uvs[2*vs[1]+1])
-		t.V3.UV = math.NewVec2(f.Uvs[2*vs[2]], f.Uvs[2*vs[2]+1])
-		t.V4.UV = math.NewVec2(f.Uvs[2*vs[3]], f.Uvs[2*vs[3]+1])
+func newQuadPrimitive(f *obj.File, face *obj.Face, materialID int64, uv obj.TextureMap) *primitive.Quad {
+	return &primitive.Quad{
+		V1:         objVertex(f, face, 0, uv),
+		V2:         objVertex(f, face, 1, uv),
+		V3:         objVertex(f, face, 2, uv),
+		V4:         objVertex(f, face, 3, uv),
+		MaterialID: materialID,
 	}
-
-	t.V1.Col = color.FromValue[float32](1, 1, 1, 1)
-	t.V2.Col = color.FromValue[float32](1, 1, 1, 1)
-	t.V3.Col = color.FromValue[float32](1, 1, 1, 1)
-	t.V4.Col = color.FromValue[float32](1, 1, 1, 1)
-	return t
 }
 
-func newPolygonPrimitive(f *obj.File, face *obj.Face, materialID int64) *primitive.Polygon {
+func newPolygonPrimitive(f *obj.File, face *obj.Face, materialID int64, uv obj.TextureMap) *primitive.Polygon {
 	t := &primitive.Polygon{
 		Verts:      make([]*primitive.Vertex, len(face.Vertices)),
 		MaterialID: materialID,
 	}
 	for i := range t.Verts {
-		t.Verts[i] = primitive.NewVertex()
-
-		vs := face.Vertices
-		t.Verts[i].Pos = math.NewVec4(f.Vertices[3*vs[i%len(vs)]+0], f.Vertices[3*vs[i%len(vs)]+1], f.Vertices[3*vs[i%len(vs)]+2], 1)
-
-		vs = face.Normals
-		if len(f.Normals) > 0 {
-			t.Verts[i].Nor = math.NewVec4(f.Normals[3*vs[i%len(vs)]], f.Normals[3*vs[i%len(vs)]+1], f.Normals[3*vs[i%len(vs)]+2], 0)
-		}
-
-		vs = face.Uvs
-		if len(f.Uvs) > 0 {
-			t.Verts[i].UV = math.NewVec2(f.Uvs[2*vs[i%len(vs)]], f.Uvs[2*vs[i%len(vs)]+1])
-		}
-
-		t.Verts[i].Col = color.FromValue[float32](1, 1, 1, 1)
+		t.Verts[i] = objVertex(f, face, i, uv)
 	}
-
 	return t
 }
+
+// vertexColor returns the color of vertex i, which is white unless the
+// file has vertex colors.
+func vertexColor(f *obj.File, i int) color.RGBA {
+	if 3*i+2 >= len(f.Colors) {
+		return color.White
+	}
+	c := f.Colors[3*i : 3*i+3]
+	return color.FromValue(math.Clamp(c[0], 0, 1), math.Clamp(c[1], 0, 1), math.Clamp(c[2], 0, 1), 1)
+}
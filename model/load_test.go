@@ -6,11 +6,17 @@ package model_test
 
 import (
 	"fmt"
+	"image"
+	"image/png"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"poly.red/color"
+	"poly.red/geometry"
 	"poly.red/geometry/mesh"
+	"poly.red/material"
 	"poly.red/math"
 	"poly.red/model"
 	"poly.red/scene"
@@ -29,6 +35,219 @@ func TestLoadOBJ(t *testing.T) {
 	})
 }
 
+// TestLoadOBJMaps loads an OBJ file whose materials have texture maps
+// and illumination models without highlights and with ray tracing.
+func TestLoadOBJMaps(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"maps.obj": `mtllib maps.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0.5 0.25
+vt 1 0
+vt 1 1
+vn 0 0 1
+o tri
+usemtl brick
+f 1/1/1 2/2/1 3/3/1
+o quad
+usemtl mirror
+f 1//1 2//1 3//1 4//1
+o wire
+l 1 2 3
+`,
+		"maps.mtl": `newmtl brick
+Ks 1 1 1
+illum 1
+map_Kd -s 2 2 1 textures\color.png
+map_Ks color.png
+map_d height.png
+bump -bm 2 height.png
+
+newmtl mirror
+Ks 1 1 1
+illum 5
+`,
+	}
+	if err := os.Mkdir(filepath.Join(dir, "textures"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	height := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range height.Pix {
+		height.Pix[i] = 0x80
+	}
+	for _, name := range []string{"height.png", "color.png", "textures/color.png"} {
+		w, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(w, height); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+	}
+
+	g, err := model.Load(filepath.Join(dir, "maps.obj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var geos []*geometry.Geometry
+	scene.IterObjects(g, func(geo *geometry.Geometry, _ math.Mat4[float32]) bool {
+		geos = append(geos, geo)
+		return true
+	})
+	if len(geos) != 2 {
+		t.Fatalf("got %d geometries, want 2", len(geos))
+	}
+
+	brick := geos[0].Materials()[0].(*material.BlinnPhong)
+	if brick.Specular != color.Black || brick.SpecularMap == nil || brick.AlphaMap == nil || brick.NormalMap == nil {
+		t.Fatalf("brick %+v", brick)
+	}
+	// A flat height map is a normal map of normals (0, 0, 1).
+	if n := brick.NormalMap.Mipmap()[0].Pix[:4]; n[0] != n[1] || n[2] != 0xff {
+		t.Fatalf("normal map texel %v", n)
+	}
+	if uv := geos[0].Triangles()[0].V1.UV; uv != math.NewVec2[float32](1, 0.5) {
+		t.Fatalf("uv %v, want scaled (1, 0.5)", uv)
+	}
+
+	mirror := geos[1].Materials()[0].(*material.BlinnPhong)
+	if mirror.Specular != color.White || mirror.NormalMap != nil {
+		t.Fatalf("mirror %+v", mirror)
+	}
+}
+
+// TestLoadGLTF loads the same asset stored as .gltf, with base64 data
+// URIs, and as .glb, with a binary chunk: a root node with a TRS transform
+// whose child carries a mesh of two primitives, an indexed and textured quad
+// and a triangle strip without normals and with a blended material.
+func TestLoadGLTF(t *testing.T) {
+	for _, path := range []string{"../internal/testdata/quad.gltf", "../internal/testdata/quad.glb"} {
+		g, err := model.Load(path)
+		if err != nil {
+			t.Fatalf("cannot load gltf model, path: %s, err: %v", path, err)
+		}
+
+		var geos []*geometry.Geometry
+		var mats []math.Mat4[float32]
+		scene.IterObjects(g, func(geo *geometry.Geometry, modelMatrix math.Mat4[float32]) bool {
+			geos = append(geos, geo)
+			mats = append(mats, modelMatrix)
+			return true
+		})
+		if len(geos) != 2 {
+			t.Fatalf("%s: got %d geometries, want 2", path, len(geos))
+		}
+
+		// T * R * S maps (1, 0, 0) to (2, 0, 0), then to (0, 0, -2), then to
+		// (1, 2, 1).
+		p := mats[0].MulV(math.NewVec4[float32](1, 0, 0, 1))
+		if want := math.NewVec4[float32](1, 2, 1, 1); p.Sub(want).Len() > 1e-5 {
+			t.Fatalf("%s: transformed vertex %v, want %v", path, p, want)
+		}
+
+		quad, strip := geos[0], geos[1]
+		if n := len(quad.Triangles()); n != 2 {
+			t.Fatalf("%s: quad has %d triangles, want 2", path, n)
+		}
+		pbr, ok := quad.Materials()[0].(*material.PBR)
+		if !ok || pbr.Name() != "textured" || pbr.Texture == nil || pbr.Roughness != 0.5 {
+			t.Fatalf("%s: unexpected quad material %+v", path, quad.Materials()[0])
+		}
+		// The top left texel of the embedded texture is red.
+		if c := pbr.Texture.Query(0, 0, 0); c != color.Red {
+			t.Fatalf("%s: texel %v, want %v", path, c, color.Red)
+		}
+		// glTF's (0, 1) of the bottom left corner is the bottom left of the
+		// texture, the renderer's (0, 0).
+		if uv := quad.Triangles()[0].V1.UV; uv != math.NewVec2[float32](0, 0) {
+			t.Fatalf("%s: uv %v, want (0, 0)", path, uv)
+		}
+
+		tris := strip.Triangles()
+		if len(tris) != 2 {
+			t.Fatalf("%s: strip has %d triangles, want 2", path, len(tris))
+		}
+		for _, tri := range tris {
+			if n := tri.V1.Nor; !n.Eq(math.NewVec4[float32](0, 0, 1, 0)) {
+				t.Fatalf("%s: flat normal %v, want (0, 0, 1)", path, n)
+			}
+		}
+		glass := strip.Materials()[0].(*material.PBR)
+		if glass.Opacity != 0.5 || glass.Emissive != color.Red {
+			t.Fatalf("%s: unexpected strip material %+v", path, glass)
+		}
+	}
+}
+
+// TestLoadPLY checks that the vertex colors of a scan reach the vertices,
+// and that its triangles keep them instead of using a material.
+func TestLoadPLY(t *testing.T) {
+	g, err := model.Load("../internal/testdata/tetra.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var geos []*geometry.Geometry
+	scene.IterObjects(g, func(geo *geometry.Geometry, _ math.Mat4[float32]) bool {
+		geos = append(geos, geo)
+		return true
+	})
+	if len(geos) != 1 || len(geos[0].Materials()) != 0 {
+		t.Fatalf("got %d geometries", len(geos))
+	}
+	tris := geos[0].Triangles()
+	if len(tris) != 4 {
+		t.Fatalf("got %d triangles, want 4", len(tris))
+	}
+	// The first face is 0 2 1: red, blue, green.
+	if tris[0].V1.Col != color.Red || tris[0].V2.Col != color.Blue || tris[0].V3.Col != color.Green {
+		t.Fatalf("colors %v %v %v", tris[0].V1.Col, tris[0].V2.Col, tris[0].V3.Col)
+	}
+	for _, tri := range tris {
+		if tri.MaterialID >= 0 {
+			t.Fatalf("triangle with material %d, want vertex colors", tri.MaterialID)
+		}
+	}
+}
+
+func TestLoadSTL(t *testing.T) {
+	g, err := model.Load("../internal/testdata/tetra.stl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	scene.IterObjects(g, func(geo *geometry.Geometry, _ math.Mat4[float32]) bool {
+		n += len(geo.Triangles())
+		if geo.Materials()[0] != material.Default() {
+			t.Fatalf("unexpected material %v", geo.Materials()[0])
+		}
+		if nor := geo.Triangles()[0].V1.Nor; !nor.Eq(math.NewVec4[float32](0, 0, -1, 0)) {
+			t.Fatalf("normal %v, want (0, 0, -1)", nor)
+		}
+		return true
+	})
+	if n != 4 {
+		t.Fatalf("got %d triangles, want 4", n)
+	}
+
+	// A solid without facets is rejected like a PLY without faces.
+	empty := filepath.Join(t.TempDir(), "empty.stl")
+	if err := os.WriteFile(empty, []byte("solid empty\nendsolid empty\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Load(empty); err == nil {
+		t.Fatal("loaded an STL file without facets")
+	}
+}
+
 func TestLoadSponza(t *testing.T) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -0,0 +1,139 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"poly.red/color"
+)
+
+// Encode writes the objects of the file in the OBJ format. Vertex colors
+// are written after the positions, which many tools read. Faces reference
+// their material by name, and the file references its Matlibs.
+func (f *File) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# polyred\n")
+	if len(f.Matlibs) > 0 {
+		fmt.Fprintf(bw, "mtllib %s\n", strings.Join(f.Matlibs, " "))
+	}
+
+	colored := len(f.Colors) > 0 && len(f.Colors) == len(f.Vertices)
+	for i := 0; i+2 < len(f.Vertices); i += 3 {
+		if colored {
+			fmt.Fprintf(bw, "v %g %g %g %g %g %g\n", f.Vertices[i], f.Vertices[i+1], f.Vertices[i+2], f.Colors[i], f.Colors[i+1], f.Colors[i+2])
+			continue
+		}
+		fmt.Fprintf(bw, "v %g %g %g\n", f.Vertices[i], f.Vertices[i+1], f.Vertices[i+2])
+	}
+	for i := 0; i+1 < len(f.Uvs); i += 2 {
+		fmt.Fprintf(bw, "vt %g %g\n", f.Uvs[i], f.Uvs[i+1])
+	}
+	for i := 0; i+2 < len(f.Normals); i += 3 {
+		fmt.Fprintf(bw, "vn %g %g %g\n", f.Normals[i], f.Normals[i+1], f.Normals[i+2])
+	}
+
+	for _, o := range f.Objs {
+		fmt.Fprintf(bw, "o %s\n", o.Name)
+		mat := ""
+		for _, face := range o.Faces {
+			if face.Material != mat && face.Material != "" {
+				mat = face.Material
+				fmt.Fprintf(bw, "usemtl %s\n", mat)
+			}
+			bw.WriteString("f")
+			for i, v := range face.Vertices {
+				vt, vn := index(face.Uvs, i), index(face.Normals, i)
+				switch {
+				case vt < 0 && vn < 0:
+					fmt.Fprintf(bw, " %d", v+1)
+				case vn < 0:
+					fmt.Fprintf(bw, " %d/%d", v+1, vt+1)
+				case vt < 0:
+					fmt.Fprintf(bw, " %d//%d", v+1, vn+1)
+				default:
+					fmt.Fprintf(bw, " %d/%d/%d", v+1, vt+1, vn+1)
+				}
+			}
+			bw.WriteString("\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// index returns the i-th index of an optional index list, or -1.
+func index(idx []int, i int) int {
+	if i >= len(idx) || idx[i] == invINDEX {
+		return -1
+	}
+	return idx[i]
+}
+
+// EncodeMtl writes the materials of the file, sorted by name, in the MTL
+// format. Texture paths are written as they are, hence relative to the
+// directory of the material library.
+func (f *File) EncodeMtl(w io.Writer) error {
+	names := make([]string, 0, len(f.Materials))
+	for name, m := range f.Materials {
+		if m != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# polyred\n")
+	for _, name := range names {
+		m := f.Materials[name]
+		fmt.Fprintf(bw, "\nnewmtl %s\n", name)
+		fmt.Fprintf(bw, "Ka %s\n", rgb(m.Ambient))
+		fmt.Fprintf(bw, "Kd %s\n", rgb(m.Diffuse))
+		fmt.Fprintf(bw, "Ks %s\n", rgb(m.Specular))
+		fmt.Fprintf(bw, "Ke %s\n", rgb(m.Emissive))
+		fmt.Fprintf(bw, "Ns %g\n", m.Shininess)
+		fmt.Fprintf(bw, "d %g\n", m.Opacity)
+		fmt.Fprintf(bw, "illum %d\n", m.Illum)
+		for _, t := range []struct {
+			key string
+			m   TextureMap
+		}{
+			{"map_Kd", m.MapKd},
+			{"map_Ks", m.MapKs},
+			{"map_Bump", m.MapBump},
+			{"norm", m.MapNorm},
+			{"map_d", m.MapD},
+		} {
+			if t.m.Path != "" {
+				fmt.Fprintf(bw, "%s %s\n", t.key, textureMap(t.m))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// textureMap returns the options and the file of a texture map, where
+// options of default values are omitted.
+func textureMap(m TextureMap) string {
+	var b strings.Builder
+	if m.Offset != [3]float32{} {
+		fmt.Fprintf(&b, "-o %g %g %g ", m.Offset[0], m.Offset[1], m.Offset[2])
+	}
+	if m.Scale != [3]float32{1, 1, 1} && m.Scale != [3]float32{} {
+		fmt.Fprintf(&b, "-s %g %g %g ", m.Scale[0], m.Scale[1], m.Scale[2])
+	}
+	if m.Bump != 1 && m.Bump != 0 {
+		fmt.Fprintf(&b, "-bm %g ", m.Bump)
+	}
+	b.WriteString(m.Path)
+	return b.String()
+}
+
+func rgb(c color.RGBA) string {
+	return fmt.Sprintf("%g %g %g", float32(c.R)/0xff, float32(c.G)/0xff, float32(c.B)/0xff)
+}
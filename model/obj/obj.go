@@ -11,9 +11,12 @@ package obj
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -25,9 +28,10 @@ import (
 // File contains all decoded data from the obj and mtl files
 type File struct {
 	Objs      []Object             // decoded objs
-	Matlib    string               // name of the material lib
+	Matlibs   []string             // names of the material libs
 	Materials map[string]*Material // maps material name to obj
 	Vertices  []float32            // vertices positions array
+	Colors    []float32            // vertices colors, 3 per vertex, or empty
 	Normals   []float32            // vertices normals
 	Uvs       []float32            // vertices texture coordinates
 	Warnings  []string             // warning messages
@@ -38,11 +42,14 @@ type File struct {
 	objCurrent    *Object   // current obj
 	matCurrent    *Material // current material
 	smoothCurrent bool      // current smooth state
+	libDir        string    // directory of the current material lib, relative to the obj
+	defined       map[string]bool
 }
 
 type Object struct {
 	Name      string
 	Faces     []Face
+	Lines     [][]int // Indices to the vertices of each polyline
 	Materials []string
 }
 
@@ -65,7 +72,20 @@ type Material struct {
 	Diffuse    color.RGBA // Diffuse color reflectivity
 	Specular   color.RGBA // Specular color reflectivity
 	Emissive   color.RGBA // Emissive color
-	MapKd      string     // Texture file linked to diffuse color
+	MapKd      TextureMap // Texture map of the diffuse color
+	MapKs      TextureMap // Texture map of the specular color
+	MapBump    TextureMap // Bump map, a height map or a normal map (map_Bump, bump)
+	MapNorm    TextureMap // Tangent-space normal map (norm)
+	MapD       TextureMap // Texture map of the opacity
+}
+
+// TextureMap is a texture file of a material and its options. A map
+// without a path is not set.
+type TextureMap struct {
+	Path   string     // Texture file, relative to MtlDir, with slash separators
+	Offset [3]float32 // Offset of the texture coordinates (-o)
+	Scale  [3]float32 // Scale of the texture coordinates (-s)
+	Bump   float32    // Multiplier of the values of a bump map (-bm)
 }
 
 // Local constants
@@ -98,35 +118,48 @@ func Load(objpath string) (*File, error) {
 		return nil, err
 	}
 
-	if f.Matlib != "" {
-		objdir := filepath.Dir(fobj.Name())
-		mtllibPath := filepath.Join(objdir, f.Matlib)
-		f.MtlDir = objdir
-		mtlf, errMTL := os.Open(mtllibPath)
-		defer mtlf.Close()
-		if errMTL != nil {
-			panic(errMTL)
+	if err := f.checkIndices(); err != nil {
+		return nil, err
+	}
+
+	// Parse the material libraries in order, such that a later library
+	// overrides the materials of an earlier one. Texture paths are made
+	// relative to the directory of the obj file.
+	objdir := filepath.Dir(objpath)
+	f.MtlDir = objdir
+	f.defined = map[string]bool{}
+	parsed := 0
+	for _, lib := range f.Matlibs {
+		err = f.parseMtlFile(objdir, lib)
+		if errors.Is(err, fs.ErrNotExist) {
+			f.appendWarn(mtlType, "cannot open material file "+lib)
+			err = nil
+			continue
 		}
-		if errMTL == nil {
-			err = f.parse(mtlf, f.parseMtlLine) // will set err to nil if successful
+		if err != nil {
+			break
+		}
+		parsed++
+	}
+
+	// If no mtllib could be opened, try <obj_filename>.mtl in the same
+	// directory.
+	if err == nil && parsed == 0 && len(f.Materials) > 0 {
+		lib := strings.TrimSuffix(filepath.Base(objpath), ".obj") + ".mtl"
+		err = f.parseMtlFile(objdir, lib)
+		switch {
+		case err == nil:
+			f.appendWarn(mtlType, fmt.Sprintf("using material file %s", lib))
+		case errors.Is(err, fs.ErrNotExist):
+			err = nil
 		}
 	}
 
-	// If the mtllib line fails try <obj_filename>.mtl in the same directory.
-	// process is basically identical to the above code block.
-	if err != nil {
-		objdir := strings.TrimSuffix(fobj.Name(), ".obj")
-		mtlpath := objdir + ".mtl"
-		f.MtlDir = objdir
-		mtlf, errMTL := os.Open(mtlpath)
-		defer mtlf.Close()
-		if errMTL == nil {
-			err = f.parse(mtlf, f.parseMtlLine) // will set err to nil if successful
-			if err == nil {
-				// log a warning
-				msg := fmt.Sprintf("using material file %s", mtlpath)
-				f.appendWarn(mtlType, msg)
-			}
+	// Materials that are used but defined by no library get the default
+	// material.
+	for name := range f.Materials {
+		if !f.defined[name] {
+			f.Materials[name] = nil
 		}
 	}
 
@@ -144,6 +177,19 @@ func Load(objpath string) (*File, error) {
 	return f, nil
 }
 
+// parseMtlFile parses the material library lib, whose path is relative to
+// the directory of the obj file.
+func (f *File) parseMtlFile(objdir, lib string) error {
+	mtlf, err := os.Open(filepath.Join(objdir, filepath.FromSlash(lib)))
+	if err != nil {
+		return err
+	}
+	defer mtlf.Close()
+	f.libDir = path.Dir(lib)
+	f.matCurrent = nil
+	return f.parse(mtlf, f.parseMtlLine)
+}
+
 func (f *File) parse(reader io.Reader, parseLine func(string) error) error {
 	bufin := bufio.NewReader(reader)
 	f.line = 1
@@ -190,6 +236,8 @@ func (f *File) parseObjLine(line string) error {
 		return f.parseUV(fields[1:])
 	case "f":
 		return f.parseFace(fields[1:])
+	case "l":
+		return f.parseLine(fields[1:])
 	case "usemtl":
 		return f.parseUsemtl(fields[1:])
 	case "s":
@@ -201,11 +249,13 @@ func (f *File) parseObjLine(line string) error {
 }
 
 func (f *File) parseMatlib(fields []string) error {
-	// mtllib <name>
+	// mtllib <name> [<name> ...]
 	if len(fields) < 1 {
 		return f.formatError("Material library (mtllib) with no fields")
 	}
-	f.Matlib = fields[0]
+	for _, name := range fields {
+		f.Matlibs = append(f.Matlibs, strings.ReplaceAll(name, "\\", "/"))
+	}
 	return nil
 }
 
@@ -228,9 +278,25 @@ func (f *File) parseObj(fields []string) error {
 
 func (f *File) parseVertex(fields []string) error {
 	// v <x> <y> <z> [w]
+	// v <x> <y> <z> <r> <g> <b>
 	if len(fields) < 3 {
 		return f.formatError(fmt.Sprintf("vertex has less than 3 vertices in 'v' line: %v", fields))
 	}
+	if len(fields) >= 6 {
+		// Vertices before the first colored one are white.
+		for len(f.Colors) < len(f.Vertices) {
+			f.Colors = append(f.Colors, 1)
+		}
+		for _, fi := range fields[3:6] {
+			val, err := strconv.ParseFloat(fi, 32)
+			if err != nil {
+				return err
+			}
+			f.Colors = append(f.Colors, float32(val))
+		}
+	} else if len(f.Colors) > 0 {
+		f.Colors = append(f.Colors, 1, 1, 1)
+	}
 	w := float32(1)
 	if len(fields) == 4 {
 		v, err := strconv.ParseFloat(fields[3], 32)
@@ -313,73 +379,24 @@ func (f *File) parseFace(fields []string) error {
 	face.Smooth = f.smoothCurrent
 
 	for pos, fi := range fields {
-
-		// Separate the current field in its components: v vt vn
+		// Separate the current field in its components: v vt vn. The
+		// position must always exist, the others are optional.
 		vfields := strings.Split(fi, "/")
-		if len(vfields) < 1 {
-			return f.formatError("face field with no parts")
-		}
-
-		// Get the index of this vertex position (must always exist)
-		val, err := strconv.ParseInt(vfields[0], 10, 32)
+		var err error
+		face.Vertices[pos], err = f.resolve(vfields[0], len(f.Vertices)/3, "vertex")
 		if err != nil {
 			return err
 		}
-
-		// Positive index is an absolute vertex index
-		if val > 0 {
-			face.Vertices[pos] = int(val - 1)
-			// Negative vertex index is relative to the last parsed vertex
-		} else if val < 0 {
-			current := (len(f.Vertices) / 3) - 1
-			face.Vertices[pos] = current + int(val) + 1
-			// Vertex index could never be 0
-		} else {
-			return f.formatError("face vertex index value equal to 0")
-		}
-
-		// Get the index of this vertex UV coordinate (optional)
+		face.Uvs[pos], face.Normals[pos] = invINDEX, invINDEX
 		if len(vfields) > 1 && len(vfields[1]) > 0 {
-			val, err := strconv.ParseInt(vfields[1], 10, 32)
-			if err != nil {
+			if face.Uvs[pos], err = f.resolve(vfields[1], len(f.Uvs)/2, "uv"); err != nil {
 				return err
 			}
-
-			// Positive index is an absolute UV index
-			if val > 0 {
-				face.Uvs[pos] = int(val - 1)
-				// Negative vertex index is relative to the last parsed uv
-			} else if val < 0 {
-				current := (len(f.Uvs) / 2) - 1
-				face.Uvs[pos] = current + int(val) + 1
-				// UV index could never be 0
-			} else {
-				return f.formatError("face uv index value equal to 0")
-			}
-		} else {
-			face.Uvs[pos] = invINDEX
 		}
-
-		// Get the index of this vertex normal (optional)
-		if len(vfields) >= 3 {
-			val, err = strconv.ParseInt(vfields[2], 10, 32)
-			if err != nil {
+		if len(vfields) > 2 && len(vfields[2]) > 0 {
+			if face.Normals[pos], err = f.resolve(vfields[2], len(f.Normals)/3, "normal"); err != nil {
 				return err
 			}
-
-			// Positive index is an absolute normal index
-			if val > 0 {
-				face.Normals[pos] = int(val - 1)
-				// Negative vertex index is relative to the last parsed normal
-			} else if val < 0 {
-				current := (len(f.Normals) / 3) - 1
-				face.Normals[pos] = current + int(val) + 1
-				// Normal index could never be 0
-			} else {
-				return f.formatError("face normal index value equal to 0")
-			}
-		} else {
-			face.Normals[pos] = invINDEX
 		}
 	}
 
@@ -387,6 +404,73 @@ func (f *File) parseFace(fields []string) error {
 	return nil
 }
 
+func (f *File) parseLine(fields []string) error {
+	// l v1[/vt1] v2[/vt2] ...
+	if f.objCurrent == nil {
+		f.parseObj([]string{fmt.Sprintf("unnamed%d", f.line)})
+	}
+	if len(fields) < 2 {
+		return f.formatError("line element with less than 2 vertices")
+	}
+	line := make([]int, len(fields))
+	for i, fi := range fields {
+		v, _, _ := strings.Cut(fi, "/")
+		var err error
+		if line[i], err = f.resolve(v, len(f.Vertices)/3, "vertex"); err != nil {
+			return err
+		}
+	}
+	f.objCurrent.Lines = append(f.objCurrent.Lines, line)
+	return nil
+}
+
+// resolve returns the 0-based index of an index field of a face or a line.
+// Positive indices count from the first of the n elements parsed so far,
+// negative indices count back from the last one.
+func (f *File) resolve(field string, n int, kind string) (int, error) {
+	val, err := strconv.ParseInt(field, 10, 32)
+	if err != nil {
+		return 0, f.formatError(fmt.Sprintf("invalid %s index %q", kind, field))
+	}
+	switch {
+	case val > 0:
+		return int(val - 1), nil
+	case val < 0 && int(-val) <= n:
+		return n + int(val), nil
+	case val < 0:
+		return 0, f.formatError(fmt.Sprintf("relative %s index %d exceeds %d %ss", kind, val, n, kind))
+	}
+	// Index could never be 0
+	return 0, f.formatError(fmt.Sprintf("face %s index value equal to 0", kind))
+}
+
+// checkIndices checks that the faces and lines only index parsed
+// elements. Positive indices may refer to elements that follow them.
+func (f *File) checkIndices() error {
+	nv, nt, nn := len(f.Vertices)/3, len(f.Uvs)/2, len(f.Normals)/3
+	valid := func(idx []int, n int) bool {
+		for _, i := range idx {
+			if i != invINDEX && i >= n {
+				return false
+			}
+		}
+		return true
+	}
+	for _, o := range f.Objs {
+		for _, face := range o.Faces {
+			if !valid(face.Vertices, nv) || !valid(face.Uvs, nt) || !valid(face.Normals, nn) {
+				return fmt.Errorf("face of %s indexes beyond %d vertices, %d uvs or %d normals", o.Name, nv, nt, nn)
+			}
+		}
+		for _, l := range o.Lines {
+			if !valid(l, nv) {
+				return fmt.Errorf("line of %s indexes beyond %d vertices", o.Name, nv)
+			}
+		}
+	}
+	return nil
+}
+
 func (f *File) parseUsemtl(fields []string) error {
 	// usemtl <name>
 	if len(fields) < 1 {
@@ -403,7 +487,7 @@ func (f *File) parseUsemtl(fields []string) error {
 	mat := f.Materials[name]
 	// Creates material descriptor
 	if mat == nil {
-		mat = &Material{Name: name}
+		mat = &Material{Name: name, Opacity: 1}
 		f.Materials[name] = mat
 	}
 	f.objCurrent.Materials = append(f.objCurrent.Materials, name)
@@ -436,9 +520,15 @@ func (f *File) parseMtlLine(line string) error {
 		return nil
 	}
 
+	if ltype != "newmtl" && f.matCurrent == nil {
+		return f.formatError(ltype + " before newmtl")
+	}
+
 	switch ltype {
 	case "newmtl":
 		return f.parseNewmtl(fields[1:])
+	case "Tr":
+		return f.parseTr(fields[1:])
 	case "d":
 		return f.parseDissolve(fields[1:])
 	case "Ka":
@@ -456,7 +546,15 @@ func (f *File) parseMtlLine(line string) error {
 	case "illum":
 		return f.parseIllum(fields[1:])
 	case "map_Kd":
-		return f.parseMapKd(fields[1:])
+		return f.parseTextureMap(&f.matCurrent.MapKd, fields[1:])
+	case "map_Ks":
+		return f.parseTextureMap(&f.matCurrent.MapKs, fields[1:])
+	case "map_Bump", "map_bump", "bump":
+		return f.parseTextureMap(&f.matCurrent.MapBump, fields[1:])
+	case "norm":
+		return f.parseTextureMap(&f.matCurrent.MapNorm, fields[1:])
+	case "map_d":
+		return f.parseTextureMap(&f.matCurrent.MapD, fields[1:])
 	default:
 		f.appendWarn(mtlType, "field not supported: "+ltype)
 	}
@@ -473,13 +571,27 @@ func (f *File) parseNewmtl(fields []string) error {
 	mat := f.Materials[name]
 	// Creates material descriptor
 	if mat == nil {
-		mat = &Material{Name: name}
+		mat = &Material{Name: name, Opacity: 1}
 		f.Materials[name] = mat
 	}
+	f.defined[name] = true
 	f.matCurrent = mat
 	return nil
 }
 
+func (f *File) parseTr(fields []string) error {
+	// Tr <transparency>
+	if len(fields) < 1 {
+		return f.formatError("'Tr' with no fields")
+	}
+	val, err := strconv.ParseFloat(fields[0], 32)
+	if err != nil {
+		return f.formatError("'Tr' parse float error")
+	}
+	f.matCurrent.Opacity = 1 - float32(val)
+	return nil
+}
+
 func (f *File) parseDissolve(fields []string) error {
 	// d <factor>
 	if len(fields) < 1 {
@@ -600,12 +712,72 @@ func (f *File) parseIllum(fields []string) error {
 	return nil
 }
 
-func (f *File) parseMapKd(fields []string) error {
-	// map_Kd [-options] <filename>
+// textureOptions maps the options of texture maps to their number of
+// arguments. The options -o, -s and -t take one to three arguments.
+var textureOptions = map[string]int{
+	"-blendu": 1, "-blendv": 1, "-bm": 1, "-boost": 1, "-cc": 1,
+	"-clamp": 1, "-imfchan": 1, "-mm": 2, "-texres": 1, "-type": 1,
+	"-o": 3, "-s": 3, "-t": 3,
+}
+
+func (f *File) parseTextureMap(m *TextureMap, fields []string) error {
+	// map_Xx [-options] <filename>
+	*m = TextureMap{Scale: [3]float32{1, 1, 1}, Bump: 1}
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "-") {
+		opt := fields[0]
+		fields = fields[1:]
+		n, ok := textureOptions[opt]
+		if !ok {
+			f.appendWarn(mtlType, "texture option not supported: "+opt)
+			continue
+		}
+
+		switch opt {
+		case "-o", "-s", "-t":
+			var v [3]float32
+			k := 0
+			for ; k < n && len(fields) > 1; k++ {
+				x, err := strconv.ParseFloat(fields[0], 32)
+				if err != nil {
+					break
+				}
+				v[k] = float32(x)
+				fields = fields[1:]
+			}
+			if k == 0 {
+				return f.formatError("texture option " + opt + " with no values")
+			}
+			switch opt {
+			case "-o":
+				copy(m.Offset[:], v[:k])
+			case "-s":
+				copy(m.Scale[:], v[:k])
+			}
+		default:
+			if len(fields) <= n {
+				return f.formatError("texture option " + opt + " with missing arguments")
+			}
+			if opt == "-bm" {
+				x, err := strconv.ParseFloat(fields[0], 32)
+				if err != nil {
+					return f.formatError("'-bm' parse float error")
+				}
+				m.Bump = float32(x)
+			}
+			fields = fields[n:]
+		}
+	}
 	if len(fields) < 1 {
-		return f.formatError("No fields")
+		return f.formatError("texture map with no file")
+	}
+
+	// File names may contain spaces, and exporters on Windows write
+	// backslash separators.
+	p := strings.ReplaceAll(strings.Join(fields, " "), "\\", "/")
+	if !path.IsAbs(p) && !filepath.IsAbs(p) {
+		p = path.Join(f.libDir, p)
 	}
-	f.matCurrent.MapKd = fields[0]
+	m.Path = p
 	return nil
 }
 
@@ -6,8 +6,13 @@ package obj_test
 
 import (
 	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	"poly.red/color"
 	"poly.red/model/obj"
 )
 
@@ -24,3 +29,156 @@ func TestParseObj(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestEncode writes a file and its material library, and parses them back.
+func TestEncode(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	f := &obj.File{
+		Matlibs: []string{"quad.mtl"},
+		Materials: map[string]*obj.Material{
+			"red": {Name: "red", Illum: 2, Opacity: 0.5, Shininess: 8, Diffuse: red, MapKd: obj.TextureMap{Path: "red.png"}},
+		},
+		Vertices: []float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0},
+		Colors:   []float32{1, 0, 0, 0, 1, 0, 0, 0, 1, 1, 1, 1},
+		Uvs:      []float32{0, 0, 1, 0, 1, 1},
+		Normals:  []float32{0, 0, 1},
+		Objs: []obj.Object{{Name: "quad", Faces: []obj.Face{
+			{Vertices: []int{0, 1, 2}, Uvs: []int{0, 1, 2}, Normals: []int{0, 0, 0}, Material: "red"},
+			{Vertices: []int{0, 2, 3}, Normals: []int{0, 0, 0}, Material: "red"},
+		}}},
+	}
+
+	dir := t.TempDir()
+	for name, encode := range map[string]func(io.Writer) error{"quad.obj": f.Encode, "quad.mtl": f.EncodeMtl} {
+		w, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := encode(w); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+	}
+
+	got, err := obj.Load(filepath.Join(dir, "quad.obj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Vertices, f.Vertices) || !reflect.DeepEqual(got.Colors, f.Colors) ||
+		!reflect.DeepEqual(got.Uvs, f.Uvs) || !reflect.DeepEqual(got.Normals, f.Normals) {
+		t.Fatalf("vertices %v, colors %v, uvs %v, normals %v", got.Vertices, got.Colors, got.Uvs, got.Normals)
+	}
+	if len(got.Objs) != 1 || got.Objs[0].Name != "quad" || len(got.Objs[0].Faces) != 2 {
+		t.Fatalf("objects %+v", got.Objs)
+	}
+	for i, face := range got.Objs[0].Faces {
+		want := f.Objs[0].Faces[i]
+		if !reflect.DeepEqual(face.Vertices, want.Vertices) || !reflect.DeepEqual(face.Normals, want.Normals) || face.Material != "red" {
+			t.Fatalf("face %d: %+v, want %+v", i, face, want)
+		}
+	}
+	m := got.Materials["red"]
+	if m == nil || m.Diffuse != red || m.Opacity != 0.5 || m.Shininess != 8 || m.Illum != 2 || m.MapKd.Path != "red.png" {
+		t.Fatalf("material %+v", m)
+	}
+}
+
+// TestParseFeatures parses an OBJ file with two material libraries, one
+// of which is in a subdirectory, relative indices, polylines and texture
+// map options.
+func TestParseFeatures(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"scene.obj": `mtllib a.mtl sub/b.mtl missing.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vn 0 0 1
+o quad
+usemtl brick
+f -4/-3/-1 -3/-2/-1 -2/-1/-1
+usemtl glass
+f 1//1 3//1 4//1
+l 1 2 -1
+`,
+		"a.mtl": `newmtl brick
+Kd 1 1 1
+illum 1
+map_Kd -s 2 2 1 -o 0.5 0 textures\brick diffuse.png
+map_Ks spec.png
+bump -bm 0.5 bump.png
+map_d alpha.png
+`,
+		"sub/b.mtl": `newmtl glass
+Tr 0.25
+illum 7
+norm normal.png
+`,
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := obj.Load(filepath.Join(dir, "scene.obj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.mtl", "sub/b.mtl", "missing.mtl"}; !reflect.DeepEqual(f.Matlibs, want) {
+		t.Fatalf("matlibs %q, want %q", f.Matlibs, want)
+	}
+	if len(f.Warnings) == 0 {
+		t.Fatal("missing material library: want a warning")
+	}
+	if len(f.Objs) != 1 || len(f.Objs[0].Faces) != 2 {
+		t.Fatalf("objects %+v", f.Objs)
+	}
+	face := f.Objs[0].Faces[0]
+	if !reflect.DeepEqual(face.Vertices, []int{0, 1, 2}) || !reflect.DeepEqual(face.Uvs, []int{0, 1, 2}) || !reflect.DeepEqual(face.Normals, []int{0, 0, 0}) {
+		t.Fatalf("relative face %+v", face)
+	}
+	if want := [][]int{{0, 1, 3}}; !reflect.DeepEqual(f.Objs[0].Lines, want) {
+		t.Fatalf("lines %v, want %v", f.Objs[0].Lines, want)
+	}
+
+	brick := f.Materials["brick"]
+	if brick == nil {
+		t.Fatal("no material brick")
+	}
+	kd := obj.TextureMap{Path: "textures/brick diffuse.png", Offset: [3]float32{0.5, 0, 0}, Scale: [3]float32{2, 2, 1}, Bump: 1}
+	if brick.MapKd != kd || brick.MapKs.Path != "spec.png" || brick.MapD.Path != "alpha.png" {
+		t.Fatalf("brick maps %+v, %+v, %+v", brick.MapKd, brick.MapKs, brick.MapD)
+	}
+	if brick.MapBump.Path != "bump.png" || brick.MapBump.Bump != 0.5 || brick.Illum != 1 {
+		t.Fatalf("brick bump %+v, illum %d", brick.MapBump, brick.Illum)
+	}
+	glass := f.Materials["glass"]
+	if glass == nil || glass.Opacity != 0.75 || glass.Illum != 7 || glass.MapNorm.Path != "sub/normal.png" {
+		t.Fatalf("glass %+v", glass)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"zero index":     "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 0 1 2\n",
+		"index overflow": "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 4\n",
+		"relative":       "v 0 0 0\nv 1 0 0\nv 0 1 0\nf -1 -2 -4\n",
+	}
+	for name, src := range tests {
+		path := filepath.Join(t.TempDir(), "bad.obj")
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := obj.Load(path); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package model
+
+import (
+	"fmt"
+
+	"poly.red/buffer"
+	"poly.red/geometry"
+	"poly.red/geometry/mesh"
+	"poly.red/material"
+	"poly.red/model/ply"
+	"poly.red/scene"
+	"poly.red/scene/object"
+)
+
+// loadPly loads a .ply file into a group of a single geometry. A scan with
+// vertex colors keeps them, other scans get the default material.
+func loadPly(path string) (*scene.Group, error) {
+	f, err := ply.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("model: cannot load the given file %v: %w", path, err)
+	}
+	if len(f.Indices) == 0 {
+		return nil, fmt.Errorf("model: the given file %v has no faces", path)
+	}
+
+	bm := mesh.NewBufferedMesh()
+	bm.SetAttribute(mesh.AttribPosition, mesh.NewBufferAttrib(3, f.Vertices))
+	if len(f.Normals) > 0 {
+		bm.SetAttribute(mesh.AttribNormal, mesh.NewBufferAttrib(3, f.Normals))
+	}
+	if len(f.Colors) > 0 {
+		bm.SetAttribute(mesh.AttribColor, mesh.NewBufferAttrib(4, f.Colors))
+	}
+	if len(f.Uvs) > 0 {
+		bm.SetAttribute(mesh.AttriTexcoord, mesh.NewBufferAttrib(2, f.Uvs))
+	}
+	bm.SetIndexBuffer(buffer.IndexBuffer(f.Indices))
+	if len(f.Normals) == 0 {
+		bm = flatMesh(bm, f.Indices)
+	}
+
+	if len(f.Colors) > 0 {
+		useVertexColor(bm)
+		return scene.NewGroup(geometry.New(bm)), nil
+	}
+	return scene.NewGroup(geometry.New(bm, material.Default())), nil
+}
+
+// useVertexColor makes the renderer shade the triangles of a mesh with
+// their vertex colors, which a negative MaterialID asks for.
+func useVertexColor(m mesh.Mesh) {
+	for _, t := range m.Triangles() {
+		t.MaterialID = -1
+	}
+}
+
+// savePly writes all geometries as a single binary ply mesh. Materials are
+// dropped; vertex colors are written if some triangles are shaded with them.
+func savePly(path string, o object.Object[float32], opt *saveOption) error {
+	f := &ply.File{Format: ply.BinaryLittle, Comments: []string{"polyred"}}
+	colored := false
+	for _, pg := range flatten(o, opt.bake) {
+		md := newMeshData(pg.geo, pg.model)
+		off := len(f.Vertices) / 3
+		f.Vertices = append(f.Vertices, md.pos...)
+		f.Normals = append(f.Normals, md.nor...)
+		f.Colors = append(f.Colors, md.col...)
+		f.Uvs = append(f.Uvs, md.uv...)
+		for _, p := range md.parts {
+			for _, i := range p.idx {
+				f.Indices = append(f.Indices, off+i)
+			}
+		}
+		colored = colored || md.vertexColored()
+	}
+	if !colored {
+		f.Colors = nil
+	}
+	return writeFile(path, f.Encode)
+}
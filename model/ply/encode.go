@@ -0,0 +1,79 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package ply
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Encode writes the file as a binary little endian ply file, whatever its
+// Format. Normals, colors and texture coordinates are written if the file
+// has them for every vertex; colors are written as 8-bit.
+func (f *File) Encode(w io.Writer) error {
+	n := len(f.Vertices) / 3
+	if len(f.Indices)%3 != 0 {
+		return errors.New("ply: indices are not triangles")
+	}
+	hasNor := len(f.Normals) == 3*n && n > 0
+	hasCol := len(f.Colors) == 4*n && n > 0
+	hasUV := len(f.Uvs) == 2*n && n > 0
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ply\nformat %s 1.0\n", BinaryLittle)
+	for _, c := range f.Comments {
+		fmt.Fprintf(bw, "comment %s\n", c)
+	}
+	fmt.Fprintf(bw, "element vertex %d\n", n)
+	bw.WriteString("property float x\nproperty float y\nproperty float z\n")
+	if hasNor {
+		bw.WriteString("property float nx\nproperty float ny\nproperty float nz\n")
+	}
+	if hasCol {
+		bw.WriteString("property uchar red\nproperty uchar green\nproperty uchar blue\nproperty uchar alpha\n")
+	}
+	if hasUV {
+		bw.WriteString("property float s\nproperty float t\n")
+	}
+	fmt.Fprintf(bw, "element face %d\n", len(f.Indices)/3)
+	bw.WriteString("property list uchar uint vertex_indices\nend_header\n")
+
+	var b [4]byte
+	float := func(v float32) {
+		binary.LittleEndian.PutUint32(b[:], math.Float32bits(v))
+		bw.Write(b[:])
+	}
+	for i := 0; i < n; i++ {
+		float(f.Vertices[3*i])
+		float(f.Vertices[3*i+1])
+		float(f.Vertices[3*i+2])
+		if hasNor {
+			float(f.Normals[3*i])
+			float(f.Normals[3*i+1])
+			float(f.Normals[3*i+2])
+		}
+		if hasCol {
+			for _, c := range f.Colors[4*i : 4*i+4] {
+				bw.WriteByte(uint8(min(max(c, 0), 1)*0xff + 0.5))
+			}
+		}
+		if hasUV {
+			float(f.Uvs[2*i])
+			float(f.Uvs[2*i+1])
+		}
+	}
+	for i := 0; i < len(f.Indices); i += 3 {
+		bw.WriteByte(3)
+		for _, v := range f.Indices[i : i+3] {
+			binary.LittleEndian.PutUint32(b[:], uint32(v))
+			bw.Write(b[:])
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,461 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+// Package ply is used to parse the Polygon File Format (*.ply) in its ASCII
+// and both binary variants. The file is decoded while it is read, so that
+// large scans are never held in memory as text. Vertex positions, normals,
+// colors and texture coordinates, and the vertex indices of faces are
+// decoded; other elements and properties are skipped. Format info:
+// http://paulbourke.net/dataformats/ply/
+package ply
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// File contains all decoded data from a ply file. Faces are triangulated
+// as fans.
+type File struct {
+	Format   string    // ascii, binary_little_endian or binary_big_endian
+	Comments []string  // comment lines of the header
+	Vertices []float32 // vertex positions, 3 per vertex
+	Normals  []float32 // vertex normals, 3 per vertex, or empty
+	Colors   []float32 // vertex colors in [0, 1], 4 per vertex, or empty
+	Uvs      []float32 // vertex texture coordinates, 2 per vertex, or empty
+	Indices  []int     // vertex indices, 3 per triangle
+}
+
+// Formats of a ply file.
+const (
+	ASCII        = "ascii"
+	BinaryLittle = "binary_little_endian"
+	BinaryBig    = "binary_big_endian"
+)
+
+// scalar is the type of a property value.
+type scalar int
+
+const (
+	tInvalid scalar = iota
+	tInt8
+	tUint8
+	tInt16
+	tUint16
+	tInt32
+	tUint32
+	tFloat32
+	tFloat64
+)
+
+var scalars = map[string]scalar{
+	"char": tInt8, "int8": tInt8,
+	"uchar": tUint8, "uint8": tUint8,
+	"short": tInt16, "int16": tInt16,
+	"ushort": tUint16, "uint16": tUint16,
+	"int": tInt32, "int32": tInt32,
+	"uint": tUint32, "uint32": tUint32,
+	"float": tFloat32, "float32": tFloat32,
+	"double": tFloat64, "float64": tFloat64,
+}
+
+func (s scalar) size() int {
+	switch s {
+	case tInt8, tUint8:
+		return 1
+	case tInt16, tUint16:
+		return 2
+	case tInt32, tUint32, tFloat32:
+		return 4
+	case tFloat64:
+		return 8
+	}
+	return 0
+}
+
+// unit returns the value that an integer color of the type has at full
+// intensity.
+func (s scalar) unit() float64 {
+	switch s {
+	case tInt8:
+		return math.MaxInt8
+	case tUint8:
+		return math.MaxUint8
+	case tInt16:
+		return math.MaxInt16
+	case tUint16:
+		return math.MaxUint16
+	case tInt32:
+		return math.MaxInt32
+	case tUint32:
+		return math.MaxUint32
+	}
+	return 1
+}
+
+// Destinations of the vertex properties.
+const (
+	slotNone = iota
+	slotX
+	slotY
+	slotZ
+	slotNX
+	slotNY
+	slotNZ
+	slotR
+	slotG
+	slotB
+	slotA
+	slotU
+	slotV
+	numSlots
+)
+
+var vertexSlots = map[string]int{
+	"x": slotX, "y": slotY, "z": slotZ,
+	"nx": slotNX, "ny": slotNY, "nz": slotNZ,
+	"red": slotR, "green": slotG, "blue": slotB, "alpha": slotA,
+	"diffuse_red": slotR, "diffuse_green": slotG, "diffuse_blue": slotB,
+	"r": slotR, "g": slotG, "b": slotB, "a": slotA,
+	"u": slotU, "v": slotV, "s": slotU, "t": slotV,
+	"texture_u": slotU, "texture_v": slotV,
+}
+
+type property struct {
+	name    string
+	typ     scalar
+	list    bool
+	countTy scalar // type of the item count of a list
+}
+
+type element struct {
+	name  string
+	count int
+	props []property
+}
+
+// maxPrealloc bounds the capacity reserved up front from the element
+// counts of the header, which are not trusted.
+const maxPrealloc = 1 << 20
+
+// Load loads a ply file.
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ply: cannot open file %v: %w", path, err)
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Decode decodes a ply file from the given reader.
+func Decode(r io.Reader) (*File, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	f := &File{}
+	elems, err := f.parseHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var rd valueReader
+	switch f.Format {
+	case ASCII:
+		s := bufio.NewScanner(br)
+		s.Split(bufio.ScanWords)
+		rd = &asciiReader{s: s}
+	case BinaryLittle:
+		rd = &binaryReader{r: br, order: binary.LittleEndian}
+	case BinaryBig:
+		rd = &binaryReader{r: br, order: binary.BigEndian}
+	}
+
+	nverts := -1
+	for _, e := range elems {
+		switch e.name {
+		case "vertex":
+			if err := f.readVertices(rd, e); err != nil {
+				return nil, err
+			}
+			nverts = e.count
+		case "face":
+			if err := f.readFaces(rd, e); err != nil {
+				return nil, err
+			}
+		default:
+			if err := skip(rd, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if nverts < 0 {
+		return nil, errors.New("ply: no vertex element")
+	}
+	for _, i := range f.Indices {
+		if i < 0 || i >= nverts {
+			return nil, fmt.Errorf("ply: vertex index %d out of range", i)
+		}
+	}
+	return f, nil
+}
+
+func (f *File) parseHeader(br *bufio.Reader) ([]*element, error) {
+	line, err := br.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "ply" {
+		return nil, errors.New("ply: missing magic number")
+	}
+
+	var elems []*element
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("ply: unterminated header: %w", err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 || fields[2] != "1.0" {
+				return nil, fmt.Errorf("ply: invalid format line %q", strings.TrimSpace(line))
+			}
+			switch fields[1] {
+			case ASCII, BinaryLittle, BinaryBig:
+				f.Format = fields[1]
+			default:
+				return nil, fmt.Errorf("ply: unsupported format %v", fields[1])
+			}
+		case "comment":
+			f.Comments = append(f.Comments, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "comment")))
+		case "obj_info":
+		case "element":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("ply: invalid element line %q", strings.TrimSpace(line))
+			}
+			n, err := strconv.Atoi(fields[2])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("ply: invalid element count %q", fields[2])
+			}
+			elems = append(elems, &element{name: fields[1], count: n})
+		case "property":
+			if len(elems) == 0 {
+				return nil, errors.New("ply: property outside an element")
+			}
+			p, err := parseProperty(fields)
+			if err != nil {
+				return nil, err
+			}
+			e := elems[len(elems)-1]
+			e.props = append(e.props, p)
+		case "end_header":
+			if f.Format == "" {
+				return nil, errors.New("ply: missing format")
+			}
+			return elems, nil
+		default:
+			return nil, fmt.Errorf("ply: unknown header line %q", strings.TrimSpace(line))
+		}
+	}
+}
+
+func parseProperty(fields []string) (property, error) {
+	if len(fields) == 5 && fields[1] == "list" {
+		p := property{name: fields[4], list: true, countTy: scalars[fields[2]], typ: scalars[fields[3]]}
+		if p.countTy == tInvalid || p.countTy == tFloat32 || p.countTy == tFloat64 || p.typ == tInvalid {
+			return p, fmt.Errorf("ply: invalid list property %v", p.name)
+		}
+		return p, nil
+	}
+	if len(fields) != 3 || scalars[fields[1]] == tInvalid {
+		return property{}, fmt.Errorf("ply: invalid property %q", strings.Join(fields, " "))
+	}
+	return property{name: fields[2], typ: scalars[fields[1]]}, nil
+}
+
+func (f *File) readVertices(rd valueReader, e *element) error {
+	slots := make([]int, len(e.props))
+	var has [numSlots]bool
+	for i, p := range e.props {
+		if p.list {
+			continue
+		}
+		slots[i] = vertexSlots[p.name]
+		has[slots[i]] = true
+	}
+	if !has[slotX] || !has[slotY] || !has[slotZ] {
+		return errors.New("ply: vertex element without x, y and z")
+	}
+	hasNor := has[slotNX] || has[slotNY] || has[slotNZ]
+	hasCol := has[slotR] || has[slotG] || has[slotB]
+	hasUV := has[slotU] || has[slotV]
+
+	n := min(e.count, maxPrealloc)
+	f.Vertices = make([]float32, 0, 3*n)
+	if hasNor {
+		f.Normals = make([]float32, 0, 3*n)
+	}
+	if hasCol {
+		f.Colors = make([]float32, 0, 4*n)
+	}
+	if hasUV {
+		f.Uvs = make([]float32, 0, 2*n)
+	}
+
+	var v [numSlots]float64
+	for i := 0; i < e.count; i++ {
+		v[slotA] = 1
+		for j, p := range e.props {
+			if p.list {
+				if err := skipList(rd, p); err != nil {
+					return err
+				}
+				continue
+			}
+			x, err := rd.read(p.typ)
+			if err != nil {
+				return fmt.Errorf("ply: vertex %d: %w", i, err)
+			}
+			switch s := slots[j]; s {
+			case slotR, slotG, slotB, slotA:
+				v[s] = x / p.typ.unit()
+			default:
+				v[s] = x
+			}
+		}
+		f.Vertices = append(f.Vertices, float32(v[slotX]), float32(v[slotY]), float32(v[slotZ]))
+		if hasNor {
+			f.Normals = append(f.Normals, float32(v[slotNX]), float32(v[slotNY]), float32(v[slotNZ]))
+		}
+		if hasCol {
+			f.Colors = append(f.Colors, float32(v[slotR]), float32(v[slotG]), float32(v[slotB]), float32(v[slotA]))
+		}
+		if hasUV {
+			f.Uvs = append(f.Uvs, float32(v[slotU]), float32(v[slotV]))
+		}
+	}
+	return nil
+}
+
+func (f *File) readFaces(rd valueReader, e *element) error {
+	f.Indices = make([]int, 0, 3*min(e.count, maxPrealloc))
+	var poly []int
+	for i := 0; i < e.count; i++ {
+		for _, p := range e.props {
+			if !p.list || (p.name != "vertex_indices" && p.name != "vertex_index") {
+				if err := skipProperty(rd, p); err != nil {
+					return err
+				}
+				continue
+			}
+			n, err := rd.read(p.countTy)
+			if err != nil {
+				return fmt.Errorf("ply: face %d: %w", i, err)
+			}
+			poly = poly[:0]
+			for k := 0; k < int(n); k++ {
+				x, err := rd.read(p.typ)
+				if err != nil {
+					return fmt.Errorf("ply: face %d: %w", i, err)
+				}
+				poly = append(poly, int(x))
+			}
+			for k := 2; k < len(poly); k++ {
+				f.Indices = append(f.Indices, poly[0], poly[k-1], poly[k])
+			}
+		}
+	}
+	return nil
+}
+
+func skip(rd valueReader, e *element) error {
+	for i := 0; i < e.count; i++ {
+		for _, p := range e.props {
+			if err := skipProperty(rd, p); err != nil {
+				return fmt.Errorf("ply: %v %d: %w", e.name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func skipProperty(rd valueReader, p property) error {
+	if p.list {
+		return skipList(rd, p)
+	}
+	_, err := rd.read(p.typ)
+	return err
+}
+
+func skipList(rd valueReader, p property) error {
+	n, err := rd.read(p.countTy)
+	if err != nil {
+		return err
+	}
+	for k := 0; k < int(n); k++ {
+		if _, err := rd.read(p.typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueReader reads the property values of the body of a ply file.
+type valueReader interface {
+	read(typ scalar) (float64, error)
+}
+
+type asciiReader struct {
+	s *bufio.Scanner
+}
+
+func (r *asciiReader) read(typ scalar) (float64, error) {
+	if !r.s.Scan() {
+		if err := r.s.Err(); err != nil {
+			return 0, err
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	return strconv.ParseFloat(r.s.Text(), 64)
+}
+
+type binaryReader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (r *binaryReader) read(typ scalar) (float64, error) {
+	b := r.buf[:typ.size()]
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	switch typ {
+	case tInt8:
+		return float64(int8(b[0])), nil
+	case tUint8:
+		return float64(b[0]), nil
+	case tInt16:
+		return float64(int16(r.order.Uint16(b))), nil
+	case tUint16:
+		return float64(r.order.Uint16(b)), nil
+	case tInt32:
+		return float64(int32(r.order.Uint32(b))), nil
+	case tUint32:
+		return float64(r.order.Uint32(b)), nil
+	case tFloat32:
+		return float64(math.Float32frombits(r.order.Uint32(b))), nil
+	default:
+		return math.Float64frombits(r.order.Uint64(b)), nil
+	}
+}
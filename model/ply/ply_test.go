@@ -0,0 +1,169 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package ply_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"poly.red/model/ply"
+)
+
+func TestParsePly(t *testing.T) {
+	f, err := ply.Load("../../internal/testdata/tetra.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Vertices) != 12 || len(f.Normals) != 12 || len(f.Colors) != 16 || len(f.Indices) != 12 {
+		t.Fatalf("unexpected sizes %d %d %d %d", len(f.Vertices), len(f.Normals), len(f.Colors), len(f.Indices))
+	}
+	if want := []float32{0, 1, 0, 1}; !reflect.DeepEqual(f.Colors[4:8], want) {
+		t.Fatalf("color %v, want %v", f.Colors[4:8], want)
+	}
+	if want := []string{"a colored tetrahedron"}; !reflect.DeepEqual(f.Comments, want) {
+		t.Fatalf("comments %q, want %q", f.Comments, want)
+	}
+}
+
+// header declares a quad with per-vertex colors and uvs, preceded by an
+// element to skip and followed by a face property to skip.
+const header = `ply
+format %s 1.0
+element camera 1
+property float view_px
+property list uchar float params
+element vertex 4
+property float x
+property float y
+property float z
+property ushort red
+property ushort green
+property ushort blue
+property float s
+property float t
+element face 1
+property list uchar uint vertex_index
+property uchar flags
+end_header
+`
+
+var (
+	quadPos = []float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}
+	quadCol = []uint16{65535, 0, 0, 0, 65535, 0, 0, 0, 65535, 0, 0, 0}
+	quadUV  = []float32{0, 0, 1, 0, 1, 1, 0, 1}
+)
+
+func asciiQuad() string {
+	var b strings.Builder
+	b.WriteString(strings.Replace(header, "%s", ply.ASCII, 1))
+	b.WriteString("1.5 2 7 8\n")
+	for i := 0; i < 4; i++ {
+		p, c, uv := quadPos[3*i:], quadCol[3*i:], quadUV[2*i:]
+		fmt.Fprintf(&b, "%v %v %v %v %v %v %v %v\n", p[0], p[1], p[2], c[0], c[1], c[2], uv[0], uv[1])
+	}
+	b.WriteString("4 0 1 2 3 1\n")
+	return b.String()
+}
+
+// TestDecode decodes the same quad from each format, and checks that its
+// face is triangulated as a fan and that unknown data is skipped.
+func TestDecode(t *testing.T) {
+	bin := func(order binary.ByteOrder, format string) []byte {
+		var b bytes.Buffer
+		b.WriteString(strings.Replace(header, "%s", format, 1))
+		binary.Write(&b, order, float32(1.5))
+		binary.Write(&b, order, uint8(2))
+		binary.Write(&b, order, []float32{7, 8})
+		for i := 0; i < 4; i++ {
+			binary.Write(&b, order, quadPos[3*i:3*i+3])
+			binary.Write(&b, order, quadCol[3*i:3*i+3])
+			binary.Write(&b, order, quadUV[2*i:2*i+2])
+		}
+		binary.Write(&b, order, uint8(4))
+		binary.Write(&b, order, []uint32{0, 1, 2, 3})
+		binary.Write(&b, order, uint8(1))
+		return b.Bytes()
+	}
+
+	inputs := map[string][]byte{
+		ply.ASCII:        []byte(asciiQuad()),
+		ply.BinaryLittle: bin(binary.LittleEndian, ply.BinaryLittle),
+		ply.BinaryBig:    bin(binary.BigEndian, ply.BinaryBig),
+	}
+	for format, b := range inputs {
+		f, err := ply.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if f.Format != format {
+			t.Fatalf("format %q, want %q", f.Format, format)
+		}
+		if !reflect.DeepEqual(f.Vertices, quadPos) || !reflect.DeepEqual(f.Uvs, quadUV) || f.Normals != nil {
+			t.Fatalf("%s: vertices %v, uvs %v, normals %v", format, f.Vertices, f.Uvs, f.Normals)
+		}
+		if want := []float32{1, 0, 0, 1, 0, 1, 0, 1, 0, 0, 1, 1, 0, 0, 0, 1}; !reflect.DeepEqual(f.Colors, want) {
+			t.Fatalf("%s: colors %v, want %v", format, f.Colors, want)
+		}
+		if want := []int{0, 1, 2, 0, 2, 3}; !reflect.DeepEqual(f.Indices, want) {
+			t.Fatalf("%s: indices %v, want %v", format, f.Indices, want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"magic":        "plx\nformat ascii 1.0\nend_header\n",
+		"format":       "ply\nformat binary_middle_endian 1.0\nend_header\n",
+		"no format":    "ply\nelement vertex 0\nend_header\n",
+		"header":       "ply\nformat ascii 1.0\nelement vertex 1\n",
+		"no vertex":    "ply\nformat ascii 1.0\nend_header\n",
+		"no position":  "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n1\n",
+		"truncated":    "ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nproperty float y\nproperty float z\nend_header\n0 0 0\n",
+		"bad index":    "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n0 0 0\n3 0 0 1\n",
+		"bad property": "ply\nformat ascii 1.0\nelement vertex 1\nproperty half x\nend_header\n",
+	}
+	for name, src := range tests {
+		if _, err := ply.Decode(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+// TestEncode encodes a quad with every vertex property and decodes it.
+func TestEncode(t *testing.T) {
+	f := &ply.File{
+		Format:   ply.ASCII,
+		Comments: []string{"a quad"},
+		Vertices: quadPos,
+		Normals:  []float32{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1},
+		Colors:   []float32{1, 0, 0, 1, 0, 1, 0, 1, 0, 0, 1, 1, 0, 0, 0, 0},
+		Uvs:      quadUV,
+		Indices:  []int{0, 1, 2, 0, 2, 3},
+	}
+	var b bytes.Buffer
+	if err := f.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ply.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Format != ply.BinaryLittle {
+		t.Fatalf("format %q, want %q", got.Format, ply.BinaryLittle)
+	}
+	got.Format = f.Format
+	if !reflect.DeepEqual(got, f) {
+		t.Fatalf("decoded %+v, want %+v", got, f)
+	}
+
+	f.Indices = f.Indices[:4]
+	if err := f.Encode(&b); err == nil {
+		t.Fatal("encoding an incomplete triangle: want an error")
+	}
+}
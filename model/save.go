@@ -0,0 +1,357 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package model
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/geometry"
+	"poly.red/geometry/primitive"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model/obj"
+	"poly.red/scene"
+	"poly.red/scene/object"
+)
+
+// SaveOption is an option of Save.
+type SaveOption func(o *saveOption)
+
+type saveOption struct {
+	bake bool
+}
+
+// BakeTransform bakes the transforms of the groups and geometries into the
+// written vertices. Otherwise, the formats without a node hierarchy, OBJ and
+// PLY, write every geometry in its local space, and glTF keeps the
+// transforms on its nodes.
+func BakeTransform(enable bool) SaveOption {
+	return func(o *saveOption) {
+		o.bake = enable
+	}
+}
+
+// Save writes a group or a geometry to a file whose format is chosen by
+// its extension, such that Load reads it back:
+//
+//   - .obj writes the materials to a .mtl file and their textures to .png
+//     files next to it;
+//   - .ply writes a binary ply file of all geometries, without materials;
+//   - .gltf and .glb write the group hierarchy and the PBR materials, with
+//     the textures embedded.
+func Save(path string, o object.Object[float32], opts ...SaveOption) error {
+	opt := &saveOption{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
+	var err error
+	switch v := filepath.Ext(path); v {
+	case ".obj":
+		err = saveObj(path, o, opt)
+	case ".ply":
+		err = savePly(path, o, opt)
+	case ".gltf", ".glb":
+		err = saveGLTF(path, o, opt)
+	default:
+		return fmt.Errorf("model: unsupported format %v", v)
+	}
+	if err != nil {
+		return fmt.Errorf("model: cannot save to the given file %v: %w", path, err)
+	}
+	return nil
+}
+
+// placedGeometry is a geometry with the matrix that takes its vertices to
+// the space they are written in.
+type placedGeometry struct {
+	geo   *geometry.Geometry
+	name  string
+	model math.Mat4[float32]
+}
+
+// flatten returns the geometries of o in traversal order. Their matrix is
+// their world transform if bake is set, and the identity otherwise.
+func flatten(o object.Object[float32], bake bool) []placedGeometry {
+	var out []placedGeometry
+	var walk func(o object.Object[float32], parent math.Mat4[float32], name string)
+	walk = func(o object.Object[float32], parent math.Mat4[float32], name string) {
+		world := parent.MulM(o.ModelMatrix())
+		switch x := o.(type) {
+		case *scene.Group:
+			if x.Name() != "group" {
+				name = x.Name()
+			}
+			for _, c := range x.Objects() {
+				walk(c, world, name)
+			}
+		case *geometry.Geometry:
+			m := math.Mat4I[float32]()
+			if bake {
+				m = world
+			}
+			out = append(out, placedGeometry{x, name, m})
+		}
+	}
+	walk(o, math.Mat4I[float32](), "object")
+	return out
+}
+
+// meshData is a geometry as indexed triangles. Its vertices are the
+// distinct corners of its triangles, and its triangles are grouped by
+// their material.
+type meshData struct {
+	pos, nor, uv []float32 // 3, 3 and 2 per vertex
+	col, tan     []float32 // 4 and 4 per vertex
+	parts        []meshPart
+
+	// tangents reports whether every vertex has a tangent.
+	tangents bool
+}
+
+// meshPart are the triangles of a mesh with the same material. mat is the
+// material, or nil for triangles shaded with their vertex colors.
+type meshPart struct {
+	mat material.Material
+	idx []int
+}
+
+// newMeshData indexes the triangles of a geometry transformed by m.
+func newMeshData(g *geometry.Geometry, m math.Mat4[float32]) *meshData {
+	type corner struct {
+		pos, nor [3]float32
+		uv       [2]float32
+		col      [4]uint8
+		tan      [4]float32
+	}
+
+	identity := m == math.Mat4I[float32]()
+	nm := m.Inv().T()
+	mats := g.Materials()
+
+	md := &meshData{tangents: true}
+	seen := map[corner]int{}
+	parts := map[material.Material]int{}
+	for _, t := range g.Triangles() {
+		var mat material.Material
+		if t.MaterialID >= 0 && t.MaterialID < int64(len(mats)) {
+			mat = mats[t.MaterialID]
+		}
+		pi, ok := parts[mat]
+		if !ok {
+			pi = len(md.parts)
+			parts[mat] = pi
+			md.parts = append(md.parts, meshPart{mat: mat})
+		}
+
+		for _, v := range []*primitive.Vertex{t.V1, t.V2, t.V3} {
+			p, n, tan := v.Pos, v.Nor, v.Tan
+			if !identity {
+				p = m.MulV(p)
+				if n = nm.MulV(n.Vec()).Vec(); !n.IsZero() {
+					n = n.Unit()
+				}
+				w := tan.W
+				if tan = m.MulV(tan.Vec()); !tan.IsZero() {
+					tan = tan.Unit()
+				}
+				tan.W = w
+			}
+			c := corner{
+				pos: [3]float32{p.X, p.Y, p.Z},
+				nor: [3]float32{n.X, n.Y, n.Z},
+				uv:  [2]float32{v.UV.X, v.UV.Y},
+				col: [4]uint8{v.Col.R, v.Col.G, v.Col.B, v.Col.A},
+				tan: [4]float32{tan.X, tan.Y, tan.Z, tan.W},
+			}
+			i, ok := seen[c]
+			if !ok {
+				i = len(md.pos) / 3
+				seen[c] = i
+				md.pos = append(md.pos, c.pos[:]...)
+				md.nor = append(md.nor, c.nor[:]...)
+				md.uv = append(md.uv, c.uv[:]...)
+				md.col = append(md.col, float32(c.col[0])/0xff, float32(c.col[1])/0xff, float32(c.col[2])/0xff, float32(c.col[3])/0xff)
+				md.tan = append(md.tan, c.tan[:]...)
+				md.tangents = md.tangents && !v.Tan.IsZero()
+			}
+			md.parts[pi].idx = append(md.parts[pi].idx, i)
+		}
+	}
+	return md
+}
+
+// vertexColored reports whether some triangles of the mesh are shaded
+// with their vertex colors.
+func (md *meshData) vertexColored() bool {
+	for _, p := range md.parts {
+		if p.mat == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// saveObj writes the geometries as objects of an OBJ file. Triangles
+// without a material come first, as a usemtl holds until the next one.
+func saveObj(path string, o object.Object[float32], opt *saveOption) error {
+	dir := filepath.Dir(path)
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	f := &obj.File{Materials: map[string]*obj.Material{}}
+	names := map[material.Material]string{}
+	textures := map[*buffer.Texture]string{}
+	var colored, shaded []obj.Object
+	for _, pg := range flatten(o, opt.bake) {
+		md := newMeshData(pg.geo, pg.model)
+		off := len(f.Vertices) / 3
+		f.Vertices = append(f.Vertices, md.pos...)
+		f.Normals = append(f.Normals, md.nor...)
+		f.Uvs = append(f.Uvs, md.uv...)
+		for i := 0; i < len(md.col); i += 4 {
+			f.Colors = append(f.Colors, md.col[i:i+3]...)
+		}
+
+		var c, s obj.Object
+		c.Name, s.Name = objName(pg.name), objName(pg.name)
+		for _, p := range md.parts {
+			name := ""
+			if p.mat != nil {
+				var err error
+				if name, err = objMaterial(f, p.mat, names, textures, dir, base); err != nil {
+					return err
+				}
+			}
+			for i := 0; i < len(p.idx); i += 3 {
+				vs := []int{off + p.idx[i], off + p.idx[i+1], off + p.idx[i+2]}
+				face := obj.Face{Vertices: vs, Uvs: vs, Normals: vs, Material: name}
+				if name == "" {
+					c.Faces = append(c.Faces, face)
+				} else {
+					s.Faces = append(s.Faces, face)
+				}
+			}
+		}
+		if len(c.Faces) > 0 {
+			colored = append(colored, c)
+		}
+		if len(s.Faces) > 0 {
+			shaded = append(shaded, s)
+		}
+	}
+	f.Objs = append(colored, shaded...)
+	if len(colored) == 0 {
+		f.Colors = nil
+	}
+
+	if len(f.Materials) > 0 {
+		f.Matlibs = []string{base + ".mtl"}
+		if err := writeFile(filepath.Join(dir, f.Matlibs[0]), f.EncodeMtl); err != nil {
+			return err
+		}
+	}
+	return writeFile(path, f.Encode)
+}
+
+// objName returns a name without whitespace, as OBJ and MTL names end at
+// the first space.
+func objName(name string) string {
+	name = strings.Join(strings.Fields(name), "_")
+	if name == "" {
+		return "object"
+	}
+	return name
+}
+
+// objMaterial adds a material to the file, once, and returns its name.
+// A PBR material is approximated by a Blinn-Phong one, whose shininess
+// matches its roughness. Textures are written as .png files named after
+// the OBJ file.
+func objMaterial(f *obj.File, m material.Material, names map[material.Material]string, textures map[*buffer.Texture]string, dir, base string) (string, error) {
+	if name, ok := names[m]; ok {
+		return name, nil
+	}
+	name := objName(m.Name())
+	for i := 2; f.Materials[name] != nil; i++ {
+		name = fmt.Sprintf("%s_%d", objName(m.Name()), i)
+	}
+	names[m] = name
+
+	om := &obj.Material{Name: name, Illum: 2, Opacity: 1, Diffuse: color.White}
+	switch x := m.(type) {
+	case *material.BlinnPhong:
+		om.Ambient, om.Diffuse, om.Specular, om.Emissive = x.Ambient, x.Diffuse, x.Specular, x.Emissive
+		om.Shininess = x.Shininess
+	case *material.PBR:
+		om.Diffuse, om.Emissive = x.BaseColor, x.Emissive
+		om.Shininess = shininess(x.Roughness)
+	}
+	if std := material.StandardOf(m); std != nil {
+		om.Opacity = std.Opacity
+		if std.Texture != nil {
+			p, ok := textures[std.Texture]
+			if !ok {
+				p = fmt.Sprintf("%s_%d.png", base, len(textures))
+				img := encodeTexture(std.Texture, true)
+				if err := writeFile(filepath.Join(dir, p), func(w io.Writer) error { return png.Encode(w, img) }); err != nil {
+					return "", err
+				}
+				textures[std.Texture] = p
+			}
+			om.MapKd = obj.TextureMap{Path: p}
+		}
+	}
+	f.Materials[name] = om
+	return name, nil
+}
+
+// shininess returns the Blinn-Phong exponent of a roughness, and
+// roughness its inverse.
+func shininess(roughness float32) float32 {
+	r := math.Max(roughness, 0.01)
+	return 2/(r*r) - 2
+}
+
+func roughness(shininess float32) float32 {
+	return math.Clamp(math.Sqrt(2/(math.Max(shininess, 0)+2)), 0, 1)
+}
+
+// encodeTexture returns the image of a texture. The colors of a color
+// texture are converted back to sRGB, as the loaders convert them to
+// linear.
+func encodeTexture(t *buffer.Texture, srgb bool) *image.RGBA {
+	src := t.Mipmap()[0]
+	img := image.NewRGBA(src.Bounds())
+	copy(img.Pix, src.Pix)
+	if srgb {
+		for i := 0; i < len(img.Pix); i += 4 {
+			for j := 0; j < 3; j++ {
+				img.Pix[i+j] = uint8(color.FromLinear2sRGB(float32(img.Pix[i+j])/0xff)*0xff + 0.5)
+			}
+		}
+	}
+	return img
+}
+
+// writeFile creates a file and writes it with the given encoder.
+func writeFile(path string, encode func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,283 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package model_test
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/geometry"
+	"poly.red/geometry/mesh"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/model/gltf"
+	"poly.red/scene"
+	"poly.red/scene/object"
+)
+
+// saveScene returns a translated group that holds a vertex colored
+// triangle and a rotated and scaled group, which holds a textured quad of
+// a PBR material.
+func saveScene() (*scene.Group, *image.RGBA) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	copy(img.Pix, []uint8{
+		0xff, 0, 0, 0xff, 0, 0x40, 0, 0xff,
+		0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	})
+	mat := material.NewPBR(
+		material.Name("paint"),
+		material.BaseColor(color.FromValue(0.2, 0.4, 0.6, 1)),
+		material.Metallic(0.25),
+		material.Roughness(0.5),
+		material.Texture(buffer.NewTexture(buffer.TextureImage(img))),
+	)
+
+	quad := mesh.NewBufferedMesh()
+	quad.SetAttribute(mesh.AttribPosition, mesh.NewBufferAttrib(3, []float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}))
+	quad.SetAttribute(mesh.AttribNormal, mesh.NewBufferAttrib(3, []float32{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1}))
+	quad.SetAttribute(mesh.AttriTexcoord, mesh.NewBufferAttrib(2, []float32{0, 1, 1, 1, 1, 0, 0, 0}))
+	quad.SetAttribute(mesh.AttribColor, mesh.NewBufferAttrib(4, []float32{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}))
+	quad.SetIndexBuffer(buffer.IndexBuffer{0, 1, 2, 0, 2, 3})
+
+	tri := mesh.NewBufferedMesh()
+	tri.SetAttribute(mesh.AttribPosition, mesh.NewBufferAttrib(3, []float32{0, 0, 0, 0, 0, -1, 1, 0, 0}))
+	tri.SetAttribute(mesh.AttribNormal, mesh.NewBufferAttrib(3, []float32{0, 1, 0, 0, 1, 0, 0, 1, 0}))
+	tri.SetAttribute(mesh.AttribColor, mesh.NewBufferAttrib(4, []float32{1, 0, 0, 1, 0, 1, 0, 1, 0, 0, 1, 1}))
+	tri.SetIndexBuffer(buffer.IndexBuffer{0, 1, 2})
+	for _, t := range tri.Triangles() {
+		t.MaterialID = -1
+	}
+
+	child := scene.NewGroup(geometry.New(quad, mat))
+	child.SetName("child")
+	child.Scale(2, 1, 1)
+	child.RotateY(math.Pi / 2)
+	root := scene.NewGroup(geometry.New(tri), child)
+	root.Translate(1, 2, 3)
+	return root, img
+}
+
+// triangles returns the triangles of a group as sorted strings of their
+// corners, in world space or in the local space of their geometries.
+func triangles(g *scene.Group, world bool) []string {
+	num := func(vs ...float32) string {
+		s := make([]string, len(vs))
+		for i, v := range vs {
+			if math.Abs(v) < 5e-4 {
+				v = 0
+			}
+			s[i] = fmt.Sprintf("%.3f", v)
+		}
+		return strings.Join(s, " ")
+	}
+
+	var out []string
+	scene.IterObjects(g, func(geo *geometry.Geometry, m math.Mat4[float32]) bool {
+		if !world {
+			m = math.Mat4I[float32]()
+		}
+		nm := m.Inv().T()
+		for _, t := range geo.Triangles() {
+			var b strings.Builder
+			for _, v := range []math.Vec4[float32]{t.V1.Pos, t.V2.Pos, t.V3.Pos} {
+				p := m.MulV(v)
+				b.WriteString(num(p.X, p.Y, p.Z) + "|")
+			}
+			for _, v := range []math.Vec4[float32]{t.V1.Nor, t.V2.Nor, t.V3.Nor} {
+				n := nm.MulV(v.Vec()).Vec().Unit()
+				b.WriteString(num(n.X, n.Y, n.Z) + "|")
+			}
+			// Meshes without vertex colors read as white or as zero.
+			for _, c := range []color.RGBA{t.V1.Col, t.V2.Col, t.V3.Col} {
+				if c == color.White || c == (color.RGBA{}) {
+					b.WriteString("-|")
+					continue
+				}
+				fmt.Fprintf(&b, "%v|", c)
+			}
+			b.WriteString(num(t.V1.UV.X, t.V1.UV.Y, t.V2.UV.X, t.V2.UV.Y, t.V3.UV.X, t.V3.UV.Y))
+			out = append(out, b.String())
+		}
+		return true
+	})
+	sort.Strings(out)
+	return out
+}
+
+// transformed reports whether some object of a group has a transform.
+func transformed(o object.Object[float32]) bool {
+	if o.ModelMatrix() != math.Mat4I[float32]() {
+		return true
+	}
+	if g, ok := o.(*scene.Group); ok {
+		for _, c := range g.Objects() {
+			if transformed(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// texels reports whether the first mipmap level of a texture is the given
+// image, up to the rounding of the sRGB conversions.
+func texels(tex *buffer.Texture, img *image.RGBA) bool {
+	got := tex.Mipmap()[0]
+	if got.Bounds() != img.Bounds() {
+		return false
+	}
+	for i := range img.Pix {
+		if d := int(got.Pix[i]) - int(img.Pix[i]); d < -1 || d > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestSave writes a scene to every format, with and without baking its
+// transforms, and checks that Load reads the same triangles back. OBJ and
+// PLY store no transforms, hence they keep the local triangles unless the
+// transforms are baked.
+func TestSave(t *testing.T) {
+	g, img := saveScene()
+	for _, ext := range []string{".obj", ".ply", ".gltf", ".glb"} {
+		for _, bake := range []bool{false, true} {
+			path := filepath.Join(t.TempDir(), "scene"+ext)
+			if err := model.Save(path, g, model.BakeTransform(bake)); err != nil {
+				t.Fatalf("%s, bake %v: %v", ext, bake, err)
+			}
+			got, err := model.Load(path)
+			if err != nil {
+				t.Fatalf("%s, bake %v: %v", ext, bake, err)
+			}
+
+			world := bake || ext == ".gltf" || ext == ".glb"
+			want := triangles(g, world)
+			if have := triangles(got, true); strings.Join(have, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("%s, bake %v: triangles\n%s\nwant\n%s", ext, bake, strings.Join(have, "\n"), strings.Join(want, "\n"))
+			}
+
+			// glTF keeps the transforms on its nodes unless they are baked.
+			if ext == ".gltf" || ext == ".glb" {
+				if transformed(got) == bake {
+					t.Fatalf("%s, bake %v: transformed nodes %v", ext, bake, !bake)
+				}
+			}
+
+			var mats []material.Material
+			colored := 0
+			scene.IterObjects(got, func(geo *geometry.Geometry, m math.Mat4[float32]) bool {
+				mats = append(mats, geo.Materials()...)
+				for _, t := range geo.Triangles() {
+					if t.MaterialID < 0 {
+						colored++
+					}
+				}
+				return true
+			})
+
+			switch ext {
+			case ".obj":
+				var paint *material.BlinnPhong
+				for _, m := range mats {
+					if m.Name() == "paint" {
+						paint = m.(*material.BlinnPhong)
+					}
+				}
+				if paint == nil {
+					t.Fatalf("%s: no material paint in %v", ext, mats)
+				}
+				if paint.Diffuse != color.FromValue(0.2, 0.4, 0.6, 1) || !texels(paint.Texture, img) {
+					t.Fatalf("%s: diffuse %v, texture %v", ext, paint.Diffuse, paint.Texture.Mipmap()[0].Pix)
+				}
+			case ".ply":
+				if len(mats) != 0 || colored != 3 {
+					t.Fatalf("%s: %d materials, %d vertex colored triangles", ext, len(mats), colored)
+				}
+			default:
+				if len(mats) != 1 || colored != 1 {
+					t.Fatalf("%s: %d materials, %d vertex colored triangles", ext, len(mats), colored)
+				}
+				m := mats[0].(*material.PBR)
+				if m.Name() != "paint" || m.BaseColor != color.FromValue(0.2, 0.4, 0.6, 1) || m.Metallic != 0.25 || m.Roughness != 0.5 {
+					t.Fatalf("%s: material %+v", ext, m)
+				}
+				if !texels(m.Texture, img) {
+					t.Fatalf("%s: texture %v, want %v", ext, m.Texture.Mipmap()[0].Pix, img.Pix)
+				}
+			}
+		}
+	}
+}
+
+// TestSaveGLTFOrientation saves the quad of a glTF file and checks that
+// every corner keeps the texture coordinates of the original file, in the
+// top left origin of glTF.
+func TestSaveGLTFOrientation(t *testing.T) {
+	const src = "../internal/testdata/quad.gltf"
+	g, err := model.Load(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "quad.gltf")
+	if err := model.Save(path, g); err != nil {
+		t.Fatal(err)
+	}
+
+	// uvs returns the texture coordinates of the corners of the textured
+	// quad of the given file by their positions.
+	uvs := func(path string) map[[3]float32][2]float32 {
+		f, err := gltf.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[[3]float32][2]float32{}
+		for _, m := range f.Meshes {
+			for _, p := range m.Primitives {
+				if p.Material == nil || f.Materials[*p.Material].Name != "textured" {
+					continue
+				}
+				pos, _, err := f.Floats(p.Attributes["POSITION"])
+				if err != nil {
+					t.Fatal(err)
+				}
+				uv, _, err := f.Floats(p.Attributes["TEXCOORD_0"])
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < len(pos)/3; i++ {
+					out[[3]float32(pos[i*3:])] = [2]float32(uv[i*2:])
+				}
+			}
+		}
+		return out
+	}
+	want, got := uvs(src), uvs(path)
+	if len(want) != 4 || len(got) != len(want) {
+		t.Fatalf("got %d corners, want %d", len(got), len(want))
+	}
+	for p, uv := range want {
+		if got[p] != uv {
+			t.Fatalf("corner %v: uv %v, want %v", p, got[p], uv)
+		}
+	}
+}
+
+func TestSaveErrors(t *testing.T) {
+	g, _ := saveScene()
+	if err := model.Save(filepath.Join(t.TempDir(), "scene.stl"), g); err == nil {
+		t.Fatal("saving to stl: want an error")
+	}
+	if err := model.Save(filepath.Join(t.TempDir(), "missing", "scene.obj"), g); err == nil {
+		t.Fatal("saving to a missing directory: want an error")
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package model
+
+import (
+	"fmt"
+
+	"poly.red/buffer"
+	"poly.red/geometry"
+	"poly.red/geometry/mesh"
+	"poly.red/material"
+	"poly.red/model/stl"
+	"poly.red/scene"
+)
+
+// loadStl loads a .stl file into a group of a single geometry with the
+// default material. The facets keep their normals, hence are flat shaded.
+func loadStl(path string) (*scene.Group, error) {
+	f, err := stl.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("model: cannot load the given file %v: %w", path, err)
+	}
+	if len(f.Vertices) == 0 {
+		return nil, fmt.Errorf("model: the given file %v has no faces", path)
+	}
+
+	bm := mesh.NewBufferedMesh()
+	bm.SetAttribute(mesh.AttribPosition, mesh.NewBufferAttrib(3, f.Vertices))
+	bm.SetAttribute(mesh.AttribNormal, mesh.NewBufferAttrib(3, f.Normals))
+	ibo := make(buffer.IndexBuffer, len(f.Vertices)/3)
+	for i := range ibo {
+		ibo[i] = i
+	}
+	bm.SetIndexBuffer(ibo)
+	return scene.NewGroup(geometry.New(bm, material.Default())), nil
+}
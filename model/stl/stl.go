@@ -0,0 +1,185 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+// Package stl is used to parse the STL file format (*.stl) in its ASCII and
+// binary variant. The file is decoded while it is read, so that large
+// models are never held in memory as text. The attribute bytes of binary
+// facets are ignored. Format info: https://www.fabbers.com/tech/STL_Format
+package stl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// File contains all decoded data from a stl file. Facets share no vertices:
+// triangle i consists of the vertices 3i, 3i+1 and 3i+2.
+type File struct {
+	Name     string    // name of the solid, or the header of a binary file
+	Binary   bool      // whether the file is binary
+	Vertices []float32 // vertex positions, 3 per vertex
+	Normals  []float32 // facet normals repeated for each vertex, 3 per vertex
+}
+
+// maxPrealloc bounds the capacity reserved up front from the triangle count
+// of a binary file, which is not trusted.
+const maxPrealloc = 1 << 20
+
+// Load loads a stl file.
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("stl: cannot open file %v: %w", path, err)
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Decode decodes a stl file from the given reader. Binary files may begin
+// with "solid" as ASCII files do, hence a file is only taken as ASCII if a
+// facet or the end of the solid follows its first line.
+func Decode(r io.Reader) (*File, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	if isASCII(br) {
+		return decodeASCII(br)
+	}
+	return decodeBinary(br)
+}
+
+func isASCII(br *bufio.Reader) bool {
+	b, _ := br.Peek(512)
+	if !bytes.HasPrefix(b, []byte("solid")) {
+		return false
+	}
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return false
+	}
+	next := bytes.Fields(b[i+1:])
+	return len(next) > 0 && (string(next[0]) == "facet" || string(next[0]) == "endsolid")
+}
+
+func decodeBinary(br *bufio.Reader) (*File, error) {
+	var hdr [84]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, fmt.Errorf("stl: truncated header: %w", err)
+	}
+	n := int(binary.LittleEndian.Uint32(hdr[80:]))
+	f := &File{
+		Name:     string(bytes.TrimRight(hdr[:80], "\x00 ")),
+		Binary:   true,
+		Vertices: make([]float32, 0, 9*min(n, maxPrealloc)),
+		Normals:  make([]float32, 0, 9*min(n, maxPrealloc)),
+	}
+
+	var facet [50]byte
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(br, facet[:]); err != nil {
+			return nil, fmt.Errorf("stl: facet %d of %d: %w", i, n, io.ErrUnexpectedEOF)
+		}
+		var v [12]float32
+		for j := range v {
+			v[j] = math.Float32frombits(binary.LittleEndian.Uint32(facet[4*j:]))
+		}
+		f.addFacet(v)
+	}
+	return f, nil
+}
+
+func decodeASCII(br *bufio.Reader) (*File, error) {
+	f := &File{}
+	s := bufio.NewScanner(br)
+	s.Split(bufio.ScanLines)
+
+	// The name is the rest of the first line.
+	s.Scan()
+	f.Name = string(bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(s.Bytes()), []byte("solid"))))
+
+	var (
+		v     [12]float32
+		nvert int
+		line  = 1
+		ended bool
+	)
+	for s.Scan() {
+		line++
+		fields := bytes.Fields(s.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		switch string(fields[0]) {
+		case "facet":
+			if len(fields) != 5 || string(fields[1]) != "normal" {
+				return nil, fmt.Errorf("stl: line %d: invalid facet", line)
+			}
+			if err := parseFloats(v[:3], fields[2:]); err != nil {
+				return nil, fmt.Errorf("stl: line %d: %w", line, err)
+			}
+			nvert = 0
+		case "vertex":
+			if len(fields) != 4 || nvert >= 3 {
+				return nil, fmt.Errorf("stl: line %d: invalid vertex", line)
+			}
+			if err := parseFloats(v[3+3*nvert:6+3*nvert], fields[1:]); err != nil {
+				return nil, fmt.Errorf("stl: line %d: %w", line, err)
+			}
+			nvert++
+		case "endfacet":
+			if nvert != 3 {
+				return nil, fmt.Errorf("stl: line %d: facet with %d vertices", line, nvert)
+			}
+			f.addFacet(v)
+		case "outer", "endloop":
+		case "endsolid":
+			ended = true
+		case "solid":
+			// Some exporters write several solids to a file.
+			ended = false
+		default:
+			return nil, fmt.Errorf("stl: line %d: unexpected %q", line, fields[0])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("stl: %w", err)
+	}
+	if !ended {
+		return nil, errors.New("stl: missing endsolid")
+	}
+	return f, nil
+}
+
+func parseFloats(dst []float32, fields [][]byte) error {
+	for i := range dst {
+		x, err := strconv.ParseFloat(string(fields[i]), 32)
+		if err != nil {
+			return err
+		}
+		dst[i] = float32(x)
+	}
+	return nil
+}
+
+// addFacet appends a facet given as its normal and its three vertices. A
+// zero normal, which exporters may write, is computed from the vertices by
+// the right-hand rule.
+func (f *File) addFacet(v [12]float32) {
+	nx, ny, nz := v[0], v[1], v[2]
+	if nx == 0 && ny == 0 && nz == 0 {
+		ax, ay, az := v[6]-v[3], v[7]-v[4], v[8]-v[5]
+		bx, by, bz := v[9]-v[3], v[10]-v[4], v[11]-v[5]
+		nx, ny, nz = ay*bz-az*by, az*bx-ax*bz, ax*by-ay*bx
+		if l := float32(math.Sqrt(float64(nx*nx + ny*ny + nz*nz))); l > 0 {
+			nx, ny, nz = nx/l, ny/l, nz/l
+		}
+	}
+	f.Vertices = append(f.Vertices, v[3:12]...)
+	f.Normals = append(f.Normals, nx, ny, nz, nx, ny, nz, nx, ny, nz)
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package stl_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"poly.red/model/stl"
+)
+
+// TestParseStl parses a binary file whose header begins with "solid", and
+// whose last facet has a zero normal.
+func TestParseStl(t *testing.T) {
+	f, err := stl.Load("../../internal/testdata/tetra.stl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Binary || f.Name != "solid tetrahedron, binary" {
+		t.Fatalf("binary %v, name %q", f.Binary, f.Name)
+	}
+	if len(f.Vertices) != 36 || len(f.Normals) != 36 {
+		t.Fatalf("%d vertices, %d normals", len(f.Vertices)/3, len(f.Normals)/3)
+	}
+	if want := []float32{0, 0, 0, 0, 1, 0, 1, 0, 0}; !reflect.DeepEqual(f.Vertices[:9], want) {
+		t.Fatalf("first facet %v, want %v", f.Vertices[:9], want)
+	}
+	n := f.Normals[27:30]
+	if d := n[0]*n[0] + n[1]*n[1] + n[2]*n[2] - 1; d > 1e-6 || d < -1e-6 || n[0] <= 0 || n[0] != n[1] || n[1] != n[2] {
+		t.Fatalf("computed normal %v, want a unit (1, 1, 1)", n)
+	}
+}
+
+const ascii = `solid cube corner
+  facet normal 0 0 -1
+    outer loop
+      vertex 0 0 0
+      vertex 0 1 0
+      vertex 1 0 0
+    endloop
+  endfacet
+endsolid cube corner
+solid second
+  facet normal 0 -1 0
+    outer loop
+      vertex 0 0 0
+      vertex 1 0 0
+      vertex 0 0 1e0
+    endloop
+  endfacet
+endsolid second
+`
+
+func TestDecodeASCII(t *testing.T) {
+	f, err := stl.Decode(strings.NewReader(ascii))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Binary || f.Name != "cube corner" {
+		t.Fatalf("binary %v, name %q", f.Binary, f.Name)
+	}
+	if want := []float32{0, 0, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}; !reflect.DeepEqual(f.Vertices, want) {
+		t.Fatalf("vertices %v, want %v", f.Vertices, want)
+	}
+	if want := []float32{0, 0, -1, 0, 0, -1, 0, 0, -1, 0, -1, 0, 0, -1, 0, 0, -1, 0}; !reflect.DeepEqual(f.Normals, want) {
+		t.Fatalf("normals %v, want %v", f.Normals, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"no endsolid":  strings.TrimSuffix(ascii, "endsolid second\n"),
+		"two vertices": strings.Replace(ascii, "      vertex 1 0 0\n    endloop", "    endloop", 1),
+		"bad number":   strings.Replace(ascii, "vertex 0 1 0", "vertex 0 one 0", 1),
+		"truncated":    "\x00\x01",
+		"short":        strings.Repeat("\x00", 80) + "\x02\x00\x00\x00" + strings.Repeat("\x00", 50),
+	}
+	for name, src := range tests {
+		if _, err := stl.Decode(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
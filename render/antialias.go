@@ -0,0 +1,233 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"unsafe"
+
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/gpu"
+	"poly.red/gpu/shader/gpumath/kernels"
+	"poly.red/internal/profiling"
+	"poly.red/math"
+)
+
+// The parameters of the post-process antialiasing.
+const (
+	fxaaContrast = 0.0312 // the luma contrast below which a pixel is kept
+	fxaaRelative = 0.125  // the same, relative to the brightest luma
+	fxaaSubpixel = 0.75   // how much subpixel aliasing is removed
+	taaBlend     = 0.1    // the weight of the current frame in the history
+	taaFrames    = 8      // the length of the jitter sequence
+)
+
+// taaState is the state of the temporal antialiasing across frames.
+type taaState struct {
+	frame   int                // the index of the frame in the jitter sequence
+	jitter  math.Vec2[float32] // the subpixel offset of the current frame
+	history []float32          // the RGBA color of the previous frames in [0, 1]
+	prev    math.Mat4[float32] // the world to screen transformation of the last frame
+}
+
+// taaJitter returns the subpixel offset of the camera for the current
+// frame: the Halton (2, 3) sequence around the pixel center with temporal
+// antialiasing, or zero without.
+func (r *Renderer) taaJitter() math.Vec2[float32] {
+	if r.cfg.Antialiasing != AntialiasingTAA {
+		return math.Vec2[float32]{}
+	}
+	i := r.taa.frame%taaFrames + 1
+	return math.NewVec2(halton(i, 2)-0.5, halton(i, 3)-0.5)
+}
+
+// halton returns the i-th element of the Halton sequence of the given base.
+func halton(i, base int) float32 {
+	f, v := float32(1), float32(0)
+	for ; i > 0; i /= base {
+		f /= float32(base)
+		v += f * float32(i%base)
+	}
+	return v
+}
+
+// projMatrix returns the projection matrix of the camera, offset by the
+// subpixel jitter of the temporal antialiasing of the current frame. Every
+// pass that rasterizes the scene or reconstructs positions from the screen
+// uses it.
+func (r *Renderer) projMatrix() math.Mat4[float32] {
+	proj := r.cfg.Camera.ProjMatrix()
+	j := r.taa.jitter
+	if j.X == 0 && j.Y == 0 {
+		return proj
+	}
+	// A clip space offset of x by a*w moves the normalized device
+	// coordinates by a, which the viewport scales by width/2.
+	return math.NewMat4(
+		1, 0, 0, 2*j.X/float32(r.cfg.Width),
+		0, 1, 0, 2*j.Y/float32(r.cfg.Height),
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	).MulM(proj)
+}
+
+// passTAA blends the current frame into the history of the previous ones by
+// the author-once kernels.TAA, on the GPU when a device is present,
+// otherwise as Go on the CPU. The history is reprojected without the jitter,
+// so a still camera accumulates the jittered samples of each pixel.
+func (r *Renderer) passTAA() {
+	if r.cfg.Debug {
+		done := profiling.Timed("temporal antialiasing")
+		defer done()
+	}
+	buf := r.CurrBuffer()
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	n := w * h
+	cur, depth := make([]float32, n*4), make([]float32, n)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			info := buf.UnsafeGet(x, y)
+			cur[i*4] = float32(info.Col.R) / 0xff
+			cur[i*4+1] = float32(info.Col.G) / 0xff
+			cur[i*4+2] = float32(info.Col.B) / 0xff
+			cur[i*4+3] = float32(info.Col.A) / 0xff
+			depth[i] = -1 // the far plane
+			if info.Ok {
+				depth[i] = info.Depth
+			}
+		}
+	}
+
+	cam := r.cfg.Camera
+	viewProj := math.ViewportMatrix(float32(w), float32(h)).
+		MulM(cam.ProjMatrix()).MulM(cam.ViewMatrix())
+	history, valid := r.taa.history, float32(1)
+	if len(history) != n*4 {
+		history, valid = cur, 0
+	}
+	tu := []float32{float32(w), float32(h), taaBlend, valid, 0, 0, 0, 0}
+	m := colMajorMat4(r.taa.prev.MulM(viewProj.Inv()))
+	tu = append(tu, m[:]...)
+
+	out := make([]float32, n*4)
+	r.runPass("taa", func() error {
+		return runScreenKernel(r.cfg.GPUDevice, kernels.TAASrc, "TAA", n, 3,
+			cur, depth, history, out, tu)
+	}, func() {
+		r.screenRows(h, func(y int) {
+			for x := 0; x < w; x++ {
+				kernels.TAA(uint(y*w+x), cur, depth, history, out, tu)
+			}
+		})
+	})
+	r.writeColors(buf, out)
+
+	r.taa.history = out
+	r.taa.prev = viewProj
+	r.taa.frame++
+}
+
+// passFXAA antialiases the final image by the author-once kernels.FXAA, on
+// the GPU when a device is present, otherwise as Go on the CPU.
+func (r *Renderer) passFXAA() {
+	if r.cfg.Debug {
+		done := profiling.Timed("fast approximate antialiasing")
+		defer done()
+	}
+	buf := r.CurrBuffer()
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	n := w * h
+	in := make([]float32, n*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			col := buf.UnsafeGet(x, y).Col
+			in[i*4] = float32(col.R) / 0xff
+			in[i*4+1] = float32(col.G) / 0xff
+			in[i*4+2] = float32(col.B) / 0xff
+			in[i*4+3] = float32(col.A) / 0xff
+		}
+	}
+	fu := []float32{float32(w), float32(h), fxaaContrast, fxaaRelative, fxaaSubpixel}
+	out := make([]float32, n*4)
+	r.runPass("fxaa", func() error {
+		return runScreenKernel(r.cfg.GPUDevice, kernels.FXAASrc, "FXAA", n, 1, in, out, fu)
+	}, func() {
+		r.screenRows(h, func(y int) {
+			for x := 0; x < w; x++ {
+				kernels.FXAA(uint(y*w+x), in, out, fu)
+			}
+		})
+	})
+	r.writeColors(buf, out)
+}
+
+// screenRows runs f for each of the h rows of the screen on the scheduler.
+func (r *Renderer) screenRows(h int, f func(y int)) {
+	for y := 0; y < h; y++ {
+		r.sched.Run(func() { f(y) })
+	}
+	r.sched.Wait()
+}
+
+// writeColors writes the RGBA colors in [0, 1] of every pixel y*w+x to the
+// colors of the given buffer.
+func (r *Renderer) writeColors(buf *buffer.FragmentBuffer, colors []float32) {
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			info := buf.UnsafeGet(x, y)
+			info.Col = color.RGBA{
+				R: toByte(colors[i*4] * 0xff),
+				G: toByte(colors[i*4+1] * 0xff),
+				B: toByte(colors[i*4+2] * 0xff),
+				A: toByte(colors[i*4+3] * 0xff),
+			}
+			buf.UnsafeSet(x, y, info)
+		}
+	}
+}
+
+// runScreenKernel runs the kernel entry of src once per pixel of a screen
+// of n pixels, with the given buffers bound in order, and reads the buffer
+// args[out] back.
+func runScreenKernel(dev *gpu.Device, src, entry string, n, out int, args ...[]float32) error {
+	mod, err := kernelModule(dev, src, entry)
+	if err != nil {
+		return err
+	}
+	entries := make([]gpu.BindGroupLayoutEntry, len(args))
+	for i := range args {
+		entries[i] = gpu.BindGroupLayoutEntry{Binding: i, Visibility: gpu.StageCompute, Kind: gpu.StorageBuffer}
+	}
+	layout := dev.NewBindGroupLayout(entries...)
+	pipe, err := dev.NewComputePipeline(gpu.ComputePipelineDescriptor{Layout: dev.NewPipelineLayout(layout), Module: mod, Entry: entry})
+	if err != nil {
+		return err
+	}
+	bufs := make([]gpu.BindGroupEntry, len(args))
+	for i, a := range args {
+		b, err := dev.NewBuffer(gpu.BufferDescriptor{Size: len(a) * 4, Usage: gpu.BufferStorage | gpu.BufferCopyDst | gpu.BufferMapRead, Data: deferredBytes(a)})
+		if err != nil {
+			return err
+		}
+		defer b.Release()
+		bufs[i] = gpu.BindGroupEntry{Binding: i, Buffer: b}
+	}
+	bg := dev.NewBindGroup(layout, bufs...)
+
+	enc := dev.NewCommandEncoder()
+	cp := enc.BeginComputePass()
+	cp.SetPipeline(pipe)
+	cp.SetBindGroup(0, bg)
+	cp.Dispatch(n, 1, 1)
+	cp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+	copy(args[out], unsafe.Slice((*float32)(unsafe.Pointer(&bufs[out].Buffer.Bytes()[0])), len(args[out])))
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestHalton(t *testing.T) {
+	want := []float32{0.5, 0.25, 0.75, 0.125, 0.625}
+	for i, v := range want {
+		if got := halton(i+1, 2); got != v {
+			t.Fatalf("halton(%d, 2): got %v, want %v", i+1, got, v)
+		}
+	}
+	r := NewRenderer(Size(8, 8), Antialiasing(AntialiasingTAA), CPU())
+	seen := map[[2]float32]bool{}
+	for i := 0; i < taaFrames; i++ {
+		j := r.taaJitter()
+		if j.X < -0.5 || j.X >= 0.5 || j.Y < -0.5 || j.Y >= 0.5 {
+			t.Fatalf("frame %d: jitter %v is outside of the pixel", i, j)
+		}
+		seen[[2]float32{j.X, j.Y}] = true
+		r.taa.frame++
+	}
+	if len(seen) != taaFrames {
+		t.Fatalf("%d distinct jitters in %d frames", len(seen), taaFrames)
+	}
+}
+
+// TestAntialiasing renders the bunny with the post-process antialiasing and
+// compares it to the multisampled rendering with 16 samples: FXAA only
+// changes the edges, and TAA with a still camera converges towards the
+// reference over a few frames.
+func TestAntialiasing(t *testing.T) {
+	const w, h = 160, 120
+	bg := color.RGBA{0, 0, 0, 255}
+	render := func(frames int, opts ...Option) *image.RGBA {
+		s, c := newscene(w, h)
+		r := NewRenderer(append(opts, Camera(c), Size(w, h), Scene(s), Background(bg), CPU())...)
+		var img *image.RGBA
+		for i := 0; i < frames; i++ {
+			img = r.Render()
+		}
+		return img
+	}
+	// The mean absolute difference of the channels of a and b.
+	dist := func(a, b *image.RGBA) float64 {
+		sum := 0
+		for i := range a.Pix {
+			sum += diff(a.Pix[i], b.Pix[i])
+		}
+		return float64(sum) / float64(len(a.Pix))
+	}
+	ref := render(1, MSAA(16))
+	plain := render(1)
+
+	fxaa := render(1, Antialiasing(AntialiasingFXAA))
+	changed := 0
+	for i := range plain.Pix {
+		if plain.Pix[i] != fxaa.Pix[i] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > len(plain.Pix)/5 {
+		t.Fatalf("FXAA changed %d of %d channels", changed, len(plain.Pix))
+	}
+	if d, p := dist(fxaa, ref), dist(plain, ref); d >= p {
+		t.Fatalf("FXAA is %.3f away from the reference, without antialiasing %.3f", d, p)
+	}
+
+	taa := render(2*taaFrames, Antialiasing(AntialiasingTAA))
+	if d, p := dist(taa, ref), dist(plain, ref); d >= p {
+		t.Fatalf("TAA is %.3f away from the reference, without antialiasing %.3f", d, p)
+	}
+}
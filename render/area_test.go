@@ -0,0 +1,90 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/camera"
+	"poly.red/geometry"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/scene"
+)
+
+func newAreaScene(shadow bool) (*scene.Scene, camera.Interface) {
+	s := scene.NewScene(
+		light.NewArea(
+			light.Intensity(1.5),
+			light.Position(math.NewVec3[float32](0.3, 0.8, 0)),
+			light.Direction(math.NewVec3[float32](-0.3, -0.8, 0)),
+			light.Size(0.8, 0.8),
+			light.CastShadow(shadow),
+		),
+		light.NewAmbient(light.Intensity(0.3)),
+	)
+	m := model.MustLoad("../internal/testdata/bunny.obj")
+	m.Scale(2, 2, 2)
+	s.Add(m)
+	g := model.MustLoad("../internal/testdata/ground.obj")
+	g.Scale(2, 2, 2)
+	s.Add(g)
+	scene.IterObjects(s, func(o *geometry.Geometry, _ math.Mat4[float32]) bool {
+		for _, m := range o.Materials() {
+			m.Config(material.ReceiveShadow(true))
+		}
+		return true
+	})
+	return s, camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](-0.6, 0.3, 1.2)),
+		camera.LookAt(math.NewVec3[float32](0, 0.3, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(60, 1, 0.1, 4),
+	)
+}
+
+// TestAreaLight renders an area light on the CPU: its emitter is visible in
+// the light color, and its shadow maps average the visibility over the light
+// such that shadows have a penumbra, i.e. fragments that are only partially
+// darkened, unlike the hard shadows of a single shadow map.
+func TestAreaLight(t *testing.T) {
+	const w, h = 128, 128
+	bg := color.RGBA{R: 0, G: 127, B: 255, A: 255}
+
+	s, c := newAreaScene(false)
+	lit := NewRenderer(Camera(c), Size(w, h), Scene(s), Background(bg), CPU()).Render()
+
+	// The emitter faces the camera in the upper right of the image.
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	if got := lit.RGBAAt(80, 16); got != white {
+		t.Fatalf("area light emitter: got %v, want %v", got, white)
+	}
+
+	s, c = newAreaScene(true)
+	shadowed := NewRenderer(Camera(c), Size(w, h), Scene(s), Background(bg), ShadowMap(true), CPU()).Render()
+	if got := shadowed.RGBAAt(80, 16); got != white {
+		t.Fatalf("area light emitter is shadowed: got %v, want %v", got, white)
+	}
+
+	darker, penumbra := 0, 0
+	for i := 0; i < len(lit.Pix); i += 4 {
+		if shadowed.Pix[i] >= lit.Pix[i] {
+			continue
+		}
+		darker++
+		// Ignore dark pixels, their ratio is dominated by quantization.
+		if ratio := float32(shadowed.Pix[i]) / float32(lit.Pix[i]); lit.Pix[i] >= 40 && ratio > 0.6 && ratio < 0.9 {
+			penumbra++
+		}
+	}
+	if darker == 0 {
+		t.Fatal("area light shadow maps did not darken any pixel")
+	}
+	if penumbra == 0 {
+		t.Fatalf("area light shadow has no penumbra among %d darker pixels", darker)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"testing"
+
+	"poly.red/camera"
+	"poly.red/geometry/primitive"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/scene"
+)
+
+func TestClipPolygon(t *testing.T) {
+	v := func(x, y, z, w, u float32) *primitive.Vertex {
+		return primitive.NewVertex(
+			primitive.Pos(math.NewVec4(x, y, z, w)),
+			primitive.UV(math.NewVec2(u, 0)),
+		)
+	}
+
+	in := []*primitive.Vertex{v(0, 0, 0, 1, 0), v(0.5, 0, 0, 1, 0), v(0, 0.5, 0, 1, 0)}
+	if got := clipPolygon(in); len(got) != 3 || got[0] != in[0] {
+		t.Fatalf("a triangle inside the frustum is clipped: %v", got)
+	}
+
+	// Behind the camera, w is negative.
+	behind := []*primitive.Vertex{v(0, 0, 0, -1, 0), v(0.5, 0, 0, -1, 0), v(0, 0.5, 0, -1, 0)}
+	if got := clipPolygon(behind); got != nil {
+		t.Fatalf("a triangle behind the camera is kept: %v", got)
+	}
+
+	// A triangle that crosses the near plane (z = w) is cut where the
+	// attributes are linear in clip space.
+	cross := []*primitive.Vertex{v(0, 0, 0, 1, 0), v(0, 0, 3, 2, 1), v(0.5, 0, 0, 1, 0)}
+	got := clipPolygon(cross)
+	if len(got) != 4 {
+		t.Fatalf("the clipped triangle has %d vertices, want 4", len(got))
+	}
+	for _, p := range got {
+		if outcode(p.Pos) != 0 {
+			t.Fatalf("the clipped vertex %v is outside the frustum", p.Pos)
+		}
+		if p.Pos.Z == p.Pos.W && p.Pos.X == 0 && !math.ApproxEq(p.UV.X, 0.5, 1e-6) {
+			t.Fatalf("the clipped vertex %v has u = %v, want 0.5", p.Pos, p.UV.X)
+		}
+	}
+}
+
+// TestClipNearPlane renders a textured plane that reaches behind the camera
+// and beyond the far plane, and checks that every pixel where the ray
+// through its center hits the plane inside the view frustum is covered,
+// with the uv of the hit point.
+func TestClipNearPlane(t *testing.T) {
+	const w, h, size = 64, 48, 8
+	s := scene.NewScene()
+	s.Add(newPBRPlane(size, material.NewPBR()))
+	c := camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 0.5, 0)),
+		camera.LookAt(math.NewVec3[float32](0, 0, -2), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(45, float32(w)/float32(h), 0.1, 3),
+	)
+	r := NewRenderer(Camera(c), Size(w, h), Scene(s), MSAA(1), CPU())
+	r.passForward()
+	buf := r.CurrBuffer()
+
+	inv := math.ViewportMatrix[float32](w, h).MulM(c.ProjMatrix()).MulM(c.ViewMatrix()).Inv()
+	unproject := func(x, y, z float32) math.Vec3[float32] {
+		return inv.MulV(math.NewVec4(x, y, z, 1)).Pos().ToVec3()
+	}
+	view := c.ViewMatrix()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			near := unproject(float32(x)+0.5, float32(y)+0.5, 1)
+			far := unproject(float32(x)+0.5, float32(y)+0.5, -1)
+			k := near.Y / (near.Y - far.Y)
+			hit := k >= 0 && k <= 1
+			p := near.Add(far.Sub(near).Scale(k, k, k))
+			// The pixels close to the far plane may go either way.
+			if d := -view.MulV(p.ToVec4(1)).Z; hit && d > 2.9 {
+				continue
+			}
+
+			info := buf.UnsafeGet(x, y)
+			if info.Ok != hit {
+				t.Fatalf("pixel (%d, %d): covered %v, want %v", x, y, info.Ok, hit)
+			}
+			if !hit {
+				continue
+			}
+			u, v := p.X/size+0.5, 0.5-p.Z/size
+			if !math.ApproxEq(info.U, u, 1e-3) || !math.ApproxEq(info.V, v, 1e-3) {
+				t.Fatalf("pixel (%d, %d): uv (%v, %v), want (%v, %v)", x, y, info.U, info.V, u, v)
+			}
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 // AABB and can be skipped.
 func (r *Renderer) cullViewFrustum(buf *buffer.FragmentBuffer, v1, v2, v3 math.Vec4[float32]) bool {
 	viewportAABB := primitive.NewAABB(
-		math.NewVec3(float32(buf.Bounds().Dx()*r.cfg.MSAA), float32(buf.Bounds().Dy()*r.cfg.MSAA), 1),
+		math.NewVec3(float32(buf.Bounds().Dx()), float32(buf.Bounds().Dy()), 1),
 		math.NewVec3[float32](0, 0, 0),
 		math.NewVec3[float32](0, 0, -1),
 	)
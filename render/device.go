@@ -0,0 +1,166 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"poly.red/gpu"
+)
+
+// gpuEnv is the environment variable that overrides the device NewRenderer
+// acquires: "cpu" (or "off") runs every pass on the CPU, and "metal",
+// "vulkan" or "gl" probes only that driver. The GPUDriver, GPU and CPU
+// options take precedence over it.
+const gpuEnv = "POLYRED_GPU"
+
+// errNoDevice is the verdict of a probe when the environment turns the GPU
+// off.
+var errNoDevice = errors.New("render: GPU disabled by " + gpuEnv)
+
+// probeDrivers returns the drivers NewRenderer probes for d, in order:
+// Metal on macOS, and Vulkan then headless EGL (GL) on Linux, where Mesa's
+// drivers, software ones included, are on any workstation. A driver whose
+// device fails the self-check yields to the next one. Other platforms probe
+// nothing unless a driver is asked for.
+func probeDrivers(d gpu.Driver) []gpu.Driver {
+	if d != gpu.DriverAuto {
+		return []gpu.Driver{d}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return []gpu.Driver{gpu.DriverMetal}
+	case "linux":
+		return []gpu.Driver{gpu.DriverVulkan, gpu.DriverGL}
+	}
+	return nil
+}
+
+// envDriver parses the gpuEnv environment variable into the driver to
+// probe, or returns false if the GPU is turned off. An unset or unknown
+// value probes every driver.
+func envDriver() (gpu.Driver, bool) {
+	switch strings.ToLower(os.Getenv(gpuEnv)) {
+	case "cpu", "off", "none":
+		return gpu.DriverAuto, false
+	case "metal":
+		return gpu.DriverMetal, true
+	case "vulkan", "vk":
+		return gpu.DriverVulkan, true
+	case "gl", "egl":
+		return gpu.DriverGL, true
+	}
+	return gpu.DriverAuto, true
+}
+
+// deviceProbe is the cached verdict of a driver: the device that passed the
+// self-check, or why none did.
+type deviceProbe struct {
+	once sync.Once
+	dev  *gpu.Device
+	err  error
+}
+
+var (
+	probesMu sync.Mutex
+	probes   = map[gpu.Driver]*deviceProbe{}
+)
+
+// acquireDevice returns a device of the first of the drivers of d that
+// opens and passes the self-check. Each driver is probed once per process
+// and its device is shared by every renderer that acquires it: reopening
+// a driver for every renderer is slow, and some drivers (GL on Mesa) do not
+// survive being opened and closed repeatedly in one process.
+func acquireDevice(d gpu.Driver) (*gpu.Device, error) {
+	var errs []error
+	for _, drv := range probeDrivers(d) {
+		probesMu.Lock()
+		p, ok := probes[drv]
+		if !ok {
+			p = &deviceProbe{}
+			probes[drv] = p
+		}
+		probesMu.Unlock()
+
+		p.once.Do(func() { p.dev, p.err = probeDevice(drv) })
+		if p.err == nil {
+			return p.dev, nil
+		}
+		errs = append(errs, p.err)
+	}
+	if len(errs) == 0 {
+		return nil, gpu.ErrUnsupported
+	}
+	return nil, errors.Join(errs...)
+}
+
+// probeDevice opens a headless device of the driver and runs the self-check
+// on it, which proves that the renderer's kernels compile for the device and
+// compute the right values there, and that its forward rasterizer builds.
+func probeDevice(drv gpu.Driver) (*gpu.Device, error) {
+	dev, err := gpu.Open(gpu.WithDriver(drv), gpu.WithHeadless())
+	if err != nil {
+		return nil, err
+	}
+	if err := selfCheck(dev); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("render: %v self-check: %w", drv, err)
+	}
+	return dev, nil
+}
+
+// selfCheckSrc is the kernel of the self-check of a device.
+const selfCheckSrc = `package kernels
+func SelfCheck(gid uint, in []float32, out []float32) {
+	out[gid] = in[gid]*2.0 + 1.0
+}`
+
+// selfCheck runs selfCheckSrc on dev and compares it to the CPU, then
+// builds the pipeline of the forward pass. A device that runs compute
+// kernels but cannot build the forward vertex and fragment stages would
+// leave the forward pass on the CPU, where another driver may run it all.
+// A driver that panics fails the check rather than the caller.
+func selfCheck(dev *gpu.Device) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	if _, _, err := forwardPipeline(dev, "ForwardGBuffer"); err != nil {
+		return fmt.Errorf("forward pipeline: %w", err)
+	}
+	const n = 64
+	in := make([]float32, n)
+	for i := range in {
+		in[i] = float32(i) - 16
+	}
+	ib := storageBuf(dev, in)
+	defer ib.Release()
+	ob, err := dev.NewBuffer(gpu.BufferDescriptor{Data: deferredBytes(make([]float32, n)), Usage: gpu.BufferStorage | gpu.BufferMapRead})
+	if err != nil {
+		return err
+	}
+	defer ob.Release()
+
+	enc := dev.NewCommandEncoder()
+	if err := encodeKernel(dev, enc, selfCheckSrc, "SelfCheck", n, nil, ib, ob); err != nil {
+		return err
+	}
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+	out := unsafe.Slice((*float32)(unsafe.Pointer(&ob.Bytes()[0])), n)
+	for i := range out {
+		if want := in[i]*2 + 1; out[i] != want {
+			return fmt.Errorf("out[%d] = %v, want %v", i, out[i], want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"runtime"
+	"testing"
+
+	"poly.red/gpu"
+)
+
+func TestEnvDriver(t *testing.T) {
+	for _, tt := range []struct {
+		env string
+		drv gpu.Driver
+		on  bool
+	}{
+		{"", gpu.DriverAuto, true},
+		{"auto", gpu.DriverAuto, true},
+		{"CPU", gpu.DriverAuto, false},
+		{"off", gpu.DriverAuto, false},
+		{"metal", gpu.DriverMetal, true},
+		{"vulkan", gpu.DriverVulkan, true},
+		{"gl", gpu.DriverGL, true},
+		{"unknown", gpu.DriverAuto, true},
+	} {
+		t.Setenv(gpuEnv, tt.env)
+		if drv, on := envDriver(); drv != tt.drv || on != tt.on {
+			t.Errorf("%s=%q: got (%v, %v), want (%v, %v)", gpuEnv, tt.env, drv, on, tt.drv, tt.on)
+		}
+	}
+}
+
+func TestProbeDrivers(t *testing.T) {
+	if got := probeDrivers(gpu.DriverGL); len(got) != 1 || got[0] != gpu.DriverGL {
+		t.Fatalf("a requested driver must be probed alone, got %v", got)
+	}
+	got := probeDrivers(gpu.DriverAuto)
+	switch runtime.GOOS {
+	case "darwin":
+		if len(got) != 1 || got[0] != gpu.DriverMetal {
+			t.Fatalf("darwin probes %v, want [metal]", got)
+		}
+	case "linux":
+		if len(got) != 2 || got[0] != gpu.DriverVulkan || got[1] != gpu.DriverGL {
+			t.Fatalf("linux probes %v, want [vulkan gl]", got)
+		}
+	}
+}
+
+// TestDeviceAcquisition checks the overrides of the device that NewRenderer
+// acquires, and that the verdict of a probe is cached: every renderer
+// shares the device of the driver that passed the self-check.
+func TestDeviceAcquisition(t *testing.T) {
+	t.Setenv(gpuEnv, "cpu")
+	if r := NewRenderer(Size(4, 4)); r.cfg.GPUDevice != nil {
+		t.Fatalf("%s=cpu: the renderer acquired a %v device", gpuEnv, r.cfg.GPUDevice.Driver())
+	}
+	// No backend opens a D3D12 device yet, so the renderer runs on the CPU.
+	if r := NewRenderer(Size(4, 4), GPUDriver(gpu.DriverD3D12)); r.cfg.GPUDevice != nil {
+		t.Fatal("GPUDriver(DriverD3D12): the renderer acquired a device")
+	}
+	if _, err := acquireDevice(gpu.DriverD3D12); err == nil {
+		t.Fatal("acquireDevice(DriverD3D12) did not fail")
+	}
+
+	// GPUDriver takes precedence over the environment.
+	r1 := NewRenderer(Size(4, 4), GPUDriver(gpu.DriverAuto))
+	if r1.cfg.GPUDevice == nil {
+		t.Skip("no device passes the self-check")
+	}
+	r2 := NewRenderer(Size(4, 4), GPUDriver(r1.cfg.GPUDevice.Driver()))
+	if r1.cfg.GPUDevice != r2.cfg.GPUDevice {
+		t.Fatal("the renderers did not share the probed device")
+	}
+	if err := selfCheck(r1.cfg.GPUDevice); err != nil {
+		t.Fatalf("self-check: %v", err)
+	}
+	if r := NewRenderer(Size(4, 4), CPU(), GPUDriver(gpu.DriverAuto)); r.cfg.GPUDevice != nil {
+		t.Fatal("CPU() must take precedence over GPUDriver")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/buffer"
+	"poly.red/camera"
+	"poly.red/geometry/primitive"
+	"poly.red/gpu/shader/gpumath/kernels"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/scene"
+	"poly.red/shader"
+)
+
+// newSkyEnvMap returns an environment map of a blue sky that gets brighter
+// towards the zenith, a brown ground and a small bright sun.
+func newSkyEnvMap(opts ...light.Option) *light.EnvMap {
+	img := buffer.NewHDR(64, 32)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			c := math.NewVec4[float32](0.35, 0.25, 0.15, 0)
+			if y < img.Height/2 {
+				k := 1 - float32(y)/float32(img.Height)
+				c = math.NewVec4(0.2*k, 0.4*k, 0.9*k, 0)
+			}
+			if x >= 20 && x < 22 && y >= 6 && y < 8 {
+				c = math.NewVec4[float32](20, 18, 14, 0)
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return light.NewEnvMap(img, opts...).(*light.EnvMap)
+}
+
+// TestEnvMapUniform checks that a uniform environment map lights a diffuse
+// surface as an ambient light of its radiance, and a mirror by its
+// radiance.
+func TestEnvMapUniform(t *testing.T) {
+	img := buffer.NewHDR(32, 16)
+	for i := range img.Pix {
+		img.Pix[i] = 0.5
+	}
+	env := light.NewEnvMap(img).(*light.EnvMap)
+	for _, d := range []math.Vec4[float32]{
+		math.NewVec4[float32](0, 1, 0, 0),
+		math.NewVec4[float32](0, 0, -1, 0),
+		math.NewVec4[float32](0.6, -0.64, 0.48, 0),
+	} {
+		for _, c := range []math.Vec4[float32]{env.Irradiance(d), env.Radiance(d, 0), env.Radiance(d, 0.7), env.Background(d)} {
+			if math.Abs(c.X-0.5) > 1e-4 || math.Abs(c.Y-0.5) > 1e-4 || math.Abs(c.Z-0.5) > 1e-4 {
+				t.Fatalf("direction %v: got %v, want the uniform radiance 0.5", d, c)
+			}
+		}
+	}
+
+	tex := buffer.NewUniformTexture(color.RGBA{R: 200, G: 150, B: 100, A: 255})
+	mat := material.NewBlinnPhong(material.Texture(tex), material.Specular(color.RGBA{A: 255}))
+	info := buffer.Fragment{Ok: true, Fragment: primitive.Fragment{
+		Nor:     math.NewVec4[float32](0, 0, 1, 0),
+		WordPos: math.NewVec4[float32](0, 0, 0, 1),
+		U:       0.5, V: 0.5,
+	}}
+	camPos := math.NewVec3[float32](0, 0, 2)
+	got := shader.FragmentShader(mat, info, camPos, nil, []light.Environment{env})
+	want := shader.FragmentShader(mat, info, camPos, []light.Source{light.NewPoint(light.Intensity(0))},
+		[]light.Environment{light.NewAmbient(light.Intensity(0.5))})
+	if got != want {
+		t.Fatalf("diffuse: got %v, want the ambient light %v", got, want)
+	}
+}
+
+// TestEnvMapShadingEquivalence locks the image based light of the CPU
+// shaders to the one of the author-once kernel, for Blinn-Phong and
+// metallic-roughness materials of increasing roughness.
+func TestEnvMapShadingEquivalence(t *testing.T) {
+	camPos, ls, _ := equivalenceLights()
+	env := newSkyEnvMap(light.Intensity(1.5))
+	es := []light.Environment{light.NewAmbient(light.Intensity(0.1)), env}
+
+	lightData, ok := packLights(ls)
+	if !ok {
+		t.Fatal("packLights: unsupported light")
+	}
+	scene := []float32{camPos.X, camPos.Y, camPos.Z, 1, 0.1, float32(len(ls)), 0, 0}
+	envData := packEnvMap(env)
+
+	tex := buffer.NewUniformTexture(color.RGBA{R: 200, G: 150, B: 100, A: 255})
+	type shade func(info buffer.Fragment, out []float32) color.RGBA
+	var cases []shade
+	for _, shininess := range []float32{2, 32, 500} {
+		mat := material.NewBlinnPhong(
+			material.Texture(tex),
+			material.Diffuse(color.RGBA{R: 220, G: 180, B: 160, A: 255}),
+			material.Specular(color.RGBA{R: 120, G: 120, B: 120, A: 255}),
+			material.Shininess(shininess),
+		)
+		materials := []float32{220, 180, 160, 255, 120, 120, 120, 255, shininess, 0}
+		cases = append(cases, func(info buffer.Fragment, out []float32) color.RGBA {
+			bc := tex.Query(0, info.U, 1-info.V)
+			n, p := info.Nor, info.WordPos
+			kernels.Shade(0, []float32{n.X, n.Y, n.Z, 0}, []float32{p.X, p.Y, p.Z, 1},
+				[]float32{float32(bc.R), float32(bc.G), float32(bc.B), float32(bc.A)},
+				lightData, []float32{0}, materials, make([]float32, 8), scene, envData, out)
+			return shader.FragmentShader(mat, info, camPos, ls, es)
+		})
+	}
+	for _, roughness := range []float32{0, 0.3, 0.8} {
+		mat := material.NewPBR(
+			material.BaseColor(color.RGBA{R: 250, G: 200, B: 120, A: 255}),
+			material.Metallic(0.5), material.Roughness(roughness),
+		)
+		cases = append(cases, func(info buffer.Fragment, out []float32) color.RGBA {
+			sf := mat.Surface(info.U, 1-info.V, info.Du, info.Dv)
+			bc := sf.BaseColor.Scale(0xff, 0xff, 0xff, 0xff)
+			n, p := info.Nor, info.WordPos
+			kernels.Shade(0, []float32{n.X, n.Y, n.Z, 0}, []float32{p.X, p.Y, p.Z, 1},
+				[]float32{bc.X, bc.Y, bc.Z, bc.W}, lightData, []float32{0},
+				[]float32{0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+				[]float32{sf.Metallic, sf.Roughness, sf.Occlusion, 0, 0, 0, 0, 0}, scene, envData, out)
+			return shader.PBRFragmentShader(mat, info, camPos, ls, es)
+		})
+	}
+
+	q := func(v float32) uint8 { return uint8(math.Clamp(math.Round(v), 0, 0xff)) }
+	for ci, c := range cases {
+		for i := range equivalenceNorms {
+			n := equivalenceNorms[i]
+			p := equivalencePoss[i]
+			info := buffer.Fragment{Ok: true, Fragment: primitive.Fragment{
+				Nor:     math.NewVec4(n[0], n[1], n[2], 0).Unit(),
+				WordPos: math.NewVec4(p[0], p[1], p[2], 1),
+				U:       0.5, V: 0.5,
+			}}
+			out := make([]float32, 4)
+			cpu := c(info, out)
+			kr, kg, kb := q(out[0]), q(out[1]), q(out[2])
+			if diff(cpu.R, kr) > 1 || diff(cpu.G, kg) > 1 || diff(cpu.B, kb) > 1 {
+				t.Errorf("case %d frag %d: CPU=(%d,%d,%d) kernels.Shade=(%d,%d,%d): differ by >1",
+					ci, i, cpu.R, cpu.G, cpu.B, kr, kg, kb)
+			}
+		}
+	}
+}
+
+// TestEnvMapBackground renders the environment map behind the bunny if no
+// background color is given.
+func TestEnvMapBackground(t *testing.T) {
+	const w, h = 64, 64
+	s := scene.NewScene(newSkyEnvMap())
+	m := model.MustLoad("../internal/testdata/bunny.obj")
+	m.Scale(2, 2, 2)
+	s.Add(m)
+	c := camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 0.3, 1)),
+		camera.ViewFrustum(90, 1, 0.1, 3),
+	)
+
+	img := NewRenderer(Camera(c), Size(w, h), Scene(s), CPU()).Render()
+	sky, ground := img.RGBAAt(w/2, 0), img.RGBAAt(w/2, h-1)
+	if sky.A != 0xff || sky.B <= sky.R || ground.R <= ground.B {
+		t.Fatalf("background: got sky %v and ground %v", sky, ground)
+	}
+	if bunny := img.RGBAAt(w/2, h/2); bunny == sky || bunny == ground {
+		t.Fatalf("the bunny is missing: got %v", bunny)
+	}
+
+	bg := color.RGBA{R: 0, G: 127, B: 255, A: 255}
+	img = NewRenderer(Camera(c), Size(w, h), Scene(s), Background(bg), CPU()).Render()
+	if got := img.RGBAAt(w/2, 0); got != bg {
+		t.Fatalf("background option: got %v, want %v", got, bg)
+	}
+}
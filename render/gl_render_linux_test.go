@@ -10,7 +10,16 @@ import (
 	"os"
 	"testing"
 
+	"poly.red/buffer"
+	"poly.red/camera"
+	"poly.red/color"
+	"poly.red/geometry"
 	"poly.red/gpu"
+	"poly.red/gpu/shader/gpumath/kernels"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/scene"
 )
 
 // TestGLDeferredRender isolates deferred-shading parity on the cgo-free GL backend:
@@ -35,10 +44,15 @@ func TestGLDeferredRender(t *testing.T) {
 	if dev.Driver() != gpu.DriverGL {
 		t.Fatalf("want DriverGL, got %v", dev.Driver())
 	}
+	checkDeferredRender(t, dev)
+}
 
+// checkDeferredRender shades the G-buffer of the CPU forward pass with the
+// deferred pass on dev and compares the image to the CPU render.
+func checkDeferredRender(t *testing.T, dev *gpu.Device) {
 	const w, h = 96, 96
 	s, c := newscene(w, h)
-	// Single worker so the forward pass is deterministic, letting the CPU and GL
+	// Single worker so the forward pass is deterministic, letting the CPU and GPU
 	// deferred shading be compared on an identical G-buffer.
 	opts := []Option{Scene(s), Camera(c), Size(w, h), Workers(1), BatchSize(1)}
 
@@ -47,17 +61,17 @@ func TestGLDeferredRender(t *testing.T) {
 	// forwardOnCPU: shade the SAME G-buffer as the CPU reference so only the deferred
 	// stage differs (GPU vs CPU), keeping this a pure deferred-shading gate.
 	gr := NewRenderer(append(opts, GPU(dev), forwardOnCPU())...)
-	gl := gr.Render()
+	img := gr.Render()
 	if !gr.passOnGPU("deferred") {
-		t.Fatal("deferred pass did not run on the GL GPU (fell back to CPU)")
+		t.Fatalf("deferred pass did not run on the %v GPU (fell back to CPU)", dev.Driver())
 	}
 
-	if len(cpu.Pix) != len(gl.Pix) {
-		t.Fatalf("size mismatch: cpu %d gl %d", len(cpu.Pix), len(gl.Pix))
+	if len(cpu.Pix) != len(img.Pix) {
+		t.Fatalf("size mismatch: cpu %d %v %d", len(cpu.Pix), dev.Driver(), len(img.Pix))
 	}
 	nBig := 0
 	for i := range cpu.Pix {
-		d := int(cpu.Pix[i]) - int(gl.Pix[i])
+		d := int(cpu.Pix[i]) - int(img.Pix[i])
 		if d < 0 {
 			d = -d
 		}
@@ -66,7 +80,381 @@ func TestGLDeferredRender(t *testing.T) {
 		}
 	}
 	if frac := float64(nBig) / float64(len(cpu.Pix)); frac > 0.02 {
-		t.Fatalf("GL vs CPU deferred: %.2f%% of channels differ by >8 (want <2%%, %d/%d)", frac*100, nBig, len(cpu.Pix))
+		t.Fatalf("%v vs CPU deferred: %.2f%% of channels differ by >8 (want <2%%, %d/%d)", dev.Driver(), frac*100, nBig, len(cpu.Pix))
+	}
+	t.Logf("%v deferred render: %d/%d channels differ by >8", dev.Driver(), nBig, len(cpu.Pix))
+}
+
+// TestGLForwardMultisample runs checkForwardMultisample on the GL backend.
+func TestGLForwardMultisample(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+	checkForwardMultisample(t, dev)
+}
+
+// checkForwardMultisample runs the multisampled GPU forward pass on dev: the
+// coverage pass must find partially covered pixels on the silhouette, and
+// the resolved image must stay close to the multisampled CPU render.
+func checkForwardMultisample(t *testing.T, dev *gpu.Device) {
+	const w, h, msaa = 96, 96, 4
+	s, c := newscene(w, h)
+	cpu := NewRenderer(Scene(s), Camera(c), Size(w, h), MSAA(msaa), CPU()).Render()
+
+	r := NewRenderer(Scene(s), Camera(c), Size(w, h), MSAA(msaa), GPU(dev))
+	r.passForward()
+	if !r.passOnGPU("forward") {
+		t.Fatalf("the multisampled forward pass did not run on the %v GPU", dev.Driver())
+	}
+	if len(r.msaaEdges) == 0 {
+		t.Fatal("the coverage pass found no partially covered pixel")
+	}
+	for _, e := range r.msaaEdges {
+		if e.primary < 1 || e.primary >= msaa {
+			t.Fatalf("pixel (%d, %d): %d of %d samples are covered", e.x, e.y, e.primary, msaa)
+		}
+	}
+
+	gpuImg := NewRenderer(Scene(s), Camera(c), Size(w, h), MSAA(msaa), GPU(dev)).Render()
+	n8 := 0
+	for i := range cpu.Pix {
+		if diff(cpu.Pix[i], gpuImg.Pix[i]) > 8 {
+			n8++
+		}
+	}
+	// Measured at 6.27%@>8: the band of the single sampled integration, plus
+	// the shared edges of triangles, which the CPU resolves per surface and
+	// the coverage pass does not see.
+	if f8 := float64(n8) / float64(len(cpu.Pix)); f8 > 0.08 {
+		t.Fatalf("multisampled GPU forward diverges from CPU on %.2f%%@>8; want <8%% (measured 6.27%%)", f8*100)
+	}
+}
+
+// TestGLAreaLight runs checkAreaLight on the GL backend.
+func TestGLAreaLight(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+	checkAreaLight(t, dev)
+}
+
+// checkAreaLight renders the area light scene with the GPU forward pass on
+// dev: the emitter is drawn by the forward pipeline in the light color, and
+// the image follows the CPU one.
+func checkAreaLight(t *testing.T, dev *gpu.Device) {
+	const w, h = 128, 128
+	bg := color.RGBA{R: 0, G: 127, B: 255, A: 255}
+	s, c := newAreaScene(true)
+	opts := []Option{Camera(c), Size(w, h), Scene(s), Background(bg), ShadowMap(true), MSAA(1)}
+	cpu := NewRenderer(append(opts, CPU())...).Render()
+
+	r := NewRenderer(append(opts, GPU(dev))...)
+	img := r.Render()
+	if !r.passOnGPU("forward") {
+		t.Fatalf("the forward pass of an area light scene did not run on the %v GPU", dev.Driver())
+	}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	if got := img.RGBAAt(80, 16); got != white {
+		t.Fatalf("area light emitter: got %v, want %v", got, white)
+	}
+	n8 := 0
+	for i := range cpu.Pix {
+		if diff(cpu.Pix[i], img.Pix[i]) > 8 {
+			n8++
+		}
+	}
+	// Measured at 3.40%@>8, all but a few pixels of which are the boundary
+	// parity band of the bunny, see TestGPUForwardDeferredIntegration.
+	if f8 := float64(n8) / float64(len(cpu.Pix)); f8 > 0.06 {
+		t.Fatalf("GPU forward of an area light diverges from CPU on %.2f%%@>8; want <6%% (measured 3.40%%)", f8*100)
+	}
+}
+
+// TestGLAntialiasingKernels runs the author-once kernels.FXAA and
+// kernels.TAA on the GL backend over a rendered image and requires the
+// result of the same kernels run as Go on the CPU. A comparison near a
+// threshold may take the other branch on the GPU: a tiny fraction of the
+// channels may differ.
+func TestGLAntialiasingKernels(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+
+	const w, h = 96, 96
+	s, c := newscene(w, h)
+	img := NewRenderer(Scene(s), Camera(c), Size(w, h), CPU()).Render()
+	n := w * h
+	cur := make([]float32, n*4)
+	history := make([]float32, n*4)
+	for i := range cur {
+		cur[i] = float32(img.Pix[i]) / 0xff
+		history[(i+4)%len(history)] = cur[i] // shifted by a pixel
+	}
+	depth := make([]float32, n)
+	fu := []float32{w, h, fxaaContrast, fxaaRelative, fxaaSubpixel}
+	tu := []float32{w, h, taaBlend, 1, 0, 0, 0, 0,
+		1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0.25, 0, 0, 1}
+
+	for _, k := range []struct {
+		name string
+		gpu  func(out []float32) error
+		cpu  func(i int, out []float32)
+	}{
+		{"FXAA", func(out []float32) error {
+			return runScreenKernel(dev, kernels.FXAASrc, "FXAA", n, 1, cur, out, fu)
+		}, func(i int, out []float32) {
+			kernels.FXAA(uint(i), cur, out, fu)
+		}},
+		{"TAA", func(out []float32) error {
+			return runScreenKernel(dev, kernels.TAASrc, "TAA", n, 3, cur, depth, history, out, tu)
+		}, func(i int, out []float32) {
+			kernels.TAA(uint(i), cur, depth, history, out, tu)
+		}},
+	} {
+		t.Run(k.name, func(t *testing.T) {
+			got, want := make([]float32, n*4), make([]float32, n*4)
+			if err := k.gpu(got); err != nil {
+				t.Fatalf("GL %s: %v", k.name, err)
+			}
+			for i := 0; i < n; i++ {
+				k.cpu(i, want)
+			}
+			differ := 0
+			for i := range got {
+				if d := got[i] - want[i]; d > 1e-3 || d < -1e-3 {
+					differ++
+				}
+			}
+			if differ > len(got)/1000 {
+				t.Fatalf("%d of %d channels differ between GL and the CPU", differ, len(got))
+			}
+		})
+	}
+}
+
+// TestGLResidentGBuffer renders a scene whose materials are uniform, with
+// shadows and ambient occlusion, so that the G-buffer of the GPU forward
+// pass stays on the GL device and the deferred kernels read its targets in
+// place. The image must match the same frame whose G-buffer is read back
+// and re-uploaded: both run the same kernels on the same data.
+func TestGLResidentGBuffer(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+
+	const w, h = 96, 96
+	s, c := newMixedMaterialScene(w, h)
+	ls, _ := s.Lights()
+	light.CastShadow(true)(ls[0])
+	scene.IterObjects(s, func(o *geometry.Geometry, _ math.Mat4[float32]) bool {
+		for _, m := range o.Materials() {
+			m.Config(material.AmbientOcclusion(true), material.ReceiveShadow(true))
+			if _, ok := m.(*material.BlinnPhong); ok {
+				m.Config(material.Texture(buffer.NewUniformTexture(color.RGBA{R: 200, G: 120, B: 80, A: 255})))
+			}
+		}
+		return true
+	})
+	compareResident(t, Scene(s), Camera(c), Size(w, h), MSAA(1), ShadowMap(true), GPU(dev))
+}
+
+// TestGLResidentTexture renders the textured bunny, whose texture the
+// resident deferred pass samples from the mipmap atlas by kernels.Sample at
+// the level of detail it computes on the device. The image must match the
+// frame whose G-buffer is read back and sampled by buffer.Texture.Query.
+func TestGLResidentTexture(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+
+	const w, h = 96, 96
+	s, c := newMixedMaterialScene(w, h)
+	compareResident(t, Scene(s), Camera(c), Size(w, h), MSAA(1), GPU(dev))
+}
+
+// compareResident renders a frame whose G-buffer stays on the device and
+// the same frame with the G-buffer read back, and requires them equal.
+func compareResident(t *testing.T, opts ...Option) {
+	t.Helper()
+	rr := NewRenderer(append(opts, gbufferReadback())...)
+	want := rr.Render()
+	r := NewRenderer(opts...)
+	got := r.Render()
+	for _, rn := range []*Renderer{rr, r} {
+		if !rn.passOnGPU("forward") || !rn.passOnGPU("deferred") {
+			t.Fatalf("the forward and deferred passes did not run on the GL GPU: %v", rn.passGPU)
+		}
+	}
+	if rr.gbuf != nil || r.gbuf == nil {
+		t.Fatal("the G-buffer did not stay on the device for the deferred pass")
+	}
+
+	differ := 0
+	for i := range want.Pix {
+		if diff(want.Pix[i], got.Pix[i]) > 1 {
+			differ++
+		}
+	}
+	if differ > 0 {
+		t.Fatalf("%d of %d channels differ between the resident and the read back G-buffer", differ, len(want.Pix))
+	}
+}
+
+// TestGLSampleKernel samples the UV grid by kernels.Sample on the GL device
+// and requires the colors of buffer.Texture.Query, bit for bit: the kernel
+// transliterates Query and the atlas carries the CPU's own mipmap, so the
+// GPU sampling has no tolerance.
+func TestGLSampleKernel(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+
+	textures := sampleTextures()
+	uvl := sampleUVL(textures)
+	got, err := runSampleKernel(dev, newTextureAtlas(textures), uvl)
+	if err != nil {
+		t.Fatalf("runSampleKernel: %v", err)
+	}
+	checkSampled(t, "GL", textures, uvl, got)
+}
+
+// TestGLKernelModuleCache compiles kernels on the GL device twice: the
+// second call returns the module, or the compile error, of the first
+// instead of compiling again, and the device passes the self-check, which
+// builds the forward pipeline.
+func TestGLKernelModuleCache(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+
+	m1, err := kernelModule(dev, kernels.ForwardSrc, "Forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m2, _ := kernelModule(dev, kernels.ForwardSrc, "Forward"); m2 != m1 {
+		t.Fatal("the module was compiled again")
+	}
+	const bad = "package kernels\nfunc Bad(gid uint, out []float32) { out[gid] = undefined }"
+	_, err1 := kernelModule(dev, bad, "Bad")
+	_, err2 := kernelModule(dev, bad, "Bad")
+	if err1 == nil || err1 != err2 {
+		t.Fatalf("errors %v and %v, want the same compile error", err1, err2)
+	}
+	if err := selfCheck(dev); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGLForwardDepthOrder runs checkForwardDepthOrder on the GL backend,
+// where the depth is that of the CPU to 1e-5.
+func TestGLForwardDepthOrder(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+	checkForwardDepthOrder(t, dev, 1e-5)
+}
+
+// checkForwardDepthOrder draws a plane above a larger ground plane, the
+// ground first: the GPU forward pass on dev keeps the nearer plane, as the
+// CPU one does, at the depth of the CPU to tol.
+func checkForwardDepthOrder(t *testing.T, dev *gpu.Device, tol float32) {
+	const w, h = 32, 32
+	ground := newPBRPlane(2, material.NewBlinnPhong())
+	top := newPBRPlane(0.8, material.NewBlinnPhong())
+	top.Translate(0, 0.2, 0)
+	s := scene.NewScene(ground, top, light.NewAmbient(light.Intensity(1)))
+	c := camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 1.5, 1)),
+		camera.LookAt(math.NewVec3[float32](0, 0, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(45, 1, 0.1, 5),
+	)
+	cpu := NewRenderer(Scene(s), Camera(c), Size(w, h), CPU())
+	cpu.cpuForwardPass()
+	r := NewRenderer(Scene(s), Camera(c), Size(w, h), GPU(dev))
+	r.passForward()
+	if !r.passOnGPU("forward") {
+		t.Fatalf("the forward pass did not run on the %v GPU", dev.Driver())
+	}
+	r.readbackGBuffer()
+	want, got := cpu.CurrBuffer().UnsafeGet(w/2, h/2), r.CurrBuffer().UnsafeGet(w/2, h/2)
+	if want.MaterialID != 1 || got.MaterialID != want.MaterialID {
+		t.Fatalf("material %d, want %d of the nearer plane", got.MaterialID, want.MaterialID)
+	}
+	if d := got.Depth - want.Depth; d < -tol || d > tol {
+		t.Fatalf("depth %v, want %v", got.Depth, want.Depth)
+	}
+}
+
+// TestGLTransparent runs checkTransparent on the GL backend.
+func TestGLTransparent(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+	checkTransparent(t, dev)
+}
+
+// checkTransparent renders overlapping transparent planes, one of them of
+// a blend mode, on dev and on the CPU: the GPU peels and shades the
+// transparent primitives, and the images agree but for the boundary band of
+// the forward rasterizer. The light is directional and the materials have no
+// specular, so that the shading does not depend on the world position,
+// which the CPU interpolates differently.
+func checkTransparent(t *testing.T, dev *gpu.Device) {
+	render := func(dev Option) (*Renderer, []byte) {
+		s := scene.NewScene(
+			light.NewDirectional(light.Intensity(1), light.Direction(math.NewVec3[float32](0, -1, -0.5))),
+			light.NewAmbient(light.Intensity(0.3)),
+		)
+		plane := func(size, height float32, col color.RGBA, opacity float32, blend color.BlendMode) {
+			g := newPBRPlane(size, material.NewBlinnPhong(
+				material.Texture(buffer.NewUniformTexture(col)),
+				material.Specular(color.RGBA{}),
+				material.Opacity(opacity),
+				material.Blend(blend),
+			))
+			g.Translate(0, height, 0)
+			s.Add(g)
+		}
+		plane(2, 0, color.RGBA{R: 200, G: 200, B: 200, A: 255}, 1, color.BlendSrcOver)
+		plane(0.8, 0.2, color.RGBA{R: 255, A: 255}, 0.4, color.BlendSrcOver)
+		plane(0.7, 0.3, color.RGBA{R: 128, G: 255, A: 255}, 1, color.BlendMultiply)
+		plane(0.6, 0.4, color.RGBA{B: 255, A: 255}, 0.6, color.BlendSrcOver)
+		c := camera.NewPerspective(
+			camera.Position(math.NewVec3[float32](0, 1.5, 1)),
+			camera.LookAt(math.NewVec3[float32](0, 0, 0), math.NewVec3[float32](0, 1, 0)),
+			camera.ViewFrustum(45, 1, 0.1, 5),
+		)
+		r := NewRenderer(Camera(c), Size(64, 64), Scene(s), MSAA(1),
+			Background(color.RGBA{A: 255}), dev)
+		return r, r.Render().Pix
+	}
+	_, want := render(CPU())
+	r, got := render(GPU(dev))
+	if !r.passOnGPU("forward") || !r.passOnGPU("transparent") {
+		t.Fatalf("the transparent primitives were not drawn on the %v GPU", dev.Driver())
+	}
+	// The center of the image is covered by all three planes.
+	if i := (32*64 + 32) * 4; got[i] == 200 && got[i+1] == 200 {
+		t.Fatalf("the center shows the ground: %v", got[i:i+4])
+	}
+	diff := 0
+	for i := 0; i < len(got); i += 4 {
+		for c := 0; c < 3; c++ {
+			if d := int(got[i+c]) - int(want[i+c]); d < -8 || d > 8 {
+				diff++
+				break
+			}
+		}
+	}
+	if n := len(got) / 4; diff > n/100 {
+		t.Fatalf("%d of %d pixels differ by more than 8", diff, n)
+	}
+}
+
+// TestGLTransparentLayers checks that the GPU forward pass peels the same
+// transparent layers as the CPU one draws.
+func TestGLTransparentLayers(t *testing.T) {
+	dev := openGLOrSkip(t)
+	defer dev.Close()
+
+	r := newTransparentRenderer(GPU(dev),
+		transparentPlane{0.2, 0.8, color.RGBA{R: 255, A: 255}, 0.4, color.BlendSrcOver},
+		transparentPlane{0.4, 0.6, color.RGBA{G: 255, A: 255}, 1, color.BlendMultiply},
+	)
+	r.passForward()
+	if !r.passOnGPU("forward") {
+		t.Fatalf("the forward pass did not run on the %v GPU", dev.Driver())
 	}
-	t.Logf("GL deferred render: %d/%d channels differ by >8", nBig, len(cpu.Pix))
+	checkTransparentLayers(t, r)
 }
@@ -9,14 +9,17 @@ package render
 import (
 	"testing"
 
+	"poly.red/buffer"
+	"poly.red/color"
 	"poly.red/gpu"
+	"poly.red/material"
 )
 
 // TestGPUForwardMetal verifies the GPU forward rasterizer runs on the Metal backend
 // (the darwin runtime, as opposed to GL which is the CI oracle): a full Render() with
 // a Metal device must run BOTH the forward and deferred passes on the GPU (not fall
 // back to the CPU) and match the all-CPU render within the measured parity tolerance.
-// It exercises the MSL forward shaders (fwdGBufMSL) and, in particular, pins the
+// It exercises the MSL of the forward stages (kernels.ForwardSrc) and pins the
 // Metal front-facing / back-face-cull convention against the CPU: if it were
 // inverted the bunny would render inside-out and blow past the tolerance.
 func TestGPUForwardMetal(t *testing.T) {
@@ -99,3 +102,88 @@ func TestGPUForwardMetal(t *testing.T) {
 		t.Fatalf("Metal forward+deferred diverges from CPU on %.2f%%@>8; want <8%%", f8*100)
 	}
 }
+
+// TestGPUForwardNormalMap checks that the Metal forward pass perturbs the
+// G-buffer normals by the normal map like the CPU forward pass.
+func TestGPUForwardNormalMap(t *testing.T) {
+	dev, err := gpu.Open(gpu.WithDriver(gpu.DriverMetal))
+	if err != nil {
+		t.Skipf("no Metal device: %v", err)
+	}
+	defer dev.Close()
+
+	const w, h = 64, 64
+	tilt := buffer.NewUniformTexture(color.RGBA{R: 204, G: 128, B: 204, A: 255})
+	s, c := newNormalMapScene(w, h, material.NewPBR(material.NormalMap(tilt)))
+
+	gr := NewRenderer(Scene(s), Camera(c), Size(w, h), MSAA(1), Workers(1), GPU(dev))
+	gb := gr.CurrBuffer()
+	gb.Clear()
+	if err := gr.gpuForwardPass(); err != nil {
+		t.Fatalf("gpuForwardPass on Metal: %v", err)
+	}
+	cr := NewRenderer(Scene(s), Camera(c), Size(w, h), MSAA(1), Workers(1), CPU())
+	cb := cr.CurrBuffer()
+	cb.Clear()
+	cr.cpuForwardPass()
+
+	n := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g, c := gb.UnsafeGet(x, y), cb.UnsafeGet(x, y)
+			if !g.Ok || !c.Ok {
+				continue
+			}
+			n++
+			if d := g.Nor.Sub(c.Nor).Len(); d > 0.01 {
+				t.Fatalf("fragment (%d, %d): GPU normal %v, CPU normal %v", x, y, g.Nor, c.Nor)
+			}
+		}
+	}
+	if n == 0 {
+		t.Fatal("no fragment is rasterized by both passes")
+	}
+}
+
+// TestGPUTransparentMetal verifies that the transparent pass resolves the
+// order-independent and the blend-mode fragments on the Metal backend, over
+// the G-buffer of the GPU forward pass, and matches the all-CPU render.
+func TestGPUTransparentMetal(t *testing.T) {
+	dev, err := gpu.Open(gpu.WithDriver(gpu.DriverMetal))
+	if err != nil {
+		t.Skipf("no Metal device: %v", err)
+	}
+	defer dev.Close()
+
+	planes := []transparentPlane{
+		{0.2, 0.8, color.RGBA{R: 255, A: 255}, 0.5, color.BlendSrcOver},
+		{0.3, 0.6, color.RGBA{R: 128, G: 255, A: 255}, 1, color.BlendMultiply},
+		{0.4, 0.4, color.RGBA{B: 255, A: 255}, 0.6, color.BlendScreen},
+	}
+	got := renderTransparent(GPU(dev), planes...)
+	want := renderTransparent(CPU(), planes...)
+	for i := range want.Pix {
+		if d := int(got.Pix[i]) - int(want.Pix[i]); d < -3 || d > 3 {
+			t.Fatalf("pixel %d: GPU %v, CPU %v", i/4, got.Pix[i&^3:i&^3+4], want.Pix[i&^3:i&^3+4])
+		}
+	}
+}
+
+// TestMetalSampleKernel samples the UV grid by kernels.Sample on the Metal
+// device and requires the colors of buffer.Texture.Query, bit for bit, as
+// TestGLSampleKernel does on GL.
+func TestMetalSampleKernel(t *testing.T) {
+	dev, err := gpu.Open(gpu.WithDriver(gpu.DriverMetal))
+	if err != nil {
+		t.Skipf("no Metal device: %v", err)
+	}
+	defer dev.Close()
+
+	textures := sampleTextures()
+	uvl := sampleUVL(textures)
+	got, err := runSampleKernel(dev, newTextureAtlas(textures), uvl)
+	if err != nil {
+		t.Fatalf("runSampleKernel: %v", err)
+	}
+	checkSampled(t, "Metal", textures, uvl, got)
+}
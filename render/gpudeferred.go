@@ -23,88 +23,122 @@ import (
 // scene; the caller falls back to the CPU shader.
 var errGPUDeferredUnsupported = errors.New("render: scene not supported by GPU deferred path")
 
-// gpuShadowData is the marshaled shadow state for N shadow-casting lights:
-// per-light combined matrices (column-major, 16 floats each) and packed depth
-// maps (dlen floats each), matching render/shadow.go:shadingVisibility.
+// gpuShadowData is the marshaled shadow state for N shadow maps of the
+// shadow-casting lights: per-map combined matrices (column-major, 16 floats)
+// followed by the index of the light that owns the map, the weight of the map
+// and padding (20 floats each), and packed depth maps (dlen floats each),
+// matching render/shadow.go:shadingVisibility. A point light owns six maps,
+// an area light owns four weighted maps.
 type gpuShadowData struct {
-	mats   []float32 // n*16
+	mats   []float32 // n*20
 	depths []float32 // n*dlen
 	width  int
 	dlen   int
 	n      int
+	filter ShadowFilterMode
+	kernel int
 }
 
-// gpuShadowData builds the shadow state for the GPU path. All source lights
-// must cast shadow (the engine's non-casting-light darkening is not modeled
-// here); otherwise returns (nil, false) and the caller falls back to the CPU.
+// gpuShadowData builds the shadow state for the GPU path from the maps of the
+// shadow-casting lights; lights that do not cast shadow own no map and leave
+// the shaded color as is. It returns nil if no light casts shadow.
 // Combined matrix matches shadingVisibility:
 // v.Apply(ScreenToWorld).Apply(lightView).Apply(lightProj).Apply(Viewport).
-func (r *Renderer) gpuShadowData(uniforms *shader.MVP) (*gpuShadowData, bool) {
-	ls, _ := r.cfg.Scene.Lights()
-	if len(r.shadowBufs) == 0 {
-		return nil, false
-	}
-	for i := range r.shadowBufs {
-		if i >= len(ls) || !ls[i].CastShadow() {
-			return nil, false
-		}
-	}
+func (r *Renderer) gpuShadowData(uniforms *shader.MVP) *gpuShadowData {
 	width := r.bufs[0].Bounds().Dx()
-	dlen := len(r.shadowBufs[0].depths)
+	dlen := 0
 	var mats, depths []float32
+	n := 0
 	for i := range r.shadowBufs {
-		sb := &r.shadowBufs[i]
-		m := uniforms.Viewport.
-			MulM(sb.camera.ProjMatrix()).
-			MulM(sb.camera.ViewMatrix()).
-			MulM(uniforms.ViewportToWorld)
-		for j := 0; j < 4; j++ { // column-major
-			for k := 0; k < 4; k++ {
-				mats = append(mats, m.Get(k, j))
+		for _, f := range r.shadowBufs[i].faces {
+			m := uniforms.Viewport.
+				MulM(f.camera.ProjMatrix()).
+				MulM(f.camera.ViewMatrix()).
+				MulM(uniforms.ViewportToWorld)
+			for j := 0; j < 4; j++ { // column-major
+				for k := 0; k < 4; k++ {
+					mats = append(mats, m.Get(k, j))
+				}
 			}
+			mats = append(mats, float32(i), f.weight, 0, 0)
+			depths = append(depths, f.depths...)
+			dlen = len(f.depths)
+			n++
 		}
-		depths = append(depths, sb.depths...)
 	}
-	return &gpuShadowData{mats: mats, depths: depths, width: width, dlen: dlen, n: len(r.shadowBufs)}, true
+	if n == 0 {
+		return nil
+	}
+	return &gpuShadowData{
+		mats: mats, depths: depths, width: width, dlen: dlen, n: n,
+		filter: r.cfg.ShadowFilter, kernel: r.cfg.ShadowKernel,
+	}
 }
 
-// gpuDeferredShade runs the deferred Blinn-Phong shading on the GPU and writes
-// the shaded colours back into buf. Supports point/directional lights +
-// ambient and multiple Blinn-Phong materials (ambient-occlusion off, no shadow
-// map); otherwise returns errGPUDeferredUnsupported and the caller uses the CPU.
-// matAt resolves a flat material index against the per-frame table, returning nil
-// for a negative or out-of-range index (use vertex color).
-func matAt(table []*material.BlinnPhong, id int64) *material.BlinnPhong {
-	if id < 0 || int(id) >= len(table) {
-		return nil
+// uniforms returns the su argument of kernels.Shadow.
+func (sd *gpuShadowData) uniforms() []float32 {
+	return []float32{
+		float32(sd.width), float32(sd.dlen), float32(sd.n),
+		float32(sd.filter), float32(sd.kernel), 0, 0, 0,
 	}
-	return table[id]
 }
 
-func gpuDeferredShade(dev *gpu.Device, buf *buffer.FragmentBuffer, ls []light.Source, es []light.Environment, camPos math.Vec3[float32], bg color.RGBA, shadow *gpuShadowData, matTable []*material.BlinnPhong) error {
+// packLights marshals the light sources into the kernels.Shade light table,
+// 16 floats per light: [type, pos-or-dir.xyzw, color.rgba, intensity,
+// spotdir.xyz, cosInner, cosOuter, range], or the half extents u.xyz, v.xyz in
+// place of the spot parameters for an area light. It returns false if a light
+// type is not supported by the kernel.
+func packLights(ls []light.Source) ([]float32, bool) {
 	var lightData []float32
 	for _, l := range ls {
+		c := l.Color()
 		switch lt := l.(type) {
 		case *light.Point:
 			pos := lt.Position()
-			c := lt.Color()
 			lightData = append(lightData, 0, pos.X, pos.Y, pos.Z, 1,
-				float32(c.R), float32(c.G), float32(c.B), float32(c.A), lt.Intensity())
+				float32(c.R), float32(c.G), float32(c.B), float32(c.A), lt.Intensity(),
+				0, 0, 0, 0, 0, 0)
 		case *light.Directional:
 			d := lt.Dir()
-			c := lt.Color()
 			lightData = append(lightData, 1, d.X, d.Y, d.Z, 0,
-				float32(c.R), float32(c.G), float32(c.B), float32(c.A), lt.Intensity())
+				float32(c.R), float32(c.G), float32(c.B), float32(c.A), lt.Intensity(),
+				0, 0, 0, 0, 0, 0)
+		case *light.Spot:
+			pos, d := lt.Position(), lt.Dir()
+			cosInner, cosOuter := lt.CosCone()
+			lightData = append(lightData, 2, pos.X, pos.Y, pos.Z, 1,
+				float32(c.R), float32(c.G), float32(c.B), float32(c.A), lt.Intensity(),
+				d.X, d.Y, d.Z, cosInner, cosOuter, lt.Range())
+		case *light.Area:
+			pos := lt.Position()
+			u, v := lt.Extents()
+			lightData = append(lightData, 3, pos.X, pos.Y, pos.Z, 1,
+				float32(c.R), float32(c.G), float32(c.B), float32(c.A), lt.Intensity(),
+				u.X, u.Y, u.Z, v.X, v.Y, v.Z)
 		default:
-			return errGPUDeferredUnsupported
+			return nil, false
 		}
 	}
-	if len(ls) == 0 {
-		return errGPUDeferredUnsupported
+	return lightData, true
+}
+
+// gpuDeferredShade runs the deferred shading on the GPU and writes
+// the shaded colours back into buf. Supports point/directional/spot/area lights +
+// ambient, an environment map and multiple Blinn-Phong and metallic-roughness materials in one
+// dispatch; otherwise returns errGPUDeferredUnsupported and the caller uses the CPU.
+// matAt resolves a flat material index against the per-frame table, returning nil
+// for a negative or out-of-range index (use vertex color).
+func matAt(table []material.Material, id int64) material.Material {
+	if id < 0 || int(id) >= len(table) {
+		return nil
 	}
-	var ambientI float32
-	for _, e := range es {
-		ambientI += e.Intensity()
+	return table[id]
+}
+
+func gpuDeferredShade(dev *gpu.Device, buf *buffer.FragmentBuffer, ls []light.Source, es []light.Environment, camPos math.Vec3[float32], bg func(x, y int) color.RGBA, shadow *gpuShadowData, ao *ssaoData, matTable []material.Material) error {
+	lightData, envData, scene, err := deferredScene(ls, es, camPos)
+	if err != nil {
+		return err
 	}
 
 	w := buf.Bounds().Dx()
@@ -115,73 +149,76 @@ func gpuDeferredShade(dev *gpu.Device, buf *buffer.FragmentBuffer, ls []light.So
 	worldpos := make([]float32, n*4)
 	basecol := make([]float32, n*4)
 	matidx := make([]float32, n)
+	surface := make([]float32, n*8) // per-fragment metallic-roughness parameters
 	okMask := make([]bool, n)
 	passthrough := make([]bool, n)
 	passCol := make([]color.RGBA, n)
 	fragxyz := make([]float32, n*4) // screen X,Y,Depth for shadow lookup
 	recv := make([]float32, n)      // per-fragment ReceiveShadow flag
-	aoflag := make([]float32, n)    // per-fragment AmbientOcclusion flag
-	depthbuf := make([]float32, n)  // screen-indexed depth (-1 for non-Ok), for SSAO
-	anyAO := false
 
-	matIndex := map[*material.BlinnPhong]int{}
+	matIndex := map[material.Material]int{}
 	var materials []float32
 	anyShaded := false
 	for y := 0; y < h; y++ {
 		for x := 0; x < w; x++ {
 			idx := y*w + x
 			info := buf.UnsafeGet(x, y)
-			depthbuf[idx] = -1
-			if info.Ok {
-				depthbuf[idx] = info.Depth
-			}
 			if !info.Ok {
 				continue
 			}
-			bp := matAt(matTable, info.MaterialID)
-			if bp == nil {
+			mat := matAt(matTable, info.MaterialID)
+			std := material.StandardOf(mat)
+			if std == nil {
 				okMask[idx] = true
 				passthrough[idx] = true
 				passCol[idx] = info.Col
 				continue
 			}
-			if bp.AmbientOcclusion {
-				aoflag[idx] = 1
-				anyAO = true
+			if m, ok := mat.(*material.BlinnPhong); ok && m.SpecularMap != nil {
+				// The kernel takes the specular color per material.
+				return errGPUDeferredUnsupported
 			}
-			mIdx, seen := matIndex[bp]
+			mIdx, seen := matIndex[mat]
 			if !seen {
 				mIdx = len(matIndex)
-				matIndex[bp] = mIdx
-				materials = append(materials,
-					float32(bp.Diffuse.R), float32(bp.Diffuse.G), float32(bp.Diffuse.B), float32(bp.Diffuse.A),
-					float32(bp.Specular.R), float32(bp.Specular.G), float32(bp.Specular.B), float32(bp.Specular.A),
-					bp.Shininess)
+				matIndex[mat] = mIdx
+				materials = append(materials, packMaterial(mat)...)
 			}
 
 			anyShaded = true
 			okMask[idx] = true
 			matidx[idx] = float32(mIdx)
 			nor := info.Nor
-			if bp.FlatShading {
+			if std.FlatShading {
 				nor = info.FaceNor
 			}
 			normals[idx*4], normals[idx*4+1], normals[idx*4+2], normals[idx*4+3] = nor.X, nor.Y, nor.Z, 0
 			worldpos[idx*4], worldpos[idx*4+1], worldpos[idx*4+2], worldpos[idx*4+3] = info.WordPos.X, info.WordPos.Y, info.WordPos.Z, 1
 			fragxyz[idx*4], fragxyz[idx*4+1], fragxyz[idx*4+2] = float32(info.X), float32(info.Y), info.Depth
-			if bp.ReceiveShadow {
+			if std.ReceiveShadow {
 				recv[idx] = 1
 			}
 
+			if m, ok := mat.(*material.PBR); ok {
+				// Sample the maps of metallic-roughness materials as the
+				// CPU shader does (shader.PBRFragmentShader).
+				sf := m.Surface(info.U, 1-info.V, info.Du, info.Dv)
+				bc := sf.BaseColor.Scale(0xff, 0xff, 0xff, 0xff)
+				basecol[idx*4], basecol[idx*4+1], basecol[idx*4+2], basecol[idx*4+3] = bc.X, bc.Y, bc.Z, bc.W
+				surface[idx*8], surface[idx*8+1], surface[idx*8+2] = sf.Metallic, sf.Roughness, sf.Occlusion
+				surface[idx*8+4], surface[idx*8+5], surface[idx*8+6] = sf.Emissive.X*0xff, sf.Emissive.Y*0xff, sf.Emissive.Z*0xff
+				continue
+			}
+
 			lod := float32(0)
-			if bp.Texture.UseMipmap() {
-				siz := float32(bp.Texture.Size()) * math.Sqrt(math.Max(info.Du, info.Dv))
+			if std.Texture.UseMipmap() {
+				siz := float32(std.Texture.Size()) * math.Sqrt(math.Max(info.Du, info.Dv))
 				if siz < 1 {
 					siz = 1
 				}
 				lod = math.Log2(siz)
 			}
-			bc := bp.Texture.Query(lod, info.U, 1-info.V)
+			bc := std.Texture.Query(lod, info.U, 1-info.V)
 			basecol[idx*4], basecol[idx*4+1], basecol[idx*4+2], basecol[idx*4+3] = float32(bc.R), float32(bc.G), float32(bc.B), float32(bc.A)
 		}
 	}
@@ -189,29 +226,26 @@ func gpuDeferredShade(dev *gpu.Device, buf *buffer.FragmentBuffer, ls []light.So
 		return errGPUDeferredUnsupported
 	}
 
-	scene := []float32{camPos.X, camPos.Y, camPos.Z, 1, ambientI, float32(len(ls)), 0, 0}
-
-	shaded, err := runDeferredKernel(dev, n, normals, worldpos, basecol, lightData, matidx, materials, scene)
+	shaded, err := runDeferredKernel(dev, n, normals, worldpos, basecol, lightData, matidx, materials, surface, scene, envData)
 	if err != nil {
 		return err
 	}
 
 	if debugDeferredSelfCheck {
-		deferredSelfCheck(n, okMask, passthrough, normals, worldpos, basecol, lightData, matidx, materials, scene, shaded)
+		deferredSelfCheck(n, okMask, passthrough, normals, worldpos, basecol, lightData, matidx, materials, surface, scene, envData, shaded)
 	}
 
 	// Apply shadows as a second pass over the shaded float buffer.
 	if shadow != nil {
-		su := []float32{float32(shadow.width), float32(shadow.dlen), float32(shadow.n), 0}
+		su := shadow.uniforms()
 		if err := runShadowKernel(dev, n, fragxyz, recv, shadow.depths, shadow.mats, shaded, su); err != nil {
 			return err
 		}
 	}
 
 	// Apply SSAO as a final pass.
-	if anyAO {
-		au := []float32{float32(w), float32(h), 0, 0}
-		if err := runAOKernel(dev, n, fragxyz, aoflag, depthbuf, shaded, au); err != nil {
+	if ao != nil {
+		if err := runAOKernel(dev, n, ao, shaded); err != nil {
 			return err
 		}
 	}
@@ -231,7 +265,7 @@ func gpuDeferredShade(dev *gpu.Device, buf *buffer.FragmentBuffer, ls []light.So
 					A: toByte(shaded[idx*4+3]),
 				}
 			default:
-				info.Col = bg
+				info.Col = bg(x, y)
 			}
 			buf.UnsafeSet(x, y, info)
 		}
@@ -239,11 +273,69 @@ func gpuDeferredShade(dev *gpu.Device, buf *buffer.FragmentBuffer, ls []light.So
 	return nil
 }
 
+// packEnvMap packs the prefiltered maps of the given environment map as
+// the env buffer of kernels.Shade, or an empty environment map if nil.
+// deferredScene packs the lights, the environment map and the scene
+// arguments of kernels.Shade, or returns errGPUDeferredUnsupported if the
+// kernel cannot shade the lights.
+func deferredScene(ls []light.Source, es []light.Environment, camPos math.Vec3[float32]) (lights, env, scene []float32, err error) {
+	lights, ok := packLights(ls)
+	if !ok {
+		return nil, nil, nil, errGPUDeferredUnsupported
+	}
+	em := shader.EnvMapOf(es)
+	if len(ls) == 0 && em == nil {
+		return nil, nil, nil, errGPUDeferredUnsupported
+	}
+	var ambientI float32
+	for _, e := range es {
+		if _, ok := e.(*light.EnvMap); ok {
+			continue
+		}
+		ambientI += e.Intensity()
+	}
+	scene = []float32{camPos.X, camPos.Y, camPos.Z, 1, ambientI, float32(len(ls)), 0, 0}
+	return lights, packEnvMap(em), scene, nil
+}
+
+// packMaterial returns the 10 floats of a material of kernels.Shade.
+func packMaterial(m material.Material) []float32 {
+	switch m := m.(type) {
+	case *material.BlinnPhong:
+		return []float32{
+			float32(m.Diffuse.R), float32(m.Diffuse.G), float32(m.Diffuse.B), float32(m.Diffuse.A),
+			float32(m.Specular.R), float32(m.Specular.G), float32(m.Specular.B), float32(m.Specular.A),
+			m.Shininess, 0,
+		}
+	case *material.PBR:
+		return []float32{0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	}
+	return make([]float32, 10)
+}
+
+func packEnvMap(env *light.EnvMap) []float32 {
+	if env == nil {
+		return []float32{0, 0, 0, 0}
+	}
+	irr, spec := env.Maps()
+	maps := append([]*buffer.HDR{irr}, spec...)
+	data := []float32{float32(len(spec)), env.Intensity(), 0, 0}
+	off := len(data) + 4*len(maps)
+	for _, m := range maps {
+		data = append(data, float32(off), float32(m.Width), float32(m.Height), 0)
+		off += len(m.Pix)
+	}
+	for _, m := range maps {
+		data = append(data, m.Pix...)
+	}
+	return data
+}
+
 func toByte(v float32) uint8 {
 	return uint8(math.Clamp(float32(math.Round(v)), 0, 255))
 }
 
-func runDeferredKernel(dev *gpu.Device, n int, normals, worldpos, basecol, lights, matidx, materials, scene []float32) ([]float32, error) {
+func runDeferredKernel(dev *gpu.Device, n int, normals, worldpos, basecol, lights, matidx, materials, surface, scene, env []float32) ([]float32, error) {
 	mod, err := kernelModule(dev, kernels.ShadeSrc, "Shade")
 	if err != nil {
 		return nil, err
@@ -252,7 +344,7 @@ func runDeferredKernel(dev *gpu.Device, n int, normals, worldpos, basecol, light
 		return gpu.BindGroupLayoutEntry{Binding: i, Visibility: gpu.StageCompute, Kind: gpu.StorageBuffer}
 	}
 	layout := dev.NewBindGroupLayout(
-		sb(0), sb(1), sb(2), sb(3), sb(4), sb(5), sb(6), sb(7),
+		sb(0), sb(1), sb(2), sb(3), sb(4), sb(5), sb(6), sb(7), sb(8), sb(9),
 	)
 	pipe, err := dev.NewComputePipeline(gpu.ComputePipelineDescriptor{Layout: dev.NewPipelineLayout(layout), Module: mod, Entry: "Shade"})
 	if err != nil {
@@ -263,7 +355,7 @@ func runDeferredKernel(dev *gpu.Device, n int, normals, worldpos, basecol, light
 		lights = []float32{0}
 	}
 	if len(materials) == 0 {
-		materials = make([]float32, 9)
+		materials = make([]float32, 10)
 	}
 	nb := storageBuf(dev, normals)
 	wb := storageBuf(dev, worldpos)
@@ -271,7 +363,9 @@ func runDeferredKernel(dev *gpu.Device, n int, normals, worldpos, basecol, light
 	lb := storageBuf(dev, lights)
 	mib := storageBuf(dev, matidx)
 	mtb := storageBuf(dev, materials)
+	sfb := storageBuf(dev, surface)
 	scb := storageBuf(dev, scene)
+	eb := storageBuf(dev, env)
 	out, err := dev.NewBuffer(gpu.BufferDescriptor{Size: n * 4 * 4, Usage: gpu.BufferStorage | gpu.BufferMapRead})
 	if err != nil {
 		return nil, err
@@ -283,7 +377,9 @@ func runDeferredKernel(dev *gpu.Device, n int, normals, worldpos, basecol, light
 		lb.Release()
 		mib.Release()
 		mtb.Release()
+		sfb.Release()
 		scb.Release()
+		eb.Release()
 		out.Release()
 	}()
 
@@ -294,8 +390,10 @@ func runDeferredKernel(dev *gpu.Device, n int, normals, worldpos, basecol, light
 		gpu.BindGroupEntry{Binding: 3, Buffer: lb},
 		gpu.BindGroupEntry{Binding: 4, Buffer: mib},
 		gpu.BindGroupEntry{Binding: 5, Buffer: mtb},
-		gpu.BindGroupEntry{Binding: 6, Buffer: scb},
-		gpu.BindGroupEntry{Binding: 7, Buffer: out},
+		gpu.BindGroupEntry{Binding: 6, Buffer: sfb},
+		gpu.BindGroupEntry{Binding: 7, Buffer: scb},
+		gpu.BindGroupEntry{Binding: 8, Buffer: eb},
+		gpu.BindGroupEntry{Binding: 9, Buffer: out},
 	)
 	enc := dev.NewCommandEncoder()
 	cp := enc.BeginComputePass()
@@ -370,11 +468,17 @@ func runShadowKernel(dev *gpu.Device, n int, fragxyz, recv, depths, mats, color,
 	return nil
 }
 
-func runAOKernel(dev *gpu.Device, n int, fragxyz, aoflag, depthbuf, color, au []float32) error {
+// runAOKernel runs the ambient occlusion kernels.AO and its bilateral blur
+// kernels.AOBlur, which darkens the shaded colors in place.
+func runAOKernel(dev *gpu.Device, n int, ao *ssaoData, color []float32) error {
 	mod, err := kernelModule(dev, kernels.AOSrc, "AO")
 	if err != nil {
 		return err
 	}
+	blurMod, err := kernelModule(dev, kernels.AOBlurSrc, "AOBlur")
+	if err != nil {
+		return err
+	}
 	sb := func(i int) gpu.BindGroupLayoutEntry {
 		return gpu.BindGroupLayoutEntry{Binding: i, Visibility: gpu.StageCompute, Kind: gpu.StorageBuffer}
 	}
@@ -385,31 +489,61 @@ func runAOKernel(dev *gpu.Device, n int, fragxyz, aoflag, depthbuf, color, au []
 	if err != nil {
 		return err
 	}
-	fb := storageBuf(dev, fragxyz)
-	ab := storageBuf(dev, aoflag)
-	db := storageBuf(dev, depthbuf)
+	blurPipe, err := dev.NewComputePipeline(gpu.ComputePipelineDescriptor{Layout: dev.NewPipelineLayout(layout), Module: blurMod, Entry: "AOBlur"})
+	if err != nil {
+		return err
+	}
+	pb := storageBuf(dev, ao.viewpos)
+	nb := storageBuf(dev, ao.viewnor)
+	fb := storageBuf(dev, ao.aoflag)
+	ab, err := dev.NewBuffer(gpu.BufferDescriptor{Size: n * 4, Usage: gpu.BufferStorage})
+	if err != nil {
+		return err
+	}
 	cb, err := dev.NewBuffer(gpu.BufferDescriptor{Size: len(color) * 4, Usage: gpu.BufferStorage | gpu.BufferCopyDst | gpu.BufferMapRead, Data: deferredBytes(color)})
 	if err != nil {
 		return err
 	}
-	ub := storageBuf(dev, au)
-	defer func() { fb.Release(); ab.Release(); db.Release(); cb.Release(); ub.Release() }()
+	ub := storageBuf(dev, ao.uniforms())
+	defer func() {
+		pb.Release()
+		nb.Release()
+		fb.Release()
+		ab.Release()
+		cb.Release()
+		ub.Release()
+	}()
 
 	bg := dev.NewBindGroup(layout,
-		gpu.BindGroupEntry{Binding: 0, Buffer: fb},
-		gpu.BindGroupEntry{Binding: 1, Buffer: ab},
-		gpu.BindGroupEntry{Binding: 2, Buffer: db},
+		gpu.BindGroupEntry{Binding: 0, Buffer: pb},
+		gpu.BindGroupEntry{Binding: 1, Buffer: nb},
+		gpu.BindGroupEntry{Binding: 2, Buffer: fb},
+		gpu.BindGroupEntry{Binding: 3, Buffer: ab},
+		gpu.BindGroupEntry{Binding: 4, Buffer: ub},
+	)
+	blurBg := dev.NewBindGroup(layout,
+		gpu.BindGroupEntry{Binding: 0, Buffer: pb},
+		gpu.BindGroupEntry{Binding: 1, Buffer: fb},
+		gpu.BindGroupEntry{Binding: 2, Buffer: ab},
 		gpu.BindGroupEntry{Binding: 3, Buffer: cb},
 		gpu.BindGroupEntry{Binding: 4, Buffer: ub},
 	)
-	enc := dev.NewCommandEncoder()
-	cp := enc.BeginComputePass()
-	cp.SetPipeline(pipe)
-	cp.SetBindGroup(0, bg)
-	cp.Dispatch(n, 1, 1)
-	cp.End()
-	dev.Queue().Submit(enc.Finish())
-	dev.Queue().WaitIdle()
+
+	// The blur reads the factors of the neighbors, hence it runs after
+	// all of them are written.
+	for _, p := range []struct {
+		pipe *gpu.ComputePipeline
+		bg   *gpu.BindGroup
+	}{{pipe, bg}, {blurPipe, blurBg}} {
+		enc := dev.NewCommandEncoder()
+		cp := enc.BeginComputePass()
+		cp.SetPipeline(p.pipe)
+		cp.SetBindGroup(0, p.bg)
+		cp.Dispatch(n, 1, 1)
+		cp.End()
+		dev.Queue().Submit(enc.Finish())
+		dev.Queue().WaitIdle()
+	}
 	copy(color, unsafe.Slice((*float32)(unsafe.Pointer(&cb.Bytes()[0])), len(color)))
 	return nil
 }
@@ -443,13 +577,13 @@ var deferredSelfCheckResult selfCheckResult
 // G-buffer and compares it to the GPU output. Because the GPU shader is compiled
 // from the same source (kernels.ShadeSrc), this proves the compiler lowering:
 // GPU(ShadeSrc) == kernels.Shade-as-Go for every shaded fragment.
-func deferredSelfCheck(n int, okMask, passthrough []bool, normals, worldpos, basecol, lights, matidx, materials, scene []float32, gpu []float32) {
+func deferredSelfCheck(n int, okMask, passthrough []bool, normals, worldpos, basecol, lights, matidx, materials, surface, scene, env []float32, gpu []float32) {
 	replica := make([]float32, len(gpu))
 	for idx := 0; idx < n; idx++ {
 		if !okMask[idx] || passthrough[idx] {
 			continue
 		}
-		kernels.Shade(uint(idx), normals, worldpos, basecol, lights, matidx, materials, scene, replica)
+		kernels.Shade(uint(idx), normals, worldpos, basecol, lights, matidx, materials, surface, scene, env, replica)
 	}
 	for idx := 0; idx < n; idx++ {
 		if !okMask[idx] || passthrough[idx] {
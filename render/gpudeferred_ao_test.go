@@ -21,9 +21,9 @@ import (
 )
 
 // TestGPUDeferredAO offloads screen-space ambient occlusion to the GPU. The
-// engine's SSAO ends in pow(total, 10000), which amplifies any GPU/CPU float
-// difference (atan/cos/sin), so exact parity is not expected; this asserts the
-// images are *close* (and reports the actual max diff).
+// GPU and CPU sample positions differ in the last bits (cos/sin), which may
+// move a sample to a neighbor pixel, so exact parity is not expected; this
+// asserts the images are *close* (and reports the actual max diff).
 func TestGPUDeferredAO(t *testing.T) {
 	dev, err := gpu.Open()
 	if err != nil {
@@ -58,7 +58,7 @@ func TestGPUDeferredAO(t *testing.T) {
 		t.Fatal("GPU deferred path not exercised (AO)")
 	}
 
-	// SSAO's pow(.,10000) makes a handful of contour pixels diverge; the helper
-	// tolerates a tiny fraction of large diffs.
+	// A handful of contour pixels may diverge; the helper tolerates a tiny
+	// fraction of large diffs.
 	assertDeferredClose(t, cpu.Pix, gpuImg.Pix, "SSAO")
 }
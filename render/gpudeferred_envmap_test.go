@@ -0,0 +1,67 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+//go:build darwin
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/camera"
+	"poly.red/gpu"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/scene"
+)
+
+// TestGPUDeferredEnvMap shades a Blinn-Phong bunny on a metallic-roughness
+// plane lit only by an environment map on the GPU, and checks it against the
+// author-once kernel and the CPU, including the environment background.
+func TestGPUDeferredEnvMap(t *testing.T) {
+	dev, err := gpu.Open()
+	if err != nil {
+		t.Skipf("no GPU device: %v", err)
+	}
+	defer dev.Close()
+
+	const w, h = 128, 128
+	s := scene.NewScene(newSkyEnvMap(light.Intensity(1.5)))
+	bunny := model.MustLoad("../internal/testdata/bunny.obj")
+	bunny.Scale(2, 2, 2)
+	s.Add(bunny)
+	s.Add(newPBRPlane(1.5, material.NewPBR(
+		material.BaseColor(color.RGBA{R: 250, G: 200, B: 120, A: 255}),
+		material.Metallic(1), material.Roughness(0.3),
+	)))
+
+	cam := camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 0.6, 0.9)),
+		camera.LookAt(math.NewVec3[float32](0, 0.1, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(60, float32(w)/float32(h), 0.1, 3),
+	)
+	opts := []Option{Camera(cam), Size(w, h), MSAA(1), Scene(s), Workers(1), BatchSize(1)}
+
+	cpu := NewRenderer(append(opts, CPU())...).Render()
+
+	debugDeferredSelfCheck = true
+	deferredSelfCheckResult = selfCheckResult{}
+	defer func() { debugDeferredSelfCheck = false }()
+	gr := NewRenderer(append(opts, GPU(dev), forwardOnCPU())...)
+	gpuImg := gr.Render()
+	if !gr.passOnGPU("deferred") {
+		t.Fatal("GPU deferred path not exercised (environment map)")
+	}
+	if !deferredSelfCheckResult.ran {
+		t.Fatal("deferred self-check did not run")
+	}
+	if !deferredSelfCheckResult.matched {
+		t.Fatalf("GPU deferred != author-once kernels.Shade: %s", deferredSelfCheckResult.detail)
+	}
+
+	assertDeferredClose(t, cpu.Pix, gpuImg.Pix, "environment map")
+}
@@ -0,0 +1,47 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+//go:build darwin
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/gpu"
+)
+
+// TestGPUDeferredMixedMaterials shades Blinn-Phong and metallic-roughness
+// materials in one GPU dispatch and compares it to the CPU shaders.
+func TestGPUDeferredMixedMaterials(t *testing.T) {
+	dev, err := gpu.Open()
+	if err != nil {
+		t.Skipf("no GPU device: %v", err)
+	}
+	defer dev.Close()
+
+	const w, h = 160, 160
+	s, cam := newMixedMaterialScene(w, h)
+	opts := []Option{Camera(cam), Size(w, h), MSAA(1), Scene(s), Background(color.RGBA{R: 0, G: 127, B: 255, A: 255}), Workers(1), BatchSize(1)}
+
+	cpu := NewRenderer(append(opts, CPU())...).Render()
+
+	debugDeferredSelfCheck = true
+	deferredSelfCheckResult = selfCheckResult{}
+	defer func() { debugDeferredSelfCheck = false }()
+	gr := NewRenderer(append(opts, GPU(dev), forwardOnCPU())...)
+	gpuImg := gr.Render()
+	if !gr.passOnGPU("deferred") {
+		t.Fatal("GPU deferred path not exercised (mixed materials)")
+	}
+	if !deferredSelfCheckResult.ran {
+		t.Fatal("deferred self-check did not run")
+	}
+	if !deferredSelfCheckResult.matched {
+		t.Fatalf("GPU deferred != author-once kernels.Shade: %s", deferredSelfCheckResult.detail)
+	}
+
+	assertDeferredClose(t, cpu.Pix, gpuImg.Pix, "mixed materials")
+}
@@ -21,8 +21,8 @@ import (
 )
 
 // TestGPUDeferredShadow renders a shadow-mapped scene (one shadow-casting light,
-// ReceiveShadow materials) on the CPU vs the GPU deferred path and asserts the
-// images match. The GPU path applies the shadow factor in a second compute pass
+// ReceiveShadow materials) on the CPU vs the GPU deferred path, for every shadow
+// filter, and asserts the images match. The GPU path applies the shadow factor in a second compute pass
 // over the shaded buffer (render/gpudeferred.go shadowKernel).
 func TestGPUDeferredShadow(t *testing.T) {
 	dev, err := gpu.Open()
@@ -53,17 +53,28 @@ func TestGPUDeferredShadow(t *testing.T) {
 		camera.ViewFrustum(45, float32(w)/float32(h), 0.1, 2),
 	)
 
-	opts := []Option{
-		Camera(cam), Size(w, h), MSAA(1), Scene(s), ShadowMap(true),
-		Background(color.RGBA{R: 0, G: 127, B: 255, A: 255}), Workers(1), BatchSize(1),
-	}
+	for _, tt := range []struct {
+		name   string
+		filter ShadowFilterMode
+		kernel int
+	}{
+		{"shadow", ShadowFilterHard, 0},
+		{"shadow-pcf", ShadowFilterPCF, 2},
+		{"shadow-pcss", ShadowFilterPCSS, 4},
+	} {
+		opts := []Option{
+			Camera(cam), Size(w, h), MSAA(1), Scene(s), ShadowMap(true),
+			ShadowFilter(tt.filter, tt.kernel),
+			Background(color.RGBA{R: 0, G: 127, B: 255, A: 255}), Workers(1), BatchSize(1),
+		}
 
-	cpu := NewRenderer(append(opts, CPU())...).Render()
-	gr := NewRenderer(append(opts, GPU(dev), forwardOnCPU())...)
-	gpuImg := gr.Render()
-	if !gr.passOnGPU("deferred") {
-		t.Fatal("GPU deferred path not exercised (shadow)")
-	}
+		cpu := NewRenderer(append(opts, CPU())...).Render()
+		gr := NewRenderer(append(opts, GPU(dev), forwardOnCPU())...)
+		gpuImg := gr.Render()
+		if !gr.passOnGPU("deferred") {
+			t.Fatalf("GPU deferred path not exercised (%s)", tt.name)
+		}
 
-	assertDeferredClose(t, cpu.Pix, gpuImg.Pix, "shadow")
+		assertDeferredClose(t, cpu.Pix, gpuImg.Pix, tt.name)
+	}
 }
@@ -0,0 +1,377 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	stdmath "math"
+	"sync/atomic"
+	"unsafe"
+
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/geometry/primitive"
+	"poly.red/gpu"
+	"poly.red/gpu/shader/gpumath/kernels"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/shader"
+)
+
+// deviceGBuffer is the G-buffer of the GPU forward pass while it is still on
+// the device, in the render targets of the pass: wp holds the world position
+// and depth, nr the normal and material index (noFragment if none), uv the
+// texture coordinates and their screen space derivatives, and tn the world
+// tangent, or the vertex color of a fragment without material. All of them
+// run bottom-up, in the row order of the buffer.
+//
+// The deferred pass reads wp, nr and uv in place (gpuDeferredResident), and
+// readbackGBuffer reads the targets back into the fragment buffer only if a
+// pass needs the fragments on the CPU.
+type deviceGBuffer struct {
+	buf            *buffer.FragmentBuffer
+	wp, nr, uv, tn *gpu.Texture
+	coverage       []byte // the MSAA coverage of each pixel, or nil
+}
+
+// readbackGBuffer reads the G-buffer of the GPU forward pass back into the
+// fragment buffer, if it is still on the device. It keeps the colors that
+// the deferred pass may have shaded on the device.
+func (r *Renderer) readbackGBuffer() {
+	g := r.gbuf
+	if g == nil {
+		return
+	}
+	r.gbuf = nil
+	r.readGBuffer(g)
+}
+
+// readGBuffer reads the targets of the G-buffer g into its fragment buffer
+// and returns the number of fragments they hold.
+func (r *Renderer) readGBuffer(g *deviceGBuffer) int {
+	buf := g.buf
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	wp := floats32(g.wp.ReadPixels())
+	nr := floats32(g.nr.ReadPixels())
+	uv := floats32(g.uv.ReadPixels())
+	tn := floats32(g.tn.ReadPixels())
+	// Render-target texture readback follows GL's bottom-left origin: source row r is
+	// screen row h-1-r. The FragmentBuffer (like the CPU pass) is top-down, so read
+	// the mirrored row when writing each (x, y). (The deferred pass reads a compute
+	// SSBO, which is not flipped, hence only the render path needs this.)
+	count := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			idx := ((h-1-y)*w + x) * 4
+			if nr[idx+3] < noFragment+0.5 { // no fragment written
+				continue
+			}
+			count++
+			matID := int64(stdmath.Round(float64(nr[idx+3])))
+			n := math.Vec4[float32]{X: nr[idx], Y: nr[idx+1], Z: nr[idx+2], W: 0}
+			if std := material.StandardOf(r.material(matID)); std != nil && std.NormalMap != nil {
+				t := math.Vec4[float32]{X: tn[idx], Y: tn[idx+1], Z: tn[idx+2], W: tn[idx+3]}
+				n = std.PerturbNormal(n, t, uv[idx], 1-uv[idx+1], uv[idx+2], uv[idx+3])
+			}
+			if g.coverage != nil {
+				r.gpuCoverageEdge(x, y, g.coverage[idx])
+			}
+			col := buf.UnsafeGet(x, y).Col
+			if matID < 0 { // materialless: the vertex color in place of the tangent
+				col = color.RGBA{
+					R: toByte(tn[idx] * 0xff), G: toByte(tn[idx+1] * 0xff),
+					B: toByte(tn[idx+2] * 0xff), A: toByte(tn[idx+3] * 0xff),
+				}
+			}
+			buf.Set(x, y, buffer.Fragment{
+				Ok: true,
+				Fragment: primitive.Fragment{
+					X:          x,
+					Y:          y,
+					Depth:      wp[idx+3],
+					U:          uv[idx],
+					V:          uv[idx+1],
+					Du:         uv[idx+2],
+					Dv:         uv[idx+3],
+					Nor:        n,
+					WordPos:    math.Vec4[float32]{X: wp[idx], Y: wp[idx+1], Z: wp[idx+2], W: 1},
+					MaterialID: matID,
+					Col:        col,
+				},
+			})
+		}
+	}
+	return count
+}
+
+// keepGBuffer reports whether the G-buffer of the GPU forward pass can stay
+// on the device for the deferred pass of the frame being rendered. That
+// needs a device whose compute kernels load storage textures, and no pass
+// of the frame that needs the fragments on the CPU: the multisample
+// resolve, the temporal antialiasing and the transparent pass do, and so do
+// vertex colors and the materials that gbufferMaterial rejects.
+func (r *Renderer) keepGBuffer(objs []forwardObject) bool {
+	if atomic.LoadUint32(&r.running) == 0 || r.cfg.gbufReadback || debugDeferredSelfCheck ||
+		!r.cfg.GPUDevice.StorageTextures() ||
+		msaaSamples(r.cfg.MSAA) > 1 || r.cfg.Antialiasing == AntialiasingTAA {
+		return false
+	}
+	for _, m := range r.matTable {
+		if transparent(m) {
+			return false
+		}
+		if _, _, ok := gbufferMaterial(m); !ok {
+			return false
+		}
+	}
+	for _, o := range objs {
+		for _, id := range o.mid {
+			if id < 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// gbufferMaterial returns the 16 floats of a material of kernels.GBuffer
+// and the texture that kernels.Sample samples for its base color, if any, or
+// false if the material varies over the surface in a way the kernels do not
+// model: normal maps, specular maps, flat shading and the maps of
+// metallic-roughness materials that are not uniform (1x1, such as
+// buffer.NewUniformTexture).
+// The caller fills in the texture's index in the atlas.
+func gbufferMaterial(m material.Material) ([]float32, *buffer.Texture, bool) {
+	std := material.StandardOf(m)
+	if std == nil || std.FlatShading || std.NormalMap != nil {
+		return nil, nil, false
+	}
+	e := make([]float32, 16)
+	if std.ReceiveShadow {
+		e[0] = 1
+	}
+	if std.AmbientOcclusion {
+		e[1] = 1
+	}
+	e[2] = -1
+	switch m := m.(type) {
+	case *material.BlinnPhong:
+		if m.Texture == nil || m.SpecularMap != nil {
+			return nil, nil, false
+		}
+		if m.Texture.Size() != 1 {
+			if m.Texture.UseMipmap() {
+				e[3] = float32(m.Texture.Size())
+			}
+			return e, m.Texture, true
+		}
+		c := m.Texture.Query(0, 0, 0)
+		e[4], e[5], e[6], e[7] = float32(c.R), float32(c.G), float32(c.B), float32(c.A)
+	case *material.PBR:
+		for _, t := range []*buffer.Texture{m.Texture, m.MetallicRoughnessMap, m.OcclusionMap, m.EmissiveMap} {
+			if t != nil && t.Size() != 1 {
+				return nil, nil, false
+			}
+		}
+		sf := m.Surface(0, 0, 0, 0)
+		bc := sf.BaseColor.Scale(0xff, 0xff, 0xff, 0xff)
+		e[4], e[5], e[6], e[7] = bc.X, bc.Y, bc.Z, bc.W
+		e[8], e[9], e[10] = sf.Metallic, sf.Roughness, sf.Occlusion
+		e[12], e[13], e[14] = sf.Emissive.X*0xff, sf.Emissive.Y*0xff, sf.Emissive.Z*0xff
+	default:
+		return nil, nil, false
+	}
+	return e, nil, true
+}
+
+// gpuDeferredResident shades the G-buffer that the GPU forward pass left on
+// the device: kernels.GBuffer unpacks its render targets into the inputs of
+// the deferred, shadow and ambient occlusion kernels, kernels.Sample samples
+// the textures of the materials from the mipmap atlas, and all of them run
+// in one command buffer, from which only the shaded colors are read back.
+func (r *Renderer) gpuDeferredResident(ls []light.Source, es []light.Environment, shadow *gpuShadowData, uniforms *shader.MVP) error {
+	dev := r.cfg.GPUDevice
+	g := r.gbuf
+	buf := g.buf
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	n := w * h
+
+	lights, env, scene, err := deferredScene(ls, es, r.cfg.Camera.Position())
+	if err != nil {
+		return err
+	}
+	if len(lights) == 0 {
+		lights = []float32{0}
+	}
+	var mats, materials []float32
+	var textures []*buffer.Texture
+	anyAO := false
+	for _, m := range r.matTable {
+		e, t, _ := gbufferMaterial(m)
+		if t != nil {
+			i := 0
+			for i < len(textures) && textures[i] != t {
+				i++
+			}
+			if i == len(textures) {
+				textures = append(textures, t)
+			}
+			e[2] = float32(i)
+		}
+		mats = append(mats, e...)
+		materials = append(materials, packMaterial(m)...)
+		anyAO = anyAO || e[1] > 0
+	}
+	if len(mats) == 0 {
+		return errGPUDeferredUnsupported
+	}
+	if len(textures) > 0 && !r.atlas.same(textures) {
+		r.atlas = newTextureAtlas(textures)
+	}
+	view := colMajorMat4(uniforms.View)
+	screenToView := colMajorMat4(uniforms.View.MulM(uniforms.ViewportToWorld))
+	gu := append([]float32{float32(w), float32(h), 0, 0}, view[:]...)
+	gu = append(gu, screenToView[:]...)
+
+	var bufs []*gpu.Buffer
+	defer func() {
+		for _, b := range bufs {
+			b.Release()
+		}
+	}()
+	upload := func(d []float32) *gpu.Buffer {
+		b := storageBuf(dev, d)
+		bufs = append(bufs, b)
+		return b
+	}
+	alloc := func(size int, usage gpu.BufferUsage) (*gpu.Buffer, error) {
+		b, err := dev.NewBuffer(gpu.BufferDescriptor{Size: size * 4, Usage: usage})
+		if err != nil {
+			return nil, err
+		}
+		bufs = append(bufs, b)
+		return b, nil
+	}
+	// The outputs of kernels.GBuffer, in the order of its arguments.
+	var gb [11]*gpu.Buffer
+	for i, size := range []int{n * 4, n * 4, n * 4, n, n * 8, n * 4, n, n * 4, n * 4, n, n * 4} {
+		if gb[i], err = alloc(size, gpu.BufferStorage); err != nil {
+			return err
+		}
+	}
+	normals, worldpos, basecol, matidx, surface := gb[0], gb[1], gb[2], gb[3], gb[4]
+	fragxyz, recv, viewpos, viewnor, aoflag, uvl := gb[5], gb[6], gb[7], gb[8], gb[9], gb[10]
+	shaded, err := alloc(n*4, gpu.BufferStorage|gpu.BufferMapRead)
+	if err != nil {
+		return err
+	}
+
+	enc := dev.NewCommandEncoder()
+	err = encodeKernel(dev, enc, kernels.GBufferSrc, "GBuffer", n, []*gpu.Texture{g.wp, g.nr, g.uv},
+		append([]*gpu.Buffer{upload(mats), upload(gu)}, gb[:]...)...)
+	if err != nil {
+		return err
+	}
+	if len(textures) > 0 {
+		err = encodeKernel(dev, enc, kernels.SampleSrc, "Sample", n, nil, upload(r.atlas.atlas), upload(r.atlas.texs), uvl, basecol)
+		if err != nil {
+			return err
+		}
+	}
+	err = encodeKernel(dev, enc, kernels.ShadeSrc, "Shade", n, nil,
+		normals, worldpos, basecol, upload(lights), matidx, upload(materials), surface, upload(scene), upload(env), shaded)
+	if err != nil {
+		return err
+	}
+	if shadow != nil {
+		depths, sm := shadow.depths, shadow.mats
+		if len(depths) == 0 {
+			depths = []float32{0}
+		}
+		if len(sm) == 0 {
+			sm = []float32{0}
+		}
+		err = encodeKernel(dev, enc, kernels.ShadowSrc, "Shadow", n, nil,
+			fragxyz, recv, upload(depths), upload(sm), shaded, upload(shadow.uniforms()))
+		if err != nil {
+			return err
+		}
+	}
+	if anyAO {
+		ao := &ssaoData{
+			width:        w,
+			height:       h,
+			viewToScreen: uniforms.Viewport.MulM(uniforms.Proj),
+			radius:       r.cfg.AORadius,
+			samples:      r.cfg.AOSamples,
+			strength:     r.cfg.AOStrength,
+		}
+		au := upload(ao.uniforms())
+		factors, err := alloc(n, gpu.BufferStorage)
+		if err != nil {
+			return err
+		}
+		if err := encodeKernel(dev, enc, kernels.AOSrc, "AO", n, nil, viewpos, viewnor, aoflag, factors, au); err != nil {
+			return err
+		}
+		if err := encodeKernel(dev, enc, kernels.AOBlurSrc, "AOBlur", n, nil, viewpos, aoflag, factors, shaded, au); err != nil {
+			return err
+		}
+	}
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	// A negative alpha marks a pixel without a fragment, see kernels.GBuffer.
+	out := unsafe.Slice((*float32)(unsafe.Pointer(&shaded.Bytes()[0])), n*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			idx := (y*w + x) * 4
+			info := buf.UnsafeGet(x, y)
+			if out[idx+3] < 0 {
+				info.Col = r.background(x, y, uniforms)
+			} else {
+				info.Col = color.RGBA{
+					R: toByte(out[idx]),
+					G: toByte(out[idx+1]),
+					B: toByte(out[idx+2]),
+					A: toByte(out[idx+3]),
+				}
+			}
+			buf.UnsafeSet(x, y, info)
+		}
+	}
+	return nil
+}
+
+// encodeKernel encodes a compute pass that runs the kernel entry of src
+// once per element of n, with the images bound to the texture indices and
+// the buffers to the buffer bindings in order. The passes of an encoder run
+// in order, each seeing the writes of the previous ones.
+func encodeKernel(dev *gpu.Device, enc *gpu.CommandEncoder, src, entry string, n int, images []*gpu.Texture, bufs ...*gpu.Buffer) error {
+	mod, err := kernelModule(dev, src, entry)
+	if err != nil {
+		return err
+	}
+	entries := make([]gpu.BindGroupLayoutEntry, len(bufs))
+	group := make([]gpu.BindGroupEntry, len(bufs))
+	for i, b := range bufs {
+		entries[i] = gpu.BindGroupLayoutEntry{Binding: i, Visibility: gpu.StageCompute, Kind: gpu.StorageBuffer}
+		group[i] = gpu.BindGroupEntry{Binding: i, Buffer: b}
+	}
+	layout := dev.NewBindGroupLayout(entries...)
+	pipe, err := dev.NewComputePipeline(gpu.ComputePipelineDescriptor{Layout: dev.NewPipelineLayout(layout), Module: mod, Entry: entry})
+	if err != nil {
+		return err
+	}
+	cp := enc.BeginComputePass()
+	cp.SetPipeline(pipe)
+	cp.SetBindGroup(0, dev.NewBindGroup(layout, group...))
+	for i, t := range images {
+		cp.SetTexture(i, t)
+	}
+	cp.Dispatch(n, 1, 1)
+	cp.End()
+	return nil
+}
@@ -6,21 +6,22 @@ package render
 
 import (
 	"errors"
+	"sync"
 
 	"poly.red/gpu"
 	"poly.red/gpu/shader"
 )
 
 // errKernelBackendUnsupported signals the device's driver has no render kernel
-// compilation path yet (Vulkan needs runtime SPIR-V via glslang, DX12 is
-// unimplemented), so the caller's runPass falls back to the CPU.
+// compilation path yet (DX12 is unimplemented), so the caller's runPass falls
+// back to the CPU.
 var errKernelBackendUnsupported = errors.New("render: GPU kernel not supported on this backend")
 
 // kernelSource compiles the Go-DSL kernel src for the given backend driver and
-// returns the shading-language ShaderSource for entry: MSL for Metal, GLSL for
-// GL. It is the single place render selects a shading language, and is
-// device-free (shader.Compile/CompileGLSL are pure Go) so it can be unit-tested
-// without a GPU.
+// returns the ShaderSource for entry: MSL for Metal, GLSL for GL and SPIR-V
+// for Vulkan. It is the single place render selects a shading language, and is
+// device-free (shader.Compile/CompileGLSL/CompileSPIRV are pure Go) so it can
+// be unit-tested without a GPU.
 func kernelSource(driver gpu.Driver, src, entry string) (gpu.ShaderSource, error) {
 	switch driver {
 	case gpu.DriverMetal:
@@ -35,18 +36,58 @@ func kernelSource(driver gpu.Driver, src, entry string) (gpu.ShaderSource, error
 			return gpu.ShaderSource{}, err
 		}
 		return gpu.ShaderSource{GLSL: ks[entry].GLSL}, nil
+	case gpu.DriverVulkan:
+		ks, err := shader.CompileSPIRV(src)
+		if err != nil {
+			return gpu.ShaderSource{}, err
+		}
+		return gpu.ShaderSource{SPIRV: ks[entry].SPIRV}, nil
 	default:
 		return gpu.ShaderSource{}, errKernelBackendUnsupported
 	}
 }
 
+// kernelKey is a kernel entry compiled for a device.
+type kernelKey struct {
+	dev        *gpu.Device
+	src, entry string
+}
+
+// kernelModules caches the module of every kernel entry compiled for a
+// device, or the error of an entry that does not compile for it.
+var (
+	kernelModulesMu sync.Mutex
+	kernelModules   = map[kernelKey]*kernelResult{}
+)
+
+type kernelResult struct {
+	once sync.Once
+	mod  *gpu.ShaderModule
+	err  error
+}
+
 // kernelModule compiles src for dev's backend and returns a shader module for
 // entry. Every render GPU pass goes through here, so the passes are
-// backend-agnostic: the same author-once kernel runs on Metal and GL.
+// backend-agnostic: the same author-once kernel runs on Metal, GL and Vulkan.
+//
+// An entry is compiled once per device. A pass whose kernel does not compile
+// for the device thus falls back to the CPU every frame without compiling it
+// again.
 func kernelModule(dev *gpu.Device, src, entry string) (*gpu.ShaderModule, error) {
-	source, err := kernelSource(dev.Driver(), src, entry)
-	if err != nil {
-		return nil, err
+	key := kernelKey{dev, src, entry}
+	kernelModulesMu.Lock()
+	k, ok := kernelModules[key]
+	if !ok {
+		k = &kernelResult{}
+		kernelModules[key] = k
 	}
-	return dev.NewShaderModule(source)
+	kernelModulesMu.Unlock()
+
+	k.once.Do(func() {
+		var source gpu.ShaderSource
+		if source, k.err = kernelSource(dev.Driver(), src, entry); k.err == nil {
+			k.mod, k.err = dev.NewShaderModule(source)
+		}
+	})
+	return k.mod, k.err
 }
@@ -12,9 +12,9 @@ import (
 )
 
 // TestKernelSourceBackend verifies render selects the right shading language per
-// device backend: MSL for Metal, GLSL for GL, unsupported elsewhere. Device-free
-// (shader.Compile/CompileGLSL are pure Go), so it runs in standard CI on every
-// platform without opening a GPU.
+// device backend: MSL for Metal, GLSL for GL, SPIR-V for Vulkan, unsupported
+// elsewhere. Device-free (the compilers are pure Go), so it runs in standard CI
+// on every platform without opening a GPU.
 func TestKernelSourceBackend(t *testing.T) {
 	metal, err := kernelSource(gpu.DriverMetal, kernels.ShadeSrc, "Shade")
 	if err != nil {
@@ -32,7 +32,29 @@ func TestKernelSourceBackend(t *testing.T) {
 		t.Errorf("GL: want GLSL only, got MSL=%d GLSL=%d bytes", len(gl.MSL), len(gl.GLSL))
 	}
 
-	if _, err := kernelSource(gpu.DriverVulkan, kernels.ShadeSrc, "Shade"); err != errKernelBackendUnsupported {
-		t.Errorf("Vulkan: want errKernelBackendUnsupported, got %v", err)
+	vk, err := kernelSource(gpu.DriverVulkan, kernels.ShadeSrc, "Shade")
+	if err != nil {
+		t.Fatalf("Vulkan: %v", err)
+	}
+	if len(vk.SPIRV) == 0 || vk.MSL != "" || vk.GLSL != "" {
+		t.Errorf("Vulkan: want SPIR-V only, got MSL=%d GLSL=%d SPIRV=%d bytes", len(vk.MSL), len(vk.GLSL), len(vk.SPIRV))
+	}
+
+	if _, err := kernelSource(gpu.DriverD3D12, kernels.ShadeSrc, "Shade"); err != errKernelBackendUnsupported {
+		t.Errorf("D3D12: want errKernelBackendUnsupported, got %v", err)
+	}
+}
+
+// TestKernelSourceVulkanStages verifies the stages of the GPU forward pass
+// compile to SPIR-V, so that the pass has its pipelines on Vulkan.
+func TestKernelSourceVulkanStages(t *testing.T) {
+	for _, entry := range []string{"Forward", "ForwardGBuffer", "ForwardCoverage", "ForwardPeel"} {
+		k, err := kernelSource(gpu.DriverVulkan, kernels.ForwardSrc, entry)
+		if err != nil {
+			t.Fatalf("%s: %v", entry, err)
+		}
+		if len(k.SPIRV) == 0 || k.MSL != "" || k.GLSL != "" {
+			t.Errorf("%s: want SPIR-V only, got MSL=%d GLSL=%d SPIRV=%d bytes", entry, len(k.MSL), len(k.GLSL), len(k.SPIRV))
+		}
 	}
 }
@@ -0,0 +1,101 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"unsafe"
+
+	"poly.red/buffer"
+	"poly.red/gpu"
+	"poly.red/gpu/shader/gpumath/kernels"
+)
+
+// textureAtlas is the mipmap atlas of the material textures sampled on the
+// GPU, in the layout of kernels.Sample: the levels of every texture in
+// atlas, and their descriptions in texs.
+type textureAtlas struct {
+	textures []*buffer.Texture
+	atlas    []float32
+	texs     []float32
+}
+
+// newTextureAtlas packs the mipmap levels of the given textures as built by
+// buffer.NewTexture, so that the GPU samples the very texels of the CPU.
+func newTextureAtlas(textures []*buffer.Texture) *textureAtlas {
+	a := &textureAtlas{textures: textures}
+	var levels []float32
+	first := len(textures)
+	for _, t := range textures {
+		mip := t.Mipmap()
+		mipmap := float32(0)
+		if t.UseMipmap() {
+			mipmap = 1
+		}
+		a.texs = append(a.texs, float32(len(mip)), mipmap, float32(first), 0)
+		first += len(mip)
+		for _, l := range mip {
+			dx, dy := l.Bounds().Dx(), l.Bounds().Dy()
+			levels = append(levels, float32(len(a.atlas)), float32(dx), float32(dy), 0)
+			for y := 0; y < dy; y++ {
+				for x := 0; x < dx; x++ {
+					c := l.RGBAAt(x, y)
+					a.atlas = append(a.atlas, float32(c.R), float32(c.G), float32(c.B), float32(c.A))
+				}
+			}
+		}
+	}
+	a.texs = append(a.texs, levels...)
+	return a
+}
+
+// index returns the index of t in the atlas, or -1 if t is not in it.
+func (a *textureAtlas) index(t *buffer.Texture) int {
+	for i := range a.textures {
+		if a.textures[i] == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// same reports whether the atlas packs exactly the given textures, in
+// order, so that it can be reused across frames.
+func (a *textureAtlas) same(textures []*buffer.Texture) bool {
+	if a == nil || len(a.textures) != len(textures) {
+		return false
+	}
+	for i := range textures {
+		if a.textures[i] != textures[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runSampleKernel samples the atlas by kernels.Sample on the GPU at uvl, 4
+// floats [u, v, lod, texture] per fragment, and returns the RGBA of each
+// fragment in [0, 255], or zeros for a fragment without texture.
+func runSampleKernel(dev *gpu.Device, a *textureAtlas, uvl []float32) ([]float32, error) {
+	n := len(uvl) / 4
+	ab, tb, ub := storageBuf(dev, a.atlas), storageBuf(dev, a.texs), storageBuf(dev, uvl)
+	out, err := dev.NewBuffer(gpu.BufferDescriptor{Data: deferredBytes(make([]float32, n*4)), Usage: gpu.BufferStorage | gpu.BufferMapRead})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		ab.Release()
+		tb.Release()
+		ub.Release()
+		out.Release()
+	}()
+
+	enc := dev.NewCommandEncoder()
+	if err := encodeKernel(dev, enc, kernels.SampleSrc, "Sample", n, nil, ab, tb, ub, out); err != nil {
+		return nil, err
+	}
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+	return append([]float32(nil), unsafe.Slice((*float32)(unsafe.Pointer(&out.Bytes()[0])), n*4)...), nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"testing"
+
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/gpu/shader/gpumath/kernels"
+	"poly.red/internal/imageutil"
+)
+
+// sampleTextures are the textures of the sampling parity tests: the UV
+// grid with and without mipmap, and a uniform texture.
+func sampleTextures() []*buffer.Texture {
+	img := imageutil.MustLoadImage("../internal/testdata/uvgrid.png")
+	return []*buffer.Texture{
+		buffer.NewTexture(buffer.TextureImage(img)),
+		buffer.NewTexture(buffer.TextureImage(img), buffer.TextureIsoMipmap(false)),
+		buffer.NewUniformTexture(color.RGBA{R: 10, G: 200, B: 30, A: 255}),
+	}
+}
+
+// sampleUVL returns the uvl argument of kernels.Sample for the parity
+// tests: every texture sampled at coordinates on, between and outside the
+// texels, wrapped or not, and at levels of detail that take each branch of
+// Query (clamped, level 0, the first blend of two levels, a whole level, the
+// last level), followed by a fragment without texture.
+func sampleUVL(textures []*buffer.Texture) []float32 {
+	uvs := []float32{-1.25, -0.3, 0, 0.0007, 0.25, 0.5, 0.731, 0.9999, 1, 1.5, 2}
+	lods := []float32{-1, 0, 0.5, 1, 1.0000001, 1.3, 2, 2.75, 3.5, 6.01, 9.5, 9.9999, 10, 10.7, 11, 40}
+	var uvl []float32
+	for ti := range textures {
+		for _, u := range uvs {
+			for _, v := range uvs {
+				for _, lod := range lods {
+					uvl = append(uvl, u, v, lod, float32(ti))
+				}
+			}
+		}
+	}
+	return append(uvl, 0.5, 0.5, 0, -1)
+}
+
+// checkSampled compares the colors sampled at uvl to buffer.Texture.Query,
+// which they must equal exactly.
+func checkSampled(t *testing.T, name string, textures []*buffer.Texture, uvl, got []float32) {
+	t.Helper()
+	for i := 0; i < len(uvl); i += 4 {
+		want := [4]float32{}
+		if ti := int(uvl[i+3]); ti >= 0 {
+			c := textures[ti].Query(uvl[i+2], uvl[i], uvl[i+1])
+			want = [4]float32{float32(c.R), float32(c.G), float32(c.B), float32(c.A)}
+		}
+		if [4]float32(got[i:i+4]) != want {
+			t.Fatalf("%s: texture %v at (u, v, lod) = (%v, %v, %v): got %v, want %v",
+				name, uvl[i+3], uvl[i], uvl[i+1], uvl[i+2], got[i:i+4], want)
+		}
+	}
+	t.Logf("%s: %d samples equal buffer.Texture.Query", name, len(uvl)/4)
+}
+
+// TestSampleKernel runs the author-once kernels.Sample as Go over the mipmap
+// atlas and requires the colors of buffer.Texture.Query, bit for bit.
+func TestSampleKernel(t *testing.T) {
+	textures := sampleTextures()
+	a := newTextureAtlas(textures)
+	uvl := sampleUVL(textures)
+	got := make([]float32, len(uvl))
+	for gid := 0; gid < len(uvl)/4; gid++ {
+		kernels.Sample(uint(gid), a.atlas, a.texs, uvl, got)
+	}
+	checkSampled(t, "Go", textures, uvl, got)
+
+	if a.index(textures[2]) != 2 || a.index(buffer.NewTexture()) != -1 {
+		t.Fatalf("atlas index: got %d and %d, want 2 and -1", a.index(textures[2]), a.index(buffer.NewTexture()))
+	}
+	if !a.same(textures) || a.same(textures[:2]) {
+		t.Fatal("atlas reuse: the atlas must be the same only for its textures")
+	}
+}
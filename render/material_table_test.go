@@ -13,19 +13,21 @@ import (
 // TestMatAt pins the per-frame flat material resolution, including the negative
 // index "use vertex color" fallback that replaced the old negative material ID.
 // This is the resolution the de-globalization moved from the material pool into
-// the renderer; a registry/index bug must not silently drop it.
+// the renderer; a registry/index bug must not silently drop it. The table mixes
+// material types.
 func TestMatAt(t *testing.T) {
 	a := material.NewBlinnPhong()
 	b := material.NewBlinnPhong()
-	table := []*material.BlinnPhong{a, b}
+	c := material.NewPBR()
+	table := []material.Material{a, b, c}
 
 	if matAt(table, -1) != nil {
 		t.Error("matAt(-1) should be nil (use vertex color)")
 	}
-	if matAt(table, 0) != a || matAt(table, 1) != b {
+	if matAt(table, 0) != a || matAt(table, 1) != b || matAt(table, 2) != c {
 		t.Error("matAt should return the material at the flat index")
 	}
-	if matAt(table, 2) != nil || matAt(table, 1<<30) != nil {
+	if matAt(table, 3) != nil || matAt(table, 1<<30) != nil {
 		t.Error("matAt(out-of-range) should be nil")
 	}
 	if matAt(nil, 0) != nil {
@@ -0,0 +1,121 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/internal/profiling"
+	"poly.red/math"
+)
+
+// maxSamples is the largest number of samples per pixel.
+const maxSamples = 16
+
+// msaaSamples returns the number of samples per pixel of the MSAA option n,
+// rounded down to a power of two in [1, maxSamples].
+func msaaSamples(n int) int {
+	s := 1
+	for s*2 <= n && s < maxSamples {
+		s *= 2
+	}
+	return s
+}
+
+// samplePattern returns the positions of the n samples of a pixel relative
+// to its center, which are the standard multisample patterns of Direct3D
+// in 1/16 pixel units.
+func samplePattern(n int) []math.Vec2[float32] {
+	var p [][2]float32
+	switch n {
+	case 1:
+		p = [][2]float32{{0, 0}}
+	case 2:
+		p = [][2]float32{{4, 4}, {-4, -4}}
+	case 4:
+		p = [][2]float32{{-2, -6}, {6, -2}, {-6, 2}, {2, 6}}
+	case 8:
+		p = [][2]float32{{1, -3}, {-1, 3}, {5, 1}, {-3, -5}, {-5, 5}, {-7, -1}, {3, 7}, {7, -7}}
+	default:
+		p = [][2]float32{
+			{1, 1}, {-1, -3}, {-3, 2}, {4, -1}, {-5, -2}, {2, 5}, {5, 3}, {3, -5},
+			{-2, 6}, {0, -7}, {-4, -6}, {-6, 4}, {-8, 0}, {7, -4}, {6, 7}, {-7, -8},
+		}
+	}
+	pattern := make([]math.Vec2[float32], len(p))
+	for i := range p {
+		pattern[i] = math.NewVec2(p[i][0]/16, p[i][1]/16)
+	}
+	return pattern
+}
+
+// msaaEdge is a pixel of the multisampled forward pass whose samples the
+// primary fragment, the one in the G-buffer, does not all cover. The rest
+// of its samples belong to other surfaces or to the background.
+type msaaEdge struct {
+	x, y     int
+	primary  int // the number of samples of the primary fragment
+	surfaces []msaaSurface
+}
+
+// msaaSurface is a surface that covers some samples of a msaaEdge.
+type msaaSurface struct {
+	frag    buffer.Fragment
+	samples int
+}
+
+// passResolve resolves the pixels of the multisampled forward pass that
+// several surfaces or the background share. The color of such a pixel is
+// the average of the colors of its samples: the samples of the primary
+// fragment take its color from the deferred pass, every other surface is
+// shaded once, and the uncovered samples take the background.
+func (r *Renderer) passResolve() {
+	if len(r.msaaEdges) == 0 {
+		return
+	}
+	if r.cfg.Debug {
+		done := profiling.Timed("multisample resolve")
+		defer done()
+	}
+	buf := r.CurrBuffer()
+	uniforms := r.screenUniforms()
+	n := msaaSamples(r.cfg.MSAA)
+	edges := r.msaaEdges
+	for i := 0; i < len(edges); i += r.cfg.BatchSize {
+		part := edges[i:min(i+r.cfg.BatchSize, len(edges))]
+		r.sched.Run(func() {
+			for j := range part {
+				e := &part[j]
+				info := buf.UnsafeGet(e.x, e.y)
+				var sum [4]float32
+				add := func(c color.RGBA, samples int) {
+					w := float32(samples)
+					sum[0] += float32(c.R) * w
+					sum[1] += float32(c.G) * w
+					sum[2] += float32(c.B) * w
+					sum[3] += float32(c.A) * w
+				}
+				add(info.Col, e.primary)
+				rest := n - e.primary
+				for _, s := range e.surfaces {
+					c, _ := r.shadeSurface(s.frag, uniforms)
+					add(c, s.samples)
+					rest -= s.samples
+				}
+				if rest > 0 {
+					add(r.background(e.x, e.y, uniforms), rest)
+				}
+				info.Col = color.RGBA{
+					R: uint8(math.Clamp(math.Round(sum[0]/float32(n)), 0, 0xff)),
+					G: uint8(math.Clamp(math.Round(sum[1]/float32(n)), 0, 0xff)),
+					B: uint8(math.Clamp(math.Round(sum[2]/float32(n)), 0, 0xff)),
+					A: uint8(math.Clamp(math.Round(sum[3]/float32(n)), 0, 0xff)),
+				}
+				buf.UnsafeSet(e.x, e.y, info)
+			}
+		})
+	}
+	r.sched.Wait()
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"testing"
+)
+
+func TestSamplePattern(t *testing.T) {
+	for in, want := range map[int]int{0: 1, 1: 1, 2: 2, 3: 2, 4: 4, 7: 4, 8: 8, 16: 16, 64: 16} {
+		if got := msaaSamples(in); got != want {
+			t.Fatalf("msaaSamples(%d): got %d, want %d", in, got, want)
+		}
+	}
+	for _, n := range []int{1, 2, 4, 8, 16} {
+		p := samplePattern(n)
+		if len(p) != n {
+			t.Fatalf("%d samples: got a pattern of %d", n, len(p))
+		}
+		seen := map[[2]float32]bool{}
+		for _, s := range p {
+			if s.X < -0.5 || s.X >= 0.5 || s.Y < -0.5 || s.Y >= 0.5 {
+				t.Fatalf("%d samples: sample %v is outside of the pixel", n, s)
+			}
+			if seen[[2]float32{s.X, s.Y}] {
+				t.Fatalf("%d samples: sample %v is repeated", n, s)
+			}
+			seen[[2]float32{s.X, s.Y}] = true
+		}
+	}
+}
+
+// TestMSAA renders the bunny single sampled and multisampled. The G-buffer
+// keeps the size of the image, and the multisampled image only differs on
+// the pixels that several surfaces share: the silhouette of the bunny, where
+// the pixels blend the bunny and the background, and the shared edges of its
+// triangles, which differ a little since each triangle is shaded once.
+func TestMSAA(t *testing.T) {
+	const w, h = 160, 120
+	bg := color.RGBA{0, 0, 0, 255}
+	for _, msaa := range []int{2, 4, 8, 16} {
+		t.Run(fmt.Sprintf("msaa %d", msaa), func(t *testing.T) {
+			render := func(msaa int) *Renderer {
+				s, c := newscene(w, h)
+				return NewRenderer(Camera(c), Size(w, h), MSAA(msaa), Scene(s), Background(bg), GammaCorrection(false), CPU())
+			}
+			ref := render(1)
+			ref.passForward()
+			cov := ref.CurrBuffer()
+			want := render(1).Render()
+
+			r := render(msaa)
+			r.passForward()
+			if b := r.CurrBuffer().Bounds(); b.Dx() != w || b.Dy() != h {
+				t.Fatalf("the G-buffer is %dx%d, want %dx%d", b.Dx(), b.Dy(), w, h)
+			}
+			if len(r.msaaEdges) == 0 {
+				t.Fatal("no pixel is partially covered")
+			}
+			edge := map[[2]int]bool{}
+			for _, e := range r.msaaEdges {
+				if e.primary < 1 || e.primary >= msaa {
+					t.Fatalf("pixel (%d, %d): %d of %d samples are primary", e.x, e.y, e.primary, msaa)
+				}
+				edge[[2]int{e.x, e.y}] = true
+			}
+			got := render(msaa).Render()
+
+			// A pixel is on the silhouette if the single sampled bunny
+			// covers some but not all of its 3x3 neighborhood.
+			silhouette := func(x, y int) bool {
+				in, all := 0, 0
+				for j := max(y-1, 0); j <= min(y+1, h-1); j++ {
+					for i := max(x-1, 0); i <= min(x+1, w-1); i++ {
+						all++
+						if cov.UnsafeGet(i, j).Ok {
+							in++
+						}
+					}
+				}
+				return in > 0 && in < all
+			}
+			blended, inner, innerDiff, differ := 0, 0, 0, 0
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					// The image is bottom-up, the G-buffer top-down.
+					a, b := want.RGBAAt(x, h-1-y), got.RGBAAt(x, h-1-y)
+					d := max(diff(a.R, b.R), diff(a.G, b.G), diff(a.B, b.B))
+					switch {
+					case !edge[[2]int{x, y}]:
+						if d > 2 {
+							differ++
+						}
+					case silhouette(x, y):
+						if d > 2 {
+							blended++
+						}
+					default:
+						inner++
+						innerDiff += d
+					}
+				}
+			}
+			if differ > 0 {
+				t.Fatalf("%d pixels that one surface covers differ from the single sampled image", differ)
+			}
+			if blended == 0 {
+				t.Fatal("no pixel on the silhouette is blended")
+			}
+			if inner > 0 && innerDiff/inner > 8 {
+				t.Fatalf("the shared edges of triangles differ by %d on average", innerDiff/inner)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/buffer"
+	"poly.red/camera"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/scene"
+)
+
+// newNormalMapScene returns a plane facing +Y under a camera above it. The
+// tangent of the plane is +X and its bitangent -Z.
+func newNormalMapScene(w, h int, m material.Material) (*scene.Scene, camera.Interface) {
+	s := scene.NewScene(
+		light.NewPoint(light.Intensity(3), light.Position(math.NewVec3[float32](1, 2, 0))),
+		light.NewAmbient(light.Intensity(0.3)),
+	)
+	s.Add(newPBRPlane(1, m))
+	return s, camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 1.2, 0.8)),
+		camera.LookAt(math.NewVec3[float32](0, 0, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(45, float32(w)/float32(h), 0.1, 3),
+	)
+}
+
+// TestNormalMap checks that a normal map tilting every normal halfway
+// towards the tangent perturbs the G-buffer normals of both material types.
+func TestNormalMap(t *testing.T) {
+	// Tangent space normal (0.6, 0, 0.6) in the usual 0.5 biased encoding.
+	tilt := buffer.NewUniformTexture(color.RGBA{R: 204, G: 128, B: 204, A: 255})
+	want := math.NewVec4[float32](1, 1, 0, 0).Unit()
+
+	tests := []struct {
+		name string
+		mat  material.Material
+	}{
+		{"blinn_phong", material.NewBlinnPhong(material.NormalMap(tilt))},
+		{"pbr", material.NewPBR(material.NormalMap(tilt))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const w, h = 64, 64
+			s, c := newNormalMapScene(w, h, tt.mat)
+			r := NewRenderer(Camera(c), Size(w, h), Scene(s), MSAA(1), CPU())
+			r.passForward()
+
+			buf := r.CurrBuffer()
+			n := 0
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					info := buf.UnsafeGet(x, y)
+					if !info.Ok {
+						continue
+					}
+					n++
+					if got := info.Nor; got.Sub(want).Len() > 0.02 {
+						t.Fatalf("fragment (%d, %d): want normal %v, got %v", x, y, want, got)
+					}
+				}
+			}
+			if n == 0 {
+				t.Fatalf("no fragment is rasterized")
+			}
+		})
+	}
+}
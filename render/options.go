@@ -22,14 +22,25 @@ type option struct {
 	MSAA          int
 	Perspect      bool
 	ShadowMap     bool
+	ShadowFilter  ShadowFilterMode
+	ShadowKernel  int
+	AORadius      float32
+	AOSamples     int
+	AOStrength    float32
+	Rasterizer    RasterizerMode
+	Antialiasing  AntialiasingMode
 	GammaCorrect  bool
 	Debug         bool
 	Camera        camera.Interface
 	Scene         *scene.Scene
 	BlendFunc     BlendFunc
 	GPUDevice     *gpu.Device
+	backgroundSet bool // the Background option was given
 	forceCPU      bool
+	gpuDriver     gpu.Driver
+	gpuDriverSet  bool // the GPUDriver option was given
 	forwardCPU    bool // force the forward raster on the CPU while other passes may use the GPU
+	gbufReadback  bool // force the GPU forward pass to read its G-buffer back to the CPU
 }
 
 // Option represents a rendering option
@@ -59,10 +70,21 @@ func Scene(s *scene.Scene) Option {
 	return func(o *option) { o.Scene = s }
 }
 
+// Background is an option that customizes the color of the pixels that
+// no geometry covers. Without it, they show the environment map of the
+// scene, if any, or are transparent.
 func Background(c color.RGBA) Option {
-	return func(o *option) { o.Background = c }
+	return func(o *option) {
+		o.Background = c
+		o.backgroundSet = true
+	}
 }
 
+// MSAA is an option that customizes the number of samples per pixel of the
+// multisample antialiasing, which is rounded down to 1, 2, 4, 8 or 16. The
+// coverage and depth are tested per sample, but a pixel is shaded once per
+// surface, and the samples are resolved to the pixel after the deferred
+// pass. The CPU multisampled pass always runs on the binned rasterizer.
 func MSAA(n int) Option {
 	return func(o *option) { o.MSAA = n }
 }
@@ -72,6 +94,109 @@ func ShadowMap(enable bool) Option {
 	return func(o *option) { o.ShadowMap = enable }
 }
 
+// ShadowFilterMode represents the filter that is used for shadow map
+// lookups, which determines how the edges of shadows look like.
+type ShadowFilterMode int
+
+// All kinds of shadow filter.
+const (
+	// ShadowFilterHard takes a single sample from the shadow map and
+	// results in hard, aliased shadow edges.
+	ShadowFilterHard ShadowFilterMode = iota
+	// ShadowFilterPCF (percentage-closer filtering) averages the depth
+	// test over a square of shadow map texels around the lookup.
+	ShadowFilterPCF
+	// ShadowFilterPCSS (percentage-closer soft shadows) estimates the
+	// penumbra from the average depth of the blockers and filters with a
+	// kernel of that size: shadows are hard at contact points and get
+	// softer as the distance to their caster grows.
+	ShadowFilterPCSS
+)
+
+// ShadowFilter is an option that customizes the filter of shadow map
+// lookups. The kernel is a radius in shadow map texels: PCF averages
+// (2*kernel+1)^2 samples, and PCSS searches blockers in that region and
+// limits the penumbra by it. The kernel is ignored by the hard filter.
+// By default, shadows are hard.
+func ShadowFilter(mode ShadowFilterMode, kernel int) Option {
+	return func(o *option) {
+		o.ShadowFilter = mode
+		o.ShadowKernel = kernel
+		if o.ShadowKernel < 0 {
+			o.ShadowKernel = 0
+		}
+	}
+}
+
+// AmbientOcclusion is an option that customizes the screen-space ambient
+// occlusion of the materials that enable it. The radius is the world space
+// distance within which geometry occludes a fragment, samples is the number
+// of samples per fragment, and strength scales the occlusion. By default,
+// the radius is 0.05, with 16 samples and a strength of 1.
+func AmbientOcclusion(radius float32, samples int, strength float32) Option {
+	return func(o *option) {
+		o.AORadius = radius
+		o.AOSamples = samples
+		o.AOStrength = strength
+		if o.AOSamples < 1 {
+			o.AOSamples = 1
+		}
+	}
+}
+
+// RasterizerMode represents how the CPU forward pass rasterizes the
+// triangles of a scene.
+type RasterizerMode int
+
+// All kinds of CPU rasterizer.
+const (
+	// RasterizerImmediate rasterizes every triangle as a concurrent task
+	// that walks its bounding box and depth tests each pixel under the
+	// lock of the fragment buffer.
+	RasterizerImmediate RasterizerMode = iota
+	// RasterizerBinned sorts the triangles into screen tiles, then
+	// rasterizes every tile on a single worker with incremental edge
+	// functions. No two workers touch the same pixel, hence the depth test
+	// takes no lock, which scales better on large meshes.
+	RasterizerBinned
+)
+
+// Rasterizer is an option that customizes the rasterizer of the CPU
+// forward pass. By default, the immediate rasterizer is used. Both
+// produce the same image up to the pixels that the edges of two
+// triangles share.
+func Rasterizer(mode RasterizerMode) Option {
+	return func(o *option) { o.Rasterizer = mode }
+}
+
+// AntialiasingMode represents the post-process antialiasing of the final
+// image.
+type AntialiasingMode int
+
+// All kinds of post-process antialiasing.
+const (
+	// AntialiasingNone applies no post-process antialiasing.
+	AntialiasingNone AntialiasingMode = iota
+	// AntialiasingFXAA (fast approximate antialiasing) finds the edges of
+	// the final image by their luma contrast and blends the pixels along
+	// them with their neighbors across. It runs on a single frame but may
+	// blur fine texture details.
+	AntialiasingFXAA
+	// AntialiasingTAA (temporal antialiasing) jitters the camera by a
+	// subpixel offset every frame and blends each frame into the history
+	// of the previous ones, reprojected by the depth and clamped to the
+	// colors around each pixel. The edges converge over a few frames of
+	// successive Render calls.
+	AntialiasingTAA
+)
+
+// Antialiasing is an option that customizes the post-process antialiasing
+// of the final image, which composes with MSAA at a fraction of its cost.
+// By default, there is none.
+func Antialiasing(mode AntialiasingMode) Option {
+	return func(o *option) { o.Antialiasing = mode }
+}
+
 // GPU supplies a GPU device so eligible passes (currently gamma correction)
 // run on the GPU through poly.red/gpu instead of the CPU. When nil, the CPU
 // path is used. Pass a device from gpu.Open().
@@ -87,6 +212,20 @@ func CPU() Option {
 	return func(o *option) { o.forceCPU = true }
 }
 
+// GPUDriver is an option that customizes the driver of the device that
+// NewRenderer acquires when no device is supplied: gpu.DriverAuto probes
+// the drivers of the platform in order (Metal on macOS, Vulkan then
+// headless GL on Linux), and any other driver only that one. It takes
+// precedence over the POLYRED_GPU environment variable, which takes "cpu",
+// "metal", "vulkan", "gl" or "auto". If no driver passes the self-check,
+// every pass runs on the CPU.
+func GPUDriver(d gpu.Driver) Option {
+	return func(o *option) {
+		o.gpuDriver = d
+		o.gpuDriverSet = true
+	}
+}
+
 // GammaCorrection is an option that customizes whether gamma correction
 // should be applied or not.
 func GammaCorrection(enable bool) Option {
@@ -0,0 +1,101 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/camera"
+	"poly.red/geometry"
+	"poly.red/geometry/mesh"
+	"poly.red/geometry/primitive"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/scene"
+	"poly.red/shader"
+)
+
+// newPBRPlane returns a plane of the given size on the XZ plane that is
+// shaded by the given material.
+func newPBRPlane(size float32, m material.Material) *geometry.Geometry {
+	v := func(x, z, u, w float32) *primitive.Vertex {
+		return primitive.NewVertex(
+			primitive.Pos(math.NewVec4(x*size, 0, z*size, 1)),
+			primitive.UV(math.NewVec2(u, w)),
+			primitive.Nor(math.NewVec4[float32](0, 1, 0, 0)),
+		)
+	}
+	v1, v2, v3, v4 := v(-0.5, -0.5, 0, 1), v(-0.5, 0.5, 0, 0), v(0.5, 0.5, 1, 0), v(0.5, -0.5, 1, 1)
+	return geometry.New(mesh.NewTriangleMesh([]*primitive.Triangle{
+		{V1: v1, V2: v2, V3: v3, MaterialID: 0},
+		{V1: v1, V2: v3, V3: v4, MaterialID: 0},
+	}), m)
+}
+
+// newMixedMaterialScene returns a Blinn-Phong bunny standing on a
+// metallic-roughness plane.
+func newMixedMaterialScene(w, h int) (*scene.Scene, camera.Interface) {
+	s := scene.NewScene(
+		light.NewPoint(light.Intensity(3), light.Position(math.NewVec3[float32](-1, 2, 1.5))),
+		light.NewAmbient(light.Intensity(0.3)),
+	)
+	bunny := model.MustLoad("../internal/testdata/bunny.obj")
+	bunny.Scale(2, 2, 2)
+	s.Add(bunny)
+	s.Add(newPBRPlane(1.5, material.NewPBR(
+		material.BaseColor(color.RGBA{R: 230, G: 180, B: 90, A: 255}),
+		material.Metallic(0.8),
+		material.Roughness(0.3),
+	)))
+	return s, camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 0.6, 0.9)),
+		camera.LookAt(math.NewVec3[float32](0, 0.1, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(45, float32(w)/float32(h), 0.1, 3),
+	)
+}
+
+// TestMixedMaterials checks that the per-frame material table carries both
+// material types and that every fragment is shaded by the shader of its own
+// material type.
+func TestMixedMaterials(t *testing.T) {
+	const w, h = 96, 96
+	s, c := newMixedMaterialScene(w, h)
+	r := NewRenderer(Camera(c), Size(w, h), Scene(s), MSAA(1), CPU())
+	r.passForward()
+
+	ls, es := s.Lights()
+	buf := r.CurrBuffer()
+	counts := map[string]int{}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			info := buf.UnsafeGet(x, y)
+			if !info.Ok {
+				continue
+			}
+			var want color.RGBA
+			switch m := r.material(info.MaterialID).(type) {
+			case *material.BlinnPhong:
+				want = shader.FragmentShader(m, info, c.Position(), ls, es)
+			case *material.PBR:
+				want = shader.PBRFragmentShader(m, info, c.Position(), ls, es)
+			default:
+				t.Fatalf("fragment (%d, %d) has material %T", x, y, m)
+			}
+			counts[r.material(info.MaterialID).Name()]++
+
+			frag := info.Fragment
+			if got := r.shade(&frag, nil); got != want {
+				t.Fatalf("fragment (%d, %d) of %s: got %v, want %v",
+					x, y, r.material(info.MaterialID).Name(), got, want)
+			}
+		}
+	}
+	if counts["pbr"] == 0 || len(counts) < 2 {
+		t.Fatalf("want fragments of both material types, got %v", counts)
+	}
+}
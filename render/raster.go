@@ -8,13 +8,14 @@ import (
 	"fmt"
 	"image"
 	"runtime"
+	"slices"
 
 	"poly.red/buffer"
 	"poly.red/color"
 	"poly.red/geometry"
 	"poly.red/geometry/primitive"
-	"poly.red/gpu"
 	"poly.red/internal/imageutil"
+	"poly.red/light"
 	"poly.red/material"
 	"poly.red/math"
 	"poly.red/scene"
@@ -48,28 +49,50 @@ type Renderer struct {
 	// tabulating each geometry's materials (the global material pool was removed).
 	// A fragment's MaterialID indexes it; a negative index means "use vertex
 	// color". See material(). Read after the forward pass barrier, so no lock.
-	matTable []*material.BlinnPhong
+	matTable []material.Material
 
 	// passGPU records, per named pass of the last frame, whether the GPU path
 	// ran (true) or the CPU fallback (false). See runPass.
 	passGPU map[string]bool
 
-	// ownDevice is the GPU device NewRenderer acquired itself (GPU by default);
-	// it is closed by the finalizer. A caller-supplied device (render.GPU) is
-	// not stored here and not closed by the renderer.
-	ownDevice *gpu.Device
+	// msaaEdges are the pixels of the last forward pass that the resolve
+	// pass blends from several surfaces, see passResolve.
+	msaaEdges []msaaEdge
+
+	// gbuf is the G-buffer of the last GPU forward pass while it stays on
+	// the device for the deferred pass, or nil once it is in the fragment
+	// buffer. See keepGBuffer.
+	gbuf *deviceGBuffer
+
+	// transLayers are the unshaded fragments of transparent materials that
+	// the last forward pass drew, in layers from the nearest, until the
+	// transparent pass blends them. See passTransparent.
+	transLayers []*buffer.FragmentBuffer
+
+	// atlas is the mipmap atlas of the material textures that the GPU
+	// deferred pass last sampled, kept while the textures do not change.
+	atlas *textureAtlas
+
+	// taa is the state of the temporal antialiasing across frames, see
+	// passTAA.
+	taa taaState
 }
 
 // runPass runs a pass on the GPU when a device is present and the GPU closure
 // succeeds, otherwise on the CPU; it records which path executed under name.
+// In debug mode, it prints why a pass fell back to the CPU.
 // This is the single dispatch seam the unified renderer's passes share (see
 // specs/foundations/render-pass-runner.md).
 func (r *Renderer) runPass(name string, gpu func() error, cpu func()) {
 	if r.cfg.GPUDevice != nil && gpu != nil {
-		if err := gpu(); err == nil {
+		err := gpu()
+		if err == nil {
 			r.passGPU[name] = true
 			return
 		}
+		if r.cfg.Debug {
+			fmt.Printf("%s pass on the CPU: %v\n", name, err)
+		}
 	}
 	cpu()
 	r.passGPU[name] = false
@@ -87,15 +110,18 @@ func NewRenderer(opts ...Option) *Renderer {
 		bufs:    nil,
 		passGPU: map[string]bool{},
 		cfg: &option{
-			Width:     800,
-			Height:    600,
-			MSAA:      1,
-			ShadowMap: false,
-			Debug:     false,
-			Scene:     nil,
-			Workers:   runtime.NumCPU(),
-			BatchSize: 32, // heuristic
-			Format:    buffer.PixelFormatRGBA,
+			Width:      800,
+			Height:     600,
+			MSAA:       1,
+			ShadowMap:  false,
+			AORadius:   0.05,
+			AOSamples:  16,
+			AOStrength: 1,
+			Debug:      false,
+			Scene:      nil,
+			Workers:    runtime.NumCPU(),
+			BatchSize:  32, // heuristic
+			Format:     buffer.PixelFormatRGBA,
 		},
 	}
 	for _, opt := range opts {
@@ -103,21 +129,22 @@ func NewRenderer(opts ...Option) *Renderer {
 	}
 
 	// GPU by default: acquire a device automatically unless one was supplied
-	// (render.GPU) or the CPU path was forced (render.CPU). Acquisition failure
-	// (e.g. a headless machine with no driver) is non-fatal: the renderer runs
-	// all-CPU. A device the renderer opens itself is closed in the finalizer; a
-	// caller-supplied device is left untouched.
+	// (render.GPU) or the CPU path was forced (render.CPU). The drivers are
+	// probed in order (render.GPUDriver, or POLYRED_GPU, narrows them), each
+	// once per process, and the first whose device passes a self-check
+	// kernel is shared by the renderers. Acquisition failure (e.g. a headless
+	// machine with no driver) is non-fatal: the renderer runs all-CPU.
 	if r.cfg.GPUDevice == nil && !r.cfg.forceCPU {
-		// The renderer's GPU offload (gpudeferred.go / gpugamma.go) emits MSL
-		// today, so only a Metal device is usable. Request Metal specifically:
-		// on non-Metal platforms Open returns ErrUnsupported and no device is
-		// created (so we never touch, e.g., a broken headless GL), and the
-		// renderer runs all-CPU. This restriction lifts when the passes are
-		// authored once for all backends (specs/foundations/unified-renderer.md).
-		// A caller can still force any device with render.GPU(dev).
-		if dev, err := gpu.Open(gpu.WithDriver(gpu.DriverMetal)); err == nil {
-			r.cfg.GPUDevice = dev
-			r.ownDevice = dev
+		drv, on := envDriver()
+		if r.cfg.gpuDriverSet {
+			drv, on = r.cfg.gpuDriver, true
+		}
+		var err error = errNoDevice
+		if on {
+			r.cfg.GPUDevice, err = acquireDevice(drv)
+		}
+		if err != nil && r.cfg.Debug {
+			fmt.Printf("rendering on the CPU: %v\n", err)
 		}
 	}
 
@@ -127,9 +154,6 @@ func NewRenderer(opts ...Option) *Renderer {
 	r.sched = sched.New(sched.Workers(r.cfg.Workers))
 	runtime.SetFinalizer(r, func(r *Renderer) {
 		r.sched.Release()
-		if r.ownDevice != nil {
-			r.ownDevice.Close()
-		}
 	})
 
 	// initialize shadow maps
@@ -146,7 +170,7 @@ func NewRenderer(opts ...Option) *Renderer {
 // Note: with Metal, we always use RGBA pixel format.
 func (r *Renderer) resetBufs() {
 	for i := 0; i < r.buflen; i++ {
-		r.bufs[i] = buffer.NewBuffer(image.Rect(0, 0, r.cfg.Width*r.cfg.MSAA, r.cfg.Height*r.cfg.MSAA),
+		r.bufs[i] = buffer.NewBuffer(image.Rect(0, 0, r.cfg.Width, r.cfg.Height),
 			buffer.Format(r.cfg.Format))
 	}
 }
@@ -165,6 +189,7 @@ func (r *Renderer) Render() *image.RGBA {
 	// record running
 	r.startRunning()
 	defer r.stopRunning()
+	r.taa.jitter = r.taaJitter()
 
 	// reset buffers
 	buf.ClearColor()
@@ -194,6 +219,16 @@ func (r *Renderer) Render() *image.RGBA {
 		return r.outBuf
 	}
 
+	r.passResolve()
+	if r.shouldStop() {
+		return r.outBuf
+	}
+
+	r.passTransparent()
+	if r.shouldStop() {
+		return r.outBuf
+	}
+
 	r.passAntialiasing()
 	return r.outBuf
 }
@@ -212,6 +247,9 @@ func (r *Renderer) NextBuffer() *buffer.FragmentBuffer {
 // in the shaded image; the residual vs the CPU is a bounded boundary band (silhouette
 // edges + depth-tie folds), the parity trap documented in the forward-raster spec.
 func (r *Renderer) passForward() {
+	r.msaaEdges = r.msaaEdges[:0]
+	r.gbuf = nil
+	r.transLayers = nil
 	if r.cfg.forwardCPU {
 		// Deferred/gamma parity gates set this to shade a CPU-built G-buffer, so they
 		// isolate the pass under test (identical input to the CPU reference) rather
@@ -231,45 +269,102 @@ func (r *Renderer) cpuForwardPass() {
 	buf := r.CurrBuffer()
 	mvp := &shader.MVP{
 		View: r.cfg.Camera.ViewMatrix(),
-		Proj: r.cfg.Camera.ProjMatrix(),
+		Proj: r.projMatrix(),
 		Viewport: math.ViewportMatrix(
 			float32(buf.Bounds().Dx()),
 			float32(buf.Bounds().Dy()),
 		),
 	}
-	r.matTable = r.matTable[:0]
+	r.tabulateMaterials()
+
+	// The immediate rasterizer draws every triangle as it goes, and the
+	// binned one collects them for its tiles. Multisampling runs on the
+	// binned rasterizer, whose tiles own the samples of their pixels.
+	var bins *tileBins
+	submit := func(mvp *shader.MVP, t *primitive.Triangle, flatMatID int64) {
+		r.sched.Run(func() {
+			if !t.IsValid() {
+				return
+			}
+			r.draw(mvp, t, flatMatID)
+		})
+	}
+	if r.cfg.Rasterizer == RasterizerBinned || msaaSamples(r.cfg.MSAA) > 1 {
+		bins = r.newTileBins()
+		submit = bins.submit
+	}
+
+	base := int64(0)
 	scene.IterObjects(r.cfg.Scene, func(g *geometry.Geometry, modelMatrix math.Mat4[float32]) bool {
+		// The draws run concurrently with the traversal, hence every
+		// geometry needs its own copy of the matrices.
+		mvp := &shader.MVP{View: mvp.View, Proj: mvp.Proj, Viewport: mvp.Viewport}
 		mvp.Model = modelMatrix.MulM(g.ModelMatrix())
 		mvp.Normal = mvp.Model.Inv().T()
 		mvp.ViewInv = mvp.View.Inv()
 		mvp.ProjInv = mvp.Proj.Inv()
 		mvp.ViewportInv = mvp.Viewport.Inv()
 
-		// Tabulate this geometry's materials into the per-render flat table; its
-		// primitives carry geometry-local indices, so flat = base + local. This
-		// runs sequentially (IterObjects), before the concurrent draws read only
-		// their captured flat id, so there is no race on matTable.
-		base := int64(len(r.matTable))
-		for _, m := range g.Materials() {
-			bp, _ := m.(*material.BlinnPhong)
-			r.matTable = append(r.matTable, bp)
-		}
+		// The primitives carry geometry-local material indices, so the
+		// index into the flat table is base + local. Transparent primitives
+		// are drawn into the transparent layers below.
 		for _, tri := range g.Triangles() {
 			t := tri
 			flatMatID := t.MaterialID
 			if flatMatID >= 0 {
 				flatMatID += base
 			}
-			r.sched.Run(func() {
-				if !t.IsValid() {
-					return
-				}
-				r.draw(mvp, t, flatMatID)
-			})
+			if transparent(r.material(flatMatID)) {
+				continue
+			}
+			submit(mvp, t, flatMatID)
 		}
+		base += int64(len(g.Materials()))
 		return true
 	})
+	r.drawEmitters(*mvp, submit)
 	r.sched.Wait()
+	if bins != nil {
+		bins.rasterize()
+	}
+	if slices.ContainsFunc(r.matTable, transparent) {
+		r.transLayers = r.rasterTransparent()
+	}
+}
+
+// tabulateMaterials rebuilds the per-frame flat material table from the
+// materials of all geometries in scene order. It runs before any draw, so the
+// concurrent draws may read the table.
+func (r *Renderer) tabulateMaterials() {
+	r.matTable = r.matTable[:0]
+	scene.IterObjects(r.cfg.Scene, func(g *geometry.Geometry, _ math.Mat4[float32]) bool {
+		for _, m := range g.Materials() {
+			r.matTable = append(r.matTable, shadable(m))
+		}
+		return true
+	})
+}
+
+// drawEmitters draws the rectangles of area lights, which are visible in the
+// scene. Their shapes are in world space and have no material, hence the
+// deferred pass keeps their light color. They are not drawn into shadow maps.
+func (r *Renderer) drawEmitters(mvp shader.MVP, submit func(mvp *shader.MVP, t *primitive.Triangle, flatMatID int64)) {
+	ls, _ := r.cfg.Scene.Lights()
+	for _, l := range ls {
+		a, ok := l.(*light.Area)
+		if !ok {
+			continue
+		}
+		emvp := mvp
+		emvp.Model = math.Mat4I[float32]()
+		emvp.Normal = math.Mat4I[float32]()
+		emvp.ViewInv = emvp.View.Inv()
+		emvp.ProjInv = emvp.Proj.Inv()
+		emvp.ViewportInv = emvp.Viewport.Inv()
+		for _, tri := range a.Shape().Triangles() {
+			submit(&emvp, tri, tri.MaterialID)
+		}
+	}
 }
 
 func (r *Renderer) passDeferred() {
@@ -277,15 +372,49 @@ func (r *Renderer) passDeferred() {
 		done := profiling.Timed("deferred pass (shading)")
 		defer done()
 	}
+	buf := r.CurrBuffer()
+	uniforms := r.screenUniforms()
+
+	// Offload deferred shading to the GPU when a device is provided and the
+	// scene is supported; otherwise shade on the CPU. A G-buffer that the GPU
+	// forward pass left on the device is shaded in place, any other is read
+	// from the fragment buffer.
+	r.runPass("deferred", func() error {
+		ls, es := r.cfg.Scene.Lights()
+		var sd *gpuShadowData
+		if r.cfg.ShadowMap {
+			sd = r.gpuShadowData(uniforms)
+		}
+		if r.gbuf != nil {
+			return r.gpuDeferredResident(ls, es, sd, uniforms)
+		}
+		bg := func(x, y int) color.RGBA { return r.background(x, y, uniforms) }
+		return gpuDeferredShade(r.cfg.GPUDevice, buf, ls, es, r.cfg.Camera.Position(), bg, sd, r.ssaoData(uniforms), r.matTable)
+	}, func() {
+		r.readbackGBuffer()
+		ao := r.ssaoData(uniforms)
+		r.DrawFragments(buf, func(frag *primitive.Fragment) color.RGBA {
+			return r.shade(frag, uniforms)
+		})
+		if ao != nil {
+			r.ambientOcclusion(buf, ao)
+		}
+	})
+}
+
+// screenUniforms returns the matrices of the camera and of the viewport of
+// the current buffer, including the transformation from the screen space to
+// the world space.
+func (r *Renderer) screenUniforms() *shader.MVP {
 	buf := r.CurrBuffer()
 	matView := r.cfg.Camera.ViewMatrix()
 	matViewInv := matView.Inv()
-	matProj := r.cfg.Camera.ProjMatrix()
+	matProj := r.projMatrix()
 	matProjInv := matProj.Inv()
 	matVP := math.ViewportMatrix(float32(buf.Bounds().Dx()), float32(buf.Bounds().Dy()))
 	matVPInv := matVP.Inv()
 	matScreenToWorld := matViewInv.MulM(matProjInv).MulM(matVPInv)
-	uniforms := &shader.MVP{
+	return &shader.MVP{
 		View:            matView,
 		ViewInv:         matViewInv,
 		Proj:            matProj,
@@ -293,57 +422,88 @@ func (r *Renderer) passDeferred() {
 		Viewport:        matVP,
 		ViewportToWorld: matScreenToWorld,
 	}
-
-	// Offload deferred shading to the GPU when a device is provided and the
-	// scene is supported; otherwise shade on the CPU. Shadow mapping is not yet
-	// handled by the GPU path.
-	r.runPass("deferred", func() error {
-		ls, es := r.cfg.Scene.Lights()
-		var sd *gpuShadowData
-		if r.cfg.ShadowMap {
-			var ok bool
-			if sd, ok = r.gpuShadowData(uniforms); !ok {
-				return errGPUDeferredUnsupported
-			}
-		}
-		return gpuDeferredShade(r.cfg.GPUDevice, buf, ls, es, r.cfg.Camera.Position(), r.cfg.Background, sd, r.matTable)
-	}, func() {
-		r.DrawFragments(buf, func(frag *primitive.Fragment) color.RGBA {
-			return r.shade(frag, uniforms)
-		})
-	})
 }
 
 // material resolves a fragment's flat MaterialID against the per-frame table,
 // returning nil when the index is negative or out of range (use vertex color).
 // This is the single material-resolution path for the CPU renderer.
-func (r *Renderer) material(id int64) *material.BlinnPhong {
+func (r *Renderer) material(id int64) material.Material {
 	return matAt(r.matTable, id)
 }
 
+// shadable returns the material if the renderer can shade it, or nil such
+// that fragments of the material keep their vertex color.
+func shadable(m material.Material) material.Material {
+	switch m.(type) {
+	case *material.BlinnPhong, *material.PBR:
+		return m
+	}
+	return nil
+}
+
 func (r *Renderer) shade(frag *primitive.Fragment, uniforms *shader.MVP) color.RGBA {
 	buf := r.CurrBuffer()
 	info := buf.UnsafeGet(frag.X, frag.Y)
 	if !info.Ok {
+		return r.background(frag.X, frag.Y, uniforms)
+	}
+
+	col, _ := r.shadeSurface(info, uniforms)
+	return col
+}
+
+// background returns the color of the uncovered pixel (x, y): the
+// Background option if given, otherwise the environment map of the scene
+// in the view direction of the pixel, if any.
+func (r *Renderer) background(x, y int, uniforms *shader.MVP) color.RGBA {
+	if r.cfg.backgroundSet {
 		return r.cfg.Background
 	}
+	_, es := r.cfg.Scene.Lights()
+	env := shader.EnvMapOf(es)
+	if env == nil {
+		return r.cfg.Background
+	}
+
+	// The view direction is from the pixel on the near plane to the pixel
+	// in the middle of the depth range, which holds for any projection.
+	p0 := math.NewVec4(float32(x), float32(y), 1, 1).Apply(uniforms.ViewportToWorld).Pos()
+	p1 := math.NewVec4(float32(x), float32(y), 0, 1).Apply(uniforms.ViewportToWorld).Pos()
+	c := env.Background(p1.Sub(p0).Unit())
+	return color.RGBA{
+		R: uint8(math.Clamp(math.Round(c.X*0xff), 0, 0xff)),
+		G: uint8(math.Clamp(math.Round(c.Y*0xff), 0, 0xff)),
+		B: uint8(math.Clamp(math.Round(c.Z*0xff), 0, 0xff)),
+		A: 0xff,
+	}
+}
 
+// shadeSurface shades a fragment by the lights of the scene and the shadows
+// they cast, without the screen space effects. It returns the color of the
+// fragment and the properties of its material, or the vertex color and nil
+// if the fragment has no material.
+func (r *Renderer) shadeSurface(info buffer.Fragment, uniforms *shader.MVP) (color.RGBA, *material.Standard) {
 	col := info.Col
-	mat := r.material(frag.MaterialID)
-	if mat != nil {
+	mat := r.material(info.MaterialID)
+	std := material.StandardOf(mat)
+	if std != nil {
 		lightSources, lightEnv := r.cfg.Scene.Lights()
-		col = shader.FragmentShader(mat,
-			info,
-			r.cfg.Camera.Position(), lightSources, lightEnv)
+		switch m := mat.(type) {
+		case *material.BlinnPhong:
+			col = shader.FragmentShader(m,
+				info,
+				r.cfg.Camera.Position(), lightSources, lightEnv)
+		case *material.PBR:
+			col = shader.PBRFragmentShader(m,
+				info,
+				r.cfg.Camera.Position(), lightSources, lightEnv)
+		}
 
-		if r.cfg.ShadowMap && mat.ReceiveShadow {
+		if r.cfg.ShadowMap && std.ReceiveShadow {
 			visibles := float32(0.0)
 			ns := len(r.shadowBufs)
 			for i := 0; i < ns; i++ {
-				visible := r.shadingVisibility(i, info, uniforms)
-				if visible {
-					visibles++
-				}
+				visibles += r.shadingVisibility(i, info, uniforms)
 			}
 			w := math.Pow(0.5, visibles)
 			r := uint8(float32(col.R) * w)
@@ -352,10 +512,7 @@ func (r *Renderer) shade(frag *primitive.Fragment, uniforms *shader.MVP) color.R
 			col = color.RGBA{R: r, G: g, B: b, A: col.A}
 		}
 	}
-
-	// FIXME: why it has to be frag?
-	frag.Col = col
-	return material.AmbientOcclusionShade(buf, frag, r.material(frag.MaterialID))
+	return col, std
 }
 
 func (r *Renderer) passAntialiasing() {
@@ -364,6 +521,10 @@ func (r *Renderer) passAntialiasing() {
 		defer done()
 	}
 
+	if r.cfg.Antialiasing == AntialiasingTAA {
+		r.passTAA()
+	}
+
 	// converts color from linear to sRGB space, on the GPU when a device was
 	// provided (render.GPU(dev)), otherwise on the CPU.
 	if r.cfg.GammaCorrect {
@@ -374,73 +535,102 @@ func (r *Renderer) passAntialiasing() {
 			r.DrawFragments(r.CurrBuffer(), shader.GammaCorrection)
 		})
 	}
+	if r.cfg.Antialiasing == AntialiasingFXAA {
+		r.passFXAA()
+	}
 	r.outBuf = imageutil.Resize(r.cfg.Width, r.cfg.Height, r.CurrBuffer().Image())
 }
 
 func (r *Renderer) draw(mvp *shader.MVP, t *primitive.Triangle, flatMatID int64) {
-	buf := r.CurrBuffer()
+	r.drawTo(mvp, t, flatMatID, r.CurrBuffer().Set)
+}
+
+// fragmentSink receives the fragments that pass the depth test of the
+// current buffer.
+type fragmentSink func(x, y int, info buffer.Fragment)
+
+// drawTo rasterizes a triangle against the depth of the current buffer and
+// hands its fragments to the sink.
+func (r *Renderer) drawTo(mvp *shader.MVP, t *primitive.Triangle, flatMatID int64, sink fragmentSink) {
+	r.setupTriangle(mvp, t, func(t1, t2, t3 *primitive.Vertex, recipw [3]float32) {
+		r.drawClipped(mvp, t1, t2, t3, recipw, flatMatID, sink)
+	})
+}
+
+// setupTriangle transforms a triangle to the screen space of the current
+// buffer, clips it against the view frustum, and culls it. It hands the
+// resulting triangles to emit, along with the reciprocal W of their
+// vertices for the perspective corrected interpolation.
+func (r *Renderer) setupTriangle(mvp *shader.MVP, t *primitive.Triangle, emit func(t1, t2, t3 *primitive.Vertex, recipw [3]float32)) {
 	trans := mvp.Proj.MulM(mvp.View).MulM(mvp.Model)
-	t1 := &primitive.Vertex{
-		Pos: trans.MulV(t.V1.Pos),
-		Col: t.V1.Col,
-		UV:  t.V1.UV,
-		Nor: t.V1.Nor.Apply(mvp.Normal),
-	}
-	t2 := &primitive.Vertex{
-		Pos: trans.MulV(t.V2.Pos),
-		Col: t.V2.Col,
-		UV:  t.V2.UV,
-		Nor: t.V2.Nor.Apply(mvp.Normal),
-	}
-	t3 := &primitive.Vertex{
-		Pos: trans.MulV(t.V3.Pos),
-		Col: t.V3.Col,
-		UV:  t.V3.UV,
-		Nor: t.V3.Nor.Apply(mvp.Normal),
-	}
-
-	// For perspective corrected interpolation, see below.
-	recipw := [3]float32{1, 1, 1}
+
+	// The perspective projection negates w, which is turned back so that
+	// w is positive in front of the camera, as the GPU forward pass does.
+	sign := float32(1)
 	if r.cfg.Perspect {
-		recipw = [3]float32{-1 / t1.Pos.W, -1 / t2.Pos.W, -1 / t3.Pos.W}
+		sign = -1
 	}
-
-	t1.Pos = t1.Pos.Apply(mvp.Viewport).Pos()
-	t2.Pos = t2.Pos.Apply(mvp.Viewport).Pos()
-	t3.Pos = t3.Pos.Apply(mvp.Viewport).Pos()
-	if r.cullBackFace(t1.Pos, t2.Pos, t3.Pos) {
-		return
+	poly := []*primitive.Vertex{
+		{
+			Pos: trans.MulV(t.V1.Pos).Scale(sign, sign, sign, sign),
+			Col: t.V1.Col,
+			UV:  t.V1.UV,
+			Nor: t.V1.Nor.Apply(mvp.Normal),
+			Tan: worldTangent(t.V1.Tan, mvp.Model),
+		},
+		{
+			Pos: trans.MulV(t.V2.Pos).Scale(sign, sign, sign, sign),
+			Col: t.V2.Col,
+			UV:  t.V2.UV,
+			Nor: t.V2.Nor.Apply(mvp.Normal),
+			Tan: worldTangent(t.V2.Tan, mvp.Model),
+		},
+		{
+			Pos: trans.MulV(t.V3.Pos).Scale(sign, sign, sign, sign),
+			Col: t.V3.Col,
+			UV:  t.V3.UV,
+			Nor: t.V3.Nor.Apply(mvp.Normal),
+			Tan: worldTangent(t.V3.Tan, mvp.Model),
+		},
 	}
 
-	p1, p2, p3 := t1.Pos.ToVec3(), t2.Pos.ToVec3(), t3.Pos.ToVec3()
-	viewportAABB := primitive.AABB{
-		Min: math.NewVec3[float32](0, 0, -1),
-		Max: math.NewVec3(
-			float32(r.cfg.MSAA*buf.Bounds().Dx()),
-			float32(r.cfg.MSAA*buf.Bounds().Dy()),
-			1,
-		),
+	// Clip in homogeneous clip space before the perspective divide, so that
+	// geometry behind the camera never folds onto the screen, and the
+	// attributes of the new vertices stay perspective correct.
+	poly = clipPolygon(poly)
+	if poly == nil {
+		return
 	}
 
-	// Check whether the triangle have an intersection with the current
-	// viewport AABB or not. If there is no intersection, we can return
-	// immediately.
-	if !viewportAABB.Intersect(primitive.NewAABB(p1, p2, p3)) {
-		return
+	// For perspective corrected interpolation, see fragmentSetup.fragment.
+	recipw := make([]float32, len(poly))
+	for i, v := range poly {
+		recipw[i] = 1
+		if r.cfg.Perspect {
+			recipw[i] = 1 / v.Pos.W
+		}
+		v.Pos = v.Pos.Apply(mvp.Viewport).Pos()
 	}
 
-	// All vertices are inside the viewport, let's rasterize them directly
-	if viewportAABB.Contains(p1, p2, p3) {
-		r.drawClipped(mvp, t1, t2, t3, recipw, flatMatID)
-		return
+	// The clipped polygon is convex and keeps the winding of the triangle,
+	// and so do the triangles of its fan.
+	for i := 2; i < len(poly); i++ {
+		if r.cullBackFace(poly[0].Pos, poly[i-1].Pos, poly[i].Pos) {
+			continue
+		}
+		emit(poly[0], poly[i-1], poly[i], [3]float32{recipw[0], recipw[i-1], recipw[i]})
 	}
+}
 
-	w := float32(r.cfg.MSAA * buf.Bounds().Dx())
-	h := float32(r.cfg.MSAA * buf.Bounds().Dy())
-	tris := r.clipTriangle(t1, t2, t3, w, h, recipw)
-	for _, tri := range tris {
-		r.drawClipped(mvp, tri.V1, tri.V2, tri.V3, recipw, flatMatID)
+// worldTangent transforms the model space tangent t by the model matrix,
+// and keeps its handedness in the W component.
+func worldTangent(t math.Vec4[float32], model math.Mat4[float32]) math.Vec4[float32] {
+	if t.IsZero() {
+		return t
 	}
+	w := math.NewVec4(t.X, t.Y, t.Z, 0).Apply(model)
+	w.W = t.W
+	return w
 }
 
 // interpWorldPos barycentrically interpolates the three world-space vertex
@@ -459,14 +649,9 @@ func interpWorldPos(bc [3]float32, m1, m2, m3 math.Vec4[float32]) math.Vec4[floa
 	}
 }
 
-func (r *Renderer) drawClipped(mvp *shader.MVP, t1, t2, t3 *primitive.Vertex, recipw [3]float32, materialId int64) {
+func (r *Renderer) drawClipped(mvp *shader.MVP, t1, t2, t3 *primitive.Vertex, recipw [3]float32, materialId int64, sink fragmentSink) {
 	buf := r.CurrBuffer()
-
-	// FIXME: do it better.
-	// Transform back to world space for computing illumination
-	m1 := t1.Pos.Apply(mvp.ViewportInv).Apply(mvp.ProjInv).Apply(mvp.ViewInv)
-	m2 := t2.Pos.Apply(mvp.ViewportInv).Apply(mvp.ProjInv).Apply(mvp.ViewInv)
-	m3 := t3.Pos.Apply(mvp.ViewportInv).Apply(mvp.ProjInv).Apply(mvp.ViewInv)
+	fs := r.newFragmentSetup(mvp, t1, t2, t3, recipw, materialId)
 
 	// Compute AABB make the AABB a little bigger that align with
 	// pixels to contain the entire triangle
@@ -476,8 +661,6 @@ func (r *Renderer) drawClipped(mvp *shader.MVP, t1, t2, t3 *primitive.Vertex, re
 	ymin := int(math.Round(aabb.Min.Y) - 1)
 	ymax := int(math.Round(aabb.Max.Y) + 1)
 
-	fN := m2.Sub(m1).Cross(m3.Sub(m1)).Unit()
-
 	for x := xmin; x <= xmax; x++ {
 		for y := ymin; y <= ymax; y++ {
 			if !buf.In(x, y) {
@@ -498,75 +681,131 @@ func (r *Renderer) drawClipped(mvp *shader.MVP, t1, t2, t3 *primitive.Vertex, re
 				continue
 			}
 
-			// Perspective corrected interpolation. See:
-			// Low, Kok-Lim. "Perspective-correct interpolation." Technical writing,
-			// Department of Computer Science, University of North Carolina at Chapel Hill (2002).
-			wc1, wc2, wc3 := recipw[0]*bc[0], recipw[1]*bc[1], recipw[2]*bc[2]
-			norm := float32(1.0)
-			if r.cfg.Perspect {
-				norm = 1 / (wc1 + wc2 + wc3)
-			}
+			// update G-buffer
+			sink(x, y, fs.fragment(x, y, bc, z))
+		}
+	}
+}
 
-			// UV interpolation
-			uvX := (wc1*t1.UV.X + wc2*t2.UV.X + wc3*t3.UV.X) * norm
-			uvY := (wc1*t1.UV.Y + wc2*t2.UV.Y + wc3*t3.UV.Y) * norm
-
-			// Compute du dv (only meaningful for a textured material; the flat
-			// material index is >= 0 when the fragment has one).
-			var du, dv float32
-			if materialId >= 0 {
-				p1 := math.NewVec2(p.X+1, p.Y)
-				p2 := math.NewVec2(p.X, p.Y+1)
-				bcx := math.Barycoord(p1, t1.Pos.ToVec2(), t2.Pos.ToVec2(), t3.Pos.ToVec2())
-				wc1x, wc2x, wc3x := recipw[0]*bcx[0], recipw[1]*bcx[1], recipw[2]*bcx[2]
-				normx := 1 / (wc1x + wc2x + wc3x)
-
-				bcy := math.Barycoord(p2, t1.Pos.ToVec2(), t2.Pos.ToVec2(), t3.Pos.ToVec2())
-				wc1y, wc2y, wc3y := recipw[0]*bcy[0], recipw[1]*bcy[1], recipw[2]*bcy[2]
-				normy := 1 / (wc1y + wc2y + wc3y)
-
-				uvdU := (wc1x*t1.UV.X + wc2x*t2.UV.X + wc3x*t3.UV.X) * normx
-				uvdX := (wc1x*t1.UV.Y + wc2x*t2.UV.Y + wc3x*t3.UV.Y) * normx
-
-				uvdV := (wc1y*t1.UV.X + wc2y*t2.UV.X + wc3y*t3.UV.X) * normy
-				uvdY := (wc1y*t1.UV.Y + wc2y*t2.UV.Y + wc3y*t3.UV.Y) * normy
-				du = (uvdU-uvX)*(uvdU-uvX) + (uvdX-uvY)*(uvdX-uvY)
-				dv = (uvdV-uvX)*(uvdV-uvX) + (uvdY-uvY)*(uvdY-uvY)
-			}
+// fragmentSetup holds the state of a screen space triangle that the
+// interpolation of its fragments shares.
+type fragmentSetup struct {
+	t1, t2, t3 *primitive.Vertex
+	recipw     [3]float32
+	perspect   bool
+	materialId int64
+
+	// The world space positions and face normal of the triangle, and the
+	// normal map of its material if the triangle carries tangents.
+	m1, m2, m3 math.Vec4[float32]
+	fN         math.Vec4[float32]
+	normalMap  *material.Standard
+}
 
-			// normal interpolation (normals are in model space, no need for perspective correction)
-			n := (math.Vec4[float32]{
-				X: (bc[0]*t1.Nor.X + bc[1]*t2.Nor.X + bc[2]*t3.Nor.X),
-				Y: (bc[0]*t1.Nor.Y + bc[1]*t2.Nor.Y + bc[2]*t3.Nor.Y),
-				Z: (bc[0]*t1.Nor.Z + bc[1]*t2.Nor.Z + bc[2]*t3.Nor.Z),
-				W: 0,
-			}).Unit()
-			pos := interpWorldPos(bc, m1, m2, m3)
-			col := color.RGBA{
-				R: uint8(math.Clamp((wc1*float32(t1.Col.R)+wc2*float32(t2.Col.R)+wc3*float32(t3.Col.R))*norm, 0, 0xff)),
-				G: uint8(math.Clamp((wc1*float32(t1.Col.G)+wc2*float32(t2.Col.G)+wc3*float32(t3.Col.G))*norm, 0, 0xff)),
-				B: uint8(math.Clamp((wc1*float32(t1.Col.B)+wc2*float32(t2.Col.B)+wc3*float32(t3.Col.B))*norm, 0, 0xff)),
-				A: uint8(math.Clamp((wc1*float32(t1.Col.A)+wc2*float32(t2.Col.A)+wc3*float32(t3.Col.A))*norm, 0, 0xff)),
-			}
+func (r *Renderer) newFragmentSetup(mvp *shader.MVP, t1, t2, t3 *primitive.Vertex, recipw [3]float32, materialId int64) *fragmentSetup {
+	fs := &fragmentSetup{
+		t1: t1, t2: t2, t3: t3,
+		recipw:     recipw,
+		perspect:   r.cfg.Perspect,
+		materialId: materialId,
+	}
 
-			// update G-buffer
-			buf.Set(x, y, buffer.Fragment{
-				Ok: true,
-				Fragment: primitive.Fragment{
-					X:          x,
-					Y:          y,
-					Depth:      z,
-					U:          uvX,
-					V:          uvY,
-					Du:         du,
-					Dv:         dv,
-					Nor:        n,
-					FaceNor:    fN,
-					WordPos:    pos,
-					Col:        col,
-					MaterialID: materialId,
-				},
-			})
+	// FIXME: do it better.
+	// Transform back to world space for computing illumination
+	fs.m1 = t1.Pos.Apply(mvp.ViewportInv).Apply(mvp.ProjInv).Apply(mvp.ViewInv)
+	fs.m2 = t2.Pos.Apply(mvp.ViewportInv).Apply(mvp.ProjInv).Apply(mvp.ViewInv)
+	fs.m3 = t3.Pos.Apply(mvp.ViewportInv).Apply(mvp.ProjInv).Apply(mvp.ViewInv)
+	fs.fN = fs.m2.Sub(fs.m1).Cross(fs.m3.Sub(fs.m1)).Unit()
+
+	if materialId >= 0 && !t1.Tan.IsZero() {
+		if std := material.StandardOf(r.material(materialId)); std != nil && std.NormalMap != nil {
+			fs.normalMap = std
+		}
+	}
+	return fs
+}
+
+// fragment interpolates the fragment of the pixel (x, y) of the given
+// barycentric coordinates and depth.
+func (fs *fragmentSetup) fragment(x, y int, bc [3]float32, z float32) buffer.Fragment {
+	t1, t2, t3, recipw := fs.t1, fs.t2, fs.t3, fs.recipw
+	p := math.NewVec2(float32(x)+0.5, float32(y)+0.5)
+
+	// Perspective corrected interpolation. See:
+	// Low, Kok-Lim. "Perspective-correct interpolation." Technical writing,
+	// Department of Computer Science, University of North Carolina at Chapel Hill (2002).
+	wc1, wc2, wc3 := recipw[0]*bc[0], recipw[1]*bc[1], recipw[2]*bc[2]
+	norm := float32(1.0)
+	if fs.perspect {
+		norm = 1 / (wc1 + wc2 + wc3)
+	}
+
+	// UV interpolation
+	uvX := (wc1*t1.UV.X + wc2*t2.UV.X + wc3*t3.UV.X) * norm
+	uvY := (wc1*t1.UV.Y + wc2*t2.UV.Y + wc3*t3.UV.Y) * norm
+
+	// Compute du dv (only meaningful for a textured material; the flat
+	// material index is >= 0 when the fragment has one).
+	var du, dv float32
+	if fs.materialId >= 0 {
+		p1 := math.NewVec2(p.X+1, p.Y)
+		p2 := math.NewVec2(p.X, p.Y+1)
+		bcx := math.Barycoord(p1, t1.Pos.ToVec2(), t2.Pos.ToVec2(), t3.Pos.ToVec2())
+		wc1x, wc2x, wc3x := recipw[0]*bcx[0], recipw[1]*bcx[1], recipw[2]*bcx[2]
+		normx := 1 / (wc1x + wc2x + wc3x)
+
+		bcy := math.Barycoord(p2, t1.Pos.ToVec2(), t2.Pos.ToVec2(), t3.Pos.ToVec2())
+		wc1y, wc2y, wc3y := recipw[0]*bcy[0], recipw[1]*bcy[1], recipw[2]*bcy[2]
+		normy := 1 / (wc1y + wc2y + wc3y)
+
+		uvdU := (wc1x*t1.UV.X + wc2x*t2.UV.X + wc3x*t3.UV.X) * normx
+		uvdX := (wc1x*t1.UV.Y + wc2x*t2.UV.Y + wc3x*t3.UV.Y) * normx
+
+		uvdV := (wc1y*t1.UV.X + wc2y*t2.UV.X + wc3y*t3.UV.X) * normy
+		uvdY := (wc1y*t1.UV.Y + wc2y*t2.UV.Y + wc3y*t3.UV.Y) * normy
+		du = (uvdU-uvX)*(uvdU-uvX) + (uvdX-uvY)*(uvdX-uvY)
+		dv = (uvdV-uvX)*(uvdV-uvX) + (uvdY-uvY)*(uvdY-uvY)
+	}
+
+	// normal interpolation (normals are in model space, no need for perspective correction)
+	n := (math.Vec4[float32]{
+		X: (bc[0]*t1.Nor.X + bc[1]*t2.Nor.X + bc[2]*t3.Nor.X),
+		Y: (bc[0]*t1.Nor.Y + bc[1]*t2.Nor.Y + bc[2]*t3.Nor.Y),
+		Z: (bc[0]*t1.Nor.Z + bc[1]*t2.Nor.Z + bc[2]*t3.Nor.Z),
+		W: 0,
+	}).Unit()
+	if fs.normalMap != nil {
+		tan := math.Vec4[float32]{
+			X: bc[0]*t1.Tan.X + bc[1]*t2.Tan.X + bc[2]*t3.Tan.X,
+			Y: bc[0]*t1.Tan.Y + bc[1]*t2.Tan.Y + bc[2]*t3.Tan.Y,
+			Z: bc[0]*t1.Tan.Z + bc[1]*t2.Tan.Z + bc[2]*t3.Tan.Z,
+			W: t1.Tan.W,
 		}
+		n = fs.normalMap.PerturbNormal(n, tan, uvX, 1-uvY, du, dv)
+	}
+	pos := interpWorldPos(bc, fs.m1, fs.m2, fs.m3)
+	col := color.RGBA{
+		R: uint8(math.Clamp((wc1*float32(t1.Col.R)+wc2*float32(t2.Col.R)+wc3*float32(t3.Col.R))*norm, 0, 0xff)),
+		G: uint8(math.Clamp((wc1*float32(t1.Col.G)+wc2*float32(t2.Col.G)+wc3*float32(t3.Col.G))*norm, 0, 0xff)),
+		B: uint8(math.Clamp((wc1*float32(t1.Col.B)+wc2*float32(t2.Col.B)+wc3*float32(t3.Col.B))*norm, 0, 0xff)),
+		A: uint8(math.Clamp((wc1*float32(t1.Col.A)+wc2*float32(t2.Col.A)+wc3*float32(t3.Col.A))*norm, 0, 0xff)),
+	}
+
+	return buffer.Fragment{
+		Ok: true,
+		Fragment: primitive.Fragment{
+			X:          x,
+			Y:          y,
+			Depth:      z,
+			U:          uvX,
+			V:          uvY,
+			Du:         du,
+			Dv:         dv,
+			Nor:        n,
+			FaceNor:    fs.fN,
+			WordPos:    pos,
+			Col:        col,
+			MaterialID: fs.materialId,
+		},
 	}
 }
@@ -0,0 +1,352 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"sync"
+
+	"poly.red/buffer"
+	"poly.red/geometry/primitive"
+	"poly.red/math"
+	"poly.red/shader"
+)
+
+// tileSize is the width and height of the screen tiles of the binned
+// rasterizer, in pixels.
+const tileSize = 32
+
+// tileBins collects the screen space triangles of a forward pass for the
+// binned rasterizer, see RasterizerBinned.
+//
+// The setup of the triangles (transform, culling and clipping) runs
+// concurrently, and every submitted triangle owns a slot that keeps the
+// submission order, so that the tiles draw the triangles of equal depth
+// in the same order from one frame to another.
+type tileBins struct {
+	r    *Renderer
+	buf  *buffer.FragmentBuffer
+	tris []*[]binnedTriangle
+
+	// The sample positions of the multisampled rasterization, nil for a
+	// single sample at the pixel center, the storage of the samples of a
+	// tile, and the pixels on the edges of the surfaces of each tile.
+	pattern []math.Vec2[float32]
+	samples sync.Pool
+	edges   [][]msaaEdge
+}
+
+// binnedTriangle is a screen space triangle that is ready to be
+// rasterized by the tiles that its bounding box overlaps.
+type binnedTriangle struct {
+	fs *fragmentSetup
+
+	// The bounding box of the triangle, clamped to the buffer.
+	xmin, xmax, ymin, ymax int
+
+	// The increments of the edge functions of the triangle from one pixel
+	// to the next in x and y, and the doubled signed area. The i-th
+	// barycentric coordinate of a pixel is the value of the i-th function
+	// divided by the area.
+	dx, dy [3]float32
+	area   float32
+}
+
+func (r *Renderer) newTileBins() *tileBins {
+	b := &tileBins{r: r, buf: r.CurrBuffer()}
+	if n := msaaSamples(r.cfg.MSAA); n > 1 {
+		b.pattern = samplePattern(n)
+		b.samples.New = func() any { return newTileSamples(n) }
+	}
+	return b
+}
+
+// submit sets up the given triangle on the scheduler. It must be called
+// from a single goroutine.
+func (b *tileBins) submit(mvp *shader.MVP, t *primitive.Triangle, flatMatID int64) {
+	out := new([]binnedTriangle)
+	b.tris = append(b.tris, out)
+	b.r.sched.Run(func() {
+		if !t.IsValid() {
+			return
+		}
+		b.r.setupTriangle(mvp, t, func(t1, t2, t3 *primitive.Vertex, recipw [3]float32) {
+			if bt, ok := b.setup(mvp, t1, t2, t3, recipw, flatMatID); ok {
+				*out = append(*out, bt)
+			}
+		})
+	})
+}
+
+// setup computes the bounding box and the edge functions of a screen
+// space triangle. It returns false if the triangle covers no pixel.
+func (b *tileBins) setup(mvp *shader.MVP, t1, t2, t3 *primitive.Vertex, recipw [3]float32, materialId int64) (binnedTriangle, bool) {
+	p1, p2, p3 := t1.Pos, t2.Pos, t3.Pos
+
+	// The edge functions are the ones of math.Barycoord.
+	area := (p2.X-p1.X)*(p3.Y-p1.Y) - (p2.Y-p1.Y)*(p3.X-p1.X)
+	if area == 0 {
+		return binnedTriangle{}, false
+	}
+	bt := binnedTriangle{
+		dx:   [3]float32{-(p3.Y - p2.Y), p3.Y - p1.Y, -(p2.Y - p1.Y)},
+		dy:   [3]float32{p3.X - p2.X, -(p3.X - p1.X), p2.X - p1.X},
+		area: area,
+	}
+
+	// Align the bounding box with pixels as drawClipped does.
+	aabb := primitive.NewAABB(p1.ToVec3(), p2.ToVec3(), p3.ToVec3())
+	rect := b.buf.Bounds()
+	bt.xmin = max(int(math.Round(aabb.Min.X)-1), rect.Min.X)
+	bt.xmax = min(int(math.Round(aabb.Max.X)+1), rect.Max.X-1)
+	bt.ymin = max(int(math.Round(aabb.Min.Y)-1), rect.Min.Y)
+	bt.ymax = min(int(math.Round(aabb.Max.Y)+1), rect.Max.Y-1)
+	if bt.xmin > bt.xmax || bt.ymin > bt.ymax {
+		return binnedTriangle{}, false
+	}
+	bt.fs = b.r.newFragmentSetup(mvp, t1, t2, t3, recipw, materialId)
+	return bt, true
+}
+
+// rasterize sorts the submitted triangles into the screen tiles and
+// rasterizes every tile as a concurrent task. The setup of the triangles
+// must be complete.
+func (b *tileBins) rasterize() {
+	rect := b.buf.Bounds()
+	tw := (rect.Dx() + tileSize - 1) / tileSize
+	th := (rect.Dy() + tileSize - 1) / tileSize
+	tiles := make([][]*binnedTriangle, tw*th)
+	for _, out := range b.tris {
+		for i := range *out {
+			bt := &(*out)[i]
+			for ty := (bt.ymin - rect.Min.Y) / tileSize; ty <= (bt.ymax-rect.Min.Y)/tileSize; ty++ {
+				for tx := (bt.xmin - rect.Min.X) / tileSize; tx <= (bt.xmax-rect.Min.X)/tileSize; tx++ {
+					tiles[ty*tw+tx] = append(tiles[ty*tw+tx], bt)
+				}
+			}
+		}
+	}
+
+	if b.pattern != nil {
+		b.edges = make([][]msaaEdge, len(tiles))
+	}
+	for i, tile := range tiles {
+		if len(tile) == 0 {
+			continue
+		}
+		x0 := rect.Min.X + (i%tw)*tileSize
+		y0 := rect.Min.Y + (i/tw)*tileSize
+		b.r.sched.Run(func() {
+			x1 := min(x0+tileSize, rect.Max.X) - 1
+			y1 := min(y0+tileSize, rect.Max.Y) - 1
+			if b.pattern != nil {
+				b.edges[i] = b.drawTileMultisample(tile, x0, y0, x1, y1)
+				return
+			}
+			for _, bt := range tile {
+				b.drawTile(bt, max(x0, bt.xmin), min(x1, bt.xmax), max(y0, bt.ymin), min(y1, bt.ymax))
+			}
+		})
+	}
+	b.r.sched.Wait()
+
+	b.r.msaaEdges = b.r.msaaEdges[:0]
+	for _, edges := range b.edges {
+		b.r.msaaEdges = append(b.r.msaaEdges, edges...)
+	}
+}
+
+// drawTile rasterizes a triangle in the given pixel range of a tile. Only
+// the worker of the tile touches its pixels, hence the depth test and the
+// update of the G-buffer need no lock.
+func (b *tileBins) drawTile(bt *binnedTriangle, xmin, xmax, ymin, ymax int) {
+	t1, t2, t3 := bt.fs.t1.Pos, bt.fs.t2.Pos, bt.fs.t3.Pos
+	for y := ymin; y <= ymax; y++ {
+		// Evaluate the edge functions at the first pixel center of the
+		// row, and step them along the row.
+		px, py := float32(xmin)+0.5, float32(y)+0.5
+		e := [3]float32{
+			(t3.X-t2.X)*(py-t2.Y) - (t3.Y-t2.Y)*(px-t2.X),
+			(px-t1.X)*(t3.Y-t1.Y) - (py-t1.Y)*(t3.X-t1.X),
+			(t2.X-t1.X)*(py-t1.Y) - (t2.Y-t1.Y)*(px-t1.X),
+		}
+		for x := xmin; x <= xmax; x++ {
+			bc := [3]float32{e[0] / bt.area, e[1] / bt.area, e[2] / bt.area}
+			e[0] += bt.dx[0]
+			e[1] += bt.dx[1]
+			e[2] += bt.dx[2]
+
+			// Is inside triangle?
+			if bc[0] < -math.Epsilon || bc[1] < -math.Epsilon || bc[2] < -math.Epsilon {
+				continue
+			}
+
+			// Z-test
+			z := bc[0]*t1.Z + bc[1]*t2.Z + bc[2]*t3.Z
+			if old := b.buf.UnsafeGet(x, y); old.Ok && z <= old.Depth {
+				continue
+			}
+
+			// update G-buffer
+			b.buf.UnsafeSet(x, y, bt.fs.fragment(x, y, bc, z))
+		}
+	}
+}
+
+// tileSamples holds the samples of the pixels of a tile for the
+// multisampled rasterization. A pixel keeps up to one fragment per sample,
+// and each sample refers to the fragment of the surface that covers it.
+type tileSamples struct {
+	n     int
+	depth []float32         // the depth of each sample
+	surf  []uint8           // the fragment slot of each sample, or noSurface
+	frags []buffer.Fragment // n fragment slots per pixel
+}
+
+// noSurface marks a sample that no triangle covers.
+const noSurface = 0xff
+
+func newTileSamples(n int) *tileSamples {
+	return &tileSamples{
+		n:     n,
+		depth: make([]float32, tileSize*tileSize*n),
+		surf:  make([]uint8, tileSize*tileSize*n),
+		frags: make([]buffer.Fragment, tileSize*tileSize*n),
+	}
+}
+
+// drawTileMultisample rasterizes the triangles of the tile of the pixels
+// [x0, x1]x[y0, y1] with a coverage and a depth test per sample, but
+// interpolates the fragment of a triangle once per pixel. Every pixel then
+// stores the fragment that covers most of its samples to the G-buffer, and
+// the pixels that other surfaces or the background share are returned for
+// the resolve.
+func (b *tileBins) drawTileMultisample(tile []*binnedTriangle, x0, y0, x1, y1 int) []msaaEdge {
+	ts := b.samples.Get().(*tileSamples)
+	defer b.samples.Put(ts)
+	n := ts.n
+	for i := range ts.surf {
+		ts.surf[i] = noSurface
+	}
+
+	for _, bt := range tile {
+		t1, t2, t3 := bt.fs.t1.Pos, bt.fs.t2.Pos, bt.fs.t3.Pos
+		for y := max(y0, bt.ymin); y <= min(y1, bt.ymax); y++ {
+			xmin := max(x0, bt.xmin)
+			px, py := float32(xmin)+0.5, float32(y)+0.5
+			e := [3]float32{
+				(t3.X-t2.X)*(py-t2.Y) - (t3.Y-t2.Y)*(px-t2.X),
+				(px-t1.X)*(t3.Y-t1.Y) - (py-t1.Y)*(t3.X-t1.X),
+				(t2.X-t1.X)*(py-t1.Y) - (t2.Y-t1.Y)*(px-t1.X),
+			}
+			for x := xmin; x <= min(x1, bt.xmax); x++ {
+				i := ((y-y0)*tileSize + (x - x0)) * n
+				ec := e
+				e[0] += bt.dx[0]
+				e[1] += bt.dx[1]
+				e[2] += bt.dx[2]
+
+				// Coverage and depth test of every sample.
+				var (
+					mask uint32
+					zs   [maxSamples]float32
+					bcs  [3]float32
+					zc   float32
+				)
+				for s, o := range b.pattern {
+					bc := [3]float32{
+						(ec[0] + bt.dx[0]*o.X + bt.dy[0]*o.Y) / bt.area,
+						(ec[1] + bt.dx[1]*o.X + bt.dy[1]*o.Y) / bt.area,
+						(ec[2] + bt.dx[2]*o.X + bt.dy[2]*o.Y) / bt.area,
+					}
+					if bc[0] < -math.Epsilon || bc[1] < -math.Epsilon || bc[2] < -math.Epsilon {
+						continue
+					}
+					z := bc[0]*t1.Z + bc[1]*t2.Z + bc[2]*t3.Z
+					if ts.surf[i+s] != noSurface && z <= ts.depth[i+s] {
+						continue
+					}
+					if mask == 0 {
+						bcs, zc = bc, z
+					}
+					mask |= 1 << s
+					zs[s] = z
+				}
+				if mask == 0 {
+					continue
+				}
+
+				// Interpolate at the pixel center if the triangle covers it,
+				// or at its first covered sample otherwise.
+				bc := [3]float32{ec[0] / bt.area, ec[1] / bt.area, ec[2] / bt.area}
+				if bc[0] >= -math.Epsilon && bc[1] >= -math.Epsilon && bc[2] >= -math.Epsilon {
+					bcs, zc = bc, bc[0]*t1.Z+bc[1]*t2.Z+bc[2]*t3.Z
+				}
+
+				// The samples the triangle does not take keep referring to at
+				// most n-1 slots, hence a free slot remains.
+				var used uint32
+				for s := 0; s < n; s++ {
+					if mask&(1<<s) == 0 && ts.surf[i+s] != noSurface {
+						used |= 1 << ts.surf[i+s]
+					}
+				}
+				slot := 0
+				for used&(1<<slot) != 0 {
+					slot++
+				}
+				ts.frags[i+slot] = bt.fs.fragment(x, y, bcs, zc)
+				for s := 0; s < n; s++ {
+					if mask&(1<<s) != 0 {
+						ts.surf[i+s] = uint8(slot)
+						ts.depth[i+s] = zs[s]
+					}
+				}
+			}
+		}
+	}
+
+	var edges []msaaEdge
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			i := ((y-y0)*tileSize + (x - x0)) * n
+			var count [maxSamples]int
+			covered := 0
+			for s := 0; s < n; s++ {
+				if ts.surf[i+s] != noSurface {
+					count[ts.surf[i+s]]++
+					covered++
+				}
+			}
+			if covered == 0 {
+				continue
+			}
+
+			// The primary fragment covers most samples, the nearer one of
+			// equal coverage.
+			primary := -1
+			for k := 0; k < n; k++ {
+				if count[k] == 0 {
+					continue
+				}
+				if primary < 0 || count[k] > count[primary] ||
+					count[k] == count[primary] && ts.frags[i+k].Depth > ts.frags[i+primary].Depth {
+					primary = k
+				}
+			}
+			b.buf.UnsafeSet(x, y, ts.frags[i+primary])
+			if count[primary] == n {
+				continue
+			}
+			edge := msaaEdge{x: x, y: y, primary: count[primary]}
+			for k := 0; k < n; k++ {
+				if k != primary && count[k] > 0 {
+					edge.surfaces = append(edge.surfaces, msaaSurface{frag: ts.frags[i+k], samples: count[k]})
+				}
+			}
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/math"
+)
+
+// TestRasterizerBinned checks that the binned rasterizer produces the
+// G-buffer and the image of the immediate one. The two evaluate the edge
+// functions differently in the last bits, which may move a pixel on the
+// shared edge of two triangles from one to the other: a tiny fraction of
+// the pixels may differ. Multisampling always runs on the binned
+// rasterizer, hence the comparison is single sampled.
+func TestRasterizerBinned(t *testing.T) {
+	const w, h = 160, 120
+	bg := color.RGBA{0, 127, 255, 255}
+	render := func(mode RasterizerMode) *Renderer {
+		s, c := newscene(w, h)
+		r := NewRenderer(Camera(c), Size(w, h), MSAA(1), Scene(s), Background(bg), Rasterizer(mode), CPU())
+		r.passForward()
+		return r
+	}
+	want := render(RasterizerImmediate).CurrBuffer()
+	got := render(RasterizerBinned).CurrBuffer()
+
+	covered, differ := 0, 0
+	n := w * h
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a, b := want.UnsafeGet(x, y), got.UnsafeGet(x, y)
+			if a.Ok != b.Ok {
+				differ++
+				continue
+			}
+			if !a.Ok {
+				continue
+			}
+			covered++
+			if math.Abs(a.Depth-b.Depth) > 1e-5 || diff(a.Col.R, b.Col.R) > 1 ||
+				a.MaterialID != b.MaterialID || a.Nor.Dot(b.Nor) < 0.999 {
+				differ++
+			}
+		}
+	}
+	if covered == 0 {
+		t.Fatal("the bunny is missing")
+	}
+	if differ > n/500 {
+		t.Fatalf("%d of %d fragments differ between the binned and immediate rasterizers", differ, n)
+	}
+
+	imgs := [2][]uint8{}
+	for i, mode := range []RasterizerMode{RasterizerImmediate, RasterizerBinned} {
+		s, c := newscene(w, h)
+		imgs[i] = NewRenderer(Camera(c), Size(w, h), MSAA(1), Scene(s), Background(bg), Rasterizer(mode), CPU()).Render().Pix
+	}
+	differ = 0
+	for i := range imgs[0] {
+		if diff(imgs[0][i], imgs[1][i]) > 2 {
+			differ++
+		}
+	}
+	if differ > len(imgs[0])/500 {
+		t.Fatalf("%d of %d channels differ between the binned and immediate images", differ, len(imgs[0]))
+	}
+}
+
+func BenchmarkRasterizerMode(b *testing.B) {
+	w, h := 800, 600
+	s, c := newscene(w, h)
+	for _, mode := range []struct {
+		name string
+		mode RasterizerMode
+	}{
+		{"immediate", RasterizerImmediate},
+		{"binned", RasterizerBinned},
+	} {
+		r := NewRenderer(Camera(c), Size(w, h), MSAA(1), Scene(s), Rasterizer(mode.mode), CPU())
+		b.Run(mode.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.passForward()
+			}
+		})
+	}
+}
@@ -4,29 +4,42 @@
 
 package render
 
-import "image/color"
+import "poly.red/color"
 
 // BlendFunc is a blending function for two given colors and returns
 // the resulting color.
 type BlendFunc func(dst, src color.RGBA) color.RGBA
 
-// AlphaBlend performs alpha blending for pre-multiplied alpha RGBA colors
+// AlphaBlend performs alpha blending for pre-multiplied alpha RGBA colors,
+// that is the Porter-Duff "source over" operator.
 func AlphaBlend(dst, src color.RGBA) color.RGBA {
-	// FIXME: there is an overflow
 	sr, sg, sb, sa := uint32(src.R), uint32(src.G), uint32(src.B), uint32(src.A)
 	dr, dg, db, da := uint32(dst.R), uint32(dst.G), uint32(dst.B), uint32(dst.A)
 
-	// dr, dg, db and da are all 8-bit color at the moment, ranging in [0,255].
-	// We work in 16-bit color, and so would normally do:
-	// dr |= dr << 8
-	// and similarly for dg, db and da, but instead we multiply a
-	// (which is a 16-bit color, ranging in [0,65535]) by 0x101.
-	// This yields the same result, but is fewer arithmetic operations.
-	a := (0xffff - sa) * 0x101
+	// The colors are 8-bit, hence d*(0xff-sa) fits easily in 32 bits and
+	// is divided by 0xff with rounding. A valid premultiplied source has
+	// components that are at most its alpha, which keeps the sums in
+	// range. The sums are saturated nevertheless to tolerate invalid
+	// colors.
+	a := 0xff - sa
+	r := sr + (dr*a+0x7f)/0xff
+	g := sg + (dg*a+0x7f)/0xff
+	b := sb + (db*a+0x7f)/0xff
+	aa := sa + (da*a+0x7f)/0xff
+	return color.RGBA{
+		R: uint8(min(r, 0xff)),
+		G: uint8(min(g, 0xff)),
+		B: uint8(min(b, 0xff)),
+		A: uint8(min(aa, 0xff)),
+	}
+}
 
-	r := sr + dr*a/0xffff
-	g := sg + dg*a/0xffff
-	b := sb + db*a/0xffff
-	aa := sa + da*a/0xffff
-	return color.RGBA{uint8(r), uint8(g), uint8(b), uint8(aa)}
+// Blend returns the blend function of the given blend mode.
+func Blend(mode color.BlendMode) BlendFunc {
+	if mode == color.BlendSrcOver {
+		return AlphaBlend
+	}
+	return func(dst, src color.RGBA) color.RGBA {
+		return color.Blend(mode, dst, src)
+	}
 }
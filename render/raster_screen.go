@@ -76,6 +76,10 @@ func (r *Renderer) DrawFragment(buf *buffer.FragmentBuffer, x, y int, shaders ..
 	info := buf.UnsafeGet(x, y)
 	old := info.Col
 
+	// The fragments of the pixels that no geometry covers are zero, but
+	// the shaders may still depend on their coordinates.
+	info.X, info.Y = x, y
+
 	for i := 0; i < len(shaders); i++ {
 		info.Col = shaders[i](&info.Fragment)
 	}
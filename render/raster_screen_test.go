@@ -29,6 +29,30 @@ func BenchmarkAlphaBlend(b *testing.B) {
 	_ = c
 }
 
+func TestAlphaBlend(t *testing.T) {
+	// AlphaBlend is the integer fast path of the source over operator.
+	for _, sa := range []uint8{0, 1, 64, 128, 200, 254, 255} {
+		for _, dv := range []uint8{0, 37, 128, 255} {
+			src := color.RGBA{R: sa, G: sa / 2, B: 0, A: sa}
+			dst := color.RGBA{R: dv, G: dv, B: dv, A: 255}
+			got := render.AlphaBlend(dst, src)
+			want := color.Blend(color.BlendSrcOver, dst, src)
+			if d := int(got.R) - int(want.R); d < -1 || d > 1 {
+				t.Fatalf("AlphaBlend(%v, %v) = %v, want %v", dst, src, got, want)
+			}
+			if got.A != 255 {
+				t.Fatalf("AlphaBlend(%v, %v) = %v, want opaque", dst, src, got)
+			}
+		}
+	}
+
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	grey := color.RGBA{R: 128, G: 128, B: 128, A: 128}
+	if got := render.AlphaBlend(white, grey); got != white {
+		t.Fatalf("AlphaBlend(%v, %v) = %v, want %v", white, grey, got, white)
+	}
+}
+
 func TestDrawPixels(t *testing.T) {
 	tests := []struct {
 		w int
@@ -29,6 +29,11 @@ import (
 // forward rasterizer's boundary parity band.
 func forwardOnCPU() Option { return func(o *option) { o.forwardCPU = true } }
 
+// gbufferReadback forces the GPU forward pass to read its G-buffer back to
+// the fragment buffer, as if the deferred pass could not shade it on the
+// device. Parity gates of the resident G-buffer render the reference with it.
+func gbufferReadback() Option { return func(o *option) { o.gbufReadback = true } }
+
 func newscene(w, h int) (*scene.Scene, camera.Interface) {
 	s := scene.NewScene(light.NewPoint(
 		light.Intensity(5),
@@ -39,37 +39,17 @@ func TestDeferredShadingEquivalence(t *testing.T) {
 		material.Shininess(32),
 	)
 
-	camPos := math.NewVec3[float32](0, 1.5, 3)
-	ls := []light.Source{
-		light.NewPoint(light.Intensity(3), light.Color(color.RGBA{R: 255, G: 240, B: 220, A: 255}), light.Position(math.NewVec3[float32](-2, 3, 4))),
-		light.NewDirectional(light.Intensity(1), light.Color(color.RGBA{R: 180, G: 200, B: 255, A: 255}), light.Direction(math.NewVec3[float32](0, -1, -1))),
-	}
-	es := []light.Environment{light.NewAmbient(light.Intensity(0.4))}
-
-	// G-buffer fragments spanning many shading angles and positions.
-	norms := [][3]float32{
-		{0, 1, 0}, {0, 0, 1}, {1, 0, 0}, {0.577, 0.577, 0.577}, {-0.4, 0.8, 0.45}, {0.3, -0.2, 0.93},
-	}
-	poss := [][3]float32{
-		{0, 0, 0}, {1, 0.5, -1}, {-1, 1, 0.5}, {0.2, -0.3, 1}, {-0.6, 0.1, -0.4}, {0.9, 0.9, 0.2},
-	}
+	camPos, ls, es := equivalenceLights()
 
 	// Mirror render/gpudeferred.go's marshaling for the shared inputs.
 	materials := []float32{
 		float32(mat.Diffuse.R), float32(mat.Diffuse.G), float32(mat.Diffuse.B), float32(mat.Diffuse.A),
 		float32(mat.Specular.R), float32(mat.Specular.G), float32(mat.Specular.B), float32(mat.Specular.A),
-		mat.Shininess,
+		mat.Shininess, 0,
 	}
-	var lightData []float32
-	for _, l := range ls {
-		switch lt := l.(type) {
-		case *light.Point:
-			p, c := lt.Position(), lt.Color()
-			lightData = append(lightData, 0, p.X, p.Y, p.Z, 1, float32(c.R), float32(c.G), float32(c.B), float32(c.A), lt.Intensity())
-		case *light.Directional:
-			d, c := lt.Dir(), lt.Color()
-			lightData = append(lightData, 1, d.X, d.Y, d.Z, 0, float32(c.R), float32(c.G), float32(c.B), float32(c.A), lt.Intensity())
-		}
+	lightData, ok := packLights(ls)
+	if !ok {
+		t.Fatal("packLights: unsupported light")
 	}
 	var ambientI float32
 	for _, e := range es {
@@ -79,9 +59,9 @@ func TestDeferredShadingEquivalence(t *testing.T) {
 
 	q := func(v float32) uint8 { return uint8(math.Clamp(math.Round(v), 0, 0xff)) }
 
-	for i := range norms {
-		nx, ny, nz := norms[i][0], norms[i][1], norms[i][2]
-		px, py, pz := poss[i][0], poss[i][1], poss[i][2]
+	for i := range equivalenceNorms {
+		nx, ny, nz := equivalenceNorms[i][0], equivalenceNorms[i][1], equivalenceNorms[i][2]
+		px, py, pz := equivalencePoss[i][0], equivalencePoss[i][1], equivalencePoss[i][2]
 		info := buffer.Fragment{Ok: true, Fragment: primitive.Fragment{
 			Nor:     math.NewVec4[float32](nx, ny, nz, 0),
 			WordPos: math.NewVec4[float32](px, py, pz, 1),
@@ -96,7 +76,7 @@ func TestDeferredShadingEquivalence(t *testing.T) {
 		worldpos := []float32{px, py, pz, 1}
 		basecol := []float32{float32(bc.R), float32(bc.G), float32(bc.B), float32(bc.A)}
 		out := make([]float32, 4)
-		kernels.Shade(0, normals, worldpos, basecol, lightData, []float32{0}, materials, scene, out)
+		kernels.Shade(0, normals, worldpos, basecol, lightData, []float32{0}, materials, make([]float32, 8), scene, []float32{0, 0, 0, 0}, out)
 
 		kr, kg, kb := q(out[0]), q(out[1]), q(out[2])
 		if diff(cpu.R, kr) > 1 || diff(cpu.G, kg) > 1 || diff(cpu.B, kb) > 1 {
@@ -106,6 +86,95 @@ func TestDeferredShadingEquivalence(t *testing.T) {
 	}
 }
 
+// equivalenceLights returns the camera position and the lights that the
+// equivalence tests shade with: one light of each type plus an ambient light.
+func equivalenceLights() (math.Vec3[float32], []light.Source, []light.Environment) {
+	camPos := math.NewVec3[float32](0, 1.5, 3)
+	ls := []light.Source{
+		light.NewPoint(light.Intensity(3), light.Color(color.RGBA{R: 255, G: 240, B: 220, A: 255}), light.Position(math.NewVec3[float32](-2, 3, 4))),
+		light.NewDirectional(light.Intensity(1), light.Color(color.RGBA{R: 180, G: 200, B: 255, A: 255}), light.Direction(math.NewVec3[float32](0, -1, -1))),
+		light.NewSpot(light.Intensity(4), light.Color(color.RGBA{R: 255, G: 200, B: 160, A: 255}), light.Position(math.NewVec3[float32](0.5, 3, 1)),
+			light.Direction(math.NewVec3[float32](-0.1, -1, -0.3)), light.Cone(20, 50), light.Range(8)),
+		light.NewArea(light.Intensity(2), light.Color(color.RGBA{R: 220, G: 255, B: 230, A: 255}), light.Position(math.NewVec3[float32](-0.5, 2.5, 1.5)),
+			light.Direction(math.NewVec3[float32](0.2, -1, -0.5)), light.Size(1.5, 0.8)),
+	}
+	es := []light.Environment{light.NewAmbient(light.Intensity(0.4))}
+	return camPos, ls, es
+}
+
+// G-buffer fragments spanning many shading angles and positions.
+var (
+	equivalenceNorms = [][3]float32{
+		{0, 1, 0}, {0, 0, 1}, {1, 0, 0}, {0.577, 0.577, 0.577}, {-0.4, 0.8, 0.45}, {0.3, -0.2, 0.93},
+	}
+	equivalencePoss = [][3]float32{
+		{0, 0, 0}, {1, 0.5, -1}, {-1, 1, 0.5}, {0.2, -0.3, 1}, {-0.6, 0.1, -0.4}, {0.9, 0.9, 0.2},
+	}
+)
+
+// TestDeferredPBRShadingEquivalence locks the CPU metallic-roughness shader
+// (shader.PBRFragmentShader) to the same kernel, for rough and smooth
+// dielectrics and metals with and without texture maps.
+func TestDeferredPBRShadingEquivalence(t *testing.T) {
+	camPos, ls, es := equivalenceLights()
+	mats := []*material.PBR{
+		material.NewPBR(material.BaseColor(color.RGBA{R: 200, G: 80, B: 60, A: 255})),
+		material.NewPBR(
+			material.BaseColor(color.RGBA{R: 250, G: 200, B: 120, A: 255}),
+			material.Metallic(1), material.Roughness(0.2),
+		),
+		material.NewPBR(
+			material.Texture(buffer.NewUniformTexture(color.RGBA{R: 120, G: 200, B: 180, A: 255})),
+			material.MetallicRoughnessMap(buffer.NewUniformTexture(color.RGBA{R: 0, G: 100, B: 200, A: 255})),
+			material.OcclusionMap(buffer.NewUniformTexture(color.RGBA{R: 160, G: 0, B: 0, A: 255})),
+			material.Emissive(color.RGBA{R: 40, G: 20, B: 0, A: 255}),
+			material.EmissiveMap(buffer.NewUniformTexture(color.RGBA{R: 255, G: 128, B: 255, A: 255})),
+			material.Metallic(0.7), material.Roughness(0.6),
+		),
+	}
+
+	lightData, ok := packLights(ls)
+	if !ok {
+		t.Fatal("packLights: unsupported light")
+	}
+	var ambientI float32
+	for _, e := range es {
+		ambientI += e.Intensity()
+	}
+	scene := []float32{camPos.X, camPos.Y, camPos.Z, 1, ambientI, float32(len(ls)), 0, 0}
+	materials := []float32{0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+
+	q := func(v float32) uint8 { return uint8(math.Clamp(math.Round(v), 0, 0xff)) }
+	for mi, mat := range mats {
+		for i := range equivalenceNorms {
+			n := equivalenceNorms[i]
+			p := equivalencePoss[i]
+			info := buffer.Fragment{Ok: true, Fragment: primitive.Fragment{
+				Nor:     math.NewVec4[float32](n[0], n[1], n[2], 0),
+				WordPos: math.NewVec4[float32](p[0], p[1], p[2], 1),
+				U:       0.5, V: 0.5,
+			}}
+
+			cpu := shader.PBRFragmentShader(mat, info, camPos, ls, es)
+
+			// Mirror render/gpudeferred.go's marshaling of the surface.
+			sf := mat.Surface(info.U, 1-info.V, info.Du, info.Dv)
+			bc := sf.BaseColor.Scale(0xff, 0xff, 0xff, 0xff)
+			surface := []float32{sf.Metallic, sf.Roughness, sf.Occlusion, 0,
+				sf.Emissive.X * 0xff, sf.Emissive.Y * 0xff, sf.Emissive.Z * 0xff, 0}
+			out := make([]float32, 4)
+			kernels.Shade(0, []float32{n[0], n[1], n[2], 0}, []float32{p[0], p[1], p[2], 1},
+				[]float32{bc.X, bc.Y, bc.Z, bc.W}, lightData, []float32{0}, materials, surface, scene, []float32{0, 0, 0, 0}, out)
+
+			kr, kg, kb := q(out[0]), q(out[1]), q(out[2])
+			if diff(cpu.R, kr) > 1 || diff(cpu.G, kg) > 1 || diff(cpu.B, kb) > 1 {
+				t.Errorf("mat %d frag %d: PBRFragmentShader=(%d,%d,%d) kernels.Shade=(%d,%d,%d): differ by >1",
+					mi, i, cpu.R, cpu.G, cpu.B, kr, kg, kb)
+			}
+		}
+	}
+}
+
 func diff(a, b uint8) int {
 	if a > b {
 		return int(a - b)
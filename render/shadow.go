@@ -13,6 +13,7 @@ import (
 	"poly.red/camera"
 	"poly.red/geometry"
 	"poly.red/geometry/primitive"
+	"poly.red/gpu/shader/gpumath/kernels"
 	"poly.red/internal/imageutil"
 	"poly.red/light"
 	"poly.red/math"
@@ -23,9 +24,22 @@ import (
 	"poly.red/internal/spinlock"
 )
 
+// shadowInfo holds the shadow maps of a light source. Directional and spot
+// lights render a single face, point lights render six faces of a cube map,
+// and area lights render a face from several points of their rectangle.
 type shadowInfo struct {
 	active bool
+	faces  []shadowFace
+}
+
+// shadowFace is a single depth map rendered from the given camera.
+//
+// A fragment is as much in shadow as in the face that shadows it most,
+// unless the face has a weight: then the shadow is the weighted sum over
+// the faces, i.e. the visibility averaged from several points of a light.
+type shadowFace struct {
 	camera camera.Interface
+	weight float32
 	depths []float32
 	lock   []spinlock.SpinLock
 }
@@ -33,60 +47,197 @@ type shadowInfo struct {
 func (r *Renderer) initShadowMaps() {
 	lightSources, _ := r.cfg.Scene.Lights()
 	r.shadowBufs = make([]shadowInfo, len(lightSources))
+	w, h := r.bufs[0].Bounds().Dx(), r.bufs[0].Bounds().Dy()
 	for i := 0; i < len(lightSources); i++ {
 		if !lightSources[i].CastShadow() {
 			continue
 		}
 
-		// initialize scene camera
-		tm := camera.ViewMatrix(
-			lightSources[i].Position(),
-			r.cfg.Scene.Center(),
-			math.NewVec3[float32](0, 1, 0),
-		).MulM(r.cfg.Camera.ViewMatrix().Inv()).MulM(r.cfg.Camera.ProjMatrix().Inv())
-		v1 := math.NewVec4[float32](1, 1, 1, 1).Apply(tm).Pos().ToVec3()
-		v2 := math.NewVec4[float32](1, 1, -1, 1).Apply(tm).Pos().ToVec3()
-		v3 := math.NewVec4[float32](1, -1, 1, 1).Apply(tm).Pos().ToVec3()
-		v4 := math.NewVec4[float32](-1, 1, 1, 1).Apply(tm).Pos().ToVec3()
-		v5 := math.NewVec4[float32](-1, -1, 1, 1).Apply(tm).Pos().ToVec3()
-		v6 := math.NewVec4[float32](1, -1, -1, 1).Apply(tm).Pos().ToVec3()
-		v7 := math.NewVec4[float32](-1, 1, -1, 1).Apply(tm).Pos().ToVec3()
-		v8 := math.NewVec4[float32](-1, -1, -1, 1).Apply(tm).Pos().ToVec3()
-		aabb := primitive.NewAABB(v1, v2, v3, v4, v5, v6, v7, v8)
-		le := aabb.Min.X
-		ri := aabb.Max.X
-		bo := aabb.Min.Y
-		to := aabb.Max.Y
-		ne := aabb.Max.Z
-		fa := aabb.Min.Z - 2
-		// aspect := ri / to
-		// fov := 2 * math.Atan(to/math.Abs(ne))
-
-		li := lightSources[i]
-		var c camera.Interface
-		switch l := li.(type) {
+		var cams []camera.Interface
+		switch l := lightSources[i].(type) {
 		case *light.Point:
-			// TODO: why perspective camera does not work?
-			// c = camera.NewPerspective(
-			// 	li.Position(),
-			// 	r.scene.Center(),
-			// 	math.NewVec4(0, 1, 0, 0),
-			// 	fov, aspect, 0.001, 100,
-			// )
-			// TODO: use cube shadow map for point light
-			c = camera.NewOrthographic(
-				camera.Position(l.Position()),
-				camera.LookAt(r.cfg.Scene.Center(),
-					math.NewVec3[float32](0, 1, 0)),
-				camera.ViewFrustum(le, ri, bo, to, ne, fa),
-			)
+			cams = pointShadowCameras(l, r.geometryBounds())
+		case *light.Spot:
+			cams = []camera.Interface{spotShadowCamera(l, r.geometryBounds(), float32(w)/float32(h))}
+		case *light.Area:
+			cams = areaShadowCameras(l, r.geometryBounds())
 		default:
+			cams = []camera.Interface{r.orthoShadowCamera(l)}
 		}
+
 		r.shadowBufs[i].active = true
-		r.shadowBufs[i].camera = c
-		r.shadowBufs[i].depths = make([]float32, r.bufs[0].Bounds().Dx()*r.bufs[0].Bounds().Dy())
-		r.shadowBufs[i].lock = make([]spinlock.SpinLock, r.bufs[0].Bounds().Dx()*r.bufs[0].Bounds().Dy())
+		r.shadowBufs[i].faces = make([]shadowFace, len(cams))
+		for j, c := range cams {
+			f := &r.shadowBufs[i].faces[j]
+			f.camera = c
+			if _, ok := lightSources[i].(*light.Area); ok {
+				f.weight = 1 / float32(len(cams))
+			}
+			f.depths = make([]float32, w*h)
+			f.lock = make([]spinlock.SpinLock, w*h)
+
+			// A perspective shadow map spans the whole [-1, 1] depth range,
+			// clear it to the far plane so that every occluder can be recorded.
+			if _, ok := c.(*camera.Perspective); ok {
+				for k := range f.depths {
+					f.depths[k] = -1
+				}
+			}
+		}
+	}
+}
+
+// orthoShadowCamera returns an orthographic camera that looks from the light
+// position to the scene center and covers the view frustum of the scene camera.
+func (r *Renderer) orthoShadowCamera(l light.Source) camera.Interface {
+	tm := camera.ViewMatrix(
+		l.Position(),
+		r.cfg.Scene.Center(),
+		math.NewVec3[float32](0, 1, 0),
+	).MulM(r.cfg.Camera.ViewMatrix().Inv()).MulM(r.cfg.Camera.ProjMatrix().Inv())
+	v1 := math.NewVec4[float32](1, 1, 1, 1).Apply(tm).Pos().ToVec3()
+	v2 := math.NewVec4[float32](1, 1, -1, 1).Apply(tm).Pos().ToVec3()
+	v3 := math.NewVec4[float32](1, -1, 1, 1).Apply(tm).Pos().ToVec3()
+	v4 := math.NewVec4[float32](-1, 1, 1, 1).Apply(tm).Pos().ToVec3()
+	v5 := math.NewVec4[float32](-1, -1, 1, 1).Apply(tm).Pos().ToVec3()
+	v6 := math.NewVec4[float32](1, -1, -1, 1).Apply(tm).Pos().ToVec3()
+	v7 := math.NewVec4[float32](-1, 1, -1, 1).Apply(tm).Pos().ToVec3()
+	v8 := math.NewVec4[float32](-1, -1, -1, 1).Apply(tm).Pos().ToVec3()
+	aabb := primitive.NewAABB(v1, v2, v3, v4, v5, v6, v7, v8)
+	le := aabb.Min.X
+	ri := aabb.Max.X
+	bo := aabb.Min.Y
+	to := aabb.Max.Y
+	ne := aabb.Max.Z
+	fa := aabb.Min.Z - 2
+	return camera.NewOrthographic(
+		camera.Position(l.Position()),
+		camera.LookAt(r.cfg.Scene.Center(),
+			math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(le, ri, bo, to, ne, fa),
+	)
+}
+
+// geometryBounds returns the world space bounding box of all geometries in the
+// scene. Unlike Scene.AABB, it applies the model matrices and leaves lights out.
+func (r *Renderer) geometryBounds() primitive.AABB {
+	var aabb *primitive.AABB
+	scene.IterObjects(r.cfg.Scene, func(g *geometry.Geometry, modelMatrix math.Mat4[float32]) bool {
+		m := modelMatrix.MulM(g.ModelMatrix())
+		for _, p := range aabbCorners(g.AABB()) {
+			q := primitive.NewAABB(p.ToVec4(1).Apply(m).ToVec3())
+			if aabb == nil {
+				aabb = &q
+			} else {
+				aabb.Add(q)
+			}
+		}
+		return true
+	})
+	if aabb == nil {
+		return primitive.AABB{}
 	}
+	return *aabb
+}
+
+func aabbCorners(aabb primitive.AABB) []math.Vec3[float32] {
+	return []math.Vec3[float32]{
+		math.NewVec3(aabb.Min.X, aabb.Min.Y, aabb.Min.Z),
+		math.NewVec3(aabb.Min.X, aabb.Min.Y, aabb.Max.Z),
+		math.NewVec3(aabb.Min.X, aabb.Max.Y, aabb.Min.Z),
+		math.NewVec3(aabb.Min.X, aabb.Max.Y, aabb.Max.Z),
+		math.NewVec3(aabb.Max.X, aabb.Min.Y, aabb.Min.Z),
+		math.NewVec3(aabb.Max.X, aabb.Min.Y, aabb.Max.Z),
+		math.NewVec3(aabb.Max.X, aabb.Max.Y, aabb.Min.Z),
+		math.NewVec3(aabb.Max.X, aabb.Max.Y, aabb.Max.Z),
+	}
+}
+
+// spotShadowCamera returns the perspective camera that renders the shadow map
+// of a spot light. It looks down the light direction with a field of view that
+// covers the outer cone.
+func spotShadowCamera(l *light.Spot, aabb primitive.AABB, aspect float32) camera.Interface {
+	_, outer := l.Cone()
+	return perspectiveShadowCamera(l.Position(), l.Dir(),
+		math.Min(2*outer+2, 170), aspect, aabb, l.Range())
+}
+
+// cubeFaces are the view directions of the six cube shadow map faces.
+var cubeFaces = [6]math.Vec3[float32]{
+	{X: 1}, {X: -1},
+	{Y: 1}, {Y: -1},
+	{Z: 1}, {Z: -1},
+}
+
+// pointShadowCameras returns the six cameras of the cube shadow map of a
+// point light. Each face has a 90 degree field of view in both directions,
+// regardless of the shadow map size, so that together they cover every
+// direction around the light exactly once.
+func pointShadowCameras(l *light.Point, aabb primitive.AABB) []camera.Interface {
+	cams := make([]camera.Interface, len(cubeFaces))
+	for i, f := range cubeFaces {
+		cams[i] = perspectiveShadowCamera(l.Position(), f, 90, 1, aabb, 0)
+	}
+	return cams
+}
+
+// areaShadowCameras returns the cameras that render the shadow maps of an
+// area light from four points of its rectangle. Averaging the visibility
+// from them results in soft shadows. The field of view covers the scene as
+// seen from the front side of the light.
+func areaShadowCameras(l *light.Area, aabb primitive.AABB) []camera.Interface {
+	pos, dir := l.Position(), l.Dir()
+	angle := float32(0)
+	for _, p := range aabbCorners(aabb) {
+		if d := p.Sub(pos); d.Len() > 0 {
+			angle = math.Max(angle, math.Acos(math.Clamp(d.Unit().Dot(dir), -1, 1)))
+		}
+	}
+	fov := math.Clamp(2*angle*180/math.Pi+2, 10, 160)
+
+	u, v := l.Extents()
+	cams := make([]camera.Interface, 0, 4)
+	for _, s := range []float32{-0.5, 0.5} {
+		for _, t := range []float32{-0.5, 0.5} {
+			p := pos.Add(u.Scale(s, s, s)).Add(v.Scale(t, t, t))
+			cams = append(cams, perspectiveShadowCamera(p, dir, fov, 1, aabb, 0))
+		}
+	}
+	return cams
+}
+
+// perspectiveShadowCamera returns a perspective shadow camera at pos looking
+// towards dir. Its near and far planes are fitted to the scene bounding box
+// (and to the light range if it is positive) so that the constant depth bias
+// stays meaningful.
+func perspectiveShadowCamera(pos, dir math.Vec3[float32], fov, aspect float32,
+	aabb primitive.AABB, lightRange float32) camera.Interface {
+	near, far := float32(math.MaxFloat32), float32(0)
+	for _, p := range aabbCorners(aabb) {
+		d := p.Sub(pos).Dot(dir)
+		near = math.Min(near, d)
+		far = math.Max(far, d)
+	}
+	near = math.Max(near*0.9, 0.05)
+	far *= 1.1
+	if lightRange > 0 {
+		far = math.Min(far, lightRange)
+	}
+	if far <= near {
+		far = near + 1
+	}
+
+	// The view matrix is degenerated if the up direction is parallel
+	// to the view direction.
+	up := math.NewVec3[float32](0, 1, 0)
+	if math.Abs(dir.Y) > 0.99 {
+		up = math.NewVec3[float32](0, 0, 1)
+	}
+	return camera.NewPerspective(
+		camera.Position(pos),
+		camera.LookAt(pos.Add(dir), up),
+		camera.ViewFrustum(fov, aspect, near, far),
+	)
 }
 
 func (r *Renderer) passShadows(index int) {
@@ -99,29 +250,38 @@ func (r *Renderer) passShadows(index int) {
 		done := profiling.Timed("forward pass (shadow)")
 		defer done()
 		defer func() {
-			img := image.NewRGBA(image.Rect(0, 0, r.bufs[0].Bounds().Dx(), r.bufs[0].Bounds().Dy()))
-			for i := 0; i < r.bufs[0].Bounds().Dx(); i++ {
-				for j := 0; j < r.bufs[0].Bounds().Dy(); j++ {
-					z := r.shadowBufs[index].depths[i+(r.bufs[0].Bounds().Dy()-j-1)*r.bufs[0].Bounds().Dx()]
-					img.SetRGBA(i, j, color.RGBA{
-						uint8(z * 255),
-						uint8(z * 255),
-						uint8(z * 255),
-						255,
-					})
+			for k, f := range r.shadowBufs[index].faces {
+				img := image.NewRGBA(image.Rect(0, 0, r.bufs[0].Bounds().Dx(), r.bufs[0].Bounds().Dy()))
+				for i := 0; i < r.bufs[0].Bounds().Dx(); i++ {
+					for j := 0; j < r.bufs[0].Bounds().Dy(); j++ {
+						z := f.depths[i+(r.bufs[0].Bounds().Dy()-j-1)*r.bufs[0].Bounds().Dx()]
+						img.SetRGBA(i, j, color.RGBA{
+							uint8(z * 255),
+							uint8(z * 255),
+							uint8(z * 255),
+							255,
+						})
+					}
 				}
+				file := fmt.Sprintf("shadow-%d-%d.png", index, k)
+				fmt.Printf("saving (shadow map)... %s\n", file)
+				imageutil.Save(img, file)
 			}
-			file := fmt.Sprintf("shadow-%d.png", index)
-			fmt.Printf("saving (shadow map)... %s\n", file)
-			imageutil.Save(img, file)
 		}()
 	}
 
+	for face := range r.shadowBufs[index].faces {
+		r.passShadowFace(index, face)
+	}
+}
+
+func (r *Renderer) passShadowFace(index, face int) {
+	c := r.shadowBufs[index].faces[face].camera
 	scene.IterObjects(r.cfg.Scene, func(g *geometry.Geometry, modelMatrix math.Mat4[float32]) bool {
 		mvp := shader.MVP{
 			Model:    modelMatrix.MulM(g.ModelMatrix()),
-			View:     r.shadowBufs[index].camera.ViewMatrix(),
-			Proj:     r.shadowBufs[index].camera.ProjMatrix(),
+			View:     c.ViewMatrix(),
+			Proj:     c.ProjMatrix(),
 			Viewport: math.ViewportMatrix(float32(r.bufs[0].Bounds().Dx()), float32(r.bufs[0].Bounds().Dy())),
 		}
 
@@ -141,7 +301,7 @@ func (r *Renderer) passShadows(index int) {
 				if !t.IsValid() {
 					return
 				}
-				r.drawDepth(index, t, mvp)
+				r.drawDepth(&r.shadowBufs[index].faces[face], t, mvp)
 			})
 		}
 		return true
@@ -149,7 +309,7 @@ func (r *Renderer) passShadows(index int) {
 	r.sched.Wait()
 }
 
-func (r *Renderer) drawDepth(index int, t *primitive.Triangle, mvp shader.MVP) {
+func (r *Renderer) drawDepth(f *shadowFace, t *primitive.Triangle, mvp shader.MVP) {
 	var t1, t2, t3 *primitive.Vertex
 	t1 = &primitive.Vertex{
 		Pos: mvp.Proj.MulM(mvp.View).MulM(mvp.Model).MulV(t.V1.Pos),
@@ -169,6 +329,14 @@ func (r *Renderer) drawDepth(index int, t *primitive.Triangle, mvp shader.MVP) {
 		UV:  t.V3.UV,
 		Nor: t.V3.Nor.Apply(mvp.Normal),
 	}
+	// Triangles that reach behind the light camera cannot be projected
+	// without clipping. This only happens for perspective shadow maps and
+	// the parts are covered by the other faces of a cube map.
+	if _, ok := f.camera.(*camera.Perspective); ok &&
+		(t1.Pos.W >= 0 || t2.Pos.W >= 0 || t3.Pos.W >= 0) {
+		return
+	}
+
 	t1.Pos = t1.Pos.Apply(mvp.Viewport).Pos()
 	t2.Pos = t2.Pos.Apply(mvp.Viewport).Pos()
 	t3.Pos = t3.Pos.Apply(mvp.Viewport).Pos()
@@ -186,10 +354,10 @@ func (r *Renderer) drawDepth(index int, t *primitive.Triangle, mvp shader.MVP) {
 	// Compute AABB make the AABB a little bigger that align with pixels
 	// to contain the entire triangle
 	aabb := primitive.NewAABB(t1.Pos.ToVec3(), t2.Pos.ToVec3(), t3.Pos.ToVec3())
-	xmin := int(math.Round(aabb.Min.X) - 1)
-	xmax := int(math.Round(aabb.Max.X) + 1)
-	ymin := int(math.Round(aabb.Min.Y) - 1)
-	ymax := int(math.Round(aabb.Max.Y) + 1)
+	xmin := int(math.Max(math.Round(aabb.Min.X)-1, 0))
+	xmax := int(math.Min(math.Round(aabb.Max.X)+1, float32(r.bufs[0].Bounds().Dx())))
+	ymin := int(math.Max(math.Round(aabb.Min.Y)-1, 0))
+	ymax := int(math.Min(math.Round(aabb.Max.Y)+1, float32(r.bufs[0].Bounds().Dy())))
 	for x := xmin; x <= xmax; x++ {
 		for y := ymin; y <= ymax; y++ {
 			if !r.bufs[0].In(x, y) {
@@ -205,39 +373,65 @@ func (r *Renderer) drawDepth(index int, t *primitive.Triangle, mvp shader.MVP) {
 
 			// Z-test
 			z := bc[0]*t1.Pos.Z + bc[1]*t2.Pos.Z + bc[2]*t3.Pos.Z
-			if !r.shadowDepthTest(index, x, y, z) {
+			if !r.shadowDepthTest(f, x, y, z) {
 				continue
 			}
 
 			// update shadow map
 			idx := x + y*r.bufs[0].Bounds().Dx()
-			r.shadowBufs[index].lock[idx].Lock()
-			r.shadowBufs[index].depths[idx] = z
-			r.shadowBufs[index].lock[idx].Unlock()
+			f.lock[idx].Lock()
+			f.depths[idx] = z
+			f.lock[idx].Unlock()
 		}
 	}
 }
 
-func (r *Renderer) shadowDepthTest(index int, x, y int, z float32) bool {
+func (r *Renderer) shadowDepthTest(f *shadowFace, x, y int, z float32) bool {
 	idx := x + y*r.bufs[0].Bounds().Dx()
-	buf := r.shadowBufs[index]
 
-	buf.lock[idx].Lock()
-	defer buf.lock[idx].Unlock()
-	return !(z <= buf.depths[idx])
+	f.lock[idx].Lock()
+	defer f.lock[idx].Unlock()
+	return !(z <= f.depths[idx])
 }
 
+// shadingVisibility returns how much the fragment is in the shadow of the
+// given light, from 0 (lit) to 1 (fully in shadow). It is fractional at the
+// shadow edges when the shadow maps are filtered by PCF or PCSS. A light
+// that does not cast shadow never shadows a fragment.
 func (r *Renderer) shadingVisibility(shadowIdx int,
 	info buffer.Fragment, uniforms *shader.MVP,
-) bool {
-	lightSources, _ := r.cfg.Scene.Lights()
-	if !lightSources[shadowIdx].CastShadow() {
-		return true
+) float32 {
+	if !r.shadowBufs[shadowIdx].active {
+		return 0
 	}
 
+	// A point light shades a fragment from the cube face that covers it,
+	// every other face leaves it out of its frustum. An area light averages
+	// over its weighted faces.
+	shadow := float32(0)
+	for i := range r.shadowBufs[shadowIdx].faces {
+		f := &r.shadowBufs[shadowIdx].faces[i]
+		if f.weight > 0 {
+			shadow += f.weight * r.shadowFaceOcclusion(f, info, uniforms)
+		} else {
+			shadow = math.Max(shadow, r.shadowFaceOcclusion(f, info, uniforms))
+		}
+	}
+	return shadow
+}
+
+// shadowBias is the depth bias of shadow map lookups that avoids shadow acne.
+const shadowBias = 0.03
+
+// shadowFaceOcclusion returns the fraction of shadow map samples of the given
+// face that occlude the fragment. The filtering is the same as kernels.Shadow,
+// which the GPU deferred pass runs.
+func (r *Renderer) shadowFaceOcclusion(shadowMap *shadowFace,
+	info buffer.Fragment, uniforms *shader.MVP,
+) float32 {
 	matVP := uniforms.Viewport
 	matScreenToWorld := uniforms.ViewportToWorld
-	shadowMap := &r.shadowBufs[shadowIdx]
+	width, height := r.bufs[0].Bounds().Dx(), r.bufs[0].Bounds().Dy()
 
 	// transform scrren coordinate to light viewport
 	screenCoord := math.NewVec4(float32(info.X), float32(info.Y), info.Depth, 1).
@@ -246,38 +440,59 @@ func (r *Renderer) shadingVisibility(shadowIdx int,
 		Apply(shadowMap.camera.ProjMatrix()).
 		Apply(matVP).Pos()
 
+	// Fragments outside of the light frustum, e.g. behind a spot light,
+	// are not covered by the shadow map and therefore not in shadow.
+	if screenCoord.X < 0 || screenCoord.X >= float32(width) ||
+		screenCoord.Y < 0 || screenCoord.Y >= float32(height) ||
+		screenCoord.Z < -1 || screenCoord.Z > 1 {
+		return 0
+	}
 	lightX, lightY := int(screenCoord.X), int(screenCoord.Y)
-	bufIdx := lightX + lightY*r.bufs[0].Bounds().Dx()
-
-	shadow := float32(0)
-	if bufIdx > 0 && bufIdx < len(shadowMap.depths) {
-		shadowZ := shadowMap.depths[bufIdx]
-		const bias = 0.03
-		if screenCoord.Z < shadowZ-bias {
-			shadow++
+	depth := func(x, y int) (float32, bool) {
+		if x < 0 || x >= width || y < 0 || y >= height {
+			return 0, false
 		}
+		return shadowMap.depths[x+y*width], true
+	}
 
-		// bufIdx2 := lightX + 1 + lightY*r.width
-		// bufIdx3 := lightX + (lightY+1)*r.width
-		// bufIdx4 := lightX + 1 + (lightY+1)*r.width
-		// if (bufIdx2 > 0 && bufIdx2 < len(shadowMap.depths)) &&
-		// 	(bufIdx3 > 0 && bufIdx3 < len(shadowMap.depths)) &&
-		// 	(bufIdx4 > 0 && bufIdx4 < len(shadowMap.depths)) {
-
-		// 	shadowZ2 := shadowMap.depths[bufIdx2]
-		// 	if screenCoord.Z < shadowZ2-bias {
-		// 		shadow++
-		// 	}
-		// 	shadowZ3 := shadowMap.depths[bufIdx3]
-		// 	if screenCoord.Z < shadowZ3-bias {
-		// 		shadow++
-		// 	}
-		// 	shadowZ4 := shadowMap.depths[bufIdx4]
-		// 	if screenCoord.Z < shadowZ4-bias {
-		// 		shadow++
-		// 	}
-		// }
+	// The filter radius in texels: none for hard shadows, the kernel for
+	// PCF, and the penumbra of the average blocker for PCSS.
+	k := r.cfg.ShadowKernel
+	radius := float32(0)
+	switch r.cfg.ShadowFilter {
+	case ShadowFilterPCF:
+		radius = float32(k)
+	case ShadowFilterPCSS:
+		blockers, sum := float32(0), float32(0)
+		for i := -k; i <= k; i++ {
+			for j := -k; j <= k; j++ {
+				if d, ok := depth(lightX+i, lightY+j); ok && screenCoord.Z < d-shadowBias {
+					blockers++
+					sum += d
+				}
+			}
+		}
+		if blockers > 0 {
+			radius = kernels.PenumbraRadius(screenCoord.Z, sum/blockers, float32(k))
+		}
 	}
 
-	return shadow > 0
+	R := int(radius + 0.5)
+	taken, occluded := float32(0), float32(0)
+	for i := -R; i <= R; i++ {
+		for j := -R; j <= R; j++ {
+			d, ok := depth(lightX+i, lightY+j)
+			if !ok {
+				continue
+			}
+			taken++
+			if screenCoord.Z < d-shadowBias {
+				occluded++
+			}
+		}
+	}
+	if taken == 0 {
+		return 0
+	}
+	return occluded / taken
 }
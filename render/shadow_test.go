@@ -0,0 +1,209 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"poly.red/camera"
+	"poly.red/geometry"
+	"poly.red/gpu/shader/gpumath/kernels"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/scene"
+	"poly.red/shader"
+)
+
+// newCubeShadowScene puts a shadow-casting point light between two bunnies,
+// so that they cast shadows towards opposite sides of the light.
+func newCubeShadowScene(w, h int, shadow bool) (*scene.Scene, camera.Interface) {
+	s := scene.NewScene(
+		light.NewPoint(
+			light.Intensity(2),
+			light.Position(math.NewVec3[float32](0, 0.25, 0)),
+			light.CastShadow(shadow),
+		),
+		light.NewAmbient(light.Intensity(0.5)),
+	)
+	for _, x := range []float32{-0.35, 0.35} {
+		m := model.MustLoad("../internal/testdata/bunny.obj")
+		m.Scale(2, 2, 2)
+		m.Translate(x, 0, 0)
+		s.Add(m)
+	}
+	g := model.MustLoad("../internal/testdata/ground.obj")
+	g.Scale(2, 2, 2)
+	s.Add(g)
+	scene.IterObjects(s, func(o *geometry.Geometry, _ math.Mat4[float32]) bool {
+		for _, m := range o.Materials() {
+			m.Config(material.ReceiveShadow(true))
+		}
+		return true
+	})
+	return s, camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 1, 1.4)),
+		camera.LookAt(math.NewVec3[float32](0, 0, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(45, float32(w)/float32(h), 0.1, 4),
+	)
+}
+
+// renderShadowGBuffer runs the shadow and forward passes of the scene on the
+// CPU and returns the renderer with its G-buffer and shadow maps in place,
+// together with the uniforms the deferred pass uses for shadow lookups.
+func renderShadowGBuffer(w, h int, opts ...Option) (*Renderer, *shader.MVP) {
+	s, c := newCubeShadowScene(w, h, true)
+	r := NewRenderer(append([]Option{Camera(c), Size(w, h), Scene(s), MSAA(1), ShadowMap(true),
+		Background(color.RGBA{R: 0, G: 127, B: 255, A: 255}), CPU()}, opts...)...)
+	for i := range r.shadowBufs {
+		r.passShadows(i)
+	}
+	r.passForward()
+
+	buf := r.CurrBuffer()
+	matVP := math.ViewportMatrix(float32(buf.Bounds().Dx()), float32(buf.Bounds().Dy()))
+	return r, &shader.MVP{
+		Viewport:        matVP,
+		ViewportToWorld: c.ViewMatrix().Inv().MulM(c.ProjMatrix().Inv()).MulM(matVP.Inv()),
+	}
+}
+
+// TestPointLightCubeShadow checks that a point light casts shadows in every
+// direction: the ground behind the bunny on either side of the light is in
+// shadow, whereas the ground right under the light is not.
+func TestPointLightCubeShadow(t *testing.T) {
+	const w, h = 128, 128
+	r, uniforms := renderShadowGBuffer(w, h)
+	if n := len(r.shadowBufs[0].faces); n != 6 {
+		t.Fatalf("point light has %d shadow map faces, want 6", n)
+	}
+
+	buf := r.CurrBuffer()
+	var left, right, under int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			info := buf.UnsafeGet(x, y)
+			if !info.Ok || info.Nor.Y < 0.999 { // ground only
+				continue
+			}
+			if r.shadingVisibility(0, info, uniforms) == 0 {
+				continue
+			}
+			p := math.NewVec4(float32(info.X), float32(info.Y), info.Depth, 1).
+				Apply(uniforms.ViewportToWorld).Pos()
+			switch {
+			case math.Abs(p.X) < 0.05 && math.Abs(p.Z) < 0.05:
+				under++
+			case p.X < 0:
+				left++
+			default:
+				right++
+			}
+		}
+	}
+	if left == 0 || right == 0 {
+		t.Fatalf("want shadows on both sides of the light, got left %d, right %d", left, right)
+	}
+	if under != 0 {
+		t.Fatalf("ground under the light is in shadow: %d fragments", under)
+	}
+}
+
+// TestNonCastingLightShadow checks that a light that does not cast shadow
+// neither shadows nor darkens fragments that receive shadows, and that it
+// owns no map in the GPU shadow state.
+func TestNonCastingLightShadow(t *testing.T) {
+	const w, h = 64, 64
+	s, c := newCubeShadowScene(w, h, false)
+	r, uniforms := renderShadowGBuffer(w, h, Scene(s), Camera(c))
+	buf := r.CurrBuffer()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			info := buf.UnsafeGet(x, y)
+			if !info.Ok {
+				continue
+			}
+			if v := r.shadingVisibility(0, info, uniforms); v != 0 {
+				t.Fatalf("fragment (%d, %d) is in shadow: %v", x, y, v)
+			}
+		}
+	}
+	if sd := r.gpuShadowData(uniforms); sd != nil {
+		t.Fatalf("marshaled %d shadow maps, want none", sd.n)
+	}
+
+	render := func(shadow bool) *image.RGBA {
+		return NewRenderer(Camera(c), Size(w, h), Scene(s), MSAA(1),
+			ShadowMap(shadow), CPU()).Render()
+	}
+	if want, got := render(false), render(true); !bytes.Equal(want.Pix, got.Pix) {
+		t.Fatal("a non-casting light changes the image with shadow maps enabled")
+	}
+}
+
+// TestShadowKernelEquivalence locks the CPU shadow lookup (shadingVisibility)
+// to the author-once kernels.Shadow that the GPU deferred path runs over the
+// marshaled shadow maps, for every shadow filter, including the six faces of a
+// cube map that must darken a fragment at most once.
+func TestShadowKernelEquivalence(t *testing.T) {
+	const w, h = 96, 96
+	for _, tt := range []struct {
+		name   string
+		filter ShadowFilterMode
+		kernel int
+	}{
+		{"hard", ShadowFilterHard, 0},
+		{"pcf1", ShadowFilterPCF, 1},
+		{"pcf3", ShadowFilterPCF, 3},
+		{"pcss", ShadowFilterPCSS, 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, uniforms := renderShadowGBuffer(w, h, ShadowFilter(tt.filter, tt.kernel))
+			sd := r.gpuShadowData(uniforms)
+			if sd == nil {
+				t.Fatal("no shadow map is marshaled")
+			}
+			if sd.n != 6 {
+				t.Fatalf("marshaled %d shadow maps, want 6", sd.n)
+			}
+
+			buf := r.CurrBuffer()
+			su := sd.uniforms()
+			shadowed, partial := 0, 0
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					info := buf.UnsafeGet(x, y)
+					if !info.Ok {
+						continue
+					}
+					s := r.shadingVisibility(0, info, uniforms)
+					if s > 0 {
+						shadowed++
+					}
+					if s > 0 && s < 1 {
+						partial++
+					}
+					want := uint8(200 * math.Pow(0.5, s))
+					col := []float32{200, 200, 200, 255}
+					kernels.Shadow(0, []float32{float32(info.X), float32(info.Y), info.Depth, 0},
+						[]float32{1}, sd.depths, sd.mats, col, su)
+					if d := int(col[0]) - int(want); d < -1 || d > 1 {
+						t.Fatalf("fragment (%d, %d): kernel %v, cpu %v", x, y, col[0], want)
+					}
+				}
+			}
+			if shadowed == 0 {
+				t.Fatal("no fragment is in shadow")
+			}
+			if (tt.filter == ShadowFilterHard) != (partial == 0) {
+				t.Fatalf("%d fragments are partially in shadow", partial)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/camera"
+	"poly.red/geometry"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/scene"
+)
+
+func newSpotScene(w, h int, shadow bool) (*scene.Scene, camera.Interface) {
+	s := scene.NewScene(
+		light.NewSpot(
+			light.Intensity(3),
+			light.Position(math.NewVec3[float32](0.6, 1.2, 0.4)),
+			light.Direction(math.NewVec3[float32](-0.6, -1.2, -0.4)),
+			light.Cone(10, 15),
+			light.CastShadow(shadow),
+		),
+		light.NewAmbient(light.Intensity(0.3)),
+	)
+	m := model.MustLoad("../internal/testdata/bunny.obj")
+	m.Scale(2, 2, 2)
+	s.Add(m)
+	g := model.MustLoad("../internal/testdata/ground.obj")
+	g.Scale(2, 2, 2)
+	s.Add(g)
+	scene.IterObjects(s, func(o *geometry.Geometry, _ math.Mat4[float32]) bool {
+		for _, m := range o.Materials() {
+			m.Config(material.ReceiveShadow(true))
+		}
+		return true
+	})
+	return s, camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 0.6, 0.9)),
+		camera.ViewFrustum(45, float32(w)/float32(h), 0.1, 2),
+	)
+}
+
+// TestSpotLight renders a spot light on the CPU: the ground under the cone is
+// lit brighter than the ground outside the outer cone, and casting a shadow
+// darkens some of the lit pixels without touching the unlit ones.
+func TestSpotLight(t *testing.T) {
+	const w, h = 128, 128
+	bg := color.RGBA{R: 0, G: 127, B: 255, A: 255}
+
+	s, c := newSpotScene(w, h, false)
+	lit := NewRenderer(Camera(c), Size(w, h), Scene(s), Background(bg), CPU()).Render()
+
+	sum := func(x, y int) int {
+		p := lit.RGBAAt(x, y)
+		return int(p.R) + int(p.G) + int(p.B)
+	}
+	// The front of the ground below the bunny is inside the 15 degree
+	// cone, its left edge is far outside of it.
+	if in, out := sum(64, 88), sum(8, 88); in <= out {
+		t.Fatalf("spot cone: inside sum %d <= outside sum %d", in, out)
+	}
+
+	s, c = newSpotScene(w, h, true)
+	shadowed := NewRenderer(Camera(c), Size(w, h), Scene(s), Background(bg), ShadowMap(true), CPU()).Render()
+
+	darker := 0
+	for i := 0; i < len(lit.Pix); i += 4 {
+		if shadowed.Pix[i] < lit.Pix[i] {
+			darker++
+		}
+	}
+	if darker == 0 {
+		t.Fatal("spot light shadow map did not darken any pixel")
+	}
+	if shadowed.RGBAAt(8, 88) != lit.RGBAAt(8, 88) {
+		t.Fatalf("pixel outside the spot frustum is shadowed: %v != %v",
+			shadowed.RGBAAt(8, 88), lit.RGBAAt(8, 88))
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/shader"
+)
+
+// ssaoData is the view-space G-buffer of the screen-space ambient occlusion,
+// in the layout of the author-once kernels.AO and kernels.AOBlur.
+type ssaoData struct {
+	width, height int
+	viewpos       []float32 // n*4: the position, w = 1 if the pixel is covered
+	viewnor       []float32 // n*4: the normal
+	aoflag        []float32 // n: whether the material enables ambient occlusion
+	viewToScreen  math.Mat4[float32]
+	radius        float32
+	samples       int
+	strength      float32
+}
+
+// ssaoData returns the view-space G-buffer of the current buffer, or nil if
+// no fragment has a material that enables ambient occlusion.
+func (r *Renderer) ssaoData(uniforms *shader.MVP) *ssaoData {
+	buf := r.CurrBuffer()
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	d := &ssaoData{
+		width:        w,
+		height:       h,
+		viewpos:      make([]float32, w*h*4),
+		viewnor:      make([]float32, w*h*4),
+		aoflag:       make([]float32, w*h),
+		viewToScreen: uniforms.Viewport.MulM(uniforms.Proj),
+		radius:       r.cfg.AORadius,
+		samples:      r.cfg.AOSamples,
+		strength:     r.cfg.AOStrength,
+	}
+	screenToView := uniforms.View.MulM(uniforms.ViewportToWorld)
+	anyAO := false
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			info := buf.UnsafeGet(x, y)
+			if !info.Ok {
+				continue
+			}
+			i := y*w + x
+			p := math.NewVec4(float32(x), float32(y), info.Depth, 1).Apply(screenToView).Pos()
+			n := info.Nor.Apply(uniforms.View).Unit()
+			d.viewpos[i*4], d.viewpos[i*4+1], d.viewpos[i*4+2], d.viewpos[i*4+3] = p.X, p.Y, p.Z, 1
+			d.viewnor[i*4], d.viewnor[i*4+1], d.viewnor[i*4+2] = n.X, n.Y, n.Z
+			if std := material.StandardOf(r.material(info.MaterialID)); std != nil && std.AmbientOcclusion {
+				d.aoflag[i] = 1
+				anyAO = true
+			}
+		}
+	}
+	if !anyAO {
+		return nil
+	}
+	return d
+}
+
+// uniforms returns the au argument of kernels.AO and kernels.AOBlur.
+func (d *ssaoData) uniforms() []float32 {
+	au := []float32{
+		float32(d.width), float32(d.height), d.radius,
+		float32(d.samples), d.strength, 0, 0, 0,
+	}
+	for j := 0; j < 4; j++ { // column-major
+		for k := 0; k < 4; k++ {
+			au = append(au, d.viewToScreen.Get(k, j))
+		}
+	}
+	return au
+}
+
+// ambientOcclusion darkens the fragments of the given buffer by the
+// screen-space ambient occlusion on the CPU.
+func (r *Renderer) ambientOcclusion(buf *buffer.FragmentBuffer, d *ssaoData) {
+	ao := make([]float32, d.width*d.height)
+	r.ssaoRows(func(y int) {
+		for x := 0; x < d.width; x++ {
+			ao[y*d.width+x] = d.occlusion(x, y)
+		}
+	})
+	r.ssaoRows(func(y int) {
+		for x := 0; x < d.width; x++ {
+			if d.aoflag[y*d.width+x] < 0.5 {
+				continue
+			}
+			f := d.blur(ao, x, y)
+			info := buf.UnsafeGet(x, y)
+			info.Col = color.RGBA{
+				R: uint8(f * float32(info.Col.R)),
+				G: uint8(f * float32(info.Col.G)),
+				B: uint8(f * float32(info.Col.B)),
+				A: info.Col.A,
+			}
+			buf.UnsafeSet(x, y, info)
+		}
+	})
+}
+
+// ssaoRows runs f for every row of the current buffer on the scheduler.
+func (r *Renderer) ssaoRows(f func(y int)) {
+	for y := 0; y < r.CurrBuffer().Bounds().Dy(); y++ {
+		r.sched.Run(func() { f(y) })
+	}
+	r.sched.Wait()
+}
+
+// occlusion returns the unblurred ambient light factor of the pixel (x, y),
+// which is 1 if the pixel does not receive ambient occlusion.
+//
+// The samples are spread over the hemisphere around the normal, closer to
+// the fragment for the first ones, and rotated per pixel by a 4x4 pattern
+// that the blur averages out. A sample is occluded if the depth buffer in
+// its direction is in front of it, and the occluder counts less the further
+// it is from the fragment beyond the radius.
+func (d *ssaoData) occlusion(x, y int) float32 {
+	i := y*d.width + x
+	if d.aoflag[i] < 0.5 {
+		return 1
+	}
+	p := math.NewVec4(d.viewpos[i*4], d.viewpos[i*4+1], d.viewpos[i*4+2], 1)
+	n := math.NewVec4(d.viewnor[i*4], d.viewnor[i*4+1], d.viewnor[i*4+2], 0).Unit()
+
+	// A tangent frame around the normal.
+	a := math.NewVec4[float32](1, 0, 0, 0)
+	if math.Abs(n.X) > 0.9 {
+		a = math.NewVec4[float32](0, 1, 0, 0)
+	}
+	t := a.Sub(n.Scale(a.Dot(n), a.Dot(n), a.Dot(n), 0)).Unit()
+	b := n.Cross(t)
+
+	rot := float32((x%4)*4+y%4) * math.Pi / 8
+	bias := 0.05 * d.radius
+	occ := float32(0)
+	for s := 0; s < d.samples; s++ {
+		k := (float32(s) + 0.5) / float32(d.samples)
+		phi := float32(s)*2.39996323 + rot
+		st, ct := math.Sqrt(k), math.Sqrt(1-k)
+		scale := d.radius * (0.1 + 0.9*k*k)
+		dx, dy := math.Cos(phi)*st*scale, math.Sin(phi)*st*scale
+		sp := math.NewVec4(
+			p.X+t.X*dx+b.X*dy+n.X*ct*scale,
+			p.Y+t.Y*dx+b.Y*dy+n.Y*ct*scale,
+			p.Z+t.Z*dx+b.Z*dy+n.Z*ct*scale,
+			1,
+		)
+		clip := sp.Apply(d.viewToScreen)
+		sx := int(math.Floor(clip.X/clip.W + 0.5))
+		sy := int(math.Floor(clip.Y/clip.W + 0.5))
+		if sx < 0 || sx >= d.width || sy < 0 || sy >= d.height {
+			continue
+		}
+		j := sy*d.width + sx
+		if d.viewpos[j*4+3] < 0.5 {
+			continue
+		}
+		if z := d.viewpos[j*4+2]; z >= sp.Z+bias {
+			r := math.Clamp(d.radius/math.Max(math.Abs(p.Z-z), 1e-6), 0, 1)
+			occ += r * r * (3 - 2*r)
+		}
+	}
+	return math.Clamp(1-d.strength*occ/float32(d.samples), 0, 1)
+}
+
+// blur returns the bilateral blur of the ambient light factors ao at the
+// pixel (x, y): their average over the 4x4 pixels of the rotation pattern,
+// weighted by how close their view-space depth is to the one of the pixel,
+// which keeps the edges between surfaces sharp.
+func (d *ssaoData) blur(ao []float32, x, y int) float32 {
+	zc := d.viewpos[(y*d.width+x)*4+2]
+	var sum, wsum float32
+	for j := -2; j < 2; j++ {
+		for i := -2; i < 2; i++ {
+			sx, sy := x+i, y+j
+			if sx < 0 || sx >= d.width || sy < 0 || sy >= d.height {
+				continue
+			}
+			k := sy*d.width + sx
+			if d.aoflag[k] < 0.5 {
+				continue
+			}
+			w := math.Clamp(1-math.Abs(d.viewpos[k*4+2]-zc)/d.radius, 0, 1)
+			sum += ao[k] * w
+			wsum += w
+		}
+	}
+	return sum / wsum
+}
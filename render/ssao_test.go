@@ -0,0 +1,160 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"poly.red/camera"
+	"poly.red/geometry"
+	"poly.red/gpu/shader/gpumath/kernels"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/scene"
+)
+
+// newAOScene returns a bunny standing on the ground, both with ambient
+// occlusion, lit by an ambient light only.
+func newAOScene(w, h int) (*scene.Scene, camera.Interface) {
+	s := scene.NewScene(light.NewAmbient(light.Intensity(1)))
+	m := model.MustLoad("../internal/testdata/bunny.obj")
+	m.Scale(2, 2, 2)
+	s.Add(m)
+	g := model.MustLoad("../internal/testdata/ground.obj")
+	g.Scale(2, 2, 2)
+	s.Add(g)
+	scene.IterObjects(s, func(o *geometry.Geometry, _ math.Mat4[float32]) bool {
+		for _, m := range o.Materials() {
+			m.Config(material.AmbientOcclusion(true))
+		}
+		return true
+	})
+	return s, camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 0.6, 0.9)),
+		camera.LookAt(math.NewVec3[float32](0, 0.1, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(45, float32(w)/float32(h), 0.1, 3),
+	)
+}
+
+// TestSSAOEquivalence locks the CPU ambient occlusion to the author-once
+// kernels.AO and kernels.AOBlur over the same G-buffer.
+func TestSSAOEquivalence(t *testing.T) {
+	const w, h = 96, 96
+	s, c := newAOScene(w, h)
+	r := NewRenderer(Camera(c), Size(w, h), Scene(s), MSAA(1), AmbientOcclusion(0.08, 12, 1.5), CPU())
+	r.passForward()
+	d := r.ssaoData(r.screenUniforms())
+	if d == nil {
+		t.Fatal("no fragment with ambient occlusion")
+	}
+
+	n := w * h
+	cpu := make([]float32, n)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cpu[y*w+x] = d.occlusion(x, y)
+		}
+	}
+	au := d.uniforms()
+	ao := make([]float32, n)
+	for i := 0; i < n; i++ {
+		kernels.AO(uint(i), d.viewpos, d.viewnor, d.aoflag, ao, au)
+	}
+
+	// The sample positions differ in the last bits, which may move a
+	// sample to a neighbor pixel: a tiny fraction of the pixels may differ.
+	occluded, differ := 0, 0
+	for i := range ao {
+		if cpu[i] < 1 {
+			occluded++
+		}
+		if math.Abs(cpu[i]-ao[i]) > 1e-3 {
+			differ++
+		}
+	}
+	if occluded == 0 {
+		t.Fatal("no fragment is occluded")
+	}
+	if differ > n/200 {
+		t.Fatalf("%d of %d factors differ between the CPU and kernels.AO", differ, n)
+	}
+
+	col := make([]float32, n*4)
+	for i := range col {
+		col[i] = 200
+	}
+	for i := 0; i < n; i++ {
+		kernels.AOBlur(uint(i), d.viewpos, d.aoflag, cpu, col, au)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			want := float32(200)
+			if d.aoflag[i] > 0.5 {
+				want = float32(uint8(d.blur(cpu, x, y) * 200))
+			}
+			if col[i*4] != want {
+				t.Fatalf("pixel (%d, %d): kernels.AOBlur %v, CPU %v", x, y, col[i*4], want)
+			}
+		}
+	}
+}
+
+// TestSSAO renders the bunny on the ground with and without ambient
+// occlusion: the contact of the bunny and the ground gets darker, the open
+// ground stays lit, and a larger strength darkens more.
+func TestSSAO(t *testing.T) {
+	const w, h = 96, 96
+	bg := color.RGBA{A: 255}
+	render := func(opts ...Option) [][]int {
+		s, c := newAOScene(w, h)
+		if len(opts) == 0 {
+			scene.IterObjects(s, func(o *geometry.Geometry, _ math.Mat4[float32]) bool {
+				for _, m := range o.Materials() {
+					m.Config(material.AmbientOcclusion(false))
+				}
+				return true
+			})
+		}
+		img := NewRenderer(append(opts, Camera(c), Size(w, h), Scene(s), MSAA(1), Background(bg), CPU())...).Render()
+		sum := make([][]int, h)
+		for y := range sum {
+			sum[y] = make([]int, w)
+			for x := range sum[y] {
+				p := img.RGBAAt(x, y)
+				sum[y][x] = int(p.R) + int(p.G) + int(p.B)
+			}
+		}
+		return sum
+	}
+	plain := render()
+	ao := render(AmbientOcclusion(0.05, 16, 1))
+	strong := render(AmbientOcclusion(0.05, 16, 4))
+
+	darker, total, lit := 0, 0, 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if ao[y][x] > plain[y][x] || strong[y][x] > ao[y][x] {
+				t.Fatalf("pixel (%d, %d): ambient occlusion brightened it", x, y)
+			}
+			if ao[y][x] < plain[y][x] {
+				darker++
+			}
+			total += ao[y][x] - strong[y][x]
+			if plain[y][x] > 0 && ao[y][x] == plain[y][x] {
+				lit++
+			}
+		}
+	}
+	if darker == 0 || total == 0 {
+		t.Fatalf("ambient occlusion did not darken: %d darker pixels, strength difference %d", darker, total)
+	}
+	if lit == 0 {
+		t.Fatal("ambient occlusion darkened every pixel")
+	}
+}
@@ -0,0 +1,553 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"cmp"
+	"slices"
+	"unsafe"
+
+	"poly.red/buffer"
+	"poly.red/color"
+	"poly.red/geometry"
+	"poly.red/gpu"
+	"poly.red/gpu/shader/gpumath/kernels"
+	"poly.red/internal/profiling"
+	"poly.red/internal/spinlock"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/scene"
+	"poly.red/shader"
+)
+
+// transparent reports whether the primitives of the given material are drawn
+// by the forward pass into the transparent layers, which the transparent pass
+// blends by the blend mode of the material, instead of into the G-buffer.
+func transparent(m material.Material) bool {
+	std := material.StandardOf(m)
+	return std != nil && (std.Opacity < 1 || std.AlphaMap != nil || std.Blend != color.BlendSrcOver)
+}
+
+// passTransparent blends the primitives of transparent materials over the
+// shaded opaque scene.
+//
+// The forward pass rasterizes the primitives against the depth of the opaque
+// G-buffer, without writing it, into the transparent layers: by depth peeling
+// on the GPU (gpuPeelTransparent), otherwise on the CPU (rasterTransparent).
+// Their fragments are shaded like opaque ones, by the deferred kernels on the
+// GPU when a device is present, otherwise on the CPU. The fragments of materials of the default blend mode are resolved with
+// weighted blended order-independent transparency by the author-once
+// kernels.OIT into a premultiplied transparent layer, which is composited over
+// the opaque color by the configured BlendFunc, or by AlphaBlend if there is
+// none. The fragments of materials of other blend modes are then composited
+// back to front by the author-once kernels.Composite. Both kernels run on the
+// GPU when a device is present, otherwise as Go on the CPU.
+func (r *Renderer) passTransparent() {
+	if r.cfg.Debug {
+		done := profiling.Timed("transparent pass (blending)")
+		defer done()
+	}
+	layers := r.transLayers
+	r.transLayers = nil
+	if len(layers) == 0 {
+		return
+	}
+	buf := r.CurrBuffer()
+	uniforms := r.screenUniforms()
+	r.runPass("transparent", func() error {
+		return r.gpuShadeTransparent(layers, uniforms)
+	}, func() {
+		r.shadeTransparent(layers, uniforms)
+	})
+	oit, modes := r.transparentLists(layers, uniforms)
+	if len(oit.frags) == 0 && len(modes.frags) == 0 {
+		return
+	}
+
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	n := w * h
+	if len(oit.frags) > 0 {
+		var layer []float32
+		r.runPass("oit", func() error {
+			var err error
+			layer, err = runOITKernel(r.cfg.GPUDevice, n, oit.ranges, oit.frags)
+			return err
+		}, func() {
+			layer = make([]float32, n*4)
+			for i := 0; i < n; i++ {
+				kernels.OIT(uint(i), oit.ranges, oit.frags, layer)
+			}
+		})
+
+		blend := r.cfg.BlendFunc
+		if blend == nil {
+			blend = AlphaBlend
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				idx := y*w + x
+				if layer[idx*4+3] <= 0 {
+					continue
+				}
+				src := color.RGBA{
+					R: toByte(layer[idx*4] * 0xff),
+					G: toByte(layer[idx*4+1] * 0xff),
+					B: toByte(layer[idx*4+2] * 0xff),
+					A: toByte(layer[idx*4+3] * 0xff),
+				}
+				info := buf.UnsafeGet(x, y)
+				info.Col = blend(info.Col, src)
+				buf.UnsafeSet(x, y, info)
+			}
+		}
+	}
+	if len(modes.frags) == 0 {
+		return
+	}
+
+	dst := make([]float32, n*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			idx := y*w + x
+			col := buf.UnsafeGet(x, y).Col
+			dst[idx*4] = float32(col.R) / 0xff
+			dst[idx*4+1] = float32(col.G) / 0xff
+			dst[idx*4+2] = float32(col.B) / 0xff
+			dst[idx*4+3] = float32(col.A) / 0xff
+		}
+	}
+	var out []float32
+	r.runPass("composite", func() error {
+		var err error
+		out, err = runCompositeKernel(r.cfg.GPUDevice, n, modes.ranges, modes.frags, dst)
+		return err
+	}, func() {
+		out = make([]float32, n*4)
+		for i := 0; i < n; i++ {
+			kernels.Composite(uint(i), modes.ranges, modes.frags, dst, out)
+		}
+	})
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			idx := y*w + x
+			if modes.ranges[idx*2+1] == 0 {
+				continue
+			}
+			info := buf.UnsafeGet(x, y)
+			info.Col = color.RGBA{
+				R: toByte(out[idx*4] * 0xff),
+				G: toByte(out[idx*4+1] * 0xff),
+				B: toByte(out[idx*4+2] * 0xff),
+				A: toByte(out[idx*4+3] * 0xff),
+			}
+			buf.UnsafeSet(x, y, info)
+		}
+	}
+}
+
+// fragmentLists holds the fragments of every pixel y*w+x of the current
+// buffer in the layout of the transparent pass kernels: ranges holds the
+// [offset, count] of the fragments of a pixel in frags, whose stride is 5.
+type fragmentLists struct {
+	ranges, frags []float32
+}
+
+// rasterTransparent rasterizes the primitives of transparent materials on
+// the CPU against the depth of the opaque fragments of the current buffer,
+// without writing it, and returns their fragments in layers as
+// gpuPeelTransparent does, but with all fragments of every pixel. The
+// material table of the forward pass must be current.
+func (r *Renderer) rasterTransparent() []*buffer.FragmentBuffer {
+	buf := r.CurrBuffer()
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	lists := make([][]buffer.Fragment, w*h)
+	locks := make([]spinlock.SpinLock, w*h)
+	sink := func(x, y int, info buffer.Fragment) {
+		i := y*w + x
+		locks[i].Lock()
+		lists[i] = append(lists[i], info)
+		locks[i].Unlock()
+	}
+
+	view, proj := r.cfg.Camera.ViewMatrix(), r.projMatrix()
+	viewport := math.ViewportMatrix(float32(w), float32(h))
+	base := int64(0)
+	scene.IterObjects(r.cfg.Scene, func(g *geometry.Geometry, modelMatrix math.Mat4[float32]) bool {
+		mvp := &shader.MVP{
+			View:     view,
+			Proj:     proj,
+			Viewport: viewport,
+		}
+		mvp.Model = modelMatrix.MulM(g.ModelMatrix())
+		mvp.Normal = mvp.Model.Inv().T()
+		mvp.ViewInv = mvp.View.Inv()
+		mvp.ProjInv = mvp.Proj.Inv()
+		mvp.ViewportInv = mvp.Viewport.Inv()
+
+		for _, tri := range g.Triangles() {
+			t := tri
+			flatMatID := t.MaterialID
+			if flatMatID >= 0 {
+				flatMatID += base
+			}
+			if !transparent(r.material(flatMatID)) {
+				continue
+			}
+			r.sched.Run(func() {
+				if !t.IsValid() {
+					return
+				}
+				r.drawTo(mvp, t, flatMatID, sink)
+			})
+		}
+		base += int64(len(g.Materials()))
+		return true
+	})
+	r.sched.Wait()
+
+	// The fragments of a pixel are layered from the nearest, and those at
+	// the same depth by their material, so that the layers do not depend
+	// on the order of the rasterization.
+	var layers []*buffer.FragmentBuffer
+	for i, l := range lists {
+		slices.SortFunc(l, func(a, b buffer.Fragment) int {
+			if c := cmp.Compare(b.Depth, a.Depth); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.MaterialID, b.MaterialID)
+		})
+		for k, f := range l {
+			if k == len(layers) {
+				layers = append(layers, buffer.NewBuffer(buf.Bounds()))
+			}
+			layers[k].UnsafeSet(i%w, i/w, f)
+		}
+	}
+	return layers
+}
+
+// maxPeelLayers is the number of layers of transparent fragments that the
+// GPU peels at most: the fragments of a pixel behind its maxPeelLayers
+// nearest ones are dropped.
+const maxPeelLayers = 8
+
+// gpuShadeTransparent shades the fragments of the given transparent layers
+// with gpuDeferredShade.
+func (r *Renderer) gpuShadeTransparent(layers []*buffer.FragmentBuffer, uniforms *shader.MVP) error {
+	ls, es := r.cfg.Scene.Lights()
+	var sd *gpuShadowData
+	if r.cfg.ShadowMap {
+		sd = r.gpuShadowData(uniforms)
+	}
+	bg := func(x, y int) color.RGBA { return color.RGBA{} }
+	for _, l := range layers {
+		if err := gpuDeferredShade(r.cfg.GPUDevice, l, ls, es, r.cfg.Camera.Position(), bg, sd, nil, r.matTable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shadeTransparent shades the fragments of the given transparent layers on
+// the CPU.
+func (r *Renderer) shadeTransparent(layers []*buffer.FragmentBuffer, uniforms *shader.MVP) {
+	for _, l := range layers {
+		w, h := l.Bounds().Dx(), l.Bounds().Dy()
+		for y := 0; y < h; y++ {
+			y := y
+			r.sched.Run(func() {
+				for x := 0; x < w; x++ {
+					info := l.UnsafeGet(x, y)
+					if !info.Ok {
+						continue
+					}
+					info.Col, _ = r.shadeSurface(info, uniforms)
+					l.UnsafeSet(x, y, info)
+				}
+			})
+		}
+	}
+	r.sched.Wait()
+}
+
+// gpuPeelTransparent rasterizes the primitives of transparent materials on
+// the GPU against the depth of the opaque fragments of the current buffer,
+// without writing it, and reads their G-buffer back in layers: the nearest
+// fragment of every pixel is in the first layer, the one behind it in the
+// second, and so on, up to maxPeelLayers. Every layer is a pass of
+// kernels.ForwardPeel, which discards the fragments of the layers before.
+// Fragments at the same depth as a fragment of the layer before are dropped
+// with it, so of coplanar primitives only one is kept. pipe is the pipeline
+// of forwardPipeline with ForwardPeel.
+func (r *Renderer) gpuPeelTransparent(dev *gpu.Device, pipe *gpu.RenderPipeline) ([]*buffer.FragmentBuffer, error) {
+	draws, err := uploadForwardObjects(dev, r.forwardObjects(transparent))
+	if err != nil {
+		return nil, err
+	}
+	buf := r.CurrBuffer()
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+
+	// The bounds of every pixel, in the row order of the fragment
+	// position: the depth of the layer peeled before, initially in front
+	// of the near plane, and that of the opaque scene, behind the far
+	// plane if none. GL and Vulkan count the rows from the bottom, as the
+	// buffer does, and Metal from the top.
+	at := func(x, y int) int {
+		if dev.Driver() == gpu.DriverMetal {
+			y = h - 1 - y
+		}
+		return 1 + (y*w+x)*2
+	}
+	peel := make([]float32, 1+w*h*2)
+	peel[0] = float32(w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := at(x, y)
+			peel[i], peel[i+1] = 2, -2
+			if info := buf.UnsafeGet(x, y); info.Ok {
+				peel[i+1] = info.Depth
+			}
+		}
+	}
+
+	mkF32 := func() (*gpu.Texture, error) {
+		return dev.NewTexture(gpu.TextureDescriptor{Format: gpu.RGBA32Float, Width: w, Height: h, RenderTarget: true})
+	}
+	var targets [4]*gpu.Texture
+	for i := range targets {
+		if targets[i], err = mkF32(); err != nil {
+			return nil, err
+		}
+	}
+	depth, err := dev.NewTexture(gpu.TextureDescriptor{Format: gpu.Depth32Float, Width: w, Height: h, RenderTarget: true})
+	if err != nil {
+		return nil, err
+	}
+	sb := func(i int) gpu.BindGroupLayoutEntry {
+		return gpu.BindGroupLayoutEntry{Binding: i, Visibility: gpu.StageFragment, Kind: gpu.StorageBuffer}
+	}
+	layout := dev.NewBindGroupLayout(sb(0), sb(1), sb(2), sb(3), sb(4), sb(5), sb(6), sb(7))
+
+	var layers []*buffer.FragmentBuffer
+	for len(layers) < maxPeelLayers {
+		pb, err := newF32Buffer(dev, peel)
+		if err != nil {
+			return nil, err
+		}
+		enc := dev.NewCommandEncoder()
+		rp := enc.BeginRenderPass(gpu.RenderPassDescriptor{
+			ColorTexture: targets[0], Load: gpu.LoadClear, ClearColor: [4]float64{0, 0, 0, 0},
+			ExtraColorTargets: []gpu.ColorTarget{
+				{Texture: targets[1], ClearColor: [4]float64{0, 0, 0, noFragment}},
+				{Texture: targets[2], ClearColor: [4]float64{0, 0, 0, 0}},
+				{Texture: targets[3], ClearColor: [4]float64{0, 0, 0, 0}},
+			},
+			DepthTexture: depth, ClearDepth: 1,
+		})
+		rp.SetPipeline(pipe)
+		for _, d := range draws {
+			// The fragment stage takes the buffers of the vertex stage
+			// before the bounds.
+			entries := []gpu.BindGroupEntry{{Binding: 7, Buffer: pb}}
+			for i, b := range d.bufs {
+				entries = append(entries, gpu.BindGroupEntry{Binding: i, Buffer: b})
+			}
+			rp.SetBindGroup(0, dev.NewBindGroup(layout, entries...))
+			d.draw(rp)
+		}
+		rp.End()
+		dev.Queue().Submit(enc.Finish())
+		dev.Queue().WaitIdle()
+		pb.Release()
+
+		l := buffer.NewBuffer(buf.Bounds())
+		g := &deviceGBuffer{buf: l, wp: targets[0], nr: targets[1], uv: targets[2], tn: targets[3]}
+		if r.readGBuffer(g) == 0 {
+			break
+		}
+		layers = append(layers, l)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if info := l.UnsafeGet(x, y); info.Ok {
+					peel[at(x, y)] = info.Depth
+				}
+			}
+		}
+	}
+	return layers, nil
+}
+
+// transparentLists returns the shaded fragments of the given transparent
+// layers in the layouts of the kernels: the fragments of the default blend
+// mode as [r, g, b, alpha, distance] with the straight color, in the layout
+// of kernels.OIT, and the fragments of other blend modes as
+// [r, g, b, alpha, mode] with the premultiplied color, sorted back to front
+// in the layout of kernels.Composite.
+func (r *Renderer) transparentLists(layers []*buffer.FragmentBuffer, uniforms *shader.MVP) (oit, modes fragmentLists) {
+	buf := r.CurrBuffer()
+	w, h := buf.Bounds().Dx(), buf.Bounds().Dy()
+	camPos := r.cfg.Camera.Position().ToVec4(1)
+
+	// Fragments of blend modes other than the default carry their
+	// distance, as a sixth component, for sorting.
+	oitLists := make([][]float32, w*h)
+	modeLists := make([][]float32, w*h)
+	for _, l := range layers {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				info := l.UnsafeGet(x, y)
+				if !info.Ok {
+					continue
+				}
+				col := info.Col
+				alpha := float32(col.A) / 0xff
+				mode := color.BlendSrcOver
+				if std := material.StandardOf(r.material(info.MaterialID)); std != nil {
+					alpha *= std.Alpha(info.U, 1-info.V, info.Du, info.Dv)
+					mode = std.Blend
+				}
+				if alpha <= 0 && mode == color.BlendSrcOver {
+					continue
+				}
+				p := math.NewVec4(float32(x), float32(y), info.Depth, 1).Apply(uniforms.ViewportToWorld).Pos()
+				dist := p.Sub(camPos).Len()
+				cr, cg, cb := float32(col.R)/0xff, float32(col.G)/0xff, float32(col.B)/0xff
+
+				i := y*w + x
+				if mode == color.BlendSrcOver {
+					oitLists[i] = append(oitLists[i], cr, cg, cb, alpha, dist)
+				} else {
+					modeLists[i] = append(modeLists[i], cr*alpha, cg*alpha, cb*alpha, alpha, float32(mode), dist)
+				}
+			}
+		}
+	}
+
+	oit.ranges = make([]float32, w*h*2)
+	for i, l := range oitLists {
+		oit.ranges[i*2] = float32(len(oit.frags) / 5)
+		oit.ranges[i*2+1] = float32(len(l) / 5)
+		oit.frags = append(oit.frags, l...)
+	}
+	modes.ranges = make([]float32, w*h*2)
+	for i, l := range modeLists {
+		modes.ranges[i*2] = float32(len(modes.frags) / 5)
+		modes.ranges[i*2+1] = float32(len(l) / 6)
+		for _, f := range sortBackToFront(l) {
+			modes.frags = append(modes.frags, f[:5]...)
+		}
+	}
+	return oit, modes
+}
+
+// sortBackToFront splits the given fragments of stride 6, whose last
+// component is the distance to the camera, and sorts them from the farthest
+// to the nearest. Fragments at the same distance are ordered by their
+// remaining components, so the order does not depend on the rasterization.
+func sortBackToFront(l []float32) [][6]float32 {
+	fs := make([][6]float32, len(l)/6)
+	for i := range fs {
+		copy(fs[i][:], l[i*6:])
+	}
+	slices.SortFunc(fs, func(a, b [6]float32) int {
+		if c := cmp.Compare(b[5], a[5]); c != 0 {
+			return c
+		}
+		for i := 0; i < 5; i++ {
+			if c := cmp.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return fs
+}
+
+// runOITKernel resolves the transparent fragments of n pixels with the
+// author-once kernels.OIT on the GPU and returns the transparent layer.
+func runOITKernel(dev *gpu.Device, n int, ranges, frags []float32) ([]float32, error) {
+	mod, err := kernelModule(dev, kernels.OITSrc, "OIT")
+	if err != nil {
+		return nil, err
+	}
+	sb := func(i int) gpu.BindGroupLayoutEntry {
+		return gpu.BindGroupLayoutEntry{Binding: i, Visibility: gpu.StageCompute, Kind: gpu.StorageBuffer}
+	}
+	layout := dev.NewBindGroupLayout(sb(0), sb(1), sb(2))
+	pipe, err := dev.NewComputePipeline(gpu.ComputePipelineDescriptor{Layout: dev.NewPipelineLayout(layout), Module: mod, Entry: "OIT"})
+	if err != nil {
+		return nil, err
+	}
+	rb := storageBuf(dev, ranges)
+	fb := storageBuf(dev, frags)
+	out, err := dev.NewBuffer(gpu.BufferDescriptor{Size: n * 4 * 4, Usage: gpu.BufferStorage | gpu.BufferMapRead})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { rb.Release(); fb.Release(); out.Release() }()
+
+	bg := dev.NewBindGroup(layout,
+		gpu.BindGroupEntry{Binding: 0, Buffer: rb},
+		gpu.BindGroupEntry{Binding: 1, Buffer: fb},
+		gpu.BindGroupEntry{Binding: 2, Buffer: out},
+	)
+	enc := dev.NewCommandEncoder()
+	cp := enc.BeginComputePass()
+	cp.SetPipeline(pipe)
+	cp.SetBindGroup(0, bg)
+	cp.Dispatch(n, 1, 1)
+	cp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	res := make([]float32, n*4)
+	copy(res, unsafe.Slice((*float32)(unsafe.Pointer(&out.Bytes()[0])), n*4))
+	return res, nil
+}
+
+// runCompositeKernel composites the blend-mode fragments of n pixels over
+// the colors dst with the author-once kernels.Composite on the GPU and
+// returns the composited colors.
+func runCompositeKernel(dev *gpu.Device, n int, ranges, frags, dst []float32) ([]float32, error) {
+	mod, err := kernelModule(dev, kernels.CompositeSrc, "Composite")
+	if err != nil {
+		return nil, err
+	}
+	sb := func(i int) gpu.BindGroupLayoutEntry {
+		return gpu.BindGroupLayoutEntry{Binding: i, Visibility: gpu.StageCompute, Kind: gpu.StorageBuffer}
+	}
+	layout := dev.NewBindGroupLayout(sb(0), sb(1), sb(2), sb(3))
+	pipe, err := dev.NewComputePipeline(gpu.ComputePipelineDescriptor{Layout: dev.NewPipelineLayout(layout), Module: mod, Entry: "Composite"})
+	if err != nil {
+		return nil, err
+	}
+	rb := storageBuf(dev, ranges)
+	fb := storageBuf(dev, frags)
+	db := storageBuf(dev, dst)
+	out, err := dev.NewBuffer(gpu.BufferDescriptor{Size: n * 4 * 4, Usage: gpu.BufferStorage | gpu.BufferMapRead})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { rb.Release(); fb.Release(); db.Release(); out.Release() }()
+
+	bg := dev.NewBindGroup(layout,
+		gpu.BindGroupEntry{Binding: 0, Buffer: rb},
+		gpu.BindGroupEntry{Binding: 1, Buffer: fb},
+		gpu.BindGroupEntry{Binding: 2, Buffer: db},
+		gpu.BindGroupEntry{Binding: 3, Buffer: out},
+	)
+	enc := dev.NewCommandEncoder()
+	cp := enc.BeginComputePass()
+	cp.SetPipeline(pipe)
+	cp.SetBindGroup(0, bg)
+	cp.Dispatch(n, 1, 1)
+	cp.End()
+	dev.Queue().Submit(enc.Finish())
+	dev.Queue().WaitIdle()
+
+	res := make([]float32, n*4)
+	copy(res, unsafe.Slice((*float32)(unsafe.Pointer(&out.Bytes()[0])), n*4))
+	return res, nil
+}
@@ -0,0 +1,270 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package render
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"poly.red/buffer"
+	"poly.red/camera"
+	"poly.red/color"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/scene"
+)
+
+// transparentPlane is a horizontal plane of the transparency tests.
+type transparentPlane struct {
+	height  float32
+	size    float32
+	col     color.RGBA
+	opacity float32
+	blend   color.BlendMode
+}
+
+// renderTransparent renders the given planes above a grey ground plane
+// with the renderer of the given device option, CPU() or GPU(dev).
+func renderTransparent(dev Option, planes ...transparentPlane) *image.RGBA {
+	return newTransparentRenderer(dev, planes...).Render()
+}
+
+// newTransparentRenderer returns the renderer of renderTransparent.
+func newTransparentRenderer(dev Option, planes ...transparentPlane) *Renderer {
+	const w, h = 64, 64
+	s := scene.NewScene(
+		light.NewPoint(light.Intensity(3), light.Position(math.NewVec3[float32](0, 2, 1))),
+		light.NewAmbient(light.Intensity(0.3)),
+	)
+	s.Add(newPBRPlane(2, material.NewBlinnPhong(
+		material.Texture(buffer.NewUniformTexture(color.RGBA{R: 200, G: 200, B: 200, A: 255})),
+	)))
+	for _, p := range planes {
+		g := newPBRPlane(p.size, material.NewBlinnPhong(
+			material.Texture(buffer.NewUniformTexture(p.col)),
+			material.Opacity(p.opacity),
+			material.Blend(p.blend),
+		))
+		g.Translate(0, p.height, 0)
+		s.Add(g)
+	}
+	c := camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 1.5, 1)),
+		camera.LookAt(math.NewVec3[float32](0, 0, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(45, 1, 0.1, 5),
+	)
+	return NewRenderer(Camera(c), Size(w, h), Scene(s), MSAA(1),
+		Background(color.RGBA{A: 255}), dev)
+}
+
+// TestTransparentPass checks that transparent geometries are blended over
+// the opaque scene, independent of their order and hidden by opaque depth.
+func TestTransparentPass(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	ground := renderTransparent(CPU())
+
+	t.Run("blend", func(t *testing.T) {
+		// A half transparent plane is the average of the ground and the
+		// plane if it were opaque.
+		opaque := renderTransparent(CPU(), transparentPlane{0.2, 0.8, red, 1, color.BlendSrcOver})
+		half := renderTransparent(CPU(), transparentPlane{0.2, 0.8, red, 0.5, color.BlendSrcOver})
+		blended := 0
+		for i := 0; i < len(half.Pix); i += 4 {
+			if ground.Pix[i+1] == opaque.Pix[i+1] {
+				continue
+			}
+			blended++
+			for c := 0; c < 3; c++ {
+				want := (int(opaque.Pix[i+c]) + int(ground.Pix[i+c]) + 1) / 2
+				if d := int(half.Pix[i+c]) - want; d < -2 || d > 2 {
+					t.Fatalf("pixel %d channel %d: got %d, want %d", i/4, c, half.Pix[i+c], want)
+				}
+			}
+		}
+		if blended == 0 {
+			t.Fatal("the transparent plane covers no pixel")
+		}
+	})
+
+	t.Run("order", func(t *testing.T) {
+		// The result does not depend on the order of the transparent
+		// geometries in the scene.
+		p1 := transparentPlane{0.2, 0.8, red, 0.4, color.BlendSrcOver}
+		p2 := transparentPlane{0.4, 0.6, blue, 0.6, color.BlendSrcOver}
+		a, b := renderTransparent(CPU(), p1, p2), renderTransparent(CPU(), p2, p1)
+		for i := range a.Pix {
+			if a.Pix[i] != b.Pix[i] {
+				t.Fatalf("pixel %d differs: %v != %v", i/4, a.Pix[i&^3:i&^3+4], b.Pix[i&^3:i&^3+4])
+			}
+		}
+	})
+
+	t.Run("occluded", func(t *testing.T) {
+		// A transparent plane below the ground is hidden by its depth.
+		hidden := renderTransparent(CPU(), transparentPlane{-0.2, 0.8, red, 0.5, color.BlendSrcOver})
+		for i := range hidden.Pix {
+			if hidden.Pix[i] != ground.Pix[i] {
+				t.Fatalf("pixel %d: the occluded plane is visible", i/4)
+			}
+		}
+	})
+}
+
+// TestTransparentLayers checks that the forward pass draws the primitives of
+// transparent materials into layers from the nearest, not into the G-buffer,
+// and that their fragments keep their material and hence its blend mode.
+func TestTransparentLayers(t *testing.T) {
+	r := newTransparentRenderer(CPU(),
+		transparentPlane{0.2, 0.8, color.RGBA{R: 255, A: 255}, 0.4, color.BlendSrcOver},
+		transparentPlane{0.4, 0.6, color.RGBA{G: 255, A: 255}, 1, color.BlendMultiply},
+	)
+	r.passForward()
+	checkTransparentLayers(t, r)
+}
+
+// checkTransparentLayers checks the transparent layers that the forward pass
+// of the renderer of TestTransparentLayers drew at the center pixel.
+func checkTransparentLayers(t *testing.T, r *Renderer) {
+	t.Helper()
+	if got := r.CurrBuffer().UnsafeGet(32, 32); !got.Ok || got.MaterialID != 0 {
+		t.Fatalf("the G-buffer holds material %d, want the ground", got.MaterialID)
+	}
+	if len(r.transLayers) != 2 {
+		t.Fatalf("%d transparent layers, want 2", len(r.transLayers))
+	}
+	near, far := r.transLayers[0].UnsafeGet(32, 32), r.transLayers[1].UnsafeGet(32, 32)
+	if !near.Ok || !far.Ok || near.Depth <= far.Depth {
+		t.Fatalf("layers are not ordered from the nearest: %v, %v", near.Depth, far.Depth)
+	}
+	for _, c := range []struct {
+		f    buffer.Fragment
+		want color.BlendMode
+	}{{near, color.BlendMultiply}, {far, color.BlendSrcOver}} {
+		if got := material.StandardOf(r.material(c.f.MaterialID)).Blend; got != c.want {
+			t.Errorf("material %d blends by %v, want %v", c.f.MaterialID, got, c.want)
+		}
+	}
+}
+
+// TestTransparentBlendMode checks that the primitives of a material with a
+// blend mode are composited by that mode, in back to front order.
+func TestTransparentBlendMode(t *testing.T) {
+	green := color.RGBA{R: 128, G: 255, A: 255}
+	ground := renderTransparent(CPU())
+	opaque := renderTransparent(CPU(), transparentPlane{0.2, 0.8, green, 1, color.BlendSrcOver})
+
+	t.Run("multiply", func(t *testing.T) {
+		got := renderTransparent(CPU(), transparentPlane{0.2, 0.8, green, 1, color.BlendMultiply})
+		blended := 0
+		for i := 0; i < len(got.Pix); i += 4 {
+			if ground.Pix[i+2] == opaque.Pix[i+2] {
+				if got.Pix[i+2] != ground.Pix[i+2] {
+					t.Fatalf("pixel %d: got %v outside of the plane", i/4, got.Pix[i:i+4])
+				}
+				continue
+			}
+			blended++
+			for c := 0; c < 3; c++ {
+				want := (int(opaque.Pix[i+c])*int(ground.Pix[i+c]) + 0x7f) / 0xff
+				if d := int(got.Pix[i+c]) - want; d < -2 || d > 2 {
+					t.Fatalf("pixel %d channel %d: got %d, want %d", i/4, c, got.Pix[i+c], want)
+				}
+			}
+		}
+		if blended == 0 {
+			t.Fatal("the plane covers no pixel")
+		}
+	})
+
+	t.Run("order", func(t *testing.T) {
+		// The far plane clears the ground and the near plane replaces the
+		// cleared color, whatever the order of the geometries in the scene.
+		near := transparentPlane{0.4, 0.4, green, 1, color.BlendSrc}
+		far := transparentPlane{0.2, 0.8, color.RGBA{R: 255, A: 255}, 1, color.BlendClear}
+		a, b := renderTransparent(CPU(), near, far), renderTransparent(CPU(), far, near)
+		cleared, drawn := 0, 0
+		for i := 0; i < len(a.Pix); i += 4 {
+			if !bytes.Equal(a.Pix[i:i+4], b.Pix[i:i+4]) {
+				t.Fatalf("pixel %d differs: %v != %v", i/4, a.Pix[i:i+4], b.Pix[i:i+4])
+			}
+			switch {
+			case a.Pix[i+3] == 0:
+				cleared++
+			case a.Pix[i+1] > a.Pix[i]:
+				drawn++
+			}
+		}
+		if cleared == 0 || drawn == 0 {
+			t.Fatalf("got %d cleared and %d drawn pixels, want both", cleared, drawn)
+		}
+	})
+}
+
+// TestTransparentBlendFunc checks that the transparent pass composites by
+// the configured blend function.
+func TestTransparentBlendFunc(t *testing.T) {
+	const w, h = 32, 32
+	s := scene.NewScene(light.NewAmbient(light.Intensity(1)))
+	g := newPBRPlane(2, material.NewBlinnPhong(
+		material.Texture(buffer.NewUniformTexture(color.RGBA{R: 255, A: 255})),
+		material.Opacity(0.5),
+	))
+	s.Add(g)
+	c := camera.NewPerspective(
+		camera.Position(math.NewVec3[float32](0, 1.5, 1)),
+		camera.LookAt(math.NewVec3[float32](0, 0, 0), math.NewVec3[float32](0, 1, 0)),
+		camera.ViewFrustum(45, 1, 0.1, 5),
+	)
+
+	calls := 0
+	keep := func(dst, src color.RGBA) color.RGBA {
+		calls++
+		return color.RGBA{G: 0xff, A: 0xff}
+	}
+	r := NewRenderer(Camera(c), Size(w, h), Scene(s), MSAA(1), CPU(), Blending(keep))
+	r.passForward()
+	calls = 0
+	r.passTransparent()
+	if calls == 0 {
+		t.Fatal("the blend function is not called")
+	}
+	if got := r.CurrBuffer().UnsafeGet(w/2, h/2).Col; got != (color.RGBA{G: 0xff, A: 0xff}) {
+		t.Fatalf("center pixel: got %v, want the color of the blend function", got)
+	}
+}
+
+// TestTransparentAlphaMap checks that a material whose alpha map scales
+// its opacity is drawn by the transparent pass, as if it had the scaled
+// opacity.
+func TestTransparentAlphaMap(t *testing.T) {
+	const w, h = 32, 32
+	render := func(opts ...material.Option) *image.RGBA {
+		s := scene.NewScene(light.NewAmbient(light.Intensity(1)))
+		opts = append(opts, material.Texture(buffer.NewUniformTexture(color.RGBA{R: 255, A: 255})))
+		s.Add(newPBRPlane(2, material.NewBlinnPhong(opts...)))
+		c := camera.NewPerspective(
+			camera.Position(math.NewVec3[float32](0, 1.5, 1)),
+			camera.LookAt(math.NewVec3[float32](0, 0, 0), math.NewVec3[float32](0, 1, 0)),
+			camera.ViewFrustum(45, 1, 0.1, 5),
+		)
+		r := NewRenderer(Camera(c), Size(w, h), Scene(s), MSAA(1), Background(color.RGBA{A: 255}), CPU())
+		return r.Render()
+	}
+
+	mapped := render(material.AlphaMap(buffer.NewUniformTexture(color.RGBA{R: 0x80, A: 0xff})))
+	want := render(material.Opacity(float32(0x80) / 0xff))
+	opaque := render()
+	if bytes.Equal(mapped.Pix, opaque.Pix) {
+		t.Fatal("the alpha map is ignored")
+	}
+	for i := range want.Pix {
+		if d := int(mapped.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+			t.Fatalf("pixel %d: got %v, want %v", i/4, mapped.Pix[i&^3:i&^3+4], want.Pix[i&^3:i&^3+4])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+//go:build linux
+
+package render
+
+import (
+	"os"
+	"testing"
+
+	"poly.red/color"
+	"poly.red/gpu"
+)
+
+// The renderer on the Vulkan backend: the GL render tests of the GPU forward
+// pass, its transparent layers and the deferred pass, run on a Vulkan device
+// with the stages and kernels compiled to SPIR-V. Gated on POLYRED_VK_PROBE=1
+// as the Vulkan tests of the gpu package.
+
+// openVulkanOrSkip opens the Vulkan device of a renderer test, or skips it.
+func openVulkanOrSkip(t *testing.T) *gpu.Device {
+	t.Helper()
+	if os.Getenv("POLYRED_VK_PROBE") != "1" {
+		t.Skip("set POLYRED_VK_PROBE=1 to run the Vulkan renderer tests")
+	}
+	dev, err := gpu.Open(gpu.WithDriver(gpu.DriverVulkan))
+	if err != nil {
+		t.Skipf("no Vulkan device: %v", err)
+	}
+	return dev
+}
+
+func TestVulkanDeferredRender(t *testing.T) {
+	dev := openVulkanOrSkip(t)
+	defer dev.Close()
+	checkDeferredRender(t, dev)
+}
+
+func TestVulkanForwardMultisample(t *testing.T) {
+	dev := openVulkanOrSkip(t)
+	defer dev.Close()
+	checkForwardMultisample(t, dev)
+}
+
+func TestVulkanAreaLight(t *testing.T) {
+	dev := openVulkanOrSkip(t)
+	defer dev.Close()
+	checkAreaLight(t, dev)
+}
+
+// TestVulkanForwardDepthOrder allows the depth more slack than GL: measured
+// on SwiftShader, it differs from the CPU's by 2e-5.
+func TestVulkanForwardDepthOrder(t *testing.T) {
+	dev := openVulkanOrSkip(t)
+	defer dev.Close()
+	checkForwardDepthOrder(t, dev, 1e-4)
+}
+
+func TestVulkanTransparent(t *testing.T) {
+	dev := openVulkanOrSkip(t)
+	defer dev.Close()
+	checkTransparent(t, dev)
+}
+
+func TestVulkanTransparentLayers(t *testing.T) {
+	dev := openVulkanOrSkip(t)
+	defer dev.Close()
+
+	r := newTransparentRenderer(GPU(dev),
+		transparentPlane{0.2, 0.8, color.RGBA{R: 255, A: 255}, 0.4, color.BlendSrcOver},
+		transparentPlane{0.4, 0.6, color.RGBA{G: 255, A: 255}, 1, color.BlendMultiply},
+	)
+	r.passForward()
+	if !r.passOnGPU("forward") {
+		t.Fatal("the forward pass did not run on the Vulkan GPU")
+	}
+	checkTransparentLayers(t, r)
+}
@@ -116,6 +116,7 @@ type Group struct {
 	math.TransformContext[float32]
 
 	name    string
+	source  string
 	root    *Scene
 	parent  *Group
 	objects []object.Object[float32]
@@ -0,0 +1,965 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"poly.red/buffer"
+	"poly.red/camera"
+	"poly.red/color"
+	"poly.red/geometry"
+	"poly.red/geometry/mesh"
+	"poly.red/geometry/primitive"
+	"poly.red/internal/imageutil"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/scene/object"
+)
+
+// Version is the version of the scene format that Save writes. Load reads
+// the files of this and of all earlier versions.
+const Version = 1
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]func(path string) (*Group, error){}
+)
+
+// RegisterFormat registers the loader of the model files of the given
+// extension, such as ".obj", which Load uses for the model files that a
+// scene file references. Importing poly.red/model registers its formats.
+func RegisterFormat(ext string, load func(path string) (*Group, error)) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[ext] = load
+}
+
+// loader returns the registered loader of the model file of the given path.
+func loader(path string) (func(path string) (*Group, error), error) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	load, ok := formats[filepath.Ext(path)]
+	if !ok {
+		return nil, fmt.Errorf("no loader registered for %v", path)
+	}
+	return load, nil
+}
+
+// SaveOption is an option of Save.
+type SaveOption func(o *saveOption)
+
+type saveOption struct {
+	reference bool
+}
+
+// ReferenceModels writes the groups that were loaded from model files as
+// references to the files by their path, instead of their objects. Save
+// fails if the objects of such a group no longer match its file, since
+// their changes would be lost. Textures loaded from image files are
+// likewise written as references to the files instead of embedded images.
+func ReferenceModels(enable bool) SaveOption {
+	return func(o *saveOption) {
+		o.reference = enable
+	}
+}
+
+// Save writes a scene to a JSON file, which Load reads back. The file
+// holds the group hierarchy with the transforms of all objects, the
+// geometries as indexed triangles with their materials, the lights and
+// the cameras that were added to the scene.
+//
+// Textures are embedded as PNG images, and environment maps as Radiance
+// RGBE images. The objects of a group with a Source are written like any
+// other, unless ReferenceModels is set, which writes a reference to its
+// model file, relative to the directory of the scene file, and references
+// to the image files of textures that were loaded from one.
+func Save(path string, s *Scene, opts ...SaveOption) error {
+	opt := &saveOption{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
+	w := &sceneWriter{
+		dir:      filepath.Dir(path),
+		opt:      opt,
+		mats:     map[material.Material]int{},
+		textures: map[*buffer.Texture]int{},
+	}
+	root, err := w.object(s.root)
+	if err == nil {
+		w.f.Version = Version
+		w.f.Root = root
+		var b []byte
+		if b, err = json.MarshalIndent(&w.f, "", "\t"); err == nil {
+			err = os.WriteFile(path, compact(b), 0o644)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("scene: cannot save to the given file %v: %w", path, err)
+	}
+	return nil
+}
+
+// numbers matches the arrays of numbers of an indented JSON document. As
+// strings cannot hold a raw newline, only indentation matches.
+var numbers = regexp.MustCompile(`\[\n[-+.0-9eE,\s]*\]`)
+
+// compact puts the arrays of numbers of an indented JSON document on a
+// single line each, which keeps the meshes of a scene file readable.
+func compact(b []byte) []byte {
+	return numbers.ReplaceAllFunc(b, func(m []byte) []byte {
+		return append(append([]byte{'['}, bytes.Join(bytes.Fields(m[1:len(m)-1]), []byte{' '})...), ']')
+	})
+}
+
+// Load reads a scene file written by Save. The cameras of the scene are
+// objects of its graph, which IterObjects finds. Referenced model files
+// are loaded by the loader registered for their extension.
+func Load(path string) (*Scene, error) {
+	s, err := load(path)
+	if err != nil {
+		return nil, fmt.Errorf("scene: cannot load the given file %v: %w", path, err)
+	}
+	return s, nil
+}
+
+func load(path string) (*Scene, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &sceneReader{dir: filepath.Dir(path)}
+	if err := json.Unmarshal(b, &r.f); err != nil {
+		return nil, err
+	}
+	if r.f.Version < 1 || r.f.Version > Version {
+		return nil, fmt.Errorf("unsupported version %d", r.f.Version)
+	}
+	if r.f.Root.Kind != kindGroup {
+		return nil, fmt.Errorf("root of kind %q, want %q", r.f.Root.Kind, kindGroup)
+	}
+	r.mats = make([]material.Material, len(r.f.Materials))
+	r.textures = make([]*buffer.Texture, len(r.f.Textures))
+
+	s := NewScene()
+	if r.f.Root.Transform != nil {
+		s.Add().Transform(r.f.Root.Transform.mat4())
+	}
+	for i := range r.f.Root.Children {
+		o, err := r.object(&r.f.Root.Children[i])
+		if err != nil {
+			return nil, err
+		}
+		s.Add(o)
+	}
+	return s, nil
+}
+
+// The kinds of the nodes of a scene file.
+const (
+	kindGroup    = "group"
+	kindGeometry = "geometry"
+	kindLight    = "light"
+	kindCamera   = "camera"
+)
+
+// sceneFile is the JSON document of a scene file. Materials and textures
+// are shared by the nodes, which reference them by their index.
+type sceneFile struct {
+	Version   int            `json:"version"`
+	Root      node           `json:"root"`
+	Materials []materialData `json:"materials,omitempty"`
+	Textures  []textureData  `json:"textures,omitempty"`
+}
+
+// node is an object of the scene graph, whose kind selects its fields.
+type node struct {
+	Kind      string     `json:"kind"`
+	Name      string     `json:"name,omitempty"`
+	Transform *transform `json:"transform,omitempty"`
+
+	// Source is the model file of a group, whose objects are not written.
+	Source   string `json:"source,omitempty"`
+	Children []node `json:"children,omitempty"`
+
+	// Mesh and Materials are the triangles of a geometry and the indices
+	// of its materials in the file.
+	Mesh      *meshData `json:"mesh,omitempty"`
+	Materials []int     `json:"materials,omitempty"`
+
+	Light  *lightData  `json:"light,omitempty"`
+	Camera *cameraData `json:"camera,omitempty"`
+}
+
+// transform is a model matrix in row-major order. A nil transform is the
+// identity.
+type transform [16]float32
+
+func newTransform(m math.Mat4[float32]) *transform {
+	if m == math.Mat4I[float32]() {
+		return nil
+	}
+	return &transform{
+		m.X00, m.X01, m.X02, m.X03,
+		m.X10, m.X11, m.X12, m.X13,
+		m.X20, m.X21, m.X22, m.X23,
+		m.X30, m.X31, m.X32, m.X33,
+	}
+}
+
+func (t *transform) mat4() math.Mat4[float32] {
+	if t == nil {
+		return math.Mat4I[float32]()
+	}
+	return math.NewMat4(
+		t[0], t[1], t[2], t[3],
+		t[4], t[5], t[6], t[7],
+		t[8], t[9], t[10], t[11],
+		t[12], t[13], t[14], t[15],
+	)
+}
+
+// meshData are the triangles of a geometry. Its vertices are the distinct
+// corners of the triangles, and each triangle has the geometry-local index
+// of its material, which is negative for vertex colors. Attributes whose
+// values are all zero, and colors that are all white, are omitted.
+type meshData struct {
+	Positions []float32 `json:"positions"`          // 3 per vertex
+	Normals   []float32 `json:"normals,omitempty"`  // 3 per vertex
+	Uvs       []float32 `json:"uvs,omitempty"`      // 2 per vertex
+	Colors    []int     `json:"colors,omitempty"`   // 4 per vertex, in [0, 255]
+	Tangents  []float32 `json:"tangents,omitempty"` // 4 per vertex
+	Indices   []int     `json:"indices"`            // 3 per triangle
+	Materials []int64   `json:"materials"`          // 1 per triangle
+}
+
+// materialData is a material, whose properties beyond the ones of
+// material.Standard are in BlinnPhong or PBR. Textures are indices of the
+// textures of the file.
+type materialData struct {
+	Name string `json:"name,omitempty"`
+
+	// Default is set for material.Default(), and no other field.
+	Default bool `json:"default,omitempty"`
+
+	FlatShading      bool    `json:"flat_shading,omitempty"`
+	AmbientOcclusion bool    `json:"ambient_occlusion,omitempty"`
+	ReceiveShadow    bool    `json:"receive_shadow,omitempty"`
+	Opacity          float32 `json:"opacity"`
+	Blend            string  `json:"blend,omitempty"`
+	Texture          *int    `json:"texture,omitempty"`
+	NormalMap        *int    `json:"normal_map,omitempty"`
+	AlphaMap         *int    `json:"alpha_map,omitempty"`
+
+	BlinnPhong *blinnPhongData `json:"blinn_phong,omitempty"`
+	PBR        *pbrData        `json:"pbr,omitempty"`
+}
+
+type blinnPhongData struct {
+	Ambient     [4]uint8 `json:"ambient"`
+	Diffuse     [4]uint8 `json:"diffuse"`
+	Specular    [4]uint8 `json:"specular"`
+	Emissive    [4]uint8 `json:"emissive"`
+	Shininess   float32  `json:"shininess"`
+	SpecularMap *int     `json:"specular_map,omitempty"`
+}
+
+type pbrData struct {
+	BaseColor            [4]uint8 `json:"base_color"`
+	Metallic             float32  `json:"metallic"`
+	Roughness            float32  `json:"roughness"`
+	Emissive             [4]uint8 `json:"emissive"`
+	MetallicRoughnessMap *int     `json:"metallic_roughness_map,omitempty"`
+	OcclusionMap         *int     `json:"occlusion_map,omitempty"`
+	EmissiveMap          *int     `json:"emissive_map,omitempty"`
+}
+
+// textureData is a texture, which is either embedded as a PNG image or
+// references an image file by its path relative to the scene file. The
+// texels of an embedded image are stored as they are, whereas the colors
+// of a referenced sRGB image are converted to linear.
+type textureData struct {
+	Data   []byte `json:"data,omitempty"`
+	Path   string `json:"path,omitempty"`
+	SRGB   bool   `json:"srgb,omitempty"`
+	Mipmap bool   `json:"mipmap,omitempty"`
+}
+
+// lightData is a light, whose type selects its fields.
+type lightData struct {
+	Type       string      `json:"type"`
+	Color      [4]uint8    `json:"color"`
+	Intensity  float32     `json:"intensity"`
+	Position   *[3]float32 `json:"position,omitempty"`
+	Direction  *[3]float32 `json:"direction,omitempty"`
+	CastShadow bool        `json:"cast_shadow,omitempty"`
+
+	Cone  *[2]float32 `json:"cone,omitempty"`  // spot
+	Range float32     `json:"range,omitempty"` // spot
+	Size  *[2]float32 `json:"size,omitempty"`  // area
+
+	// Image is the Radiance RGBE image of an environment map, embedded
+	// or referenced by its path relative to the scene file.
+	Image *hdrData `json:"image,omitempty"`
+}
+
+type hdrData struct {
+	Data []byte `json:"data,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// cameraData is a camera. Frustum are the parameters of the
+// camera.ViewFrustum option.
+type cameraData struct {
+	Type     string     `json:"type"`
+	Position [3]float32 `json:"position"`
+	Target   [3]float32 `json:"target"`
+	Up       [3]float32 `json:"up"`
+	Frustum  []float32  `json:"frustum"`
+}
+
+func vec3(v math.Vec3[float32]) [3]float32 { return [3]float32{v.X, v.Y, v.Z} }
+
+func ptr3(v math.Vec3[float32]) *[3]float32 {
+	a := vec3(v)
+	return &a
+}
+
+func rgba(c color.RGBA) [4]uint8 { return [4]uint8{c.R, c.G, c.B, c.A} }
+
+func fromVec3(a *[3]float32) math.Vec3[float32] {
+	if a == nil {
+		return math.Vec3[float32]{}
+	}
+	return math.NewVec3(a[0], a[1], a[2])
+}
+
+func fromRGBA(a [4]uint8) color.RGBA { return color.RGBA{R: a[0], G: a[1], B: a[2], A: a[3]} }
+
+// sceneWriter builds the document of a scene file.
+type sceneWriter struct {
+	f        sceneFile
+	dir      string
+	opt      *saveOption
+	mats     map[material.Material]int
+	textures map[*buffer.Texture]int
+}
+
+func (w *sceneWriter) object(o object.Object[float32]) (node, error) {
+	n := node{Transform: newTransform(o.ModelMatrix())}
+	switch x := o.(type) {
+	case *Group:
+		n.Kind, n.Name = kindGroup, x.Name()
+		if x.source != "" && w.opt.reference {
+			if err := checkSource(x); err != nil {
+				return n, err
+			}
+			n.Source = w.path(x.source)
+			return n, nil
+		}
+		for _, c := range x.objects {
+			cn, err := w.object(c)
+			if err != nil {
+				return n, err
+			}
+			n.Children = append(n.Children, cn)
+		}
+	case *geometry.Geometry:
+		n.Kind = kindGeometry
+		n.Mesh = newMeshData(x)
+		for _, m := range x.Materials() {
+			i, err := w.material(m)
+			if err != nil {
+				return n, err
+			}
+			n.Materials = append(n.Materials, i)
+		}
+	case camera.Interface:
+		n.Kind = kindCamera
+		n.Camera = &cameraData{Position: vec3(x.Position())}
+		target, up := x.LookAt()
+		n.Camera.Target, n.Camera.Up = vec3(target), vec3(up)
+		switch c := x.(type) {
+		case *camera.Perspective:
+			n.Camera.Type, n.Camera.Frustum = "perspective", c.ViewFrustum()
+		case *camera.Orthographic:
+			n.Camera.Type, n.Camera.Frustum = "orthographic", c.ViewFrustum()
+		default:
+			return n, fmt.Errorf("unsupported camera %T", x)
+		}
+	case light.Light:
+		n.Kind = kindLight
+		l, err := w.light(x)
+		if err != nil {
+			return n, err
+		}
+		n.Light = l
+	default:
+		return n, fmt.Errorf("unsupported object %T", o)
+	}
+	return n, nil
+}
+
+// checkSource loads the model file of the given group again and reports an
+// error unless its objects are written the same as those of the group. The
+// transform and the name of the group itself are written with the
+// reference, hence they may differ.
+func checkSource(g *Group) error {
+	load, err := loader(g.source)
+	if err != nil {
+		return err
+	}
+	orig, err := load(g.source)
+	if err != nil {
+		return err
+	}
+	have, err := groupContents(g)
+	if err != nil {
+		return err
+	}
+	want, err := groupContents(orig)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(have, want) {
+		return fmt.Errorf("group %q was changed since it was loaded from %v", g.Name(), g.source)
+	}
+	return nil
+}
+
+// groupContents returns the objects of a group, with the materials and
+// textures they use, as Save writes them.
+func groupContents(g *Group) (sceneFile, error) {
+	w := &sceneWriter{
+		opt:      &saveOption{},
+		mats:     map[material.Material]int{},
+		textures: map[*buffer.Texture]int{},
+	}
+	for _, o := range g.objects {
+		n, err := w.object(o)
+		if err != nil {
+			return w.f, err
+		}
+		w.f.Root.Children = append(w.f.Root.Children, n)
+	}
+	return w.f, nil
+}
+
+// path returns a path relative to the directory of the scene file if
+// possible, with forward slashes.
+func (w *sceneWriter) path(p string) string {
+	dir, err1 := filepath.Abs(w.dir)
+	abs, err2 := filepath.Abs(p)
+	if err1 != nil || err2 != nil {
+		return filepath.ToSlash(p)
+	}
+	if rel, err := filepath.Rel(dir, abs); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(abs)
+}
+
+func newMeshData(g *geometry.Geometry) *meshData {
+	type corner struct {
+		pos, nor [3]float32
+		uv       [2]float32
+		col      [4]uint8
+		tan      [4]float32
+	}
+
+	md := &meshData{}
+	var normals, uvs, colors, tangents bool
+	seen := map[corner]int{}
+	for _, t := range g.Triangles() {
+		for _, v := range []*primitive.Vertex{t.V1, t.V2, t.V3} {
+			c := corner{
+				pos: [3]float32{v.Pos.X, v.Pos.Y, v.Pos.Z},
+				nor: [3]float32{v.Nor.X, v.Nor.Y, v.Nor.Z},
+				uv:  [2]float32{v.UV.X, v.UV.Y},
+				col: rgba(v.Col),
+				tan: [4]float32{v.Tan.X, v.Tan.Y, v.Tan.Z, v.Tan.W},
+			}
+			i, ok := seen[c]
+			if !ok {
+				i = len(md.Positions) / 3
+				seen[c] = i
+				md.Positions = append(md.Positions, c.pos[:]...)
+				md.Normals = append(md.Normals, c.nor[:]...)
+				md.Uvs = append(md.Uvs, c.uv[:]...)
+				md.Colors = append(md.Colors, int(c.col[0]), int(c.col[1]), int(c.col[2]), int(c.col[3]))
+				md.Tangents = append(md.Tangents, c.tan[:]...)
+				normals = normals || c.nor != [3]float32{}
+				uvs = uvs || c.uv != [2]float32{}
+				colors = colors || v.Col != color.White
+				tangents = tangents || c.tan != [4]float32{}
+			}
+			md.Indices = append(md.Indices, i)
+		}
+		md.Materials = append(md.Materials, t.MaterialID)
+	}
+	if !normals {
+		md.Normals = nil
+	}
+	if !uvs {
+		md.Uvs = nil
+	}
+	if !colors {
+		md.Colors = nil
+	}
+	if !tangents {
+		md.Tangents = nil
+	}
+	return md
+}
+
+// material adds a material to the file, once, and returns its index.
+func (w *sceneWriter) material(m material.Material) (int, error) {
+	if i, ok := w.mats[m]; ok {
+		return i, nil
+	}
+	md := materialData{}
+	if m == material.Material(material.Default()) {
+		md.Default = true
+	} else {
+		std := material.StandardOf(m)
+		if std == nil {
+			return 0, fmt.Errorf("unsupported material %T", m)
+		}
+		md.Name = m.Name()
+		md.FlatShading, md.AmbientOcclusion, md.ReceiveShadow = std.FlatShading, std.AmbientOcclusion, std.ReceiveShadow
+		md.Opacity = std.Opacity
+		if std.Blend != color.BlendSrcOver {
+			md.Blend = std.Blend.String()
+		}
+		md.Texture, md.NormalMap, md.AlphaMap = w.texture(std.Texture), w.texture(std.NormalMap), w.texture(std.AlphaMap)
+		switch x := m.(type) {
+		case *material.BlinnPhong:
+			md.BlinnPhong = &blinnPhongData{
+				Ambient:     rgba(x.Ambient),
+				Diffuse:     rgba(x.Diffuse),
+				Specular:    rgba(x.Specular),
+				Emissive:    rgba(x.Emissive),
+				Shininess:   x.Shininess,
+				SpecularMap: w.texture(x.SpecularMap),
+			}
+		case *material.PBR:
+			md.PBR = &pbrData{
+				BaseColor:            rgba(x.BaseColor),
+				Metallic:             x.Metallic,
+				Roughness:            x.Roughness,
+				Emissive:             rgba(x.Emissive),
+				MetallicRoughnessMap: w.texture(x.MetallicRoughnessMap),
+				OcclusionMap:         w.texture(x.OcclusionMap),
+				EmissiveMap:          w.texture(x.EmissiveMap),
+			}
+		}
+	}
+	i := len(w.f.Materials)
+	w.f.Materials = append(w.f.Materials, md)
+	w.mats[m] = i
+	return i, nil
+}
+
+// texture adds a texture to the file, once, and returns its index, or
+// nil for a nil texture.
+func (w *sceneWriter) texture(t *buffer.Texture) *int {
+	if t == nil {
+		return nil
+	}
+	i, ok := w.textures[t]
+	if !ok {
+		td := textureData{Mipmap: t.UseMipmap()}
+		if path, srgb := t.Source(); path != "" && w.opt.reference {
+			td.Path, td.SRGB = w.path(path), srgb
+		} else {
+			var b bytes.Buffer
+			png.Encode(&b, t.Mipmap()[0]) // writing to a buffer does not fail
+			td.Data = b.Bytes()
+		}
+		i = len(w.f.Textures)
+		w.f.Textures = append(w.f.Textures, td)
+		w.textures[t] = i
+	}
+	return &i
+}
+
+func (w *sceneWriter) light(l light.Light) (*lightData, error) {
+	ld := &lightData{Color: rgba(l.Color())}
+	switch x := l.(type) {
+	case *light.Point:
+		ld.Type, ld.Intensity = "point", x.Intensity()
+		ld.Position, ld.CastShadow = ptr3(x.Position()), x.CastShadow()
+	case *light.Directional:
+		ld.Type, ld.Intensity = "directional", x.Intensity()
+		ld.Position, ld.Direction, ld.CastShadow = ptr3(x.Position()), ptr3(x.Dir()), x.CastShadow()
+	case *light.Spot:
+		ld.Type, ld.Intensity = "spot", x.Intensity()
+		ld.Position, ld.Direction, ld.CastShadow = ptr3(x.Position()), ptr3(x.Dir()), x.CastShadow()
+		inner, outer := x.Cone()
+		ld.Cone, ld.Range = &[2]float32{inner, outer}, x.Range()
+	case *light.Area:
+		ld.Type, ld.Intensity = "area", x.Intensity()
+		ld.Position, ld.Direction, ld.CastShadow = ptr3(x.Position()), ptr3(x.Dir()), x.CastShadow()
+		width, height := x.Size()
+		ld.Size = &[2]float32{width, height}
+	case *light.Ambient:
+		ld.Type, ld.Intensity = "ambient", x.Intensity()
+	case *light.EnvMap:
+		ld.Type, ld.Intensity = "envmap", x.Intensity()
+		var b bytes.Buffer
+		if err := buffer.EncodeHDR(&b, x.Image()); err != nil {
+			return nil, err
+		}
+		ld.Image = &hdrData{Data: b.Bytes()}
+	default:
+		return nil, fmt.Errorf("unsupported light %T", l)
+	}
+	return ld, nil
+}
+
+// sceneReader creates the objects of a scene file. Materials and textures
+// are created once, when a node first references them.
+type sceneReader struct {
+	f        sceneFile
+	dir      string
+	mats     []material.Material
+	textures []*buffer.Texture
+}
+
+func (r *sceneReader) object(n *node) (object.Object[float32], error) {
+	var o object.Object[float32]
+	switch n.Kind {
+	case kindGroup:
+		g, err := r.group(n)
+		if err != nil {
+			return nil, err
+		}
+		o = g
+	case kindGeometry:
+		g, err := r.geometry(n)
+		if err != nil {
+			return nil, err
+		}
+		o = g
+	case kindLight:
+		l, err := r.light(n.Light)
+		if err != nil {
+			return nil, err
+		}
+		o = l
+	case kindCamera:
+		c, err := r.camera(n.Camera)
+		if err != nil {
+			return nil, err
+		}
+		o = c
+	default:
+		return nil, fmt.Errorf("unsupported node kind %q", n.Kind)
+	}
+	if t, ok := o.(interface{ Transform(math.Mat4[float32]) }); ok && n.Transform != nil {
+		t.Transform(n.Transform.mat4())
+	}
+	return o, nil
+}
+
+func (r *sceneReader) group(n *node) (*Group, error) {
+	var g *Group
+	if n.Source != "" {
+		p := r.path(n.Source)
+		load, err := loader(p)
+		if err != nil {
+			return nil, err
+		}
+		if g, err = load(p); err != nil {
+			return nil, err
+		}
+		g.source = p
+	} else {
+		g = NewGroup()
+		for i := range n.Children {
+			o, err := r.object(&n.Children[i])
+			if err != nil {
+				return nil, err
+			}
+			g.Add(o)
+		}
+	}
+	if n.Name != "" {
+		g.SetName(n.Name)
+	}
+	return g, nil
+}
+
+// path returns the path of a file referenced relative to the scene file.
+func (r *sceneReader) path(p string) string {
+	p = filepath.FromSlash(p)
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(r.dir, p)
+}
+
+func (r *sceneReader) geometry(n *node) (*geometry.Geometry, error) {
+	md := n.Mesh
+	if md == nil || len(md.Indices) == 0 {
+		return nil, errors.New("geometry without triangles")
+	}
+	nv := len(md.Positions) / 3
+	switch {
+	case len(md.Positions) != 3*nv,
+		md.Normals != nil && len(md.Normals) != 3*nv,
+		md.Uvs != nil && len(md.Uvs) != 2*nv,
+		md.Colors != nil && len(md.Colors) != 4*nv,
+		md.Tangents != nil && len(md.Tangents) != 4*nv:
+		return nil, fmt.Errorf("mesh attributes of %d vertices", nv)
+	case len(md.Indices)%3 != 0 || len(md.Materials) != len(md.Indices)/3:
+		return nil, fmt.Errorf("mesh of %d indices and %d triangle materials", len(md.Indices), len(md.Materials))
+	}
+
+	mats := make([]material.Material, len(n.Materials))
+	for i, mi := range n.Materials {
+		m, err := r.material(mi)
+		if err != nil {
+			return nil, err
+		}
+		mats[i] = m
+	}
+
+	vertex := func(i int) (*primitive.Vertex, error) {
+		if i < 0 || i >= nv {
+			return nil, fmt.Errorf("vertex index %d out of range", i)
+		}
+		v := primitive.NewVertex()
+		v.Pos = math.NewVec4(md.Positions[3*i], md.Positions[3*i+1], md.Positions[3*i+2], 1)
+		if md.Normals != nil {
+			v.Nor = math.NewVec4(md.Normals[3*i], md.Normals[3*i+1], md.Normals[3*i+2], 0)
+		}
+		if md.Uvs != nil {
+			v.UV = math.NewVec2(md.Uvs[2*i], md.Uvs[2*i+1])
+		}
+		v.Col = color.White
+		if md.Colors != nil {
+			c := md.Colors[4*i : 4*i+4]
+			v.Col = color.RGBA{R: uint8(c[0]), G: uint8(c[1]), B: uint8(c[2]), A: uint8(c[3])}
+		}
+		if md.Tangents != nil {
+			v.Tan = math.NewVec4(md.Tangents[4*i], md.Tangents[4*i+1], md.Tangents[4*i+2], md.Tangents[4*i+3])
+		}
+		return v, nil
+	}
+	tris := make([]*primitive.Triangle, len(md.Materials))
+	for i := range tris {
+		var vs [3]*primitive.Vertex
+		for j := range vs {
+			v, err := vertex(md.Indices[3*i+j])
+			if err != nil {
+				return nil, err
+			}
+			vs[j] = v
+		}
+		if md.Materials[i] >= int64(len(mats)) {
+			return nil, fmt.Errorf("material %d of a geometry with %d materials", md.Materials[i], len(mats))
+		}
+		tris[i] = &primitive.Triangle{V1: vs[0], V2: vs[1], V3: vs[2], MaterialID: md.Materials[i]}
+	}
+	return geometry.New(mesh.NewTriangleMesh(tris), mats...), nil
+}
+
+func (r *sceneReader) material(i int) (material.Material, error) {
+	if i < 0 || i >= len(r.mats) {
+		return nil, fmt.Errorf("material %d out of range", i)
+	}
+	if r.mats[i] != nil {
+		return r.mats[i], nil
+	}
+	md := &r.f.Materials[i]
+	if md.Default {
+		r.mats[i] = material.Default()
+		return r.mats[i], nil
+	}
+
+	blend, err := blendMode(md.Blend)
+	if err != nil {
+		return nil, err
+	}
+	tex := func(i *int) *buffer.Texture {
+		if i == nil || err != nil {
+			return nil
+		}
+		var t *buffer.Texture
+		t, err = r.texture(*i)
+		return t
+	}
+	opts := []material.Option{
+		material.Name(md.Name),
+		material.FlatShading(md.FlatShading),
+		material.AmbientOcclusion(md.AmbientOcclusion),
+		material.ReceiveShadow(md.ReceiveShadow),
+		material.Opacity(md.Opacity),
+		material.Blend(blend),
+	}
+	if t := tex(md.Texture); t != nil {
+		opts = append(opts, material.Texture(t))
+	}
+	if t := tex(md.NormalMap); t != nil {
+		opts = append(opts, material.NormalMap(t))
+	}
+	if t := tex(md.AlphaMap); t != nil {
+		opts = append(opts, material.AlphaMap(t))
+	}
+
+	var m material.Material
+	switch {
+	case md.BlinnPhong != nil:
+		d := md.BlinnPhong
+		bp := material.NewBlinnPhong(opts...)
+		bp.Ambient, bp.Diffuse, bp.Specular, bp.Emissive = fromRGBA(d.Ambient), fromRGBA(d.Diffuse), fromRGBA(d.Specular), fromRGBA(d.Emissive)
+		bp.Shininess = d.Shininess
+		bp.SpecularMap = tex(d.SpecularMap)
+		m = bp
+	case md.PBR != nil:
+		d := md.PBR
+		pbr := material.NewPBR(opts...)
+		pbr.BaseColor, pbr.Emissive = fromRGBA(d.BaseColor), fromRGBA(d.Emissive)
+		pbr.Metallic, pbr.Roughness = d.Metallic, d.Roughness
+		pbr.MetallicRoughnessMap = tex(d.MetallicRoughnessMap)
+		pbr.OcclusionMap = tex(d.OcclusionMap)
+		pbr.EmissiveMap = tex(d.EmissiveMap)
+		m = pbr
+	default:
+		return nil, fmt.Errorf("material %d is neither Blinn-Phong nor PBR", i)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.mats[i] = m
+	return m, nil
+}
+
+// blendMode returns the blend mode of the given name, or the "source over"
+// operator for an empty name.
+func blendMode(name string) (color.BlendMode, error) {
+	if name == "" {
+		return color.BlendSrcOver, nil
+	}
+	for m := color.BlendMode(0); m.String() != "unknown"; m++ {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown blend mode %q", name)
+}
+
+func (r *sceneReader) texture(i int) (*buffer.Texture, error) {
+	if i < 0 || i >= len(r.textures) {
+		return nil, fmt.Errorf("texture %d out of range", i)
+	}
+	if r.textures[i] != nil {
+		return r.textures[i], nil
+	}
+	td := &r.f.Textures[i]
+	var img *image.RGBA
+	src := buffer.TextureSource("", false)
+	switch {
+	case td.Data != nil:
+		src, err := png.Decode(bytes.NewReader(td.Data))
+		if err != nil {
+			return nil, err
+		}
+		// Images with transparency decode as non-premultiplied colors.
+		img = image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+		draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+	case td.Path != "":
+		var err error
+		if img, err = imageutil.LoadImage(r.path(td.Path), imageutil.GammaCorrect(td.SRGB)); err != nil {
+			return nil, err
+		}
+		src = buffer.TextureSource(r.path(td.Path), td.SRGB)
+	default:
+		return nil, fmt.Errorf("texture %d without an image", i)
+	}
+	r.textures[i] = buffer.NewTexture(buffer.TextureImage(img), buffer.TextureIsoMipmap(td.Mipmap), src)
+	return r.textures[i], nil
+}
+
+func (r *sceneReader) light(ld *lightData) (light.Light, error) {
+	if ld == nil {
+		return nil, errors.New("light node without a light")
+	}
+	opts := []light.Option{light.Color(fromRGBA(ld.Color)), light.Intensity(ld.Intensity)}
+	switch ld.Type {
+	case "point":
+		opts = append(opts, light.Position(fromVec3(ld.Position)), light.CastShadow(ld.CastShadow))
+		return light.NewPoint(opts...), nil
+	case "directional":
+		opts = append(opts, light.Position(fromVec3(ld.Position)), light.Direction(fromVec3(ld.Direction)), light.CastShadow(ld.CastShadow))
+		return light.NewDirectional(opts...), nil
+	case "spot":
+		opts = append(opts, light.Position(fromVec3(ld.Position)), light.Direction(fromVec3(ld.Direction)), light.CastShadow(ld.CastShadow), light.Range(ld.Range))
+		if ld.Cone != nil {
+			opts = append(opts, light.Cone(ld.Cone[0], ld.Cone[1]))
+		}
+		return light.NewSpot(opts...), nil
+	case "area":
+		opts = append(opts, light.Position(fromVec3(ld.Position)), light.Direction(fromVec3(ld.Direction)), light.CastShadow(ld.CastShadow))
+		if ld.Size != nil {
+			opts = append(opts, light.Size(ld.Size[0], ld.Size[1]))
+		}
+		return light.NewArea(opts...), nil
+	case "ambient":
+		return light.NewAmbient(opts...), nil
+	case "envmap":
+		var img *buffer.HDR
+		var err error
+		switch {
+		case ld.Image == nil:
+			return nil, errors.New("environment map without an image")
+		case ld.Image.Data != nil:
+			img, err = buffer.DecodeHDR(bytes.NewReader(ld.Image.Data))
+		default:
+			img, err = buffer.LoadHDR(r.path(ld.Image.Path))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return light.NewEnvMap(img, opts...), nil
+	default:
+		return nil, fmt.Errorf("unsupported light type %q", ld.Type)
+	}
+}
+
+func (r *sceneReader) camera(cd *cameraData) (camera.Interface, error) {
+	if cd == nil {
+		return nil, errors.New("camera node without a camera")
+	}
+	opts := []camera.Option{
+		camera.Position(math.NewVec3(cd.Position[0], cd.Position[1], cd.Position[2])),
+		camera.LookAt(math.NewVec3(cd.Target[0], cd.Target[1], cd.Target[2]), math.NewVec3(cd.Up[0], cd.Up[1], cd.Up[2])),
+	}
+	switch {
+	case cd.Type == "perspective" && len(cd.Frustum) == 4,
+		cd.Type == "orthographic" && len(cd.Frustum) == 6:
+		opts = append(opts, camera.ViewFrustum(cd.Frustum...))
+	default:
+		return nil, fmt.Errorf("unsupported %v camera with %d frustum parameters", cd.Type, len(cd.Frustum))
+	}
+	if cd.Type == "perspective" {
+		return camera.NewPerspective(opts...), nil
+	}
+	return camera.NewOrthographic(opts...), nil
+}
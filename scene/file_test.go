@@ -0,0 +1,428 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package scene_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"poly.red/buffer"
+	"poly.red/camera"
+	"poly.red/color"
+	"poly.red/geometry"
+	"poly.red/geometry/mesh"
+	"poly.red/geometry/primitive"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+	"poly.red/model"
+	"poly.red/scene"
+	"poly.red/scene/object"
+)
+
+// fileScene returns a scaled scene with a rotated group of a textured PBR
+// quad with a normal map, a vertex colored plane, a referenced model, all
+// kinds of lights and both kinds of cameras.
+func fileScene(t *testing.T) *scene.Scene {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	copy(img.Pix, []uint8{
+		0xff, 0, 0, 0xff, 0, 0x40, 0, 0xff,
+		0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	})
+	tex := buffer.NewTexture(buffer.TextureImage(img), buffer.TextureIsoMipmap(true))
+	v := func(x, z, u, w float32) *primitive.Vertex {
+		return primitive.NewVertex(
+			primitive.Pos(math.NewVec4(x, 0, z, 1)),
+			primitive.UV(math.NewVec2(u, w)),
+			primitive.Nor(math.NewVec4[float32](0, 1, 0, 0)),
+		)
+	}
+	v1, v2, v3, v4 := v(-1, -1, 0, 1), v(-1, 1, 0, 0), v(1, 1, 1, 0), v(1, -1, 1, 1)
+	quad := geometry.New(mesh.NewTriangleMesh([]*primitive.Triangle{
+		{V1: v1, V2: v2, V3: v3, MaterialID: 0},
+		{V1: v1, V2: v3, V3: v4, MaterialID: 1},
+	}), material.NewPBR(
+		material.Name("paint"),
+		material.BaseColor(color.FromValue(0.2, 0.4, 0.6, 1)),
+		material.Metallic(0.25),
+		material.Roughness(0.5),
+		material.Texture(tex),
+		material.NormalMap(buffer.NewUniformTexture(color.RGBA{R: 0x80, G: 0x80, B: 0xff, A: 0xff})),
+	), material.NewBlinnPhong(
+		material.Name("glass"),
+		material.Texture(tex),
+		material.Specular(color.White),
+		material.Shininess(32),
+		material.Opacity(0.5),
+		material.Blend(color.BlendMultiply),
+	))
+	quad.Translate(0, 1, 0)
+
+	child := scene.NewGroup(quad, geometry.New(model.NewPlane(1, 1)))
+	child.SetName("child")
+	child.RotateY(math.Pi / 2)
+
+	tetra, err := model.Load("../internal/testdata/tetra.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tetra.Translate(3, 0, 0)
+
+	// The environment is decoded from the RGBE format, which then keeps it.
+	hdr := buffer.NewHDR(8, 4)
+	for i := 0; i < 8; i++ {
+		hdr.Set(i, i%4, math.NewVec4(float32(i), 0.5, 2, 0))
+	}
+	var b bytes.Buffer
+	if err := buffer.EncodeHDR(&b, hdr); err != nil {
+		t.Fatal(err)
+	}
+	env, err := buffer.DecodeHDR(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := scene.NewScene(child, tetra,
+		light.NewPoint(light.Intensity(3), light.Position(math.NewVec3[float32](0, 2, 1)), light.CastShadow(true)),
+		light.NewDirectional(light.Direction(math.NewVec3[float32](0, -1, 0)), light.Color(color.Red)),
+		light.NewSpot(light.Position(math.NewVec3[float32](0, 3, 0)), light.Cone(10, 20), light.Range(5)),
+		light.NewArea(light.Position(math.NewVec3[float32](0, 2, 0)), light.Size(1, 0.5)),
+		light.NewAmbient(light.Intensity(0.3)),
+		light.NewEnvMap(env, light.Intensity(0.5)),
+		camera.NewPerspective(
+			camera.Position(math.NewVec3[float32](0, 1.5, 1)),
+			camera.LookAt(math.NewVec3[float32](0, 0, 0), math.NewVec3[float32](0, 1, 0)),
+			camera.ViewFrustum(45, 1, 0.1, 5),
+		),
+		camera.NewOrthographic(camera.ViewFrustum(-2, 2, -1, 1, 1, -3)),
+	)
+	s.Add().Scale(2, 2, 2)
+	return s
+}
+
+// fileObjects returns the objects of a scene, where geometries are their
+// world space triangles and other objects their type.
+func fileObjects(s *scene.Scene) []string {
+	var out []string
+	s.IterObjects(func(o object.Object[float32], m math.Mat4[float32]) bool {
+		g, ok := o.(*geometry.Geometry)
+		if !ok {
+			out = append(out, fmt.Sprintf("%T", o))
+			return true
+		}
+		m = m.MulM(g.ModelMatrix())
+		for _, t := range g.Triangles() {
+			var b strings.Builder
+			for _, v := range []*primitive.Vertex{t.V1, t.V2, t.V3} {
+				p := m.MulV(v.Pos)
+				fmt.Fprintf(&b, "%.3f %.3f %.3f %v %v %v %v|", p.X, p.Y, p.Z, v.Nor, v.UV, v.Col, v.Tan)
+			}
+			fmt.Fprintf(&b, "%d", t.MaterialID)
+			out = append(out, b.String())
+		}
+		return true
+	})
+	return out
+}
+
+// fileMaterials returns the materials of the geometries of a scene, with
+// their textures replaced by their images.
+func fileMaterials(s *scene.Scene) []string {
+	tex := func(t *buffer.Texture) string {
+		if t == nil {
+			return "nil"
+		}
+		return fmt.Sprintf("%v %v", t.UseMipmap(), t.Mipmap()[0].Pix)
+	}
+	var out []string
+	s.IterGeometry(func(g *geometry.Geometry, _ math.Mat4[float32]) bool {
+		for _, m := range g.Materials() {
+			std := material.StandardOf(m)
+			switch x := m.(type) {
+			case *material.BlinnPhong:
+				c := *x
+				c.Texture, c.NormalMap, c.AlphaMap, c.SpecularMap = nil, nil, nil, nil
+				out = append(out, fmt.Sprintf("%s %+v %s %s %s %s", m.Name(), c, tex(std.Texture), tex(std.NormalMap), tex(std.AlphaMap), tex(x.SpecularMap)))
+			case *material.PBR:
+				c := *x
+				c.Texture, c.NormalMap, c.AlphaMap = nil, nil, nil
+				c.MetallicRoughnessMap, c.OcclusionMap, c.EmissiveMap = nil, nil, nil
+				out = append(out, fmt.Sprintf("%s %+v %s %s %s", m.Name(), c, tex(std.Texture), tex(std.NormalMap), tex(std.AlphaMap)))
+			}
+		}
+		return true
+	})
+	return out
+}
+
+// fileGroups returns the names of the groups in a group, and the absolute
+// paths of their sources unless the models are embedded.
+func fileGroups(g *scene.Group, embed bool) []string {
+	var out []string
+	for _, o := range g.Objects() {
+		c, ok := o.(*scene.Group)
+		if !ok {
+			continue
+		}
+		src := ""
+		if c.Source() != "" && !embed {
+			src, _ = filepath.Abs(c.Source())
+		}
+		out = append(out, c.Name()+" "+src)
+		out = append(out, fileGroups(c, embed)...)
+	}
+	return out
+}
+
+// TestSaveLoad saves a scene, with and without embedding its models, and
+// checks that Load reads the same objects back.
+func TestSaveLoad(t *testing.T) {
+	s := fileScene(t)
+	for _, embed := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "scene.json")
+		if err := scene.Save(path, s, scene.ReferenceModels(!embed)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := scene.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := fileObjects(got), fileObjects(s); !reflect.DeepEqual(have, want) {
+			t.Fatalf("embed %v: objects\n%s\nwant\n%s", embed, strings.Join(have, "\n"), strings.Join(want, "\n"))
+		}
+		if have, want := fileMaterials(got), fileMaterials(s); !reflect.DeepEqual(have, want) {
+			t.Fatalf("embed %v: materials\n%s\nwant\n%s", embed, strings.Join(have, "\n"), strings.Join(want, "\n"))
+		}
+
+		// Referenced models keep their source.
+		if have, want := fileGroups(got.Add(), embed), fileGroups(s.Add(), embed); !reflect.DeepEqual(have, want) {
+			t.Fatalf("embed %v: groups %q, want %q", embed, have, want)
+		}
+
+		// The lights and cameras are equal.
+		same := func(o object.Object[float32]) bool {
+			return o.Type() == object.TypeLight || o.Type() == object.TypeCamera
+		}
+		var have, want []object.Object[float32]
+		got.IterObjects(func(o object.Object[float32], _ math.Mat4[float32]) bool {
+			if same(o) {
+				have = append(have, o)
+			}
+			return true
+		})
+		s.IterObjects(func(o object.Object[float32], _ math.Mat4[float32]) bool {
+			if same(o) {
+				want = append(want, o)
+			}
+			return true
+		})
+		if len(have) != len(want) {
+			t.Fatalf("embed %v: %d lights and cameras, want %d", embed, len(have), len(want))
+		}
+		for i := range want {
+			if !reflect.DeepEqual(have[i], want[i]) {
+				t.Fatalf("embed %v: %T\n%+v\nwant\n%+v", embed, want[i], have[i], want[i])
+			}
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(b), `"source"`) == embed {
+			t.Fatalf("embed %v: file references a model %v", embed, !embed)
+		}
+	}
+}
+
+// TestSaveChangedModel changes a geometry of a loaded model: Save writes
+// the change by default, whereas a reference to the model file would lose
+// it and is an error.
+func TestSaveChangedModel(t *testing.T) {
+	tetra, err := model.Load("../internal/testdata/tetra.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tetra.Objects()[0].(*geometry.Geometry).Translate(0, 1, 0)
+	s := scene.NewScene(tetra)
+
+	path := filepath.Join(t.TempDir(), "scene.json")
+	if err := scene.Save(path, s, scene.ReferenceModels(true)); err == nil {
+		t.Fatal("referencing a changed model: want an error")
+	}
+	if err := scene.Save(path, s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := scene.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := fileObjects(got), fileObjects(s); !reflect.DeepEqual(have, want) {
+		t.Fatalf("objects\n%s\nwant\n%s", strings.Join(have, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// fixture is a scene file as written by hand, with a triangle textured by
+// an image file, a camera and a light.
+const fixture = `{
+	"version": 1,
+	"root": {
+		"kind": "group",
+		"children": [
+			{
+				"kind": "geometry",
+				"transform": [1, 0, 0, 2, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1],
+				"mesh": {
+					"positions": [0, 0, 0, 1, 0, 0, 0, 1, 0],
+					"uvs": [0, 0, 1, 0, 0, 1],
+					"indices": [0, 1, 2],
+					"materials": [0]
+				},
+				"materials": [0]
+			},
+			{"kind": "camera", "camera": {"type": "perspective", "position": [0, 0, 3], "target": [0, 0, 0], "up": [0, 1, 0], "frustum": [45, 1, 0.1, 10]}},
+			{"kind": "light", "light": {"type": "ambient", "color": [255, 255, 255, 255], "intensity": 0.5}}
+		]
+	},
+	"materials": [{"name": "red", "opacity": 1, "texture": 0, "blinn_phong": {"diffuse": [255, 255, 255, 255], "shininess": 8}}],
+	"textures": [{"path": "textures/red.png", "srgb": true}]
+}`
+
+// TestLoadFixture loads a scene file that references a texture by its
+// path, relative to the scene file.
+func TestLoadFixture(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "textures"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	copy(img.Pix, []uint8{0xff, 0x80, 0, 0xff})
+	w, err := os.Create(filepath.Join(dir, "textures", "red.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(w, img); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	path := filepath.Join(dir, "scene.json")
+	if err := os.WriteFile(path, []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := scene.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var geos []*geometry.Geometry
+	var cams []camera.Interface
+	var lights []light.Light
+	s.IterObjects(func(o object.Object[float32], _ math.Mat4[float32]) bool {
+		switch x := o.(type) {
+		case *geometry.Geometry:
+			geos = append(geos, x)
+		case camera.Interface:
+			cams = append(cams, x)
+		case light.Light:
+			lights = append(lights, x)
+		}
+		return true
+	})
+	if len(geos) != 1 || len(cams) != 1 || len(lights) != 1 {
+		t.Fatalf("got %d geometries, %d cameras and %d lights", len(geos), len(cams), len(lights))
+	}
+	if p := geos[0].ModelMatrix().MulV(geos[0].Triangles()[0].V2.Pos); p != math.NewVec4[float32](3, 0, 0, 1) {
+		t.Fatalf("translated vertex %v, want (3, 0, 0, 1)", p)
+	}
+	m := geos[0].Materials()[0].(*material.BlinnPhong)
+	if m.Name() != "red" || m.Shininess != 8 {
+		t.Fatalf("material %+v", m)
+	}
+	// The sRGB texels are converted to linear.
+	if got := m.Texture.Mipmap()[0].Pix; got[0] != 0xff || got[1] >= 0x80 {
+		t.Fatalf("texel %v, want a linear orange", got)
+	}
+	if cams[0].Fov() != 45 || cams[0].Position() != math.NewVec3[float32](0, 0, 3) {
+		t.Fatalf("camera fov %v, position %v", cams[0].Fov(), cams[0].Position())
+	}
+}
+
+// TestSaveTextureSource saves the fixture, whose texture was loaded from an
+// image file: the texture is embedded by default, and references the file
+// with ReferenceModels.
+func TestSaveTextureSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "textures"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := os.Create(filepath.Join(dir, "textures", "red.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(w, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	path := filepath.Join(dir, "scene.json")
+	if err := os.WriteFile(path, []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := scene.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reference := range []bool{false, true} {
+		out := filepath.Join(dir, fmt.Sprintf("saved-%v.json", reference))
+		if err := scene.Save(out, s, scene.ReferenceModels(reference)); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(b), `"path": "textures/red.png"`); got != reference {
+			t.Fatalf("reference %v: file references the texture %v", reference, got)
+		}
+		if got := strings.Contains(string(b), `"data"`); got == reference {
+			t.Fatalf("reference %v: file embeds the texture %v", reference, got)
+		}
+		if _, err := scene.Load(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"version":  strings.Replace(fixture, `"version": 1`, `"version": 2`, 1),
+		"kind":     strings.Replace(fixture, `"kind": "geometry"`, `"kind": "mesh"`, 1),
+		"index":    strings.Replace(fixture, `"indices": [0, 1, 2]`, `"indices": [0, 1, 3]`, 1),
+		"material": strings.Replace(fixture, `"materials": [0]`, `"materials": [1]`, 1),
+		"texture":  strings.Replace(fixture, `textures/red.png`, `textures/missing.png`, 1),
+		"camera":   strings.Replace(fixture, `[45, 1, 0.1, 10]`, `[45, 1, 0.1]`, 1),
+		"light":    strings.Replace(fixture, `"type": "ambient"`, `"type": "laser"`, 1),
+		"blend":    strings.Replace(fixture, `"opacity": 1,`, `"opacity": 1, "blend": "unknown",`, 1),
+		"format":   strings.Replace(fixture, `"kind": "geometry",`, `"kind": "group", "source": "model.xyz",`, 1),
+		"json":     fixture[:len(fixture)/2],
+	}
+	for name, src := range tests {
+		path := filepath.Join(t.TempDir(), "scene.json")
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := scene.Load(path); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
@@ -18,6 +18,19 @@ func (g *Group) SetName(name string) { g.name = name }
 
 func (g *Group) Type() object.Type { return object.TypeGroup }
 
+// Source returns the path of the model file that the group was loaded
+// from, or an empty string. With ReferenceModels, Save references a group
+// with a source by its path instead of writing its objects.
+func (g *Group) Source() string { return g.source }
+
+// SetSource sets the path of the model file that the group was loaded from.
+func (g *Group) SetSource(path string) { g.source = path }
+
+// Objects returns the objects of the group in the order they were added.
+// Unlike IterObjects, it does not descend into subgroups, hence it exposes
+// the hierarchy of the group.
+func (g *Group) Objects() []object.Object[float32] { return g.objects }
+
 func (g *Group) AABB() primitive.AABB {
 	var (
 		aabb *primitive.AABB
@@ -14,9 +14,9 @@ import (
 )
 
 // FragmentShader is the live CPU deferred fragment shader: per-fragment
-// Blinn-Phong (ambient + point/directional diffuse and specular, texture and
-// LOD, no-lights early return). It is the renderer's CPU shading path
-// (render/raster.go). It is intentionally separate from the GPU author-once
+// Blinn-Phong (ambient + point/directional/spot/area diffuse and specular,
+// texture and LOD, no-lights early return). It is the renderer's CPU shading
+// path (render/raster.go). It is intentionally separate from the GPU author-once
 // kernel gpu/shader/gpumath/kernels.Shade, not legacy: the two are locked
 // equivalent within 1 LSB (see specs/foundations/render-shading-equivalence.md),
 // because the per-fragment CPU path and the slice-based GPU kernel want
@@ -37,7 +37,8 @@ func FragmentShader(m *material.BlinnPhong,
 
 	// When using blinn-phong, if there are no light sources, we just use
 	// the texture color.
-	if len(ls) == 0 {
+	env := EnvMapOf(es)
+	if len(ls) == 0 && env == nil {
 		return col
 	}
 
@@ -46,6 +47,9 @@ func FragmentShader(m *material.BlinnPhong,
 	LaB := float32(0.0)
 
 	for _, e := range es {
+		if _, ok := e.(*light.EnvMap); ok {
+			continue
+		}
 		LaR += e.Intensity() * float32(col.R)
 		LaG += e.Intensity() * float32(col.G)
 		LaB += e.Intensity() * float32(col.B)
@@ -64,6 +68,7 @@ func FragmentShader(m *material.BlinnPhong,
 		n = info.FaceNor
 	}
 	x := info.WordPos
+	V := c.ToVec4(1).Sub(x).Unit()
 	for _, l := range ls {
 		var (
 			L math.Vec4[float32]
@@ -77,9 +82,34 @@ func FragmentShader(m *material.BlinnPhong,
 		case *light.Directional:
 			L = ll.Dir().ToVec4(0).Scale(-1, -1, -1, 1)
 			I = ll.Intensity()
+		case *light.Spot:
+			Ldir := ll.Position().ToVec4(1).Sub(x)
+			L = Ldir.Unit()
+			I = ll.Intensity() / Ldir.Len() * ll.Attenuation(x)
+		case *light.Area:
+			// Each sample of the grid is a point light that carries its
+			// share of the intensity and emits from the front side.
+			samples := ll.Samples()
+			for _, p := range samples {
+				Ldir := p.ToVec4(1).Sub(x)
+				L = Ldir.Unit()
+				I = ll.Intensity() / Ldir.Len() * ll.Emission(p, x) / float32(len(samples))
+
+				H := L.Add(V).Unit()
+				Ld := math.Clamp(n.Dot(L), 0, 1)
+				Ls := math.Pow(math.Clamp(n.Dot(H), 0, 1), m.Shininess)
+
+				LdR += Ld * float32(col.R) * I
+				LdG += Ld * float32(col.G) * I
+				LdB += Ld * float32(col.B) * I
+
+				LsR += Ls * float32(l.Color().R) * I
+				LsG += Ls * float32(l.Color().G) * I
+				LsB += Ls * float32(l.Color().B) * I
+			}
+			continue
 		}
 
-		V := c.ToVec4(1).Sub(x).Unit()
 		H := L.Add(V).Unit()
 		Ld := math.Clamp(n.Dot(L), 0, 1)
 		Ls := math.Pow(math.Clamp(n.Dot(H), 0, 1), m.Shininess)
@@ -93,10 +123,27 @@ func FragmentShader(m *material.BlinnPhong,
 		LsB += Ls * float32(l.Color().B) * I
 	}
 
+	// The image based light is the ambient light of the irradiance, and a
+	// specular light of the radiance around the reflected view direction
+	// for the roughness that corresponds to the shininess.
+	if env != nil {
+		E := env.Irradiance(n)
+		LaR += E.X * float32(col.R)
+		LaG += E.Y * float32(col.G)
+		LaB += E.Z * float32(col.B)
+
+		R := n.Scale(2*n.Dot(V), 2*n.Dot(V), 2*n.Dot(V), 0).Sub(V)
+		Lr := env.Radiance(R, ShininessRoughness(m.Shininess))
+		LsR += Lr.X * 0xff
+		LsG += Lr.Y * 0xff
+		LsB += Lr.Z * 0xff
+	}
+
 	// The Blinn-Phong Reflection Model
-	r := math.Round(LaR + (float32(m.Diffuse.R) * LdR / 255.0) + (float32(m.Specular.R) * LsR / 255.0))
-	g := math.Round(LaG + (float32(m.Diffuse.G) * LdG / 255.0) + (float32(m.Specular.G) * LsG / 255.0))
-	b := math.Round(LaB + (float32(m.Diffuse.B) * LdB / 255.0) + (float32(m.Specular.B) * LsB / 255.0))
+	spec := m.SpecularAt(info.U, 1-info.V, info.Du, info.Dv)
+	r := math.Round(LaR + (float32(m.Diffuse.R) * LdR / 255.0) + (float32(spec.R) * LsR / 255.0))
+	g := math.Round(LaG + (float32(m.Diffuse.G) * LdG / 255.0) + (float32(spec.G) * LsG / 255.0))
+	b := math.Round(LaB + (float32(m.Diffuse.B) * LdB / 255.0) + (float32(spec.B) * LsB / 255.0))
 
 	return color.RGBA{
 		uint8(math.Clamp(r, 0, 0xff)),
@@ -104,3 +151,21 @@ func FragmentShader(m *material.BlinnPhong,
 		uint8(math.Clamp(b, 0, 0xff)),
 		uint8(math.Clamp(float32(col.A), 0, 0xff))}
 }
+
+// EnvMapOf returns the first environment map of the given environment
+// lights, or nil if there is none. It lights the scene in addition to the
+// other environment lights.
+func EnvMapOf(es []light.Environment) *light.EnvMap {
+	for _, e := range es {
+		if env, ok := e.(*light.EnvMap); ok {
+			return env
+		}
+	}
+	return nil
+}
+
+// ShininessRoughness returns the roughness of a GGX distribution that
+// resembles the Blinn-Phong distribution of the given shininess.
+func ShininessRoughness(shininess float32) float32 {
+	return math.Pow(2/(math.Max(shininess, 0)+2), 0.25)
+}
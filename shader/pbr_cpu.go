@@ -0,0 +1,149 @@
+// Copyright 2026 The Polyred Authors. All rights reserved.
+// Use of this source code is governed by a GPLv3 license that
+// can be found in the LICENSE file.
+
+package shader
+
+import (
+	"image/color"
+
+	"poly.red/buffer"
+	"poly.red/light"
+	"poly.red/material"
+	"poly.red/math"
+)
+
+// PBRFragmentShader is the CPU deferred fragment shader of metallic-roughness
+// materials: ambient light scaled by the occlusion, the emissive color, and the
+// Cook-Torrance reflection of point/directional/spot/area lights. Like
+// FragmentShader it is locked equivalent to the GPU author-once kernel
+// gpu/shader/gpumath/kernels.Shade within 1 LSB.
+func PBRFragmentShader(m *material.PBR,
+	info buffer.Fragment, c math.Vec3[float32],
+	ls []light.Source, es []light.Environment,
+) color.RGBA {
+	s := m.Surface(info.U, 1-info.V, info.Du, info.Dv)
+
+	// The colors are accumulated in [0, 255] like the Blinn-Phong shader.
+	base := s.BaseColor.Scale(0xff, 0xff, 0xff, 0xff)
+	ambientI := float32(0.0)
+	for _, e := range es {
+		if _, ok := e.(*light.EnvMap); ok {
+			continue
+		}
+		ambientI += e.Intensity()
+	}
+	r := base.X*ambientI*s.Occlusion + s.Emissive.X*0xff
+	g := base.Y*ambientI*s.Occlusion + s.Emissive.Y*0xff
+	b := base.Z*ambientI*s.Occlusion + s.Emissive.Z*0xff
+
+	n := info.Nor
+	if m.FlatShading {
+		n = info.FaceNor
+	}
+	x := info.WordPos
+	V := c.ToVec4(1).Sub(x).Unit()
+	// The image based light is the diffuse light of the irradiance and the
+	// specular light of the radiance around the reflected view direction,
+	// scaled by the split-sum approximation of the specular reflectance.
+	if env := EnvMapOf(es); env != nil {
+		E := env.Irradiance(n)
+		kd := (1 - s.Metallic) * s.Occlusion * 0xff
+		R := n.Scale(2*n.Dot(V), 2*n.Dot(V), 2*n.Dot(V), 0).Sub(V)
+		Lr := env.Radiance(R, s.Roughness)
+		A, B := EnvBRDF(math.Clamp(n.Dot(V), 0, 1), s.Roughness)
+		spec := func(base float32) float32 {
+			f0 := 0.04*(1-s.Metallic) + base*s.Metallic
+			return (f0*A + B) * s.Occlusion * 0xff
+		}
+		r += s.BaseColor.X*E.X*kd + Lr.X*spec(s.BaseColor.X)
+		g += s.BaseColor.Y*E.Y*kd + Lr.Y*spec(s.BaseColor.Y)
+		b += s.BaseColor.Z*E.Z*kd + Lr.Z*spec(s.BaseColor.Z)
+	}
+	reflect := func(l light.Source, L math.Vec4[float32], I float32) {
+		f := CookTorrance(n, L, V, s.BaseColor, s.Metallic, s.Roughness)
+		r += f.X * float32(l.Color().R) * I
+		g += f.Y * float32(l.Color().G) * I
+		b += f.Z * float32(l.Color().B) * I
+	}
+	for _, l := range ls {
+		switch ll := l.(type) {
+		case *light.Point:
+			Ldir := ll.Position().ToVec4(1).Sub(x)
+			reflect(l, Ldir.Unit(), ll.Intensity()/Ldir.Len())
+		case *light.Directional:
+			reflect(l, ll.Dir().ToVec4(0).Scale(-1, -1, -1, 1), ll.Intensity())
+		case *light.Spot:
+			Ldir := ll.Position().ToVec4(1).Sub(x)
+			reflect(l, Ldir.Unit(), ll.Intensity()/Ldir.Len()*ll.Attenuation(x))
+		case *light.Area:
+			samples := ll.Samples()
+			for _, p := range samples {
+				Ldir := p.ToVec4(1).Sub(x)
+				reflect(l, Ldir.Unit(), ll.Intensity()/Ldir.Len()*ll.Emission(p, x)/float32(len(samples)))
+			}
+		}
+	}
+
+	return color.RGBA{
+		uint8(math.Clamp(math.Round(r), 0, 0xff)),
+		uint8(math.Clamp(math.Round(g), 0, 0xff)),
+		uint8(math.Clamp(math.Round(b), 0, 0xff)),
+		uint8(math.Clamp(math.Round(base.W), 0, 0xff))}
+}
+
+// CookTorrance returns the reflectance of a metallic-roughness surface with
+// normal n and base color base in [0, 1] towards v for light incident from
+// l: a Lambertian diffuse term plus a GGX distribution, a Smith-Schlick
+// geometry term and a Schlick Fresnel term, multiplied by the cosine of the
+// incident angle. The light is scaled by pi, such that a rough dielectric
+// reflects as much as the Blinn-Phong diffuse term.
+func CookTorrance(n, l, v, base math.Vec4[float32], metallic, roughness float32) math.Vec4[float32] {
+	h := l.Add(v).Unit()
+	NdotL := math.Clamp(n.Dot(l), 0, 1)
+	NdotV := math.Clamp(n.Dot(v), 0.0001, 1)
+	NdotH := math.Clamp(n.Dot(h), 0, 1)
+	VdotH := math.Clamp(v.Dot(h), 0, 1)
+
+	// GGX normal distribution with alpha = roughness^2.
+	r := math.Clamp(roughness, 0.045, 1)
+	a2 := r * r * r * r
+	d := NdotH*NdotH*(a2-1) + 1
+	D := a2 / (math.Pi * d * d)
+
+	// Smith-Schlick geometry term for direct lighting.
+	k := (r + 1) * (r + 1) / 8
+	G := NdotV / (NdotV*(1-k) + k) * NdotL / (NdotL*(1-k) + k)
+
+	// Schlick Fresnel with the reflectance at normal incidence of 4% for
+	// dielectrics and the base color for metals.
+	fw := math.Pow(1-VdotH, 5)
+	fresnel := func(base float32) float32 {
+		f0 := 0.04*(1-metallic) + base*metallic
+		return f0*(1-fw) + fw
+	}
+	F := math.NewVec4(fresnel(base.X), fresnel(base.Y), fresnel(base.Z), 0)
+
+	spec := math.Pi * D * G / (4*NdotV*NdotL + 0.0001)
+	kd := 1 - metallic
+	return math.NewVec4(
+		((1-F.X)*kd*base.X+F.X*spec)*NdotL,
+		((1-F.Y)*kd*base.Y+F.Y*spec)*NdotL,
+		((1-F.Z)*kd*base.Z+F.Z*spec)*NdotL,
+		0,
+	)
+}
+
+// EnvBRDF returns the scale A and the bias B of the reflectance at normal
+// incidence F0, such that F0*A + B is the reflectance of a surface of the
+// given roughness, integrated over the environment, for the cosine NdotV
+// of the view direction. It is the analytic fit of Karis, "Physically Based
+// Shading on Mobile", 2014.
+func EnvBRDF(NdotV, roughness float32) (A, B float32) {
+	r0 := -1*roughness + 1
+	r1 := -0.0275*roughness + 0.0425
+	r2 := -0.572*roughness + 1.04
+	r3 := 0.022*roughness - 0.04
+	a004 := math.Min(r0*r0, math.Pow(2, -9.28*NdotV))*r0 + r1
+	return -1.04*a004 + r2, 1.04*a004 + r3
+}